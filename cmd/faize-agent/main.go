@@ -0,0 +1,47 @@
+// Command faize-agent is the guest agent baked into faize VM images.
+// It reads the session configuration from the bootstrap share and sets up
+// mounts, networking, and Claude Code, replacing the generated init script.
+// Invoked through a symlink named xclip, xsel, xdg-open, or open, it acts as
+// the corresponding clipboard or browser shim. With --reinit it re-runs the
+// re-entrant setup steps in an already running VM; with --version it prints
+// the version of its interface with the host, which the rootfs build records.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/faize-ai/faize/internal/guest/agent"
+)
+
+func main() {
+	if name := filepath.Base(os.Args[0]); agent.IsShim(name) {
+		os.Exit(agent.RunShim(name, os.Args[1:]))
+	}
+
+	configPath := flag.String("config", filepath.Join(guest.BootstrapDir, guest.ConfigFile), "agent configuration file")
+	reinit := flag.Bool("reinit", false, "re-run the re-entrant setup steps in a running VM (after a Claude restart or resume)")
+	version := flag.Bool("version", false, "print the agent's interface version and exit")
+	flag.Parse()
+
+	if *version {
+		fmt.Println(guest.AgentVersion)
+		return
+	}
+
+	if *reinit {
+		if err := agent.Reinit(*configPath); err != nil {
+			fmt.Fprintf(os.Stderr, "faize-agent: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := agent.Run(*configPath); err != nil {
+		fmt.Fprintf(os.Stderr, "faize-agent: %v\n", err)
+		agent.Poweroff()
+	}
+}
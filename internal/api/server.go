@@ -0,0 +1,344 @@
+// Package api serves faize's local HTTP API on a Unix socket, so editors and
+// other frontends can manage sessions without running the CLI.
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/faize-ai/faize/internal/changeset"
+	"github.com/faize-ai/faize/internal/redact"
+	"github.com/faize-ai/faize/internal/session"
+)
+
+// UpgradeProtocol is the Upgrade header value that switches an attach
+// request's connection to the session's raw console stream
+const UpgradeProtocol = "faize-console"
+
+// OffsetHeader is the response header of GET /v1/sessions/{id}/logs with the
+// offset to request the rest of the log from
+const OffsetHeader = "X-Faize-Offset"
+
+// maxLogChunk is the most console log a single logs request returns
+const maxLogChunk = 1 << 20
+
+var (
+	// ErrNotFound is returned by a Backend for sessions or data that don't exist
+	ErrNotFound = errors.New("not found")
+	// ErrInvalid is returned by a Backend for requests it can't carry out as asked
+	ErrInvalid = errors.New("invalid request")
+)
+
+// Backend carries out API requests
+type Backend interface {
+	List() ([]*session.Session, error)
+	Get(id string) (*session.Session, error)
+	// Start starts a session owned by a background process, so it outlives
+	// the request and the server
+	Start(req StartRequest) (*session.Session, error)
+	Stop(id string, force bool) error
+	// Allow adds a network entry to a running session's allowlist and
+	// returns a message saying what was done
+	Allow(id, entry string) (string, error)
+	// Changeset returns the changes recorded when a session ended
+	Changeset(id string) (*changeset.SessionChangeset, error)
+	// ConsoleSocket returns the Unix socket of a running session's console
+	ConsoleSocket(id string) (string, error)
+	// ConsoleLog returns the path of a session's console log
+	ConsoleLog(id string) (string, error)
+	// Network returns the network activity a session's guest has logged so far
+	Network(id string) ([]changeset.NetworkEvent, error)
+	Resize(id string, cols, rows int) error
+}
+
+// StartRequest is the body of POST /v1/sessions
+type StartRequest struct {
+	Project  string   `json:"project"`
+	Mounts   []string `json:"mounts,omitempty"`
+	Timeout  string   `json:"timeout,omitempty"`  // e.g. "2h"; default from config
+	Worktree string   `json:"worktree,omitempty"` // git branch to run the session in a worktree of
+}
+
+// AllowRequest is the body of POST /v1/sessions/{id}/allow
+type AllowRequest struct {
+	Entry string `json:"entry"`
+}
+
+// ResizeRequest is the body of POST /v1/sessions/{id}/resize
+type ResizeRequest struct {
+	Cols int `json:"cols"`
+	Rows int `json:"rows"`
+}
+
+// Server routes API requests to a Backend
+type Server struct {
+	backend Backend
+	mux     *http.ServeMux
+}
+
+// NewServer returns a server for backend
+func NewServer(backend Backend) *Server {
+	s := &Server{backend: backend, mux: http.NewServeMux()}
+	s.mux.HandleFunc("GET /v1/sessions", s.list)
+	s.mux.HandleFunc("POST /v1/sessions", s.start)
+	s.mux.HandleFunc("GET /v1/sessions/{id}", s.get)
+	s.mux.HandleFunc("DELETE /v1/sessions/{id}", s.stop)
+	s.mux.HandleFunc("GET /v1/sessions/{id}/diff", s.diff)
+	s.mux.HandleFunc("GET /v1/sessions/{id}/logs", s.logs)
+	s.mux.HandleFunc("GET /v1/sessions/{id}/network", s.network)
+	s.mux.HandleFunc("POST /v1/sessions/{id}/allow", s.allow)
+	s.mux.HandleFunc("POST /v1/sessions/{id}/resize", s.resize)
+	s.mux.HandleFunc("GET /v1/sessions/{id}/attach", s.attach)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Listen listens on a Unix socket only the current user can connect to,
+// replacing a stale socket file
+func Listen(path string) (net.Listener, error) {
+	if conn, err := net.Dial("unix", path); err == nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("a server is already listening on %s", path)
+	}
+	_ = os.Remove(path)
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		_ = l.Close()
+		return nil, fmt.Errorf("failed to restrict %s: %w", path, err)
+	}
+	return l, nil
+}
+
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	sessions, err := s.backend.List()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if sessions == nil {
+		sessions = []*session.Session{}
+	}
+	writeJSON(w, http.StatusOK, sessions)
+}
+
+func (s *Server) start(w http.ResponseWriter, r *http.Request) {
+	var req StartRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if req.Project == "" {
+		writeError(w, fmt.Errorf("%w: project is required", ErrInvalid))
+		return
+	}
+	sess, err := s.backend.Start(req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, sess)
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request) {
+	sess, err := s.backend.Get(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, sess)
+}
+
+func (s *Server) stop(w http.ResponseWriter, r *http.Request) {
+	force, _ := strconv.ParseBool(r.URL.Query().Get("force"))
+	if err := s.backend.Stop(r.PathValue("id"), force); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) diff(w http.ResponseWriter, r *http.Request) {
+	cs, err := s.backend.Changeset(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, cs)
+}
+
+// logs answers with the console log from ?offset= on, redacted, and the
+// offset of its end in OffsetHeader, so clients can poll for new output
+func (s *Server) logs(w http.ResponseWriter, r *http.Request) {
+	var offset int64
+	if v := r.URL.Query().Get("offset"); v != "" {
+		var err error
+		if offset, err = strconv.ParseInt(v, 10, 64); err != nil || offset < 0 {
+			writeError(w, fmt.Errorf("%w: invalid offset %q", ErrInvalid, v))
+			return
+		}
+	}
+	path, err := s.backend.ConsoleLog(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		writeError(w, fmt.Errorf("%w: no console log recorded", ErrNotFound))
+		return
+	} else if err != nil {
+		writeError(w, fmt.Errorf("failed to read console log: %w", err))
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	buf := make([]byte, maxLogChunk)
+	n, err := f.ReadAt(buf, offset)
+	if err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, fmt.Errorf("failed to read console log: %w", err))
+		return
+	}
+	data := buf[:n]
+	if n == maxLogChunk {
+		// Stop at a line end, so a token split by the chunk is still redacted
+		if i := bytes.LastIndexByte(data, '\n'); i >= 0 {
+			data = data[:i+1]
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set(OffsetHeader, strconv.FormatInt(offset+int64(len(data)), 10))
+	_, _ = w.Write(redact.Bytes(data))
+}
+
+func (s *Server) network(w http.ResponseWriter, r *http.Request) {
+	events, err := s.backend.Network(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if events == nil {
+		events = []changeset.NetworkEvent{}
+	}
+	writeJSON(w, http.StatusOK, events)
+}
+
+func (s *Server) allow(w http.ResponseWriter, r *http.Request) {
+	var req AllowRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if req.Entry == "" {
+		writeError(w, fmt.Errorf("%w: entry is required", ErrInvalid))
+		return
+	}
+	msg, err := s.backend.Allow(r.PathValue("id"), req.Entry)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"message": msg})
+}
+
+func (s *Server) resize(w http.ResponseWriter, r *http.Request) {
+	var req ResizeRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if req.Cols <= 0 || req.Rows <= 0 {
+		writeError(w, fmt.Errorf("%w: cols and rows must be positive", ErrInvalid))
+		return
+	}
+	if err := s.backend.Resize(r.PathValue("id"), req.Cols, req.Rows); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// attach switches the connection to the session's console: after a 101
+// response, bytes are relayed in both directions until either side closes
+func (s *Server) attach(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Upgrade") != UpgradeProtocol {
+		writeError(w, fmt.Errorf("%w: attach needs 'Upgrade: %s'", ErrInvalid, UpgradeProtocol))
+		return
+	}
+	socket, err := s.backend.ConsoleSocket(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	console, err := net.Dial("unix", socket)
+	if err != nil {
+		writeError(w, fmt.Errorf("failed to connect to the console: %w", err))
+		return
+	}
+	defer func() { _ = console.Close() }()
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		writeError(w, fmt.Errorf("the connection can't be upgraded"))
+		return
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	defer func() { _ = conn.Close() }()
+	_, _ = fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: %s\r\nConnection: Upgrade\r\n\r\n", UpgradeProtocol)
+	if err := rw.Flush(); err != nil {
+		return
+	}
+
+	done := make(chan struct{}, 2)
+	go func() {
+		// Bytes the client sent after the request are already buffered
+		_, _ = io.Copy(console, rw.Reader)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(conn, console)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// readJSON decodes a request body, answering 400 when it's invalid
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, fmt.Errorf("%w: %v", ErrInvalid, err))
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError answers with an error message and a status matching err
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrInvalid):
+		status = http.StatusBadRequest
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,255 @@
+package api
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/faize-ai/faize/internal/changeset"
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBackend keeps sessions in memory
+type fakeBackend struct {
+	sessions map[string]*session.Session
+	started  []StartRequest
+	stopped  []string
+	allowed  []string
+	resized  string
+	socket   string
+	log      string
+}
+
+func (b *fakeBackend) List() ([]*session.Session, error) {
+	var list []*session.Session
+	for _, s := range b.sessions {
+		list = append(list, s)
+	}
+	return list, nil
+}
+
+func (b *fakeBackend) Get(id string) (*session.Session, error) {
+	if s, ok := b.sessions[id]; ok {
+		return s, nil
+	}
+	return nil, fmt.Errorf("%w: session %s", ErrNotFound, id)
+}
+
+func (b *fakeBackend) Start(req StartRequest) (*session.Session, error) {
+	b.started = append(b.started, req)
+	sess := &session.Session{ID: "new123", ProjectDir: req.Project, Status: "running"}
+	b.sessions[sess.ID] = sess
+	return sess, nil
+}
+
+func (b *fakeBackend) Stop(id string, force bool) error {
+	if _, err := b.Get(id); err != nil {
+		return err
+	}
+	b.stopped = append(b.stopped, fmt.Sprintf("%s force=%v", id, force))
+	return nil
+}
+
+func (b *fakeBackend) Allow(id, entry string) (string, error) {
+	if entry == "all" {
+		return "", fmt.Errorf("%w: 'all' can't be added", ErrInvalid)
+	}
+	b.allowed = append(b.allowed, id+" "+entry)
+	return "Allowed " + entry, nil
+}
+
+func (b *fakeBackend) Changeset(id string) (*changeset.SessionChangeset, error) {
+	if id != "abc123" {
+		return nil, fmt.Errorf("%w: no changeset", ErrNotFound)
+	}
+	return &changeset.SessionChangeset{SessionID: id, GuestChanges: []string{"/etc/hosts"}}, nil
+}
+
+func (b *fakeBackend) ConsoleSocket(id string) (string, error) {
+	if _, err := b.Get(id); err != nil {
+		return "", err
+	}
+	return b.socket, nil
+}
+
+func (b *fakeBackend) ConsoleLog(id string) (string, error) {
+	if _, err := b.Get(id); err != nil {
+		return "", err
+	}
+	return b.log, nil
+}
+
+func (b *fakeBackend) Network(id string) ([]changeset.NetworkEvent, error) {
+	if _, err := b.Get(id); err != nil {
+		return nil, err
+	}
+	return []changeset.NetworkEvent{{Action: "DENY", DstIP: "1.2.3.4", DstPort: 443}}, nil
+}
+
+func (b *fakeBackend) Resize(id string, cols, rows int) error {
+	b.resized = fmt.Sprintf("%s %dx%d", id, cols, rows)
+	return nil
+}
+
+func newTestServer(t *testing.T) (*fakeBackend, *httptest.Server) {
+	t.Helper()
+	backend := &fakeBackend{sessions: map[string]*session.Session{
+		"abc123": {ID: "abc123", ProjectDir: "/home/user/project", Status: "running"},
+	}}
+	srv := httptest.NewServer(NewServer(backend))
+	t.Cleanup(srv.Close)
+	return backend, srv
+}
+
+func request(t *testing.T, method, url, body string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, strings.TrimSpace(string(data))
+}
+
+func TestServer_Sessions(t *testing.T) {
+	backend, srv := newTestServer(t)
+
+	code, body := request(t, "GET", srv.URL+"/v1/sessions", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, `"id":"abc123"`)
+
+	code, body = request(t, "GET", srv.URL+"/v1/sessions/missing", "")
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Equal(t, `{"error":"not found: session missing"}`, body)
+
+	code, _ = request(t, "POST", srv.URL+"/v1/sessions", `{"mounts":["/tmp"]}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = request(t, "POST", srv.URL+"/v1/sessions", `{"project":"/p","unknown":true}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, body = request(t, "POST", srv.URL+"/v1/sessions", `{"project":"/home/user/other","timeout":"1h"}`)
+	assert.Equal(t, http.StatusCreated, code)
+	assert.Contains(t, body, `"id":"new123"`)
+	assert.Equal(t, []StartRequest{{Project: "/home/user/other", Timeout: "1h"}}, backend.started)
+
+	code, _ = request(t, "DELETE", srv.URL+"/v1/sessions/abc123?force=true", "")
+	assert.Equal(t, http.StatusNoContent, code)
+	assert.Equal(t, []string{"abc123 force=true"}, backend.stopped)
+}
+
+func TestServer_SessionData(t *testing.T) {
+	backend, srv := newTestServer(t)
+
+	code, body := request(t, "GET", srv.URL+"/v1/sessions/abc123/diff", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, `"guest_changes":["/etc/hosts"]`)
+	code, _ = request(t, "GET", srv.URL+"/v1/sessions/other/diff", "")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, body = request(t, "POST", srv.URL+"/v1/sessions/abc123/allow", `{"entry":"crates.io"}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, `{"message":"Allowed crates.io"}`, body)
+	assert.Equal(t, []string{"abc123 crates.io"}, backend.allowed)
+	code, _ = request(t, "POST", srv.URL+"/v1/sessions/abc123/allow", `{"entry":"all"}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = request(t, "POST", srv.URL+"/v1/sessions/abc123/resize", `{"cols":120,"rows":40}`)
+	assert.Equal(t, http.StatusNoContent, code)
+	assert.Equal(t, "abc123 120x40", backend.resized)
+	code, _ = request(t, "POST", srv.URL+"/v1/sessions/abc123/resize", `{"cols":0,"rows":40}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestServer_Logs(t *testing.T) {
+	backend, srv := newTestServer(t)
+	backend.log = filepath.Join(t.TempDir(), "console.log")
+
+	code, _ := request(t, "GET", srv.URL+"/v1/sessions/abc123/logs", "")
+	assert.Equal(t, http.StatusNotFound, code, "no log yet")
+
+	require.NoError(t, os.WriteFile(backend.log, []byte("booting\ntoken sk-ant-REDACTED\n"), 0644))
+	resp, err := http.Get(srv.URL + "/v1/sessions/abc123/logs?offset=8")
+	require.NoError(t, err)
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.NotContains(t, string(data), "abcdefghijklmnopqrstuvwxyz")
+	assert.True(t, strings.HasPrefix(string(data), "token "))
+	assert.Equal(t, "54", resp.Header.Get(OffsetHeader))
+
+	code, body := request(t, "GET", srv.URL+"/v1/sessions/abc123/logs?offset=54", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, body)
+	code, _ = request(t, "GET", srv.URL+"/v1/sessions/abc123/logs?offset=-1", "")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, body = request(t, "GET", srv.URL+"/v1/sessions/abc123/network", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, `"dst_ip":"1.2.3.4"`)
+}
+
+func TestServer_Attach(t *testing.T) {
+	backend, srv := newTestServer(t)
+
+	// A console that echoes what it receives
+	backend.socket = filepath.Join(t.TempDir(), "console.sock")
+	console, err := net.Listen("unix", backend.socket)
+	require.NoError(t, err)
+	defer func() { _ = console.Close() }()
+	go func() {
+		conn, err := console.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		_, _ = io.Copy(conn, conn)
+	}()
+
+	code, _ := request(t, "GET", srv.URL+"/v1/sessions/abc123/attach", "")
+	assert.Equal(t, http.StatusBadRequest, code, "attach without Upgrade")
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+	_, err = fmt.Fprintf(conn, "GET /v1/sessions/abc123/attach HTTP/1.1\r\nHost: faize\r\nConnection: Upgrade\r\nUpgrade: %s\r\n\r\nhello\n", UpgradeProtocol)
+	require.NoError(t, err)
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	line, err := br.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", line)
+}
+
+func TestListen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "faized.sock")
+	l, err := Listen(path)
+	require.NoError(t, err)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	_, err = Listen(path)
+	assert.ErrorContains(t, err, "already listening")
+
+	// A socket left behind by a server that exited is replaced
+	_ = l.Close()
+	require.NoError(t, os.WriteFile(path, nil, 0600))
+	l, err = Listen(path)
+	require.NoError(t, err)
+	_ = l.Close()
+}
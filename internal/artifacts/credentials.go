@@ -0,0 +1,68 @@
+package artifacts
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// PermissionIssue is a persisted credentials path other user accounts can access
+type PermissionIssue struct {
+	Path  string
+	Mode  fs.FileMode // permission bits as found
+	Fixed bool        // group and other access were removed
+	Err   error       // why the permissions could not be fixed
+}
+
+// CredentialPermissionIssues returns the paths in the credentials directory
+// dir, including dir itself, that grant group or other access. Symlinks are
+// skipped. A missing directory has no issues.
+func CredentialPermissionIssues(dir string) ([]PermissionIssue, error) {
+	return walkCredentials(dir, false)
+}
+
+// SecureCredentials removes group and other access from the credentials
+// directory dir and everything in it. The guest writes credentials over
+// VirtioFS, which does not always keep them 0600. Symlinks are never
+// followed, so a link created in the guest can't redirect the chmod.
+// Returns the paths that were too open.
+func SecureCredentials(dir string) ([]PermissionIssue, error) {
+	return walkCredentials(dir, true)
+}
+
+// walkCredentials finds, and with fix tightens, paths under dir with group or other access
+func walkCredentials(dir string, fix bool) ([]PermissionIssue, error) {
+	var issues []PermissionIssue
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir && errors.Is(err, fs.ErrNotExist) {
+				return filepath.SkipAll
+			}
+			return err
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		mode := info.Mode().Perm()
+		if mode&0077 == 0 {
+			return nil
+		}
+
+		issue := PermissionIssue{Path: path, Mode: mode}
+		if fix {
+			if err := os.Chmod(path, mode&^0077); err != nil {
+				issue.Err = err
+			} else {
+				issue.Fixed = true
+			}
+		}
+		issues = append(issues, issue)
+		return nil
+	})
+	return issues, err
+}
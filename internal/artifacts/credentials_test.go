@@ -0,0 +1,55 @@
+package artifacts
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecureCredentials(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "credentials")
+	require.NoError(t, os.Mkdir(dir, 0755))
+	require.NoError(t, os.Chmod(dir, 0755))
+	creds := filepath.Join(dir, ".credentials.json")
+	require.NoError(t, os.WriteFile(creds, []byte("{}"), 0600))
+	require.NoError(t, os.Chmod(creds, 0644))
+	private := filepath.Join(dir, ".claude.json")
+	require.NoError(t, os.WriteFile(private, []byte("{}"), 0600))
+
+	// A symlink to a host file must not be followed
+	target := filepath.Join(t.TempDir(), "hosts")
+	require.NoError(t, os.WriteFile(target, []byte("x"), 0644))
+	require.NoError(t, os.Chmod(target, 0644))
+	require.NoError(t, os.Symlink(target, filepath.Join(dir, "link")))
+
+	issues, err := CredentialPermissionIssues(dir)
+	require.NoError(t, err)
+	require.Len(t, issues, 2)
+	assert.Equal(t, PermissionIssue{Path: dir, Mode: 0755}, issues[0])
+	assert.Equal(t, PermissionIssue{Path: creds, Mode: 0644}, issues[1])
+
+	issues, err = SecureCredentials(dir)
+	require.NoError(t, err)
+	require.Len(t, issues, 2)
+	assert.True(t, issues[0].Fixed)
+	assert.True(t, issues[1].Fixed)
+
+	for path, want := range map[string]os.FileMode{dir: 0700, creds: 0600, private: 0600, target: 0644} {
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, want, info.Mode().Perm(), path)
+	}
+
+	issues, err = CredentialPermissionIssues(dir)
+	require.NoError(t, err)
+	assert.Empty(t, issues)
+}
+
+func TestSecureCredentialsMissingDir(t *testing.T) {
+	issues, err := SecureCredentials(filepath.Join(t.TempDir(), "missing"))
+	assert.NoError(t, err)
+	assert.Empty(t, issues)
+}
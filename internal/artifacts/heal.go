@@ -0,0 +1,40 @@
+package artifacts
+
+import (
+	"fmt"
+	"os"
+)
+
+// QuarantinePath returns where a corrupt artifact is moved by Quarantine
+func QuarantinePath(path string) string {
+	return path + ".corrupt"
+}
+
+// Quarantine moves a corrupt artifact aside so it is replaced rather than
+// reused, keeping the latest bad copy for inspection. It returns the new path.
+func (m *Manager) Quarantine(path string) (string, error) {
+	dest := QuarantinePath(path)
+	if err := os.Rename(path, dest); err != nil {
+		return "", fmt.Errorf("failed to quarantine %s: %w", path, err)
+	}
+	return dest, nil
+}
+
+// Restore downloads or rebuilds a missing artifact. A Claude rootfs, of any
+// flavor, is rebuilt with extraDeps baked in; the other artifacts ignore them.
+func (m *Manager) Restore(path string, extraDeps []string) error {
+	switch path {
+	case m.KernelPath():
+		return m.EnsureKernel()
+	case m.RootfsPath():
+		return m.ensureRootfs()
+	case m.ClaudeRootfsPath():
+		return m.BuildClaudeRootfsWithDeps(extraDeps)
+	}
+	for _, flavor := range Flavors {
+		if path == m.FlavorRootfsPath(flavor) {
+			return m.BuildFlavorRootfs(flavor, extraDeps)
+		}
+	}
+	return fmt.Errorf("unknown artifact: %s", path)
+}
@@ -0,0 +1,34 @@
+package artifacts
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuarantine(t *testing.T) {
+	m := &Manager{dir: t.TempDir()}
+	path := m.KernelPath()
+	require.NoError(t, os.WriteFile(path, []byte("bad"), 0644))
+	require.NoError(t, os.WriteFile(QuarantinePath(path), []byte("older"), 0644))
+
+	dest, err := m.Quarantine(path)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(m.dir, "vmlinux.corrupt"), dest)
+	assert.NoFileExists(t, path)
+
+	data, err := os.ReadFile(dest)
+	require.NoError(t, err)
+	assert.Equal(t, "bad", string(data), "replaces an older quarantined copy")
+
+	_, err = m.Quarantine(path)
+	assert.Error(t, err)
+}
+
+func TestRestore_UnknownArtifact(t *testing.T) {
+	m := &Manager{dir: t.TempDir()}
+	assert.Error(t, m.Restore(filepath.Join(m.dir, "other.img"), nil))
+}
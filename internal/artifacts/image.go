@@ -0,0 +1,119 @@
+package artifacts
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/faize-ai/faize/internal/guest"
+)
+
+// imageRefPattern matches an OCI image reference: an optional registry host
+// and port, lowercase path components, and an optional tag and digest
+var imageRefPattern = regexp.MustCompile(`^` +
+	`(?:[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?(?:\.[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?)*(?::[0-9]+)?/)?` +
+	`[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*` +
+	`(?::[A-Za-z0-9_][A-Za-z0-9_.-]{0,127})?` +
+	`(?:@[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9a-fA-F]{32,})?$`)
+
+// ValidateImageRef checks that ref is an OCI image reference. References
+// come from project files such as devcontainer.json, so anything else, like
+// an option for docker, is refused before it reaches a command line.
+func ValidateImageRef(ref string) error {
+	if len(ref) > 255 || !imageRefPattern.MatchString(ref) {
+		return fmt.Errorf("invalid image reference %q", ref)
+	}
+	return nil
+}
+
+// imageNameChars are the characters of an image reference kept in the name
+// of the rootfs built from it
+var imageNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// ImagesDir returns the path to ~/.faize/images/, where rootfs images built
+// from OCI images are kept
+func (m *Manager) ImagesDir() string {
+	return filepath.Join(m.FaizeDir(), "images")
+}
+
+// ImageRootfsPath returns where the rootfs built from the OCI image ref
+// (faize start --image) is kept: one per image reference
+func (m *Manager) ImageRootfsPath(ref string) string {
+	sum := sha256.Sum256([]byte(ref))
+	name := strings.Trim(imageNameChars.ReplaceAllString(ref, "-"), "-.")
+	return filepath.Join(m.ImagesDir(), fmt.Sprintf("%s-%s.img", name, hex.EncodeToString(sum[:])[:12]))
+}
+
+// imageIDPath returns the file next to an image rootfs that records the ID of
+// the image it was built from
+func imageIDPath(rootfsPath string) string {
+	return rootfsPath + ".id"
+}
+
+// EnsureImageRootfs builds the rootfs for the OCI image ref unless one built
+// from the local copy of the image exists, and returns its path. An image
+// that isn't local is pulled; 'docker pull' updates it, and the next call
+// rebuilds the rootfs from it.
+func (m *Manager) EnsureImageRootfs(ref string) (string, error) {
+	if err := ValidateImageRef(ref); err != nil {
+		return "", err
+	}
+	path := m.ImageRootfsPath(ref)
+	if !dockerAvailable() {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+		return "", fmt.Errorf("docker is required to build a rootfs from %s but is not available.\n"+
+			"Install Docker (https://www.docker.com/products/docker-desktop)", ref)
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		recorded, _ := os.ReadFile(imageIDPath(path))
+		current, err := exec.Command("docker", "image", "inspect", "--format", "{{.Id}}", "--", ref).Output()
+		sameImage := err == nil && strings.TrimSpace(string(current)) == strings.TrimSpace(string(recorded))
+		sameAgent := RootfsAgentVersion(path) == strconv.Itoa(guest.AgentVersion)
+		switch {
+		case sameImage && sameAgent:
+			return path, nil
+		case sameImage:
+			fmt.Printf("faize's guest agent changed, rebuilding the rootfs of %s...\n", ref)
+		case err == nil:
+			fmt.Printf("Image %s changed, rebuilding its rootfs...\n", ref)
+		}
+	}
+	if err := m.BuildImageRootfs(ref); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// BuildImageRootfs builds the rootfs for the OCI image ref using
+// build-image-rootfs.sh
+func (m *Manager) BuildImageRootfs(ref string) error {
+	if err := ValidateImageRef(ref); err != nil {
+		return err
+	}
+	scriptPath, err := findScript("build-image-rootfs.sh")
+	if err != nil {
+		return fmt.Errorf("failed to find build-image-rootfs.sh script: %w", err)
+	}
+
+	fmt.Printf("Building rootfs from %s using: %s\n", ref, scriptPath)
+
+	path := m.ImageRootfsPath(ref)
+	cmd := exec.Command("bash", scriptPath, ref, path)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to build rootfs from %s: %w", ref, err)
+	}
+
+	fmt.Printf("Rootfs built successfully at: %s\n", path)
+	return nil
+}
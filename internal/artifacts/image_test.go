@@ -0,0 +1,51 @@
+package artifacts
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImageRootfsPath(t *testing.T) {
+	m := &Manager{dir: filepath.Join(t.TempDir(), "artifacts")}
+
+	path := m.ImageRootfsPath("ghcr.io/org/dev:latest")
+	assert.Equal(t, m.ImagesDir(), filepath.Dir(path))
+	assert.True(t, strings.HasPrefix(filepath.Base(path), "ghcr.io-org-dev-latest-"))
+	assert.Equal(t, ".img", filepath.Ext(path))
+	assert.Equal(t, path, m.ImageRootfsPath("ghcr.io/org/dev:latest"))
+
+	// References that sanitize alike still get their own rootfs
+	assert.NotEqual(t, path, m.ImageRootfsPath("ghcr.io/org/dev/latest"))
+
+	digest := m.ImageRootfsPath("alpine@sha256:0123abcd")
+	assert.True(t, strings.HasPrefix(filepath.Base(digest), "alpine-sha256-0123abcd-"))
+}
+
+func TestValidateImageRef(t *testing.T) {
+	for _, ref := range []string{
+		"alpine",
+		"alpine:3.20",
+		"ghcr.io/org/dev:latest",
+		"localhost:5000/team/dev_env-1",
+		"mcr.microsoft.com/devcontainers/base:bookworm",
+		"alpine@sha256:" + strings.Repeat("0123abcd", 8),
+	} {
+		assert.NoError(t, ValidateImageRef(ref), ref)
+	}
+	for _, ref := range []string{
+		"",
+		"--help",
+		"-v/:/host",
+		"alpine;touch /tmp/x",
+		"alpine $(id)",
+		"Upper/Case",
+		"alpine:",
+		"alpine\n",
+		strings.Repeat("a", 256),
+	} {
+		assert.Error(t, ValidateImageRef(ref), ref)
+	}
+}
@@ -1,14 +1,17 @@
 package artifacts
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
 
+	"github.com/faize-ai/faize/internal/rootfs"
 	"github.com/mitchellh/go-homedir"
 )
 
@@ -24,7 +27,8 @@ const (
 
 // Manager handles artifact download and storage at ~/.faize/artifacts/
 type Manager struct {
-	dir string
+	dir         string
+	buildRunner rootfs.Runner // runs Claude rootfs build steps instead of Docker; nil for Docker
 }
 
 // NewManager creates a new artifact manager
@@ -44,7 +48,7 @@ func NewManager() (*Manager, error) {
 
 // EnsureArtifacts downloads kernel and rootfs if missing
 func (m *Manager) EnsureArtifacts() error {
-	if err := m.ensureKernel(); err != nil {
+	if err := m.EnsureKernel(); err != nil {
 		return fmt.Errorf("failed to ensure kernel: %w", err)
 	}
 
@@ -80,7 +84,8 @@ func (m *Manager) SessionDir(id string) string {
 	return filepath.Join(m.FaizeDir(), "sessions", id)
 }
 
-func (m *Manager) ensureKernel() error {
+// EnsureKernel downloads the kernel if missing
+func (m *Manager) EnsureKernel() error {
 	path := m.KernelPath()
 	if _, err := os.Stat(path); err == nil {
 		fmt.Printf("Kernel found at %s\n", path)
@@ -88,7 +93,7 @@ func (m *Manager) ensureKernel() error {
 	}
 
 	// Try our own release first
-	url := fmt.Sprintf("%s/%s/vmlinux", BaseURL, Version)
+	url := releaseAsset("vmlinux")
 	err := m.download(url, path, "vmlinux kernel")
 	if err == nil {
 		return nil
@@ -103,6 +108,22 @@ func (m *Manager) ensureKernel() error {
 	return nil
 }
 
+// releaseAsset returns the URL of a release asset built for this host's
+// architecture, such as vmlinux-arm64 or rootfs-amd64.img
+func releaseAsset(name string) string {
+	ext := filepath.Ext(name)
+	return fmt.Sprintf("%s/%s/%s-%s%s", BaseURL, Version, strings.TrimSuffix(name, ext), runtime.GOARCH, ext)
+}
+
+// kernelBuildArch returns the kernel's name for this host's architecture,
+// which build-kernel.sh builds for
+func kernelBuildArch() string {
+	if runtime.GOARCH == "amd64" {
+		return "x86_64"
+	}
+	return runtime.GOARCH
+}
+
 func (m *Manager) ensureRootfs() error {
 	path := m.RootfsPath()
 	if _, err := os.Stat(path); err == nil {
@@ -111,7 +132,7 @@ func (m *Manager) ensureRootfs() error {
 	}
 
 	// Try downloading from GitHub releases first
-	url := fmt.Sprintf("%s/%s/rootfs.img", BaseURL, Version)
+	url := releaseAsset("rootfs.img")
 	fmt.Printf("Attempting to download rootfs from GitHub releases...\n")
 	err := m.download(url, path, "rootfs image")
 
@@ -182,10 +203,11 @@ func dockerAvailable() bool {
 	return cmd.Run() == nil
 }
 
-// buildKernel builds the kernel using scripts/build-kernel.sh
-// This produces an uncompressed ARM64 Image that Apple Virtualization.framework requires
+// buildKernel builds the kernel for this host's architecture using
+// scripts/build-kernel.sh: an uncompressed ARM64 Image, which Apple
+// Virtualization.framework requires, or an x86 bzImage
 func (m *Manager) buildKernel(destPath string) error {
-	scriptPath, err := m.findKernelBuildScript()
+	scriptPath, err := findScript("build-kernel.sh")
 	if err != nil {
 		return fmt.Errorf("failed to find build-kernel.sh: %w", err)
 	}
@@ -193,9 +215,9 @@ func (m *Manager) buildKernel(destPath string) error {
 	fmt.Printf("Building kernel with virtio support (this may take 5-10 minutes on first run)...\n")
 	fmt.Printf("Using build script: %s\n", scriptPath)
 
-	// build-kernel.sh <version> <workdir> <output>
+	// build-kernel.sh <version> <workdir> <output> <arch>
 	// Use empty string for workdir to let the script use a temp directory
-	cmd := exec.Command("bash", scriptPath, "6.6.10", "", destPath)
+	cmd := exec.Command("bash", scriptPath, "6.6.10", "", destPath, kernelBuildArch())
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
@@ -211,59 +233,41 @@ func (m *Manager) buildKernel(destPath string) error {
 	return nil
 }
 
-// findKernelBuildScript locates the build-kernel.sh script
-// Looks in ../scripts/ relative to the binary, or scripts/ relative to repo root
-func (m *Manager) findKernelBuildScript() (string, error) {
-	// Try relative to binary (for installed/distributed binaries)
+// findScript locates one of faize's build scripts next to the faize binary:
+// in ../scripts/ (installed) or scripts/ (built in a checkout). The working
+// directory is never searched, as it may be a project faize doesn't trust.
+func findScript(name string) (string, error) {
 	execPath, err := os.Executable()
-	if err == nil {
-		scriptPath := filepath.Join(filepath.Dir(execPath), "..", "scripts", "build-kernel.sh")
-		if _, err := os.Stat(scriptPath); err == nil {
-			return scriptPath, nil
-		}
+	if err != nil {
+		return "", fmt.Errorf("failed to locate the faize binary: %w", err)
 	}
-
-	// Try relative to repo root (for development)
-	wd, err := os.Getwd()
-	if err == nil {
-		// Try from current working directory
-		scriptPath := filepath.Join(wd, "cli", "scripts", "build-kernel.sh")
-		if _, err := os.Stat(scriptPath); err == nil {
-			return scriptPath, nil
-		}
-
-		// Try going up from working directory to find scripts
-		scriptPath = filepath.Join(wd, "scripts", "build-kernel.sh")
+	if resolved, err := filepath.EvalSymlinks(execPath); err == nil {
+		execPath = resolved
+	}
+	binDir := filepath.Dir(execPath)
+	for _, dir := range []string{filepath.Join(binDir, "..", "scripts"), filepath.Join(binDir, "scripts")} {
+		scriptPath := filepath.Join(dir, name)
 		if _, err := os.Stat(scriptPath); err == nil {
 			return scriptPath, nil
 		}
 	}
-
-	return "", fmt.Errorf("build-kernel.sh not found in expected locations")
+	return "", fmt.Errorf("%s not found next to the faize binary (%s)", name, binDir)
 }
 
-// BuildRootfs builds the rootfs locally using build-rootfs.sh script
+// BuildRootfs builds the rootfs locally with the rootfs builder
 func (m *Manager) BuildRootfs() error {
-	// Find the build-rootfs.sh script
-	scriptPath, err := m.findBuildScript()
+	builder, err := m.builder()
 	if err != nil {
-		return fmt.Errorf("failed to find build-rootfs.sh script: %w", err)
+		return err
 	}
-
-	if !dockerAvailable() {
-		return fmt.Errorf("docker is required to build rootfs but is not available.\n" +
-			"Either install Docker (https://www.docker.com/products/docker-desktop) or\n" +
-			"pre-build artifacts with: make rootfs")
+	ctx := context.Background()
+	agent, err := rootfs.BuildAgent(ctx, builder.Runner)
+	if err != nil {
+		return err
 	}
 
-	fmt.Printf("Building rootfs using: %s\n", scriptPath)
-
-	// Execute the script with rootfs path as argument
-	cmd := exec.Command("bash", scriptPath, m.RootfsPath())
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	if err := cmd.Run(); err != nil {
+	fmt.Printf("Building rootfs at %s\n", m.RootfsPath())
+	if err := builder.Build(ctx, rootfs.BaseSpec(agent), m.RootfsPath()); err != nil {
 		return fmt.Errorf("failed to build rootfs: %w", err)
 	}
 
@@ -271,9 +275,108 @@ func (m *Manager) BuildRootfs() error {
 	return nil
 }
 
+// BuildCacheDir returns the path to ~/.faize/artifacts/cache/, where the
+// rootfs builder keeps the layers of the images it builds
+func (m *Manager) BuildCacheDir() string {
+	return filepath.Join(m.dir, "cache")
+}
+
+// ClearBuildCache removes the rootfs builder's cached layers, so the next
+// build downloads and installs everything afresh
+func (m *Manager) ClearBuildCache() error {
+	if err := os.RemoveAll(m.BuildCacheDir()); err != nil {
+		return fmt.Errorf("failed to clear build cache: %w", err)
+	}
+	return nil
+}
+
+// SetBuildRunner makes the Alpine Claude rootfs build its Linux steps with
+// runner, such as a builder VM, instead of in Docker. The base rootfs, which
+// builder VMs boot, and the flavors, which start from container images,
+// still build in Docker.
+func (m *Manager) SetBuildRunner(runner rootfs.Runner) {
+	m.buildRunner = runner
+}
+
+// flavorBuilder returns the rootfs builder of a Claude rootfs flavor: with
+// the build runner set for Alpine, if any, else in Docker
+func (m *Manager) flavorBuilder(flavor string) (*rootfs.Builder, error) {
+	if flavor == "" && m.buildRunner != nil {
+		return &rootfs.Builder{Runner: m.buildRunner, CacheDir: m.BuildCacheDir(), Out: os.Stdout}, nil
+	}
+	return m.builder()
+}
+
+// builder returns a rootfs builder whose Linux steps run in Docker
+func (m *Manager) builder() (*rootfs.Builder, error) {
+	if !dockerAvailable() {
+		return nil, fmt.Errorf("docker is required to build rootfs images but is not available.\n" +
+			"Install Docker (https://www.docker.com/products/docker-desktop)")
+	}
+	return &rootfs.Builder{
+		Runner:   &rootfs.DockerRunner{Stderr: os.Stdout},
+		CacheDir: m.BuildCacheDir(),
+		Out:      os.Stdout,
+	}, nil
+}
+
+// Flavors are the Claude rootfs flavors besides the default Alpine one
+// (claude.flavor), for toolchains that need glibc
+var Flavors = []string{"debian", "ubuntu"}
+
+// ParseFlavor checks a Claude rootfs flavor, returning "" for the default
+// Alpine one
+func ParseFlavor(flavor string) (string, error) {
+	if flavor == "" || flavor == "alpine" {
+		return "", nil
+	}
+	for _, f := range Flavors {
+		if f == flavor {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown rootfs flavor %q: use alpine, %s", flavor, strings.Join(Flavors, " or "))
+}
+
 // ClaudeRootfsPath returns the path to the claude-rootfs.img
 func (m *Manager) ClaudeRootfsPath() string {
-	return filepath.Join(m.dir, "claude-rootfs.img")
+	return m.FlavorRootfsPath("")
+}
+
+// FlavorRootfsPath returns the path to the Claude rootfs of flavor:
+// claude-rootfs.img for Alpine (""), claude-rootfs-<flavor>.img otherwise
+func (m *Manager) FlavorRootfsPath(flavor string) string {
+	if flavor == "" {
+		return filepath.Join(m.dir, "claude-rootfs.img")
+	}
+	return filepath.Join(m.dir, "claude-rootfs-"+flavor+".img")
+}
+
+// ClaudeRootfsManifestPath returns the path to the versions recorded when
+// claude-rootfs.img was built
+func (m *Manager) ClaudeRootfsManifestPath() string {
+	return m.FlavorManifestPath("")
+}
+
+// FlavorManifestPath returns the path to the versions recorded when the
+// Claude rootfs of flavor was built
+func (m *Manager) FlavorManifestPath(flavor string) string {
+	return m.FlavorRootfsPath(flavor) + ".manifest"
+}
+
+// RootfsAgentVersion returns the guest agent version recorded in the
+// manifest next to a rootfs image, or "" if it has none
+func RootfsAgentVersion(rootfsPath string) string {
+	data, err := os.ReadFile(rootfsPath + ".manifest")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if version, ok := strings.CutPrefix(line, "faize-agent="); ok {
+			return strings.TrimSpace(version)
+		}
+	}
+	return ""
 }
 
 // ToolchainDir returns the path to ~/.faize/toolchain/
@@ -283,97 +386,78 @@ func (m *Manager) ToolchainDir() string {
 
 // EnsureClaudeRootfs ensures kernel and claude-rootfs.img exist
 func (m *Manager) EnsureClaudeRootfs() error {
+	return m.EnsureFlavorRootfs("")
+}
+
+// EnsureFlavorRootfs ensures kernel and the Claude rootfs of flavor exist
+func (m *Manager) EnsureFlavorRootfs(flavor string) error {
 	// Ensure kernel exists (shared with regular rootfs)
-	if err := m.ensureKernel(); err != nil {
+	if err := m.EnsureKernel(); err != nil {
 		return fmt.Errorf("failed to ensure kernel: %w", err)
 	}
 
-	path := m.ClaudeRootfsPath()
+	path := m.FlavorRootfsPath(flavor)
 	if _, err := os.Stat(path); err == nil {
 		return nil // Already exists
 	}
 
 	// Claude rootfs is not published to GitHub releases — always built locally
 	fmt.Printf("Claude rootfs not found at %s, building locally...\n", path)
-	if !dockerAvailable() {
-		return fmt.Errorf("docker is required to build claude-rootfs but is not available.\n" +
-			"Either install Docker (https://www.docker.com/products/docker-desktop) or\n" +
-			"pre-build artifacts with: make claude-rootfs")
-	}
-	return m.BuildClaudeRootfs()
+	return m.BuildFlavorRootfs(flavor, nil)
 }
 
-// BuildClaudeRootfs builds claude rootfs using build-claude-rootfs.sh
+// BuildClaudeRootfs builds claude rootfs with the rootfs builder
 func (m *Manager) BuildClaudeRootfs() error {
 	return m.BuildClaudeRootfsWithDeps(nil)
 }
 
 // BuildClaudeRootfsWithDeps builds claude rootfs with extra dependencies baked in
 func (m *Manager) BuildClaudeRootfsWithDeps(extraDeps []string) error {
-	scriptPath, err := m.findClaudeBuildScript()
+	return m.BuildFlavorRootfs("", extraDeps)
+}
+
+// BuildFlavorRootfs builds the Claude rootfs of flavor with extra
+// dependencies, package names of its distribution, baked in, and the
+// newest Claude CLI
+func (m *Manager) BuildFlavorRootfs(flavor string, extraDeps []string) error {
+	builder, err := m.flavorBuilder(flavor)
 	if err != nil {
-		return fmt.Errorf("failed to find build-claude-rootfs.sh script: %w", err)
+		return err
 	}
-
-	if !dockerAvailable() {
-		return fmt.Errorf("docker is required to build claude-rootfs but is not available.\n" +
-			"Either install Docker (https://www.docker.com/products/docker-desktop) or\n" +
-			"pre-build artifacts with: make claude-rootfs")
+	ctx := context.Background()
+	version, err := rootfs.LatestClaudeVersion(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to look up the newest Claude CLI, installing the latest one npm finds: %v\n", err)
+	}
+	agent, err := rootfs.BuildAgent(ctx, builder.Runner)
+	if err != nil {
+		return err
+	}
+	spec, err := rootfs.ClaudeSpec(flavor, extraDeps, version, agent)
+	if err != nil {
+		return err
 	}
 
-	fmt.Printf("Building Claude rootfs using: %s\n", scriptPath)
-
-	cmd := exec.Command("bash", scriptPath, m.ClaudeRootfsPath())
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	// Pass extra dependencies via environment variable
+	path := m.FlavorRootfsPath(flavor)
+	fmt.Printf("Building Claude rootfs at %s\n", path)
+	if flavor != "" {
+		fmt.Printf("Flavor: %s\n", flavor)
+	}
 	if len(extraDeps) > 0 {
-		cmd.Env = append(os.Environ(),
-			fmt.Sprintf("EXTRA_DEPS=%s", strings.Join(extraDeps, " ")))
 		fmt.Printf("Extra dependencies: %v\n", extraDeps)
 	}
+	if version != "" {
+		fmt.Printf("Claude CLI: %s\n", version)
+	}
 
-	if err := cmd.Run(); err != nil {
+	if err := builder.Build(ctx, spec, path); err != nil {
 		return fmt.Errorf("failed to build claude rootfs: %w", err)
 	}
 
-	fmt.Printf("Claude rootfs built successfully at: %s\n", m.ClaudeRootfsPath())
+	fmt.Printf("Claude rootfs built successfully at: %s\n", path)
 	return nil
 }
 
-// findClaudeBuildScript locates the build-claude-rootfs.sh script
-// Looks in ../scripts/ relative to the binary, or cli/scripts/ relative to repo root
-func (m *Manager) findClaudeBuildScript() (string, error) {
-	// Try relative to binary (for installed/distributed binaries)
-	execPath, err := os.Executable()
-	if err == nil {
-		scriptPath := filepath.Join(filepath.Dir(execPath), "..", "scripts", "build-claude-rootfs.sh")
-		if _, err := os.Stat(scriptPath); err == nil {
-			return scriptPath, nil
-		}
-	}
-
-	// Try relative to repo root (for development)
-	// Assume we're in cli/internal/artifacts, so go up to repo root
-	wd, err := os.Getwd()
-	if err == nil {
-		// Try from current working directory
-		scriptPath := filepath.Join(wd, "cli", "scripts", "build-claude-rootfs.sh")
-		if _, err := os.Stat(scriptPath); err == nil {
-			return scriptPath, nil
-		}
-
-		// Try going up from working directory to find cli/scripts
-		scriptPath = filepath.Join(wd, "scripts", "build-claude-rootfs.sh")
-		if _, err := os.Stat(scriptPath); err == nil {
-			return scriptPath, nil
-		}
-	}
-
-	return "", fmt.Errorf("build-claude-rootfs.sh not found in expected locations")
-}
-
 // EnsureToolchainDir ensures toolchain directory exists
 func (m *Manager) EnsureToolchainDir() error {
 	dir := m.ToolchainDir()
@@ -390,35 +474,3 @@ func (m *Manager) EnsureCredentialsDir() error {
 	dir := m.CredentialsDir()
 	return os.MkdirAll(dir, 0700)
 }
-
-// findBuildScript locates the build-rootfs.sh script
-// Looks in ../scripts/ relative to the binary, or cli/scripts/ relative to repo root
-func (m *Manager) findBuildScript() (string, error) {
-	// Try relative to binary (for installed/distributed binaries)
-	execPath, err := os.Executable()
-	if err == nil {
-		scriptPath := filepath.Join(filepath.Dir(execPath), "..", "scripts", "build-rootfs.sh")
-		if _, err := os.Stat(scriptPath); err == nil {
-			return scriptPath, nil
-		}
-	}
-
-	// Try relative to repo root (for development)
-	// Assume we're in cli/internal/artifacts, so go up to repo root
-	wd, err := os.Getwd()
-	if err == nil {
-		// Try from current working directory
-		scriptPath := filepath.Join(wd, "cli", "scripts", "build-rootfs.sh")
-		if _, err := os.Stat(scriptPath); err == nil {
-			return scriptPath, nil
-		}
-
-		// Try going up from working directory to find cli/scripts
-		scriptPath = filepath.Join(wd, "scripts", "build-rootfs.sh")
-		if _, err := os.Stat(scriptPath); err == nil {
-			return scriptPath, nil
-		}
-	}
-
-	return "", fmt.Errorf("build-rootfs.sh not found in expected locations")
-}
@@ -0,0 +1,36 @@
+package artifacts
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFlavor(t *testing.T) {
+	for _, alpine := range []string{"", "alpine"} {
+		flavor, err := ParseFlavor(alpine)
+		require.NoError(t, err)
+		assert.Empty(t, flavor)
+	}
+	flavor, err := ParseFlavor("debian")
+	require.NoError(t, err)
+	assert.Equal(t, "debian", flavor)
+
+	_, err = ParseFlavor("arch")
+	assert.Error(t, err)
+}
+
+func TestFlavorRootfsPath(t *testing.T) {
+	m := &Manager{dir: t.TempDir()}
+	assert.Equal(t, m.ClaudeRootfsPath(), m.FlavorRootfsPath(""))
+	assert.Equal(t, filepath.Join(m.dir, "claude-rootfs-ubuntu.img"), m.FlavorRootfsPath("ubuntu"))
+	assert.Equal(t, filepath.Join(m.dir, "claude-rootfs-ubuntu.img.manifest"), m.FlavorManifestPath("ubuntu"))
+}
+
+func TestReleaseAsset(t *testing.T) {
+	assert.Equal(t, BaseURL+"/"+Version+"/vmlinux-"+runtime.GOARCH, releaseAsset("vmlinux"))
+	assert.Equal(t, BaseURL+"/"+Version+"/rootfs-"+runtime.GOARCH+".img", releaseAsset("rootfs.img"))
+}
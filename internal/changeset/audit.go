@@ -0,0 +1,169 @@
+package changeset
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AuditFile is a session's audit log in its session directory, written when
+// the session ends
+const AuditFile = "audit.json"
+
+// AuditEntry is one event in a session's audit log.
+type AuditEntry struct {
+	Time   time.Time `json:"time"`
+	Kind   string    `json:"kind"`             // "dns", "network", "file", or "guest"
+	Action string    `json:"action"`           // "query", "allow", "deny", "doh", "created", "modified", "deleted", "renamed", or "installed"
+	Target string    `json:"target"`           // domain, destination, path, or package
+	Detail string    `json:"detail,omitempty"` // protocol and domain, the guest path of a mount, where a renamed file was, or a package version
+}
+
+// auditActions maps NetworkEvent actions to audit actions
+var auditActions = map[string]string{
+	"DNS":  "query",
+	"CONN": "allow",
+	"DENY": "deny",
+	"DOH":  "doh",
+}
+
+// BuildAudit combines a session's DNS queries, network events, file changes
+// in mounts, and guest changes into one list ordered by time. Events are
+// read from the logs in bootstrapDir; mounts is nil when change tracking was
+// off. Events without a time, from agents that didn't record one, are placed
+// at started; deleted files, guest changes, and installed packages, which
+// are only listed when the session ends, at ended.
+func BuildAudit(bootstrapDir string, mounts []MountChanges, started, ended time.Time) ([]AuditEntry, error) {
+	events, err := CollectNetworkEvents(bootstrapDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read network events: %w", err)
+	}
+	guestChanges, err := ParseGuestChanges(filepath.Join(bootstrapDir, "guest-changes.txt"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read guest changes: %w", err)
+	}
+	packages, err := ParseGuestPackages(filepath.Join(bootstrapDir, "guest-packages.txt"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read guest packages: %w", err)
+	}
+
+	var entries []AuditEntry
+	for _, e := range events {
+		entry := AuditEntry{
+			Time:   eventTime(e.Timestamp, started),
+			Kind:   "network",
+			Action: auditActions[e.Action],
+			Target: e.Domain,
+		}
+		switch {
+		case e.Action == "DNS":
+			entry.Kind = "dns"
+		case e.DstIP != "":
+			entry.Target = fmt.Sprintf("%s:%d", e.DstIP, e.DstPort)
+			entry.Detail = e.Proto
+			if e.Domain != "" {
+				entry.Detail += " " + e.Domain
+			}
+		default:
+			// Egress proxy events are logged by host name
+			entry.Target = fmt.Sprintf("%s:%d", e.Domain, e.DstPort)
+			entry.Detail = e.Proto
+		}
+		entries = append(entries, entry)
+	}
+
+	for _, m := range mounts {
+		for _, c := range m.Changes {
+			at := ended
+			if c.ModTime != nil {
+				at = *c.ModTime
+			}
+			detail := m.Target
+			if c.Type == "renamed" {
+				detail = filepath.Join(m.Source, c.OldPath)
+			}
+			entries = append(entries, AuditEntry{
+				Time:   at,
+				Kind:   "file",
+				Action: c.Type,
+				Target: filepath.Join(m.Source, c.Path),
+				Detail: detail,
+			})
+		}
+	}
+
+	for _, path := range guestChanges {
+		entries = append(entries, AuditEntry{Time: ended, Kind: "guest", Action: "modified", Target: path})
+	}
+	for _, p := range packages {
+		name, version, _ := strings.Cut(p, " ")
+		entries = append(entries, AuditEntry{Time: ended, Kind: "guest", Action: "installed", Target: name, Detail: version})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+	return entries, nil
+}
+
+// eventTime parses a NetworkEvent timestamp: RFC 3339 from the agent and the
+// egress proxy, or dnsmasq's "Jan 2 15:04:05" in the guest's UTC clock, dated
+// in the year of ref. Returns ref when there is no usable timestamp.
+func eventTime(ts string, ref time.Time) time.Time {
+	if t, err := time.Parse(time.RFC3339, ts); err == nil {
+		return t
+	}
+	t, err := time.Parse("Jan _2 15:04:05", ts)
+	if err != nil {
+		return ref
+	}
+	ref = ref.UTC()
+	t = t.AddDate(ref.Year(), 0, 0)
+	if t.Before(ref.AddDate(0, 0, -1)) {
+		// The session ran past new year
+		t = t.AddDate(1, 0, 0)
+	}
+	return t
+}
+
+// SaveAudit saves an audit log to JSON.
+func SaveAudit(path string, entries []AuditEntry) error {
+	if entries == nil {
+		entries = []AuditEntry{}
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// LoadAudit loads an audit log saved with SaveAudit.
+func LoadAudit(path string) ([]AuditEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []AuditEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// WriteAuditCSV writes an audit log as CSV with a header row.
+func WriteAuditCSV(w io.Writer, entries []AuditEntry) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"time", "kind", "action", "target", "detail"})
+	for _, e := range entries {
+		_ = cw.Write([]string{e.Time.UTC().Format(time.RFC3339), e.Kind, e.Action, e.Target, e.Detail})
+	}
+	cw.Flush()
+	return cw.Error()
+}
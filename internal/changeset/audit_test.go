@@ -0,0 +1,106 @@
+package changeset
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildAudit(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "dns.log"), []byte(
+		"Mar  1 12:00:01 dnsmasq[42]: query[A] github.com from 127.0.0.1\n"+
+			"Mar  1 12:00:01 dnsmasq[42]: reply github.com is 140.82.114.4\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "network.log"), []byte(
+		"2026-03-01T12:00:02Z [  12.345] FAIZE_NET: IN= OUT=eth0 SRC=10.0.2.15 DST=140.82.114.4 LEN=60 PROTO=TCP SPT=45678 DPT=443\n"+
+			"[  13.000] FAIZE_DENY: IN= OUT=eth0 SRC=10.0.2.15 DST=1.2.3.4 LEN=60 PROTO=TCP SPT=45679 DPT=80\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "proxy.log"), []byte(
+		"2026-03-01T12:00:05Z FAIZE_PROXY: DENY CONNECT example.com:443\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "guest-changes.txt"), []byte("/etc/hosts\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "guest-packages.txt"), []byte("ripgrep 14.1.0-r0\n"), 0644))
+
+	started := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	ended := started.Add(time.Minute)
+	modified := started.Add(3 * time.Second)
+	mounts := []MountChanges{{
+		Source: "/home/user/project",
+		Target: "/workspace",
+		Changes: []Change{
+			{Path: "main.go", Type: "modified", ModTime: &modified},
+			{Path: "old.go", Type: "deleted"},
+		},
+	}}
+
+	entries, err := BuildAudit(dir, mounts, started, ended)
+	require.NoError(t, err)
+
+	want := []AuditEntry{
+		// Without a collection time, the denial is placed at the start
+		{Time: started, Kind: "network", Action: "deny", Target: "1.2.3.4:80", Detail: "TCP"},
+		{Time: started.Add(time.Second), Kind: "dns", Action: "query", Target: "github.com"},
+		{Time: started.Add(2 * time.Second), Kind: "network", Action: "allow", Target: "140.82.114.4:443", Detail: "TCP github.com"},
+		{Time: modified, Kind: "file", Action: "modified", Target: "/home/user/project/main.go", Detail: "/workspace"},
+		{Time: started.Add(5 * time.Second), Kind: "network", Action: "deny", Target: "example.com:443", Detail: "TCP"},
+		{Time: ended, Kind: "file", Action: "deleted", Target: "/home/user/project/old.go", Detail: "/workspace"},
+		{Time: ended, Kind: "guest", Action: "modified", Target: "/etc/hosts"},
+		{Time: ended, Kind: "guest", Action: "installed", Target: "ripgrep", Detail: "14.1.0-r0"},
+	}
+	require.Len(t, entries, len(want))
+	for i := range want {
+		assert.True(t, want[i].Time.Equal(entries[i].Time), "entry %d: %v", i, entries[i].Time)
+		entries[i].Time = want[i].Time
+	}
+	assert.Equal(t, want, entries)
+}
+
+func TestBuildAudit_Empty(t *testing.T) {
+	entries, err := BuildAudit(t.TempDir(), nil, time.Now(), time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestEventTime(t *testing.T) {
+	ref := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2026, 3, 1, 12, 0, 5, 0, time.UTC), eventTime("2026-03-01T12:00:05Z", ref))
+	assert.Equal(t, time.Date(2026, 3, 1, 12, 0, 1, 0, time.UTC), eventTime("Mar  1 12:00:01", ref))
+	assert.Equal(t, ref, eventTime("", ref))
+
+	// A session started on new year's eve
+	ref = time.Date(2025, 12, 31, 23, 59, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2026, 1, 1, 0, 0, 1, 0, time.UTC), eventTime("Jan  1 00:00:01", ref))
+}
+
+func TestSaveLoadAudit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), AuditFile)
+	entries := []AuditEntry{{
+		Time:   time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		Kind:   "dns",
+		Action: "query",
+		Target: "github.com",
+	}}
+	require.NoError(t, SaveAudit(path, entries))
+	loaded, err := LoadAudit(path)
+	require.NoError(t, err)
+	assert.Equal(t, entries, loaded)
+
+	_, err = LoadAudit(filepath.Join(t.TempDir(), AuditFile))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestWriteAuditCSV(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteAuditCSV(&buf, []AuditEntry{{
+		Time:   time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		Kind:   "file",
+		Action: "created",
+		Target: "/home/user/a, b.txt",
+		Detail: "/workspace",
+	}}))
+	assert.Equal(t, "time,kind,action,target,detail\n"+
+		"2026-03-01T12:00:00Z,file,created,\"/home/user/a, b.txt\",/workspace\n", buf.String())
+}
@@ -0,0 +1,175 @@
+package changeset
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// MountComparison is how two sessions' changes to the same mount differ.
+type MountComparison struct {
+	Target string   `json:"target"` // guest path, which identifies the mount in both sessions
+	OnlyA  []Change `json:"only_a"` // changed by the first session only
+	OnlyB  []Change `json:"only_b"` // changed by the second session only
+	// Differ lists paths both sessions changed, but not identically
+	Differ []ChangePair `json:"differ"`
+	// Same counts paths both sessions changed to the same content
+	Same int `json:"same"`
+}
+
+// ChangePair is a path both sessions changed.
+type ChangePair struct {
+	Path string `json:"path"`
+	A    Change `json:"a"`
+	B    Change `json:"b"`
+	// Unhashed is set when neither content hash was recorded, so the files
+	// may still be identical
+	Unhashed bool `json:"unhashed,omitempty"`
+}
+
+// CompareChangesets compares the file changes of two sessions, matching
+// mounts by guest path so sessions of separate checkouts of a project can be
+// compared. Summarized mounts (toolchain, credentials) are left out.
+func CompareChangesets(a, b *SessionChangeset) []MountComparison {
+	byTarget := func(cs *SessionChangeset) map[string][]Change {
+		mounts := make(map[string][]Change)
+		for _, mc := range cs.MountChanges {
+			if len(mc.Summary) == 0 {
+				mounts[mc.Target] = append(mounts[mc.Target], FilterPaths(mc.Changes)...)
+			}
+		}
+		return mounts
+	}
+	mountsA, mountsB := byTarget(a), byTarget(b)
+
+	targets := make(map[string]bool)
+	for t := range mountsA {
+		targets[t] = true
+	}
+	for t := range mountsB {
+		targets[t] = true
+	}
+	sorted := make([]string, 0, len(targets))
+	for t := range targets {
+		sorted = append(sorted, t)
+	}
+	sort.Strings(sorted)
+
+	var result []MountComparison
+	for _, target := range sorted {
+		if cmp := compareChanges(target, mountsA[target], mountsB[target]); !cmp.empty() {
+			result = append(result, cmp)
+		}
+	}
+	return result
+}
+
+// compareChanges compares two sessions' changes to one mount
+func compareChanges(target string, a, b []Change) MountComparison {
+	cmp := MountComparison{Target: target}
+	inB := make(map[string]Change, len(b))
+	for _, c := range b {
+		inB[c.Path] = c
+	}
+	inA := make(map[string]bool, len(a))
+	for _, ca := range a {
+		inA[ca.Path] = true
+		cb, ok := inB[ca.Path]
+		switch {
+		case !ok:
+			cmp.OnlyA = append(cmp.OnlyA, ca)
+		case sameChange(ca, cb):
+			cmp.Same++
+		default:
+			cmp.Differ = append(cmp.Differ, ChangePair{
+				Path:     ca.Path,
+				A:        ca,
+				B:        cb,
+				Unhashed: ca.Type == cb.Type && ca.NewHash == "" && cb.NewHash == "" && ca.NewSize == cb.NewSize,
+			})
+		}
+	}
+	for _, cb := range b {
+		if !inA[cb.Path] {
+			cmp.OnlyB = append(cmp.OnlyB, cb)
+		}
+	}
+	sort.Slice(cmp.Differ, func(i, j int) bool { return cmp.Differ[i].Path < cmp.Differ[j].Path })
+	return cmp
+}
+
+// sameChange reports whether two changes to a path leave the same result
+func sameChange(a, b Change) bool {
+	if a.Type != b.Type {
+		return false
+	}
+	if a.Type == "deleted" {
+		return true
+	}
+	if a.Type == "renamed" && a.OldPath != b.OldPath {
+		return false
+	}
+	if a.Mode.IsDir() && b.Mode.IsDir() {
+		return true
+	}
+	return a.NewHash != "" && a.NewHash == b.NewHash
+}
+
+func (m MountComparison) empty() bool {
+	return len(m.OnlyA) == 0 && len(m.OnlyB) == 0 && len(m.Differ) == 0 && m.Same == 0
+}
+
+// PrintComparison prints a comparison of sessions idA and idB.
+func PrintComparison(w io.Writer, idA, idB string, cmps []MountComparison) {
+	_, _ = fmt.Fprintf(w, "\nComparing %s and %s\n", idA, idB)
+	_, _ = fmt.Fprintln(w, strings.Repeat("─", 40))
+	if len(cmps) == 0 {
+		_, _ = fmt.Fprintln(w, "\nNeither session changed files.")
+		return
+	}
+
+	for _, cmp := range cmps {
+		_, _ = fmt.Fprintf(w, "\n%s (%s):\n", mountLabel(cmp.Target), cmp.Target)
+		if len(cmp.OnlyA) == 0 && len(cmp.OnlyB) == 0 && len(cmp.Differ) == 0 {
+			_, _ = fmt.Fprintf(w, "  identical: %d change(s) in both\n", cmp.Same)
+			continue
+		}
+		if len(cmp.OnlyA) > 0 {
+			_, _ = fmt.Fprintf(w, "  only in %s:\n", idA)
+			printChanges(w, cmp.OnlyA)
+		}
+		if len(cmp.OnlyB) > 0 {
+			_, _ = fmt.Fprintf(w, "  only in %s:\n", idB)
+			printChanges(w, cmp.OnlyB)
+		}
+		if len(cmp.Differ) > 0 {
+			_, _ = fmt.Fprintln(w, "  changed differently:")
+			for i, p := range cmp.Differ {
+				if i == maxDisplayChanges {
+					_, _ = fmt.Fprintf(w, "  (%d more)\n", len(cmp.Differ)-i)
+					break
+				}
+				note := ""
+				if p.Unhashed {
+					note = ", contents not hashed"
+				}
+				_, _ = fmt.Fprintf(w, "  ≠ %-50s (%s: %s, %s: %s%s)\n", p.Path, idA, describeChange(p.A), idB, describeChange(p.B), note)
+			}
+		}
+		if cmp.Same > 0 {
+			_, _ = fmt.Fprintf(w, "  %d change(s) identical in both\n", cmp.Same)
+		}
+	}
+}
+
+// describeChange summarizes a change as its type and resulting size
+func describeChange(c Change) string {
+	switch c.Type {
+	case "deleted":
+		return "deleted"
+	case "renamed":
+		return "renamed from " + c.OldPath
+	}
+	return c.Type + " " + FormatSize(c.NewSize)
+}
@@ -0,0 +1,67 @@
+package changeset
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareChangesets(t *testing.T) {
+	a := &SessionChangeset{SessionID: "aaa", MountChanges: []MountChanges{
+		{Source: "/src/a", Target: "/workspace", Changes: []Change{
+			{Path: "main.go", Type: "modified", NewSize: 10, NewHash: "h1"},
+			{Path: "same.go", Type: "created", NewSize: 5, NewHash: "h2"},
+			{Path: "gone.go", Type: "deleted"},
+			{Path: "only-a.go", Type: "created", NewSize: 1},
+			{Path: "big.bin", Type: "modified", NewSize: 100},
+		}},
+		{Source: "/home/u/.faize/credentials", Target: "/mnt/host-credentials", Summary: []string{"credentials updated"}},
+	}}
+	b := &SessionChangeset{SessionID: "bbb", MountChanges: []MountChanges{
+		{Source: "/src/b", Target: "/workspace", Changes: []Change{
+			{Path: "main.go", Type: "modified", NewSize: 12, NewHash: "h3"},
+			{Path: "same.go", Type: "created", NewSize: 5, NewHash: "h2"},
+			{Path: "gone.go", Type: "deleted"},
+			{Path: "only-b.go", Type: "created", NewSize: 2},
+			{Path: "big.bin", Type: "modified", NewSize: 100},
+		}},
+	}}
+
+	cmps := CompareChangesets(a, b)
+	require.Len(t, cmps, 1, "summarized mounts are left out")
+	cmp := cmps[0]
+	assert.Equal(t, "/workspace", cmp.Target)
+	assert.Equal(t, 2, cmp.Same)
+	require.Len(t, cmp.OnlyA, 1)
+	assert.Equal(t, "only-a.go", cmp.OnlyA[0].Path)
+	require.Len(t, cmp.OnlyB, 1)
+	assert.Equal(t, "only-b.go", cmp.OnlyB[0].Path)
+	require.Len(t, cmp.Differ, 2)
+	assert.Equal(t, "big.bin", cmp.Differ[0].Path)
+	assert.True(t, cmp.Differ[0].Unhashed)
+	assert.Equal(t, "main.go", cmp.Differ[1].Path)
+	assert.False(t, cmp.Differ[1].Unhashed)
+
+	var buf bytes.Buffer
+	PrintComparison(&buf, "aaa", "bbb", cmps)
+	out := buf.String()
+	assert.Contains(t, out, "only in aaa:\n  + only-a.go")
+	assert.Contains(t, out, "(aaa: modified 10 B, bbb: modified 12 B)")
+	assert.Contains(t, out, "contents not hashed")
+	assert.Contains(t, out, "2 change(s) identical in both")
+}
+
+func TestCompareChangesets_Identical(t *testing.T) {
+	cs := &SessionChangeset{MountChanges: []MountChanges{
+		{Target: "/workspace", Changes: []Change{{Path: "a", Type: "created", NewHash: "h"}}},
+	}}
+	cmps := CompareChangesets(cs, cs)
+	require.Len(t, cmps, 1)
+	assert.Equal(t, 1, cmps[0].Same)
+
+	var buf bytes.Buffer
+	PrintComparison(&buf, "x", "y", CompareChangesets(&SessionChangeset{}, &SessionChangeset{}))
+	assert.Contains(t, buf.String(), "Neither session changed files.")
+}
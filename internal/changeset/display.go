@@ -5,6 +5,8 @@ import (
 	"io"
 	"sort"
 	"strings"
+
+	"github.com/faize-ai/faize/internal/network"
 )
 
 const maxDisplayChanges = 20
@@ -17,10 +19,10 @@ func PrintSummary(w io.Writer, cs *SessionChangeset) {
 
 	totalChanges := 0
 	for _, mc := range cs.MountChanges {
-		totalChanges += len(mc.Changes)
+		totalChanges += len(mc.Changes) + len(mc.Summary)
 	}
 
-	if totalChanges == 0 && len(cs.NetworkEvents) == 0 {
+	if totalChanges == 0 && len(cs.GuestPackages) == 0 && len(cs.NetworkEvents) == 0 {
 		_, _ = fmt.Fprintln(w, "\nNo changes detected.")
 		return
 	}
@@ -30,21 +32,58 @@ func PrintSummary(w io.Writer, cs *SessionChangeset) {
 
 	// Print mount changes
 	for _, mc := range cs.MountChanges {
-		if len(mc.Changes) == 0 {
+		if len(mc.Changes) == 0 && len(mc.Summary) == 0 {
 			continue
 		}
 		// Determine label based on mount target
 		label := mountLabel(mc.Target)
 		_, _ = fmt.Fprintf(w, "\n%s (%s → %s):\n", label, mc.Source, mc.Target)
+		if len(mc.Summary) > 0 {
+			for _, line := range mc.Summary {
+				_, _ = fmt.Fprintf(w, "  %s\n", line)
+			}
+			continue
+		}
+		stats := mc.Statistics()
+		_, _ = fmt.Fprintf(w, "  %s\n", stats.summaryLine())
+		if len(stats.LargestNew) > 0 {
+			_, _ = fmt.Fprintf(w, "  largest new: %s\n", stats.largestLine(3))
+		}
 		printChanges(w, mc.Changes)
 	}
 
+	if len(cs.GuestPackages) > 0 {
+		_, _ = fmt.Fprintf(w, "\nPackages installed: %s\n", strings.Join(PackageNames(cs.GuestPackages), ", "))
+	}
+
 	// Print network activity summary
 	if len(cs.NetworkEvents) > 0 {
 		printNetworkSummary(w, cs.NetworkEvents)
 	}
 }
 
+// CommitMessage returns a commit message for a session's changes in mounts:
+// the session as the subject, then each mount's summary and changes
+func CommitMessage(sessionID string, mounts []MountChanges) string {
+	var b strings.Builder
+	_, _ = fmt.Fprintf(&b, "faize session %s\n", sessionID)
+	for _, mc := range mounts {
+		_, _ = fmt.Fprintln(&b)
+		if len(mounts) > 1 {
+			_, _ = fmt.Fprintf(&b, "%s:\n", mc.Source)
+		}
+		_, _ = fmt.Fprintf(&b, "  %s\n", mc.Statistics().summaryLine())
+		printChanges(&b, mc.Changes)
+	}
+	return b.String()
+}
+
+// IsProjectMount reports whether a guest mount target holds project files,
+// rather than the toolchain, Claude config, or credentials
+func IsProjectMount(target string) bool {
+	return mountLabel(target) == "Project"
+}
+
 // mountLabel returns a human-friendly label based on the guest mount target
 func mountLabel(target string) string {
 	switch {
@@ -52,6 +91,8 @@ func mountLabel(target string) string {
 		return "Toolchain"
 	case strings.HasPrefix(target, "/mnt/host-claude"):
 		return "Claude Config"
+	case strings.HasPrefix(target, "/mnt/host-credentials"):
+		return "Credentials"
 	default:
 		return "Project"
 	}
@@ -61,7 +102,7 @@ func mountLabel(target string) string {
 func printChanges(w io.Writer, changes []Change) {
 	if len(changes) > maxDisplayChanges {
 		// Show top 5 of each type, then summary
-		created, modified, deleted := categorize(changes)
+		created, modified, deleted, renamed := categorize(changes)
 		shown := 0
 		for _, c := range created {
 			if shown >= 5 {
@@ -84,8 +125,18 @@ func printChanges(w io.Writer, changes []Change) {
 			printChange(w, c)
 			shown++
 		}
-		_, _ = fmt.Fprintf(w, "  (%d changes total: %d created, %d modified, %d deleted)\n",
-			len(changes), len(created), len(modified), len(deleted))
+		for _, c := range renamed {
+			if shown >= 5 {
+				break
+			}
+			printChange(w, c)
+			shown++
+		}
+		line := fmt.Sprintf("  (%d changes total: %d created, %d modified, %d deleted", len(changes), len(created), len(modified), len(deleted))
+		if len(renamed) > 0 {
+			line += fmt.Sprintf(", %d renamed", len(renamed))
+		}
+		_, _ = fmt.Fprintln(w, line+")")
 		return
 	}
 	for _, c := range changes {
@@ -97,16 +148,18 @@ func printChanges(w io.Writer, changes []Change) {
 func printChange(w io.Writer, c Change) {
 	switch c.Type {
 	case "created":
-		_, _ = fmt.Fprintf(w, "  + %-50s (%s)\n", c.Path, formatSize(c.NewSize))
+		_, _ = fmt.Fprintf(w, "  + %-50s (%s)\n", c.Path, FormatSize(c.NewSize))
 	case "modified":
-		_, _ = fmt.Fprintf(w, "  ~ %-50s (%s → %s)\n", c.Path, formatSize(c.OldSize), formatSize(c.NewSize))
+		_, _ = fmt.Fprintf(w, "  ~ %-50s (%s → %s)\n", c.Path, FormatSize(c.OldSize), FormatSize(c.NewSize))
 	case "deleted":
 		_, _ = fmt.Fprintf(w, "  - %s\n", c.Path)
+	case "renamed":
+		_, _ = fmt.Fprintf(w, "  renamed %s → %s\n", c.OldPath, c.Path)
 	}
 }
 
-// categorize splits changes into created/modified/deleted slices
-func categorize(changes []Change) (created, modified, deleted []Change) {
+// categorize splits changes into created/modified/deleted/renamed slices
+func categorize(changes []Change) (created, modified, deleted, renamed []Change) {
 	for _, c := range changes {
 		switch c.Type {
 		case "created":
@@ -115,13 +168,15 @@ func categorize(changes []Change) (created, modified, deleted []Change) {
 			modified = append(modified, c)
 		case "deleted":
 			deleted = append(deleted, c)
+		case "renamed":
+			renamed = append(renamed, c)
 		}
 	}
 	return
 }
 
-// formatSize returns a human-readable file size
-func formatSize(bytes int64) string {
+// FormatSize returns a human-readable file size.
+func FormatSize(bytes int64) string {
 	switch {
 	case bytes >= 1<<20:
 		return fmt.Sprintf("%.1f MB", float64(bytes)/float64(1<<20))
@@ -138,12 +193,14 @@ func printNetworkSummary(w io.Writer, events []NetworkEvent) {
 	_, _ = fmt.Fprintln(w, strings.Repeat("─", 40))
 
 	// Separate by type
-	var dnsEvents, conns, denies []NetworkEvent
+	var dnsEvents, conns, denies, doh []NetworkEvent
 	for _, e := range events {
-		switch e.Action {
-		case "DNS":
+		switch {
+		case SuspectedDoH(e):
+			doh = append(doh, e)
+		case e.Action == "DNS":
 			dnsEvents = append(dnsEvents, e)
-		case "DENY":
+		case e.Action == "DENY":
 			denies = append(denies, e)
 		default:
 			conns = append(conns, e)
@@ -208,4 +265,47 @@ func printNetworkSummary(w io.Writer, events []NetworkEvent) {
 		sort.Strings(destList)
 		_, _ = fmt.Fprintf(w, "  Denied: %d (%s)\n", len(denyDests), strings.Join(destList, ", "))
 	}
+
+	// Suspected DNS-over-HTTPS/TLS, which can bypass the policy's DNS
+	if len(doh) > 0 {
+		dohDests := make(map[string]bool)
+		for _, e := range doh {
+			host := e.DstIP
+			if e.Domain != "" {
+				host = e.Domain
+			}
+			dest := fmt.Sprintf("%s:%d", host, e.DstPort)
+			switch e.Action {
+			case "DNS":
+				dest = e.Domain + " lookup"
+			case "DENY", "DOH":
+				dest += " blocked"
+			}
+			dohDests[dest] = true
+		}
+		destList := make([]string, 0, len(dohDests))
+		for dest := range dohDests {
+			destList = append(destList, dest)
+		}
+		sort.Strings(destList)
+		_, _ = fmt.Fprintf(w, "  Suspected DNS-over-HTTPS: %d (%s)\n", len(dohDests), strings.Join(destList, ", "))
+	}
+}
+
+// SuspectedDoH reports whether a network event looks like DNS-over-HTTPS or
+// DNS-over-TLS: an attempt the guest firewall blocked as such, a connection
+// to a well-known DoH endpoint, or any connection to the DoT port. DNS
+// lookups of the endpoints' names are flagged too.
+func SuspectedDoH(e NetworkEvent) bool {
+	switch {
+	case e.Action == "DOH":
+		return true
+	case e.Action == "DNS":
+		return network.IsDoH(e.Domain)
+	case e.DstPort == network.DoTPort:
+		return true
+	case e.DstPort != 443:
+		return false
+	}
+	return (e.Domain != "" && network.IsDoH(e.Domain)) || (e.DstIP != "" && network.IsDoH(e.DstIP))
 }
@@ -0,0 +1,173 @@
+package changeset
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// gitPattern is one pattern of a .gitignore file or the ignore config
+type gitPattern struct {
+	base     []string // directory holding the pattern's file, relative to the project root
+	segments []string // the pattern split on "/"
+	anchored bool     // matched against the whole path below base, not just the name
+	dirOnly  bool     // trailing "/": matches directories only
+	negate   bool     // leading "!": re-includes what earlier patterns excluded
+}
+
+// parsePattern parses one .gitignore line. ok is false for blank lines and comments.
+func parsePattern(line string, base []string) (gitPattern, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return gitPattern{}, false
+	}
+	p := gitPattern{base: base}
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		// "\#" and "\!" match names starting with those characters
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		p.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return gitPattern{}, false
+	}
+	p.segments = strings.Split(line, "/")
+	return p, true
+}
+
+// isPattern reports whether a configured ignore entry is a .gitignore-style
+// pattern rather than a directory name
+func isPattern(entry string) bool {
+	name := cleanDirName(entry)
+	return strings.ContainsAny(name, "*?[/") || strings.HasPrefix(strings.TrimSpace(entry), "/")
+}
+
+// match reports whether the pattern matches a path given as its components
+// relative to the project root
+func (p gitPattern) match(parts []string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	if len(parts) <= len(p.base) {
+		return false
+	}
+	for i, dir := range p.base {
+		if parts[i] != dir {
+			return false
+		}
+	}
+	parts = parts[len(p.base):]
+	if !p.anchored {
+		ok, _ := path.Match(p.segments[0], parts[len(parts)-1])
+		return ok
+	}
+	return matchSegments(p.segments, parts)
+}
+
+// matchSegments matches path components against pattern components, where
+// "**" matches any number of components
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			if len(rest) == 0 {
+				// "dir/**" matches everything inside dir
+				return len(parts) > 0
+			}
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(rest, parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+// readGitignore reads the patterns of a .gitignore-style file; nil if it
+// doesn't exist or can't be read
+func readGitignore(file string, base []string) []gitPattern {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer func() { _ = f.Close() }()
+
+	var patterns []gitPattern
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if p, ok := parsePattern(scanner.Text(), base); ok {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// gitignore returns the patterns of the .gitignore file in dir, a directory
+// relative to the project root given as its components. The root's also
+// include .git/info/exclude. Files are read once.
+func (r *IgnoreRules) gitignore(dir []string) []gitPattern {
+	if r.root == "" {
+		return nil
+	}
+	key := strings.Join(dir, "/")
+	r.mu.RLock()
+	patterns, ok := r.gitignores[key]
+	r.mu.RUnlock()
+	if ok {
+		return patterns
+	}
+
+	base := append([]string(nil), dir...)
+	if len(dir) == 0 {
+		patterns = readGitignore(filepath.Join(r.root, ".git", "info", "exclude"), base)
+	}
+	patterns = append(patterns, readGitignore(filepath.Join(r.root, filepath.FromSlash(key), ".gitignore"), base)...)
+
+	r.mu.Lock()
+	r.gitignores[key] = patterns
+	r.mu.Unlock()
+	return patterns
+}
+
+// gitIgnored reports whether the last of parts is excluded by the
+// .gitignore files of the directories above it and the configured patterns,
+// assuming its parent directory is not excluded. The last matching pattern
+// wins; deeper .gitignore files and then the configured patterns take
+// precedence.
+func (r *IgnoreRules) gitIgnored(parts []string, isDir bool) bool {
+	ignored := false
+	check := func(patterns []gitPattern) {
+		for _, p := range patterns {
+			if p.match(parts, isDir) {
+				ignored = !p.negate
+			}
+		}
+	}
+	if r.gitignores != nil {
+		for i := 0; i < len(parts); i++ {
+			check(r.gitignore(parts[:i]))
+		}
+	}
+	check(r.patterns)
+	return ignored && !r.keep[parts[len(parts)-1]]
+}
@@ -0,0 +1,118 @@
+package changeset
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitPattern_Match(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		isDir   bool
+		want    bool
+	}{
+		{"*.log", "debug.log", false, true},
+		{"*.log", "logs/debug.log", false, true},
+		{"*.log", "debug.txt", false, false},
+		{"build/", "build", true, true},
+		{"build/", "build", false, false},
+		{"build/", "src/build", true, true},
+		{"/build", "build", true, true},
+		{"/build", "src/build", true, false},
+		{"doc/*.txt", "doc/notes.txt", false, true},
+		{"doc/*.txt", "doc/server/arch.txt", false, false},
+		{"**/cache", "a/b/cache", true, true},
+		{"a/**/b", "a/b", true, true},
+		{"a/**/b", "a/x/y/b", true, true},
+		{"out/**", "out/x/y", false, true},
+		{"out/**", "out", true, false},
+		{`\#notes`, "#notes", false, true},
+	}
+	for _, tt := range tests {
+		p, ok := parsePattern(tt.pattern, nil)
+		require.True(t, ok, tt.pattern)
+		assert.Equal(t, tt.want, p.match(splitPath(tt.path), tt.isDir), "%s vs %s", tt.pattern, tt.path)
+	}
+
+	for _, line := range []string{"", "   ", "# comment", "/"} {
+		_, ok := parsePattern(line, nil)
+		assert.False(t, ok, line)
+	}
+
+	// Patterns from a nested .gitignore apply below its directory only
+	p, _ := parsePattern("/dist", []string{"web"})
+	assert.True(t, p.match(splitPath("web/dist"), true))
+	assert.False(t, p.match(splitPath("dist"), true))
+	assert.False(t, p.match(splitPath("api/web/dist"), true))
+}
+
+func TestIsPattern(t *testing.T) {
+	assert.False(t, isPattern("tmp"))
+	assert.False(t, isPattern("tmp/"))
+	assert.True(t, isPattern("*.log"))
+	assert.True(t, isPattern("/build"))
+	assert.True(t, isPattern("web/dist"))
+}
+
+func TestIgnoreRules_Gitignore(t *testing.T) {
+	dir := t.TempDir()
+	write := func(rel, data string) {
+		path := filepath.Join(dir, filepath.FromSlash(rel))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(data), 0644))
+	}
+	write(".gitignore", "*.log\n!keep.log\n/coverage/\n.env\n")
+	write(".git/info/exclude", "notes.md\n")
+	write("web/.gitignore", ".next/\n!*.log\n")
+	write(ProjectIgnoreFile, "!.env\n")
+
+	rules, err := NewIgnoreRules(dir, []string{"none"}, []string{"*.tmp", "/scratch"})
+	require.NoError(t, err)
+
+	assert.True(t, rules.Ignored("debug.log", false))
+	assert.True(t, rules.Ignored("src/debug.log", false))
+	assert.False(t, rules.Ignored("keep.log", false), "negated")
+	assert.True(t, rules.Ignored("coverage/index.html", false), "inside an ignored directory")
+	assert.False(t, rules.Ignored("src/coverage/index.html", false), "anchored to the root")
+	assert.True(t, rules.Ignored("notes.md", false), ".git/info/exclude")
+	assert.True(t, rules.Ignored("web/.next/cache/x", false))
+	assert.False(t, rules.Ignored("web/server.log", false), "re-included by a deeper .gitignore")
+	assert.False(t, rules.Ignored(".env", false), "re-included by .faizeignore")
+	assert.True(t, rules.Ignored("a/b.tmp", false))
+	assert.True(t, rules.Ignored("scratch", true))
+	assert.False(t, rules.Ignored("src/scratch", true))
+	assert.False(t, rules.Ignored("src/main.go", false))
+
+	assert.True(t, rules.Match("build.log"))
+	assert.False(t, rules.Match("main.go"))
+}
+
+func TestTakeWithRules_SkipsGitignored(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("dist/\n*.pyc\n"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "dist"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "dist", "app.js"), []byte("x"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.py"), []byte("x"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.pyc"), []byte("x"), 0644))
+
+	rules, err := NewIgnoreRules(dir, []string{"none"}, nil)
+	require.NoError(t, err)
+	snap, err := TakeWithRules(dir, rules)
+	require.NoError(t, err)
+
+	assert.Contains(t, snap, "main.py")
+	assert.Contains(t, snap, ".gitignore")
+	assert.NotContains(t, snap, "main.pyc")
+	assert.NotContains(t, snap, "dist")
+	assert.NotContains(t, snap, "dist/app.js")
+}
+
+func splitPath(rel string) []string {
+	return strings.Split(rel, "/")
+}
@@ -0,0 +1,288 @@
+package changeset
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ProjectIgnoreFile is the per-project override file read from a mount root.
+// Each line names a directory to ignore or is a .gitignore-style pattern; a
+// leading "!" un-ignores a name contributed by a profile or excluded by the
+// project's .gitignore files. Blank lines and lines starting with "#" are
+// skipped.
+const ProjectIgnoreFile = ".faizeignore"
+
+// Special profile names accepted in configuration
+const (
+	ProfileAuto = "auto" // detect profiles from marker files in the project root
+	ProfileNone = "none" // disable all ecosystem profiles
+)
+
+// Profile is a named set of directories an ecosystem generates as build or dependency output.
+type Profile struct {
+	Name    string
+	Markers []string // files whose presence at the project root selects this profile
+	Dirs    []string // directory names ignored at any depth
+}
+
+// Profiles are the built-in ecosystem ignore profiles.
+var Profiles = []Profile{
+	{
+		Name:    "node",
+		Markers: []string{"package.json"},
+		Dirs:    []string{"node_modules", "dist", ".next", ".nuxt", ".turbo", ".parcel-cache", "coverage"},
+	},
+	{
+		Name:    "python",
+		Markers: []string{"pyproject.toml", "requirements.txt", "setup.py", "Pipfile"},
+		Dirs:    []string{".venv", "venv", "__pycache__", ".pytest_cache", ".mypy_cache", ".ruff_cache", ".tox"},
+	},
+	{
+		Name:    "rust",
+		Markers: []string{"Cargo.toml"},
+		Dirs:    []string{"target"},
+	},
+	{
+		Name:    "go",
+		Markers: []string{"go.mod"},
+		Dirs:    []string{"vendor"},
+	},
+	{
+		Name:    "java",
+		Markers: []string{"pom.xml", "build.gradle", "build.gradle.kts"},
+		Dirs:    []string{"target", "build", ".gradle"},
+	},
+}
+
+// findProfile returns the built-in profile with the given name.
+func findProfile(name string) (Profile, bool) {
+	for _, p := range Profiles {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Profile{}, false
+}
+
+// ValidateProfiles checks that every name is a built-in profile, "auto", or "none".
+func ValidateProfiles(names []string) error {
+	for _, name := range names {
+		if name == ProfileAuto || name == ProfileNone {
+			continue
+		}
+		if _, ok := findProfile(name); !ok {
+			known := make([]string, 0, len(Profiles))
+			for _, p := range Profiles {
+				known = append(known, p.Name)
+			}
+			return fmt.Errorf("unknown ignore profile '%s' (available: %s, auto, none)", name, strings.Join(known, ", "))
+		}
+	}
+	return nil
+}
+
+// DetectProfiles returns the names of profiles whose marker files exist in root.
+func DetectProfiles(root string) []string {
+	var detected []string
+	for _, p := range Profiles {
+		for _, marker := range p.Markers {
+			if _, err := os.Stat(filepath.Join(root, marker)); err == nil {
+				detected = append(detected, p.Name)
+				break
+			}
+		}
+	}
+	return detected
+}
+
+// IgnoreRules holds the directory names and .gitignore-style patterns
+// excluded from snapshots and change summaries. A nil *IgnoreRules ignores
+// nothing.
+type IgnoreRules struct {
+	Profiles []string // resolved profile names, for display
+	dirs     map[string]bool
+	patterns []gitPattern    // from the config and .faizeignore
+	keep     map[string]bool // names re-included with "!" in .faizeignore
+
+	// root is the project root whose .gitignore files apply; empty reads none
+	root       string
+	mu         sync.RWMutex
+	gitignores map[string][]gitPattern // by directory relative to root
+}
+
+// NewIgnoreRules resolves profiles for the project at root and combines them with
+// extra directory names or patterns, the project's .faizeignore file, and its
+// .gitignore files. An empty profiles list behaves like "auto".
+func NewIgnoreRules(root string, profiles []string, extra []string) (*IgnoreRules, error) {
+	if err := ValidateProfiles(profiles); err != nil {
+		return nil, err
+	}
+
+	var names []string
+	switch {
+	case len(profiles) == 0:
+		names = DetectProfiles(root)
+	default:
+		seen := make(map[string]bool)
+		for _, name := range profiles {
+			if name == ProfileNone {
+				names = nil
+				break
+			}
+			var resolved []string
+			if name == ProfileAuto {
+				resolved = DetectProfiles(root)
+			} else {
+				resolved = []string{name}
+			}
+			for _, n := range resolved {
+				if !seen[n] {
+					seen[n] = true
+					names = append(names, n)
+				}
+			}
+		}
+	}
+
+	rules := &IgnoreRules{
+		Profiles:   names,
+		dirs:       make(map[string]bool),
+		keep:       make(map[string]bool),
+		root:       root,
+		gitignores: make(map[string][]gitPattern),
+	}
+	for _, name := range names {
+		p, _ := findProfile(name)
+		for _, d := range p.Dirs {
+			rules.dirs[d] = true
+		}
+	}
+	for _, d := range extra {
+		rules.add(d)
+	}
+
+	overrides, err := readProjectIgnore(filepath.Join(root, ProjectIgnoreFile))
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range overrides {
+		if strings.HasPrefix(line, "!") {
+			name := cleanDirName(strings.TrimPrefix(line, "!"))
+			delete(rules.dirs, name)
+			rules.keep[name] = true
+			continue
+		}
+		rules.add(line)
+	}
+
+	return rules, nil
+}
+
+// add registers a directory name, tolerating a trailing slash ("target/"),
+// or a .gitignore-style pattern such as "*.log" or "/build/**".
+func (r *IgnoreRules) add(entry string) {
+	if isPattern(entry) {
+		if p, ok := parsePattern(strings.TrimSpace(entry), nil); ok {
+			r.patterns = append(r.patterns, p)
+		}
+		return
+	}
+	if name := cleanDirName(entry); name != "" {
+		r.dirs[name] = true
+	}
+}
+
+// cleanDirName normalizes a configured directory name.
+func cleanDirName(dir string) string {
+	return strings.Trim(strings.TrimSpace(dir), "/")
+}
+
+// Dirs returns the ignored directory names in sorted order.
+func (r *IgnoreRules) Dirs() []string {
+	if r == nil {
+		return nil
+	}
+	dirs := make([]string, 0, len(r.dirs))
+	for d := range r.dirs {
+		dirs = append(dirs, d)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// MatchDir reports whether a directory with the given base name is ignored.
+func (r *IgnoreRules) MatchDir(name string) bool {
+	return r != nil && r.dirs[name]
+}
+
+// Match reports whether any directory component of the relative path is
+// ignored, or the file is.
+func (r *IgnoreRules) Match(path string) bool {
+	if r == nil {
+		return false
+	}
+	parts := strings.Split(filepath.ToSlash(path), "/")
+	if r.dirs[parts[len(parts)-1]] {
+		return true
+	}
+	return r.Ignored(path, false)
+}
+
+// Ignored reports whether the path relative to the project root is excluded:
+// it or a directory above it has an ignored name or matches a pattern.
+func (r *IgnoreRules) Ignored(path string, isDir bool) bool {
+	if r == nil {
+		return false
+	}
+	parts := strings.Split(filepath.ToSlash(path), "/")
+	for i := range parts {
+		if r.matchEntry(parts[:i+1], isDir || i < len(parts)-1) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchEntry reports whether the last of parts is excluded, assuming the
+// directories above it are not
+func (r *IgnoreRules) matchEntry(parts []string, isDir bool) bool {
+	if r == nil {
+		return false
+	}
+	if isDir && r.dirs[parts[len(parts)-1]] {
+		return true
+	}
+	return r.gitIgnored(parts, isDir)
+}
+
+// readProjectIgnore reads a .faizeignore file.
+// Returns nil and no error if the file doesn't exist.
+func readProjectIgnore(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
@@ -0,0 +1,101 @@
+package changeset
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectProfiles(t *testing.T) {
+	dir := t.TempDir()
+	assert.Empty(t, DetectProfiles(dir))
+
+	_ = os.WriteFile(filepath.Join(dir, "Cargo.toml"), []byte("[package]"), 0644)
+	_ = os.WriteFile(filepath.Join(dir, "pyproject.toml"), []byte("[project]"), 0644)
+	assert.Equal(t, []string{"python", "rust"}, DetectProfiles(dir))
+}
+
+func TestValidateProfiles(t *testing.T) {
+	assert.NoError(t, ValidateProfiles(nil))
+	assert.NoError(t, ValidateProfiles([]string{"auto", "rust", "none"}))
+
+	err := ValidateProfiles([]string{"cobol"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cobol")
+}
+
+func TestNewIgnoreRules_AutoDetect(t *testing.T) {
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, "Cargo.toml"), []byte("[package]"), 0644)
+
+	rules, err := NewIgnoreRules(dir, nil, []string{"out/"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"rust"}, rules.Profiles)
+	assert.Equal(t, []string{"out", "target"}, rules.Dirs())
+}
+
+func TestNewIgnoreRules_ExplicitAndNone(t *testing.T) {
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, "Cargo.toml"), []byte("[package]"), 0644)
+
+	rules, err := NewIgnoreRules(dir, []string{"python"}, nil)
+	require.NoError(t, err)
+	assert.True(t, rules.MatchDir(".venv"))
+	assert.False(t, rules.MatchDir("target"))
+
+	rules, err = NewIgnoreRules(dir, []string{"none"}, nil)
+	require.NoError(t, err)
+	assert.Empty(t, rules.Dirs())
+}
+
+func TestNewIgnoreRules_ProjectOverride(t *testing.T) {
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, "package.json"), []byte("{}"), 0644)
+	_ = os.WriteFile(filepath.Join(dir, ProjectIgnoreFile), []byte("# keep build output visible\n!dist\ntmp/\n"), 0644)
+
+	rules, err := NewIgnoreRules(dir, nil, nil)
+	require.NoError(t, err)
+	assert.False(t, rules.MatchDir("dist"))
+	assert.True(t, rules.MatchDir("tmp"))
+	assert.True(t, rules.MatchDir("node_modules"))
+}
+
+func TestIgnoreRules_NilMatchesNothing(t *testing.T) {
+	var rules *IgnoreRules
+	assert.False(t, rules.Match("target/debug/app"))
+	assert.False(t, rules.MatchDir("target"))
+	assert.Nil(t, rules.Dirs())
+}
+
+func TestTakeWithRules_SkipsIgnoredDirs(t *testing.T) {
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, "Cargo.toml"), []byte("[package]"), 0644)
+	_ = os.MkdirAll(filepath.Join(dir, "target", "debug"), 0755)
+	_ = os.WriteFile(filepath.Join(dir, "target", "debug", "app"), []byte("bin"), 0644)
+	_ = os.MkdirAll(filepath.Join(dir, "src"), 0755)
+	_ = os.WriteFile(filepath.Join(dir, "src", "main.rs"), []byte("fn main() {}"), 0644)
+
+	rules, err := NewIgnoreRules(dir, nil, nil)
+	require.NoError(t, err)
+
+	snap, err := TakeWithRules(dir, rules)
+	require.NoError(t, err)
+	assert.Contains(t, snap, "src/main.rs")
+	assert.NotContains(t, snap, "target")
+	assert.NotContains(t, snap, "target/debug/app")
+}
+
+func TestFilterNoiseWithRules_RemovesIgnoredDirs(t *testing.T) {
+	rules := &IgnoreRules{dirs: map[string]bool{".venv": true}}
+	changes := []Change{
+		{Path: ".venv/lib/site.py", Type: "created"},
+		{Path: "pkg/.venv/bin/python", Type: "created"},
+		{Path: "app.py", Type: "modified"},
+	}
+	filtered := FilterNoiseWithRules(changes, Snapshot{}, Snapshot{}, rules)
+	assert.Len(t, filtered, 1)
+	assert.Equal(t, "app.py", filtered[0].Path)
+}
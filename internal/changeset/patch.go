@@ -0,0 +1,359 @@
+package changeset
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// StashDir is the directory in a session's directory holding copies of the
+// files in its snapshots, named by content hash
+const StashDir = "stash"
+
+// DefaultStashLimit is the hash limit used to stash files when
+// changeset.hash_limit is not set
+const DefaultStashLimit = 1 << 20
+
+// patchContext is the number of unchanged lines around each hunk
+const patchContext = 3
+
+// maxDiffEdits bounds the line diff; files that differ by more lines are
+// shown as replaced entirely
+const maxDiffEdits = 1000
+
+var hashRe = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// stashFile hashes a file and keeps a copy of it in stash, named by its hash,
+// unless one is already there
+func stashFile(path, stash string) (string, error) {
+	hash, err := hashFile(path)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(filepath.Join(stash, hash)); err == nil {
+		return hash, nil
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = src.Close() }()
+	tmp, err := os.CreateTemp(stash, ".tmp-")
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	// The file may change after it was hashed; name the copy by what was copied
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, h), src)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	hash = hex.EncodeToString(h.Sum(nil))
+	if err := os.Rename(tmp.Name(), filepath.Join(stash, hash)); err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+// readStash returns the stashed content with the given hash
+func readStash(stash, hash string) ([]byte, error) {
+	if !hashRe.MatchString(hash) {
+		return nil, fmt.Errorf("invalid content hash %q", hash)
+	}
+	return os.ReadFile(filepath.Join(stash, hash))
+}
+
+// WritePatch writes the content changes of a mount as a unified diff that
+// applies with `git apply` or `patch -p1` in the mount's source directory.
+// Contents are read from stash, so only files hashed by both snapshots are
+// included; the paths of changed files that couldn't be included (binary,
+// over the hash limit, or not stashed) are returned.
+func WritePatch(w io.Writer, mc MountChanges, stash string) ([]string, error) {
+	var skipped []string
+	for _, c := range mc.Changes {
+		if c.Mode.IsDir() {
+			continue
+		}
+		if c.Type == "renamed" {
+			// Renames are detected by identical content
+			_, err := fmt.Fprintf(w, "diff --git a/%s b/%s\nsimilarity index 100%%\nrename from %s\nrename to %s\n",
+				filepath.ToSlash(c.OldPath), filepath.ToSlash(c.Path), filepath.ToSlash(c.OldPath), filepath.ToSlash(c.Path))
+			if err != nil {
+				return skipped, err
+			}
+			continue
+		}
+		var oldData, newData []byte
+		var err error
+		if c.Type != "created" {
+			if oldData, err = readStash(stash, c.OldHash); err != nil {
+				skipped = append(skipped, c.Path)
+				continue
+			}
+		}
+		if c.Type != "deleted" {
+			if newData, err = readStash(stash, c.NewHash); err != nil {
+				skipped = append(skipped, c.Path)
+				continue
+			}
+		}
+		if isBinary(oldData) || isBinary(newData) {
+			skipped = append(skipped, c.Path)
+			continue
+		}
+		if err := writeFilePatch(w, filepath.ToSlash(c.Path), c, oldData, newData); err != nil {
+			return skipped, err
+		}
+	}
+	return skipped, nil
+}
+
+// WriteFileDiff writes a unified diff of one file from oldData to newData,
+// with path in the headers. Nil oldData is a new file. Nothing is written if
+// their lines are the same.
+func WriteFileDiff(w io.Writer, path string, oldData, newData []byte) error {
+	c := Change{Type: "modified", Mode: 0644}
+	if oldData == nil {
+		c.Type = "created"
+	}
+	return writeFilePatch(w, filepath.ToSlash(path), c, oldData, newData)
+}
+
+// isBinary reports whether content looks binary, the way git decides
+func isBinary(data []byte) bool {
+	if len(data) > 8000 {
+		data = data[:8000]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
+
+// writeFilePatch writes one file's header and hunks
+func writeFilePatch(w io.Writer, path string, c Change, oldData, newData []byte) error {
+	edits := diffLines(splitLines(oldData), splitLines(newData))
+	if c.Type == "modified" && !hasChanges(edits) {
+		return nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "diff --git a/%s b/%s\n", path, path)
+	oldName, newName := "a/"+path, "b/"+path
+	switch c.Type {
+	case "created":
+		fmt.Fprintf(&b, "new file mode %s\n", gitMode(c.Mode))
+		oldName = "/dev/null"
+	case "deleted":
+		fmt.Fprintf(&b, "deleted file mode %s\n", gitMode(c.Mode))
+		newName = "/dev/null"
+	}
+	if hasChanges(edits) {
+		fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+		writeHunks(&b, edits)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// gitMode formats a file mode as git does
+func gitMode(mode os.FileMode) string {
+	if mode&0111 != 0 {
+		return "100755"
+	}
+	return "100644"
+}
+
+// splitLines splits content into lines, keeping each line's newline
+func splitLines(data []byte) []string {
+	var lines []string
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n') + 1
+		if i == 0 {
+			i = len(data)
+		}
+		lines = append(lines, string(data[:i]))
+		data = data[i:]
+	}
+	return lines
+}
+
+// edit is one line of a line diff: ' ' kept, '-' removed, or '+' added
+type edit struct {
+	op   byte
+	line string
+}
+
+func hasChanges(edits []edit) bool {
+	for _, e := range edits {
+		if e.op != ' ' {
+			return true
+		}
+	}
+	return false
+}
+
+// diffLines returns the edits turning a into b
+func diffLines(a, b []string) []edit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var edits []edit
+	for _, line := range a[:prefix] {
+		edits = append(edits, edit{' ', line})
+	}
+	edits = append(edits, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		edits = append(edits, edit{' ', line})
+	}
+	return edits
+}
+
+// myers finds a shortest edit script with Myers' algorithm. Past
+// maxDiffEdits it gives up and replaces all of a with b.
+func myers(a, b []string) []edit {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	var trace [][]int // v before each step d, for k in [-d, d]
+	for d := 0; d <= n+m; d++ {
+		if d > maxDiffEdits {
+			return replaceLines(a, b)
+		}
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(a, b, trace)
+			}
+		}
+	}
+	return replaceLines(a, b)
+}
+
+// backtrack recovers the edits of a Myers search from its trace
+func backtrack(a, b []string, trace [][]int) []edit {
+	var edits []edit
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d > 0; d-- {
+		vd := trace[d]
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && vd[k-1+d] < vd[k+1+d]) {
+			prevK = k + 1
+		}
+		prevX := vd[prevK+d]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			edits = append(edits, edit{' ', a[x-1]})
+			x--
+			y--
+		}
+		if x == prevX {
+			edits = append(edits, edit{'+', b[y-1]})
+			y--
+		} else {
+			edits = append(edits, edit{'-', a[x-1]})
+			x--
+		}
+	}
+	for x > 0 && y > 0 {
+		edits = append(edits, edit{' ', a[x-1]})
+		x--
+		y--
+	}
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+// replaceLines removes all of a and adds all of b
+func replaceLines(a, b []string) []edit {
+	edits := make([]edit, 0, len(a)+len(b))
+	for _, line := range a {
+		edits = append(edits, edit{'-', line})
+	}
+	for _, line := range b {
+		edits = append(edits, edit{'+', line})
+	}
+	return edits
+}
+
+// writeHunks writes edits as unified diff hunks with patchContext lines of
+// context, merging hunks whose context would overlap
+func writeHunks(b *strings.Builder, edits []edit) {
+	// Line numbers in a and b before each edit
+	oldLine := make([]int, len(edits)+1)
+	newLine := make([]int, len(edits)+1)
+	for i, e := range edits {
+		oldLine[i+1], newLine[i+1] = oldLine[i], newLine[i]
+		if e.op != '+' {
+			oldLine[i+1]++
+		}
+		if e.op != '-' {
+			newLine[i+1]++
+		}
+	}
+
+	for i := 0; i < len(edits); {
+		if edits[i].op == ' ' {
+			i++
+			continue
+		}
+		start := max(i-patchContext, 0)
+		last := i // last changed edit in the hunk
+		for j := i + 1; j < len(edits) && j <= last+2*patchContext+1; j++ {
+			if edits[j].op != ' ' {
+				last = j
+			}
+		}
+		end := min(last+patchContext+1, len(edits))
+
+		oldCount, newCount := oldLine[end]-oldLine[start], newLine[end]-newLine[start]
+		fmt.Fprintf(b, "@@ -%s +%s @@\n", hunkRange(oldLine[start], oldCount), hunkRange(newLine[start], newCount))
+		for _, e := range edits[start:end] {
+			b.WriteByte(e.op)
+			b.WriteString(e.line)
+			if !strings.HasSuffix(e.line, "\n") {
+				b.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = end
+	}
+}
+
+// hunkRange formats the start and length of a hunk's side; an empty side
+// starts at the line before it
+func hunkRange(before, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", before)
+	}
+	return fmt.Sprintf("%d,%d", before+1, count)
+}
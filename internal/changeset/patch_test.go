@@ -0,0 +1,115 @@
+package changeset
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffLines(t *testing.T) {
+	a := splitLines([]byte("a\nb\nc\nd\n"))
+	b := splitLines([]byte("a\nc\nx\nd\n"))
+	var ops []string
+	for _, e := range diffLines(a, b) {
+		ops = append(ops, string(e.op)+strings.TrimSuffix(e.line, "\n"))
+	}
+	assert.Equal(t, []string{" a", "-b", " c", "+x", " d"}, ops)
+
+	assert.Empty(t, diffLines(nil, nil))
+	assert.False(t, hasChanges(diffLines(a, a)))
+}
+
+func TestWriteHunks(t *testing.T) {
+	var before, after []string
+	for i := 1; i <= 20; i++ {
+		line := strings.Repeat("x", i) + "\n"
+		before = append(before, line)
+		if i == 2 {
+			line = "changed\n"
+		}
+		if i != 18 {
+			after = append(after, line)
+		}
+	}
+	var b strings.Builder
+	writeHunks(&b, diffLines(before, after))
+	hunks := strings.Count(b.String(), "@@ -")
+	assert.Equal(t, 2, hunks, b.String())
+	assert.True(t, strings.HasPrefix(b.String(), "@@ -1,5 +1,5 @@\n x\n-xx\n+changed\n"), b.String())
+	assert.Contains(t, b.String(), "@@ -15,6 +15,5 @@\n")
+}
+
+func TestWritePatch(t *testing.T) {
+	root := t.TempDir()
+	stash := t.TempDir()
+	write := func(rel, data string) {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, filepath.Dir(rel)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(root, rel), []byte(data), 0644))
+	}
+	write("main.go", "package main\n\nfunc main() {\n}\n")
+	write("old.txt", "bye\n")
+	write("logo.png", "\x89PNG\x00\x00")
+	write("guide.md", "# Guide\n")
+	opts := SnapshotOptions{HashLimit: 1 << 20, Stash: stash}
+	before, err := TakeWithOptions(root, opts)
+	require.NoError(t, err)
+	original, _ := os.ReadFile(filepath.Join(root, "main.go"))
+
+	write("main.go", "package main\n\nfunc main() {\n\tprintln(\"hi\")\n}\n")
+	write("docs/new.md", "no newline")
+	write("logo.png", "\x89PNG\x00\x01")
+	require.NoError(t, os.Remove(filepath.Join(root, "old.txt")))
+	require.NoError(t, os.Rename(filepath.Join(root, "guide.md"), filepath.Join(root, "docs", "guide.md")))
+	after, err := TakeWithOptions(root, opts)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	skipped, err := WritePatch(&buf, MountChanges{Changes: Diff(before, after)}, stash)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"logo.png"}, skipped)
+
+	patch := buf.String()
+	assert.Contains(t, patch, "diff --git a/docs/new.md b/docs/new.md\nnew file mode 100644\n--- /dev/null\n+++ b/docs/new.md\n@@ -0,0 +1,1 @@\n+no newline\n\\ No newline at end of file\n")
+	assert.Contains(t, patch, "@@ -1,4 +1,5 @@\n package main\n \n func main() {\n+\tprintln(\"hi\")\n }\n")
+	assert.Contains(t, patch, "diff --git a/guide.md b/docs/guide.md\nsimilarity index 100%\nrename from guide.md\nrename to docs/guide.md\n")
+	assert.Contains(t, patch, "deleted file mode 100644\n--- a/old.txt\n+++ /dev/null\n@@ -1,1 +0,0 @@\n-bye\n")
+
+	// The patch reverses cleanly onto the tree it was taken from
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	patchFile := filepath.Join(t.TempDir(), "session.patch")
+	require.NoError(t, os.WriteFile(patchFile, buf.Bytes(), 0644))
+	out, err := exec.Command("git", "-C", root, "apply", "--reverse", patchFile).CombinedOutput()
+	require.NoError(t, err, string(out))
+	restored, _ := os.ReadFile(filepath.Join(root, "main.go"))
+	assert.Equal(t, original, restored)
+	assert.FileExists(t, filepath.Join(root, "old.txt"))
+	assert.FileExists(t, filepath.Join(root, "guide.md"))
+	assert.NoFileExists(t, filepath.Join(root, "docs", "new.md"))
+}
+
+func TestWriteFileDiff(t *testing.T) {
+	var b strings.Builder
+	require.NoError(t, WriteFileDiff(&b, "settings.json", []byte("{\n  \"a\": 1\n}\n"), []byte("{\n  \"a\": 2\n}\n")))
+	assert.Equal(t, "diff --git a/settings.json b/settings.json\n--- a/settings.json\n+++ b/settings.json\n@@ -1,3 +1,3 @@\n {\n-  \"a\": 1\n+  \"a\": 2\n }\n", b.String())
+
+	b.Reset()
+	require.NoError(t, WriteFileDiff(&b, "settings.json", nil, []byte("{}\n")))
+	assert.Contains(t, b.String(), "new file mode 100644\n--- /dev/null\n+++ b/settings.json\n")
+
+	b.Reset()
+	require.NoError(t, WriteFileDiff(&b, "settings.json", []byte("{}\n"), []byte("{}\n")))
+	assert.Empty(t, b.String())
+}
+
+func TestReadStash_RejectsPaths(t *testing.T) {
+	_, err := readStash(t.TempDir(), "../../etc/passwd")
+	assert.Error(t, err)
+}
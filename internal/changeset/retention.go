@@ -0,0 +1,86 @@
+package changeset
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// StoredChangeset describes a session's saved changeset.
+type StoredChangeset struct {
+	SessionID string    `json:"session_id"`
+	Time      time.Time `json:"time"`              // when the changeset was saved, at the session's end
+	Project   string    `json:"project,omitempty"` // host path of the first project mount
+	Changes   int       `json:"changes"`
+	Size      int64     `json:"size"` // bytes used by the session's directory
+}
+
+// ListChangesets returns the changesets saved in the session directories
+// under sessionsDir, newest first. Unreadable changesets are skipped.
+func ListChangesets(sessionsDir string) ([]StoredChangeset, error) {
+	entries, err := os.ReadDir(sessionsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var stored []StoredChangeset
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		dir := filepath.Join(sessionsDir, e.Name())
+		path := filepath.Join(dir, "bootstrap", "changeset.json")
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		cs, err := LoadChangeset(path)
+		if err != nil {
+			continue
+		}
+		s := StoredChangeset{SessionID: e.Name(), Time: info.ModTime(), Size: dirSize(dir)}
+		for _, mc := range cs.MountChanges {
+			s.Changes += len(FilterPaths(mc.Changes)) + len(mc.Summary)
+			if s.Project == "" && mountLabel(mc.Target) == "Project" {
+				s.Project = mc.Source
+			}
+		}
+		stored = append(stored, s)
+	}
+	sort.Slice(stored, func(i, j int) bool { return stored[i].Time.After(stored[j].Time) })
+	return stored, nil
+}
+
+// dirSize returns the total size of the files under dir
+func dirSize(dir string) int64 {
+	var size int64
+	_ = filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && !d.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// Expired returns the changesets a retention policy no longer keeps: those
+// saved more than keepDays days before now, and those beyond the newest
+// keepSessions. Zero disables either limit. stored must be newest first.
+func Expired(stored []StoredChangeset, keepDays, keepSessions int, now time.Time) []StoredChangeset {
+	var expired []StoredChangeset
+	cutoff := now.AddDate(0, 0, -keepDays)
+	for i, s := range stored {
+		if (keepSessions > 0 && i >= keepSessions) || (keepDays > 0 && s.Time.Before(cutoff)) {
+			expired = append(expired, s)
+		}
+	}
+	return expired
+}
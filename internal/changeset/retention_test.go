@@ -0,0 +1,65 @@
+package changeset
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListChangesets(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	save := func(id string, age time.Duration, cs *SessionChangeset) {
+		bootstrap := filepath.Join(dir, id, "bootstrap")
+		require.NoError(t, os.MkdirAll(bootstrap, 0755))
+		path := filepath.Join(bootstrap, "changeset.json")
+		require.NoError(t, SaveChangeset(path, cs))
+		require.NoError(t, os.Chtimes(path, now.Add(-age), now.Add(-age)))
+	}
+	save("old", 48*time.Hour, &SessionChangeset{SessionID: "old"})
+	save("new", time.Hour, &SessionChangeset{SessionID: "new", MountChanges: []MountChanges{
+		{Source: "/home/u/.faize/toolchain", Target: "/opt/toolchain", Summary: []string{"+ installed go 1.22"}},
+		{Source: "/home/u/app", Target: "/workspace", Changes: []Change{{Path: "a.go", Type: "created"}, {Path: ".git/index", Type: "modified"}}},
+	}})
+	// Sessions without a changeset, and stray files, are skipped
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "running", "bootstrap"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "abc.json"), []byte("{}"), 0644))
+
+	stored, err := ListChangesets(dir)
+	require.NoError(t, err)
+	require.Len(t, stored, 2)
+	assert.Equal(t, "new", stored[0].SessionID)
+	assert.Equal(t, "/home/u/app", stored[0].Project)
+	assert.Equal(t, 2, stored[0].Changes)
+	assert.Positive(t, stored[0].Size)
+	assert.Equal(t, "old", stored[1].SessionID)
+
+	stored, err = ListChangesets(filepath.Join(dir, "missing"))
+	require.NoError(t, err)
+	assert.Empty(t, stored)
+}
+
+func TestExpired(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	stored := []StoredChangeset{
+		{SessionID: "a", Time: now.Add(-time.Hour)},
+		{SessionID: "b", Time: now.AddDate(0, 0, -2)},
+		{SessionID: "c", Time: now.AddDate(0, 0, -10)},
+	}
+	ids := func(s []StoredChangeset) []string {
+		var out []string
+		for _, c := range s {
+			out = append(out, c.SessionID)
+		}
+		return out
+	}
+
+	assert.Empty(t, Expired(stored, 0, 0, now))
+	assert.Equal(t, []string{"c"}, ids(Expired(stored, 7, 0, now)))
+	assert.Equal(t, []string{"b", "c"}, ids(Expired(stored, 0, 1, now)))
+	assert.Equal(t, []string{"b", "c"}, ids(Expired(stored, 7, 1, now)))
+}
@@ -0,0 +1,167 @@
+package changeset
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNoContent is returned by Revert when no pre-session copy of a file was kept
+var ErrNoContent = errors.New("no pre-session copy was kept")
+
+// ErrChangedSince is returned by Revert when a file no longer matches the
+// session's end, so reverting would lose later edits
+var ErrChangedSince = errors.New("changed since the session ended")
+
+// Revert undoes a change in the mount at root: created files are removed,
+// renamed files are moved back, and modified and deleted files are restored
+// from their pre-session copy in stash. A created directory is only removed
+// when empty. Reverting a change again does nothing.
+func Revert(root string, c Change, stash string) error {
+	path, err := revertPath(root, c.Path)
+	if err != nil {
+		return err
+	}
+	if c.Type == "renamed" {
+		return revertRename(root, path, c)
+	}
+	if c.Type != "created" && c.OldHash != "" {
+		if current, err := hashFile(path); err == nil && current == c.OldHash {
+			return nil
+		}
+	}
+
+	switch c.Type {
+	case "created":
+		if _, err := os.Lstat(path); os.IsNotExist(err) {
+			return nil
+		}
+		if !c.Mode.IsDir() {
+			if err := checkUnchanged(path, c.NewHash); err != nil {
+				return err
+			}
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	case "modified":
+		data, err := readStash(stash, c.OldHash)
+		if err != nil {
+			return ErrNoContent
+		}
+		if err := checkUnchanged(path, c.NewHash); err != nil {
+			return err
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		return writeAtomic(path, data, info.Mode().Perm())
+	case "deleted":
+		if info, err := os.Lstat(path); err == nil {
+			if info.IsDir() && c.Mode.IsDir() {
+				return nil
+			}
+			return ErrChangedSince
+		}
+		if c.Mode.IsDir() {
+			return os.MkdirAll(path, c.Mode.Perm()|0700)
+		}
+		data, err := readStash(stash, c.OldHash)
+		if err != nil {
+			return ErrNoContent
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		return writeAtomic(path, data, c.Mode.Perm())
+	}
+	return fmt.Errorf("unknown change type %q", c.Type)
+}
+
+// revertRename moves a renamed file at path back to c.OldPath
+func revertRename(root, path string, c Change) error {
+	oldPath, err := revertPath(root, c.OldPath)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		if _, err := os.Lstat(oldPath); err == nil {
+			return nil
+		}
+		return ErrChangedSince
+	}
+	if err := checkUnchanged(path, c.NewHash); err != nil {
+		return err
+	}
+	if _, err := os.Lstat(oldPath); err == nil {
+		return ErrChangedSince
+	}
+	if err := os.MkdirAll(filepath.Dir(oldPath), 0755); err != nil {
+		return err
+	}
+	return os.Rename(path, oldPath)
+}
+
+// revertPath joins a changed path to the mount root. Changesets are saved in
+// the guest-writable bootstrap directory, so the path must stay below root
+// and not pass through symlinks.
+func revertPath(root, rel string) (string, error) {
+	if !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("invalid path %q", rel)
+	}
+	dir := root
+	parts := strings.Split(filepath.Dir(rel), string(filepath.Separator))
+	for _, part := range parts {
+		if part == "." {
+			continue
+		}
+		dir = filepath.Join(dir, part)
+		if info, err := os.Lstat(dir); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("%s is a symlink", dir)
+		}
+	}
+	return filepath.Join(root, rel), nil
+}
+
+// checkUnchanged returns ErrChangedSince if the file's content no longer has
+// the given hash. Files that weren't hashed can't be checked.
+func checkUnchanged(path, hash string) error {
+	if hash == "" {
+		return nil
+	}
+	current, err := hashFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ErrChangedSince
+		}
+		return err
+	}
+	if current != hash {
+		return ErrChangedSince
+	}
+	return nil
+}
+
+// writeAtomic replaces a file through a temporary file in its directory
+func writeAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".faize-revert-")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
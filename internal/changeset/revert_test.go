@@ -0,0 +1,99 @@
+package changeset
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevert(t *testing.T) {
+	root := t.TempDir()
+	stash := t.TempDir()
+	write := func(rel, data string) {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, filepath.Dir(rel)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(root, rel), []byte(data), 0644))
+	}
+	read := func(rel string) string {
+		data, err := os.ReadFile(filepath.Join(root, rel))
+		require.NoError(t, err)
+		return string(data)
+	}
+	write("main.go", "before\n")
+	write("lib/old.go", "old\n")
+	opts := SnapshotOptions{HashLimit: 1 << 20, Stash: stash}
+	before, err := TakeWithOptions(root, opts)
+	require.NoError(t, err)
+
+	write("main.go", "after\n")
+	write("gen/new.go", "new\n")
+	require.NoError(t, os.RemoveAll(filepath.Join(root, "lib")))
+	after, err := TakeWithOptions(root, opts)
+	require.NoError(t, err)
+
+	changes := make(map[string]Change)
+	for _, c := range Diff(before, after) {
+		changes[c.Path] = c
+	}
+
+	require.NoError(t, Revert(root, changes["main.go"], stash))
+	assert.Equal(t, "before\n", read("main.go"))
+	require.NoError(t, Revert(root, changes["main.go"], stash), "already reverted")
+
+	require.NoError(t, Revert(root, changes[filepath.Join("lib", "old.go")], stash))
+	assert.Equal(t, "old\n", read("lib/old.go"))
+
+	require.NoError(t, Revert(root, changes[filepath.Join("gen", "new.go")], stash))
+	assert.NoFileExists(t, filepath.Join(root, "gen", "new.go"))
+	require.NoError(t, Revert(root, changes["gen"], stash))
+	assert.NoDirExists(t, filepath.Join(root, "gen"))
+	require.NoError(t, Revert(root, changes[filepath.Join("gen", "new.go")], stash), "already reverted")
+}
+
+func TestRevert_ChangedSince(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a.txt"), []byte("edited later"), 0644))
+	c := Change{Path: "a.txt", Type: "created", NewHash: "0000000000000000000000000000000000000000000000000000000000000000"}
+	assert.ErrorIs(t, Revert(root, c, t.TempDir()), ErrChangedSince)
+	assert.FileExists(t, filepath.Join(root, "a.txt"))
+}
+
+func TestRevert_NoContent(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "big.bin"), []byte("x"), 0644))
+	c := Change{Path: "big.bin", Type: "modified"}
+	assert.ErrorIs(t, Revert(root, c, t.TempDir()), ErrNoContent)
+}
+
+func TestRevert_RejectsEscapes(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	require.NoError(t, os.Symlink(outside, filepath.Join(root, "link")))
+
+	assert.Error(t, Revert(root, Change{Path: "../x", Type: "created"}, ""))
+	assert.Error(t, Revert(root, Change{Path: filepath.Join("link", "x"), Type: "created"}, ""))
+}
+
+func TestRevert_Renamed(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a.txt"), []byte("content"), 0644))
+	opts := SnapshotOptions{HashLimit: 1 << 20}
+	before, err := TakeWithOptions(root, opts)
+	require.NoError(t, err)
+	require.NoError(t, os.Mkdir(filepath.Join(root, "dir"), 0755))
+	require.NoError(t, os.Rename(filepath.Join(root, "a.txt"), filepath.Join(root, "dir", "b.txt")))
+	after, err := TakeWithOptions(root, opts)
+	require.NoError(t, err)
+
+	changes := Diff(before, after)
+	require.Len(t, changes, 2)
+	renamed := changes[1]
+	require.Equal(t, "renamed", renamed.Type)
+
+	require.NoError(t, Revert(root, renamed, ""))
+	assert.FileExists(t, filepath.Join(root, "a.txt"))
+	assert.NoFileExists(t, filepath.Join(root, "dir", "b.txt"))
+	require.NoError(t, Revert(root, renamed, ""), "already reverted")
+}
@@ -2,7 +2,11 @@ package changeset
 
 import (
 	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"io"
 	"net"
 	"os"
 	"path/filepath"
@@ -22,111 +26,249 @@ type FileEntry struct {
 	IsDir   bool        `json:"is_dir"`
 	// For summarized directories (node_modules, etc): count of children
 	ChildCount int `json:"child_count,omitempty"`
+	// Hash is the SHA-256 of the content of files within the snapshot's
+	// hash limit; empty otherwise
+	Hash string `json:"hash,omitempty"`
+}
+
+// SnapshotOptions controls what TakeWithOptions records.
+type SnapshotOptions struct {
+	Rules *IgnoreRules // directories to skip; nil skips none
+	// HashLimit is the size of the largest file whose content is hashed, so
+	// Diff can tell content changes from files that were only touched or
+	// were changed and reverted; zero disables hashing
+	HashLimit int64
+	// Workers is the number of directories read concurrently; zero picks
+	// one per CPU, between 4 and 16
+	Workers int
+	// Stash is a directory where a copy of each hashed file is kept, named
+	// by its hash, so WritePatch can show content changes; empty keeps none
+	Stash string
 }
 
 // Snapshot is a map of relative paths to FileEntry.
 type Snapshot map[string]FileEntry
 
+// summarizeChildren is the number of direct children above which a directory
+// is recorded with its child count instead of its contents
+const summarizeChildren = 500
+
 // Take walks a directory and returns a Snapshot.
-// - Uses filepath.WalkDir for efficiency
+// - Reads directories concurrently, each once
 // - Skips .git directory contents (records .git dir entry itself only)
 // - For node_modules or any dir with >500 direct children: records dir entry + child count, doesn't recurse
 // - All paths are relative to root
 func Take(root string) (Snapshot, error) {
-	snap := make(Snapshot)
+	return TakeWithRules(root, nil)
+}
 
-	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
+// TakeWithRules is like Take but skips directories matched by rules entirely,
+// recording neither the directory nor its contents.
+func TakeWithRules(root string, rules *IgnoreRules) (Snapshot, error) {
+	return TakeWithOptions(root, SnapshotOptions{Rules: rules})
+}
 
-		rel, err := filepath.Rel(root, path)
-		if err != nil {
-			return err
-		}
+// TakeWithOptions is like Take but skips directories matched by opts.Rules
+// and hashes files up to opts.HashLimit bytes.
+func TakeWithOptions(root string, opts SnapshotOptions) (Snapshot, error) {
+	snap := make(Snapshot)
+	if err := walkInto(snap, root, root, opts); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// Refresh returns a copy of before, a snapshot of root taken with opts,
+// updated for the paths (relative to root) that changed since, as reported by
+// a Watch. Only the changed paths are examined, so the result matches a new
+// snapshot without walking the rest of the tree; directories that grow past
+// the summarize threshold meanwhile keep listing their files. A change to
+// root itself ("."), such as dropped events, takes a new snapshot.
+func Refresh(root string, before Snapshot, changed []string, opts SnapshotOptions) (Snapshot, error) {
+	snap := make(Snapshot, len(before))
+	for path, entry := range before {
+		snap[path] = entry
+	}
 
-		// Skip the root itself
+	changed = append([]string(nil), changed...)
+	sort.Strings(changed)
+	parents := make(map[string]bool)
+	lastDir := ""
+	for _, rel := range changed {
+		rel = filepath.Clean(rel)
 		if rel == "." {
-			return nil
+			return TakeWithOptions(root, opts)
+		}
+		if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		// A directory that was walked again already covers its contents
+		if lastDir != "" && strings.HasPrefix(rel, lastDir+string(filepath.Separator)) {
+			continue
 		}
 
-		info, err := d.Info()
-		if err != nil {
-			return err
+		rel, ok := scanPoint(before, rel, opts.Rules)
+		if !ok {
+			continue
+		}
+		parents[filepath.Dir(rel)] = true
+		if snap[rel].IsDir {
+			prefix := rel + string(filepath.Separator)
+			for path := range snap {
+				if strings.HasPrefix(path, prefix) {
+					delete(snap, path)
+				}
+			}
 		}
+		delete(snap, rel)
 
-		entry := FileEntry{
-			Path:    rel,
-			Size:    info.Size(),
-			ModTime: info.ModTime(),
-			Mode:    info.Mode(),
-			IsDir:   d.IsDir(),
+		path := filepath.Join(root, rel)
+		info, err := os.Lstat(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err := walkInto(snap, root, path, opts); err != nil {
+			return nil, err
 		}
+		if info.IsDir() {
+			lastDir = rel
+		}
+	}
 
-		// Handle .git: record dir entry, skip contents
-		if d.IsDir() && d.Name() == ".git" {
-			snap[rel] = entry
-			return filepath.SkipDir
+	// Entries were added to or removed from the parents
+	for dir := range parents {
+		if entry, ok := snap[dir]; ok && entry.IsDir {
+			restatDir(snap, root, dir)
 		}
+	}
+	return snap, nil
+}
 
-		// For directories, check child count before deciding to recurse
-		if d.IsDir() {
-			children, err := os.ReadDir(path)
-			if err != nil {
-				return err
-			}
-			childCount := len(children)
-			entry.ChildCount = childCount
+// restatDir updates the recorded metadata and child count of a directory
+func restatDir(snap Snapshot, root, rel string) {
+	path := filepath.Join(root, rel)
+	info, err := os.Lstat(path)
+	if err != nil || !info.IsDir() {
+		return
+	}
+	children, err := os.ReadDir(path)
+	if err != nil {
+		return
+	}
+	entry := snap[rel]
+	entry.Size, entry.ModTime, entry.Mode = info.Size(), info.ModTime(), info.Mode()
+	entry.ChildCount = len(children)
+	snap[rel] = entry
+}
 
-			// Summarize large dirs (node_modules or >500 direct children)
-			if d.Name() == "node_modules" || childCount > 500 {
-				snap[rel] = entry
-				return filepath.SkipDir
-			}
+// scanPoint returns the path to examine for a change at rel: rel itself, or
+// its nearest ancestor whose contents snapshots don't record (.git,
+// node_modules, and large directories). ok is false under a directory the
+// rules skip.
+func scanPoint(before Snapshot, rel string, rules *IgnoreRules) (string, bool) {
+	parts := strings.Split(rel, string(filepath.Separator))
+	for i, name := range parts[:len(parts)-1] {
+		if rules.matchEntry(parts[:i+1], true) {
+			return "", false
+		}
+		dir := filepath.Join(parts[:i+1]...)
+		if name == ".git" || name == "node_modules" || before[dir].ChildCount > summarizeChildren {
+			return dir, true
 		}
+	}
+	return rel, true
+}
 
-		snap[rel] = entry
-		return nil
-	})
+// hashFile returns the hex SHA-256 of a file's content
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
 	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
 
-	return snap, nil
+// ParseSize parses a size such as "512KB", "1MB", or "1048576" (bytes).
+func ParseSize(size string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(size))
+	units := []struct {
+		suffix string
+		size   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1}}
+	unit := int64(1)
+	for _, u := range units {
+		if strings.HasSuffix(s, u.suffix) {
+			s, unit = strings.TrimSpace(strings.TrimSuffix(s, u.suffix)), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q: expected bytes or a number with KB, MB, or GB", size)
+	}
+	return n * unit, nil
 }
 
 // Change represents a single file change.
 type Change struct {
 	Path    string `json:"path"` // relative to mount root
-	Type    string `json:"type"` // "created", "modified", "deleted"
+	Type    string `json:"type"` // "created", "modified", "deleted", "renamed"
 	OldSize int64  `json:"old_size,omitempty"`
 	NewSize int64  `json:"new_size,omitempty"`
+	// OldPath is where a renamed file was before the session
+	OldPath string `json:"old_path,omitempty"`
+	// ModTime is the file's modification time after the session; nil for deletions
+	ModTime *time.Time `json:"mod_time,omitempty"`
+	// Mode is the file's mode after the session, or before it for deletions
+	Mode os.FileMode `json:"mode,omitempty"`
+	// OldHash and NewHash are the content hashes before and after the
+	// session, for files within the snapshots' hash limit
+	OldHash string `json:"old_hash,omitempty"`
+	NewHash string `json:"new_hash,omitempty"`
 }
 
 // Diff compares two snapshots and returns changes.
 // - Files in after but not before = "created"
 // - Files in before but not after = "deleted"
-// - Files in both but with different size or modtime = "modified"
+// - Files in both but with different size or modtime = "modified", unless
+// both snapshots hashed the file and the content is unchanged
+// - A deleted and a created file with the same content = "renamed"
 func Diff(before, after Snapshot) []Change {
 	var changes []Change
 
 	// Check for created and modified
 	for path, afterEntry := range after {
 		beforeEntry, exists := before[path]
+		modTime := afterEntry.ModTime
 		if !exists {
 			changes = append(changes, Change{
 				Path:    path,
 				Type:    "created",
 				NewSize: afterEntry.Size,
+				ModTime: &modTime,
+				Mode:    afterEntry.Mode,
+				NewHash: afterEntry.Hash,
 			})
 			continue
 		}
+		if beforeEntry.Hash != "" && beforeEntry.Hash == afterEntry.Hash {
+			continue
+		}
 		if beforeEntry.Size != afterEntry.Size || !beforeEntry.ModTime.Equal(afterEntry.ModTime) {
 			changes = append(changes, Change{
 				Path:    path,
 				Type:    "modified",
 				OldSize: beforeEntry.Size,
 				NewSize: afterEntry.Size,
+				ModTime: &modTime,
+				Mode:    afterEntry.Mode,
+				OldHash: beforeEntry.Hash,
+				NewHash: afterEntry.Hash,
 			})
 		}
 	}
@@ -138,6 +280,8 @@ func Diff(before, after Snapshot) []Change {
 				Path:    path,
 				Type:    "deleted",
 				OldSize: beforeEntry.Size,
+				Mode:    beforeEntry.Mode,
+				OldHash: beforeEntry.Hash,
 			})
 		}
 	}
@@ -147,7 +291,77 @@ func Diff(before, after Snapshot) []Change {
 		return changes[i].Path < changes[j].Path
 	})
 
-	return changes
+	return detectRenames(changes, before)
+}
+
+// renameKey identifies a file's content for rename detection: its hash, or
+// without one its size and modification time, which a move keeps
+func renameKey(hash string, size int64, modTime time.Time) string {
+	if hash != "" {
+		return hash
+	}
+	return fmt.Sprintf("%d@%d", size, modTime.UnixNano())
+}
+
+// detectRenames replaces each deleted file whose content reappears as a
+// created file with a single "renamed" change. A candidate with the same
+// name is preferred. Empty files and directories are never paired, since
+// their content says nothing about where they came from. changes must be
+// sorted by path and stays sorted.
+func detectRenames(changes []Change, before Snapshot) []Change {
+	created := make(map[string][]int)
+	for i, c := range changes {
+		if c.Type == "created" && !c.Mode.IsDir() && c.NewSize > 0 && c.ModTime != nil {
+			key := renameKey(c.NewHash, c.NewSize, *c.ModTime)
+			created[key] = append(created[key], i)
+		}
+	}
+	if len(created) == 0 {
+		return changes
+	}
+
+	removed := make(map[int]bool)
+	for i, c := range changes {
+		if c.Type != "deleted" || c.Mode.IsDir() || c.OldSize == 0 {
+			continue
+		}
+		key := renameKey(c.OldHash, c.OldSize, before[c.Path].ModTime)
+		candidates := created[key]
+		if len(candidates) == 0 {
+			continue
+		}
+		pick := 0
+		for j, idx := range candidates {
+			if filepath.Base(changes[idx].Path) == filepath.Base(c.Path) {
+				pick = j
+				break
+			}
+		}
+		idx := candidates[pick]
+		created[key] = append(candidates[:pick:pick], candidates[pick+1:]...)
+
+		to := changes[idx]
+		changes[idx] = Change{
+			Path:    to.Path,
+			Type:    "renamed",
+			OldPath: c.Path,
+			OldSize: c.OldSize,
+			NewSize: to.NewSize,
+			ModTime: to.ModTime,
+			Mode:    to.Mode,
+			OldHash: c.OldHash,
+			NewHash: to.NewHash,
+		}
+		removed[i] = true
+	}
+
+	result := changes[:0]
+	for i, c := range changes {
+		if !removed[i] {
+			result = append(result, c)
+		}
+	}
+	return result
 }
 
 // MountChanges groups changes by mount source.
@@ -155,12 +369,16 @@ type MountChanges struct {
 	Source  string   `json:"source"` // host path
 	Target  string   `json:"target"` // guest path
 	Changes []Change `json:"changes"`
+	// Summary is shown instead of Changes for mounts with a Summarizer
+	Summary []string `json:"summary,omitempty"`
+	// Stats aggregates Changes; nil in changesets saved before stats were recorded
+	Stats *MountStats `json:"stats,omitempty"`
 }
 
 // NetworkEvent represents a parsed network event from guest-side iptables LOG rules.
 type NetworkEvent struct {
 	Timestamp string `json:"timestamp"`
-	Action    string `json:"action"`          // "CONN", "DENY", or "DNS"
+	Action    string `json:"action"`          // "CONN", "DENY", "DNS", or "DOH" (a blocked DNS-over-HTTPS/TLS attempt)
 	Proto     string `json:"proto,omitempty"` // "TCP", "UDP"
 	DstIP     string `json:"dst_ip,omitempty"`
 	DstPort   int    `json:"dst_port,omitempty"`
@@ -172,7 +390,8 @@ type NetworkEvent struct {
 type SessionChangeset struct {
 	SessionID     string         `json:"session_id"`
 	MountChanges  []MountChanges `json:"mount_changes"`
-	GuestChanges  []string       `json:"guest_changes"` // lines from guest-changes.txt
+	GuestChanges  []string       `json:"guest_changes"`            // lines from guest-changes.txt
+	GuestPackages []string       `json:"guest_packages,omitempty"` // "name version" lines from guest-packages.txt
 	NetworkEvents []NetworkEvent `json:"network_events,omitempty"`
 }
 
@@ -250,11 +469,28 @@ func ParseGuestChanges(path string) ([]string, error) {
 	return lines, nil
 }
 
-// networkLogRe matches iptables LOG lines from dmesg with FAIZE_ prefixes.
-// Example line: "FAIZE_NET: IN= OUT=eth0 SRC=10.0.2.15 DST=140.82.114.4 ... PROTO=TCP SPT=45678 DPT=443"
+// ParseGuestPackages reads guest-packages.txt, the apk or apt packages
+// installed in the guest during the session as "name version" lines.
+func ParseGuestPackages(path string) ([]string, error) {
+	return ParseGuestChanges(path)
+}
+
+// PackageNames returns the names from "name version" package lines.
+func PackageNames(packages []string) []string {
+	names := make([]string, len(packages))
+	for i, p := range packages {
+		names[i], _, _ = strings.Cut(p, " ")
+	}
+	return names
+}
+
+// networkLogRe matches iptables LOG lines from dmesg with FAIZE_ prefixes,
+// optionally preceded by the RFC 3339 time the agent collected them.
+// Example line: "2026-03-01T12:00:00Z [  12.345] FAIZE_NET: IN= OUT=eth0 SRC=10.0.2.15 DST=140.82.114.4 ... PROTO=TCP SPT=45678 DPT=443"
 // Example line: "FAIZE_DENY: IN= OUT=eth0 SRC=10.0.2.15 DST=1.2.3.4 ... PROTO=TCP SPT=12345 DPT=80"
+// Example line: "FAIZE_DOH: IN= OUT=eth0 SRC=10.0.2.15 DST=8.8.8.8 ... PROTO=TCP SPT=12345 DPT=443"
 var networkLogRe = regexp.MustCompile(
-	`FAIZE_(NET|DENY):.*?SRC=(\S+)\s+DST=(\S+).*?PROTO=(\S+)(?:.*?SPT=(\d+))?(?:.*?DPT=(\d+))?`,
+	`^(\d{4}-\d{2}-\d{2}T\S+ )?.*?FAIZE_(NET|DENY|DOH):.*?SRC=(\S+)\s+DST=(\S+).*?PROTO=(\S+)(?:.*?SPT=(\d+))?(?:.*?DPT=(\d+))?`,
 )
 
 // ParseNetworkLog reads a network.log file (dmesg output with FAIZE_ prefixes)
@@ -280,19 +516,78 @@ func ParseNetworkLog(path string) ([]NetworkEvent, error) {
 		}
 
 		action := "CONN"
-		if matches[1] == "DENY" {
-			action = "DENY"
+		if matches[2] == "DENY" || matches[2] == "DOH" {
+			action = matches[2]
 		}
 
-		dstPort, _ := strconv.Atoi(matches[6])
-		srcPort, _ := strconv.Atoi(matches[5])
+		dstPort, _ := strconv.Atoi(matches[7])
+		srcPort, _ := strconv.Atoi(matches[6])
 
 		events = append(events, NetworkEvent{
-			Action:  action,
-			Proto:   matches[4],
-			DstIP:   matches[3],
-			DstPort: dstPort,
-			SrcPort: srcPort,
+			Timestamp: strings.TrimSpace(matches[1]),
+			Action:    action,
+			Proto:     matches[5],
+			DstIP:     matches[4],
+			DstPort:   dstPort,
+			SrcPort:   srcPort,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if events == nil {
+		return []NetworkEvent{}, nil
+	}
+	return events, nil
+}
+
+// proxyLogRe matches egress proxy decisions in proxy.log.
+// Example line: "2026-03-01T12:00:00Z FAIZE_PROXY: DENY CONNECT example.com:443"
+var proxyLogRe = regexp.MustCompile(`^(\S+) FAIZE_PROXY: (ALLOW|DENY) (\S+) (\S+)$`)
+
+// ParseProxyLog reads the host egress proxy's proxy.log and returns a CONN or
+// DENY event per request, with the requested host name as the domain. Name
+// lookups the host resolver refused are DENY events for UDP port 53.
+// Returns empty slice and nil error if the file doesn't exist.
+func ParseProxyLog(path string) ([]NetworkEvent, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []NetworkEvent{}, nil
+		}
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var events []NetworkEvent
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		matches := proxyLogRe.FindStringSubmatch(scanner.Text())
+		if matches == nil {
+			continue
+		}
+		host, portStr, err := net.SplitHostPort(matches[4])
+		if err != nil {
+			continue
+		}
+
+		action := "CONN"
+		if matches[2] == "DENY" {
+			action = "DENY"
+		}
+		port, _ := strconv.Atoi(portStr)
+		proto := "TCP"
+		if matches[3] == "DNS" {
+			proto = "UDP"
+		}
+
+		events = append(events, NetworkEvent{
+			Timestamp: matches[1],
+			Action:    action,
+			Proto:     proto,
+			DstPort:   port,
+			Domain:    host,
 		})
 	}
 	if err := scanner.Err(); err != nil {
@@ -306,10 +601,10 @@ func ParseNetworkLog(path string) ([]NetworkEvent, error) {
 }
 
 // dnsQueryRe matches dnsmasq query lines: "Feb 24 12:00:01 dnsmasq[42]: query[A] api.anthropic.com from 127.0.0.1"
-var dnsQueryRe = regexp.MustCompile(`^(\w+ \d+ [\d:]+) dnsmasq\[\d+\]: query\[\w+\] (\S+) from`)
+var dnsQueryRe = regexp.MustCompile(`^(\w+ +\d+ [\d:]+) dnsmasq\[\d+\]: query\[\w+\] (\S+) from`)
 
 // dnsReplyRe matches dnsmasq reply lines: "Feb 24 12:00:01 dnsmasq[42]: reply api.anthropic.com is 104.18.32.47"
-var dnsReplyRe = regexp.MustCompile(`^(\w+ \d+ [\d:]+) dnsmasq\[\d+\]: reply (\S+) is (\S+)`)
+var dnsReplyRe = regexp.MustCompile(`^(\w+ +\d+ [\d:]+) dnsmasq\[\d+\]: reply (\S+) is (\S+)`)
 
 // ParseDNSLog reads a dnsmasq query log and returns DNS events and an IP→domain mapping.
 func ParseDNSLog(path string) (events []NetworkEvent, ipToDomain map[string]string, err error) {
@@ -363,8 +658,9 @@ func ParseDNSLog(path string) (events []NetworkEvent, ipToDomain map[string]stri
 	return events, ipToDomain, nil
 }
 
-// CollectNetworkEvents reads both network.log (iptables) and dns.log (dnsmasq),
-// then annotates iptables connection events with domain names from DNS replies.
+// CollectNetworkEvents reads network.log (iptables), dns.log (dnsmasq) and
+// proxy.log (the host egress proxy), then annotates iptables connection
+// events with domain names from DNS replies.
 func CollectNetworkEvents(bootstrapDir string) ([]NetworkEvent, error) {
 	// Parse DNS log → get DNS events + IP→domain map
 	dnsEvents, ipToDomain, err := ParseDNSLog(filepath.Join(bootstrapDir, "dns.log"))
@@ -385,10 +681,17 @@ func CollectNetworkEvents(bootstrapDir string) ([]NetworkEvent, error) {
 		}
 	}
 
+	// Connections through the egress proxy are logged by host name
+	proxyEvents, err := ParseProxyLog(filepath.Join(bootstrapDir, "proxy.log"))
+	if err != nil {
+		return nil, err
+	}
+
 	// Return DNS events followed by annotated connection events
 	var all []NetworkEvent
 	all = append(all, dnsEvents...)
 	all = append(all, netEvents...)
+	all = append(all, proxyEvents...)
 	return all, nil
 }
 
@@ -410,6 +713,12 @@ func matchesIgnorePrefix(path string) bool {
 // Directory entries are redundant when child files are listed.
 // Internal paths (.git, .omc, .claude) are not user code.
 func FilterNoise(changes []Change, before, after Snapshot) []Change {
+	return FilterNoiseWithRules(changes, before, after, nil)
+}
+
+// FilterNoiseWithRules is like FilterNoise but also removes paths under
+// directories matched by rules.
+func FilterNoiseWithRules(changes []Change, before, after Snapshot, rules *IgnoreRules) []Change {
 	var filtered []Change
 	for _, c := range changes {
 		// Skip directories
@@ -420,7 +729,7 @@ func FilterNoise(changes []Change, before, after Snapshot) []Change {
 			continue
 		}
 		// Skip noise paths
-		if matchesIgnorePrefix(c.Path) {
+		if matchesIgnorePrefix(c.Path) || rules.Match(c.Path) {
 			continue
 		}
 		filtered = append(filtered, c)
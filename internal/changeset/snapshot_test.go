@@ -1,6 +1,7 @@
 package changeset
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -116,6 +117,112 @@ func TestDiff_NoChanges(t *testing.T) {
 	assert.Empty(t, changes)
 }
 
+func TestDiff_Renamed(t *testing.T) {
+	now := time.Now()
+	before := Snapshot{
+		"src/util.go":  FileEntry{Path: "src/util.go", Size: 10, ModTime: now, Hash: "aa"},
+		"notes.txt":    FileEntry{Path: "notes.txt", Size: 7, ModTime: now},
+		"a/config.yml": FileEntry{Path: "a/config.yml", Size: 3, ModTime: now, Hash: "cc"},
+		"empty":        FileEntry{Path: "empty", ModTime: now},
+		"gone.txt":     FileEntry{Path: "gone.txt", Size: 4, ModTime: now, Hash: "dd"},
+	}
+	after := Snapshot{
+		// Moved with its content unchanged
+		"lib/util.go": FileEntry{Path: "lib/util.go", Size: 10, ModTime: now.Add(time.Second), Hash: "aa"},
+		// Unhashed, matched by size and modification time
+		"docs/notes.txt": FileEntry{Path: "docs/notes.txt", Size: 7, ModTime: now},
+		// Two copies; the one with the same name wins
+		"b/copy.yml":   FileEntry{Path: "b/copy.yml", Size: 3, ModTime: now, Hash: "cc"},
+		"c/config.yml": FileEntry{Path: "c/config.yml", Size: 3, ModTime: now, Hash: "cc"},
+		"empty2":       FileEntry{Path: "empty2", ModTime: now},
+		"new.txt":      FileEntry{Path: "new.txt", Size: 4, ModTime: now, Hash: "ee"},
+	}
+
+	var got []string
+	for _, c := range Diff(before, after) {
+		if c.Type == "renamed" {
+			got = append(got, "renamed "+c.OldPath+" → "+c.Path)
+		} else {
+			got = append(got, c.Type+" "+c.Path)
+		}
+	}
+	assert.Equal(t, []string{
+		"created b/copy.yml",
+		"renamed a/config.yml → c/config.yml",
+		"renamed notes.txt → docs/notes.txt",
+		"deleted empty",
+		"created empty2",
+		"deleted gone.txt",
+		"renamed src/util.go → lib/util.go",
+		"created new.txt",
+	}, got)
+}
+
+func TestDiff_SameHash(t *testing.T) {
+	now := time.Now()
+	before := Snapshot{
+		"touched.txt":  FileEntry{Path: "touched.txt", Size: 5, ModTime: now, Hash: "aa"},
+		"edited.txt":   FileEntry{Path: "edited.txt", Size: 5, ModTime: now, Hash: "bb"},
+		"unhashed.txt": FileEntry{Path: "unhashed.txt", Size: 5, ModTime: now},
+	}
+	after := Snapshot{
+		"touched.txt":  FileEntry{Path: "touched.txt", Size: 5, ModTime: now.Add(time.Second), Hash: "aa"},
+		"edited.txt":   FileEntry{Path: "edited.txt", Size: 5, ModTime: now.Add(time.Second), Hash: "cc"},
+		"unhashed.txt": FileEntry{Path: "unhashed.txt", Size: 5, ModTime: now.Add(time.Second)},
+	}
+	changes := Diff(before, after)
+	require.Len(t, changes, 2)
+	assert.Equal(t, "edited.txt", changes[0].Path)
+	assert.Equal(t, "unhashed.txt", changes[1].Path)
+}
+
+func TestTakeWithOptions_Hash(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "small.txt"), []byte("hello"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "large.txt"), make([]byte, 64), 0644))
+
+	before, err := TakeWithOptions(root, SnapshotOptions{HashLimit: 16})
+	require.NoError(t, err)
+	assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", before["small.txt"].Hash)
+	assert.Empty(t, before["large.txt"].Hash, "over the hash limit")
+
+	// Rewriting the same content is not a change
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.WriteFile(filepath.Join(root, "small.txt"), []byte("hello"), 0644))
+	require.NoError(t, os.Chtimes(filepath.Join(root, "small.txt"), later, later))
+	require.NoError(t, os.Chtimes(filepath.Join(root, "large.txt"), later, later))
+
+	after, err := TakeWithOptions(root, SnapshotOptions{HashLimit: 16})
+	require.NoError(t, err)
+	changes := Diff(before, after)
+	require.Len(t, changes, 1)
+	assert.Equal(t, "large.txt", changes[0].Path)
+
+	plain, err := Take(root)
+	require.NoError(t, err)
+	assert.Empty(t, plain["small.txt"].Hash, "hashing is off by default")
+}
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]int64{
+		"1048576": 1 << 20,
+		"512KB":   512 << 10,
+		"1MB":     1 << 20,
+		"2m":      2 << 20,
+		"1 GB":    1 << 30,
+		"10B":     10,
+	}
+	for in, want := range tests {
+		got, err := ParseSize(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+	for _, in := range []string{"", "MB", "-1MB", "1TB", "1.5MB"} {
+		_, err := ParseSize(in)
+		assert.Error(t, err, in)
+	}
+}
+
 func TestDiff_SortedOutput(t *testing.T) {
 	before := Snapshot{}
 	after := Snapshot{
@@ -280,6 +387,55 @@ some garbage line that should be skipped
 	assert.Equal(t, 80, events[2].DstPort)
 }
 
+func TestParseNetworkLog_DoH(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "network.log")
+	content := "[  126.000] FAIZE_DOH: IN= OUT=eth0 SRC=10.0.2.15 DST=8.8.8.8 LEN=60 TOS=0x00 PROTO=TCP SPT=23456 DPT=443\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	events, err := ParseNetworkLog(path)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "DOH", events[0].Action)
+	assert.Equal(t, "8.8.8.8", events[0].DstIP)
+}
+
+func TestPrintSummary_FlagsDoH(t *testing.T) {
+	cs := &SessionChangeset{NetworkEvents: []NetworkEvent{
+		{Action: "DNS", Domain: "registry.npmjs.org"},
+		{Action: "DNS", Domain: "cloudflare-dns.com"},
+		{Action: "CONN", Proto: "TCP", DstIP: "104.16.0.1", DstPort: 443, Domain: "registry.npmjs.org"},
+		{Action: "CONN", Proto: "TCP", DstIP: "104.16.248.249", DstPort: 443, Domain: "cloudflare-dns.com"},
+		{Action: "DOH", Proto: "TCP", DstIP: "8.8.8.8", DstPort: 443},
+		{Action: "DENY", Proto: "TCP", DstIP: "203.0.113.7", DstPort: 853},
+	}}
+
+	var buf bytes.Buffer
+	PrintSummary(&buf, cs)
+	out := buf.String()
+	assert.Contains(t, out, "  DNS queries: 1 (registry.npmjs.org)\n")
+	assert.Contains(t, out, "  Connections: 1 (registry.npmjs.org:443)\n")
+	assert.Contains(t, out, "  Suspected DNS-over-HTTPS: 4 (203.0.113.7:853 blocked, 8.8.8.8:443 blocked, cloudflare-dns.com lookup, cloudflare-dns.com:443)\n")
+	assert.NotContains(t, out, "Denied:")
+}
+
+func TestPrintSummary_GuestPackages(t *testing.T) {
+	cs := &SessionChangeset{GuestPackages: []string{"go 1.22.5-r0", "ripgrep 14.1.0-r0"}}
+
+	var buf bytes.Buffer
+	PrintSummary(&buf, cs)
+	assert.Contains(t, buf.String(), "\nPackages installed: go, ripgrep\n")
+	assert.NotContains(t, buf.String(), "No changes detected")
+}
+
+func TestCommitMessage(t *testing.T) {
+	msg := CommitMessage("abc123", []MountChanges{{
+		Source:  "/home/u/app",
+		Target:  "/home/u/app",
+		Changes: []Change{{Path: "old.go", Type: "deleted", OldSize: 10}},
+	}})
+	assert.Equal(t, "faize session abc123\n\n  1 file(s) changed (+0 ~0 -1), +0 B / -10 B\n  - old.go\n", msg)
+}
+
 func TestParseNetworkLog_EmptyFile(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "network.log")
@@ -290,6 +446,31 @@ func TestParseNetworkLog_EmptyFile(t *testing.T) {
 	assert.Empty(t, events)
 }
 
+func TestParseProxyLog_ParsesEvents(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "proxy.log")
+	content := `2026-03-01T12:00:00Z FAIZE_PROXY: ALLOW CONNECT api.anthropic.com:443
+2026-03-01T12:00:01Z FAIZE_PROXY: DENY GET example.com:80
+2026-03-01T12:00:02Z FAIZE_PROXY: ALLOW CONNECT [2606:4700::6810:84e5]:443
+2026-03-01T12:00:03Z FAIZE_PROXY: DENY DNS evil.example:53
+not a proxy line
+`
+	_ = os.WriteFile(path, []byte(content), 0644)
+
+	events, err := ParseProxyLog(path)
+	require.NoError(t, err)
+	require.Len(t, events, 4)
+
+	assert.Equal(t, NetworkEvent{Timestamp: "2026-03-01T12:00:00Z", Action: "CONN", Proto: "TCP", DstPort: 443, Domain: "api.anthropic.com"}, events[0])
+	assert.Equal(t, NetworkEvent{Timestamp: "2026-03-01T12:00:01Z", Action: "DENY", Proto: "TCP", DstPort: 80, Domain: "example.com"}, events[1])
+	assert.Equal(t, "2606:4700::6810:84e5", events[2].Domain)
+	assert.Equal(t, NetworkEvent{Timestamp: "2026-03-01T12:00:03Z", Action: "DENY", Proto: "UDP", DstPort: 53, Domain: "evil.example"}, events[3])
+
+	events, err = ParseProxyLog(filepath.Join(dir, "missing.log"))
+	require.NoError(t, err)
+	assert.Empty(t, events)
+}
+
 func TestParseDNSLog_ParsesQueries(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "dns.log")
@@ -0,0 +1,246 @@
+package changeset
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// maxLargestNew is how many of the largest created files are kept in MountStats
+const maxLargestNew = 5
+
+// MountStats aggregates a mount's changes so large or unexpected additions
+// stand out in the summary.
+type MountStats struct {
+	Files    int `json:"files"` // files changed in any way
+	Created  int `json:"created"`
+	Modified int `json:"modified"`
+	Deleted  int `json:"deleted"`
+	Renamed  int `json:"renamed,omitempty"`
+	// BytesAdded is the size of created files plus the growth of modified ones
+	BytesAdded int64 `json:"bytes_added"`
+	// BytesRemoved is the size of deleted files plus the shrinkage of modified ones
+	BytesRemoved int64 `json:"bytes_removed"`
+	// LargestNew are the largest created files, biggest first
+	LargestNew []Change `json:"largest_new,omitempty"`
+	// SnapshotTime is how long the before and after snapshots took; zero if unknown
+	SnapshotTime time.Duration `json:"snapshot_time_ns,omitempty"`
+}
+
+// ComputeStats aggregates changes into MountStats
+func ComputeStats(changes []Change, snapshotTime time.Duration) MountStats {
+	stats := MountStats{Files: len(changes), SnapshotTime: snapshotTime}
+	var created []Change
+	for _, c := range changes {
+		switch c.Type {
+		case "created":
+			stats.Created++
+			stats.BytesAdded += c.NewSize
+			created = append(created, c)
+		case "modified":
+			stats.Modified++
+			if delta := c.NewSize - c.OldSize; delta > 0 {
+				stats.BytesAdded += delta
+			} else {
+				stats.BytesRemoved -= delta
+			}
+		case "deleted":
+			stats.Deleted++
+			stats.BytesRemoved += c.OldSize
+		case "renamed":
+			stats.Renamed++
+		}
+	}
+
+	sort.SliceStable(created, func(i, j int) bool {
+		return created[i].NewSize > created[j].NewSize
+	})
+	for _, c := range created {
+		if len(stats.LargestNew) == maxLargestNew || c.NewSize == 0 {
+			break
+		}
+		stats.LargestNew = append(stats.LargestNew, c)
+	}
+	return stats
+}
+
+// Statistics returns the mount's recorded stats, computing them from its
+// changes for changesets saved before stats were recorded
+func (mc MountChanges) Statistics() MountStats {
+	if mc.Stats != nil {
+		return *mc.Stats
+	}
+	return ComputeStats(mc.Changes, 0)
+}
+
+// summaryLine formats the one-line aggregate shown under a mount, e.g.
+// "12 file(s) changed (+3 ~8 -1), +1.2 MB / -40 B"
+func (s MountStats) summaryLine() string {
+	counts := fmt.Sprintf("+%d ~%d -%d", s.Created, s.Modified, s.Deleted)
+	if s.Renamed > 0 {
+		counts += fmt.Sprintf(", %d renamed", s.Renamed)
+	}
+	line := fmt.Sprintf("%d file(s) changed (%s), +%s / -%s",
+		s.Files, counts, FormatSize(s.BytesAdded), FormatSize(s.BytesRemoved))
+	if s.SnapshotTime > 0 {
+		line += fmt.Sprintf(", snapshot %s", s.SnapshotTime.Round(time.Millisecond))
+	}
+	return line
+}
+
+// largestLine formats the largest new files, e.g. "dist/app.js (2.1 MB), ..."
+func (s MountStats) largestLine(n int) string {
+	var parts []string
+	for i, c := range s.LargestNew {
+		if i == n {
+			break
+		}
+		parts = append(parts, fmt.Sprintf("%s (%s)", c.Path, FormatSize(c.NewSize)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// PrintStats prints a per-mount statistics table followed by each mount's
+// largest new files (faize diff --stats).
+func PrintStats(w io.Writer, cs *SessionChangeset) {
+	if cs == nil || len(cs.MountChanges) == 0 {
+		_, _ = fmt.Fprintln(w, "No changes detected.")
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "MOUNT\tFILES\tCREATED\tMODIFIED\tDELETED\tADDED\tREMOVED\tSNAPSHOT")
+	for _, mc := range cs.MountChanges {
+		s := mc.Statistics()
+		snapshot := "-"
+		if s.SnapshotTime > 0 {
+			snapshot = s.SnapshotTime.Round(time.Millisecond).String()
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%s\t%s\t%s\n",
+			mc.Source, s.Files, s.Created, s.Modified, s.Deleted,
+			FormatSize(s.BytesAdded), FormatSize(s.BytesRemoved), snapshot)
+	}
+	_ = tw.Flush()
+
+	for _, mc := range cs.MountChanges {
+		s := mc.Statistics()
+		if len(s.LargestNew) == 0 {
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "\nLargest new files in %s:\n", mc.Source)
+		for _, c := range s.LargestNew {
+			_, _ = fmt.Fprintf(w, "  %-50s %s\n", c.Path, FormatSize(c.NewSize))
+		}
+	}
+}
+
+// bytesPerLine is the average line length used to estimate insertions and
+// deletions from file sizes
+const bytesPerLine = 40
+
+// statBarWidth is the width of the widest +/- bar in PrintStat
+const statBarWidth = 30
+
+// dirStat aggregates the changes below one top-level directory
+type dirStat struct {
+	name       string
+	files      int
+	insertions int64
+	deletions  int64
+}
+
+// estimateLines estimates the lines added and removed by a change from its
+// sizes; a modification counts only its net growth or shrinkage
+func estimateLines(c Change) (insertions, deletions int64) {
+	lines := func(bytes int64) int64 { return (bytes + bytesPerLine - 1) / bytesPerLine }
+	switch c.Type {
+	case "created":
+		return lines(c.NewSize), 0
+	case "deleted":
+		return 0, lines(c.OldSize)
+	}
+	if delta := c.NewSize - c.OldSize; delta < 0 {
+		return 0, lines(-delta)
+	}
+	return lines(c.NewSize - c.OldSize), 0
+}
+
+// PrintStat prints a compact, git --stat style view of each mount: files
+// changed per top-level directory with estimated insertions and deletions
+// (faize diff --stat).
+func PrintStat(w io.Writer, cs *SessionChangeset) {
+	if cs == nil || len(cs.MountChanges) == 0 {
+		_, _ = fmt.Fprintln(w, "No changes detected.")
+		return
+	}
+
+	for i, mc := range cs.MountChanges {
+		if i > 0 {
+			_, _ = fmt.Fprintln(w)
+		}
+		_, _ = fmt.Fprintf(w, "%s (%s → %s):\n", mountLabel(mc.Target), mc.Source, mc.Target)
+		if len(mc.Summary) > 0 {
+			for _, line := range mc.Summary {
+				_, _ = fmt.Fprintf(w, "  %s\n", line)
+			}
+			continue
+		}
+
+		dirs := make(map[string]*dirStat)
+		var total dirStat
+		for _, c := range mc.Changes {
+			if c.Mode.IsDir() {
+				continue
+			}
+			name := "./"
+			if top, _, found := strings.Cut(filepath.ToSlash(c.Path), "/"); found {
+				name = top + "/"
+			}
+			d := dirs[name]
+			if d == nil {
+				d = &dirStat{name: name}
+				dirs[name] = d
+			}
+			ins, del := estimateLines(c)
+			d.files++
+			d.insertions += ins
+			d.deletions += del
+			total.files++
+			total.insertions += ins
+			total.deletions += del
+		}
+
+		sorted := make([]*dirStat, 0, len(dirs))
+		width, largest := 0, int64(0)
+		for _, d := range dirs {
+			sorted = append(sorted, d)
+			width = max(width, len(d.name))
+			largest = max(largest, d.insertions+d.deletions)
+		}
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i].name < sorted[j].name })
+		for _, d := range sorted {
+			plus, minus := d.insertions, d.deletions
+			if largest > statBarWidth {
+				// Scale the bar, keeping at least one mark for any change
+				plus = scaleBar(plus, largest)
+				minus = scaleBar(minus, largest)
+			}
+			_, _ = fmt.Fprintf(w, " %-*s | %4d file(s) %s%s\n", width, d.name, d.files,
+				strings.Repeat("+", int(plus)), strings.Repeat("-", int(minus)))
+		}
+		_, _ = fmt.Fprintf(w, " %d file(s) changed, ~%d insertions(+), ~%d deletions(-)\n",
+			total.files, total.insertions, total.deletions)
+	}
+}
+
+// scaleBar scales n of largest to statBarWidth, rounding nonzero counts up
+func scaleBar(n, largest int64) int64 {
+	if n == 0 {
+		return 0
+	}
+	return max(1, n*statBarWidth/largest)
+}
@@ -0,0 +1,111 @@
+package changeset
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComputeStats(t *testing.T) {
+	changes := []Change{
+		{Path: "a.txt", Type: "created", NewSize: 100},
+		{Path: "vendor/big.bin", Type: "created", NewSize: 5 << 20},
+		{Path: "empty", Type: "created"},
+		{Path: "grew.go", Type: "modified", OldSize: 100, NewSize: 150},
+		{Path: "shrank.go", Type: "modified", OldSize: 300, NewSize: 200},
+		{Path: "gone.txt", Type: "deleted", OldSize: 40},
+	}
+
+	stats := ComputeStats(changes, 120*time.Millisecond)
+	assert.Equal(t, 6, stats.Files)
+	assert.Equal(t, 3, stats.Created)
+	assert.Equal(t, 2, stats.Modified)
+	assert.Equal(t, 1, stats.Deleted)
+	assert.Equal(t, int64(100+5<<20+50), stats.BytesAdded)
+	assert.Equal(t, int64(100+40), stats.BytesRemoved)
+	assert.Equal(t, 120*time.Millisecond, stats.SnapshotTime)
+
+	// Largest first; empty files are not listed
+	assert.Equal(t, []string{"vendor/big.bin", "a.txt"}, []string{stats.LargestNew[0].Path, stats.LargestNew[1].Path})
+	assert.Len(t, stats.LargestNew, 2)
+}
+
+func TestComputeStats_LimitsLargestNew(t *testing.T) {
+	var changes []Change
+	for i := 1; i <= 8; i++ {
+		changes = append(changes, Change{Path: string(rune('a' + i)), Type: "created", NewSize: int64(i)})
+	}
+	stats := ComputeStats(changes, 0)
+	assert.Len(t, stats.LargestNew, maxLargestNew)
+	assert.Equal(t, int64(8), stats.LargestNew[0].NewSize)
+}
+
+func TestMountChanges_Statistics(t *testing.T) {
+	recorded := MountStats{Files: 1, SnapshotTime: time.Second}
+	assert.Equal(t, recorded, MountChanges{Stats: &recorded}.Statistics())
+
+	// Older changesets have no stats recorded
+	legacy := MountChanges{Changes: []Change{{Path: "a", Type: "created", NewSize: 10}}}
+	assert.Equal(t, int64(10), legacy.Statistics().BytesAdded)
+}
+
+func TestPrintSummary_ShowsStats(t *testing.T) {
+	stats := ComputeStats([]Change{{Path: "node_modules.tar", Type: "created", NewSize: 3 << 20}}, 0)
+	cs := &SessionChangeset{MountChanges: []MountChanges{{
+		Source:  "/home/u/app",
+		Target:  "/home/u/app",
+		Changes: []Change{{Path: "node_modules.tar", Type: "created", NewSize: 3 << 20}},
+		Stats:   &stats,
+	}}}
+
+	var buf bytes.Buffer
+	PrintSummary(&buf, cs)
+	assert.Contains(t, buf.String(), "  1 file(s) changed (+1 ~0 -0), +3.0 MB / -0 B\n")
+	assert.Contains(t, buf.String(), "  largest new: node_modules.tar (3.0 MB)\n")
+}
+
+func TestPrintStats(t *testing.T) {
+	stats := MountStats{Files: 2, Created: 1, Modified: 1, BytesAdded: 2048, SnapshotTime: 1500 * time.Microsecond,
+		LargestNew: []Change{{Path: "dist/app.js", Type: "created", NewSize: 2048}}}
+	cs := &SessionChangeset{MountChanges: []MountChanges{{Source: "/home/u/app", Stats: &stats}}}
+
+	var buf bytes.Buffer
+	PrintStats(&buf, cs)
+	out := buf.String()
+	assert.Contains(t, out, "MOUNT")
+	assert.Contains(t, out, "/home/u/app  2      1        1         0        2.0 KB  0 B      2ms")
+	assert.Contains(t, out, "Largest new files in /home/u/app:\n  dist/app.js")
+}
+
+func TestPrintStat(t *testing.T) {
+	cs := &SessionChangeset{MountChanges: []MountChanges{{
+		Source: "/home/u/app",
+		Target: "/workspace",
+		Changes: []Change{
+			{Path: "README.md", Type: "modified", OldSize: 400, NewSize: 480},
+			{Path: "src", Type: "created", Mode: os.ModeDir | 0755},
+			{Path: "src/a.go", Type: "created", NewSize: 4000},
+			{Path: "src/b.go", Type: "deleted", OldSize: 400},
+			{Path: "src/c.go", Type: "modified", OldSize: 800, NewSize: 400},
+		},
+	}}}
+
+	var buf bytes.Buffer
+	PrintStat(&buf, cs)
+	assert.Equal(t, "Project (/home/u/app → /workspace):\n"+
+		" ./   |    1 file(s) +\n"+
+		" src/ |    3 file(s) +++++++++++++++++++++++++-----\n"+
+		" 4 file(s) changed, ~102 insertions(+), ~20 deletions(-)\n", buf.String())
+}
+
+func TestEstimateLines(t *testing.T) {
+	ins, del := estimateLines(Change{Type: "created", NewSize: 81})
+	assert.Equal(t, []int64{3, 0}, []int64{ins, del})
+	ins, del = estimateLines(Change{Type: "modified", OldSize: 100, NewSize: 60})
+	assert.Equal(t, []int64{0, 1}, []int64{ins, del})
+	ins, del = estimateLines(Change{Type: "modified", OldSize: 100, NewSize: 100})
+	assert.Equal(t, []int64{0, 0}, []int64{ins, del})
+}
@@ -0,0 +1,290 @@
+package changeset
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// InventoryItem is something a Summarizer found in a mount, such as an installed tool.
+type InventoryItem struct {
+	Name    string
+	Kind    string // e.g. "npm", "pip", "binary"
+	Version string // empty when unknown
+	Path    string // path relative to the mount root that holds the item
+	Stamp   string // changes when the item changes in place; never displayed
+}
+
+// label formats the item for summaries, e.g. "typescript 5.4.2 (npm)".
+func (i InventoryItem) label() string {
+	return i.labelWithVersion(i.Version)
+}
+
+// labelWithVersion is label with the version replaced, e.g. "typescript 5.3.0 → 5.4.2 (npm)"
+func (i InventoryItem) labelWithVersion(version string) string {
+	s := i.Name
+	if version != "" {
+		s += " " + version
+	}
+	if i.Kind != "" {
+		s += " (" + i.Kind + ")"
+	}
+	return s
+}
+
+// Inventory maps unique item keys to what a Summarizer found in a mount.
+type Inventory map[string]InventoryItem
+
+// Summarizer describes changes to a mount at a higher level than file lists.
+// An inventory of the mount is taken before and after the session; Summarize
+// compares the two and returns the lines shown in place of the raw changes.
+type Summarizer interface {
+	Inventory(root string) Inventory
+	Summarize(root string, before, after Inventory, changes []Change) []string
+}
+
+// Summarizers are the built-in per-mount summarizers, keyed by guest mount target.
+var Summarizers = map[string]Summarizer{
+	"/opt/toolchain":        ToolchainSummarizer{},
+	"/mnt/host-credentials": CredentialsSummarizer{},
+}
+
+// SummarizerFor returns the summarizer for a guest mount target, or nil.
+func SummarizerFor(target string) Summarizer {
+	return Summarizers[target]
+}
+
+// ToolchainSummarizer groups toolchain changes by installed tool and version:
+// global npm packages, Python packages, standalone binaries in bin/, and
+// versioned tool directories such as go1.22.1.
+type ToolchainSummarizer struct{}
+
+// versionedDirRe matches unpacked tool directories: go1.22.1, node-v20.11.0-linux-arm64
+var versionedDirRe = regexp.MustCompile(`^([A-Za-z][A-Za-z_]*?)[-_]?v?(\d+(?:\.\d+)+)`)
+
+// Inventory lists the tools installed under a toolchain root.
+func (ToolchainSummarizer) Inventory(root string) Inventory {
+	inv := Inventory{}
+
+	// Global npm packages: lib/node_modules/<pkg> and lib/node_modules/@scope/<pkg>
+	modules := filepath.Join("lib", "node_modules")
+	entries, _ := os.ReadDir(filepath.Join(root, modules))
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		if !strings.HasPrefix(name, "@") {
+			addNpmPackage(inv, root, filepath.Join(modules, name))
+			continue
+		}
+		scoped, _ := os.ReadDir(filepath.Join(root, modules, name))
+		for _, s := range scoped {
+			addNpmPackage(inv, root, filepath.Join(modules, name, s.Name()))
+		}
+	}
+
+	// Python packages: lib/python3.X/site-packages/<name>-<version>.dist-info
+	distInfos, _ := filepath.Glob(filepath.Join(root, "lib", "python*", "site-packages", "*.dist-info"))
+	for _, d := range distInfos {
+		name, version, ok := strings.Cut(strings.TrimSuffix(filepath.Base(d), ".dist-info"), "-")
+		if !ok {
+			continue
+		}
+		rel, _ := filepath.Rel(root, d)
+		inv["pip:"+name] = InventoryItem{Name: name, Kind: "pip", Version: version, Path: rel}
+	}
+
+	// Standalone binaries; symlinks belong to packages (npm bin links)
+	bins, _ := os.ReadDir(filepath.Join(root, "bin"))
+	for _, e := range bins {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		inv["bin:"+e.Name()] = InventoryItem{
+			Name:  e.Name(),
+			Kind:  "binary",
+			Path:  filepath.Join("bin", e.Name()),
+			Stamp: fmt.Sprintf("%d:%d", info.Size(), info.ModTime().UnixNano()),
+		}
+	}
+
+	// Versioned tool directories at the top level
+	top, _ := os.ReadDir(root)
+	for _, e := range top {
+		if !e.IsDir() {
+			continue
+		}
+		if m := versionedDirRe.FindStringSubmatch(e.Name()); m != nil {
+			inv["dir:"+e.Name()] = InventoryItem{Name: m[1], Version: m[2], Path: e.Name()}
+		}
+	}
+
+	return inv
+}
+
+// addNpmPackage records the npm package at dir (relative to root) using its package.json
+func addNpmPackage(inv Inventory, root, dir string) {
+	data, err := os.ReadFile(filepath.Join(root, dir, "package.json"))
+	if err != nil {
+		return
+	}
+	var pkg struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil || pkg.Name == "" {
+		return
+	}
+	inv["npm:"+pkg.Name] = InventoryItem{Name: pkg.Name, Kind: "npm", Version: pkg.Version, Path: dir}
+}
+
+// Summarize reports installed, updated, and removed tools, plus a count of
+// changed files that don't belong to any tool.
+func (ToolchainSummarizer) Summarize(root string, before, after Inventory, changes []Change) []string {
+	lines := diffInventory(before, after)
+
+	other := 0
+	for _, c := range changes {
+		if isPackageManagerPath(c.Path) || inventoryOwns(before, c.Path) || inventoryOwns(after, c.Path) {
+			continue
+		}
+		other++
+	}
+	if other > 0 {
+		lines = append(lines, fmt.Sprintf("%d other file(s) changed", other))
+	}
+	return lines
+}
+
+// isPackageManagerPath reports whether a toolchain path is managed by npm or
+// pip (package trees and bin links), and so is covered by the inventory
+func isPackageManagerPath(path string) bool {
+	return strings.HasPrefix(path, "bin/") ||
+		strings.HasPrefix(path, "lib/node_modules/") ||
+		strings.Contains(path, "/site-packages/")
+}
+
+// diffInventory compares two inventories and returns sorted summary lines
+func diffInventory(before, after Inventory) []string {
+	var lines []string
+	for key, a := range after {
+		b, existed := before[key]
+		switch {
+		case !existed:
+			lines = append(lines, "+ installed "+a.label())
+		case b.Version != a.Version:
+			lines = append(lines, "~ updated "+a.labelWithVersion(b.Version+" → "+a.Version))
+		case b.Stamp != a.Stamp:
+			lines = append(lines, "~ updated "+a.label())
+		}
+	}
+	for key, b := range before {
+		if _, ok := after[key]; !ok {
+			lines = append(lines, "- removed "+b.label())
+		}
+	}
+	// Sort by item label, ignoring the change marker and verb
+	sort.Slice(lines, func(i, j int) bool {
+		return summaryItem(lines[i]) < summaryItem(lines[j])
+	})
+	return lines
+}
+
+// summaryItem strips the marker and verb from a diffInventory line
+func summaryItem(line string) string {
+	if _, rest, ok := strings.Cut(line, " "); ok {
+		if _, item, ok := strings.Cut(rest, " "); ok {
+			return item
+		}
+	}
+	return line
+}
+
+// inventoryOwns reports whether path belongs to an item in inv
+func inventoryOwns(inv Inventory, path string) bool {
+	for _, item := range inv {
+		if path == item.Path || strings.HasPrefix(path, item.Path+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// credentialFiles are the persisted Claude credential files and how they are described
+var credentialFiles = []struct {
+	name  string
+	label string
+}{
+	{".credentials.json", "credentials"},
+	{"claude.json", "account settings"},
+}
+
+// CredentialsSummarizer reports that persisted credentials changed without
+// revealing their contents. Files are compared by hash, and only the token
+// expiry is read from the credentials file.
+type CredentialsSummarizer struct{}
+
+// Inventory fingerprints the credential files under root.
+func (CredentialsSummarizer) Inventory(root string) Inventory {
+	inv := Inventory{}
+	for _, f := range credentialFiles {
+		data, err := os.ReadFile(filepath.Join(root, f.name))
+		if err != nil {
+			continue
+		}
+		sum := sha256.Sum256(data)
+		inv[f.name] = InventoryItem{Name: f.label, Path: f.name, Stamp: hex.EncodeToString(sum[:])}
+	}
+	return inv
+}
+
+// Summarize reports which credential files were created, updated, or removed.
+func (CredentialsSummarizer) Summarize(root string, before, after Inventory, changes []Change) []string {
+	var lines []string
+	for _, f := range credentialFiles {
+		b, hadBefore := before[f.name]
+		a, hasAfter := after[f.name]
+		switch {
+		case hasAfter && (!hadBefore || a.Stamp != b.Stamp):
+			line := f.label + " updated"
+			if f.name == ".credentials.json" {
+				if expiry, ok := credentialsExpiry(filepath.Join(root, f.name)); ok {
+					line += fmt.Sprintf(" (expiry %s)", expiry.UTC().Format("2006-01-02 15:04 UTC"))
+				}
+			}
+			lines = append(lines, line)
+		case hadBefore && !hasAfter:
+			lines = append(lines, f.label+" removed")
+		}
+	}
+	return lines
+}
+
+// credentialsExpiry reads only the OAuth token expiry from a credentials file
+func credentialsExpiry(path string) (time.Time, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	var creds struct {
+		OAuth struct {
+			ExpiresAt int64 `json:"expiresAt"` // milliseconds since the epoch
+		} `json:"claudeAiOauth"`
+	}
+	if err := json.Unmarshal(data, &creds); err != nil || creds.OAuth.ExpiresAt <= 0 {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(creds.OAuth.ExpiresAt), true
+}
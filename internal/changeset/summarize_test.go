@@ -0,0 +1,133 @@
+package changeset
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+func TestSummarizerFor(t *testing.T) {
+	assert.IsType(t, ToolchainSummarizer{}, SummarizerFor("/opt/toolchain"))
+	assert.IsType(t, CredentialsSummarizer{}, SummarizerFor("/mnt/host-credentials"))
+	assert.Nil(t, SummarizerFor("/workspace"))
+}
+
+func TestToolchainSummarizer_Inventory(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "lib/node_modules/typescript/package.json"), `{"name":"typescript","version":"5.4.2"}`)
+	writeFile(t, filepath.Join(root, "lib/node_modules/@anthropic-ai/sdk/package.json"), `{"name":"@anthropic-ai/sdk","version":"0.20.0"}`)
+	writeFile(t, filepath.Join(root, "lib/python3.12/site-packages/requests-2.31.0.dist-info/METADATA"), "")
+	writeFile(t, filepath.Join(root, "bin/rg"), "binary")
+	require.NoError(t, os.Symlink("../lib/node_modules/typescript/bin/tsc", filepath.Join(root, "bin/tsc")))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "go1.22.1"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "node-v20.11.0-linux-arm64"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "cache"), 0755))
+
+	inv := ToolchainSummarizer{}.Inventory(root)
+
+	assert.Equal(t, "5.4.2", inv["npm:typescript"].Version)
+	assert.Equal(t, "0.20.0", inv["npm:@anthropic-ai/sdk"].Version)
+	assert.Equal(t, "2.31.0", inv["pip:requests"].Version)
+	assert.Equal(t, "binary", inv["bin:rg"].Kind)
+	assert.NotContains(t, inv, "bin:tsc", "symlinks belong to packages")
+	assert.Equal(t, InventoryItem{Name: "go", Version: "1.22.1", Path: "go1.22.1"}, inv["dir:go1.22.1"])
+	assert.Equal(t, "node", inv["dir:node-v20.11.0-linux-arm64"].Name)
+	assert.Len(t, inv, 6)
+}
+
+func TestToolchainSummarizer_Summarize(t *testing.T) {
+	before := Inventory{
+		"npm:typescript": {Name: "typescript", Kind: "npm", Version: "5.3.0", Path: "lib/node_modules/typescript"},
+		"npm:prettier":   {Name: "prettier", Kind: "npm", Version: "3.0.0", Path: "lib/node_modules/prettier"},
+		"bin:rg":         {Name: "rg", Kind: "binary", Path: "bin/rg", Stamp: "1"},
+	}
+	after := Inventory{
+		"npm:typescript": {Name: "typescript", Kind: "npm", Version: "5.4.2", Path: "lib/node_modules/typescript"},
+		"bin:rg":         {Name: "rg", Kind: "binary", Path: "bin/rg", Stamp: "2"},
+		"dir:go1.22.1":   {Name: "go", Version: "1.22.1", Path: "go1.22.1"},
+	}
+	changes := []Change{
+		{Path: "bin/rg", Type: "modified"},
+		{Path: "bin/tsc", Type: "modified"},
+		{Path: "go1.22.1/VERSION", Type: "created"},
+		{Path: "env.sh", Type: "modified"},
+		{Path: "notes.txt", Type: "created"},
+	}
+
+	lines := ToolchainSummarizer{}.Summarize(t.TempDir(), before, after, changes)
+	assert.Equal(t, []string{
+		"+ installed go 1.22.1",
+		"- removed prettier 3.0.0 (npm)",
+		"~ updated rg (binary)",
+		"~ updated typescript 5.3.0 → 5.4.2 (npm)",
+		"2 other file(s) changed",
+	}, lines)
+}
+
+func TestToolchainSummarizer_NoChanges(t *testing.T) {
+	inv := Inventory{"npm:typescript": {Name: "typescript", Kind: "npm", Version: "5.4.2"}}
+	assert.Empty(t, ToolchainSummarizer{}.Summarize(t.TempDir(), inv, inv, nil))
+}
+
+func TestCredentialsSummarizer(t *testing.T) {
+	root := t.TempDir()
+	credsPath := filepath.Join(root, ".credentials.json")
+	writeFile(t, credsPath, `{"claudeAiOauth":{"accessToken":"secret-old","expiresAt":1}}`)
+	writeFile(t, filepath.Join(root, "claude.json"), `{"theme":"dark"}`)
+	s := CredentialsSummarizer{}
+	before := s.Inventory(root)
+
+	expiry := time.Date(2026, 10, 17, 14, 3, 0, 0, time.UTC)
+	writeFile(t, credsPath, fmt.Sprintf(`{"claudeAiOauth":{"accessToken":"secret-new","refreshToken":"secret-refresh","expiresAt":%d}}`, expiry.UnixMilli()))
+	after := s.Inventory(root)
+
+	lines := s.Summarize(root, before, after, []Change{{Path: ".credentials.json", Type: "modified"}})
+	assert.Equal(t, []string{"credentials updated (expiry 2026-10-17 14:03 UTC)"}, lines)
+
+	for _, item := range after {
+		assert.NotContains(t, item.Stamp, "secret")
+	}
+}
+
+func TestCredentialsSummarizer_CreatedAndRemoved(t *testing.T) {
+	root := t.TempDir()
+	s := CredentialsSummarizer{}
+	writeFile(t, filepath.Join(root, "claude.json"), `{}`)
+	before := s.Inventory(root)
+
+	require.NoError(t, os.Remove(filepath.Join(root, "claude.json")))
+	writeFile(t, filepath.Join(root, ".credentials.json"), `not json`)
+	after := s.Inventory(root)
+
+	assert.Equal(t, []string{"credentials updated", "account settings removed"}, s.Summarize(root, before, after, nil))
+}
+
+func TestPrintSummary_UsesMountSummary(t *testing.T) {
+	cs := &SessionChangeset{
+		MountChanges: []MountChanges{{
+			Source:  "/home/u/.faize/credentials",
+			Target:  "/mnt/host-credentials",
+			Changes: []Change{{Path: ".credentials.json", Type: "modified", OldSize: 100, NewSize: 120}},
+			Summary: []string{"credentials updated"},
+		}},
+	}
+
+	var buf bytes.Buffer
+	PrintSummary(&buf, cs)
+	out := buf.String()
+	assert.Contains(t, out, "Credentials (/home/u/.faize/credentials → /mnt/host-credentials):")
+	assert.Contains(t, out, "  credentials updated\n")
+	assert.NotContains(t, out, ".credentials.json")
+}
@@ -0,0 +1,268 @@
+package changeset
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// walker reads the directories of a snapshot with a pool of workers
+type walker struct {
+	root string
+	opts SnapshotOptions
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []string // directories waiting to be read
+	pending int      // directories queued or being read
+	snap    Snapshot
+	err     error // first error; stops the walk
+}
+
+// walkInto records start, a path under root, and what it contains in snap
+func walkInto(snap Snapshot, root, start string, opts SnapshotOptions) error {
+	w := &walker{root: root, opts: opts, snap: snap}
+	w.cond = sync.NewCond(&w.mu)
+
+	info, err := os.Lstat(start)
+	if err != nil {
+		return err
+	}
+	if start == root {
+		// The root itself isn't recorded, and is always walked
+		if !info.IsDir() {
+			return nil
+		}
+	} else {
+		entry, descend, ok, err := w.visit(start, fs.FileInfoToDirEntry(info))
+		if err != nil || !ok {
+			return err
+		}
+		snap[entry.Path] = entry
+		if !descend {
+			return nil
+		}
+	}
+	w.queue = []string{start}
+	w.pending = 1
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = min(max(runtime.NumCPU(), 4), 16)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.work()
+		}()
+	}
+	wg.Wait()
+	return w.err
+}
+
+// work reads queued directories until the walk is done or fails
+func (w *walker) work() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for {
+		for len(w.queue) == 0 && w.pending > 0 && w.err == nil {
+			w.cond.Wait()
+		}
+		if w.pending == 0 || w.err != nil {
+			w.cond.Broadcast()
+			return
+		}
+		// Last in, first out keeps the queue as short as a depth-first walk
+		dir := w.queue[len(w.queue)-1]
+		w.queue = w.queue[:len(w.queue)-1]
+
+		w.mu.Unlock()
+		count, entries, subdirs, err := w.readDir(dir)
+		w.mu.Lock()
+
+		if err != nil && w.err == nil {
+			w.err = err
+		}
+		if rel, _ := filepath.Rel(w.root, dir); rel != "." {
+			entry := w.snap[rel]
+			entry.ChildCount = count
+			w.snap[rel] = entry
+		}
+		for _, e := range entries {
+			w.snap[e.Path] = e
+		}
+		w.queue = append(w.queue, subdirs...)
+		w.pending += len(subdirs) - 1
+		w.cond.Broadcast()
+	}
+}
+
+// readDir reads a directory and returns its child count, the entries to
+// record, and the subdirectories to read. The contents of node_modules and
+// directories with many children, other than the root, are not recorded.
+func (w *walker) readDir(dir string) (int, []FileEntry, []string, error) {
+	children, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	if dir != w.root && (filepath.Base(dir) == "node_modules" || len(children) > summarizeChildren) {
+		return len(children), nil, nil, nil
+	}
+
+	entries := make([]FileEntry, 0, len(children))
+	var subdirs []string
+	for _, d := range children {
+		path := filepath.Join(dir, d.Name())
+		entry, descend, ok, err := w.visit(path, d)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+		if !ok {
+			continue
+		}
+		entries = append(entries, entry)
+		if descend {
+			subdirs = append(subdirs, path)
+		}
+	}
+	return len(children), entries, subdirs, nil
+}
+
+// visit returns the entry for a path found by the walk. descend reports
+// whether a directory is read; ok is false for directories the rules skip.
+// A directory's child count is filled in when it is read.
+func (w *walker) visit(path string, d fs.DirEntry) (entry FileEntry, descend, ok bool, err error) {
+	rel, err := filepath.Rel(w.root, path)
+	if err != nil {
+		return entry, false, false, err
+	}
+	info, err := d.Info()
+	if err != nil {
+		return entry, false, false, err
+	}
+	entry = FileEntry{
+		Path:    rel,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Mode:    info.Mode(),
+		IsDir:   d.IsDir(),
+	}
+
+	if d.IsDir() {
+		switch {
+		case d.Name() == ".git":
+			// Record the directory, not its contents
+			return entry, false, true, nil
+		case w.opts.Rules.matchEntry(strings.Split(filepath.ToSlash(rel), "/"), true):
+			// Ecosystem build/dependency output (target/, .venv, ...) and
+			// directories the project's .gitignore files exclude
+			return entry, false, false, nil
+		}
+		return entry, true, true, nil
+	}
+	if w.opts.Rules.matchEntry(strings.Split(filepath.ToSlash(rel), "/"), false) {
+		return entry, false, false, nil
+	}
+
+	if w.opts.HashLimit > 0 && d.Type().IsRegular() && entry.Size <= w.opts.HashLimit {
+		// Unreadable files fall back to size and modification time
+		if w.opts.Stash != "" {
+			entry.Hash, _ = stashFile(path, w.opts.Stash)
+		} else {
+			entry.Hash, _ = hashFile(path)
+		}
+	}
+	return entry, false, true, nil
+}
+
+// errChangeFound ends ChangedSince's walk at the first change
+var errChangeFound = errors.New("change found")
+
+// ChangedSince reports whether a file or directory under root that rules
+// don't exclude was modified after t. Deletions and renames count through
+// the modification time of their directory. The walk stops at the first
+// change, so an active tree is cheap to check.
+func ChangedSince(root string, t time.Time, rules *IgnoreRules) (bool, error) {
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // unreadable entries can't tell
+		}
+		if path != root {
+			rel, _ := filepath.Rel(root, path)
+			if rules.Ignored(rel, d.IsDir()) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+		if info, err := d.Info(); err == nil && info.ModTime().After(t) {
+			return errChangeFound
+		}
+		return nil
+	})
+	if errors.Is(err, errChangeFound) {
+		return true, nil
+	}
+	return false, err
+}
+
+// Sizes is the size of every regular file under a directory, by path
+// relative to it
+type Sizes map[string]int64
+
+// TakeSizes returns the sizes of all regular files under root, including
+// those under .git and the directories change tracking ignores
+func TakeSizes(root string) (Sizes, error) {
+	sizes := make(Sizes)
+	err := walkSizes(root, func(rel string, size int64) { sizes[rel] = size })
+	if err != nil {
+		return nil, err
+	}
+	return sizes, nil
+}
+
+// Growth returns how many bytes were written under root since before was
+// taken of it, going by file sizes: all of each new file and what each
+// existing file grew by. Files that shrank or were deleted give nothing back.
+// Every file counts, .git and ignored directories included: ignore rules
+// only shape the changeset, not how much a session may write.
+func Growth(root string, before Sizes) (int64, error) {
+	var total int64
+	err := walkSizes(root, func(rel string, size int64) {
+		if grown := size - before[rel]; grown > 0 {
+			total += grown
+		}
+	})
+	return total, err
+}
+
+// walkSizes calls fn with the path relative to root and size of every
+// regular file under root. Unreadable entries are skipped.
+func walkSizes(root string, fn func(rel string, size int64)) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return nil // unreadable entries can't tell
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, path)
+		fn(rel, info.Size())
+		return nil
+	})
+}
@@ -0,0 +1,179 @@
+package changeset
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// makeTree creates dirs directories of files files each under root, nested
+// a few levels deep, plus a node_modules and a .git directory
+func makeTree(tb testing.TB, root string, dirs, files int) {
+	tb.Helper()
+	for d := 0; d < dirs; d++ {
+		dir := filepath.Join(root, fmt.Sprintf("pkg%d", d%10), fmt.Sprintf("sub%d", d))
+		require.NoError(tb, os.MkdirAll(dir, 0755))
+		for f := 0; f < files; f++ {
+			require.NoError(tb, os.WriteFile(filepath.Join(dir, fmt.Sprintf("file%d.go", f)), []byte("package x"), 0644))
+		}
+	}
+	for _, path := range []string{"node_modules/left-pad/index.js", ".git/HEAD"} {
+		full := filepath.Join(root, path)
+		require.NoError(tb, os.MkdirAll(filepath.Dir(full), 0755))
+		require.NoError(tb, os.WriteFile(full, []byte("x"), 0644))
+	}
+}
+
+func TestTake_WorkersAgree(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, 40, 20)
+	// A directory large enough to be summarized
+	big := filepath.Join(root, "big")
+	require.NoError(t, os.MkdirAll(big, 0755))
+	for i := 0; i <= summarizeChildren; i++ {
+		require.NoError(t, os.WriteFile(filepath.Join(big, fmt.Sprintf("%d.txt", i)), nil, 0644))
+	}
+	rules := &IgnoreRules{dirs: map[string]bool{"sub3": true}}
+
+	sequential, err := TakeWithOptions(root, SnapshotOptions{Rules: rules, Workers: 1})
+	require.NoError(t, err)
+	concurrent, err := TakeWithOptions(root, SnapshotOptions{Rules: rules, Workers: 8})
+	require.NoError(t, err)
+	assert.Equal(t, sequential, concurrent)
+
+	assert.Equal(t, summarizeChildren+1, concurrent["big"].ChildCount)
+	assert.NotContains(t, concurrent, filepath.Join("big", "0.txt"))
+	assert.Equal(t, 1, concurrent["node_modules"].ChildCount)
+	assert.NotContains(t, concurrent, filepath.Join("node_modules", "left-pad"))
+	assert.Contains(t, concurrent, ".git")
+	assert.NotContains(t, concurrent, filepath.Join(".git", "HEAD"))
+	assert.NotContains(t, concurrent, filepath.Join("pkg3", "sub3"))
+	assert.Equal(t, 20, concurrent[filepath.Join("pkg0", "sub0")].ChildCount)
+	// 40 directories of 20 files, 10 parents, big, node_modules, .git
+	assert.Len(t, concurrent, 39*21+10+3)
+}
+
+func TestTake_Error(t *testing.T) {
+	_, err := Take(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
+
+func TestChangedSince(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, 4, 3)
+	rules, err := NewIgnoreRules(root, []string{"none"}, []string{"out/"})
+	require.NoError(t, err)
+	old := time.Now().Add(-time.Hour)
+	require.NoError(t, filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		require.NoError(t, err)
+		return os.Chtimes(path, old, old)
+	}))
+	since := old.Add(time.Minute)
+
+	changed, err := ChangedSince(root, since, rules)
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	// Changes in excluded directories don't count
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "out"), 0755))
+	require.NoError(t, os.Chtimes(root, old, old))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "out", "a.o"), nil, 0644))
+	changed, err = ChangedSince(root, since, rules)
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	// A file changed in place
+	require.NoError(t, os.WriteFile(filepath.Join(root, "pkg1", "sub1", "file2.go"), []byte("changed"), 0644))
+	changed, err = ChangedSince(root, since, rules)
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	// A deletion, through its directory
+	require.NoError(t, os.Chtimes(filepath.Join(root, "pkg1", "sub1", "file2.go"), old, old))
+	require.NoError(t, os.Remove(filepath.Join(root, "pkg2", "sub2", "file0.go")))
+	changed, err = ChangedSince(root, since, rules)
+	require.NoError(t, err)
+	assert.True(t, changed)
+}
+
+func TestGrowth(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "keep.txt"), make([]byte, 100), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "shrink.txt"), make([]byte, 100), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "gone.txt"), make([]byte, 100), 0644))
+	before, err := TakeSizes(root)
+	require.NoError(t, err)
+
+	grown, err := Growth(root, before)
+	require.NoError(t, err)
+	assert.Zero(t, grown)
+
+	// New files count in full, grown ones by what they grew; shrinking and
+	// deleting give nothing back
+	require.NoError(t, os.WriteFile(filepath.Join(root, "keep.txt"), make([]byte, 150), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "shrink.txt"), make([]byte, 10), 0644))
+	require.NoError(t, os.Remove(filepath.Join(root, "gone.txt")))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "pkg"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "pkg", "new.txt"), make([]byte, 200), 0644))
+
+	grown, err = Growth(root, before)
+	require.NoError(t, err)
+	assert.Equal(t, int64(250), grown)
+}
+
+func TestGrowthCountsIgnoredDirectories(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, ".gitignore"), []byte("out/\n"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "node_modules", "dep"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "node_modules", "dep", "index.js"), make([]byte, 500), 0644))
+	before, err := TakeSizes(root)
+	require.NoError(t, err)
+
+	// Writes the changeset leaves out still count toward the quota, and
+	// files that were already there only by what they grew
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "out"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "out", "a.o"), make([]byte, 1000), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, ".git", "objects"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, ".git", "objects", "pack"), make([]byte, 1000), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "node_modules", "dep", "index.js"), make([]byte, 600), 0644))
+
+	grown, err := Growth(root, before)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2100), grown)
+}
+
+func BenchmarkTake(b *testing.B) {
+	root := b.TempDir()
+	makeTree(b, root, 500, 40)
+
+	for _, workers := range []int{1, 4, 0} {
+		name := fmt.Sprintf("workers=%d", workers)
+		if workers == 0 {
+			name = "workers=default"
+		}
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := TakeWithOptions(root, SnapshotOptions{Workers: workers}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkTake_Hash(b *testing.B) {
+	root := b.TempDir()
+	makeTree(b, root, 200, 40)
+
+	for i := 0; i < b.N; i++ {
+		if _, err := TakeWithOptions(root, SnapshotOptions{HashLimit: 1 << 20}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
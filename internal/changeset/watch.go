@@ -0,0 +1,16 @@
+package changeset
+
+import "errors"
+
+// ErrWatchUnsupported is returned by StartWatch on platforms without file
+// change events
+var ErrWatchUnsupported = errors.New("file change events are not supported on this platform")
+
+// Watch records which paths under a directory change, so a snapshot can be
+// refreshed (see Refresh) instead of taken again.
+type Watch interface {
+	// Stop ends the watch and returns the changed paths, relative to the
+	// watched directory. ok is false when changes may have been missed and
+	// a new snapshot must be taken.
+	Stop() (changed []string, ok bool)
+}
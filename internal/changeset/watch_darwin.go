@@ -0,0 +1,147 @@
+//go:build darwin
+
+package changeset
+
+/*
+#cgo LDFLAGS: -framework CoreServices
+#include <CoreServices/CoreServices.h>
+#include <dispatch/dispatch.h>
+#include <stdint.h>
+#include <stdlib.h>
+
+extern void faizeFSEvents(uintptr_t handle, size_t n, char **paths, FSEventStreamEventFlags *flags);
+
+static void faizeFSEventsCallback(ConstFSEventStreamRef stream, void *info, size_t n, void *paths,
+		const FSEventStreamEventFlags flags[], const FSEventStreamEventId ids[]) {
+	faizeFSEvents((uintptr_t)info, n, (char **)paths, (FSEventStreamEventFlags *)flags);
+}
+
+// faizeWatch starts a file-level event stream for root on queue; NULL on failure
+static FSEventStreamRef faizeWatch(const char *root, uintptr_t handle, dispatch_queue_t queue) {
+	CFStringRef path = CFStringCreateWithCString(NULL, root, kCFStringEncodingUTF8);
+	if (path == NULL) {
+		return NULL;
+	}
+	CFArrayRef paths = CFArrayCreate(NULL, (const void **)&path, 1, &kCFTypeArrayCallBacks);
+	FSEventStreamContext ctx = {0, (void *)handle, NULL, NULL, NULL};
+	FSEventStreamRef stream = FSEventStreamCreate(NULL, faizeFSEventsCallback, &ctx, paths,
+		kFSEventStreamEventIdSinceNow, 0.5,
+		kFSEventStreamCreateFlagFileEvents | kFSEventStreamCreateFlagNoDefer | kFSEventStreamCreateFlagWatchRoot);
+	CFRelease(paths);
+	CFRelease(path);
+	if (stream == NULL) {
+		return NULL;
+	}
+	FSEventStreamSetDispatchQueue(stream, queue);
+	if (!FSEventStreamStart(stream)) {
+		FSEventStreamInvalidate(stream);
+		FSEventStreamRelease(stream);
+		return NULL;
+	}
+	return stream;
+}
+
+static dispatch_queue_t faizeWatchQueue(void) {
+	return dispatch_queue_create("faize.changeset.watch", DISPATCH_QUEUE_SERIAL);
+}
+
+// faizeUnwatch delivers pending events and releases the stream and its queue
+static void faizeUnwatch(FSEventStreamRef stream, dispatch_queue_t queue) {
+	FSEventStreamFlushSync(stream);
+	FSEventStreamStop(stream);
+	FSEventStreamInvalidate(stream);
+	FSEventStreamRelease(stream);
+	dispatch_release(queue);
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime/cgo"
+	"sort"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+// fsWatch records changes reported by an FSEvents stream
+type fsWatch struct {
+	root   string // with symlinks resolved, as FSEvents reports paths
+	stream C.FSEventStreamRef
+	queue  C.dispatch_queue_t
+	handle cgo.Handle
+
+	mu      sync.Mutex
+	changed map[string]bool
+	lost    bool // root was moved or deleted
+	stopped bool
+}
+
+// StartWatch records changes under root with FSEvents until Stop
+func StartWatch(root string) (Watch, error) {
+	resolved, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", root, err)
+	}
+	w := &fsWatch{root: resolved, changed: make(map[string]bool)}
+	w.handle = cgo.NewHandle(w)
+
+	croot := C.CString(resolved)
+	defer C.free(unsafe.Pointer(croot))
+	w.queue = C.faizeWatchQueue()
+	w.stream = C.faizeWatch(croot, C.uintptr_t(w.handle), w.queue)
+	if w.stream == nil {
+		C.dispatch_release(w.queue)
+		w.handle.Delete()
+		return nil, fmt.Errorf("failed to watch %s for changes", root)
+	}
+	return w, nil
+}
+
+//export faizeFSEvents
+func faizeFSEvents(handle C.uintptr_t, n C.size_t, paths **C.char, flags *C.FSEventStreamEventFlags) {
+	w := cgo.Handle(handle).Value().(*fsWatch)
+	pathList := unsafe.Slice(paths, int(n))
+	flagList := unsafe.Slice(flags, int(n))
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for i, p := range pathList {
+		if flagList[i]&C.FSEventStreamEventFlags(C.kFSEventStreamEventFlagRootChanged) != 0 {
+			w.lost = true
+			continue
+		}
+		// Directories flagged with must-scan-subdirs (after dropped events)
+		// are walked again by Refresh like any changed directory
+		rel, err := filepath.Rel(w.root, C.GoString(p))
+		if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+			continue
+		}
+		w.changed[rel] = true
+	}
+}
+
+func (w *fsWatch) Stop() ([]string, bool) {
+	w.mu.Lock()
+	if w.stopped {
+		w.mu.Unlock()
+		return nil, false
+	}
+	w.stopped = true
+	w.mu.Unlock()
+
+	// Pending events are delivered before the stream is released
+	C.faizeUnwatch(w.stream, w.queue)
+	w.handle.Delete()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	changed := make([]string, 0, len(w.changed))
+	for rel := range w.changed {
+		changed = append(changed, rel)
+	}
+	sort.Strings(changed)
+	return changed, !w.lost
+}
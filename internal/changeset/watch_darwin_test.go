@@ -0,0 +1,37 @@
+//go:build darwin
+
+package changeset
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartWatch(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a.txt"), []byte("a"), 0644))
+
+	w, err := StartWatch(root)
+	require.NoError(t, err)
+	// FSEvents only reports changes made after the stream starts
+	time.Sleep(100 * time.Millisecond)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "sub"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "sub", "b.txt"), []byte("b"), 0644))
+	require.NoError(t, os.Remove(filepath.Join(root, "a.txt")))
+	time.Sleep(time.Second)
+
+	changed, ok := w.Stop()
+	assert.True(t, ok)
+	assert.Contains(t, changed, "a.txt")
+	assert.Contains(t, changed, filepath.Join("sub", "b.txt"))
+
+	changed, ok = w.Stop()
+	assert.False(t, ok, "a stopped watch reports nothing")
+	assert.Empty(t, changed)
+}
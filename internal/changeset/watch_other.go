@@ -0,0 +1,8 @@
+//go:build !darwin
+
+package changeset
+
+// StartWatch returns ErrWatchUnsupported; sessions take a new snapshot instead
+func StartWatch(root string) (Watch, error) {
+	return nil, ErrWatchUnsupported
+}
@@ -0,0 +1,102 @@
+package changeset
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefresh(t *testing.T) {
+	root := t.TempDir()
+	writeTree := func(files map[string]string) {
+		for path, content := range files {
+			full := filepath.Join(root, path)
+			require.NoError(t, os.MkdirAll(filepath.Dir(full), 0755))
+			require.NoError(t, os.WriteFile(full, []byte(content), 0644))
+		}
+	}
+	writeTree(map[string]string{
+		"main.go":                  "package main",
+		"pkg/a.go":                 "package pkg",
+		"pkg/b.go":                 "package pkg",
+		"old/gone.go":              "package old",
+		"node_modules/x/index.js":  "x",
+		"target/debug/app":         "bin",
+		".git/HEAD":                "ref: refs/heads/main",
+		"unchanged/deep/keep.txt":  "keep",
+		"unchanged/deep/other.txt": "other",
+	})
+	rules := &IgnoreRules{dirs: map[string]bool{"target": true}}
+	opts := SnapshotOptions{Rules: rules, HashLimit: 1 << 20}
+
+	before, err := TakeWithOptions(root, opts)
+	require.NoError(t, err)
+
+	writeTree(map[string]string{
+		"main.go":                 "package main // edited",
+		"pkg/new/c.go":            "package new",
+		"node_modules/y/index.js": "y",
+		"target/debug/app2":       "bin",
+	})
+	require.NoError(t, os.RemoveAll(filepath.Join(root, "old")))
+	require.NoError(t, os.Remove(filepath.Join(root, "pkg", "b.go")))
+
+	changed := []string{
+		"main.go",
+		"pkg/new",
+		"pkg/new/c.go",
+		"pkg/b.go",
+		"old",
+		"old/gone.go",
+		"node_modules/y/index.js",
+		"target/debug/app2",
+	}
+	refreshed, err := Refresh(root, before, changed, opts)
+	require.NoError(t, err)
+
+	after, err := TakeWithOptions(root, opts)
+	require.NoError(t, err)
+	assert.Equal(t, after, refreshed)
+	assert.Equal(t, Diff(before, after), Diff(before, refreshed))
+
+	// The original snapshot is not modified
+	assert.Contains(t, before, filepath.Join("old", "gone.go"))
+}
+
+func TestRefresh_Root(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a.txt"), []byte("a"), 0644))
+	before, err := Take(root)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "b.txt"), []byte("b"), 0644))
+	refreshed, err := Refresh(root, before, []string{"."}, SnapshotOptions{})
+	require.NoError(t, err)
+	assert.Contains(t, refreshed, "b.txt", "a change to the root takes a new snapshot")
+}
+
+func TestScanPoint(t *testing.T) {
+	before := Snapshot{"big": FileEntry{Path: "big", IsDir: true, ChildCount: 600}}
+	rules := &IgnoreRules{dirs: map[string]bool{"target": true}}
+
+	tests := []struct {
+		rel  string
+		want string
+		ok   bool
+	}{
+		{"src/main.go", "src/main.go", true},
+		{"node_modules/x/index.js", "node_modules", true},
+		{".git/refs/heads/main", ".git", true},
+		{"big/file.txt", "big", true},
+		{"target/debug/app", "", false},
+		{"target", "target", true},
+	}
+	for _, tt := range tests {
+		got, ok := scanPoint(before, filepath.FromSlash(tt.rel), rules)
+		assert.Equal(t, tt.ok, ok, tt.rel)
+		assert.Equal(t, filepath.FromSlash(tt.want), got, tt.rel)
+	}
+}
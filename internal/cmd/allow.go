@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/faize-ai/faize/internal/network"
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/spf13/cobra"
+)
+
+// allowTimeout is how long faize allow waits for a session to apply an addition
+const allowTimeout = 10 * time.Second
+
+var allowCmd = &cobra.Command{
+	Use:   "allow <domain> [session-id]",
+	Short: "Add a domain to a running session's network allowlist",
+	Long: `Allow a running session to reach another domain without restarting it.
+
+The domain can be a literal domain, a wildcard like *.example.com, a preset
+(npm, pypi, github, ...), an IP range like 10.0.0.0/8, or any of these limited
+to one port, like db.internal:5432. Sessions using the host egress proxy apply it to new
+connections immediately; otherwise the guest resolves the domain and adds it
+to its firewall. The session ID can be left out when only one session is
+running. Additions last for the session's lifetime and are shown by
+'faize inspect'.
+
+Examples:
+  faize allow crates.io
+  faize allow '*.googleapis.com' abc123
+  faize allow pypi abc123`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runAllow,
+}
+
+func init() {
+	rootCmd.AddCommand(allowCmd)
+}
+
+func runAllow(cmd *cobra.Command, args []string) error {
+	spec := strings.TrimSpace(strings.ToLower(args[0]))
+	if err := checkAllowSpec(spec); err != nil {
+		return err
+	}
+
+	store, err := session.NewStore()
+	if err != nil {
+		return fmt.Errorf("failed to open session store: %w", err)
+	}
+	var sess *session.Session
+	if len(args) == 2 {
+		if sess, err = store.Load(args[1]); err != nil {
+			return err
+		}
+	} else if sess, err = onlyRunningSession(store); err != nil {
+		return err
+	}
+	msg, err := allowNetwork(store, sess, spec)
+	if err != nil {
+		return err
+	}
+	fmt.Println(msg)
+	return nil
+}
+
+// checkAllowSpec rejects network entries that can't be added to a running
+// session, and warns about questionable ones
+func checkAllowSpec(spec string) error {
+	if spec == network.NetworkAll || spec == network.NetworkNone {
+		return fmt.Errorf("'%s' can't be added to a running session; restart it with --network %s", spec, spec)
+	}
+	_, problems := network.ParseStrict([]string{spec})
+	for _, p := range problems {
+		if p.Fatal {
+			return fmt.Errorf("invalid network entry %s", p)
+		}
+		fmt.Fprintf(os.Stderr, "Warning: network entry %s\n", p)
+	}
+	return nil
+}
+
+// allowNetwork adds spec, checked with checkAllowSpec, to a session's
+// allowlist and waits for a running session to apply it. Returns a message
+// saying what was done.
+func allowNetwork(store *session.Store, sess *session.Session, spec string) (string, error) {
+	if sess.Status != "running" && sess.Status != "paused" {
+		return "", fmt.Errorf("session %s is not running (status: %s)", sess.ID, sess.Status)
+	}
+	policy := network.Parse(sess.Network)
+	switch {
+	case !sess.ClaudeMode || policy.AllowAll:
+		return fmt.Sprintf("Session %s already allows all network traffic.", sess.ID), nil
+	case policy.Blocked:
+		return "", fmt.Errorf("session %s has networking disabled; restart it with --network to allow domains", sess.ID)
+	}
+	if policy.Extend([]string{spec}).Len() == policy.Len() {
+		return fmt.Sprintf("%s is already allowed in session %s.", spec, sess.ID), nil
+	}
+
+	// The host egress proxy reads additions from the session directory, which
+	// the guest can't write, and acknowledges them there; the guest's firewall
+	// reads its copy in the bootstrap share. Only the host's acknowledgement
+	// is waited for, since the guest can write anything to the share.
+	sessionDir := filepath.Join(store.Dir(), sess.ID)
+	bootstrapDir := filepath.Join(sessionDir, "bootstrap")
+	n, err := guest.AppendAllow(sessionDir, spec)
+	if err != nil {
+		return "", err
+	}
+	if _, err := guest.AppendAllow(bootstrapDir, spec); err != nil {
+		return "", err
+	}
+	// Update the latest record: the session's owner saves it while it runs
+	if _, err := store.Update(sess.ID, func(s *session.Session) bool {
+		s.Network = append(s.Network, spec)
+		*sess = *s
+		return true
+	}); err != nil {
+		return "", fmt.Errorf("failed to save session: %w", err)
+	}
+
+	if sess.Status == "paused" {
+		return fmt.Sprintf("Allowed %s in session %s; it applies when the session resumes.", spec, sess.ID), nil
+	}
+	agentCfg, err := guest.ReadConfig(filepath.Join(bootstrapDir, guest.ConfigFile))
+	if err != nil {
+		return "", fmt.Errorf("failed to read the session's agent config: %w", err)
+	}
+	if agentCfg.EgressPort == 0 {
+		return fmt.Sprintf("Allowed %s in session %s; the guest firewall applies it within a few seconds.", spec, sess.ID), nil
+	}
+	deadline := time.Now().Add(allowTimeout)
+	for guest.ReadAllowed(sessionDir) < n {
+		if time.Now().After(deadline) {
+			return "", fmt.Errorf("session %s has not applied %s after %s", sess.ID, spec, allowTimeout)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fmt.Sprintf("Allowed %s in session %s.", spec, sess.ID), nil
+}
+
+// onlyRunningSession returns the running session when there is exactly one
+func onlyRunningSession(store *session.Store) (*session.Session, error) {
+	sessions, err := store.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	var running []*session.Session
+	for _, sess := range sessions {
+		if sess.Status == "running" && !sess.Warm {
+			running = append(running, sess)
+		}
+	}
+	switch len(running) {
+	case 0:
+		return nil, fmt.Errorf("no running sessions")
+	case 1:
+		return running[0], nil
+	default:
+		ids := make([]string, len(running))
+		for i, sess := range running {
+			ids[i] = sess.ID
+		}
+		return nil, fmt.Errorf("%d sessions are running (%s); specify a session ID", len(running), strings.Join(ids, ", "))
+	}
+}
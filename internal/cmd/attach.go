@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/faize-ai/faize/internal/vm"
+	"github.com/spf13/cobra"
+)
+
+var attachCmd = &cobra.Command{
+	Use:   "attach <session-id>",
+	Short: "Attach to a running session's console",
+	Long: `Attach the current terminal to the console of a running session.
+
+Detaching with ~. leaves the session running, so you can reattach later.
+Use this with sessions started via 'faize start --detach'. Sessions paused by
+--auto-suspend are resumed first.
+
+Examples:
+  faize attach abc123`,
+	Args: cobra.ExactArgs(1),
+	RunE: runAttach,
+}
+
+func init() {
+	rootCmd.AddCommand(attachCmd)
+}
+
+func runAttach(cmd *cobra.Command, args []string) error {
+	id := resolveSessionRef(args[0])
+
+	store, err := session.NewStore()
+	if err != nil {
+		return fmt.Errorf("failed to open session store: %w", err)
+	}
+
+	sess, err := store.Load(id)
+	if err != nil {
+		return err
+	}
+	if sess.Status == "paused" && sess.AutoSuspended {
+		fmt.Printf("Resuming idle session %s...\n", id)
+		if err := resumeDetached(id); err != nil {
+			return err
+		}
+	} else if sess.Status != "running" {
+		return fmt.Errorf("session %s is not running (status: %s)", id, sess.Status)
+	}
+
+	manager, err := vm.NewManager()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Attaching to session %s... (~. to detach)\n", id)
+	err = manager.Attach(id)
+	if errors.Is(err, vm.ErrUserDetach) {
+		fmt.Printf("\nDetached from session %s (still running).\n", id)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("console error: %w", err)
+	}
+	return nil
+}
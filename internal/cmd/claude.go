@@ -10,10 +10,14 @@ var claudeCmd = &cobra.Command{
 	Long: `Manage Claude Code VM images and toolchain.
 
 Commands:
-  rebuild  Rebuild rootfs with extra dependencies from config
+  rebuild         Rebuild rootfs with extra dependencies from config
+  doctor          Check the Claude-specific environment
+  sync-settings   Write a session's settings changes back to ~/.claude
 
 Examples:
-  faize claude rebuild`,
+  faize claude rebuild
+  faize claude doctor
+  faize claude sync-settings`,
 }
 
 func init() {
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/faize-ai/faize/internal/artifacts"
+	"github.com/faize-ai/faize/internal/config"
+	"github.com/faize-ai/faize/internal/doctor"
+	"github.com/faize-ai/faize/internal/rootfs"
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+)
+
+var claudeDoctorOffline bool
+
+var claudeDoctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the Claude-specific environment",
+	Long: `Check everything a Claude session depends on and print a table of
+pass/warn/fail results, followed by a fix for each problem:
+
+  ~/.claude      the directory exists and shared files are readable
+  settings.json  valid JSON
+  plugins        plugin registries and manifests parse
+  credentials    persisted credentials exist and are not expired
+  rootfs         the image exists and contains claude, node, bun, faize-agent
+  network        the anthropic preset is allowed and resolves
+
+Exits non-zero if any check fails.
+
+Examples:
+  faize claude doctor
+  faize claude doctor --offline`,
+	Args: cobra.NoArgs,
+	RunE: runClaudeDoctor,
+}
+
+func init() {
+	claudeDoctorCmd.Flags().BoolVar(&claudeDoctorOffline, "offline", false, "skip DNS lookups and the latest-release check")
+	claudeCmd.AddCommand(claudeDoctorCmd)
+}
+
+func runClaudeDoctor(cmd *cobra.Command, args []string) error {
+	// Check the networks a session started here would get
+	cwd, _ := os.Getwd()
+	cfg, err := config.Load(cwd)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	manager, err := artifacts.NewManager()
+	if err != nil {
+		return fmt.Errorf("failed to create artifact manager: %w", err)
+	}
+	flavor, err := artifacts.ParseFlavor(cfg.Claude.Flavor)
+	if err != nil {
+		return err
+	}
+	home, err := homedir.Dir()
+	if err != nil {
+		return fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	env := doctor.ClaudeEnv{
+		ClaudeDir:    filepath.Join(home, ".claude"),
+		RootfsPath:   manager.FlavorRootfsPath(flavor),
+		ManifestPath: manager.FlavorManifestPath(flavor),
+		ToolchainDir: manager.ToolchainDir(),
+		Networks:     cfg.Networks,
+	}
+	if cfg.Claude.ShouldPersistCredentials() {
+		env.CredentialsDir = manager.CredentialsDir()
+	}
+	if !claudeDoctorOffline {
+		env.LookupHost = net.DefaultResolver.LookupHost
+		env.LatestClaude = rootfs.LatestClaudeVersion
+	}
+
+	results := doctor.CheckClaude(cmd.Context(), env)
+	doctor.Print(os.Stdout, results)
+	if doctor.Failed(results) {
+		return fmt.Errorf("claude doctor found problems")
+	}
+	return nil
+}
@@ -2,12 +2,16 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/faize-ai/faize/internal/artifacts"
 	"github.com/faize-ai/faize/internal/config"
+	"github.com/faize-ai/faize/internal/vm"
 	"github.com/spf13/cobra"
 )
 
+var claudeRebuildNoCache bool
+
 var claudeRebuildCmd = &cobra.Command{
 	Use:   "rebuild",
 	Short: "Rebuild Claude rootfs with extra dependencies",
@@ -15,8 +19,11 @@ var claudeRebuildCmd = &cobra.Command{
 
 This command reads claude.extra_deps from ~/.faize/config.yaml and bakes
 those packages into the rootfs image at build time. This is more reliable
-than installing packages at runtime since the rootfs has full apk support
-during the build process.
+than installing packages at runtime since the rootfs has full apk (or apt)
+support during the build process.
+
+The image rebuilt is the one of claude.flavor: alpine (the default), debian
+or ubuntu. Extra dependencies are package names of that distribution.
 
 Example config (~/.faize/config.yaml):
   claude:
@@ -28,18 +35,27 @@ Example config (~/.faize/config.yaml):
 After updating extra_deps, run this command to rebuild the rootfs:
   faize claude rebuild
 
+The build runs in a builder VM, which boots faize's base rootfs, so it needs
+no Docker; the debian and ubuntu flavors, which start from container images,
+still build in Docker, as does the Alpine one where faize can't boot VMs.
+
+Each build stage is cached in ~/.faize/artifacts/cache/, so a rebuild only
+repeats the stages whose inputs changed. Use --no-cache to build everything
+afresh, e.g. to pick up package updates.
+
 Then start a new session:
   faize start`,
 	RunE: runClaudeRebuild,
 }
 
 func init() {
+	claudeRebuildCmd.Flags().BoolVar(&claudeRebuildNoCache, "no-cache", false, "discard cached build stages and rebuild from scratch")
 	claudeCmd.AddCommand(claudeRebuildCmd)
 }
 
 func runClaudeRebuild(cmd *cobra.Command, args []string) error {
 	// Load configuration
-	cfg, err := config.Load()
+	cfg, err := config.Load("")
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -50,6 +66,27 @@ func runClaudeRebuild(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to create artifact manager: %w", err)
 	}
 
+	flavor, err := artifacts.ParseFlavor(cfg.Claude.Flavor)
+	if err != nil {
+		return err
+	}
+
+	// Build in builder VMs where this host can boot them, else in Docker
+	if vmManager, err := vm.NewManager(); err == nil {
+		runner, err := vm.NewBuildRunner(vmManager, manager, os.Stdout)
+		if err == nil {
+			manager.SetBuildRunner(runner)
+		} else {
+			Debug("Builder VMs unavailable, building in Docker: %v", err)
+		}
+	}
+
+	if claudeRebuildNoCache {
+		if err := manager.ClearBuildCache(); err != nil {
+			return err
+		}
+	}
+
 	extraDeps := cfg.Claude.ExtraDeps
 	if len(extraDeps) == 0 {
 		fmt.Println("No extra dependencies configured in ~/.faize/config.yaml")
@@ -61,7 +98,7 @@ func runClaudeRebuild(cmd *cobra.Command, args []string) error {
 	}
 
 	// Build rootfs with extra dependencies
-	if err := manager.BuildClaudeRootfsWithDeps(extraDeps); err != nil {
+	if err := manager.BuildFlavorRootfs(flavor, extraDeps); err != nil {
 		return fmt.Errorf("failed to rebuild rootfs: %w", err)
 	}
 
@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/faize-ai/faize/internal/changeset"
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+)
+
+var claudeSyncSettingsYes bool
+
+var claudeSyncSettingsCmd = &cobra.Command{
+	Use:   "sync-settings [session-id]",
+	Short: "Write a session's Claude settings changes back to ~/.claude",
+	Long: `Write the Claude settings.json of a session started with --sync-settings
+(or claude.sync_settings) back to ~/.claude/settings.json, after showing the
+diff and asking for confirmation. Skills and plugin files the session added
+or changed are copied back likewise when it was started with --sync-skills
+(or claude.sync_skills).
+
+An attached session offers this when it ends; use this command for detached
+sessions, or to apply changes declined then. Defaults to the most recent
+session.
+
+Examples:
+  faize claude sync-settings
+  faize claude sync-settings abc123 --yes`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runClaudeSyncSettings,
+}
+
+func init() {
+	claudeSyncSettingsCmd.Flags().BoolVarP(&claudeSyncSettingsYes, "yes", "y", false, "write the settings without asking")
+	claudeCmd.AddCommand(claudeSyncSettingsCmd)
+}
+
+func runClaudeSyncSettings(cmd *cobra.Command, args []string) error {
+	store, err := session.NewStore()
+	if err != nil {
+		return fmt.Errorf("failed to open session store: %w", err)
+	}
+	var id string
+	if len(args) > 0 {
+		id = resolveSessionRef(args[0])
+	} else if id, err = findMostRecentSession(store); err != nil {
+		return err
+	}
+	sess, err := store.Load(id)
+	if err != nil {
+		return fmt.Errorf("failed to load session: %w", err)
+	}
+	if sess.Status == "running" || sess.Status == "paused" {
+		return fmt.Errorf("session %s is %s; its settings are saved when it stops", id, sess.Status)
+	}
+	home, err := homedir.Dir()
+	if err != nil {
+		return fmt.Errorf("failed to get home directory: %w", err)
+	}
+	bootstrapDir := filepath.Join(store.Dir(), id, "bootstrap")
+	_, settingsErr := os.Stat(filepath.Join(bootstrapDir, guest.SettingsFile))
+	_, skillsErr := os.Stat(filepath.Join(bootstrapDir, guest.ClaudeSyncDir))
+	if settingsErr != nil && skillsErr != nil {
+		return fmt.Errorf("session %s saved no settings (start sessions with --sync-settings or --sync-skills)", id)
+	}
+	claudeDir := filepath.Join(home, ".claude")
+	handled, err := syncSettings(id, bootstrapDir, claudeDir, claudeSyncSettingsYes)
+	if err != nil {
+		return err
+	}
+	handledDirs, err := syncClaudeDirs(id, bootstrapDir, claudeDir, claudeSyncSettingsYes)
+	if err != nil {
+		return err
+	}
+	if !handled && !handledDirs {
+		fmt.Println("Nothing to sync")
+	}
+	return nil
+}
+
+// syncSettings offers to write the settings.json a session saved at shutdown
+// back to claudeDir, showing the diff and asking first unless yes is set.
+// Settings the session didn't change are left alone, even if the host's
+// changed meanwhile; handled reports whether there was anything to offer.
+func syncSettings(id, bootstrapDir, claudeDir string, yes bool) (handled bool, err error) {
+	changed, err := os.ReadFile(filepath.Join(bootstrapDir, guest.SettingsFile))
+	if err != nil {
+		return false, nil
+	}
+	base, baseErr := os.ReadFile(filepath.Join(bootstrapDir, guest.SettingsBase))
+	if baseErr == nil && bytes.Equal(changed, base) {
+		return false, nil
+	}
+	hostPath := filepath.Join(claudeDir, "settings.json")
+	current, err := os.ReadFile(hostPath)
+	if err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("failed to read %s: %w", hostPath, err)
+	}
+	if bytes.Equal(changed, current) {
+		return false, nil
+	}
+	if !json.Valid(changed) {
+		return true, fmt.Errorf("session %s's settings.json is not valid JSON; not syncing it", id)
+	}
+
+	fmt.Printf("\nClaude settings changed in session %s:\n", id)
+	if err := changeset.WriteFileDiff(os.Stdout, "settings.json", current, changed); err != nil {
+		return true, err
+	}
+	if baseErr == nil && !bytes.Equal(current, base) {
+		fmt.Println("~/.claude/settings.json also changed since the session started; syncing replaces those changes")
+	}
+	if !yes && !confirm("Write them to ~/.claude/settings.json?") {
+		fmt.Printf("Not synced; apply them later with: faize claude sync-settings %s\n", id)
+		return true, nil
+	}
+
+	// Write in place, so a settings.json symlinked from elsewhere stays linked
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(hostPath); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.WriteFile(hostPath, changed, mode); err != nil {
+		return true, fmt.Errorf("failed to write %s: %w", hostPath, err)
+	}
+	fmt.Println("Synced settings to ~/.claude/settings.json")
+	return true, nil
+}
+
+// syncClaudeDirs offers to copy the skills and plugin files a session added
+// or changed back to claudeDir, listing them and asking first unless yes is
+// set. Files are only added or replaced, never deleted; plugin configs get
+// the host's paths back. handled reports whether there was anything to offer.
+func syncClaudeDirs(id, bootstrapDir, claudeDir string, yes bool) (handled bool, err error) {
+	type syncFile struct {
+		rel  string
+		data []byte
+		mode os.FileMode
+		new  bool
+	}
+	src := filepath.Join(bootstrapDir, guest.ClaudeSyncDir)
+	var files []syncFile
+	err = filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(rel, "plugins"+string(filepath.Separator)) && filepath.Ext(rel) == ".json" {
+			data = guest.RestorePluginPaths(data, claudeDir)
+		}
+		current, err := os.ReadFile(filepath.Join(claudeDir, rel))
+		if err == nil && bytes.Equal(current, data) {
+			return nil
+		}
+		files = append(files, syncFile{rel: rel, data: data, mode: info.Mode().Perm(), new: os.IsNotExist(err)})
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read session %s's skills and plugins: %w", id, err)
+	}
+	if len(files) == 0 {
+		return false, nil
+	}
+
+	fmt.Printf("\nClaude skills and plugins changed in session %s:\n", id)
+	for _, f := range files {
+		status := "modified"
+		if f.new {
+			status = "new"
+		}
+		fmt.Printf("  %-9s %s\n", status, filepath.ToSlash(f.rel))
+	}
+	if !yes && !confirm("Copy them to ~/.claude?") {
+		fmt.Printf("Not synced; apply them later with: faize claude sync-settings %s\n", id)
+		return true, nil
+	}
+	for _, f := range files {
+		dst := filepath.Join(claudeDir, f.rel)
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return true, fmt.Errorf("failed to create %s: %w", filepath.Dir(dst), err)
+		}
+		if err := os.WriteFile(dst, f.data, f.mode); err != nil {
+			return true, fmt.Errorf("failed to write %s: %w", dst, err)
+		}
+	}
+	fmt.Printf("Synced %d file(s) to ~/.claude\n", len(files))
+	return true, nil
+}
+
+// saveSettingsBase records the host's settings.json as a session starts, so
+// syncSettings can tell what the session changed
+func saveSettingsBase(bootstrapDir, claudeDir string) {
+	data, _ := os.ReadFile(filepath.Join(claudeDir, "settings.json"))
+	if err := os.WriteFile(filepath.Join(bootstrapDir, guest.SettingsBase), data, 0600); err != nil {
+		Debug("Failed to record settings base: %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/faize-ai/faize/internal/vm"
+	"github.com/spf13/cobra"
+)
+
+var cpCmd = &cobra.Command{
+	Use:   "cp <session-id>:<path> <local-path> | <local-path> <session-id>:<path>",
+	Short: "Copy files between the host and a running session",
+	Long: `Copy a file or directory between the host and a running session, whether
+or not the path is under a mount. Copies are staged through the session's
+bootstrap share.
+
+Like cp -R, an existing directory at the destination receives the copy;
+otherwise the copy takes the destination's name. Relative guest paths are
+resolved against the project directory. Files copied into the session are
+owned by the session user.
+
+Examples:
+  faize cp abc123:/tmp/build/app.tar.gz .
+  faize cp abc123:dist ./dist-from-vm
+  faize cp ./fixtures abc123:/tmp/`,
+	Args: cobra.ExactArgs(2),
+	RunE: runCp,
+}
+
+func init() {
+	rootCmd.AddCommand(cpCmd)
+}
+
+func runCp(cmd *cobra.Command, args []string) error {
+	srcID, src, srcRemote := parseCopyPath(args[0])
+	dstID, dst, dstRemote := parseCopyPath(args[1])
+	if srcRemote == dstRemote {
+		return fmt.Errorf("exactly one of the paths must be in a session (<session-id>:<path>)")
+	}
+	id := srcID
+	if dstRemote {
+		id = dstID
+	}
+	id = resolveSessionRef(id)
+	if src == "" || dst == "" {
+		return fmt.Errorf("paths must not be empty")
+	}
+
+	store, err := session.NewStore()
+	if err != nil {
+		return fmt.Errorf("failed to open session store: %w", err)
+	}
+	sess, err := store.Load(id)
+	if err != nil {
+		return err
+	}
+	if sess.Status != "running" {
+		return fmt.Errorf("session %s is not running (status: %s)", id, sess.Status)
+	}
+
+	if srcRemote {
+		Debug("Copy %s:%s -> %s", id, src, dst)
+		return vm.CopyFromGuest(id, src, dst)
+	}
+	Debug("Copy %s -> %s:%s", src, id, dst)
+	return vm.CopyToGuest(id, src, dst)
+}
+
+// parseCopyPath splits "<session-id>:<path>". Anything else, including paths
+// whose prefix contains a slash (./a:b), is a host path.
+func parseCopyPath(arg string) (id, path string, remote bool) {
+	prefix, rest, ok := strings.Cut(arg, ":")
+	if !ok || prefix == "" || strings.ContainsAny(prefix, `/\`) {
+		return "", arg, false
+	}
+	return prefix, rest, true
+}
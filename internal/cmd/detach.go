@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/faize-ai/faize/internal/vm"
+	"github.com/mitchellh/go-homedir"
+)
+
+// daemonReadyFD is the file descriptor a detached session process reports readiness on
+const daemonReadyFD = 3
+
+// daemonReady is the readiness pipe inherited from the detaching parent
+var daemonReady *os.File
+
+// runDetached re-executes the current command (`faize start` or `faize resume`)
+// as a background process that owns the VM, waits for it to report the session
+// ID, and returns it without attaching.
+func runDetached() (string, error) {
+	// Same arguments, minus --detach, plus the hidden --daemon flag
+	var args []string
+	for _, arg := range os.Args[1:] {
+		if arg == "--detach" || strings.HasPrefix(arg, "--detach=") {
+			continue
+		}
+		args = append(args, arg)
+	}
+	return startDetached(append(args, "--daemon"))
+}
+
+// startDetached runs faize with args, which must include --daemon, as the
+// background owner of a session and returns the session's ID once it runs
+func startDetached(args []string) (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate faize executable: %w", err)
+	}
+
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	logPath := filepath.Join(home, ".faize", "daemon.log")
+	if err := os.MkdirAll(filepath.Dir(logPath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create faize directory: %w", err)
+	}
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return "", fmt.Errorf("failed to open daemon log: %w", err)
+	}
+	defer func() { _ = logFile.Close() }()
+
+	readyRead, readyWrite, err := os.Pipe()
+	if err != nil {
+		return "", fmt.Errorf("failed to create ready pipe: %w", err)
+	}
+	defer func() { _ = readyRead.Close() }()
+
+	child := exec.Command(exe, args...)
+	child.Stdout = logFile
+	child.Stderr = logFile
+	child.ExtraFiles = []*os.File{readyWrite} // becomes daemonReadyFD in the child
+	child.SysProcAttr = detachSysProcAttr()
+	if err := child.Start(); err != nil {
+		_ = readyWrite.Close()
+		return "", fmt.Errorf("failed to start background session: %w", err)
+	}
+	_ = readyWrite.Close()
+
+	// The child writes a single line once the VM is running (or startup failed).
+	// Read just that line: VM helper processes may inherit the pipe and hold it open.
+	line, _ := bufio.NewReader(readyRead).ReadString('\n')
+	line = strings.TrimSpace(line)
+
+	if id, ok := strings.CutPrefix(line, "ready "); ok {
+		_ = child.Process.Release()
+		return id, nil
+	}
+
+	_ = child.Wait()
+	if msg, ok := strings.CutPrefix(line, "error "); ok {
+		return "", fmt.Errorf("%s", msg)
+	}
+	return "", fmt.Errorf("background session failed to start (see %s)", logPath)
+}
+
+// notifyDaemonParent reports status to the `faize start --detach` process waiting on
+// daemonReadyFD. Only the first call has any effect.
+func notifyDaemonParent(status string) {
+	if daemonReady == nil {
+		return
+	}
+	_, _ = fmt.Fprintln(daemonReady, status)
+	_ = daemonReady.Close()
+	daemonReady = nil
+}
+
+// openDaemonReady claims the inherited readiness pipe
+func openDaemonReady() {
+	daemonReady = os.NewFile(daemonReadyFD, "daemon-ready")
+}
+
+// superviseDetached records this process as the owner of a running session,
+// releases the waiting parent and blocks until the VM stops, a termination
+// signal arrives (killed), or the session is saved by 'faize pause' or after
+// being detached and idle for its auto-suspend period (paused).
+func superviseDetached(manager vm.Manager, sess *session.Session) (killed, paused bool) {
+	sess.PID = os.Getpid()
+	sess.Detached = true
+	sess.AutoSuspended = false
+	store, storeErr := session.NewStore()
+	if storeErr == nil {
+		if saveErr := store.Save(sess); saveErr != nil {
+			Debug("Failed to save session: %v", saveErr)
+		}
+	}
+	notifyDaemonParent("ready " + sess.ID)
+
+	suspender, canSuspend := manager.(vm.Suspender)
+	suspend := func() bool {
+		if !canSuspend {
+			vm.RecordPauseError(sess.ID, fmt.Errorf("this VM backend does not support pausing"))
+			return false
+		}
+		if err := suspender.Suspend(sess.ID); err != nil {
+			fmt.Printf("Failed to pause session: %v\n", err)
+			vm.RecordPauseError(sess.ID, err)
+			return false
+		}
+		return true
+	}
+
+	// Pause the session once it has been detached and idle long enough
+	var idleCh chan struct{}
+	if autoSuspend, _ := time.ParseDuration(sess.AutoSuspend); autoSuspend > 0 && canSuspend && storeErr == nil {
+		idleCh = make(chan struct{}, 1)
+		attached := func() bool {
+			latest, err := store.Load(sess.ID)
+			return err == nil && latest.Attached()
+		}
+		consoleLog := filepath.Join(store.Dir(), sess.ID, "console.log")
+		cancel := watchIdle(consoleLog, sessionIdleRoots(sess), autoSuspend, attached, func() { idleCh <- struct{}{} })
+		defer cancel()
+	}
+
+	signal.Ignore(syscall.SIGHUP)
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(sigCh)
+	pauseCh := make(chan os.Signal, 1)
+	vm.NotifyPause(pauseCh)
+	defer signal.Stop(pauseCh)
+
+	stopped := manager.WaitForVMStop(sess.ID)
+	for {
+		select {
+		case <-stopped:
+			return false, false
+		case sig := <-sigCh:
+			fmt.Printf("Received %s\n", sig)
+			return true, false
+		case <-pauseCh:
+			if suspend() {
+				return false, true
+			}
+		case <-idleCh:
+			fmt.Printf("Session %s is detached and idle, pausing\n", sess.ID)
+			if !suspend() {
+				idleCh = nil // don't try again
+				continue
+			}
+			if _, err := store.Update(sess.ID, func(s *session.Session) bool {
+				s.AutoSuspended = true
+				return true
+			}); err != nil {
+				Debug("Failed to save session: %v", err)
+			}
+			return false, true
+		}
+	}
+}
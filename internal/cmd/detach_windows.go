@@ -0,0 +1,10 @@
+//go:build windows
+
+package cmd
+
+import "syscall"
+
+// detachSysProcAttr is a no-op on Windows
+func detachSysProcAttr() *syscall.SysProcAttr {
+	return nil
+}
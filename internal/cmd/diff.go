@@ -6,30 +6,66 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/faize-ai/faize/internal/changeset"
 	"github.com/faize-ai/faize/internal/session"
 	"github.com/spf13/cobra"
 )
 
-var diffJSON bool
+var (
+	diffJSON  bool
+	diffStats bool
+	diffStat  bool
+	diffPatch bool
+	diffMount string
+	diffList  bool
+)
 
 var diffCmd = &cobra.Command{
-	Use:   "diff [session-id]",
+	Use:   "diff [session-id] [other-session-id]",
 	Short: "Show changes from a session",
 	Long: `Show file changes made during a faize session.
 
 If no session-id is given, shows changes from the most recent session.
+With --list, list the saved changesets of all sessions with their dates and
+disk usage instead; 'faize prune' removes old ones per changeset.keep_days
+and changeset.keep_sessions.
+With two session IDs, compare their changes instead: files only one session
+changed, files both changed differently, and how many they changed the same
+way, such as when running one task in parallel sessions to pick the best.
+With --stats, show per-mount totals instead: files created, modified, and
+deleted, bytes added and removed, snapshot time, and the largest new files.
+With --stat, show a compact view like git's: files changed per top-level
+directory with insertions and deletions estimated from file sizes.
+
+With --patch, print the content changes as a patch that applies with
+'git apply' in the mount's directory. This needs changeset.keep_contents:
+true in ~/.faize/config.yaml when the session starts, so files are copied
+before the session; binary files and files over changeset.hash_limit are
+left out.
 
 Examples:
   faize diff
   faize diff abc123
-  faize diff --json`,
+  faize diff --stats
+  faize diff --stat
+  faize diff --json
+  faize diff abc123 --patch > session.patch
+  faize diff abc123 def456
+  faize diff --list`,
+	Args: cobra.MaximumNArgs(2),
 	RunE: runDiff,
 }
 
 func init() {
 	diffCmd.Flags().BoolVar(&diffJSON, "json", false, "output in JSON format")
+	diffCmd.Flags().BoolVar(&diffStats, "stats", false, "show per-mount statistics instead of individual changes")
+	diffCmd.Flags().BoolVar(&diffStat, "stat", false, "show files changed per directory with estimated insertions and deletions")
+	diffCmd.Flags().BoolVar(&diffPatch, "patch", false, "print content changes as a unified diff")
+	diffCmd.Flags().StringVar(&diffMount, "mount", "", "with --patch, the mount to diff, by host or guest path")
+	diffCmd.Flags().BoolVar(&diffList, "list", false, "list all saved changesets")
 	rootCmd.AddCommand(diffCmd)
 }
 
@@ -39,9 +75,16 @@ func runDiff(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to open session store: %w", err)
 	}
 
+	if diffList {
+		return runDiffList(store)
+	}
+	if len(args) == 2 {
+		return runDiffCompare(store, resolveSessionRef(args[0]), resolveSessionRef(args[1]))
+	}
+
 	var sessionID string
 	if len(args) > 0 {
-		sessionID = args[0]
+		sessionID = resolveSessionRef(args[0])
 	} else {
 		// Find most recent session
 		sessionID, err = findMostRecentSession(store)
@@ -50,13 +93,9 @@ func runDiff(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	// Look for changeset.json in session's bootstrap dir
-	bootstrapDir := filepath.Join(store.Dir(), sessionID, "bootstrap")
-	changesetPath := filepath.Join(bootstrapDir, "changeset.json")
-
-	cs, err := changeset.LoadChangeset(changesetPath)
+	cs, err := loadSessionChangeset(store, sessionID)
 	if err != nil {
-		return fmt.Errorf("no changeset found for session %s: %w", sessionID, err)
+		return err
 	}
 
 	if diffJSON {
@@ -69,10 +108,135 @@ func runDiff(cmd *cobra.Command, args []string) error {
 	for i := range cs.MountChanges {
 		cs.MountChanges[i].Changes = changeset.FilterPaths(cs.MountChanges[i].Changes)
 	}
+	if diffPatch {
+		return writeSessionPatch(filepath.Join(store.Dir(), sessionID), cs)
+	}
+	if diffStats {
+		changeset.PrintStats(os.Stdout, cs)
+		return nil
+	}
+	if diffStat {
+		changeset.PrintStat(os.Stdout, cs)
+		return nil
+	}
 	changeset.PrintSummary(os.Stdout, cs)
 	return nil
 }
 
+// runDiffList lists the saved changesets, newest first
+func runDiffList(store *session.Store) error {
+	stored, err := changeset.ListChangesets(store.Dir())
+	if err != nil {
+		return fmt.Errorf("failed to list changesets: %w", err)
+	}
+	if diffJSON {
+		if stored == nil {
+			stored = []changeset.StoredChangeset{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(stored)
+	}
+	if len(stored) == 0 {
+		fmt.Println("No saved changesets.")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "SESSION\tSAVED\tCHANGES\tSIZE\tPROJECT")
+	var total int64
+	for _, s := range stored {
+		total += s.Size
+		project := s.Project
+		if project == "" {
+			project = "-"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n",
+			s.SessionID, s.Time.Local().Format("2006-01-02 15:04"), s.Changes, changeset.FormatSize(s.Size), project)
+	}
+	_ = tw.Flush()
+	fmt.Printf("\n%d changeset(s), %s\n", len(stored), changeset.FormatSize(total))
+	return nil
+}
+
+// runDiffCompare compares the changes of two sessions
+func runDiffCompare(store *session.Store, idA, idB string) error {
+	if diffStats || diffStat || diffPatch {
+		return fmt.Errorf("--stats, --stat, and --patch show a single session")
+	}
+	a, err := loadSessionChangeset(store, idA)
+	if err != nil {
+		return err
+	}
+	b, err := loadSessionChangeset(store, idB)
+	if err != nil {
+		return err
+	}
+
+	cmps := changeset.CompareChangesets(a, b)
+	if diffJSON {
+		if cmps == nil {
+			cmps = []changeset.MountComparison{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(cmps)
+	}
+	changeset.PrintComparison(os.Stdout, idA, idB, cmps)
+	return nil
+}
+
+// loadSessionChangeset loads the changeset saved in a session's bootstrap dir
+func loadSessionChangeset(store *session.Store, sessionID string) (*changeset.SessionChangeset, error) {
+	changesetPath := filepath.Join(store.Dir(), sessionID, "bootstrap", "changeset.json")
+	cs, err := changeset.LoadChangeset(changesetPath)
+	if err != nil {
+		return nil, fmt.Errorf("no changeset found for session %s: %w", sessionID, err)
+	}
+	return cs, nil
+}
+
+// writeSessionPatch prints the content changes of one of a session's mounts
+// as a patch, listing the files left out on stderr
+func writeSessionPatch(sessionDir string, cs *changeset.SessionChangeset) error {
+	stash := filepath.Join(sessionDir, changeset.StashDir)
+	if _, err := os.Stat(stash); err != nil {
+		return fmt.Errorf("session %s kept no file contents; set changeset.keep_contents: true in ~/.faize/config.yaml before starting a session", cs.SessionID)
+	}
+
+	var mounts []changeset.MountChanges
+	var sources []string
+	for _, mc := range cs.MountChanges {
+		// Summarized mounts (toolchain, credentials) have no contents kept
+		if len(mc.Summary) > 0 || len(mc.Changes) == 0 {
+			continue
+		}
+		if diffMount != "" && filepath.Clean(diffMount) != mc.Source && filepath.Clean(diffMount) != mc.Target {
+			continue
+		}
+		mounts = append(mounts, mc)
+		sources = append(sources, mc.Source)
+	}
+	switch {
+	case len(mounts) == 0 && diffMount != "":
+		return fmt.Errorf("no file changes in mount %s", diffMount)
+	case len(mounts) == 0:
+		return nil
+	case len(mounts) > 1:
+		return fmt.Errorf("session %s changed files in %d mounts; choose one with --mount: %s",
+			cs.SessionID, len(mounts), strings.Join(sources, ", "))
+	}
+
+	skipped, err := changeset.WritePatch(os.Stdout, mounts[0], stash)
+	if err != nil {
+		return fmt.Errorf("failed to write patch: %w", err)
+	}
+	for _, path := range skipped {
+		fmt.Fprintf(os.Stderr, "Not in patch (binary or no contents kept): %s\n", path)
+	}
+	return nil
+}
+
 // findMostRecentSession returns the ID of the most recently started session.
 func findMostRecentSession(store *session.Store) (string, error) {
 	sessions, err := store.List()
@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/faize-ai/faize/internal/changeset"
+	"github.com/faize-ai/faize/internal/guest"
+)
+
+// eventPollInterval is how often a session's boot stage and network logs are
+// checked for new events
+const eventPollInterval = 500 * time.Millisecond
+
+// startOutput selects how faize start and faize run report the session:
+// "text" or "json-stream"
+var startOutput string
+
+// events receives session lifecycle events with --output json-stream; nil
+// otherwise, and then emitting does nothing
+var events *eventStream
+
+// streamEvent is one line of --output json-stream
+type streamEvent struct {
+	Time    time.Time      `json:"time"`
+	Event   string         `json:"event"` // "created", "booted", "attached", "network-deny", "write-quota", "file-change", "stopped", or "summary"
+	Session string         `json:"session,omitempty"`
+	Data    map[string]any `json:"data,omitempty"`
+}
+
+// eventStream writes events as newline-delimited JSON
+type eventStream struct {
+	mu      sync.Mutex
+	enc     *json.Encoder
+	session string
+}
+
+// configureOutput applies --output before a session starts
+func configureOutput() error {
+	switch startOutput {
+	case "", "text":
+		events = nil
+	case "json-stream":
+		if startDetach {
+			return fmt.Errorf("--output json-stream needs a foreground session; it can't be used with --detach")
+		}
+		events = newEventStream(os.Stderr)
+	default:
+		return fmt.Errorf("invalid --output %q: use text or json-stream", startOutput)
+	}
+	return nil
+}
+
+func newEventStream(w io.Writer) *eventStream {
+	return &eventStream{enc: json.NewEncoder(w)}
+}
+
+// setSession sets the session ID of the events that follow
+func (s *eventStream) setSession(id string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.session = id
+	s.mu.Unlock()
+}
+
+// emit writes one event
+func (s *eventStream) emit(event string, data map[string]any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_ = s.enc.Encode(streamEvent{Time: time.Now().UTC(), Event: event, Session: s.session, Data: data})
+}
+
+// follow emits "booted" when the guest of a session started at started is
+// ready, "network-deny" for each connection the session's firewall or egress
+// proxy denies, and "notification" for each event guest hooks report with
+// faize-notify, until the returned function is called. It checks the logs a
+// last time before it returns.
+func (s *eventStream) follow(bootstrapDir string, started time.Time) (stop func()) {
+	if s == nil {
+		return func() {}
+	}
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		booted := false
+		denied := make(map[changeset.NetworkEvent]bool)
+		var notifyOffset int64
+		ticker := time.NewTicker(eventPollInterval)
+		defer ticker.Stop()
+		for {
+			last := false
+			select {
+			case <-done:
+				last = true
+			case <-ticker.C:
+			}
+			if !booted && readStage(bootstrapDir) == guest.BootReady {
+				booted = true
+				s.emit("booted", map[string]any{"seconds": time.Since(started).Round(100 * time.Millisecond).Seconds()})
+			}
+			s.emitDenials(bootstrapDir, denied)
+			var notes []guest.Notification
+			notes, notifyOffset, _ = guest.ReadNotifications(bootstrapDir, notifyOffset)
+			for _, n := range notes {
+				s.emit("notification", map[string]any{"event": n.Event, "message": n.Message})
+			}
+			if last {
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+// emitDenials emits the denied connections in the session's logs that
+// haven't been emitted yet
+func (s *eventStream) emitDenials(bootstrapDir string, seen map[changeset.NetworkEvent]bool) {
+	netEvents, err := changeset.CollectNetworkEvents(bootstrapDir)
+	if err != nil {
+		return
+	}
+	for _, e := range netEvents {
+		if e.Action != "DENY" || seen[e] {
+			continue
+		}
+		seen[e] = true
+		data := map[string]any{"port": e.DstPort, "proto": e.Proto}
+		if e.DstIP != "" {
+			data["ip"] = e.DstIP
+		}
+		if e.Domain != "" {
+			data["domain"] = e.Domain
+		}
+		s.emit("network-deny", data)
+	}
+}
+
+// emitChanges emits a "file-change" event for each file changed in a mount
+func (s *eventStream) emitChanges(mounts []changeset.MountChanges) {
+	if s == nil {
+		return
+	}
+	for _, m := range mounts {
+		for _, c := range m.Changes {
+			data := map[string]any{"path": filepath.Join(m.Source, c.Path), "type": c.Type, "mount": m.Target}
+			if c.Type == "renamed" {
+				data["old_path"] = filepath.Join(m.Source, c.OldPath)
+			}
+			s.emit("file-change", data)
+		}
+	}
+}
+
+// emitSummary emits the "summary" event of a session that ran from started
+// to ended
+func (s *eventStream) emitSummary(bootstrapDir string, mounts []changeset.MountChanges, started, ended time.Time) {
+	if s == nil {
+		return
+	}
+	files := 0
+	for _, m := range mounts {
+		files += len(m.Changes)
+	}
+	denied := 0
+	if netEvents, err := changeset.CollectNetworkEvents(bootstrapDir); err == nil {
+		for _, e := range netEvents {
+			if e.Action == "DENY" {
+				denied++
+			}
+		}
+	}
+	guestChanges, _ := changeset.ParseGuestChanges(filepath.Join(bootstrapDir, "guest-changes.txt"))
+	packages, _ := changeset.ParseGuestPackages(filepath.Join(bootstrapDir, guest.PackagesFile))
+	names := changeset.PackageNames(packages)
+	if names == nil {
+		names = []string{}
+	}
+	s.emit("summary", map[string]any{
+		"duration":       ended.Sub(started).Round(time.Second).String(),
+		"exit_code":      sessionExitCode,
+		"files_changed":  files,
+		"guest_changes":  len(guestChanges),
+		"packages":       names,
+		"network_denied": denied,
+	})
+}
+
+// readStage returns the boot stage the agent last recorded, or ""
+func readStage(bootstrapDir string) string {
+	data, err := os.ReadFile(filepath.Join(bootstrapDir, guest.BootStageFile))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
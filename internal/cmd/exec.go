@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/faize-ai/faize/internal/vm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	execUser    string
+	execWorkdir string
+	execEnv     []string
+)
+
+var execCmd = &cobra.Command{
+	Use:   "exec <session-id> -- <command> [args...]",
+	Short: "Run a command inside a running session",
+	Long: `Run a command inside a running session and print its output.
+
+The command runs non-interactively (no stdin or TTY) in the session's project
+directory, as the session user. Its stdout, stderr, and exit code are passed
+through, so faize exec works in scripts.
+
+Examples:
+  faize exec abc123 -- git status
+  faize exec abc123 -- sh -c 'npm test 2>&1 | tail -20'
+  faize exec abc123 --user root -- apk add jq
+  faize exec abc123 -w /tmp -e DEBUG=1 -- ./run.sh`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: runExec,
+}
+
+func init() {
+	addExecFlags(execCmd)
+	rootCmd.AddCommand(execCmd)
+}
+
+// addExecFlags registers the exec flags on a command (faize exec and faize session exec)
+func addExecFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&execUser, "user", "u", "", "user to run the command as (default: session user)")
+	cmd.Flags().StringVarP(&execWorkdir, "workdir", "w", "", "working directory inside the VM (default: project directory)")
+	cmd.Flags().StringArrayVarP(&execEnv, "env", "e", nil, "set an environment variable (KEY=VALUE, repeatable)")
+}
+
+func runExec(cmd *cobra.Command, args []string) error {
+	id := resolveSessionRef(args[0])
+	if dash := cmd.ArgsLenAtDash(); dash != -1 && dash != 1 {
+		return fmt.Errorf("usage: faize exec <session-id> -- <command> [args...]")
+	}
+
+	store, err := session.NewStore()
+	if err != nil {
+		return fmt.Errorf("failed to open session store: %w", err)
+	}
+
+	sess, err := store.Load(id)
+	if err != nil {
+		return err
+	}
+	if sess.Status != "running" {
+		return fmt.Errorf("session %s is not running (status: %s)", id, sess.Status)
+	}
+
+	req := &guest.ExecRequest{
+		Args: args[1:],
+		Dir:  execWorkdir,
+		Env:  execEnv,
+		User: execUser,
+	}
+	Debug("Exec in %s: %v", id, req.Args)
+
+	code, err := vm.Exec(id, req, os.Stdout, os.Stderr)
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		// Pass the guest exit code through to the caller
+		os.Exit(code)
+	}
+	return nil
+}
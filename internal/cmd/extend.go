@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/faize-ai/faize/internal/vm"
+	"github.com/spf13/cobra"
+)
+
+// timeoutWarning is how long before a session's timeout its user is warned
+const timeoutWarning = 5 * time.Minute
+
+var extendCmd = &cobra.Command{
+	Use:   "extend <session-id> <duration>",
+	Short: "Extend a running session's timeout",
+	Long: `Push out the deadline at which a running session's timeout stops it, e.g.
+when a task needs longer than the timeout it was started with. The duration
+is added to the current deadline.
+
+Examples:
+  faize extend abc123 1h
+  faize extend auth-refactor 30m`,
+	Args: cobra.ExactArgs(2),
+	RunE: runExtend,
+}
+
+func init() {
+	rootCmd.AddCommand(extendCmd)
+}
+
+func runExtend(cmd *cobra.Command, args []string) error {
+	by, err := time.ParseDuration(args[1])
+	if err != nil {
+		return fmt.Errorf("invalid duration '%s': %w", args[1], err)
+	}
+	if by <= 0 {
+		return fmt.Errorf("duration must be positive")
+	}
+	store, err := session.NewStore()
+	if err != nil {
+		return fmt.Errorf("failed to open session store: %w", err)
+	}
+	id, err := store.Resolve(args[0])
+	if err != nil {
+		return err
+	}
+
+	var deadline time.Time
+	var extendErr error
+	_, err = store.Update(id, func(s *session.Session) bool {
+		switch {
+		case s.Status != "created" && s.Status != "running":
+			extendErr = fmt.Errorf("session %s is not running (status: %s)", id, s.Status)
+		case s.Deadline == nil:
+			extendErr = fmt.Errorf("session %s has no timeout", id)
+		default:
+			deadline = s.Deadline.Add(by)
+			s.Deadline = &deadline
+			return true
+		}
+		return false
+	})
+	if err != nil {
+		return err
+	}
+	if extendErr != nil {
+		return extendErr
+	}
+	fmt.Printf("Session %s now times out at %s (in %s)\n", id,
+		deadline.Format("2006-01-02 15:04:05"), time.Until(deadline).Round(time.Second))
+	return nil
+}
+
+// enforceDeadline calls warn timeoutWarning before a session's deadline and
+// expire once it passes. The deadline is read again from the session record
+// when either is due, so faize extend can push it out while the session
+// runs; expire is told whether it was. Sessions with less than
+// timeoutWarning to run aren't warned. The returned function cancels
+// enforcement.
+func enforceDeadline(id string, deadline time.Time, warn func(left time.Duration), expire func(extended bool)) func() {
+	done := make(chan struct{})
+	go func() {
+		started := time.Now()
+		extended := false
+		var warned time.Time // the deadline last warned about
+		for {
+			wake := deadline
+			if !warned.Equal(deadline) && deadline.Sub(started) > timeoutWarning {
+				wake = deadline.Add(-timeoutWarning)
+			}
+			timer := time.NewTimer(time.Until(wake))
+			select {
+			case <-done:
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			if later := loadDeadline(id); later.After(deadline) {
+				Debug("Session %s extended until %s", id, later.Format(time.TimeOnly))
+				deadline = later
+				extended = true
+				continue
+			}
+			if left := time.Until(deadline); left > 0 {
+				warned = deadline
+				warn(left.Round(time.Second))
+				continue
+			}
+			expire(extended)
+			return
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// warnTimeout returns the warning enforceDeadline gives a session's user
+func warnTimeout(manager vm.Manager, id string) func(left time.Duration) {
+	return func(left time.Duration) {
+		warnSession(manager, id, fmt.Sprintf("faize: session %s times out in %s; run 'faize extend %s 1h' to keep it running", id, left, id))
+	}
+}
+
+// loadDeadline returns a session's recorded deadline, or the zero time
+func loadDeadline(id string) time.Time {
+	store, err := session.NewStore()
+	if err != nil {
+		return time.Time{}
+	}
+	sess, err := store.Load(id)
+	if err != nil || sess.Deadline == nil {
+		return time.Time{}
+	}
+	return *sess.Deadline
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/faize-ai/faize/internal/changeset"
+	"github.com/faize-ai/faize/internal/config"
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/faize-ai/faize/internal/vm"
+)
+
+// minIdleTimeout is the shortest accepted idle timeout
+const minIdleTimeout = time.Minute
+
+// idleRoot is a writable mount whose changes count as session activity
+type idleRoot struct {
+	path  string
+	rules *changeset.IgnoreRules
+}
+
+// idleCheckInterval is how often a session with the given idle timeout is
+// checked for activity
+func idleCheckInterval(idle time.Duration) time.Duration {
+	return min(idle/4, time.Minute)
+}
+
+// idleRoots returns a session's writable mounts, leaving out the directories
+// change tracking ignores
+func idleRoots(mounts []session.VMMount, cfg *config.Config) []idleRoot {
+	var roots []idleRoot
+	for _, m := range mounts {
+		if m.ReadOnly {
+			continue
+		}
+		rules, err := changeset.NewIgnoreRules(m.Source, cfg.Changeset.Profiles, cfg.Changeset.Ignore)
+		if err != nil {
+			Debug("Failed to load ignore rules for %s: %v", m.Source, err)
+		}
+		roots = append(roots, idleRoot{path: m.Source, rules: rules})
+	}
+	return roots
+}
+
+// sessionIdleRoots returns the idle roots of a session's mounts, with its
+// project's ignore rules
+func sessionIdleRoots(sess *session.Session) []idleRoot {
+	cfg, err := config.Load(sess.ProjectDir)
+	if err != nil {
+		cfg = &config.Config{}
+	}
+	return idleRoots(sess.Mounts, cfg)
+}
+
+// stopWhenIdle stops a session's VM once it has been idle for idle, by the
+// measure of watchIdle, and records that in idled. The returned function
+// stops watching.
+func stopWhenIdle(manager vm.Manager, id, consoleLog string, roots []idleRoot, idle time.Duration, idled *atomic.Bool) func() {
+	return watchIdle(consoleLog, roots, idle, nil, func() {
+		idled.Store(true)
+		fmt.Printf("\nSession idle for %s. Stopping...\n", idle)
+		_ = manager.Stop(id)
+	})
+}
+
+// watchIdle calls expire once a session has gone idle long without console
+// output or changes under roots, and without active reporting true, if
+// given. The mounts are only walked once the console has been quiet for that
+// long. The returned function stops watching.
+func watchIdle(consoleLog string, roots []idleRoot, idle time.Duration, active func() bool, expire func()) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(idleCheckInterval(idle))
+		defer ticker.Stop()
+		last := time.Now()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			if active != nil && active() {
+				last = time.Now()
+				continue
+			}
+			if info, err := os.Stat(consoleLog); err == nil && info.ModTime().After(last) {
+				last = info.ModTime()
+			}
+			if time.Since(last) < idle {
+				continue
+			}
+			for _, root := range roots {
+				if changed, err := changeset.ChangedSince(root.path, last, root.rules); err != nil {
+					Debug("Failed to check %s for changes: %v", root.path, err)
+				} else if changed {
+					last = time.Now()
+					break
+				}
+			}
+			if time.Since(last) >= idle {
+				expire()
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
@@ -0,0 +1,284 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/faize-ai/faize/internal/changeset"
+	"github.com/faize-ai/faize/internal/network"
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/spf13/cobra"
+)
+
+var inspectJSON bool
+
+// inspectReport is what faize inspect --json prints: the session record and
+// what is derived from it
+type inspectReport struct {
+	*session.Session
+	Mode          string          `json:"mode"` // "claude" or "shell"
+	UptimeSeconds float64         `json:"uptime_seconds"`
+	NetworkPolicy *network.Policy `json:"network_policy"`
+	Paths         inspectPaths    `json:"paths"`
+}
+
+// inspectPaths are a session's files on the host; sockets are only set
+// while they exist
+type inspectPaths struct {
+	SessionDir    string `json:"session_dir"`
+	Bootstrap     string `json:"bootstrap"`
+	ConsoleLog    string `json:"console_log"`
+	ConsoleSocket string `json:"console_socket,omitempty"`
+	ExecSocket    string `json:"exec_socket,omitempty"`
+	Capture       string `json:"capture,omitempty"`
+}
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect <session-id>",
+	Short: "Show detailed information about a session",
+	Long: `Show detailed information about a faize session: its resources, timestamps
+and exit reason, every VirtioFS share with its tag and mode, the network
+policy its allowlist resolves to, and the host paths of its session
+directory, bootstrap share, console log, and sockets. Useful for debugging
+guest mount failures.
+
+With --json, print the session record with the resolved network policy and
+paths added.
+
+Examples:
+  faize inspect abc123
+  faize inspect abc123 --json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runInspect,
+}
+
+func init() {
+	inspectCmd.Flags().BoolVar(&inspectJSON, "json", false, "output in JSON format")
+	rootCmd.AddCommand(inspectCmd)
+}
+
+func runInspect(cmd *cobra.Command, args []string) error {
+	store, err := session.NewStore()
+	if err != nil {
+		return fmt.Errorf("failed to open session store: %w", err)
+	}
+
+	sess, err := store.Load(resolveSessionRef(args[0]))
+	if err != nil {
+		return err
+	}
+
+	// Don't print the upstream proxy's password
+	if upstream, err := url.Parse(sess.Proxy); err == nil && sess.Proxy != "" {
+		sess.Proxy = upstream.Redacted()
+	}
+
+	now := time.Now()
+	mode := "shell"
+	if sess.ClaudeMode {
+		mode = "claude"
+	}
+	policy := network.Parse(sess.Network)
+	paths := inspectSessionPaths(store.Dir(), sess.ID)
+
+	if inspectJSON {
+		end := now
+		if sess.StoppedAt != nil {
+			end = *sess.StoppedAt
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(inspectReport{
+			Session:       sess,
+			Mode:          mode,
+			UptimeSeconds: max(end.Sub(sess.StartedAt), 0).Seconds(),
+			NetworkPolicy: policy,
+			Paths:         paths,
+		})
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "ID:\t%s\n", sess.ID)
+	if sess.Name != "" {
+		_, _ = fmt.Fprintf(w, "Name:\t%s\n", sess.Name)
+	}
+	if len(sess.Labels) > 0 {
+		_, _ = fmt.Fprintf(w, "Labels:\t%s\n", session.FormatLabels(sess.Labels))
+	}
+	_, _ = fmt.Fprintf(w, "Project:\t%s\n", sess.ProjectDir)
+	_, _ = fmt.Fprintf(w, "Mode:\t%s\n", mode)
+	if sess.Status == "paused" && sess.AutoSuspended {
+		_, _ = fmt.Fprintf(w, "Status:\t%s (idle, resumes on attach)\n", sess.Status)
+	} else {
+		_, _ = fmt.Fprintf(w, "Status:\t%s\n", sess.Status)
+	}
+	if sess.MaxCPUs > sess.CPUs || (sess.MaxMemory != "" && sess.MaxMemory != sess.Memory) {
+		_, _ = fmt.Fprintf(w, "Resources:\t%d CPUs, %s (resizable up to %d CPUs, %s)\n", sess.CPUs, sess.Memory, sess.MaxCPUs, sess.MaxMemory)
+	} else {
+		_, _ = fmt.Fprintf(w, "Resources:\t%d CPUs, %s\n", sess.CPUs, sess.Memory)
+	}
+	if sess.WriteQuota != "" {
+		_, _ = fmt.Fprintf(w, "Write quota:\t%s per writable mount\n", sess.WriteQuota)
+	}
+	if sess.Overlay != "" {
+		_, _ = fmt.Fprintf(w, "Rootfs overlay:\t%s (kept for the project's later sessions)\n", sess.Overlay)
+	}
+	if sess.Snapshot != "" {
+		_, _ = fmt.Fprintf(w, "Rootfs snapshot:\t%s\n", sess.Snapshot)
+	}
+	if sess.Kernel != "" {
+		_, _ = fmt.Fprintf(w, "Kernel:\t%s\n", sess.Kernel)
+	}
+	if sess.Rootfs != "" {
+		_, _ = fmt.Fprintf(w, "Rootfs:\t%s\n", sess.Rootfs)
+	}
+	if sess.Image != "" {
+		_, _ = fmt.Fprintf(w, "Image:\t%s\n", sess.Image)
+	}
+	if sess.Flavor != "" {
+		_, _ = fmt.Fprintf(w, "Flavor:\t%s\n", sess.Flavor)
+	}
+	if sess.Home != "" {
+		_, _ = fmt.Fprintf(w, "Home:\t%s (kept for the project's later sessions)\n", sess.Home)
+	}
+	if sess.Disk != "" {
+		_, _ = fmt.Fprintf(w, "Scratch disk:\t%s at /scratch\n", sess.Disk)
+	}
+	_, _ = fmt.Fprintf(w, "Started:\t%s\n", sess.StartedAt.Format("2006-01-02 15:04:05"))
+	if sess.StoppedAt != nil {
+		_, _ = fmt.Fprintf(w, "Stopped:\t%s\n", sess.StoppedAt.Format("2006-01-02 15:04:05"))
+	}
+	if uptime := formatUptime(sess, now); uptime != "-" {
+		_, _ = fmt.Fprintf(w, "Uptime:\t%s\n", uptime)
+	}
+	if sess.Timeout != "" {
+		_, _ = fmt.Fprintf(w, "Timeout:\t%s\n", sess.Timeout)
+	}
+	if sess.IdleTimeout != "" {
+		_, _ = fmt.Fprintf(w, "Idle timeout:\t%s\n", sess.IdleTimeout)
+	}
+	if sess.AutoSuspend != "" {
+		_, _ = fmt.Fprintf(w, "Auto-suspend:\t%s\n", sess.AutoSuspend)
+	}
+	if sess.Deadline != nil && (sess.Status == "created" || sess.Status == "running") {
+		_, _ = fmt.Fprintf(w, "Deadline:\t%s (in %s)\n", sess.Deadline.Format("2006-01-02 15:04:05"),
+			max(sess.Deadline.Sub(now), 0).Round(time.Second))
+	}
+	if sess.ExitReason != "" {
+		_, _ = fmt.Fprintf(w, "Exit reason:\t%s\n", sess.ExitReason)
+	}
+	if len(sess.Attaches) > 0 {
+		_, _ = fmt.Fprintf(w, "Attached:\t%s in total, last at %s\n", sess.AttachedTime(now).Round(time.Second),
+			sess.Attaches[len(sess.Attaches)-1].AttachedAt.Format("2006-01-02 15:04:05"))
+	}
+	if sess.PeakCPU > 0 || sess.PeakMemory > 0 {
+		_, _ = fmt.Fprintf(w, "Peak usage:\t%.0f%% CPU, %s memory\n", sess.PeakCPU, changeset.FormatSize(int64(sess.PeakMemory)))
+	}
+	for _, p := range sess.Ports {
+		_, _ = fmt.Fprintf(w, "Port:\t127.0.0.1:%d -> %d\n", p.HostPort, p.GuestPort)
+	}
+	if sess.CaptureNetwork {
+		capture := paths.Capture
+		if capture == "" {
+			capture = "faize network pcap " + sess.ID
+		}
+		_, _ = fmt.Fprintf(w, "Capture:\t%s\n", capture)
+	}
+	for _, entry := range sess.Hosts {
+		_, _ = fmt.Fprintf(w, "Host:\t%s -> %s\n", entry.Name, entry.IP)
+	}
+	if sess.Proxy != "" {
+		_, _ = fmt.Fprintf(w, "Proxy:\t%s\n", sess.Proxy)
+	}
+	if sess.NetLimit != 0 {
+		_, _ = fmt.Fprintf(w, "Net limit:\t%s\n", network.FormatRate(sess.NetLimit))
+	}
+	_ = w.Flush()
+
+	fmt.Println("\nMounts:")
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "TAG\tSOURCE\tTARGET\tMODE\tKIND")
+	printInspectMounts(w, sess.SystemMounts, "system")
+	printInspectMounts(w, sess.Mounts, "user")
+	_ = w.Flush()
+
+	switch {
+	case policy.AllowAll:
+		fmt.Println("\nNetwork policy: all traffic allowed")
+	case policy.Blocked:
+		fmt.Println("\nNetwork policy: no network access")
+	default:
+		fmt.Printf("\nNetwork policy: %d allowlist entries from %s\n", policy.Len(), strings.Join(sess.Network, ", "))
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "KIND\tENTRY")
+		for _, d := range policy.Domains {
+			_, _ = fmt.Fprintf(w, "domain\t%s\n", d)
+		}
+		for _, d := range policy.Wildcards {
+			_, _ = fmt.Fprintf(w, "wildcard\t%s\n", d)
+		}
+		for _, c := range policy.CIDRs {
+			_, _ = fmt.Fprintf(w, "ip range\t%s\n", c)
+		}
+		for _, r := range policy.Ports {
+			_, _ = fmt.Fprintf(w, "port\t%s\n", r)
+		}
+		_ = w.Flush()
+	}
+
+	fmt.Println("\nPaths:")
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "Session dir:\t%s\n", paths.SessionDir)
+	_, _ = fmt.Fprintf(w, "Bootstrap:\t%s\n", paths.Bootstrap)
+	_, _ = fmt.Fprintf(w, "Console log:\t%s\n", paths.ConsoleLog)
+	if paths.ConsoleSocket != "" {
+		_, _ = fmt.Fprintf(w, "Console socket:\t%s\n", paths.ConsoleSocket)
+	}
+	if paths.ExecSocket != "" {
+		_, _ = fmt.Fprintf(w, "Exec socket:\t%s\n", paths.ExecSocket)
+	}
+	_ = w.Flush()
+
+	return nil
+}
+
+// inspectSessionPaths returns the host paths of a session in the store directory
+func inspectSessionPaths(storeDir, id string) inspectPaths {
+	dir := filepath.Join(storeDir, id)
+	paths := inspectPaths{
+		SessionDir: dir,
+		Bootstrap:  filepath.Join(dir, "bootstrap"),
+		ConsoleLog: filepath.Join(dir, "console.log"),
+	}
+	exists := func(path string) string {
+		if _, err := os.Stat(path); err != nil {
+			return ""
+		}
+		return path
+	}
+	paths.ConsoleSocket = exists(filepath.Join(storeDir, id+".sock"))
+	paths.ExecSocket = exists(filepath.Join(storeDir, id+".exec.sock"))
+	paths.Capture = exists(filepath.Join(dir, network.CaptureFile))
+	return paths
+}
+
+// printInspectMounts writes one row per mount to the tabwriter
+func printInspectMounts(w *tabwriter.Writer, mounts []session.VMMount, kind string) {
+	for _, m := range mounts {
+		mode := "rw"
+		if m.ReadOnly {
+			mode = "ro"
+		}
+		tag := m.Tag
+		if tag == "" {
+			tag = "-"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", tag, m.Source, m.Target, mode, kind)
+	}
+}
@@ -39,12 +39,9 @@ func runKill(cmd *cobra.Command, args []string) error {
 	}
 
 	// Create VM manager for stopping running sessions
-	var manager vm.Manager
-	vzManager, err := vm.NewVZManager()
+	manager, err := vm.NewManager()
 	if err != nil {
 		manager = vm.NewStubManager()
-	} else {
-		manager = vzManager
 	}
 
 	removedCount := 0
@@ -61,7 +58,7 @@ func runKill(cmd *cobra.Command, args []string) error {
 				removedCount++
 			}
 
-		case "running":
+		case "running", "paused":
 			if killForce {
 				// Stop the VM first
 				if err := manager.Stop(sess.ID); err != nil {
@@ -74,7 +71,7 @@ func runKill(cmd *cobra.Command, args []string) error {
 				if err := store.Delete(sess.ID); err != nil {
 					fmt.Printf("Warning: failed to delete session %s: %v\n", sess.ID, err)
 				} else {
-					fmt.Printf("Stopped and removed session: %s (running)\n", sess.ID)
+					fmt.Printf("Stopped and removed session: %s (%s)\n", sess.ID, sess.Status)
 					removedCount++
 				}
 			} else {
@@ -88,7 +85,7 @@ func runKill(cmd *cobra.Command, args []string) error {
 	}
 
 	if skippedRunning > 0 {
-		fmt.Printf("Skipped %d running session(s). Use --force to remove them.\n", skippedRunning)
+		fmt.Printf("Skipped %d running or paused session(s). Use --force to remove them.\n", skippedRunning)
 	}
 
 	if removedCount == 0 {
@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/faize-ai/faize/internal/redact"
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/spf13/cobra"
+)
+
+// logsPollInterval is how often faize logs -f checks for new output
+const logsPollInterval = 250 * time.Millisecond
+
+var (
+	logsFollow bool
+	logsGrep   string
+	logsBoot   bool
+)
+
+// ansiEscape matches terminal control sequences, ignored when matching --grep
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[@-Z\\-_]`)
+
+var logsCmd = &cobra.Command{
+	Use:   "logs <session-id>",
+	Short: "Show a session's console log",
+	Long: `Show everything a session printed to its console, including output that was
+produced while no client was attached. The log is kept after the session
+exits, in ~/.faize/sessions/<id>/console.log, with tokens redacted.
+
+With -f, keep printing new output until the session stops. With --grep,
+print only the lines matching a regular expression (terminal escape codes
+are ignored when matching).
+
+With --boot, show the guest agent's boot log instead: status messages and
+warnings from starting the session, which are kept off the console unless
+the session was started with --debug.
+
+Examples:
+  faize logs abc123
+  faize logs -f abc123
+  faize logs abc123 --grep 'error|panic'
+  faize logs --boot abc123`,
+	Args: cobra.ExactArgs(1),
+	RunE: runLogs,
+}
+
+func init() {
+	addLogsFlags(logsCmd)
+	rootCmd.AddCommand(logsCmd)
+}
+
+// addLogsFlags registers the logs flags on a command (faize logs and faize session logs)
+func addLogsFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVarP(&logsFollow, "follow", "f", false, "keep printing new output until the session stops")
+	cmd.Flags().StringVar(&logsGrep, "grep", "", "only print lines matching this regular expression")
+	cmd.Flags().BoolVar(&logsBoot, "boot", false, "show the guest boot log instead of the console log")
+}
+
+func runLogs(cmd *cobra.Command, args []string) error {
+	id := resolveSessionRef(args[0])
+
+	var pattern *regexp.Regexp
+	if logsGrep != "" {
+		var err error
+		if pattern, err = regexp.Compile(logsGrep); err != nil {
+			return fmt.Errorf("invalid --grep pattern: %w", err)
+		}
+	}
+
+	store, err := session.NewStore()
+	if err != nil {
+		return fmt.Errorf("failed to open session store: %w", err)
+	}
+	if _, err := store.Load(id); err != nil {
+		return err
+	}
+
+	logName, logPath := "console", filepath.Join(store.Dir(), id, "console.log")
+	if logsBoot {
+		logName, logPath = "boot", filepath.Join(store.Dir(), id, "bootstrap", guest.BootLogFile)
+	}
+	f, err := openSessionLog(store, id, logPath, logsFollow)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no %s log recorded for session %s", logName, id)
+		}
+		return fmt.Errorf("failed to read %s log: %w", logName, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	out := newLogPrinter(os.Stdout, pattern)
+	defer out.Close()
+
+	for {
+		if _, err := io.Copy(out, f); err != nil {
+			return fmt.Errorf("failed to read %s log: %w", logName, err)
+		}
+		if !logsFollow || !sessionRunning(store, id) {
+			return nil
+		}
+		out.Flush()
+		time.Sleep(logsPollInterval)
+	}
+}
+
+// openSessionLog opens a session's log. The session's owner creates it once
+// the VM boots, so with follow it is waited for while the session runs.
+func openSessionLog(store *session.Store, id, path string, follow bool) (*os.File, error) {
+	for {
+		f, err := os.Open(path)
+		if !os.IsNotExist(err) || !follow || !sessionRunning(store, id) {
+			return f, err
+		}
+		time.Sleep(logsPollInterval)
+	}
+}
+
+// sessionRunning reports whether a session may still write to its console log
+func sessionRunning(store *session.Store, id string) bool {
+	sess, err := store.Load(id)
+	return err == nil && (sess.Status == "running" || sess.Status == "created")
+}
+
+// logPrinter writes console log output with tokens redacted, keeping only
+// lines that match a pattern when one is set
+type logPrinter struct {
+	w       *bufio.Writer
+	redact  *redact.Writer
+	pattern *regexp.Regexp
+	line    []byte
+}
+
+func newLogPrinter(w io.Writer, pattern *regexp.Regexp) *logPrinter {
+	bw := bufio.NewWriter(w)
+	return &logPrinter{w: bw, redact: redact.NewWriter(bw, nil), pattern: pattern}
+}
+
+func (p *logPrinter) Write(data []byte) (int, error) {
+	if p.pattern == nil {
+		return p.redact.Write(data)
+	}
+
+	p.line = append(p.line, data...)
+	for {
+		i := bytes.IndexByte(p.line, '\n')
+		if i < 0 {
+			break
+		}
+		p.printIfMatch(p.line[:i+1])
+		p.line = p.line[i+1:]
+	}
+	return len(data), nil
+}
+
+// printIfMatch prints a complete line if it matches, ignoring terminal escapes
+func (p *logPrinter) printIfMatch(line []byte) {
+	plain := ansiEscape.ReplaceAll(line, nil)
+	if p.pattern.Match(plain) {
+		_, _ = p.w.Write(redact.Bytes(line))
+	}
+}
+
+// Flush prints buffered output. A partial line is held back when matching,
+// since the rest of it may still decide whether it matches.
+func (p *logPrinter) Flush() {
+	_ = p.redact.Flush()
+	_ = p.w.Flush()
+}
+
+// Close prints everything left, including a final partial line
+func (p *logPrinter) Close() {
+	if p.pattern != nil && len(p.line) > 0 {
+		p.printIfMatch(p.line)
+		p.line = nil
+	}
+	p.Flush()
+}
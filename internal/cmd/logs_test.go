@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenSessionLog(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	store, err := session.NewStore()
+	require.NoError(t, err)
+	sess := &session.Session{ID: "0123456789ab", Status: "running"}
+	require.NoError(t, store.Save(sess))
+	path := filepath.Join(store.Dir(), sess.ID, "console.log")
+
+	_, err = openSessionLog(store, sess.ID, path, false)
+	assert.True(t, os.IsNotExist(err), "without follow, a missing log is an error")
+
+	// Following a running session waits for its owner to create the log
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = os.MkdirAll(filepath.Dir(path), 0755)
+		_ = os.WriteFile(path, []byte("booted\n"), 0644)
+	}()
+	f, err := openSessionLog(store, sess.ID, path, true)
+	require.NoError(t, err)
+	_ = f.Close()
+
+	sess.Status = "stopped"
+	require.NoError(t, store.Save(sess))
+	_, err = openSessionLog(store, sess.ID, filepath.Join(store.Dir(), sess.ID, "missing.log"), true)
+	assert.True(t, os.IsNotExist(err), "a stopped session's log won't appear")
+}
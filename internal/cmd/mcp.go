@@ -0,0 +1,356 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/faize-ai/faize/internal/api"
+	"github.com/faize-ai/faize/internal/changeset"
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/faize-ai/faize/internal/mcp"
+	"github.com/faize-ai/faize/internal/redact"
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/faize-ai/faize/internal/vm"
+	"github.com/spf13/cobra"
+)
+
+var mcpCmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Serve faize tools over the Model Context Protocol",
+	Long: `Run an MCP server on stdin and stdout, so other agents can start sandboxed
+sessions, run commands and prompts in them, and read what they changed.
+
+Tools:
+  list_sessions   list sessions
+  start_session   start a background session for a project
+  run_prompt      run Claude headless with a prompt in a new session (faize run)
+  exec            run a command in a running session
+  read_diff       show the changes a stopped session made
+  allow_network   add a domain to a running session's allowlist
+  stop_session    stop a session
+
+Register it with an MCP client, for example:
+  claude mcp add faize -- faize mcp`,
+	Args: cobra.NoArgs,
+	RunE: runMCP,
+}
+
+func init() {
+	rootCmd.AddCommand(mcpCmd)
+}
+
+func runMCP(cmd *cobra.Command, args []string) error {
+	store, err := session.NewStore()
+	if err != nil {
+		return fmt.Errorf("failed to open session store: %w", err)
+	}
+	backend := &localBackend{store: store, manager: newSessionManager()}
+
+	// Stdout carries the protocol; anything else faize prints goes to stderr
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	defer func() { os.Stdout = stdout }()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	version := rootCmd.Version
+	if version == "" {
+		version = "dev"
+	}
+	return mcp.NewServer("faize", version, mcpTools(backend)).Serve(ctx, os.Stdin, stdout)
+}
+
+// mcpExecTimeout is how long the exec tool lets a command run unless the call
+// sets a timeout
+const mcpExecTimeout = 10 * time.Minute
+
+// mcpOutputLimit caps how much of a command's stdout and of its stderr a tool
+// result carries
+const mcpOutputLimit = 1 << 20
+
+// cappedBuffer keeps the first mcpOutputLimit bytes written to it and drops
+// the rest
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	truncated bool
+}
+
+func (c *cappedBuffer) Write(p []byte) (int, error) {
+	if room := mcpOutputLimit - c.buf.Len(); len(p) > room {
+		c.buf.Write(p[:room])
+		c.truncated = true
+	} else {
+		c.buf.Write(p)
+	}
+	return len(p), nil
+}
+
+func (c *cappedBuffer) Len() int {
+	return c.buf.Len()
+}
+
+// String returns what was kept, noting whether anything was dropped
+func (c *cappedBuffer) String() string {
+	if c.truncated {
+		return c.buf.String() + "\n[output truncated]"
+	}
+	return c.buf.String()
+}
+
+// mcpTools returns the tools faize mcp serves
+func mcpTools(b *localBackend) []mcp.Tool {
+	return []mcp.Tool{
+		{
+			Name:        "list_sessions",
+			Description: "List faize sessions with their IDs, projects, and status.",
+			InputSchema: json.RawMessage(`{"type":"object","properties":{}}`),
+			Handler: func(ctx context.Context, _ json.RawMessage) (string, error) {
+				sessions, err := b.List()
+				if err != nil {
+					return "", err
+				}
+				var sb strings.Builder
+				for _, s := range sessions {
+					fmt.Fprintf(&sb, "%s\t%s\t%s\n", s.ID, s.Status, s.ProjectDir)
+				}
+				if sb.Len() == 0 {
+					return "No sessions.", nil
+				}
+				return sb.String(), nil
+			},
+		},
+		{
+			Name:        "start_session",
+			Description: "Start a sandboxed Claude session for a project directory in a background VM. Returns the session ID for exec, read_diff, and stop_session.",
+			InputSchema: json.RawMessage(`{"type":"object","properties":{
+				"project":{"type":"string","description":"absolute path of the project directory"},
+				"mounts":{"type":"array","items":{"type":"string"},"description":"additional mounts, as for faize start --mount"},
+				"timeout":{"type":"string","description":"session timeout, e.g. 30m"},
+				"worktree":{"type":"string","description":"run the session in a git worktree with this branch"}},
+				"required":["project"]}`),
+			Handler: func(ctx context.Context, args json.RawMessage) (string, error) {
+				var req api.StartRequest
+				if err := json.Unmarshal(args, &req); err != nil {
+					return "", err
+				}
+				sess, err := b.Start(req)
+				if err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("Started session %s for %s.", sess.ID, sess.ProjectDir), nil
+			},
+		},
+		{
+			Name:        "run_prompt",
+			Description: "Run Claude headless with a prompt in a new sandboxed session for a project, wait for it to finish, and return its output. The session's changes are written to the project; read them with read_diff.",
+			InputSchema: json.RawMessage(`{"type":"object","properties":{
+				"project":{"type":"string","description":"absolute path of the project directory"},
+				"prompt":{"type":"string"},
+				"timeout":{"type":"string","description":"session timeout, e.g. 30m"},
+				"worktree":{"type":"string","description":"run the session in a git worktree with this branch"}},
+				"required":["project","prompt"]}`),
+			Handler: mcpRunPrompt,
+		},
+		{
+			Name:        "exec",
+			Description: "Run a command in a running session, in its project directory, and return its exit code and output.",
+			InputSchema: json.RawMessage(`{"type":"object","properties":{
+				"session":{"type":"string"},
+				"command":{"type":"array","items":{"type":"string"},"description":"the program and its arguments, e.g. [\"sh\",\"-c\",\"npm test\"]"},
+				"workdir":{"type":"string"},
+				"user":{"type":"string","description":"user to run as (default: the session user)"},
+				"timeout":{"type":"string","description":"how long the command may run, e.g. 30m (default 10m)"}},
+				"required":["session","command"]}`),
+			Handler: func(ctx context.Context, args json.RawMessage) (string, error) {
+				var a struct {
+					Session string   `json:"session"`
+					Command []string `json:"command"`
+					Workdir string   `json:"workdir"`
+					User    string   `json:"user"`
+					Timeout string   `json:"timeout"`
+				}
+				if err := json.Unmarshal(args, &a); err != nil {
+					return "", err
+				}
+				if len(a.Command) == 0 {
+					return "", fmt.Errorf("command is required")
+				}
+				timeout := mcpExecTimeout
+				if a.Timeout != "" {
+					d, err := time.ParseDuration(a.Timeout)
+					if err != nil || d <= 0 {
+						return "", fmt.Errorf("invalid timeout %q", a.Timeout)
+					}
+					timeout = d
+				}
+				if _, err := b.running(a.Session); err != nil {
+					return "", err
+				}
+				ctx, cancel := context.WithTimeout(ctx, timeout)
+				defer cancel()
+				var out, errOut cappedBuffer
+				code, err := vm.ExecContext(ctx, a.Session, &guest.ExecRequest{Args: a.Command, Dir: a.Workdir, User: a.User}, &out, &errOut)
+				if errors.Is(err, context.DeadlineExceeded) {
+					return "", fmt.Errorf("command timed out after %s", timeout)
+				}
+				if err != nil {
+					return "", err
+				}
+				result := fmt.Sprintf("exit code %d\n%s", code, out.String())
+				if errOut.Len() > 0 {
+					result += "\nstderr:\n" + errOut.String()
+				}
+				return redact.String(result), nil
+			},
+		},
+		{
+			Name:        "read_diff",
+			Description: "Show the files, guest changes, and network activity a stopped session recorded.",
+			InputSchema: json.RawMessage(`{"type":"object","properties":{"session":{"type":"string"}},"required":["session"]}`),
+			Handler: func(ctx context.Context, args json.RawMessage) (string, error) {
+				var a struct {
+					Session string `json:"session"`
+				}
+				if err := json.Unmarshal(args, &a); err != nil {
+					return "", err
+				}
+				cs, err := b.Changeset(a.Session)
+				if err != nil {
+					return "", err
+				}
+				var sb strings.Builder
+				changeset.PrintSummary(&sb, cs)
+				return sb.String(), nil
+			},
+		},
+		{
+			Name:        "allow_network",
+			Description: "Add a domain, wildcard, preset, or IP range to a running session's network allowlist, as faize allow.",
+			InputSchema: json.RawMessage(`{"type":"object","properties":{"session":{"type":"string"},"entry":{"type":"string"}},"required":["session","entry"]}`),
+			Handler: func(ctx context.Context, args json.RawMessage) (string, error) {
+				var a struct {
+					Session string `json:"session"`
+					Entry   string `json:"entry"`
+				}
+				if err := json.Unmarshal(args, &a); err != nil {
+					return "", err
+				}
+				return b.Allow(a.Session, a.Entry)
+			},
+		},
+		{
+			Name:        "stop_session",
+			Description: "Stop a session, letting the guest shut down cleanly unless force is set.",
+			InputSchema: json.RawMessage(`{"type":"object","properties":{"session":{"type":"string"},"force":{"type":"boolean"}},"required":["session"]}`),
+			Handler: func(ctx context.Context, args json.RawMessage) (string, error) {
+				var a struct {
+					Session string `json:"session"`
+					Force   bool   `json:"force"`
+				}
+				if err := json.Unmarshal(args, &a); err != nil {
+					return "", err
+				}
+				if err := b.Stop(a.Session, a.Force); err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("Stopped session %s.", a.Session), nil
+			},
+		},
+	}
+}
+
+// mcpRunPrompt runs faize run with --output json-stream and reports Claude's
+// output with the session's ID and outcome from the events
+func mcpRunPrompt(ctx context.Context, args json.RawMessage) (string, error) {
+	var a struct {
+		Project  string `json:"project"`
+		Prompt   string `json:"prompt"`
+		Timeout  string `json:"timeout"`
+		Worktree string `json:"worktree"`
+	}
+	if err := json.Unmarshal(args, &a); err != nil {
+		return "", err
+	}
+	if a.Project == "" || strings.TrimSpace(a.Prompt) == "" {
+		return "", fmt.Errorf("project and prompt are required")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate the faize executable: %w", err)
+	}
+
+	runArgs := []string{"run", "-p", "-", "--project", a.Project, "--output", "json-stream"}
+	if a.Timeout != "" {
+		runArgs = append(runArgs, "--timeout", a.Timeout)
+	}
+	if a.Worktree != "" {
+		runArgs = append(runArgs, "--worktree", a.Worktree)
+	}
+	if cfgFile != "" {
+		runArgs = append(runArgs, "--config", cfgFile)
+	}
+	var out cappedBuffer
+	child := exec.CommandContext(ctx, exe, runArgs...)
+	child.Stdin = strings.NewReader(a.Prompt)
+	child.Stdout = &out
+	// A cancelled call interrupts faize run, so it stops its session
+	child.Cancel = func() error { return child.Process.Signal(os.Interrupt) }
+	child.WaitDelay = 30 * time.Second
+	stderr, err := child.StderrPipe()
+	if err != nil {
+		return "", fmt.Errorf("failed to run faize: %w", err)
+	}
+	if err := child.Start(); err != nil {
+		return "", fmt.Errorf("failed to run faize: %w", err)
+	}
+
+	// Stderr has the events as JSON lines, among Claude's stderr and warnings
+	var sessionID, outcome string
+	var other cappedBuffer
+	scanner := bufio.NewScanner(stderr)
+	scanner.Buffer(make([]byte, 64*1024), mcpOutputLimit)
+	for scanner.Scan() {
+		var ev streamEvent
+		if json.Unmarshal(scanner.Bytes(), &ev) != nil || ev.Event == "" {
+			fmt.Fprintln(&other, scanner.Text())
+			continue
+		}
+		switch ev.Event {
+		case "created":
+			sessionID = ev.Session
+		case "summary":
+			outcome = fmt.Sprintf("exit code %v, %v file(s) changed, %v connection(s) denied",
+				ev.Data["exit_code"], ev.Data["files_changed"], ev.Data["network_denied"])
+		}
+	}
+	// Keep the pipe drained past an overlong line so faize run doesn't block
+	_, _ = io.Copy(io.Discard, stderr)
+	err = child.Wait()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return "", fmt.Errorf("failed to run faize: %w", err)
+	}
+
+	result := out.String()
+	if sessionID != "" {
+		result += fmt.Sprintf("\n\nSession %s: %s", sessionID, outcome)
+	}
+	if exitErr != nil {
+		if other.Len() > 0 {
+			result += "\n\nstderr:\n" + strings.TrimSuffix(other.String(), "\n")
+		}
+		return "", fmt.Errorf("%s", strings.TrimSpace(result))
+	}
+	return result, nil
+}
@@ -0,0 +1,258 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/faize-ai/faize/internal/config"
+	"github.com/faize-ai/faize/internal/doctor"
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/faize-ai/faize/internal/network"
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/faize-ai/faize/internal/vm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	networkPcapOutput  string
+	networkTestSession string
+)
+
+var networkCmd = &cobra.Command{
+	Use:     "network",
+	Aliases: []string{"net"},
+	Short:   "Inspect session networking",
+	Long: `Inspect the network activity of faize sessions.
+
+Commands:
+  pcap     Export a session's packet capture
+  test     Explain whether a session can reach a host`,
+}
+
+var networkPcapCmd = &cobra.Command{
+	Use:   "pcap <session-id>",
+	Short: "Export a session's packet capture",
+	Long: `Export the traffic recorded for a session started with --capture-network
+(or --pcap).
+
+The guest runs tcpdump on all interfaces, rotating through bounded files in the
+session's bootstrap share. They are merged into a single pcap, oldest first;
+when the session ends the merged capture is also saved as capture.pcap in
+its session directory.
+Packets dropped by the allowlist firewall never leave the guest, so they do not
+appear in the capture; see 'faize session events' for denied connections.
+
+Examples:
+  faize network pcap abc123                     # writes faize-abc123.pcap
+  faize network pcap abc123 -o /tmp/fetch.pcap
+  faize network pcap abc123 -o - | wireshark -k -i -`,
+	Args: cobra.ExactArgs(1),
+	RunE: runNetworkPcap,
+}
+
+var networkTestCmd = &cobra.Command{
+	Use:   "test <host>[:port]",
+	Short: "Explain whether a session can reach a host",
+	Long: `Check a host against a running session's network policy and print a
+table of pass/warn/fail results, followed by a fix for each problem:
+
+  allowlist    the entry that allows host:port, or why none does
+  enforcement  how the session enforces its allowlist
+  dns          what the host resolves to in the guest
+  firewall     the guest iptables rule that accepts each address
+               (sessions without the host egress proxy)
+  connect      a curl from the guest, with the egress proxy's decision
+
+The port defaults to 443. Without --session the most recently started
+running session is used; with no running session, only the allowlist from
+the config is checked.
+
+Exits non-zero if any check fails.
+
+Examples:
+  faize net test registry.npmjs.org
+  faize net test db.internal:5432 --session abc123`,
+	Args: cobra.ExactArgs(1),
+	RunE: runNetworkTest,
+}
+
+func init() {
+	networkPcapCmd.Flags().StringVarP(&networkPcapOutput, "output", "o", "", "output file, or - for stdout (default: faize-<session-id>.pcap)")
+	networkTestCmd.Flags().StringVarP(&networkTestSession, "session", "s", "", "session to test (default: the most recently started running session)")
+	networkCmd.AddCommand(networkPcapCmd)
+	networkCmd.AddCommand(networkTestCmd)
+	rootCmd.AddCommand(networkCmd)
+}
+
+// saveSessionCapture merges an ended session's rotated capture files into
+// capture.pcap in its session directory
+func saveSessionCapture(sess *session.Session) {
+	if !sess.CaptureNetwork {
+		return
+	}
+	store, err := session.NewStore()
+	if err != nil {
+		return
+	}
+	sessionDir := filepath.Join(store.Dir(), sess.ID)
+	files, err := network.CaptureFiles(filepath.Join(sessionDir, "bootstrap"))
+	if err != nil || len(files) == 0 {
+		return
+	}
+
+	path := filepath.Join(sessionDir, network.CaptureFile)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		Debug("Failed to create %s: %v", path, err)
+		return
+	}
+	err = network.MergePcap(f, files)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		Debug("Failed to save packet capture: %v", err)
+		_ = os.Remove(path)
+		return
+	}
+	fmt.Printf("Packet capture: %s\n", path)
+}
+
+func runNetworkTest(cmd *cobra.Command, args []string) error {
+	host, port := args[0], 443
+	if h, p, err := net.SplitHostPort(args[0]); err == nil {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 1 || n > 65535 {
+			return fmt.Errorf("invalid port in %q", args[0])
+		}
+		host, port = h, n
+	}
+
+	store, err := session.NewStore()
+	if err != nil {
+		return fmt.Errorf("failed to open session store: %w", err)
+	}
+	sess, err := networkTestTarget(store)
+	if err != nil {
+		return err
+	}
+
+	env := doctor.NetworkEnv{Host: host, Port: port}
+	if sess == nil {
+		cwd, _ := os.Getwd()
+		cfg, err := config.Load(cwd)
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		env.Policy = network.Parse(cfg.Networks)
+	} else {
+		fmt.Printf("Session: %s\n\n", sess.ID)
+		bootstrapDir := filepath.Join(store.Dir(), sess.ID, "bootstrap")
+		agentCfg, err := guest.ReadConfig(filepath.Join(bootstrapDir, guest.ConfigFile))
+		if err != nil {
+			return fmt.Errorf("failed to read the session's agent config: %w", err)
+		}
+		env.SessionID = sess.ID
+		env.Policy = network.Parse(sess.Network)
+		env.Agent = agentCfg
+		env.Run = func(ctx context.Context, args ...string) (string, int, error) {
+			var out bytes.Buffer
+			code, err := vm.Exec(sess.ID, &guest.ExecRequest{Args: args, User: "root"}, &out, &out)
+			return out.String(), code, err
+		}
+		if agentCfg.EgressPort != 0 {
+			env.ProxyLog = func() string {
+				data, _ := os.ReadFile(filepath.Join(bootstrapDir, network.ProxyLogFile))
+				return string(data)
+			}
+		}
+	}
+
+	results := doctor.CheckNetwork(cmd.Context(), env)
+	doctor.Print(os.Stdout, results)
+	if doctor.Failed(results) {
+		return fmt.Errorf("%s can't be reached", net.JoinHostPort(host, strconv.Itoa(port)))
+	}
+	return nil
+}
+
+// networkTestTarget returns the session named by --session, or the most
+// recently started running one; nil if none is running
+func networkTestTarget(store *session.Store) (*session.Session, error) {
+	if networkTestSession != "" {
+		sess, err := store.Load(networkTestSession)
+		if err != nil {
+			return nil, err
+		}
+		if sess.Status != "running" {
+			return nil, fmt.Errorf("session %s is not running (status: %s)", sess.ID, sess.Status)
+		}
+		return sess, nil
+	}
+
+	sessions, err := store.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	var latest *session.Session
+	for _, sess := range sessions {
+		if sess.Status == "running" && !sess.Warm && (latest == nil || sess.StartedAt.After(latest.StartedAt)) {
+			latest = sess
+		}
+	}
+	return latest, nil
+}
+
+func runNetworkPcap(cmd *cobra.Command, args []string) error {
+	store, err := session.NewStore()
+	if err != nil {
+		return fmt.Errorf("failed to open session store: %w", err)
+	}
+	id := resolveSessionRef(args[0])
+	sess, err := store.Load(id)
+	if err != nil {
+		return err
+	}
+
+	files, err := network.CaptureFiles(filepath.Join(store.Dir(), id, "bootstrap"))
+	if err != nil {
+		return fmt.Errorf("failed to list capture files: %w", err)
+	}
+	if len(files) == 0 {
+		if !sess.CaptureNetwork {
+			return fmt.Errorf("session %s was not started with --capture-network", id)
+		}
+		return fmt.Errorf("no traffic captured for session %s", id)
+	}
+
+	if networkPcapOutput == "-" {
+		return network.MergePcap(os.Stdout, files)
+	}
+
+	output := networkPcapOutput
+	if output == "" {
+		output = fmt.Sprintf("faize-%s.pcap", id)
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", output, err)
+	}
+	if err := network.MergePcap(f, files); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", output, err)
+	}
+
+	if sess.Status == "running" {
+		fmt.Fprintln(os.Stderr, "Note: the session is still running; the capture may be incomplete.")
+	}
+	fmt.Printf("Wrote %s (%d capture file(s))\n", output, len(files))
+	return nil
+}
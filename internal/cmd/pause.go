@@ -0,0 +1,218 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/faize-ai/faize/internal/vm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	resumeAttach bool
+	resumeDaemon bool
+)
+
+var pauseCmd = &cobra.Command{
+	Use:   "pause <session-id>",
+	Short: "Save a running session to disk",
+	Long: `Pause a detached session: the VM is suspended, its memory and device state
+are saved to the session directory, and the VM is released. Claude keeps its
+in-memory state and picks up where it left off after 'faize resume'.
+
+Only sessions started with --detach can be paused. Saving VM state requires
+macOS 14 or newer on Apple silicon.
+
+Examples:
+  faize pause abc123
+  faize resume abc123`,
+	Args: cobra.ExactArgs(1),
+	RunE: runPause,
+}
+
+var resumeCmd = &cobra.Command{
+	Use:   "resume <session-id>",
+	Short: "Resume a paused session",
+	Long: `Resume a session saved with 'faize pause'. The VM is restored in the
+background with the same mounts, network, and published ports; attach with
+'faize attach' or pass --attach.
+
+Examples:
+  faize resume abc123
+  faize resume abc123 --attach`,
+	Args: cobra.ExactArgs(1),
+	RunE: runResume,
+}
+
+func init() {
+	addResumeFlags(resumeCmd)
+	rootCmd.AddCommand(pauseCmd, resumeCmd)
+}
+
+// addResumeFlags registers the resume flags on a command (faize resume and faize session resume)
+func addResumeFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVarP(&resumeAttach, "attach", "a", false, "attach to the console once the session is running")
+	cmd.Flags().BoolVar(&resumeDaemon, "daemon", false, "run as the background owner of the resumed session")
+	_ = cmd.Flags().MarkHidden("daemon")
+}
+
+func runPause(cmd *cobra.Command, args []string) error {
+	id := resolveSessionRef(args[0])
+
+	store, err := session.NewStore()
+	if err != nil {
+		return fmt.Errorf("failed to open session store: %w", err)
+	}
+	if _, ok := newSessionManager().(vm.Suspender); !ok {
+		return fmt.Errorf("pausing sessions is not supported by this VM backend")
+	}
+
+	fmt.Printf("Pausing session %s...\n", id)
+	if err := vm.Pause(store, id); err != nil {
+		return err
+	}
+	fmt.Printf("Session %s paused. Resume with: faize resume %s\n", id, id)
+	return nil
+}
+
+func runResume(cmd *cobra.Command, args []string) error {
+	id := resolveSessionRef(args[0])
+
+	if resumeDaemon {
+		openDaemonReady()
+		err := resumeSession(id)
+		if err != nil {
+			notifyDaemonParent("error " + err.Error())
+		}
+		return err
+	}
+
+	store, err := session.NewStore()
+	if err != nil {
+		return fmt.Errorf("failed to open session store: %w", err)
+	}
+	sess, err := store.Load(id)
+	if err != nil {
+		return err
+	}
+	if sess.Status != "paused" {
+		return fmt.Errorf("session %s is %s, not paused", id, sess.Status)
+	}
+
+	if _, err := runDetached(); err != nil {
+		return err
+	}
+	fmt.Printf("Session %s resumed in the background.\n", id)
+	if resumeAttach {
+		return runAttach(cmd, []string{id})
+	}
+	fmt.Printf("Attach with: faize attach %s\n", id)
+	return nil
+}
+
+// resumeDetached resumes a paused session in the background, like
+// 'faize resume', and returns once it runs
+func resumeDetached(id string) error {
+	args := []string{"resume", id, "--daemon"}
+	if cfgFile != "" {
+		args = append(args, "--config", cfgFile)
+	}
+	if debug {
+		args = append(args, "--debug")
+	}
+	_, err := startDetached(args)
+	return err
+}
+
+// resumeSession restores a paused session and owns its VM until it stops or
+// is paused again
+func resumeSession(id string) error {
+	manager, err := vm.NewManager()
+	if err != nil {
+		return err
+	}
+	return resumeWith(manager, id)
+}
+
+// resumeWith restores a paused session with manager and owns its VM, with
+// the session's timeout and idle timeout enforced again, until it stops or
+// is paused again
+func resumeWith(manager vm.Manager, id string) error {
+	suspender, ok := manager.(vm.Suspender)
+	if !ok {
+		return fmt.Errorf("resuming sessions is not supported by this VM backend")
+	}
+
+	sess, err := suspender.Resume(id)
+	if err != nil {
+		return fmt.Errorf("failed to resume session: %w", err)
+	}
+	fmt.Printf("Session %s resumed\n", id)
+
+	// The timeout runs on from what was left of it when the session paused
+	var timedOut atomic.Bool
+	if sess.Deadline != nil {
+		cancel := enforceDeadline(id, *sess.Deadline, warnTimeout(manager, id), func(bool) {
+			timedOut.Store(true)
+			fmt.Printf("\nSession deadline reached. Stopping...\n")
+			_ = manager.Stop(id)
+		})
+		defer cancel()
+	}
+
+	// The idle timeout is measured afresh from the resume
+	var idled atomic.Bool
+	if idle, _ := time.ParseDuration(sess.IdleTimeout); idle > 0 {
+		if store, err := session.NewStore(); err == nil {
+			consoleLog := filepath.Join(store.Dir(), id, "console.log")
+			cancel := stopWhenIdle(manager, id, consoleLog, sessionIdleRoots(sess), idle, &idled)
+			defer cancel()
+		}
+	}
+
+	killed, paused := superviseDetached(manager, sess)
+	if paused {
+		fmt.Printf("Session %s paused.\n", id)
+		return nil
+	}
+
+	fmt.Printf("\nStopping session %s...\n", id)
+	if err := manager.Stop(id); err != nil {
+		Debug("Failed to stop session: %v", err)
+	}
+
+	exitReason := "normal"
+	switch {
+	case timedOut.Load():
+		exitReason = "timeout"
+	case idled.Load():
+		exitReason = "idle"
+	case killed:
+		exitReason = "killed"
+	}
+	now := time.Now()
+	stop := func(s *session.Session) bool {
+		s.StoppedAt = &now
+		s.ExitReason = exitReason
+		s.Status = "stopped"
+		s.PID = 0
+		return true
+	}
+	stop(sess)
+	if store, err := session.NewStore(); err == nil {
+		// Keep the attaches and peak usage recorded while the session ran
+		if _, err := store.Update(sess.ID, func(latest *session.Session) bool {
+			stop(latest)
+			*sess = *latest
+			return true
+		}); err != nil {
+			Debug("Failed to save session: %v", err)
+		}
+	}
+	saveSessionAudit(sess, nil)
+	saveSessionCapture(sess)
+	return nil
+}
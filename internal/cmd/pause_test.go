@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/faize-ai/faize/internal/vm"
+	"github.com/faize-ai/faize/internal/vmtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResumeStopsWhenIdle(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	store, err := session.NewStore()
+	require.NoError(t, err)
+	manager := vmtest.NewFakeManager()
+	manager.Store = store
+
+	sess, err := manager.Create(&vm.Config{ProjectDir: t.TempDir()})
+	require.NoError(t, err)
+	require.NoError(t, manager.Start(sess))
+	require.NoError(t, manager.Suspend(sess.ID))
+	_, err = store.Update(sess.ID, func(s *session.Session) bool {
+		s.IdleTimeout = "50ms"
+		return true
+	})
+	require.NoError(t, err)
+
+	// The resumed session is stopped again once it has been idle
+	done := make(chan error, 1)
+	go func() { done <- resumeWith(manager, sess.ID) }()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("resumed session was not stopped when idle")
+	}
+
+	rec, err := store.Load(sess.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "stopped", rec.Status)
+	assert.Equal(t, "idle", rec.ExitReason)
+	assert.Contains(t, manager.Calls(), vmtest.Call{Op: vmtest.OpStop, ID: sess.ID})
+}
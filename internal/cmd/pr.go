@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/faize-ai/faize/internal/changeset"
+	"github.com/faize-ai/faize/internal/git"
+	"github.com/faize-ai/faize/internal/github"
+	"github.com/faize-ai/faize/internal/redact"
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/spf13/cobra"
+)
+
+var (
+	prRemote string
+	prBase   string
+	prTitle  string
+	prDraft  bool
+	prDryRun bool
+)
+
+var prCmd = &cobra.Command{
+	Use:   "pr <session-id>",
+	Short: "Open a GitHub pull request with a session's changes",
+	Long: `Push a session's faize/session-<id> branch and open a GitHub pull request
+for it, with the session's file changes and network activity in the
+description.
+
+The branch is the one written with changeset.git_branch or 'faize start
+--git-branch'; without it, the files the session changed are committed to the
+branch now, as they are in the project directory.
+
+The pull request is opened with the gh CLI when it is installed, and with the
+GitHub API and the GITHUB_TOKEN (or GH_TOKEN) environment variable otherwise.
+
+Examples:
+  faize pr abc123
+  faize pr abc123 --base develop --draft
+  faize pr abc123 --dry-run`,
+	Args: cobra.ExactArgs(1),
+	RunE: runPR,
+}
+
+func init() {
+	prCmd.Flags().StringVar(&prRemote, "remote", "origin", "git remote to push the branch to")
+	prCmd.Flags().StringVar(&prBase, "base", "", "branch to merge into (default: the remote's default branch)")
+	prCmd.Flags().StringVar(&prTitle, "title", "", "pull request title (default: names the session)")
+	prCmd.Flags().BoolVar(&prDraft, "draft", false, "open the pull request as a draft")
+	prCmd.Flags().BoolVarP(&prDryRun, "dry-run", "n", false, "print the pull request instead of pushing and opening it")
+	rootCmd.AddCommand(prCmd)
+}
+
+func runPR(cmd *cobra.Command, args []string) error {
+	store, err := session.NewStore()
+	if err != nil {
+		return fmt.Errorf("failed to open session store: %w", err)
+	}
+	sessionID := resolveSessionRef(args[0])
+	sess, err := store.Load(sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to load session: %w", err)
+	}
+	if sess.Status == "running" {
+		return fmt.Errorf("session %s is still running; stop it first", sessionID)
+	}
+	cs, err := loadSessionChangeset(store, sessionID)
+	if err != nil {
+		return err
+	}
+	branch := "faize/session-" + sessionID
+
+	// Nothing runs git in the project before this check: the guest could have
+	// redirected .git, pointed the remote elsewhere or planted hooks
+	repo := sessionRepo(sess)
+	if repo == nil {
+		return fmt.Errorf("no git repository was recorded when session %s started; commit and push its changes yourself", sessionID)
+	}
+	if err := repo.Check(sess.GitConfig); err != nil {
+		return fmt.Errorf("%w during session %s; review the repository, then push %s yourself", err, sessionID, branch)
+	}
+
+	if !git.BranchExists(repo, branch) {
+		if prDryRun {
+			fmt.Printf("Would commit the session's changes to %s\n", branch)
+		} else {
+			commitSessionBranch(sess, cs.MountChanges)
+			if !git.BranchExists(repo, branch) {
+				return fmt.Errorf("session %s has no changes to commit in %s", sessionID, repo.Root)
+			}
+		}
+	}
+
+	title := prTitle
+	if title == "" {
+		title = "Changes from faize session " + sessionID
+	}
+	// The description leaves the machine; mask anything that looks like a secret
+	body := redact.String(prBody(sess, cs))
+	if prDryRun {
+		fmt.Printf("Would push %s to %s and open a pull request:\n\n%s\n\n%s", branch, prRemote, title, body)
+		return nil
+	}
+
+	fmt.Printf("Pushing %s to %s...\n", branch, prRemote)
+	if err := git.Push(repo, prRemote, branch); err != nil {
+		return fmt.Errorf("failed to push %s: %w", branch, err)
+	}
+	if _, err := exec.LookPath("gh"); err == nil {
+		return createPRWithGH(repo, branch, title, body)
+	}
+	return createPRWithAPI(cmd, repo, branch, title, body)
+}
+
+// prBody formats a pull request description from a session's changeset
+func prBody(sess *session.Session, cs *changeset.SessionChangeset) string {
+	var summary bytes.Buffer
+	changeset.PrintSummary(&summary, cs)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Changes made in faize session `%s`", sess.ID)
+	if sess.StoppedAt != nil {
+		fmt.Fprintf(&b, " (%s, %s)", sess.StartedAt.Format("2006-01-02 15:04"), sess.StoppedAt.Sub(sess.StartedAt).Round(time.Second))
+	}
+	b.WriteString(".\n\n```\n")
+	b.WriteString(strings.TrimSpace(summary.String()))
+	b.WriteString("\n```\n")
+	return b.String()
+}
+
+// createPRWithGH opens the pull request with the gh CLI, which prints its URL.
+// The git commands gh runs get the repository's recorded git directory and
+// the hardened options.
+func createPRWithGH(repo *git.Repo, branch, title, body string) error {
+	args := []string{"pr", "create", "--head", branch, "--title", title, "--body-file", "-"}
+	if prBase != "" {
+		args = append(args, "--base", prBase)
+	}
+	if prDraft {
+		args = append(args, "--draft")
+	}
+	gh := exec.Command("gh", args...)
+	gh.Dir = repo.Root
+	gh.Env = append(os.Environ(), repo.Env()...)
+	gh.Stdin = strings.NewReader(body)
+	gh.Stdout, gh.Stderr = os.Stdout, os.Stderr
+	if err := gh.Run(); err != nil {
+		return fmt.Errorf("failed to create pull request with gh: %w", err)
+	}
+	return nil
+}
+
+// createPRWithAPI opens the pull request with the GitHub API
+func createPRWithAPI(cmd *cobra.Command, repo *git.Repo, branch, title, body string) error {
+	token := os.Getenv("GITHUB_TOKEN")
+	if token == "" {
+		token = os.Getenv("GH_TOKEN")
+	}
+	if token == "" {
+		return fmt.Errorf("install the gh CLI or set GITHUB_TOKEN to open the pull request for %s", branch)
+	}
+	remoteURL, err := git.RemoteURL(repo, prRemote)
+	if err != nil {
+		return fmt.Errorf("failed to read remote %s: %w", prRemote, err)
+	}
+	owner, name, ok := github.ParseRemote(remoteURL)
+	if !ok {
+		return fmt.Errorf("remote %s (%s) is not a github.com repository", prRemote, remoteURL)
+	}
+	base := prBase
+	if base == "" {
+		if base = git.DefaultBranch(repo, prRemote); base == "" {
+			return fmt.Errorf("can't tell the default branch of %s; pass --base", prRemote)
+		}
+	}
+
+	client := &github.Client{BaseURL: github.APIURL, Token: token}
+	url, err := client.CreatePullRequest(cmd.Context(), owner, name, github.PullRequest{
+		Title: title,
+		Head:  branch,
+		Base:  base,
+		Body:  body,
+		Draft: prDraft,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println(url)
+	return nil
+}
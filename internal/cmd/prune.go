@@ -1,9 +1,15 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/faize-ai/faize/internal/artifacts"
+	"github.com/faize-ai/faize/internal/changeset"
+	"github.com/faize-ai/faize/internal/config"
 	"github.com/faize-ai/faize/internal/session"
 	"github.com/spf13/cobra"
 )
@@ -20,6 +26,8 @@ var pruneCmd = &cobra.Command{
 
 This command removes:
   - Stopped VM sessions
+  - Saved changesets older than changeset.keep_days or beyond the newest
+    changeset.keep_sessions (see 'faize diff --list')
   - Unused base images (with --artifacts)
   - Build caches`,
 	RunE: runPrune,
@@ -63,6 +71,10 @@ func runPrune(cmd *cobra.Command, args []string) error {
 		fmt.Printf("Removed %d session(s).\n", removedCount)
 	}
 
+	if err := pruneChangesets(store); err != nil {
+		return err
+	}
+
 	// Optionally clean artifacts
 	if pruneArtifacts {
 		fmt.Println("\nCleaning up artifacts...")
@@ -79,3 +91,53 @@ func runPrune(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// pruneChangesets removes the saved changesets the retention policy in the
+// config no longer keeps. Sessions still running or paused keep theirs.
+func pruneChangesets(store *session.Store) error {
+	cfg, err := config.Load("")
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	keepDays, keepSessions := cfg.Changeset.KeepDays, cfg.Changeset.KeepSessions
+	if keepDays < 0 || keepSessions < 0 {
+		return fmt.Errorf("invalid changeset config: keep_days and keep_sessions must not be negative")
+	}
+	if keepDays == 0 && keepSessions == 0 {
+		return nil
+	}
+
+	stored, err := changeset.ListChangesets(store.Dir())
+	if err != nil {
+		return fmt.Errorf("failed to list changesets: %w", err)
+	}
+	removed := 0
+	for _, s := range changeset.Expired(stored, keepDays, keepSessions, time.Now()) {
+		dir := filepath.Join(store.Dir(), s.SessionID)
+		sess, err := store.Load(s.SessionID)
+		switch {
+		case errors.Is(err, session.ErrNotFound):
+			// The session's record is gone; nothing else uses its directory
+			err = os.RemoveAll(dir)
+		case err != nil:
+			// An unreadable record is reported below and left alone
+		case sess.Status == "running" || sess.Status == "paused":
+			continue
+		default:
+			// Keep the stopped session's logs; drop its changes
+			err = os.Remove(filepath.Join(dir, "bootstrap", "changeset.json"))
+			if err == nil {
+				err = os.RemoveAll(filepath.Join(dir, changeset.StashDir))
+			}
+		}
+		if err != nil {
+			fmt.Printf("Warning: failed to remove changeset of session %s: %v\n", s.SessionID, err)
+			continue
+		}
+		removed++
+	}
+	if removed > 0 {
+		fmt.Printf("Removed %d old changeset(s).\n", removed)
+	}
+	return nil
+}
@@ -1,75 +1,309 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
 	"text/tabwriter"
+	"time"
 
+	"github.com/faize-ai/faize/internal/network"
+	"github.com/faize-ai/faize/internal/session"
 	"github.com/faize-ai/faize/internal/vm"
 	"github.com/spf13/cobra"
 )
 
+var (
+	psJSON    bool
+	psFilters []string
+	psStatus  []string
+	psProject string
+	psLabels  []string
+	psWatch   bool
+	psEvery   time.Duration
+)
+
 var psCmd = &cobra.Command{
 	Use:   "ps",
 	Short: "List running VM sessions",
-	Long:  `List all running Faize VM sessions with their status and details.`,
-	RunE:  runPs,
+	Long: `List all running Faize VM sessions with their status and details: mode
+(claude or shell), uptime, CPUs and memory, network policy (all, none, or the
+number of allowlist entries), timeout, and exit reason.
+
+With --json, print every session as a JSON array of the full session records
+(mounts, network policy, ports, timestamps, exit reason) for scripts.
+
+--filter shows only the sessions matching every filter given:
+  name=PATTERN       the session's name matches a shell pattern (auth, auth-*)
+  label=KEY          the session has the label
+  label=KEY=VALUE    the session has the label with this value
+  status=STATUS      created, running, paused, or stopped (comma-separated for any)
+  project=DIR        the session's project is DIR or inside it
+
+--status, --project, and --label are shorthands for the filters.
+
+With --watch, the table is redrawn every --interval until interrupted; a
+session whose status changed is highlighted for a few seconds, and the recent
+changes are listed below the table.
+
+Examples:
+  faize ps
+  faize ps --status running,paused --project .
+  faize ps --watch --label team=backend
+  faize ps --filter label=team=backend --filter status=running
+  faize ps --json | jq -r '.[] | select(.status == "running") | .id'`,
+	RunE: runPs,
 }
 
 func init() {
+	addPsFlags(psCmd)
 	rootCmd.AddCommand(psCmd)
 }
 
+// addPsFlags registers the ps flags on a command (faize ps and faize session list)
+func addPsFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&psJSON, "json", false, "output in JSON format")
+	cmd.Flags().StringArrayVarP(&psFilters, "filter", "f", []string{}, "show only sessions matching name=, label=, status= or project= (repeatable)")
+	cmd.Flags().StringSliceVar(&psStatus, "status", []string{}, "show only sessions with one of these statuses")
+	cmd.Flags().StringVar(&psProject, "project", "", "show only sessions of this project directory or its subdirectories")
+	cmd.Flags().StringArrayVar(&psLabels, "label", []string{}, "show only sessions with this KEY or KEY=VALUE label (repeatable)")
+	cmd.Flags().BoolVarP(&psWatch, "watch", "w", false, "refresh the table in place, highlighting sessions whose status changes")
+	cmd.Flags().DurationVar(&psEvery, "interval", 2*time.Second, "how often --watch refreshes")
+}
+
 func runPs(cmd *cobra.Command, args []string) error {
-	// Try VZManager first, fall back to stub
-	var manager vm.Manager
-	vzManager, err := vm.NewVZManager()
+	filters := append([]string{}, psFilters...)
+	if len(psStatus) > 0 {
+		filters = append(filters, "status="+strings.Join(psStatus, ","))
+	}
+	if psProject != "" {
+		filters = append(filters, "project="+psProject)
+	}
+	for _, label := range psLabels {
+		filters = append(filters, "label="+label)
+	}
+	match, err := parsePsFilters(filters)
+	if err != nil {
+		return err
+	}
+	if psWatch {
+		if psJSON {
+			return fmt.Errorf("--watch can't be combined with --json")
+		}
+		if psEvery <= 0 {
+			return fmt.Errorf("--interval must be positive")
+		}
+		return watchSessions(newSessionManager(), match)
+	}
+
+	// Try the platform VM backend first, fall back to stub
+	manager, err := vm.NewManager()
 	if err != nil {
 		manager = vm.NewStubManager()
-	} else {
-		manager = vzManager
 	}
 
-	sessions, err := manager.List()
+	sessions, err := listSessions(manager, match)
 	if err != nil {
 		if err == vm.ErrVMNotImplemented {
+			if psJSON {
+				return printSessionsJSON(nil)
+			}
 			fmt.Println("[Phase 1] VM support not yet implemented.")
 			fmt.Println("No sessions to display.")
 			return nil
 		}
-		return fmt.Errorf("failed to list sessions: %w", err)
+		return err
+	}
+
+	if psJSON {
+		return printSessionsJSON(sessions)
 	}
 
 	if len(sessions) == 0 {
+		if len(filters) > 0 {
+			fmt.Println("No sessions match the filters.")
+			return nil
+		}
 		fmt.Println("No running sessions.")
 		return nil
 	}
+	return writeSessionTable(os.Stdout, sessions, time.Now())
+}
 
-	// Create tabwriter for aligned output
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	_, _ = fmt.Fprintln(w, "ID\tPROJECT\tSTATUS\tTIMEOUT\tEXIT REASON\tSTARTED")
-	_, _ = fmt.Fprintln(w, "--\t-------\t------\t-------\t-----------\t-------")
+// listSessions returns the manager's sessions that match
+func listSessions(manager vm.Manager, match func(*session.Session) bool) ([]*session.Session, error) {
+	sessions, err := manager.List()
+	if err != nil {
+		if err == vm.ErrVMNotImplemented {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	var matched []*session.Session
+	for _, sess := range sessions {
+		if match(sess) {
+			matched = append(matched, sess)
+		}
+	}
+	return matched, nil
+}
 
-	for _, session := range sessions {
-		started := session.StartedAt.Format("2006-01-02 15:04:05")
-		timeout := session.Timeout
+// writeSessionTable writes the ps table: two header lines, then one line
+// per session
+func writeSessionTable(out io.Writer, sessions []*session.Session, now time.Time) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tNAME\tPROJECT\tMODE\tSTATUS\tUPTIME\tCPUS\tMEMORY\tNETWORK\tTIMEOUT\tEXIT REASON\tSTARTED\tLABELS")
+	_, _ = fmt.Fprintln(w, "--\t----\t-------\t----\t------\t------\t----\t------\t-------\t-------\t-----------\t-------\t------")
+
+	for _, sess := range sessions {
+		started := sess.StartedAt.Format("2006-01-02 15:04:05")
+		timeout := sess.Timeout
 		if timeout == "" {
 			timeout = "-"
 		}
-		exitReason := session.ExitReason
+		exitReason := sess.ExitReason
 		if exitReason == "" {
 			exitReason = "-"
 		}
-		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
-			session.ID,
-			session.ProjectDir,
-			session.Status,
+		name := sess.Name
+		if name == "" {
+			name = "-"
+		}
+		labels := "-"
+		if len(sess.Labels) > 0 {
+			labels = session.FormatLabels(sess.Labels)
+		}
+		mode := "shell"
+		if sess.ClaudeMode {
+			mode = "claude"
+		}
+		memory := sess.Memory
+		if memory == "" {
+			memory = "-"
+		}
+		status := sess.Status
+		if sess.Warm && status == "running" {
+			status = "warm (idle)"
+		} else if sess.Detached && status == "running" {
+			status += " (detached)"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			sess.ID,
+			name,
+			sess.ProjectDir,
+			mode,
+			status,
+			formatUptime(sess, now),
+			sess.CPUs,
+			memory,
+			formatNetworkPolicy(sess.Network),
 			timeout,
 			exitReason,
 			started,
+			labels,
 		)
 	}
 
-	_ = w.Flush()
-	return nil
+	return w.Flush()
+}
+
+// printSessionsJSON prints sessions as a JSON array; an empty list prints []
+func printSessionsJSON(sessions []*session.Session) error {
+	if sessions == nil {
+		sessions = []*session.Session{}
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sessions)
+}
+
+// parsePsFilters parses --filter values into a function reporting whether a
+// session matches all of them
+func parsePsFilters(filters []string) (func(*session.Session) bool, error) {
+	var tests []func(*session.Session) bool
+	for _, f := range filters {
+		key, value, ok := strings.Cut(f, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid filter %q: expected name=, label=, status= or project=", f)
+		}
+		switch key {
+		case "name":
+			if _, err := path.Match(value, ""); err != nil {
+				return nil, fmt.Errorf("invalid filter %q: %w", f, err)
+			}
+			tests = append(tests, func(s *session.Session) bool {
+				ok, _ := path.Match(value, s.Name)
+				return s.Name != "" && ok
+			})
+		case "label":
+			k, v, hasValue := strings.Cut(value, "=")
+			tests = append(tests, func(s *session.Session) bool {
+				got, ok := s.Labels[k]
+				return ok && (!hasValue || got == v)
+			})
+		case "status":
+			statuses := strings.Split(value, ",")
+			tests = append(tests, func(s *session.Session) bool { return slices.Contains(statuses, s.Status) })
+		case "project":
+			dir, err := filepath.Abs(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid filter %q: %w", f, err)
+			}
+			tests = append(tests, func(s *session.Session) bool {
+				return s.ProjectDir == dir || strings.HasPrefix(s.ProjectDir, dir+string(filepath.Separator))
+			})
+		default:
+			return nil, fmt.Errorf("invalid filter %q: expected name=, label=, status= or project=", f)
+		}
+	}
+	return func(s *session.Session) bool {
+		for _, test := range tests {
+			if !test(s) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// formatUptime formats how long a session has run, or ran if it stopped, to
+// two units (45s, 12m, 3h04m, 2d05h)
+func formatUptime(sess *session.Session, now time.Time) string {
+	end := now
+	if sess.StoppedAt != nil {
+		end = *sess.StoppedAt
+	} else if sess.Status == "stopped" {
+		return "-"
+	}
+	d := max(end.Sub(sess.StartedAt), 0)
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return fmt.Sprintf("%dd%02dh", int(d.Hours())/24, int(d.Hours())%24)
+}
+
+// formatNetworkPolicy summarizes a session's network allowlist: all, none,
+// or how many entries it has
+func formatNetworkPolicy(specs []string) string {
+	policy := network.Parse(specs)
+	switch {
+	case policy.AllowAll:
+		return network.NetworkAll
+	case policy.Blocked:
+		return network.NetworkNone
+	}
+	if len(specs) == 1 {
+		return "1 entry"
+	}
+	return fmt.Sprintf("%d entries", len(specs))
 }
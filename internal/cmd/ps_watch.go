@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/faize-ai/faize/internal/vm"
+	"golang.org/x/term"
+)
+
+const (
+	// psWatchHighlight is how long a session stays highlighted after its status changes
+	psWatchHighlight = 5 * time.Second
+	// psWatchChanges is how many recent status changes are listed under the table
+	psWatchChanges = 5
+)
+
+// psChange is a status change seen by faize ps --watch; From is empty for a
+// session that appeared, To for one that was removed
+type psChange struct {
+	Time     time.Time
+	Session  string
+	From, To string
+}
+
+// psWatcher tracks session statuses between refreshes of faize ps --watch
+type psWatcher struct {
+	seen     bool                 // a first listing has been recorded
+	statuses map[string]string    // session ID -> status at the last refresh
+	changed  map[string]time.Time // session ID -> when its status last changed
+	changes  []psChange           // most recent last
+}
+
+func newPsWatcher() *psWatcher {
+	return &psWatcher{statuses: make(map[string]string), changed: make(map[string]time.Time)}
+}
+
+// watchSessions redraws the session table every --interval until interrupted
+func watchSessions(manager vm.Manager, match func(*session.Session) bool) error {
+	if !term.IsTerminal(int(os.Stdout.Fd())) {
+		return fmt.Errorf("--watch needs a terminal")
+	}
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	// Draw on the alternate screen so the terminal is left as it was
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	defer fmt.Print("\x1b[?25h\x1b[?1049l")
+
+	w := newPsWatcher()
+	ticker := time.NewTicker(psEvery)
+	defer ticker.Stop()
+	for {
+		sessions, err := listSessions(manager, match)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		w.update(sessions, now)
+		width, _, err := term.GetSize(int(os.Stdout.Fd()))
+		if err != nil {
+			width = 80
+		}
+		_, _ = os.Stdout.WriteString(w.render(sessions, now, width))
+
+		select {
+		case <-sigCh:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// update records the status changes since the last refresh
+func (w *psWatcher) update(sessions []*session.Session, now time.Time) {
+	current := make(map[string]string, len(sessions))
+	for _, sess := range sessions {
+		current[sess.ID] = sess.Status
+		if prev, ok := w.statuses[sess.ID]; w.seen && (!ok || prev != sess.Status) {
+			w.record(psChange{Time: now, Session: sessionLabel(sess), From: prev, To: sess.Status})
+			w.changed[sess.ID] = now
+		}
+	}
+	for id, prev := range w.statuses {
+		if _, ok := current[id]; !ok {
+			w.record(psChange{Time: now, Session: id, From: prev})
+			delete(w.changed, id)
+		}
+	}
+	w.statuses = current
+	w.seen = true
+}
+
+func (w *psWatcher) record(c psChange) {
+	w.changes = append(w.changes, c)
+	if len(w.changes) > psWatchChanges {
+		w.changes = w.changes[len(w.changes)-psWatchChanges:]
+	}
+}
+
+// render returns the screen for a refresh, with recently changed sessions
+// highlighted and lines cut at width
+func (w *psWatcher) render(sessions []*session.Session, now time.Time, width int) string {
+	var lines []string
+	lines = append(lines, fmt.Sprintf("Every %s: faize ps - %d session(s) - %s", psEvery, len(sessions), now.Format(time.TimeOnly)), "")
+
+	if len(sessions) == 0 {
+		lines = append(lines, "No matching sessions.")
+	} else {
+		var table bytes.Buffer
+		_ = writeSessionTable(&table, sessions, now)
+		rows := strings.Split(strings.TrimSuffix(table.String(), "\n"), "\n")
+		for i, row := range rows {
+			row = truncateLine(row, width)
+			// Rows follow the two header lines, in the order of sessions
+			if i >= 2 {
+				if at, ok := w.changed[sessions[i-2].ID]; ok && now.Sub(at) < psWatchHighlight {
+					row = "\x1b[7m" + row + "\x1b[0m"
+				}
+			}
+			lines = append(lines, row)
+		}
+	}
+
+	if len(w.changes) > 0 {
+		lines = append(lines, "", "Recent changes:")
+		for i := len(w.changes) - 1; i >= 0; i-- {
+			c := w.changes[i]
+			var change string
+			switch {
+			case c.From == "":
+				change = "appeared (" + c.To + ")"
+			case c.To == "":
+				change = "removed"
+			default:
+				change = c.From + " -> " + c.To
+			}
+			lines = append(lines, truncateLine(fmt.Sprintf("  %s  %s  %s", c.Time.Format(time.TimeOnly), c.Session, change), width))
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	for _, line := range lines {
+		b.WriteString(line)
+		b.WriteString("\x1b[K\n")
+	}
+	b.WriteString("\x1b[J")
+	return b.String()
+}
+
+// sessionLabel names a session by ID, with its name if it has one
+func sessionLabel(sess *session.Session) string {
+	if sess.Name != "" {
+		return fmt.Sprintf("%s (%s)", sess.ID, sess.Name)
+	}
+	return sess.ID
+}
+
+func truncateLine(line string, width int) string {
+	if width > 0 && len(line) > width {
+		return line[:width]
+	}
+	return line
+}
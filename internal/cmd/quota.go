@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/faize-ai/faize/internal/changeset"
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/faize-ai/faize/internal/vm"
+)
+
+// quotaCheckInterval is how often writable mounts are measured against the
+// write quota
+const quotaCheckInterval = 15 * time.Second
+
+// quotaWarnShare is how much of the write quota a mount may use before the
+// user is warned
+const quotaWarnShare = 0.8
+
+// quotaMount is a writable mount and the sizes of its files before the
+// session, which what the session writes into it is measured against
+type quotaMount struct {
+	source string
+	target string // guest path
+	before changeset.Sizes
+}
+
+// newQuotaMount records the file sizes of a writable mount for the write
+// quota. Unlike change tracking, it leaves nothing out.
+func newQuotaMount(m session.VMMount) (quotaMount, error) {
+	before, err := changeset.TakeSizes(m.Source)
+	if err != nil {
+		return quotaMount{}, err
+	}
+	return quotaMount{source: m.Source, target: m.Target, before: before}, nil
+}
+
+// watchQuota measures how much has been written into each mount since the
+// session started (see changeset.Growth), and calls warn once a mount has
+// used quotaWarnShare of quota and exceed once it is over it. Each is called
+// at most once per mount. The returned function stops watching.
+func watchQuota(mounts []quotaMount, quota int64, warn, exceed func(m quotaMount, written int64)) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(quotaCheckInterval)
+		defer ticker.Stop()
+		warned := make(map[string]bool)
+		exceeded := make(map[string]bool)
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			for _, m := range mounts {
+				if exceeded[m.source] {
+					continue
+				}
+				written, err := changeset.Growth(m.source, m.before)
+				if err != nil {
+					Debug("Failed to measure writes to %s: %v", m.source, err)
+					continue
+				}
+				switch {
+				case written > quota:
+					exceeded[m.source] = true
+					exceed(m, written)
+				case !warned[m.source] && float64(written) >= quotaWarnShare*float64(quota):
+					warned[m.source] = true
+					warn(m, written)
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// remountReadOnly makes a mount read-only in a running session's guest, so
+// writes to it fail from then on
+func remountReadOnly(id, target string) error {
+	var out bytes.Buffer
+	req := &guest.ExecRequest{Args: []string{"mount", "-o", "remount,bind,ro", target}, User: "root"}
+	code, err := vm.Exec(id, req, &out, &out)
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return fmt.Errorf("mount exited with status %d: %s", code, strings.TrimSpace(out.String()))
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/spf13/cobra"
+)
+
+var renameCmd = &cobra.Command{
+	Use:   "rename <session-id> <name>",
+	Short: "Rename a session",
+	Long: `Give a session a new name, e.g. when the task it runs changes. The session
+can be running, paused, or stopped, and is then referred to by the new name
+in attach, exec, logs, stop, and the other session commands.
+
+Names must be unique among sessions that haven't stopped. A session's files
+are kept under its ID, so renaming moves nothing.
+
+Examples:
+  faize rename abc123 auth-refactor
+  faize rename auth-refactor auth-tests`,
+	Args: cobra.ExactArgs(2),
+	RunE: runRename,
+}
+
+func init() {
+	rootCmd.AddCommand(renameCmd)
+}
+
+func runRename(cmd *cobra.Command, args []string) error {
+	store, err := session.NewStore()
+	if err != nil {
+		return fmt.Errorf("failed to open session store: %w", err)
+	}
+	id, err := store.Resolve(args[0])
+	if err != nil {
+		return err
+	}
+	if err := store.Rename(id, args[1]); err != nil {
+		return err
+	}
+	fmt.Printf("Session %s renamed to %s\n", id, args[1])
+	return nil
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/faize-ai/faize/internal/config"
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/faize-ai/faize/internal/vm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	resizeCPUs   int
+	resizeMemory string
+)
+
+var resizeCmd = &cobra.Command{
+	Use:   "resize <session-id> [--cpus N] [--memory SIZE]",
+	Short: "Change a running session's CPUs and memory",
+	Long: `Change how many CPUs and how much memory a running session may use,
+without restarting it, e.g. when a task outgrows the resources it was
+started with.
+
+A VM's size can't change while it runs, or when a paused session is
+restored, so sessions can only grow up to the size their VM was created
+with: resources.max_cpus and resources.max_memory in the config, which
+default to cpus and memory. The guest takes CPUs offline or brings them
+back within a few seconds, and memory is handed back to or taken from the
+guest through its memory balloon.
+
+Examples:
+  faize resize abc123 --memory 8GB
+  faize resize abc123 --cpus 4 --memory 6GB`,
+	Args: cobra.ExactArgs(1),
+	RunE: runResize,
+}
+
+func init() {
+	addResizeFlags(resizeCmd)
+	rootCmd.AddCommand(resizeCmd)
+}
+
+func addResizeFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&resizeCPUs, "cpus", 0, "number of CPUs the session may use")
+	cmd.Flags().StringVar(&resizeMemory, "memory", "", "memory the session may use (e.g., 8GB)")
+}
+
+func runResize(cmd *cobra.Command, args []string) error {
+	if resizeCPUs == 0 && resizeMemory == "" {
+		return fmt.Errorf("nothing to resize: pass --cpus, --memory, or both")
+	}
+	if cmd.Flags().Changed("cpus") && resizeCPUs < 1 {
+		return fmt.Errorf("--cpus must be at least 1")
+	}
+	cfg, err := config.Load("")
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	store, err := session.NewStore()
+	if err != nil {
+		return fmt.Errorf("failed to open session store: %w", err)
+	}
+	id, err := store.Resolve(args[0])
+	if err != nil {
+		return err
+	}
+
+	sess, err := vm.Resize(store, id, resizeCPUs, resizeMemory, cfg.Limits.MaxTotalMemory)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Session %s now has %d CPUs and %s of memory\n", id, sess.CPUs, sess.Memory)
+	return nil
+}
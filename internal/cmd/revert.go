@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/faize-ai/faize/internal/changeset"
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	revertDryRun bool
+	revertYes    bool
+)
+
+var revertCmd = &cobra.Command{
+	Use:   "revert <session-id> [paths...]",
+	Short: "Undo a session's file changes",
+	Long: `Undo the file changes a session made in its project mounts: created files
+are removed, and modified and deleted files are restored from their
+pre-session copies, which are kept when changeset.keep_contents: true is set
+in ~/.faize/config.yaml. Files edited again since the session ended are left
+alone.
+
+Paths limit the revert to those files and directories, given as host paths
+(relative paths are resolved against the current directory).
+
+Examples:
+  faize revert abc123
+  faize revert abc123 src/main.go docs/
+  faize revert abc123 --dry-run`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runRevert,
+}
+
+func init() {
+	revertCmd.Flags().BoolVarP(&revertDryRun, "dry-run", "n", false, "list the changes that would be reverted")
+	revertCmd.Flags().BoolVarP(&revertYes, "yes", "y", false, "revert without asking")
+	rootCmd.AddCommand(revertCmd)
+}
+
+func runRevert(cmd *cobra.Command, args []string) error {
+	store, err := session.NewStore()
+	if err != nil {
+		return fmt.Errorf("failed to open session store: %w", err)
+	}
+	sessionID := resolveSessionRef(args[0])
+	if sess, err := store.Load(sessionID); err == nil && sess.Status == "running" {
+		return fmt.Errorf("session %s is still running; stop it first", sessionID)
+	}
+	cs, err := loadSessionChangeset(store, sessionID)
+	if err != nil {
+		return err
+	}
+
+	var targets []string
+	for _, arg := range args[1:] {
+		abs, err := filepath.Abs(arg)
+		if err != nil {
+			return fmt.Errorf("invalid path %s: %w", arg, err)
+		}
+		targets = append(targets, abs)
+	}
+
+	var mounts []changeset.MountChanges
+	total := 0
+	for _, mc := range cs.MountChanges {
+		// Toolchain and credentials changes are summarized, not reverted
+		if len(mc.Summary) > 0 {
+			continue
+		}
+		var selected []changeset.Change
+		for _, c := range changeset.FilterPaths(mc.Changes) {
+			if matchesTargets(filepath.Join(mc.Source, c.Path), targets) {
+				selected = append(selected, c)
+			}
+		}
+		if len(selected) == 0 {
+			continue
+		}
+		mc.Changes = selected
+		mounts = append(mounts, mc)
+		total += len(selected)
+	}
+	if total == 0 {
+		if len(targets) > 0 {
+			return fmt.Errorf("no changes in session %s match %s", sessionID, strings.Join(args[1:], ", "))
+		}
+		fmt.Println("No file changes to revert.")
+		return nil
+	}
+
+	for _, mc := range mounts {
+		for _, c := range mc.Changes {
+			fmt.Printf("  %-8s %s\n", c.Type, changePaths(c, mc.Source))
+		}
+	}
+	if revertDryRun {
+		return nil
+	}
+	if !revertYes {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return fmt.Errorf("pass --yes to revert without a terminal")
+		}
+		if !confirm(fmt.Sprintf("Revert %d change(s)?", total)) {
+			fmt.Println("Nothing reverted.")
+			return nil
+		}
+	}
+
+	stash := filepath.Join(store.Dir(), sessionID, changeset.StashDir)
+	reverted, failed := 0, 0
+	for _, mc := range mounts {
+		r, f := revertChanges(mc, stash)
+		reverted, failed = reverted+r, failed+f
+	}
+	fmt.Printf("Reverted %d change(s).\n", reverted)
+	if failed > 0 {
+		return fmt.Errorf("%d change(s) could not be reverted", failed)
+	}
+	return nil
+}
+
+// matchesTargets reports whether path is one of targets or below one. With
+// no targets every path matches.
+func matchesTargets(path string, targets []string) bool {
+	if len(targets) == 0 {
+		return true
+	}
+	for _, t := range targets {
+		if path == t || strings.HasPrefix(path, t+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// revertChanges reverts a mount's changes, printing those that fail.
+// Changes are in path order, so deleted directories are recreated before
+// their files; created directories are removed last, deepest first, and only
+// once empty.
+func revertChanges(mc changeset.MountChanges, stash string) (reverted, failed int) {
+	var createdDirs []changeset.Change
+	for _, c := range mc.Changes {
+		if c.Type == "created" && c.Mode.IsDir() {
+			createdDirs = append(createdDirs, c)
+			continue
+		}
+		if err := changeset.Revert(mc.Source, c, stash); err != nil {
+			fmt.Printf("  not reverted: %s: %v\n", filepath.Join(mc.Source, c.Path), err)
+			failed++
+			continue
+		}
+		reverted++
+	}
+	for i := len(createdDirs) - 1; i >= 0; i-- {
+		c := createdDirs[i]
+		if err := changeset.Revert(mc.Source, c, stash); err == nil {
+			reverted++
+		}
+	}
+	return reverted, failed
+}
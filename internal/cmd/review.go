@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/faize-ai/faize/internal/changeset"
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var reviewCmd = &cobra.Command{
+	Use:   "review [session-id]",
+	Short: "Keep or revert a session's file changes one by one",
+	Long: `Review the files a session changed in its project mounts and keep or
+revert each one. Reverting removes a created file and restores a modified
+or deleted file from its pre-session copy, which is kept when
+changeset.keep_contents: true is set in ~/.faize/config.yaml. Files edited
+again since the session ended are not reverted.
+
+If no session-id is given, reviews the most recent session.
+
+Examples:
+  faize review
+  faize review abc123
+  faize start --review`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runReview,
+}
+
+func init() {
+	rootCmd.AddCommand(reviewCmd)
+}
+
+func runReview(cmd *cobra.Command, args []string) error {
+	store, err := session.NewStore()
+	if err != nil {
+		return fmt.Errorf("failed to open session store: %w", err)
+	}
+	var sessionID string
+	if len(args) > 0 {
+		sessionID = resolveSessionRef(args[0])
+	} else if sessionID, err = findMostRecentSession(store); err != nil {
+		return err
+	}
+	return reviewSession(store, sessionID)
+}
+
+// reviewSession asks whether to keep or revert each file a session changed
+// in its project mounts
+func reviewSession(store *session.Store, sessionID string) error {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return fmt.Errorf("reviewing changes needs a terminal")
+	}
+	cs, err := loadSessionChangeset(store, sessionID)
+	if err != nil {
+		return err
+	}
+	stash := filepath.Join(store.Dir(), sessionID, changeset.StashDir)
+
+	in := bufio.NewReader(os.Stdin)
+	kept, reverted := 0, 0
+	for _, mc := range cs.MountChanges {
+		// Toolchain and credentials changes are summarized, not reviewed
+		if len(mc.Summary) > 0 {
+			continue
+		}
+		k, r, quit := reviewMount(in, mc, stash)
+		kept, reverted = kept+k, reverted+r
+		if quit {
+			break
+		}
+	}
+	if kept+reverted == 0 {
+		fmt.Println("No file changes to review.")
+		return nil
+	}
+	fmt.Printf("\nKept %d, reverted %d file(s).\n", kept, reverted)
+	return nil
+}
+
+// reviewMount prompts for each changed file of a mount. Files not answered
+// when the user quits are kept.
+func reviewMount(in *bufio.Reader, mc changeset.MountChanges, stash string) (kept, reverted int, quit bool) {
+	var files, createdDirs []changeset.Change
+	for _, c := range changeset.FilterPaths(mc.Changes) {
+		switch {
+		case !c.Mode.IsDir():
+			files = append(files, c)
+		case c.Type == "created":
+			createdDirs = append(createdDirs, c)
+		}
+	}
+	if len(files) == 0 {
+		return 0, 0, false
+	}
+	fmt.Printf("\n%s → %s: %d file(s) changed\n", mc.Source, mc.Target, len(files))
+
+	var revertedPaths []string
+	keepRest := false
+	for i, c := range files {
+		if keepRest || quit {
+			kept++
+			continue
+		}
+	prompt:
+		for {
+			fmt.Printf("[%d/%d] %s %s — keep, revert, diff, keep all, quit? [k,r,d,a,q] ", i+1, len(files), c.Type, changePaths(c, ""))
+			answer, err := in.ReadString('\n')
+			if err != nil && answer == "" {
+				fmt.Println()
+				answer = "q"
+			}
+			switch strings.ToLower(strings.TrimSpace(answer)) {
+			case "", "k":
+				kept++
+				break prompt
+			case "r":
+				if err := changeset.Revert(mc.Source, c, stash); err != nil {
+					fmt.Printf("  not reverted: %v\n", err)
+					continue
+				}
+				fmt.Println("  reverted")
+				reverted++
+				revertedPaths = append(revertedPaths, c.Path)
+				break prompt
+			case "d":
+				showChangeDiff(os.Stdout, c, stash)
+			case "a":
+				keepRest = true
+				kept++
+				break prompt
+			case "q":
+				quit = true
+				kept++
+				break prompt
+			default:
+				fmt.Println("  k keep the change, r revert it, d show its diff, a keep this and all remaining, q keep the rest and quit")
+			}
+		}
+	}
+
+	// Remove directories the session created once reverting emptied them,
+	// deepest first; directories still holding kept files stay
+	for i := len(createdDirs) - 1; i >= 0; i-- {
+		dir := createdDirs[i]
+		for _, path := range revertedPaths {
+			if strings.HasPrefix(path, dir.Path+string(filepath.Separator)) {
+				_ = changeset.Revert(mc.Source, dir, stash)
+				break
+			}
+		}
+	}
+	return kept, reverted, quit
+}
+
+// changePaths formats a change's path below root, with where a renamed file
+// was before it
+func changePaths(c changeset.Change, root string) string {
+	if c.Type == "renamed" {
+		return filepath.Join(root, c.OldPath) + " → " + filepath.Join(root, c.Path)
+	}
+	return filepath.Join(root, c.Path)
+}
+
+// showChangeDiff prints one change as a patch, or why it can't
+func showChangeDiff(w io.Writer, c changeset.Change, stash string) {
+	skipped, err := changeset.WritePatch(w, changeset.MountChanges{Changes: []changeset.Change{c}}, stash)
+	if err != nil {
+		fmt.Fprintf(w, "  failed to show diff: %v\n", err)
+		return
+	}
+	if len(skipped) > 0 {
+		fmt.Fprintln(w, "  no diff: binary, or no pre-session copy was kept (see changeset.keep_contents)")
+	}
+}
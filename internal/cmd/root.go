@@ -2,7 +2,12 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 
+	"github.com/faize-ai/faize/internal/config"
+	"github.com/faize-ai/faize/internal/redact"
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/faize-ai/faize/internal/vm"
 	"github.com/spf13/cobra"
 )
 
@@ -14,7 +19,7 @@ var (
 // Debug prints a message if debug mode is enabled
 func Debug(format string, args ...interface{}) {
 	if debug {
-		fmt.Printf("[DEBUG] "+format+"\n", args...)
+		fmt.Println(redact.String(fmt.Sprintf("[DEBUG] "+format, args...)))
 	}
 }
 
@@ -30,8 +35,17 @@ Start a Claude Code session:
 List running sessions:
   faize ps
 
+Run a command in a running session:
+  faize exec <session-id> -- git status
+
 Manage sessions:
-  faize kill <session-id>
+  faize session list|start|stop|attach|exec|ssh|cp|pause|resume|inspect|rename|extend|rm|logs|events
+  faize stop <session-id>
+  faize extend <session-id> 1h
+  faize logs -f <session-id>
+  faize network pcap <session-id>
+  faize warm
+  faize kill
   faize prune`,
 }
 
@@ -41,7 +55,7 @@ func Execute() error {
 }
 
 func init() {
-	cobra.OnInitialize(initConfig)
+	cobra.OnInitialize(initConfig, reconcileSessions)
 
 	// Persistent flags (available to all subcommands)
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is ~/.faize/config.yaml)")
@@ -49,5 +63,29 @@ func init() {
 }
 
 func initConfig() {
-	// Config is loaded on-demand in subcommands
+	// Config is loaded on-demand in subcommands; only redaction applies globally.
+	// Load errors are reported by the subcommands that need the config.
+	cfg, err := config.Load("")
+	if err != nil {
+		return
+	}
+	if err := redact.Configure(cfg.Redact.Patterns); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v (using built-in redaction patterns only)\n", err)
+	}
+}
+
+// reconcileSessions marks sessions stopped whose owner process crashed or
+// didn't survive a reboot, so they aren't shown as running
+func reconcileSessions() {
+	store, err := session.NewStore()
+	if err != nil {
+		return
+	}
+	lost, err := vm.ReconcileSessions(store)
+	if err != nil {
+		Debug("Failed to reconcile sessions: %v", err)
+	}
+	for _, sess := range lost {
+		Debug("Session %s lost its owner process; marked stopped", sess.ID)
+	}
 }
@@ -0,0 +1,386 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/faize-ai/faize/internal/changeset"
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/faize-ai/faize/internal/redact"
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/faize-ai/faize/internal/vm"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	runPrompt     string
+	runPromptArgs []string
+	runTasksFile  string
+	runKeepGoing  bool
+	runTasks      []guest.Task
+	runOutput     io.Writer = os.Stdout // where Claude's output goes; faize's own messages go to stderr
+)
+
+// taskResult is the outcome of one task of a task queue, saved to
+// tasks.json in the session directory
+type taskResult struct {
+	Name      string                   `json:"name"`
+	ExitCode  int                      `json:"exit_code"`
+	StartedAt time.Time                `json:"started_at"`
+	EndedAt   time.Time                `json:"ended_at"`
+	Changes   []changeset.MountChanges `json:"changes"` // files the task changed in project mounts
+}
+
+var runCmd = &cobra.Command{
+	Use:   "run (-p <prompt> | --tasks <file>) [flags] [-- claude-args...]",
+	Short: "Run Claude non-interactively with a prompt",
+	Long: `Boot a session, run 'claude -p' with the prompt in the project, stream
+Claude's output to stdout, and exit with Claude's exit status. Nothing is
+attached to the terminal, so it works in scripts and CI.
+
+Only Claude's output is written to stdout; faize's messages and the change
+summary go to stderr. Arguments after -- are passed to Claude. With -p -,
+the prompt is read from stdin.
+
+With --tasks, run a queue of prompts from a YAML or JSON file one after
+another in the same VM:
+
+  tasks:
+    - name: tests
+      prompt: fix the failing tests
+    - name: docs
+      prompt: update the README for the changes
+      args: [--model, sonnet]
+
+The files each task changed and its exit code are saved to tasks.json in the
+session directory. The queue stops at the first failing task unless
+--keep-going is set; faize exits with the first failure's status.
+
+Examples:
+  faize run -p "fix the failing tests"
+  faize run -p "summarize the open TODOs" -- --output-format json
+  git diff | faize run -p - --project ~/code/myapp --timeout 30m
+  faize run --tasks tasks.yaml --keep-going`,
+	RunE: runRun,
+}
+
+func init() {
+	runCmd.Flags().StringVarP(&runPrompt, "prompt", "p", "", "prompt for Claude; - reads it from stdin")
+	runCmd.Flags().StringVar(&startProjectDir, "project", "", "project directory to mount (default: current directory)")
+	runCmd.Flags().StringArrayVarP(&startMounts, "mount", "m", []string{}, "additional mount paths (repeatable)")
+	runCmd.Flags().StringVarP(&startTimeout, "timeout", "t", "", "session timeout (e.g., 30m)")
+	runCmd.Flags().StringVar(&startIdleTimeout, "idle-timeout", "", "stop the session after this long without console output or file changes (e.g., 10m)")
+	runCmd.Flags().StringVar(&startName, "name", "", "name the session, usable in place of its ID")
+	runCmd.Flags().StringArrayVar(&startLabels, "label", []string{}, "label the session with KEY=VALUE (repeatable, see 'faize ps --filter')")
+	runCmd.Flags().BoolVar(&startPersistCreds, "persist-credentials", false, "persist Claude credentials across sessions")
+	runCmd.Flags().BoolVar(&startNoDiff, "no-diff", false, "disable change tracking and summary")
+	runCmd.Flags().BoolVar(&startGitBranch, "git-branch", false, "commit the session's project changes to a faize/session-<id> branch")
+	runCmd.Flags().StringVar(&startWorktree, "worktree", "", "run the session in a git worktree with this branch checked out, created if needed")
+	runCmd.Flags().BoolVar(&startForce, "force", false, "start even if the network allowlist has errors")
+	runCmd.Flags().BoolVarP(&startYes, "yes", "y", false, "replace corrupt kernel or rootfs images without asking")
+	runCmd.Flags().StringVar(&startOutput, "output", "text", "output format: text, or json-stream for lifecycle events as NDJSON on stderr")
+	runCmd.Flags().StringVar(&runTasksFile, "tasks", "", "run the prompts in this YAML or JSON file one after another")
+	runCmd.Flags().BoolVar(&runKeepGoing, "keep-going", false, "with --tasks, run the remaining tasks after one fails")
+	runCmd.MarkFlagsMutuallyExclusive("prompt", "tasks")
+	runCmd.MarkFlagsOneRequired("prompt", "tasks")
+	rootCmd.AddCommand(runCmd)
+}
+
+func runRun(cmd *cobra.Command, args []string) error {
+	if dash := cmd.ArgsLenAtDash(); dash != 0 && len(args) > 0 {
+		return fmt.Errorf("unexpected argument %q; pass Claude arguments after --", args[0])
+	}
+	runPromptArgs = args
+	if runTasksFile != "" {
+		tasks, err := loadTasks(runTasksFile, args)
+		if err != nil {
+			return err
+		}
+		runTasks = tasks
+		return runHeadless()
+	}
+	if runPrompt == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read the prompt from stdin: %w", err)
+		}
+		runPrompt = string(data)
+	}
+	if strings.TrimSpace(runPrompt) == "" {
+		return fmt.Errorf("the prompt is empty")
+	}
+	return runHeadless()
+}
+
+// runHeadless runs the session with only Claude's output on stdout and exits
+// with its status
+func runHeadless() error {
+	if err := configureOutput(); err != nil {
+		return err
+	}
+	// Keep stdout for Claude's output so it can be piped. With json-stream,
+	// the events replace faize's messages on stderr.
+	stdout := os.Stdout
+	runOutput = stdout
+	os.Stdout = os.Stderr
+	if events != nil {
+		devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", os.DevNull, err)
+		}
+		defer func() { _ = devNull.Close() }()
+		os.Stdout = devNull
+	}
+	err := startSession(false)
+	os.Stdout = stdout
+	if err != nil {
+		return err
+	}
+	if sessionExitCode != 0 {
+		os.Exit(sessionExitCode)
+	}
+	return nil
+}
+
+// loadTasks reads a task queue: a YAML or JSON file with a list of tasks,
+// under "tasks" or at the top level. args are added to every task's
+// arguments. Tasks without a name are numbered.
+func loadTasks(path string, args []string) ([]guest.Task, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tasks: %w", err)
+	}
+	type task struct {
+		Name   string   `yaml:"name"`
+		Prompt string   `yaml:"prompt"`
+		Args   []string `yaml:"args"`
+	}
+	var file struct {
+		Tasks []task `yaml:"tasks"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		// A bare list of tasks
+		if listErr := yaml.Unmarshal(data, &file.Tasks); listErr != nil {
+			return nil, fmt.Errorf("invalid tasks file %s: %w", path, err)
+		}
+	}
+	if len(file.Tasks) == 0 {
+		return nil, fmt.Errorf("no tasks in %s", path)
+	}
+
+	tasks := make([]guest.Task, len(file.Tasks))
+	for i, t := range file.Tasks {
+		if strings.TrimSpace(t.Prompt) == "" {
+			return nil, fmt.Errorf("task %d in %s has no prompt", i+1, path)
+		}
+		name := t.Name
+		if name == "" {
+			name = fmt.Sprintf("task %d", i+1)
+		}
+		tasks[i] = guest.Task{Name: name, Prompt: t.Prompt, Args: append(t.Args, args...)}
+	}
+	return tasks, nil
+}
+
+// summary describes a task's outcome in one line
+func (r taskResult) summary() string {
+	files := 0
+	for _, mc := range r.Changes {
+		files += len(mc.Changes)
+	}
+	return fmt.Sprintf("%s: exit %d, %d file(s) changed in %s", r.Name, r.ExitCode, files, r.EndedAt.Sub(r.StartedAt).Round(time.Second))
+}
+
+// queueExitCode returns the status of the first failed task, or 0. A queue
+// that stopped before its last task without a failure exits with 1.
+func queueExitCode(results []taskResult) int {
+	for _, r := range results {
+		if r.ExitCode != 0 {
+			return r.ExitCode
+		}
+	}
+	if len(results) < len(runTasks) {
+		return 1
+	}
+	return 0
+}
+
+// saveTaskResults writes a task queue's results to tasks.json in the session directory
+func saveTaskResults(sessionDir string, results []taskResult) {
+	if results == nil {
+		results = []taskResult{}
+	}
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err == nil {
+		err = os.WriteFile(filepath.Join(sessionDir, "tasks.json"), data, 0600)
+	}
+	if err != nil {
+		Debug("Failed to save task results: %v", err)
+	}
+}
+
+// streamRunOutput copies the output of a faize run session to runOutput and
+// stderr as the guest writes it, until the VM stops or faize is interrupted,
+// and returns Claude's exit code
+func streamRunOutput(manager vm.Manager, sess *session.Session, bootstrapDir string) (code int, killed bool) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(sigCh)
+	stopped := manager.WaitForVMStop(sess.ID)
+
+	code, finished, killed := followRun(bootstrapDir, stopped, sigCh)
+	if killed {
+		return 1, true
+	}
+	if !finished {
+		fmt.Printf("Claude did not finish; see 'faize logs --boot %s'\n", sess.ID)
+		return 1, false
+	}
+	// The guest records its changes while shutting down
+	select {
+	case <-stopped:
+	case <-sigCh:
+		return code, true
+	}
+	return code, false
+}
+
+// followRun copies the output files in dir to runOutput and stderr as the
+// guest writes them, until the exit file appears, the VM stops, or faize is
+// interrupted. Returns Claude's exit code (1 if it couldn't start) and
+// whether it finished.
+func followRun(dir string, stopped <-chan struct{}, sigCh <-chan os.Signal) (code int, finished, killed bool) {
+	stdout := &followedFile{path: filepath.Join(dir, guest.RunOutputFile), w: redact.NewWriter(runOutput, nil)}
+	stderr := &followedFile{path: filepath.Join(dir, guest.RunErrorFile), w: redact.NewWriter(os.Stderr, nil)}
+	defer stdout.close()
+	defer stderr.close()
+
+	ticker := time.NewTicker(logsPollInterval)
+	defer ticker.Stop()
+	exitPath := filepath.Join(dir, guest.RunExitFile)
+	for {
+		vmStopped := false
+		select {
+		case <-stopped:
+			vmStopped = true
+		case sig := <-sigCh:
+			fmt.Printf("Received %s\n", sig)
+			return 0, false, true
+		case <-ticker.C:
+		}
+		// The exit code is written after the output is complete
+		code, ok := readExitCode(exitPath)
+		stdout.copy()
+		stderr.copy()
+		if ok {
+			return code, true, false
+		}
+		if vmStopped {
+			return 0, false, false
+		}
+	}
+}
+
+// readExitCode reads an exit code the guest recorded, reporting whether
+// there was one. Codes the guest couldn't determine become 1.
+func readExitCode(path string) (int, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	code, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || code < 0 {
+		code = 1
+	}
+	return code, true
+}
+
+// runTaskQueue streams each task's output as the guest runs it. After a task
+// exits, changes records the files it changed, and the guest is told to run
+// the next task, or to stop after a failure unless runKeepGoing is set.
+// Returns the results of the tasks that ran.
+func runTaskQueue(manager vm.Manager, sess *session.Session, bootstrapDir string, changes func() []changeset.MountChanges) (results []taskResult, killed bool) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(sigCh)
+	stopped := manager.WaitForVMStop(sess.ID)
+
+	started := time.Now()
+	for i, task := range runTasks {
+		fmt.Printf("\n==> Task %d/%d: %s\n", i+1, len(runTasks), task.Name)
+		dir := filepath.Join(bootstrapDir, guest.TaskDir(i))
+		code, finished, killed := followRun(dir, stopped, sigCh)
+		if killed {
+			return results, true
+		}
+		if !finished {
+			fmt.Printf("Task %d did not finish; see 'faize logs --boot %s'\n", i+1, sess.ID)
+			return results, false
+		}
+
+		result := taskResult{Name: task.Name, ExitCode: code, StartedAt: started, EndedAt: time.Now()}
+		if changes != nil {
+			result.Changes = changes()
+		}
+		results = append(results, result)
+		fmt.Printf("Task %d/%d %s\n", i+1, len(runTasks), result.summary())
+		started = result.EndedAt
+
+		if i == len(runTasks)-1 {
+			break
+		}
+		next := guest.TaskContinue
+		if code != 0 && !runKeepGoing {
+			fmt.Printf("Stopping the queue after task %d failed (--keep-going runs the rest)\n", i+1)
+			next = guest.TaskStop
+		}
+		if err := os.WriteFile(filepath.Join(dir, guest.TaskNextFile), []byte(next+"\n"), 0644); err != nil {
+			fmt.Printf("Failed to start the next task: %v\n", err)
+			return results, false
+		}
+		if next == guest.TaskStop {
+			break
+		}
+	}
+
+	select {
+	case <-stopped:
+	case <-sigCh:
+		return results, true
+	}
+	return results, false
+}
+
+// followedFile copies what is appended to a file that may not exist yet
+type followedFile struct {
+	path string
+	f    *os.File
+	w    *redact.Writer
+}
+
+func (t *followedFile) copy() {
+	if t.f == nil {
+		f, err := os.Open(t.path)
+		if err != nil {
+			return
+		}
+		t.f = f
+	}
+	_, _ = io.Copy(t.w, t.f)
+}
+
+func (t *followedFile) close() {
+	_ = t.w.Flush()
+	if t.f != nil {
+		_ = t.f.Close()
+	}
+}
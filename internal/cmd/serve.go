@@ -0,0 +1,278 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/faize-ai/faize/internal/api"
+	"github.com/faize-ai/faize/internal/changeset"
+	"github.com/faize-ai/faize/internal/metrics"
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/faize-ai/faize/internal/vm"
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+)
+
+// serveStopTimeout is how long a stop request waits for the guest to shut down
+const serveStopTimeout = 30 * time.Second
+
+var (
+	serveSocket  string
+	serveMetrics string
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the local session API (faized)",
+	Long: `Run faized, a long-running server exposing sessions over an HTTP API on a
+Unix socket only the current user can connect to, for editors and other
+frontends.
+
+Sessions started through the API are owned by background processes, like
+'faize start --detach', so they keep running when the server stops.
+
+Endpoints:
+  GET    /v1/sessions               list sessions
+  POST   /v1/sessions               start a session: {"project", "mounts", "timeout", "worktree"}
+  GET    /v1/sessions/{id}          show a session
+  DELETE /v1/sessions/{id}          stop a session (?force=true skips the guest's cleanup)
+  GET    /v1/sessions/{id}/diff     the changes recorded when the session ended
+  GET    /v1/sessions/{id}/logs     the console log, from ?offset= on
+  GET    /v1/sessions/{id}/network  the network activity logged so far
+  POST   /v1/sessions/{id}/allow    add to the network allowlist: {"entry"}
+  POST   /v1/sessions/{id}/resize   set the console size: {"cols", "rows"}
+  GET    /v1/sessions/{id}/attach   with 'Upgrade: faize-console', the raw console stream
+
+With --metrics, also serve per-session metrics in the Prometheus format at
+/metrics on a loopback address: uptime, boot time, console output, network
+events by action, and changeset sizes.
+
+Examples:
+  faize serve
+  faize serve --metrics 127.0.0.1:9464
+  curl --unix-socket ~/.faize/faized.sock http://faize/v1/sessions`,
+	Args: cobra.NoArgs,
+	RunE: runServe,
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&serveSocket, "socket", "", "Unix socket to listen on (default: ~/.faize/faized.sock)")
+	serveCmd.Flags().StringVar(&serveMetrics, "metrics", "", "serve Prometheus metrics at /metrics on this loopback address (e.g. 127.0.0.1:9464)")
+	rootCmd.AddCommand(serveCmd)
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	path := serveSocket
+	if path == "" {
+		home, err := homedir.Dir()
+		if err != nil {
+			return fmt.Errorf("failed to get home directory: %w", err)
+		}
+		path = filepath.Join(home, ".faize", "faized.sock")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	store, err := session.NewStore()
+	if err != nil {
+		return fmt.Errorf("failed to open session store: %w", err)
+	}
+
+	var metricsListener net.Listener
+	if serveMetrics != "" {
+		if metricsListener, err = listenMetrics(serveMetrics); err != nil {
+			return err
+		}
+		defer func() { _ = metricsListener.Close() }()
+	}
+
+	l, err := api.Listen(path)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{
+		Handler:           api.NewServer(&localBackend{store: store, manager: newSessionManager()}),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if metricsListener != nil {
+		mux := http.NewServeMux()
+		mux.Handle("GET /metrics", metrics.Handler(store))
+		metricsSrv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := metricsSrv.Serve(metricsListener); err != nil && !errors.Is(err, net.ErrClosed) {
+				fmt.Fprintf(os.Stderr, "Warning: metrics server failed: %v\n", err)
+			}
+		}()
+		fmt.Printf("Serving metrics on http://%s/metrics\n", metricsListener.Addr())
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(sigCh)
+	go func() {
+		<-sigCh
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(ctx)
+	}()
+
+	fmt.Printf("Serving the faize API on %s\n", path)
+	if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("server failed: %w", err)
+	}
+	return nil
+}
+
+// listenMetrics listens on a loopback TCP address for the metrics endpoint,
+// which isn't authenticated
+func listenMetrics(addr string) (net.Listener, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --metrics address %q: %w", addr, err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("--metrics must listen on a loopback address such as 127.0.0.1, not %q", host)
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	return l, nil
+}
+
+// localBackend carries out API requests on this machine's sessions
+type localBackend struct {
+	store   *session.Store
+	manager vm.Manager
+}
+
+func (b *localBackend) List() ([]*session.Session, error) {
+	return b.store.List()
+}
+
+func (b *localBackend) Get(id string) (*session.Session, error) {
+	sess, err := b.store.Load(id)
+	if errors.Is(err, session.ErrNotFound) {
+		return nil, fmt.Errorf("%w: session %s", api.ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", api.ErrInvalid, err)
+	}
+	return sess, nil
+}
+
+// running loads a session that must be running
+func (b *localBackend) running(id string) (*session.Session, error) {
+	sess, err := b.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if sess.Status != "running" {
+		return nil, fmt.Errorf("%w: session %s is not running (status: %s)", api.ErrInvalid, id, sess.Status)
+	}
+	return sess, nil
+}
+
+func (b *localBackend) Start(req api.StartRequest) (*session.Session, error) {
+	if info, err := os.Stat(req.Project); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("%w: project %s is not a directory", api.ErrInvalid, req.Project)
+	}
+	args := []string{"start", "--project", req.Project, "--yes"}
+	for _, m := range req.Mounts {
+		args = append(args, "--mount", m)
+	}
+	if req.Timeout != "" {
+		if _, err := time.ParseDuration(req.Timeout); err != nil {
+			return nil, fmt.Errorf("%w: invalid timeout %q", api.ErrInvalid, req.Timeout)
+		}
+		args = append(args, "--timeout", req.Timeout)
+	}
+	if req.Worktree != "" {
+		args = append(args, "--worktree", req.Worktree)
+	}
+	if cfgFile != "" {
+		args = append(args, "--config", cfgFile)
+	}
+	id, err := startDetached(append(args, "--daemon"))
+	if err != nil {
+		return nil, err
+	}
+	return b.store.Load(id)
+}
+
+func (b *localBackend) Stop(id string, force bool) error {
+	sess, err := b.Get(id)
+	if err != nil {
+		return err
+	}
+	if sess.Status != "running" && sess.Status != "paused" {
+		return fmt.Errorf("%w: session %s is not running (status: %s)", api.ErrInvalid, id, sess.Status)
+	}
+	if sess.Status == "running" && !force {
+		if err := vm.Shutdown(b.store, id, serveStopTimeout); err == nil {
+			return nil
+		}
+	}
+	return b.manager.Stop(id)
+}
+
+func (b *localBackend) Allow(id, entry string) (string, error) {
+	spec := strings.TrimSpace(strings.ToLower(entry))
+	if err := checkAllowSpec(spec); err != nil {
+		return "", fmt.Errorf("%w: %v", api.ErrInvalid, err)
+	}
+	sess, err := b.Get(id)
+	if err != nil {
+		return "", err
+	}
+	return allowNetwork(b.store, sess, spec)
+}
+
+func (b *localBackend) Changeset(id string) (*changeset.SessionChangeset, error) {
+	if _, err := b.Get(id); err != nil {
+		return nil, err
+	}
+	cs, err := changeset.LoadChangeset(filepath.Join(b.store.Dir(), id, "bootstrap", "changeset.json"))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: no changes recorded for session %s (it may still be running, or change tracking was off)", api.ErrNotFound, id)
+	}
+	return cs, err
+}
+
+func (b *localBackend) ConsoleSocket(id string) (string, error) {
+	if _, err := b.running(id); err != nil {
+		return "", err
+	}
+	return filepath.Join(b.store.Dir(), id+".sock"), nil
+}
+
+func (b *localBackend) ConsoleLog(id string) (string, error) {
+	if _, err := b.Get(id); err != nil {
+		return "", err
+	}
+	return filepath.Join(b.store.Dir(), id, "console.log"), nil
+}
+
+func (b *localBackend) Network(id string) ([]changeset.NetworkEvent, error) {
+	if _, err := b.Get(id); err != nil {
+		return nil, err
+	}
+	return changeset.CollectNetworkEvents(filepath.Join(b.store.Dir(), id, "bootstrap"))
+}
+
+func (b *localBackend) Resize(id string, cols, rows int) error {
+	if _, err := b.running(id); err != nil {
+		return err
+	}
+	path := filepath.Join(b.store.Dir(), id, "bootstrap", "termsize")
+	return os.WriteFile(path, []byte(fmt.Sprintf("%d %d", cols, rows)), 0644)
+}
@@ -0,0 +1,491 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/faize-ai/faize/internal/changeset"
+	"github.com/faize-ai/faize/internal/git"
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/faize-ai/faize/internal/vm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	sessionRmForce     bool
+	sessionEventsJSON  bool
+	sessionAuditFormat string
+	sessionAuditOutput string
+)
+
+var sessionCmd = &cobra.Command{
+	Use:     "session",
+	Aliases: []string{"sessions"},
+	Short:   "Manage VM sessions",
+	Long: `Manage faize VM sessions.
+
+Commands:
+  list     List sessions                      (alias: faize ps)
+  start    Start a new session                (alias: faize start)
+  stop     Stop running sessions
+  attach   Attach to a running session        (alias: faize attach)
+  exec     Run a command in a running session (alias: faize exec)
+  ssh      Open an SSH shell in a session     (alias: faize ssh)
+  cp       Copy files to or from a session    (alias: faize cp)
+  pause    Save a running session to disk     (alias: faize pause)
+  resume   Resume a paused session            (alias: faize resume)
+  inspect  Show session details               (alias: faize inspect)
+  rename   Rename a session                   (alias: faize rename)
+  extend   Extend a running session's timeout (alias: faize extend)
+  rm       Remove session metadata            (see also: faize kill, faize prune)
+  logs     Show a session's console log       (alias: faize logs)
+  events   Show a session's network events
+  audit    Export a session's audit log
+
+Examples:
+  faize session list
+  faize session start --detach
+  faize session attach abc123
+  faize session stop abc123`,
+}
+
+var sessionListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List sessions",
+	Args:    cobra.NoArgs,
+	RunE:    runPs,
+}
+
+var sessionStartCmd = &cobra.Command{
+	Use:   "start",
+	Short: "Start a new session",
+	Long:  startCmd.Long,
+	Args:  cobra.NoArgs,
+	RunE:  runStart,
+}
+
+var sessionStopCmd = &cobra.Command{
+	Use:   "stop <session-id>...",
+	Short: "Stop running sessions",
+	Long:  stopCmd.Long,
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runStop,
+}
+
+var sessionAttachCmd = &cobra.Command{
+	Use:   "attach <session-id>",
+	Short: "Attach to a running session's console",
+	Long:  attachCmd.Long,
+	Args:  cobra.ExactArgs(1),
+	RunE:  runAttach,
+}
+
+var sessionExecCmd = &cobra.Command{
+	Use:   "exec <session-id> -- <command> [args...]",
+	Short: "Run a command inside a running session",
+	Long:  execCmd.Long,
+	Args:  cobra.MinimumNArgs(2),
+	RunE:  runExec,
+}
+
+var sessionSSHCmd = &cobra.Command{
+	Use:   "ssh <session-id> [-- command [args...]]",
+	Short: "Open an SSH shell in a running session",
+	Long:  sshCmd.Long,
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runSSH,
+}
+
+var sessionCpCmd = &cobra.Command{
+	Use:   "cp <session-id>:<path> <local-path> | <local-path> <session-id>:<path>",
+	Short: "Copy files between the host and a running session",
+	Long:  cpCmd.Long,
+	Args:  cobra.ExactArgs(2),
+	RunE:  runCp,
+}
+
+var sessionPauseCmd = &cobra.Command{
+	Use:   "pause <session-id>",
+	Short: "Save a running session to disk",
+	Long:  pauseCmd.Long,
+	Args:  cobra.ExactArgs(1),
+	RunE:  runPause,
+}
+
+var sessionResumeCmd = &cobra.Command{
+	Use:   "resume <session-id>",
+	Short: "Resume a paused session",
+	Long:  resumeCmd.Long,
+	Args:  cobra.ExactArgs(1),
+	RunE:  runResume,
+}
+
+var sessionInspectCmd = &cobra.Command{
+	Use:   "inspect <session-id>",
+	Short: "Show detailed information about a session",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runInspect,
+}
+
+var sessionRenameCmd = &cobra.Command{
+	Use:   "rename <session-id> <name>",
+	Short: "Rename a session",
+	Long:  renameCmd.Long,
+	Args:  cobra.ExactArgs(2),
+	RunE:  runRename,
+}
+
+var sessionExtendCmd = &cobra.Command{
+	Use:   "extend <session-id> <duration>",
+	Short: "Extend a running session's timeout",
+	Long:  extendCmd.Long,
+	Args:  cobra.ExactArgs(2),
+	RunE:  runExtend,
+}
+
+var sessionResizeCmd = &cobra.Command{
+	Use:   "resize <session-id> [--cpus N] [--memory SIZE]",
+	Short: "Change a running session's CPUs and memory",
+	Long:  resizeCmd.Long,
+	Args:  cobra.ExactArgs(1),
+	RunE:  runResize,
+}
+
+var sessionRmCmd = &cobra.Command{
+	Use:   "rm <session-id>...",
+	Short: "Remove session metadata",
+	Long: `Remove one or more sessions from the session store.
+
+Running sessions are skipped unless --force is given, in which case they
+are stopped first.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runSessionRm,
+}
+
+var sessionLogsCmd = &cobra.Command{
+	Use:   "logs <session-id>",
+	Short: "Show a session's console log",
+	Long:  logsCmd.Long,
+	Args:  cobra.ExactArgs(1),
+	RunE:  runLogs,
+}
+
+var sessionEventsCmd = &cobra.Command{
+	Use:   "events <session-id>",
+	Short: "Show a session's network events",
+	Long: `Show DNS queries, connections, and denied connections recorded by the
+guest firewall for a session.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSessionEvents,
+}
+
+var sessionAuditCmd = &cobra.Command{
+	Use:   "audit <session-id>",
+	Short: "Export a session's audit log",
+	Long: `Export one chronological audit log of a session for review: DNS queries,
+allowed and denied connections, file changes in mounts, and files changed
+in the guest.
+
+The log is saved to the session directory when the session ends; for a
+running session it is built from the logs recorded so far. File changes
+are only included when change tracking (--diff) was on, and guest changes
+are listed when the session ends.
+
+Examples:
+  faize session audit abc123
+  faize session audit abc123 --format csv -o audit.csv`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSessionAudit,
+}
+
+func init() {
+	addPsFlags(sessionListCmd)
+	addStartFlags(sessionStartCmd)
+	addExecFlags(sessionExecCmd)
+	addSSHFlags(sessionSSHCmd)
+	addResumeFlags(sessionResumeCmd)
+	addLogsFlags(sessionLogsCmd)
+	addStopFlags(sessionStopCmd)
+	addResizeFlags(sessionResizeCmd)
+	sessionInspectCmd.Flags().BoolVar(&inspectJSON, "json", false, "output in JSON format")
+	sessionRmCmd.Flags().BoolVarP(&sessionRmForce, "force", "f", false, "stop and remove running sessions")
+	sessionEventsCmd.Flags().BoolVar(&sessionEventsJSON, "json", false, "output in JSON format")
+	sessionAuditCmd.Flags().StringVar(&sessionAuditFormat, "format", "json", "output format: json or csv")
+	sessionAuditCmd.Flags().StringVarP(&sessionAuditOutput, "output", "o", "", "output file (default: stdout)")
+
+	sessionCmd.AddCommand(
+		sessionListCmd,
+		sessionStartCmd,
+		sessionStopCmd,
+		sessionAttachCmd,
+		sessionExecCmd,
+		sessionSSHCmd,
+		sessionCpCmd,
+		sessionPauseCmd,
+		sessionResumeCmd,
+		sessionInspectCmd,
+		sessionRenameCmd,
+		sessionExtendCmd,
+		sessionResizeCmd,
+		sessionRmCmd,
+		sessionLogsCmd,
+		sessionEventsCmd,
+		sessionAuditCmd,
+	)
+	rootCmd.AddCommand(sessionCmd)
+}
+
+// newSessionManager returns the platform VM manager, falling back to the stub
+func newSessionManager() vm.Manager {
+	manager, err := vm.NewManager()
+	if err != nil {
+		return vm.NewStubManager()
+	}
+	return manager
+}
+
+// resolveSessionRef returns the ID of the session named ref (faize start
+// --name); anything else is returned as given, for the command to report
+func resolveSessionRef(ref string) string {
+	store, err := session.NewStore()
+	if err != nil {
+		return ref
+	}
+	if id, err := store.Resolve(ref); err == nil {
+		return id
+	}
+	return ref
+}
+
+func runSessionRm(cmd *cobra.Command, args []string) error {
+	store, err := session.NewStore()
+	if err != nil {
+		return fmt.Errorf("failed to access session store: %w", err)
+	}
+	manager := newSessionManager()
+
+	var failed int
+	for _, ref := range args {
+		id := resolveSessionRef(ref)
+		sess, err := store.Load(id)
+		if err != nil {
+			fmt.Printf("Warning: %v\n", err)
+			failed++
+			continue
+		}
+		if sess.Status == "running" || sess.Status == "paused" {
+			if !sessionRmForce {
+				fmt.Printf("Skipped %s session %s. Use --force to stop and remove it.\n", sess.Status, id)
+				continue
+			}
+			if err := manager.Stop(id); err != nil && err != vm.ErrVMNotImplemented {
+				fmt.Printf("Warning: failed to stop session %s: %v\n", id, err)
+				// Continue to delete session metadata even if stop fails
+			}
+		}
+		if err := store.Delete(id); err != nil {
+			fmt.Printf("Warning: failed to delete session %s: %v\n", id, err)
+			failed++
+			continue
+		}
+		fmt.Printf("Removed session: %s\n", id)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to remove %d session(s)", failed)
+	}
+	return nil
+}
+
+func runSessionEvents(cmd *cobra.Command, args []string) error {
+	store, err := session.NewStore()
+	if err != nil {
+		return fmt.Errorf("failed to open session store: %w", err)
+	}
+	id := resolveSessionRef(args[0])
+	if _, err := store.Load(id); err != nil {
+		return err
+	}
+
+	bootstrapDir := filepath.Join(store.Dir(), id, "bootstrap")
+	events, err := changeset.CollectNetworkEvents(bootstrapDir)
+	if err != nil {
+		return fmt.Errorf("failed to read network events: %w", err)
+	}
+
+	if sessionEventsJSON {
+		if events == nil {
+			events = []changeset.NetworkEvent{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(events)
+	}
+
+	if len(events) == 0 {
+		fmt.Println("No network events recorded.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "TIME\tACTION\tPROTO\tDESTINATION\tDOMAIN")
+	for _, e := range events {
+		dest := "-"
+		if e.DstIP != "" {
+			dest = fmt.Sprintf("%s:%d", e.DstIP, e.DstPort)
+		} else if e.DstPort != 0 {
+			// Egress proxy events are logged by host name
+			dest = fmt.Sprintf("%s:%d", e.Domain, e.DstPort)
+		}
+		proto := e.Proto
+		if proto == "" {
+			proto = "-"
+		}
+		domain := e.Domain
+		if domain == "" {
+			domain = "-"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", e.Timestamp, e.Action, proto, dest, domain)
+	}
+	return w.Flush()
+}
+
+func runSessionAudit(cmd *cobra.Command, args []string) error {
+	if sessionAuditFormat != "json" && sessionAuditFormat != "csv" {
+		return fmt.Errorf("invalid --format %q: expected json or csv", sessionAuditFormat)
+	}
+	store, err := session.NewStore()
+	if err != nil {
+		return fmt.Errorf("failed to open session store: %w", err)
+	}
+	sess, err := store.Load(resolveSessionRef(args[0]))
+	if err != nil {
+		return err
+	}
+
+	sessionDir := filepath.Join(store.Dir(), sess.ID)
+	entries, err := changeset.LoadAudit(filepath.Join(sessionDir, changeset.AuditFile))
+	if os.IsNotExist(err) {
+		// Running, or ended before audit logs were saved
+		ended := time.Now()
+		if sess.StoppedAt != nil {
+			ended = *sess.StoppedAt
+		}
+		var mounts []changeset.MountChanges
+		if cs, err := changeset.LoadChangeset(filepath.Join(sessionDir, "bootstrap", "changeset.json")); err == nil {
+			mounts = cs.MountChanges
+		}
+		entries, err = changeset.BuildAudit(filepath.Join(sessionDir, "bootstrap"), mounts, sess.StartedAt, ended)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	out := os.Stdout
+	if sessionAuditOutput != "" {
+		f, err := os.OpenFile(sessionAuditOutput, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", sessionAuditOutput, err)
+		}
+		defer func() { _ = f.Close() }()
+		out = f
+	}
+
+	if sessionAuditFormat == "csv" {
+		return changeset.WriteAuditCSV(out, entries)
+	}
+	if entries == nil {
+		entries = []changeset.AuditEntry{}
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(entries)
+}
+
+// saveSessionAudit writes the audit log of a session that has ended to its
+// session directory. mounts is nil when change tracking was off.
+func saveSessionAudit(sess *session.Session, mounts []changeset.MountChanges) {
+	store, err := session.NewStore()
+	if err != nil || sess.StoppedAt == nil {
+		return
+	}
+	sessionDir := filepath.Join(store.Dir(), sess.ID)
+	entries, err := changeset.BuildAudit(filepath.Join(sessionDir, "bootstrap"), mounts, sess.StartedAt, *sess.StoppedAt)
+	if err != nil {
+		Debug("Failed to build audit log: %v", err)
+		return
+	}
+	if err := changeset.SaveAudit(filepath.Join(sessionDir, changeset.AuditFile), entries); err != nil {
+		Debug("Failed to save audit log: %v", err)
+	}
+}
+
+// commitSessionBranch commits the changes in a session's project mounts to a
+// faize/session-<id> branch of the repository recorded when it started. The
+// repository is checked first, since the guest could have planted or
+// rewritten a .git or changed the config git would use on the host.
+func commitSessionBranch(sess *session.Session, mounts []changeset.MountChanges) {
+	repo := sessionRepo(sess)
+	if repo == nil {
+		Debug("Not committing session changes: no git repository was recorded when session %s started", sess.ID)
+		return
+	}
+	if err := repo.Check(sess.GitConfig); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: not committing session changes in %s: %v\n", repo.Root, err)
+		return
+	}
+
+	var paths []string
+	var committed []changeset.MountChanges
+	for _, mc := range mounts {
+		if !changeset.IsProjectMount(mc.Target) || len(mc.Changes) == 0 {
+			continue
+		}
+		source := mc.Source
+		if real, err := filepath.EvalSymlinks(source); err == nil {
+			source = real
+		}
+		if !matchesTargets(source, []string{repo.Root}) {
+			Debug("Not committing changes in %s: outside %s", mc.Source, repo.Root)
+			continue
+		}
+		committed = append(committed, mc)
+		for _, c := range mc.Changes {
+			names := []string{c.Path}
+			if c.Type == "renamed" {
+				names = append(names, c.OldPath)
+			}
+			for _, name := range names {
+				if rel, err := filepath.Rel(repo.Root, filepath.Join(source, name)); err == nil {
+					paths = append(paths, rel)
+				}
+			}
+		}
+	}
+	if len(committed) == 0 {
+		return
+	}
+
+	branch := "faize/session-" + sess.ID
+	commit, err := git.CommitToBranch(repo, branch, changeset.CommitMessage(sess.ID, committed), paths)
+	switch {
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "Warning: failed to commit session changes in %s: %v\n", repo.Root, err)
+	case commit != "":
+		fmt.Printf("Committed session changes to branch %s in %s\n", branch, repo.Root)
+	}
+}
+
+// sessionRepo returns the project repository recorded when a session
+// started, or nil if it had none or predates the record
+func sessionRepo(sess *session.Session) *git.Repo {
+	if sess.GitRoot == "" || sess.GitDir == "" {
+		return nil
+	}
+	return &git.Repo{Root: sess.GitRoot, GitDir: sess.GitDir}
+}
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/faize-ai/faize/internal/artifacts"
+	"github.com/faize-ai/faize/internal/changeset"
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/faize-ai/faize/internal/vm"
+	"github.com/spf13/cobra"
+)
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Save and manage rootfs snapshots",
+	Long: `Save a running session's rootfs changes, such as installed packages and
+configured tools, as a named snapshot that new sessions can start from with
+'faize start --from-snapshot <name>'.
+
+Commands:
+  save     Save a session's rootfs changes as a snapshot
+  ls       List snapshots
+  rm       Remove a snapshot`,
+}
+
+var snapshotSaveCmd = &cobra.Command{
+	Use:   "save <session-id> <name>",
+	Short: "Save a session's rootfs changes as a snapshot",
+	Long: `Save the rootfs changes of a running Claude session as a snapshot in
+~/.faize/snapshots. The guest's overlay, where everything the session wrote
+outside its mounts goes, is archived while the session keeps running; /tmp,
+/run, /mnt and the Claude configuration copied in at boot are left out.
+
+Unlike pausing, a snapshot keeps no memory or processes, and any number of
+sessions can start from it.
+
+Examples:
+  faize snapshot save abc123 node-toolchain
+  faize start --from-snapshot node-toolchain`,
+	Args: cobra.ExactArgs(2),
+	RunE: runSnapshotSave,
+}
+
+var snapshotLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List snapshots",
+	Args:  cobra.NoArgs,
+	RunE:  runSnapshotLs,
+}
+
+var snapshotRmCmd = &cobra.Command{
+	Use:   "rm <name>...",
+	Short: "Remove snapshots",
+	Long: `Remove snapshots. A snapshot can't be removed while a session that
+started from it is running or paused.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runSnapshotRm,
+}
+
+func init() {
+	snapshotCmd.AddCommand(snapshotSaveCmd)
+	snapshotCmd.AddCommand(snapshotLsCmd)
+	snapshotCmd.AddCommand(snapshotRmCmd)
+	rootCmd.AddCommand(snapshotCmd)
+}
+
+func runSnapshotSave(cmd *cobra.Command, args []string) error {
+	store, err := session.NewStore()
+	if err != nil {
+		return fmt.Errorf("failed to open session store: %w", err)
+	}
+	sess, err := store.Load(resolveSessionRef(args[0]))
+	if err != nil {
+		return fmt.Errorf("failed to load session: %w", err)
+	}
+	manager, err := artifacts.NewManager()
+	if err != nil {
+		return fmt.Errorf("failed to create artifact manager: %w", err)
+	}
+	rootfsPath := sess.Rootfs
+	if rootfsPath == "" {
+		rootfsPath = manager.FlavorRootfsPath(sess.Flavor)
+	}
+	snap, err := vm.SaveSnapshot(sess, args[1], rootfsPath)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Saved snapshot %s (%s) from session %s\n", snap.Name, changeset.FormatSize(snap.Size), sess.ID)
+	return nil
+}
+
+func runSnapshotLs(cmd *cobra.Command, args []string) error {
+	snaps, err := vm.ListSnapshots()
+	if err != nil {
+		return fmt.Errorf("failed to list snapshots: %w", err)
+	}
+	if len(snaps) == 0 {
+		fmt.Println("No snapshots.")
+		return nil
+	}
+	manager, err := artifacts.NewManager()
+	if err != nil {
+		return fmt.Errorf("failed to create artifact manager: %w", err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "NAME\tSAVED\tSIZE\tSESSION\tPROJECT")
+	for _, s := range snaps {
+		name := s.Name
+		if s.Outdated(manager.FlavorRootfsPath(s.Flavor)) {
+			name += " (outdated)"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			name, s.Created.Local().Format("2006-01-02 15:04"), changeset.FormatSize(s.Size), s.Session, s.Project)
+	}
+	return tw.Flush()
+}
+
+func runSnapshotRm(cmd *cobra.Command, args []string) error {
+	failed := 0
+	for _, name := range args {
+		snap, err := vm.LoadSnapshot(name)
+		if err != nil {
+			fmt.Printf("Warning: %v\n", err)
+			failed++
+			continue
+		}
+		if id := sessionHolding(func(s *session.Session) bool { return s.Snapshot == snap.Path }); id != "" {
+			fmt.Printf("Skipped snapshot %s: session %s started from it and hasn't stopped\n", name, id)
+			failed++
+			continue
+		}
+		if err := vm.RemoveSnapshot(snap); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+			failed++
+			continue
+		}
+		fmt.Printf("Removed snapshot: %s\n", name)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to remove %d snapshot(s)", failed)
+	}
+	return nil
+}
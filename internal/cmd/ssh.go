@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/faize-ai/faize/internal/vm"
+	"github.com/spf13/cobra"
+)
+
+var sshPrintConfig bool
+
+var sshCmd = &cobra.Command{
+	Use:   "ssh <session-id> [-- command [args...]]",
+	Short: "Open an SSH shell in a running session",
+	Long: `Open a shell in a running session over SSH, alongside the serial console.
+Unlike 'faize attach', each SSH connection is a separate terminal, so several
+shells (and scp or an editor) can use the session at once.
+
+Every session gets its own key pair and pinned host key, generated on the host
+when the session starts. sshd is only reachable on host loopback.
+
+With --print-config, print an ssh_config block instead. Append it to
+~/.ssh/config to use scp, rsync, or an editor's remote mode with the host
+name faize-<session-id>.
+
+Examples:
+  faize ssh abc123
+  faize ssh abc123 -- npm test
+  faize ssh abc123 --print-config >> ~/.ssh/config
+  scp faize-abc123:/tmp/report.txt .`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runSSH,
+}
+
+func init() {
+	addSSHFlags(sshCmd)
+	rootCmd.AddCommand(sshCmd)
+}
+
+// addSSHFlags registers the ssh flags on a command (faize ssh and faize session ssh)
+func addSSHFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&sshPrintConfig, "print-config", false, "print an ssh_config block for the session instead of connecting")
+}
+
+func runSSH(cmd *cobra.Command, args []string) error {
+	id := resolveSessionRef(args[0])
+	if dash := cmd.ArgsLenAtDash(); dash > 1 || (dash == -1 && len(args) > 1) {
+		return fmt.Errorf("usage: faize ssh <session-id> [-- command [args...]]")
+	}
+
+	store, err := session.NewStore()
+	if err != nil {
+		return fmt.Errorf("failed to open session store: %w", err)
+	}
+	sess, err := store.Load(id)
+	if err != nil {
+		return err
+	}
+
+	if sshPrintConfig {
+		config, err := vm.SSHConfig(sess)
+		if err != nil {
+			return err
+		}
+		fmt.Print(config)
+		return nil
+	}
+
+	if sess.Status != "running" {
+		return fmt.Errorf("session %s is not running (status: %s)", id, sess.Status)
+	}
+	sshArgs, err := vm.SSHArgs(sess, args[1:]...)
+	if err != nil {
+		return err
+	}
+	if len(args) == 1 {
+		sshArgs = append([]string{"-t"}, sshArgs...)
+	}
+
+	sshPath, err := exec.LookPath("ssh")
+	if err != nil {
+		return fmt.Errorf("ssh client not found: %w", err)
+	}
+	Debug("Running %s %v", sshPath, sshArgs)
+
+	ssh := exec.Command(sshPath, sshArgs...)
+	ssh.Stdin, ssh.Stdout, ssh.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := ssh.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			// Pass the remote exit code through to the caller
+			os.Exit(exitErr.ExitCode())
+		}
+		return fmt.Errorf("failed to run ssh: %w", err)
+	}
+	return nil
+}
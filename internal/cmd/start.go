@@ -1,32 +1,77 @@
 package cmd
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync/atomic"
 	"time"
 
+	"github.com/faize-ai/faize/internal/artifacts"
 	"github.com/faize-ai/faize/internal/changeset"
 	"github.com/faize-ai/faize/internal/config"
 	"github.com/faize-ai/faize/internal/git"
+	"github.com/faize-ai/faize/internal/guest"
 	"github.com/faize-ai/faize/internal/mount"
 	"github.com/faize-ai/faize/internal/network"
 	"github.com/faize-ai/faize/internal/session"
 	"github.com/faize-ai/faize/internal/vm"
 	"github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 var (
-	startProjectDir   string
-	startMounts       []string
-	startTimeout      string
-	startPersistCreds bool
-	startNoGitContext bool
-	startClaude       bool
-	startNoDiff       bool
+	startProjectDir    string
+	startMounts        []string
+	startTimeout       string
+	startIdleTimeout   string
+	startAutoSuspend   string
+	startWriteQuota    string
+	startPersistCreds  bool
+	startNoGitContext  bool
+	startClaude        bool
+	startNoDiff        bool
+	startReview        bool
+	startGitBranch     bool
+	startWorktree      string
+	startReplaceOldest bool
+	startDetach        bool
+	startDaemon        bool
+	startCaptureNet    bool
+	startNetLimit      string
+	startDisk          string
+	startPersistRootfs bool
+	startPersistHome   bool
+	startSyncSettings  bool
+	startSyncSkills    bool
+	startFromSnapshot  string
+	startKernel        string
+	startRootfs        string
+	startImage         string
+	startNoDevcont     bool
+	startAddHosts      []string
+	startPublish       []string
+	startForce         bool
+	startCold          bool
+	startYes           bool
+	startName          string
+	startLabels        []string
+
+	// sessionExitCode is faize's exit status after a foreground session:
+	// Claude's, or one of the codes below when the session ended first
+	sessionExitCode int
+)
+
+// Exit statuses for sessions that ended before Claude exited
+const (
+	exitTimeout = 124 // the session timeout or idle timeout expired, as with timeout(1)
+	exitDetach  = 125 // the console was detached with ~.
 )
 
 var startCmd = &cobra.Command{
@@ -44,28 +89,138 @@ This command automatically:
 Examples:
   faize start                              # uses current directory
   faize start --project ~/code/myapp
-  faize start -p ~/code/myapp`,
+  faize start -p ~/code/myapp
+  faize start --detach                     # run in the background, reattach with 'faize attach'
+  faize start --name auth                  # refer to it by name, as in 'faize attach auth'
+  faize start --publish 3000:3000          # reach a dev server at http://localhost:3000
+  faize start --net-limit 10mbit           # keep downloads from saturating the uplink
+  faize start --disk 20GB                  # fast local disk at /scratch for heavy builds
+  faize start --add-host db.local:10.0.0.5 # resolve a name the guest's DNS doesn't know
+  faize start --cold                       # boot a new VM even if a warm one is ready`,
 	RunE: runStart,
 }
 
 func init() {
-	startCmd.Flags().StringVarP(&startProjectDir, "project", "p", "", "project directory to mount (default: current directory)")
-	startCmd.Flags().StringArrayVarP(&startMounts, "mount", "m", []string{}, "additional mount paths (repeatable)")
-	startCmd.Flags().StringVarP(&startTimeout, "timeout", "t", "", "session timeout (e.g., 2h)")
-	startCmd.Flags().BoolVar(&startPersistCreds, "persist-credentials", false, "persist Claude credentials across sessions")
-	startCmd.Flags().BoolVar(&startNoGitContext, "no-git-context", false, "disable automatic .git directory mounting from git root")
-	startCmd.Flags().BoolVar(&startClaude, "claude", true, "use Claude Code mode")
-	startCmd.Flags().BoolVar(&startNoDiff, "no-diff", false, "disable change tracking and summary")
-
+	addStartFlags(startCmd)
 	rootCmd.AddCommand(startCmd)
 }
 
+// addStartFlags registers the start flags on cmd (shared by `start` and `session start`)
+func addStartFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&startProjectDir, "project", "p", "", "project directory to mount (default: current directory)")
+	cmd.Flags().StringArrayVarP(&startMounts, "mount", "m", []string{}, "additional mount paths (repeatable)")
+	cmd.Flags().StringVarP(&startTimeout, "timeout", "t", "", "session timeout (e.g., 2h)")
+	cmd.Flags().StringVar(&startIdleTimeout, "idle-timeout", "", "stop the session after this long without console output or file changes (e.g., 30m)")
+	cmd.Flags().StringVar(&startAutoSuspend, "auto-suspend", "", "pause the session after it has been detached and idle this long; it resumes on attach (e.g., 30m)")
+	cmd.Flags().StringVar(&startWriteQuota, "write-quota", "", "make a writable mount read-only in the guest once this much has been written to it (e.g., 10GB)")
+	cmd.Flags().StringVar(&startName, "name", "", "name the session, usable in place of its ID")
+	cmd.Flags().StringArrayVar(&startLabels, "label", []string{}, "label the session with KEY=VALUE (repeatable, see 'faize ps --filter')")
+	cmd.Flags().BoolVar(&startPersistCreds, "persist-credentials", false, "persist Claude credentials across sessions")
+	cmd.Flags().BoolVar(&startPersistRootfs, "persist-rootfs", false, "keep rootfs changes, such as installed packages, for the project's later sessions")
+	cmd.Flags().BoolVar(&startPersistHome, "persist-home", false, "keep /home/claude, such as shell history, caches and user-level tools, for the project's later sessions")
+	cmd.Flags().StringVar(&startFromSnapshot, "from-snapshot", "", "start from a rootfs snapshot saved with 'faize snapshot save'")
+	cmd.Flags().StringVar(&startKernel, "kernel", "", "boot this kernel image (ELF, ARM64 Image or bzImage) instead of the downloaded one")
+	cmd.Flags().StringVar(&startRootfs, "rootfs", "", "boot this ext4 rootfs image instead of the downloaded Claude rootfs")
+	cmd.Flags().StringVar(&startImage, "image", "", "boot a rootfs built from this OCI/Docker image, e.g. ghcr.io/org/dev:latest (needs Docker)")
+	cmd.Flags().BoolVar(&startSyncSettings, "sync-settings", false, "offer to write Claude settings changed in the session back to ~/.claude when it ends")
+	cmd.Flags().BoolVar(&startSyncSkills, "sync-skills", false, "offer to copy skills and plugins added or changed in the session back to ~/.claude when it ends")
+	cmd.Flags().BoolVar(&startNoGitContext, "no-git-context", false, "disable automatic .git directory mounting from git root")
+	cmd.Flags().BoolVar(&startNoDevcont, "no-devcontainer", false, "ignore the project's devcontainer.json")
+	cmd.Flags().BoolVar(&startClaude, "claude", true, "use Claude Code mode")
+	cmd.Flags().BoolVar(&startNoDiff, "no-diff", false, "disable change tracking and summary")
+	cmd.Flags().BoolVar(&startReview, "review", false, "keep or revert each changed file after the session (see 'faize review')")
+	cmd.Flags().BoolVar(&startGitBranch, "git-branch", false, "commit the session's project changes to a faize/session-<id> branch")
+	cmd.Flags().StringVar(&startWorktree, "worktree", "", "run the session in a git worktree with this branch checked out, created if needed")
+	cmd.Flags().BoolVar(&startReplaceOldest, "replace-oldest", false, "stop the oldest running session if session limits are reached")
+	cmd.Flags().BoolVar(&startDetach, "detach", false, "run the session in the background and return immediately")
+	cmd.Flags().StringArrayVar(&startPublish, "publish", []string{}, "publish a guest TCP port on host loopback, HOST:GUEST or PORT (repeatable)")
+	cmd.Flags().BoolVar(&startCaptureNet, "capture-network", false, "record guest network traffic to a pcap (see 'faize network pcap')")
+	cmd.Flags().BoolVar(&startCaptureNet, "pcap", false, "same as --capture-network")
+	cmd.Flags().StringVar(&startDisk, "disk", "", "attach a scratch disk of this size at /scratch, removed when the session stops (e.g., 20GB)")
+	cmd.Flags().StringVar(&startNetLimit, "net-limit", "", "cap the session's bandwidth in each direction (e.g., 10mbit, 2mbps)")
+	cmd.Flags().StringArrayVar(&startAddHosts, "add-host", []string{}, "add a NAME:IP entry to the guest's /etc/hosts (repeatable)")
+	cmd.Flags().BoolVar(&startForce, "force", false, "start even if the network allowlist has errors")
+	cmd.Flags().BoolVar(&startCold, "cold", false, "boot a new VM instead of claiming a warm one (see 'faize warm')")
+	cmd.Flags().BoolVarP(&startYes, "yes", "y", false, "replace corrupt kernel or rootfs images without asking")
+	cmd.Flags().StringVar(&startOutput, "output", "text", "output format: text, or json-stream for lifecycle events as NDJSON on stderr")
+	cmd.Flags().BoolVar(&startDaemon, "daemon", false, "run as the background owner of a detached session")
+	_ = cmd.Flags().MarkHidden("daemon")
+}
+
+// checkSessionName validates a --name and that no session that hasn't
+// stopped already has it
+func checkSessionName(name string) error {
+	if name == "" {
+		return nil
+	}
+	if err := session.ValidateName(name); err != nil {
+		return err
+	}
+	store, err := session.NewStore()
+	if err != nil {
+		return fmt.Errorf("failed to open session store: %w", err)
+	}
+	sessions, err := store.ListByStatus("created", "running", "paused")
+	if err != nil {
+		return err
+	}
+	for _, sess := range sessions {
+		if sess.Name == name {
+			return fmt.Errorf("session %s is already named %q; stop it or choose another name", sess.ID, name)
+		}
+	}
+	return nil
+}
+
 func runStart(cmd *cobra.Command, args []string) error {
+	if err := configureOutput(); err != nil {
+		return err
+	}
+	if startDetach && !startDaemon {
+		id, err := runDetached()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Session %s started in the background.\n", id)
+		fmt.Printf("Attach with: faize attach %s\n", id)
+		return nil
+	}
+
+	if startDaemon {
+		openDaemonReady()
+	}
+	err := startSession(false)
+	if err != nil {
+		notifyDaemonParent("error " + err.Error())
+		return err
+	}
+	if sessionExitCode != 0 && !startDaemon {
+		os.Exit(sessionExitCode)
+	}
+	return nil
+}
+
+// startSession creates, boots and supervises a session. In the foreground it
+// attaches to the console, claiming an idle warm VM when one matches; as a
+// detached owner it waits for the VM to stop. With warm set it boots an idle
+// VM for the warm pool instead (faize warm).
+func startSession(warm bool) error {
 	// Set debug env var for subpackages
 	if debug {
 		_ = os.Setenv("FAIZE_DEBUG", "1")
 	}
 
+	var labels map[string]string
+	if !warm {
+		var err error
+		if labels, err = session.ParseLabels(startLabels); err != nil {
+			return err
+		}
+		if err := checkSessionName(startName); err != nil {
+			return err
+		}
+	}
+
 	// Default project directory to current working directory
 	if startProjectDir == "" {
 		cwd, err := os.Getwd()
@@ -74,13 +229,58 @@ func runStart(cmd *cobra.Command, args []string) error {
 		}
 		startProjectDir = cwd
 	}
+	if startWorktree != "" && !warm {
+		dir, err := prepareWorktree(startProjectDir, startWorktree)
+		if err != nil {
+			return err
+		}
+		startProjectDir = dir
+	}
 
-	// Load configuration
-	cfg, err := config.Load()
+	// Load configuration, merged with the project's .faize.yaml. Warm VMs
+	// aren't tied to a project, so they only use the user config.
+	configProject := startProjectDir
+	if warm {
+		configProject = ""
+	}
+	cfg, err := config.Load(configProject)
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 	Debug("Config loaded successfully")
+	if cfg.ProjectFile != "" {
+		fmt.Printf("Using project config %s\n", cfg.ProjectFile)
+	}
+
+	// Warm VMs aren't tied to a project: the start that claims one adds its
+	// mounts to the VM, or binds them from the shared warm root where the
+	// hypervisor can't add shares to a running VM
+	warmRoot := ""
+	if vm.WarmNeedsRoot() {
+		warmRoot = cfg.Warm.Root
+	}
+	if warm {
+		if vm.WarmNeedsRoot() && warmRoot == "" {
+			return errNoWarmRoot
+		}
+		startProjectDir = warmRoot
+	}
+
+	if err := changeset.ValidateProfiles(cfg.Changeset.Profiles); err != nil {
+		return fmt.Errorf("invalid changeset config: %w", err)
+	}
+	var hashLimit int64
+	if cfg.Changeset.HashLimit != "" {
+		if hashLimit, err = changeset.ParseSize(cfg.Changeset.HashLimit); err != nil {
+			return fmt.Errorf("invalid changeset.hash_limit: %w", err)
+		}
+	}
+	if cfg.Changeset.KeepContents && hashLimit == 0 {
+		hashLimit = changeset.DefaultStashLimit
+	}
+	if err := vm.ValidatePreventSleep(cfg.Power.PreventSleep); err != nil {
+		return fmt.Errorf("invalid power config: %w", err)
+	}
 
 	// Get home directory for Claude paths
 	home, err := homedir.Dir()
@@ -112,9 +312,39 @@ func runStart(cmd *cobra.Command, args []string) error {
 		// No need to pre-create empty files - copy logic handles missing files gracefully
 	}
 
-	// Read CPUs and memory directly from config
+	// Read CPUs and memory from config; memory is normalized, so a
+	// percentage of host memory is recorded as the size it came to
 	cpus := cfg.Resources.CPUs
-	memory := cfg.Resources.Memory
+	memorySize, err := vm.ParseMemory(cfg.Resources.Memory)
+	if err != nil {
+		return fmt.Errorf("invalid resources.memory: %w", err)
+	}
+	memory := vm.FormatMemory(memorySize)
+	maxMemory := cfg.Resources.MaxMemory
+	if maxMemory != "" {
+		size, err := vm.ParseMemory(maxMemory)
+		if err != nil {
+			return fmt.Errorf("invalid resources.max_memory: %w", err)
+		}
+		maxMemory = vm.FormatMemory(size)
+	}
+	// The total may exceed the host's memory, since idle sessions give theirs back
+	if total := cfg.Limits.MaxTotalMemory; total != "" {
+		if _, err := vm.ParseMemory(total); err != nil && !errors.Is(err, vm.ErrExceedsHostMemory) {
+			return fmt.Errorf("invalid limits.max_total_memory: %w", err)
+		}
+	}
+
+	// Size of the scratch disk, if any
+	disk := cfg.Resources.Disk
+	if startDisk != "" {
+		disk = startDisk
+	}
+	if disk != "" {
+		if _, err := vm.ParseDisk(disk); err != nil {
+			return fmt.Errorf("invalid disk size: %w", err)
+		}
+	}
 
 	if startTimeout == "" {
 		startTimeout = cfg.Timeout
@@ -132,10 +362,117 @@ func runStart(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("invalid timeout format '%s': %w", startTimeout, err)
 	}
 
-	// Parse project directory
-	projectMount, err := mount.Parse(startProjectDir)
+	// Parse idle timeout (empty or "0" disables it)
+	if startIdleTimeout == "" {
+		startIdleTimeout = cfg.IdleTimeout
+	}
+	var idleTimeout time.Duration
+	if startIdleTimeout != "" {
+		if idleTimeout, err = time.ParseDuration(startIdleTimeout); err != nil {
+			return fmt.Errorf("invalid idle timeout format '%s': %w", startIdleTimeout, err)
+		}
+		if idleTimeout > 0 && idleTimeout < minIdleTimeout {
+			return fmt.Errorf("idle timeout must be at least %s (got %s)", minIdleTimeout, idleTimeout)
+		}
+	}
+
+	// Parse auto-suspend period for detached sessions (empty or "0" disables it)
+	if startAutoSuspend == "" {
+		startAutoSuspend = cfg.AutoSuspend
+	}
+	var autoSuspend time.Duration
+	if startAutoSuspend != "" {
+		if autoSuspend, err = time.ParseDuration(startAutoSuspend); err != nil {
+			return fmt.Errorf("invalid auto-suspend format '%s': %w", startAutoSuspend, err)
+		}
+		if autoSuspend > 0 && autoSuspend < minIdleTimeout {
+			return fmt.Errorf("auto-suspend must be at least %s (got %s)", minIdleTimeout, autoSuspend)
+		}
+	}
+
+	// Parse the write quota on writable mounts (empty or "0" disables it)
+	if startWriteQuota == "" {
+		startWriteQuota = cfg.Limits.WriteQuota
+	}
+	var writeQuota int64
+	if startWriteQuota != "" {
+		if writeQuota, err = changeset.ParseSize(startWriteQuota); err != nil {
+			return fmt.Errorf("invalid write quota: %w", err)
+		}
+	}
+	if warm {
+		// An idle VM has no deadline; the start that claims it enforces its own
+		timeoutDuration = 0
+		idleTimeout = 0
+		autoSuspend = 0
+		writeQuota = 0
+	}
+
+	// Parse guest watchdog timeout ("0" disables it)
+	watchdog, err := time.ParseDuration(cfg.Watchdog)
 	if err != nil {
-		return fmt.Errorf("invalid project path: %w", err)
+		return fmt.Errorf("invalid watchdog format '%s': %w", cfg.Watchdog, err)
+	}
+	if watchdog > 0 && watchdog < vm.MinWatchdog {
+		return fmt.Errorf("watchdog must be at least %s (got %s)", vm.MinWatchdog, watchdog)
+	}
+
+	// Parse bandwidth limit
+	netLimitSpec := cfg.Resources.NetLimit
+	if startNetLimit != "" {
+		netLimitSpec = startNetLimit
+	}
+	var netLimit uint64
+	if netLimitSpec != "" {
+		if netLimit, err = network.ParseRate(netLimitSpec); err != nil {
+			return fmt.Errorf("invalid network limit: %w", err)
+		}
+	}
+
+	resolvers, err := network.ParseResolvers(cfg.Network.Resolvers)
+	if err != nil {
+		return fmt.Errorf("invalid network config: %w", err)
+	}
+
+	// Parse /etc/hosts entries; flags override the config for the same name
+	hostSpecs := make([]string, 0, len(cfg.Hosts)+len(startAddHosts))
+	for name, ip := range cfg.Hosts {
+		hostSpecs = append(hostSpecs, name+":"+ip)
+	}
+	sort.Strings(hostSpecs)
+	hosts, err := vm.ParseHosts(append(hostSpecs, startAddHosts...))
+	if err != nil {
+		return err
+	}
+
+	// Validate the upstream proxy
+	var proxyURL string
+	if cfg.Proxy.URL != "" {
+		upstream, err := network.ParseProxyURL(cfg.Proxy.URL)
+		if err != nil {
+			return fmt.Errorf("invalid proxy config: %w", err)
+		}
+		proxyURL = upstream.String()
+	}
+
+	if err := vm.CheckCACerts(cfg.CACerts); err != nil {
+		return err
+	}
+
+	// Parse published ports
+	publish, err := vm.ParsePublish(startPublish)
+	if err != nil {
+		return err
+	}
+
+	// Parse project directory; a warm VM without a shared root has none
+	var projectDir string
+	if startProjectDir != "" {
+		projectMount, err := mount.Parse(startProjectDir)
+		if err != nil {
+			return fmt.Errorf("invalid project path: %w", err)
+		}
+		projectDir = projectMount.Source
 	}
 
 	// Create mount validator with blocked paths
@@ -145,29 +482,42 @@ func runStart(cmd *cobra.Command, args []string) error {
 	}
 
 	// Build mount list
-	allMountSpecs := []string{
-		startProjectDir + ":rw",
-		claudeDir + ":/mnt/host-claude:ro",
-		toolchainDir + ":/opt/toolchain:rw",
-	}
-	allMountSpecs = append(allMountSpecs, cfg.Claude.AutoMounts...)
-	allMountSpecs = append(allMountSpecs, startMounts...)
-
-	// Auto-detect git root for monorepo support
-	if !startNoGitContext && cfg.Claude.ShouldMountGitContext() {
-		gitRoot := git.FindRoot(startProjectDir)
-		if gitRoot != "" && gitRoot != startProjectDir {
-			gitDirPath := filepath.Join(gitRoot, ".git")
+	var allMountSpecs []string
+	switch {
+	case !warm:
+		allMountSpecs = append(allMountSpecs, startProjectDir+":rw")
+	case warmRoot != "":
+		allMountSpecs = append(allMountSpecs, warmRoot+":"+guest.WarmRoot+":rw")
+	}
+	allMountSpecs = append(allMountSpecs,
+		claudeDir+":/mnt/host-claude:ro",
+		toolchainDir+":/opt/toolchain:rw",
+	)
+	if !warm {
+		allMountSpecs = append(allMountSpecs, cfg.Claude.AutoMounts...)
+		allMountSpecs = append(allMountSpecs, cfg.ProjectMounts...)
+		allMountSpecs = append(allMountSpecs, startMounts...)
+	}
+
+	// Mount the repository's .git directory read-only, over the project when
+	// it is inside it, so the guest can't plant hooks or config that git
+	// commands faize runs on the host would use. Outside the project, in a
+	// subdirectory of the repository or a worktree whose .git file points
+	// into it, it is only mounted as git context for monorepos.
+	if !warm {
+		gitDirPath := git.CommonDir(startProjectDir)
+		inside := gitDirPath != "" && matchesTargets(gitDirPath, []string{startProjectDir})
+		if gitDirPath != "" && (inside || !startNoGitContext && cfg.Claude.ShouldMountGitContext()) {
 			if info, err := os.Stat(gitDirPath); err == nil && info.IsDir() {
 				allMountSpecs = append(allMountSpecs, gitDirPath+":"+gitDirPath+":ro")
-				Debug("Git root detected: %s (mounting .git read-only)", gitRoot)
+				Debug("Git directory detected: %s (mounting read-only)", gitDirPath)
 			}
 		}
 	}
 
 	// Parse and validate all mounts
 	var parsedMounts []session.VMMount
-	for i, spec := range allMountSpecs {
+	for _, spec := range allMountSpecs {
 		m, err := mount.Parse(spec)
 		if err != nil {
 			return fmt.Errorf("invalid mount '%s': %w", spec, err)
@@ -183,12 +533,33 @@ func runStart(cmd *cobra.Command, args []string) error {
 			Source:   m.Source,
 			Target:   m.Target,
 			ReadOnly: m.ReadOnly,
-			Tag:      fmt.Sprintf("mount%d", i),
 		})
 	}
 
-	// Parse network policy
-	policy := network.Parse(claudeNetworks)
+	// Assign VirtioFS tags centrally so they can't collide with system shares
+	parsedMounts, err = vm.AllocateTags(parsedMounts)
+	if err != nil {
+		return fmt.Errorf("mount validation failed: %w", err)
+	}
+
+	// Parse network policy, reporting bad entries before anything boots
+	policy, problems := network.ParseStrict(claudeNetworks)
+	fatal := false
+	for _, p := range problems {
+		if p.Fatal {
+			fatal = true
+			fmt.Fprintf(os.Stderr, "Error: network entry %s\n", p)
+		} else {
+			fmt.Fprintf(os.Stderr, "Warning: network entry %s\n", p)
+		}
+	}
+	if fatal {
+		if !startForce {
+			return fmt.Errorf("invalid network allowlist (fix ~/.faize/config.yaml or pass --force to ignore invalid entries)")
+		}
+		fmt.Fprintln(os.Stderr, "Warning: starting anyway; invalid network entries are ignored")
+		claudeNetworks = validNetworkEntries(claudeNetworks, problems)
+	}
 	if policy.AllowAll {
 		Debug("Network policy: allow all traffic")
 	} else if policy.Blocked {
@@ -200,22 +571,155 @@ func runStart(cmd *cobra.Command, args []string) error {
 		if len(policy.Wildcards) > 0 {
 			Debug("Network policy: allowed wildcards: %v", policy.Wildcards)
 		}
+		if len(policy.CIDRs) > 0 {
+			Debug("Network policy: allowed IP ranges: %v", policy.CIDRs)
+		}
+		if len(policy.Ports) > 0 {
+			Debug("Network policy: allowed ports: %v", policy.Ports)
+		}
 	}
 
 	// Create VM configuration
 	vmConfig := &vm.Config{
-		ProjectDir:     projectMount.Source,
+		Name:           startName,
+		Labels:         labels,
+		ProjectDir:     projectDir,
 		Mounts:         parsedMounts,
 		Network:        claudeNetworks,
 		NetworkPolicy:  policy,
 		CPUs:           cpus,
 		Memory:         memory,
+		MaxCPUs:        cfg.Resources.MaxCPUs,
+		MaxMemory:      maxMemory,
+		Disk:           disk,
 		Timeout:        timeoutDuration,
+		Watchdog:       watchdog,
 		ClaudeMode:     true,
 		HostClaudeDir:  claudeDir,
 		ToolchainDir:   toolchainDir,
 		CredentialsDir: credentialsDir,
 		ExtraDeps:      cfg.Claude.ExtraDeps,
+		CaptureNetwork: startCaptureNet,
+		Publish:        publish,
+		Warm:           warm,
+		PreventSleep:   cfg.Power.PreventSleep,
+		Notify:         cfg.ShouldNotify(),
+		NetworkPrompt:  cfg.NetworkPrompt,
+		NetLimit:       netLimit,
+		Resolvers:      resolvers,
+		Hosts:          hosts,
+		Proxy:          proxyURL,
+		NoProxy:        cfg.Proxy.NoProxy,
+		CACerts:        cfg.CACerts,
+		StrictNetwork:  cfg.Network.Strict,
+		Prompt:         runPrompt,
+		PromptArgs:     runPromptArgs,
+		Tasks:          runTasks,
+		SyncSettings:   !warm && (startSyncSettings || cfg.Claude.SyncSettings),
+		SyncSkills:     !warm && (startSyncSkills || cfg.Claude.SyncSkills),
+
+		MaxRunningSessions: cfg.Limits.MaxRunningSessions,
+		MaxTotalMemory:     cfg.Limits.MaxTotalMemory,
+		ReplaceOldest:      startReplaceOldest,
+	}
+	// Boot the user's own kernel and rootfs images, if given
+	if !warm {
+		kernel, rootfs, image := startKernel, startRootfs, startImage
+		if kernel == "" {
+			kernel = cfg.Claude.Kernel
+		}
+		// The rootfs flags override both rootfs config keys
+		if rootfs == "" && image == "" {
+			rootfs, image = cfg.Claude.Rootfs, cfg.Claude.Image
+		}
+		if rootfs != "" && image != "" {
+			return fmt.Errorf("--image can't be combined with --rootfs (nor claude.image with claude.rootfs)")
+		}
+		// Then the project's devcontainer.json, if any
+		if !startNoDevcont && cfg.Claude.ShouldUseDevcontainer() {
+			if path := config.FindDevcontainer(vmConfig.ProjectDir); path != "" {
+				dc, err := config.LoadDevcontainer(path)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("Using %s\n", path)
+				if rootfs == "" && image == "" {
+					image = devcontainerImage(dc)
+				}
+				vmConfig.Publish = append(vmConfig.Publish, devcontainerPorts(dc, vmConfig.Publish)...)
+				vmConfig.PostCreate = dc.PostCreate
+			}
+		}
+		var err error
+		if vmConfig.Kernel, err = customImage("kernel", kernel, vm.ValidateKernel); err != nil {
+			return err
+		}
+		if vmConfig.Rootfs, err = customImage("rootfs", rootfs, vm.ValidateRootfs); err != nil {
+			return err
+		}
+		if image != "" {
+			if vmConfig.Rootfs, err = imageRootfs(image); err != nil {
+				return err
+			}
+			vmConfig.Image = image
+		}
+		// The rootfs flavor picks among the Claude rootfs images faize builds
+		if vmConfig.Rootfs == "" {
+			if vmConfig.Flavor, err = artifacts.ParseFlavor(cfg.Claude.Flavor); err != nil {
+				return err
+			}
+		}
+	}
+	// Keep rootfs changes on the project's overlay disk, unless another
+	// session of the project has it
+	if !warm && (startPersistRootfs || cfg.Claude.PersistRootfs) {
+		overlay, err := vm.OverlayPath(vmConfig.ProjectDir)
+		if err != nil {
+			return err
+		}
+		if id := sessionHolding(func(s *session.Session) bool { return s.Overlay == overlay }); id != "" {
+			fmt.Fprintf(os.Stderr, "Warning: session %s is using this project's rootfs overlay; this session's rootfs changes won't be kept\n", id)
+		} else {
+			vmConfig.Overlay = overlay
+		}
+	}
+	// Seed the rootfs overlay from a snapshot
+	if startFromSnapshot != "" && !warm {
+		if vmConfig.Overlay != "" {
+			return fmt.Errorf("--from-snapshot can't be combined with a persistent rootfs overlay (--persist-rootfs or claude.persist_rootfs)")
+		}
+		snap, err := vm.LoadSnapshot(startFromSnapshot)
+		if err != nil {
+			return err
+		}
+		rootfsPath := vmConfig.Rootfs
+		if rootfsPath == "" && snap.Flavor != vmConfig.Flavor {
+			return fmt.Errorf("snapshot %s was saved on the %s rootfs, not the %s one (claude.flavor)",
+				snap.Name, flavorName(snap.Flavor), flavorName(vmConfig.Flavor))
+		}
+		if manager, err := artifacts.NewManager(); err == nil && rootfsPath == "" {
+			rootfsPath = manager.FlavorRootfsPath(vmConfig.Flavor)
+		}
+		if snap.Outdated(rootfsPath) {
+			fmt.Fprintf(os.Stderr, "Warning: snapshot %s was saved on another build of the rootfs; files it changed may hide the new image's\n", snap.Name)
+		}
+		vmConfig.Snapshot = snap.Path
+	}
+	// Keep /home/claude in the project's home directory, likewise
+	if !warm && (startPersistHome || cfg.Claude.PersistHome) {
+		home, err := vm.HomePath(vmConfig.ProjectDir)
+		if err != nil {
+			return err
+		}
+		if id := sessionHolding(func(s *session.Session) bool { return s.Home == home }); id != "" {
+			fmt.Fprintf(os.Stderr, "Warning: session %s is using this project's persistent home; this session's home won't be kept\n", id)
+		} else {
+			vmConfig.Home = home
+		}
+	}
+	if warm && vmConfig.PreventSleep == vm.PreventSleepAlways {
+		// An idle warm VM shouldn't keep the Mac awake; the start that claims it attaches
+		vmConfig.PreventSleep = vm.PreventSleepAttached
 	}
 
 	// Print configuration (debug only)
@@ -229,7 +733,55 @@ func runStart(cmd *cobra.Command, args []string) error {
 	}
 	Debug("  CPUs: %d", vmConfig.CPUs)
 	Debug("  Memory: %s", vmConfig.Memory)
+	if vmConfig.Disk != "" {
+		Debug("  Scratch disk: %s", vmConfig.Disk)
+	}
+	if vmConfig.Overlay != "" {
+		Debug("  Rootfs overlay: %s", vmConfig.Overlay)
+	}
+	if vmConfig.Home != "" {
+		Debug("  Home: %s (rw)", vmConfig.Home)
+	}
+	if vmConfig.Snapshot != "" {
+		Debug("  Rootfs snapshot: %s", vmConfig.Snapshot)
+	}
+	if vmConfig.Kernel != "" {
+		Debug("  Kernel: %s", vmConfig.Kernel)
+	}
+	if vmConfig.Rootfs != "" {
+		Debug("  Rootfs: %s", vmConfig.Rootfs)
+	}
+	if vmConfig.Image != "" {
+		Debug("  Image: %s", vmConfig.Image)
+	}
+	if vmConfig.Flavor != "" {
+		Debug("  Flavor: %s", vmConfig.Flavor)
+	}
+	for _, command := range vmConfig.PostCreate {
+		Debug("  postCreateCommand: %s", command)
+	}
 	Debug("  Timeout: %s", vmConfig.Timeout)
+	Debug("  Watchdog: %s", vmConfig.Watchdog)
+	Debug("  Capture network: %v", vmConfig.CaptureNetwork)
+	if len(vmConfig.Resolvers) > 0 {
+		Debug("  DNS resolvers: %v", vmConfig.Resolvers)
+	}
+	if len(vmConfig.Hosts) > 0 {
+		Debug("  Hosts entries: %v", vmConfig.Hosts)
+	}
+	if upstream, err := url.Parse(vmConfig.Proxy); err == nil && vmConfig.Proxy != "" {
+		Debug("  Upstream proxy: %s (no proxy: %v)", upstream.Redacted(), vmConfig.NoProxy)
+	}
+	if vmConfig.StrictNetwork {
+		Debug("  Strict network: true")
+	}
+	if len(vmConfig.CACerts) > 0 {
+		Debug("  CA certificates: %v", vmConfig.CACerts)
+	}
+	if vmConfig.NetLimit != 0 {
+		Debug("  Network limit: %s", network.FormatRate(vmConfig.NetLimit))
+	}
+	Debug("  Published ports: %v", vmConfig.Publish)
 	Debug("  Mounts: %d configured", len(vmConfig.Mounts))
 	for _, m := range vmConfig.Mounts {
 		mode := "rw"
@@ -241,77 +793,226 @@ func runStart(cmd *cobra.Command, args []string) error {
 
 	// Create VM manager
 	Debug("Creating VM manager...")
-	var manager vm.Manager
-	vzManager, err := vm.NewVZManager()
+	manager, err := vm.NewManager()
 	if err != nil {
 		fmt.Printf("\nNote: %v\n", err)
 		fmt.Println("Using stub manager for validation only.")
 		manager = vm.NewStubManager()
 	} else {
-		manager = vzManager
-		Debug("VZManager created successfully")
+		Debug("VM manager created successfully")
 	}
 
-	Debug("Creating VM session...")
-	sess, err := manager.Create(vmConfig)
-	if err != nil {
-		if err == vm.ErrVMNotImplemented {
-			fmt.Println("\n[Phase 1] VM support not yet implemented.")
-			fmt.Println("Configuration validated successfully. VM creation will be available in Phase 2.")
-			return nil
+	// Locate the repository and fingerprint its git config before the guest
+	// can write them
+	var gitRepo *git.Repo
+	var gitConfig string
+	if !warm {
+		if gitRepo = git.OpenRepo(startProjectDir); gitRepo != nil {
+			gitConfig = gitRepo.ConfigFingerprint()
 		}
-		return fmt.Errorf("failed to create VM session: %w", err)
 	}
 
-	// Start the session
-	Debug("Starting VM session %s...", sess.ID)
-	if err := manager.Start(sess); err != nil {
-		return fmt.Errorf("failed to start VM session: %w", err)
+	// Hand the project to an idle warm VM when one matches; otherwise boot a new one
+	var sess *session.Session
+	if !warm && !startDaemon && !startCold && runPrompt == "" && len(runTasks) == 0 {
+		sess = claimWarmSession(vmConfig, warmRoot)
+	}
+	claimed := sess != nil
+	if claimed {
+		Debug("Claimed warm session %s", sess.ID)
+	} else {
+		sess, err = createAndStart(manager, vmConfig, cfg.Claude.ExtraDeps)
+		if err != nil {
+			if err == vm.ErrVMNotImplemented {
+				fmt.Println("\n[Phase 1] VM support not yet implemented.")
+				fmt.Println("Configuration validated successfully. VM creation will be available in Phase 2.")
+				return nil
+			}
+			return err
+		}
+		Debug("VM started successfully")
+	}
+	if warm {
+		sess.Warm = true
+		sess.WarmKey = vm.WarmKey(vmConfig, warmRoot)
+	} else if !startDaemon && (warmRoot != "" || !vm.WarmNeedsRoot()) {
+		refillWarmPool(cfg.Warm.Pool)
+	}
+
+	// Record the session's timeouts, write quota and git config fingerprint,
+	// also in sess, which is saved again later
+	deadline := time.Now().Add(timeoutDuration)
+	recordTimeouts := func(s *session.Session) bool {
+		if timeoutDuration > 0 {
+			s.Timeout = startTimeout
+			s.Deadline = &deadline
+		}
+		if idleTimeout > 0 {
+			s.IdleTimeout = startIdleTimeout
+		}
+		if autoSuspend > 0 {
+			s.AutoSuspend = startAutoSuspend
+		}
+		if writeQuota > 0 {
+			s.WriteQuota = startWriteQuota
+		}
+		s.GitConfig = gitConfig
+		if gitRepo != nil {
+			s.GitRoot, s.GitDir = gitRepo.Root, gitRepo.GitDir
+		}
+		return true
+	}
+	recordTimeouts(sess)
+	if store, err := session.NewStore(); err == nil {
+		if _, err := store.Update(sess.ID, recordTimeouts); err != nil {
+			Debug("Failed to save session timeouts: %v", err)
+		}
 	}
-	Debug("VM started successfully")
 
 	// Timeout enforcement: stop the VM when the timeout expires
 	var timedOut atomic.Bool
 	if timeoutDuration > 0 {
-		timer := time.AfterFunc(timeoutDuration, func() {
+		cancel := enforceDeadline(sess.ID, deadline, warnTimeout(manager, sess.ID), func(extended bool) {
 			timedOut.Store(true)
-			fmt.Printf("\nSession timeout (%s) reached. Stopping...\n", timeoutDuration)
+			if extended {
+				fmt.Printf("\nSession deadline reached. Stopping...\n")
+			} else {
+				fmt.Printf("\nSession timeout (%s) reached. Stopping...\n", timeoutDuration)
+			}
 			_ = manager.Stop(sess.ID)
 		})
-		defer timer.Stop()
+		defer cancel()
 	}
 
-	// Take pre-snapshots of rw mounts for change tracking
+	// Idle timeout enforcement: stop the VM once nothing has happened in it
+	var idled atomic.Bool
+	if idleTimeout > 0 {
+		consoleLog := filepath.Join(home, ".faize", "sessions", sess.ID, "console.log")
+		cancel := stopWhenIdle(manager, sess.ID, consoleLog, idleRoots(parsedMounts, cfg), idleTimeout, &idled)
+		defer cancel()
+	}
+
+	// Take pre-snapshots of rw mounts for change tracking. Mounts with a
+	// summarizer (toolchain, credentials) also get an inventory.
 	type mountSnapshot struct {
-		source string
-		target string
-		tag    string
-		snap   changeset.Snapshot
+		source     string
+		target     string
+		tag        string
+		snap       changeset.Snapshot
+		watch      changeset.Watch // nil without file change events
+		opts       changeset.SnapshotOptions
+		summarizer changeset.Summarizer
+		inventory  changeset.Inventory
+		elapsed    time.Duration // time spent snapshotting
 	}
 	var preSnapshots []mountSnapshot
-	showDiff := cfg.Claude.ShouldShowDiff() && !startNoDiff
+	showDiff := cfg.Claude.ShouldShowDiff() && !startNoDiff && !warm
+	var stash string
+	if showDiff && cfg.Changeset.KeepContents {
+		stash = filepath.Join(home, ".faize", "sessions", sess.ID, changeset.StashDir)
+		if err := os.MkdirAll(stash, 0700); err != nil {
+			Debug("Failed to create stash directory: %v", err)
+			stash = ""
+		}
+	}
 	if showDiff {
-		for _, m := range parsedMounts {
+		trackedMounts := parsedMounts
+		if credentialsDir != "" {
+			trackedMounts = append(trackedMounts, session.VMMount{Source: credentialsDir, Target: "/mnt/host-credentials"})
+		}
+		for _, m := range trackedMounts {
 			if m.ReadOnly {
 				continue
 			}
+			rules, err := changeset.NewIgnoreRules(m.Source, cfg.Changeset.Profiles, cfg.Changeset.Ignore)
+			if err != nil {
+				Debug("Failed to load ignore rules for %s: %v", m.Source, err)
+			} else if len(rules.Profiles) > 0 {
+				Debug("Ignore profiles for %s: %v", m.Source, rules.Profiles)
+			}
+			// Watch before the snapshot so no change is missed; the post-snapshot
+			// then only examines the changed paths
+			watch, err := changeset.StartWatch(m.Source)
+			if err != nil {
+				Debug("Not watching %s for changes: %v", m.Source, err)
+			}
+			// Summarized mounts hold credentials and toolchains; their
+			// contents are never kept
+			summarizer := changeset.SummarizerFor(m.Target)
+			opts := changeset.SnapshotOptions{Rules: rules, HashLimit: hashLimit}
+			if summarizer == nil {
+				opts.Stash = stash
+			}
 			Debug("Taking pre-snapshot of %s", m.Source)
-			snap, err := changeset.Take(m.Source)
+			snapStart := time.Now()
+			snap, err := changeset.TakeWithOptions(m.Source, opts)
 			if err != nil {
 				Debug("Failed to snapshot %s: %v", m.Source, err)
+				if watch != nil {
+					watch.Stop()
+				}
+				continue
+			}
+			pre := mountSnapshot{
+				source:     m.Source,
+				target:     m.Target,
+				tag:        m.Tag,
+				snap:       snap,
+				watch:      watch,
+				opts:       opts,
+				summarizer: summarizer,
+				elapsed:    time.Since(snapStart),
+			}
+			if summarizer != nil {
+				pre.inventory = summarizer.Inventory(m.Source)
+			}
+			preSnapshots = append(preSnapshots, pre)
+		}
+	}
+
+	// Write quota enforcement: warn as writable mounts use up the quota and
+	// make them read-only once they are over it, or stop the session if that
+	// fails, to protect the host disk
+	var overQuota atomic.Bool
+	if writeQuota > 0 {
+		var quotaMounts []quotaMount
+		for _, m := range parsedMounts {
+			if m.ReadOnly {
+				continue
+			}
+			qm, err := newQuotaMount(m)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: write quota not enforced on %s: %v\n", m.Source, err)
 				continue
 			}
-			preSnapshots = append(preSnapshots, mountSnapshot{
-				source: m.Source,
-				target: m.Target,
-				tag:    m.Tag,
-				snap:   snap,
-			})
+			quotaMounts = append(quotaMounts, qm)
+		}
+		quota := changeset.FormatSize(writeQuota)
+		warn := func(m quotaMount, written int64) {
+			warnSession(manager, sess.ID, fmt.Sprintf("faize: %s has been written to %s, %d%% of its write quota of %s", changeset.FormatSize(written), m.target, written*100/writeQuota, quota))
+		}
+		exceed := func(m quotaMount, written int64) {
+			events.emit("write-quota", map[string]any{"mount": m.target, "written": written, "quota": writeQuota})
+			if err := remountReadOnly(sess.ID, m.target); err != nil {
+				Debug("Failed to make %s read-only: %v", m.target, err)
+				overQuota.Store(true)
+				fmt.Printf("\nOver the write quota of %s in %s. Stopping...\n", quota, m.target)
+				_ = manager.Stop(sess.ID)
+				return
+			}
+			warnSession(manager, sess.ID, fmt.Sprintf("faize: %s has been written to %s, over its write quota of %s; it is read-only from now on", changeset.FormatSize(written), m.target, quota))
 		}
+		cancel := watchQuota(quotaMounts, writeQuota, warn, exceed)
+		defer cancel()
 	}
 
-	// Ensure session is stopped when we exit (detach, VM stop, error, signal)
+	// Ensure session is stopped when we exit (detach, VM stop, error, signal),
+	// unless it was paused to disk or already stopped
+	paused, stopped := false, false
 	defer func() {
+		if paused || stopped {
+			return
+		}
 		fmt.Printf("\nStopping session %s...\n", sess.ID)
 		if stopErr := manager.Stop(sess.ID); stopErr != nil {
 			Debug("Failed to stop session: %v", stopErr)
@@ -319,59 +1020,197 @@ func runStart(cmd *cobra.Command, args []string) error {
 	}()
 
 	projectName := filepath.Base(vmConfig.ProjectDir)
-	fmt.Printf("\nSession %s | %s | %d CPUs, %s | %s timeout\n",
-		sess.ID, projectName, vmConfig.CPUs, vmConfig.Memory, vmConfig.Timeout)
+	if warm {
+		fmt.Printf("\nWarm session %s | %d CPUs, %s | waiting for a project\n",
+			sess.ID, vmConfig.CPUs, vmConfig.Memory)
+	} else {
+		fmt.Printf("\nSession %s | %s | %d CPUs, %s | %s timeout\n",
+			sess.ID, projectName, vmConfig.CPUs, vmConfig.Memory, vmConfig.Timeout)
+	}
+	for _, p := range vmConfig.Publish {
+		fmt.Printf("Publishing http://localhost:%d -> guest port %d\n", p.HostPort, p.GuestPort)
+	}
+	bootstrapDir := filepath.Join(home, ".faize", "sessions", sess.ID, "bootstrap")
+	if vmConfig.SyncSettings {
+		saveSettingsBase(bootstrapDir, claudeDir)
+	}
+	events.setSession(sess.ID)
+	events.emit("created", map[string]any{
+		"project": vmConfig.ProjectDir,
+		"cpus":    vmConfig.CPUs,
+		"memory":  vmConfig.Memory,
+		"timeout": vmConfig.Timeout.String(),
+		"warm":    claimed,
+	})
+	stopEvents := events.follow(bootstrapDir, sess.StartedAt)
 
-	// Attach to console — session stops when we return
-	fmt.Println("Attaching to console... (~. to detach)")
-	attachErr := manager.Attach(sess.ID)
-	if attachErr != nil && !errors.Is(attachErr, vm.ErrUserDetach) {
-		return fmt.Errorf("console error: %w", attachErr)
+	var attachErr error
+	killed := false
+	if startDaemon || warm {
+		// Detached: record ownership, release the parent, and wait for the VM to stop
+		killed, paused = superviseDetached(manager, sess)
+		if paused {
+			fmt.Printf("Session %s paused.\n", sess.ID)
+			return nil
+		}
+	} else if len(runTasks) > 0 {
+		// Task queue (faize run --tasks): snapshot project mounts after each
+		// task to record what it changed
+		var taskChanges func() []changeset.MountChanges
+		if showDiff {
+			last := make(map[string]changeset.Snapshot)
+			taskChanges = func() []changeset.MountChanges {
+				var all []changeset.MountChanges
+				for _, pre := range preSnapshots {
+					if pre.summarizer != nil {
+						continue
+					}
+					prev, ok := last[pre.source]
+					if !ok {
+						prev = pre.snap
+					}
+					opts := pre.opts
+					opts.Stash = ""
+					snap, err := changeset.TakeWithOptions(pre.source, opts)
+					if err != nil {
+						Debug("Failed to snapshot %s: %v", pre.source, err)
+						continue
+					}
+					last[pre.source] = snap
+					changes := changeset.FilterNoiseWithRules(changeset.Diff(prev, snap), prev, snap, opts.Rules)
+					if len(changes) > 0 {
+						all = append(all, changeset.MountChanges{Source: pre.source, Target: pre.target, Changes: changes})
+					}
+				}
+				return all
+			}
+		}
+		var results []taskResult
+		events.emit("attached", map[string]any{"mode": "tasks"})
+		results, killed = runTaskQueue(manager, sess, bootstrapDir, taskChanges)
+		saveTaskResults(filepath.Join(home, ".faize", "sessions", sess.ID), results)
+		sessionExitCode = queueExitCode(results)
+	} else if runPrompt != "" {
+		// Headless (faize run): stream Claude's output until the VM stops
+		events.emit("attached", map[string]any{"mode": "run"})
+		sessionExitCode, killed = streamRunOutput(manager, sess, bootstrapDir)
+	} else {
+		// Attach to console — session stops when we return
+		fmt.Println("Attaching to console... (~. to detach)")
+		events.emit("attached", map[string]any{"mode": "console"})
+		attachErr = manager.Attach(sess.ID)
+		if attachErr != nil && !errors.Is(attachErr, vm.ErrUserDetach) {
+			return fmt.Errorf("console error: %w", attachErr)
+		}
+		if claimed {
+			// The warm VM's owner records its own exit; stop it first so the
+			// record below is the one that is kept
+			fmt.Printf("\nStopping session %s...\n", sess.ID)
+			if stopErr := manager.Stop(sess.ID); stopErr != nil {
+				Debug("Failed to stop session: %v", stopErr)
+			}
+			stopped = true
+		}
 	}
 
 	// Determine exit reason and persist session metadata
 	exitReason := "normal"
 	if timedOut.Load() {
 		exitReason = "timeout"
+	} else if idled.Load() {
+		exitReason = "idle"
+	} else if overQuota.Load() {
+		exitReason = "quota"
+	} else if killed {
+		exitReason = "killed"
 	} else if errors.Is(attachErr, vm.ErrUserDetach) {
 		exitReason = "detach"
 	}
-	now := time.Now()
-	sess.Timeout = startTimeout
-	sess.StoppedAt = &now
-	sess.ExitReason = exitReason
-	sess.Status = "stopped"
+	switch {
+	case exitReason == "timeout" || exitReason == "idle":
+		sessionExitCode = exitTimeout
+	case exitReason == "detach":
+		sessionExitCode = exitDetach
+	case !startDaemon && !warm && runPrompt == "" && len(runTasks) == 0:
+		// Agents from before the exit file was written leave 0
+		sessionExitCode, _ = readExitCode(filepath.Join(bootstrapDir, guest.ExitFile))
+	}
+	stopEvents()
+	events.emit("stopped", map[string]any{"reason": exitReason, "exit_code": sessionExitCode})
 	store, storeErr := session.NewStore()
+	now := time.Now()
+	stop := func(s *session.Session) bool {
+		s.Timeout = startTimeout
+		s.StoppedAt = &now
+		s.ExitReason = exitReason
+		s.Status = "stopped"
+		s.PID = 0
+		return true
+	}
+	stop(sess)
 	if storeErr == nil {
-		if saveErr := store.Save(sess); saveErr != nil {
-			Debug("Failed to save session: %v", saveErr)
+		// Stop the latest record: a warm VM may have been claimed since it
+		// booted, and attaches and peak usage are recorded while it runs
+		if _, err := store.Update(sess.ID, func(latest *session.Session) bool {
+			stop(latest)
+			*sess = *latest
+			return true
+		}); err != nil {
+			Debug("Failed to save session: %v", err)
 		}
 	}
 
+	if credentialsDir != "" {
+		secureCredentials(credentialsDir)
+	}
+
 	// Post-session change tracking
+	var mountChanges []changeset.MountChanges
 	if showDiff && len(preSnapshots) > 0 {
-		var mountChanges []changeset.MountChanges
 		for _, pre := range preSnapshots {
-			Debug("Taking post-snapshot of %s", pre.source)
-			postSnap, err := changeset.Take(pre.source)
+			snapStart := time.Now()
+			opts := pre.opts
+			var postSnap changeset.Snapshot
+			var err error
+			if pre.watch != nil {
+				if changed, ok := pre.watch.Stop(); ok {
+					Debug("Refreshing snapshot of %s from %d changed path(s)", pre.source, len(changed))
+					if postSnap, err = changeset.Refresh(pre.source, pre.snap, changed, opts); err != nil {
+						Debug("Failed to refresh snapshot of %s: %v", pre.source, err)
+					}
+				}
+			}
+			if postSnap == nil {
+				Debug("Taking post-snapshot of %s", pre.source)
+				postSnap, err = changeset.TakeWithOptions(pre.source, opts)
+			}
 			if err != nil {
 				Debug("Failed to post-snapshot %s: %v", pre.source, err)
 				continue
 			}
 			changes := changeset.Diff(pre.snap, postSnap)
-			changes = changeset.FilterNoise(changes, pre.snap, postSnap)
-			if len(changes) > 0 {
+			changes = changeset.FilterNoiseWithRules(changes, pre.snap, postSnap, pre.opts.Rules)
+			stats := changeset.ComputeStats(changes, pre.elapsed+time.Since(snapStart))
+			var summary []string
+			if pre.summarizer != nil {
+				summary = pre.summarizer.Summarize(pre.source, pre.inventory, pre.summarizer.Inventory(pre.source), changes)
+			}
+			if len(changes) > 0 || len(summary) > 0 {
 				mountChanges = append(mountChanges, changeset.MountChanges{
 					Source:  pre.source,
 					Target:  pre.target,
 					Changes: changes,
+					Summary: summary,
+					Stats:   &stats,
 				})
 			}
 		}
 
+		events.emitChanges(mountChanges)
+
 		// Read guest-side changes from bootstrap dir
-		bootstrapDir := filepath.Join(home, ".faize", "sessions", sess.ID, "bootstrap")
 		guestChanges, _ := changeset.ParseGuestChanges(filepath.Join(bootstrapDir, "guest-changes.txt"))
+		guestPackages, _ := changeset.ParseGuestPackages(filepath.Join(bootstrapDir, guest.PackagesFile))
 
 		// Read network + DNS logs from bootstrap dir
 		networkEvents, netErr := changeset.CollectNetworkEvents(bootstrapDir)
@@ -383,6 +1222,7 @@ func runStart(cmd *cobra.Command, args []string) error {
 			SessionID:     sess.ID,
 			MountChanges:  mountChanges,
 			GuestChanges:  guestChanges,
+			GuestPackages: guestPackages,
 			NetworkEvents: networkEvents,
 		}
 
@@ -396,7 +1236,341 @@ func runStart(cmd *cobra.Command, args []string) error {
 				Debug("Failed to save changeset: %v", saveErr)
 			}
 		}
+
+		if startGitBranch || cfg.Changeset.GitBranch {
+			commitSessionBranch(sess, mountChanges)
+		}
+	}
+
+	// Detached sessions' settings are synced with faize claude sync-settings
+	if vmConfig.SyncSettings && !startDaemon {
+		if _, err := syncSettings(sess.ID, bootstrapDir, claudeDir, false); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+	if vmConfig.SyncSkills && !startDaemon {
+		if _, err := syncClaudeDirs(sess.ID, bootstrapDir, claudeDir, false); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+
+	// An idle warm VM's owner has nothing to audit; the session that
+	// claimed it saves the log with its file changes
+	if !warm {
+		saveSessionAudit(sess, mountChanges)
+		saveSessionCapture(sess)
 	}
 
+	if startReview && len(mountChanges) > 0 && storeErr == nil && !startDaemon {
+		if err := reviewSession(store, sess.ID); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+
+	events.emitSummary(bootstrapDir, mountChanges, sess.StartedAt, now)
 	return nil
 }
+
+// warnSession shows a warning in a session's console, or on this terminal
+// if it is attached to a VM another process owns
+func warnSession(manager vm.Manager, id, msg string) {
+	if w, ok := manager.(vm.Warner); ok && w.Warn(id, msg) {
+		return
+	}
+	// A claimed warm VM is owned by another process, but this one is attached
+	if !startDaemon {
+		fmt.Fprintf(os.Stderr, "\r\n%s\r\n", msg)
+	}
+}
+
+// secureCredentials restricts persisted credentials to the current user after
+// a session, since the guest writes them over VirtioFS, and warns about any
+// that other users could read
+func secureCredentials(dir string) {
+	issues, err := artifacts.SecureCredentials(dir)
+	if err != nil {
+		Debug("Failed to check credential permissions: %v", err)
+	}
+	for _, issue := range issues {
+		if issue.Fixed {
+			fmt.Fprintf(os.Stderr, "Warning: %s was readable by other users (%#o); restricted to owner-only\n", issue.Path, issue.Mode)
+		} else {
+			fmt.Fprintf(os.Stderr, "Warning: %s is readable by other users (%#o) and could not be fixed: %v\n", issue.Path, issue.Mode, issue.Err)
+		}
+	}
+}
+
+// createAndStart creates and boots a session. If the kernel or rootfs image
+// fails validation, the image is quarantined and replaced (after confirmation
+// unless --yes) and the start is retried once with a new VM.
+func createAndStart(manager vm.Manager, vmConfig *vm.Config, extraDeps []string) (*session.Session, error) {
+	for attempt := 0; ; attempt++ {
+		Debug("Creating VM session...")
+		sess, err := manager.Create(vmConfig)
+		if err != nil {
+			if err == vm.ErrVMNotImplemented {
+				return nil, err
+			}
+			return nil, fmt.Errorf("failed to create VM session: %w", err)
+		}
+
+		Debug("Starting VM session %s...", sess.ID)
+		err = manager.Start(sess)
+		if err == nil {
+			return sess, nil
+		}
+
+		// Images the user gave are theirs to fix, not faize's to replace
+		var artifactErr *vm.ArtifactError
+		if attempt > 0 || !errors.As(err, &artifactErr) || artifactErr.Custom {
+			return nil, fmt.Errorf("failed to start VM session: %w", err)
+		}
+		discardSession(manager, sess.ID)
+		if err := repairArtifact(artifactErr, extraDeps); err != nil {
+			return nil, err
+		}
+		fmt.Println("Retrying start...")
+	}
+}
+
+// customImage returns the absolute path of an image given with --kernel or
+// --rootfs (or the config), once validate accepts it; empty when none was
+func customImage(name, path string, validate func(string) error) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	expanded, err := homedir.Expand(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to expand %s path: %w", name, err)
+	}
+	abs, err := filepath.Abs(expanded)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s path: %w", name, err)
+	}
+	if err := validate(abs); err != nil {
+		return "", fmt.Errorf("invalid %s image %s: %w", name, abs, err)
+	}
+	return abs, nil
+}
+
+// devcontainerImage returns the image a devcontainer.json runs, warning
+// about what of its container faize can't reproduce
+func devcontainerImage(dc *config.Devcontainer) string {
+	if dc.Image == "" && dc.Build {
+		fmt.Fprintf(os.Stderr, "Warning: %s builds its container from a Dockerfile or Compose file, which faize doesn't support; build and tag the image, then pass it with --image\n", dc.Path)
+	}
+	if len(dc.Features) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: devcontainer features aren't installed (%s); add them to the image\n", strings.Join(dc.Features, ", "))
+	}
+	return dc.Image
+}
+
+// devcontainerPorts returns the forwardPorts of a devcontainer.json to
+// publish on the same host ports, leaving out ports published already and
+// host ports in use, which it warns about
+func devcontainerPorts(dc *config.Devcontainer, published []session.PortForward) []session.PortForward {
+	var forwards []session.PortForward
+	taken := func(port int) bool {
+		for _, f := range published {
+			if f.HostPort == port || f.GuestPort == port {
+				return true
+			}
+		}
+		return false
+	}
+	for _, port := range dc.ForwardPorts {
+		if taken(port) {
+			continue
+		}
+		if !vm.HostPortFree(port) {
+			fmt.Fprintf(os.Stderr, "Warning: not forwarding port %d from %s: already in use on the host\n", port, dc.Path)
+			continue
+		}
+		forwards = append(forwards, session.PortForward{HostPort: port, GuestPort: port})
+	}
+	return forwards
+}
+
+// imageRootfs returns the rootfs built from the OCI image ref (--image),
+// building it first if needed
+func imageRootfs(ref string) (string, error) {
+	artifactMgr, err := artifacts.NewManager()
+	if err != nil {
+		return "", fmt.Errorf("failed to create artifact manager: %w", err)
+	}
+	path, err := artifactMgr.EnsureImageRootfs(ref)
+	if err != nil {
+		return "", err
+	}
+	if err := vm.ValidateRootfs(path); err != nil {
+		return "", fmt.Errorf("invalid rootfs built from %s: %w (delete %s to rebuild it)", ref, err, path)
+	}
+	return path, nil
+}
+
+// flavorName names a Claude rootfs flavor, "" being Alpine's
+func flavorName(flavor string) string {
+	if flavor == "" {
+		return "alpine"
+	}
+	return flavor
+}
+
+// repairArtifact quarantines a corrupt image and downloads or rebuilds it
+func repairArtifact(artifactErr *vm.ArtifactError, extraDeps []string) error {
+	fmt.Printf("The %s image at %s is corrupt: %v\n", artifactErr.Name, artifactErr.Path, artifactErr.Err)
+	if !startYes && !confirm("Move it aside and download or rebuild it?") {
+		return fmt.Errorf("%w (re-run with --yes to replace it automatically)", artifactErr)
+	}
+
+	artifactMgr, err := artifacts.NewManager()
+	if err != nil {
+		return fmt.Errorf("failed to create artifact manager: %w", err)
+	}
+	quarantined, err := artifactMgr.Quarantine(artifactErr.Path)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Moved the corrupt %s to %s\n", artifactErr.Name, quarantined)
+	if err := artifactMgr.Restore(artifactErr.Path, extraDeps); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", artifactErr.Name, err)
+	}
+	return nil
+}
+
+// discardSession stops a session that never booted and removes its records
+func discardSession(manager vm.Manager, id string) {
+	if err := manager.Stop(id); err != nil {
+		Debug("Failed to stop session %s: %v", id, err)
+	}
+	store, err := session.NewStore()
+	if err != nil {
+		return
+	}
+	if err := store.Delete(id); err != nil {
+		Debug("Failed to delete session %s: %v", id, err)
+	}
+	_ = os.RemoveAll(filepath.Join(store.Dir(), id))
+}
+
+// confirm asks a yes/no question on the terminal; it answers no when stdin
+// is not a terminal
+func confirm(question string) bool {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false
+	}
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// prepareWorktree checks out branch in a git worktree of projectDir's
+// repository, so the session doesn't share a working tree with the user or
+// other sessions. The branch's existing worktree is reused; otherwise one is
+// created under ~/.faize/worktrees. Returns the directory in the worktree
+// matching projectDir.
+func prepareWorktree(projectDir, branch string) (string, error) {
+	if resolved, err := filepath.EvalSymlinks(projectDir); err == nil {
+		projectDir = resolved
+	}
+	root := git.FindRoot(projectDir)
+	if root == "" {
+		return "", fmt.Errorf("--worktree needs a git repository; %s is not in one", projectDir)
+	}
+	rel, err := filepath.Rel(root, projectDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to locate %s in %s: %w", projectDir, root, err)
+	}
+	trees, err := git.Worktrees(root)
+	if err != nil {
+		return "", fmt.Errorf("failed to list worktrees: %w", err)
+	}
+
+	var path string
+	for _, t := range trees {
+		if t.Branch != branch {
+			continue
+		}
+		if t.Main {
+			return "", fmt.Errorf("branch %s is checked out in %s; pick another branch for the worktree", branch, t.Path)
+		}
+		path = t.Path
+	}
+	if path != "" {
+		if id := sessionUsingDir(path); id != "" {
+			return "", fmt.Errorf("worktree %s is in use by session %s", path, id)
+		}
+		fmt.Printf("Using worktree %s\n", path)
+	} else {
+		home, err := homedir.Dir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %w", err)
+		}
+		path = filepath.Join(home, ".faize", "worktrees", filepath.Base(root), strings.ReplaceAll(branch, "/", "-"))
+		fmt.Printf("Creating worktree for branch %s at %s\n", branch, path)
+		if err := git.AddWorktree(root, path, branch); err != nil {
+			return "", fmt.Errorf("failed to create worktree: %w", err)
+		}
+	}
+	return filepath.Join(path, rel), nil
+}
+
+// sessionUsingDir returns the ID of a running or paused session whose
+// project is in dir, or ""
+func sessionUsingDir(dir string) string {
+	store, err := session.NewStore()
+	if err != nil {
+		return ""
+	}
+	sessions, err := store.List()
+	if err != nil {
+		return ""
+	}
+	for _, s := range sessions {
+		if s.Status != "running" && s.Status != "paused" {
+			continue
+		}
+		if matchesTargets(s.ProjectDir, []string{dir}) {
+			return s.ID
+		}
+	}
+	return ""
+}
+
+// sessionHolding returns the ID of a session that hasn't stopped for which
+// holds is true, or ""; used for project state only one session can have
+func sessionHolding(holds func(s *session.Session) bool) string {
+	store, err := session.NewStore()
+	if err != nil {
+		return ""
+	}
+	sessions, err := store.ListByStatus("created", "running", "paused")
+	if err != nil {
+		return ""
+	}
+	for _, s := range sessions {
+		if holds(s) {
+			return s.ID
+		}
+	}
+	return ""
+}
+
+// validNetworkEntries returns specs without the ones problems reports as
+// fatal, so a session started with --force records only what it enforces
+func validNetworkEntries(specs []string, problems []network.Problem) []string {
+	invalid := make(map[string]bool)
+	for _, p := range problems {
+		if p.Fatal {
+			invalid[p.Spec] = true
+		}
+	}
+	valid := make([]string, 0, len(specs))
+	for _, spec := range specs {
+		if !invalid[spec] {
+			valid = append(valid, spec)
+		}
+	}
+	return valid
+}
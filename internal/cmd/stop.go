@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/faize-ai/faize/internal/vm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	stopTimeout time.Duration
+	stopForce   bool
+)
+
+var stopCmd = &cobra.Command{
+	Use:   "stop <session-id>...",
+	Short: "Stop running sessions",
+	Long: `Stop one or more running sessions. Session metadata is kept so the
+changeset and logs remain available; use 'faize session rm' to remove it.
+
+The guest is asked to shut down first, so its cleanup runs: the session
+process is stopped, Claude credentials are persisted (with
+claude.persist_credentials), and the guest's file changes are recorded.
+If the guest has not shut down within --timeout, or with --force, the VM
+is stopped from the host. Paused sessions are always stopped from the host.
+
+Examples:
+  faize stop abc123
+  faize stop abc123 def456 --timeout 1m
+  faize stop --force abc123`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runStop,
+}
+
+func init() {
+	addStopFlags(stopCmd)
+	rootCmd.AddCommand(stopCmd)
+}
+
+// addStopFlags registers the stop flags on a command (faize stop and faize session stop)
+func addStopFlags(cmd *cobra.Command) {
+	cmd.Flags().DurationVar(&stopTimeout, "timeout", 30*time.Second, "how long to wait for the guest to shut down before stopping the VM")
+	cmd.Flags().BoolVarP(&stopForce, "force", "f", false, "stop the VM immediately, skipping the guest's cleanup")
+}
+
+func runStop(cmd *cobra.Command, args []string) error {
+	store, err := session.NewStore()
+	if err != nil {
+		return fmt.Errorf("failed to access session store: %w", err)
+	}
+	manager := newSessionManager()
+
+	var failed int
+	for _, ref := range args {
+		id := resolveSessionRef(ref)
+		sess, err := store.Load(id)
+		if err != nil {
+			fmt.Printf("Warning: %v\n", err)
+			failed++
+			continue
+		}
+		if sess.Status != "running" && sess.Status != "paused" {
+			fmt.Printf("Session %s is not running (status: %s)\n", id, sess.Status)
+			continue
+		}
+
+		if sess.Status == "running" && !stopForce {
+			fmt.Printf("Shutting down session %s...\n", id)
+			err := vm.Shutdown(store, id, stopTimeout)
+			if err == nil {
+				fmt.Printf("Stopped session: %s\n", id)
+				continue
+			}
+			fmt.Printf("Warning: graceful shutdown failed: %v; stopping the VM\n", err)
+		}
+
+		if err := manager.Stop(id); err != nil {
+			fmt.Printf("Warning: failed to stop session %s: %v\n", id, err)
+			failed++
+			continue
+		}
+		fmt.Printf("Stopped session: %s\n", id)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to stop %d session(s)", failed)
+	}
+	return nil
+}
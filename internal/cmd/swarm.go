@@ -0,0 +1,329 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/faize-ai/faize/internal/git"
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/google/uuid"
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+)
+
+var (
+	swarmCount      int
+	swarmPrompt     string
+	swarmProjectDir string
+	swarmTimeout    string
+)
+
+// swarmCandidate is one of a swarm's parallel runs
+type swarmCandidate struct {
+	branch    string
+	dir       string // the project directory in the candidate's worktree
+	root      string // the worktree's root
+	output    string // Claude's output
+	log       string // faize's messages
+	exitCode  int
+	sessionID string
+	stats     []git.FileStat
+	err       error
+}
+
+var swarmCmd = &cobra.Command{
+	Use:   "swarm -p <prompt> [-n count] [flags] [-- claude-args...]",
+	Short: "Run the same prompt in parallel sessions and compare the results",
+	Long: `Start several sessions at once, each in its own git worktree, run the same
+prompt in each with 'faize run', and compare what they changed side by side
+so you can pick the best result.
+
+Each candidate works on a faize/swarm-<id>-<n> branch created from the
+project's HEAD; uncommitted changes in the project are not included. When a
+candidate finishes, its changes are committed to its branch. Claude's output
+and faize's messages for each candidate are saved under ~/.faize/swarms/<id>.
+
+Keep a result by merging its branch; remove the others with
+'git worktree remove' and 'git branch -D'.
+
+Examples:
+  faize swarm -p "fix the failing tests"
+  faize swarm -n 5 -p "make the parser faster" --timeout 1h
+  faize swarm -p "add input validation" -- --model sonnet`,
+	RunE: runSwarm,
+}
+
+func init() {
+	swarmCmd.Flags().IntVarP(&swarmCount, "count", "n", 3, "number of parallel sessions")
+	swarmCmd.Flags().StringVarP(&swarmPrompt, "prompt", "p", "", "prompt for Claude; - reads it from stdin")
+	swarmCmd.Flags().StringVar(&swarmProjectDir, "project", "", "project directory (default: current directory)")
+	swarmCmd.Flags().StringVarP(&swarmTimeout, "timeout", "t", "", "timeout for each session (e.g., 30m)")
+	_ = swarmCmd.MarkFlagRequired("prompt")
+	rootCmd.AddCommand(swarmCmd)
+}
+
+func runSwarm(cmd *cobra.Command, args []string) error {
+	if dash := cmd.ArgsLenAtDash(); dash != 0 && len(args) > 0 {
+		return fmt.Errorf("unexpected argument %q; pass Claude arguments after --", args[0])
+	}
+	if swarmCount < 1 {
+		return fmt.Errorf("--count must be at least 1")
+	}
+	if swarmPrompt == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read the prompt from stdin: %w", err)
+		}
+		swarmPrompt = string(data)
+	}
+	if strings.TrimSpace(swarmPrompt) == "" {
+		return fmt.Errorf("the prompt is empty")
+	}
+
+	projectDir := swarmProjectDir
+	if projectDir == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("failed to get working directory: %w", err)
+		}
+		projectDir = wd
+	}
+	projectDir, err := filepath.Abs(projectDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve project directory: %w", err)
+	}
+	root := git.FindRoot(projectDir)
+	if root == "" {
+		return fmt.Errorf("faize swarm needs a git repository; %s is not in one", projectDir)
+	}
+	base, err := git.Head(root)
+	if err != nil {
+		return fmt.Errorf("faize swarm needs a commit to start from: %w", err)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate the faize executable: %w", err)
+	}
+	home, err := homedir.Dir()
+	if err != nil {
+		return fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	id := uuid.New().String()[:8]
+	outDir := filepath.Join(home, ".faize", "swarms", id)
+	if err := os.MkdirAll(outDir, 0700); err != nil {
+		return fmt.Errorf("failed to create swarm directory: %w", err)
+	}
+
+	// Create the worktrees one at a time; git locks the repository while
+	// adding one
+	candidates := make([]*swarmCandidate, swarmCount)
+	for i := range candidates {
+		c := &swarmCandidate{
+			branch: fmt.Sprintf("faize/swarm-%s-%d", id, i+1),
+			output: filepath.Join(outDir, strconv.Itoa(i+1)+".out"),
+			log:    filepath.Join(outDir, strconv.Itoa(i+1)+".log"),
+		}
+		if c.dir, err = prepareWorktree(projectDir, c.branch); err != nil {
+			return err
+		}
+		c.root = git.FindRoot(c.dir)
+		candidates[i] = c
+	}
+
+	// Interrupts reach the runs directly; wait for them to stop and report
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	fmt.Printf("\nSwarm %s: running %d sessions in %s\n", id, swarmCount, projectDir)
+	started := time.Now()
+	var wg sync.WaitGroup
+	for i, c := range candidates {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.err = runCandidate(exe, projectDir, c, args)
+			status := fmt.Sprintf("exit %d", c.exitCode)
+			if c.err != nil {
+				status = c.err.Error()
+			}
+			fmt.Printf("Candidate %d finished in %s: %s\n", i+1, time.Since(started).Round(time.Second), status)
+		}()
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for i, c := range candidates {
+		c.sessionID = latestSessionIn(c.root, started)
+		if c.err != nil {
+			continue
+		}
+		if c.exitCode == 0 {
+			succeeded++
+		}
+		commit, err := git.CommitAll(c.root, fmt.Sprintf("faize swarm %s: candidate %d\n\n%s\n", id, i+1, strings.TrimSpace(swarmPrompt)))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to commit candidate %d: %v\n", i+1, err)
+			continue
+		}
+		if commit == "" {
+			continue
+		}
+		if c.stats, err = git.DiffStat(c.root, base, commit); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to compare candidate %d: %v\n", i+1, err)
+		}
+	}
+
+	printSwarmReport(candidates)
+	if succeeded == 0 {
+		return fmt.Errorf("no candidate succeeded; see the logs in %s", outDir)
+	}
+	return nil
+}
+
+// runCandidate runs 'faize run' in a candidate's worktree, saving its output
+// and messages
+func runCandidate(exe, projectDir string, c *swarmCandidate, claudeArgs []string) error {
+	out, err := os.OpenFile(c.output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer func() { _ = out.Close() }()
+	log, err := os.OpenFile(c.log, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create log file: %w", err)
+	}
+	defer func() { _ = log.Close() }()
+
+	args := []string{"run", "-p", swarmPrompt, "--project", projectDir, "--worktree", c.branch}
+	if swarmTimeout != "" {
+		args = append(args, "--timeout", swarmTimeout)
+	}
+	args = append(args, "--")
+	child := exec.Command(exe, append(args, claudeArgs...)...)
+	child.Stdout = out
+	child.Stderr = log
+	err = child.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		c.exitCode = exitErr.ExitCode()
+		return nil
+	}
+	return err
+}
+
+// latestSessionIn returns the ID of the last session started in dir since
+// the given time, or ""
+func latestSessionIn(dir string, since time.Time) string {
+	store, err := session.NewStore()
+	if err != nil {
+		return ""
+	}
+	sessions, err := store.List()
+	if err != nil {
+		return ""
+	}
+	var latest *session.Session
+	for _, s := range sessions {
+		if s.StartedAt.Before(since) || !matchesTargets(s.ProjectDir, []string{dir}) {
+			continue
+		}
+		if latest == nil || s.StartedAt.After(latest.StartedAt) {
+			latest = s
+		}
+	}
+	if latest == nil {
+		return ""
+	}
+	return latest.ID
+}
+
+// printSwarmReport prints a table of the lines each candidate changed per
+// file, one column per candidate, and where to find each one
+func printSwarmReport(candidates []*swarmCandidate) {
+	var paths []string
+	seen := make(map[string]bool)
+	cells := make([]map[string]string, len(candidates))
+	for i, c := range candidates {
+		cells[i] = make(map[string]string)
+		for _, s := range c.stats {
+			if !seen[s.Path] {
+				seen[s.Path] = true
+				paths = append(paths, s.Path)
+			}
+			cells[i][s.Path] = formatFileStat(s)
+		}
+	}
+	sort.Strings(paths)
+
+	fmt.Println()
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	row := []string{"FILE"}
+	for i := range candidates {
+		row = append(row, "#"+strconv.Itoa(i+1))
+	}
+	_, _ = fmt.Fprintln(tw, strings.Join(row, "\t"))
+	for _, p := range paths {
+		row = []string{p}
+		for i := range candidates {
+			cell := cells[i][p]
+			if cell == "" {
+				cell = "-"
+			}
+			row = append(row, cell)
+		}
+		_, _ = fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	totals, exits := []string{"TOTAL"}, []string{"EXIT"}
+	for _, c := range candidates {
+		var total git.FileStat
+		for _, s := range c.stats {
+			total.Added += s.Added
+			total.Deleted += s.Deleted
+		}
+		totals = append(totals, fmt.Sprintf("%s, %d file(s)", formatFileStat(total), len(c.stats)))
+		exit := strconv.Itoa(c.exitCode)
+		if c.err != nil {
+			exit = "error"
+		}
+		exits = append(exits, exit)
+	}
+	_, _ = fmt.Fprintln(tw, strings.Join(totals, "\t"))
+	_, _ = fmt.Fprintln(tw, strings.Join(exits, "\t"))
+	_ = tw.Flush()
+
+	fmt.Println()
+	for i, c := range candidates {
+		fmt.Printf("#%d  %s  %s\n", i+1, c.branch, c.root)
+		if c.sessionID != "" {
+			fmt.Printf("    session %s, output in %s\n", c.sessionID, c.output)
+		} else {
+			fmt.Printf("    output in %s, messages in %s\n", c.output, c.log)
+		}
+	}
+	if len(candidates) > 1 {
+		fmt.Printf("\nCompare two with 'git diff %s %s'; keep one with 'git merge <branch>'.\n",
+			candidates[0].branch, candidates[1].branch)
+	}
+}
+
+// formatFileStat formats a file's line counts as "+12 -3"
+func formatFileStat(s git.FileStat) string {
+	if s.Binary {
+		return "binary"
+	}
+	return fmt.Sprintf("+%d -%d", s.Added, s.Deleted)
+}
@@ -0,0 +1,345 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/faize-ai/faize/internal/changeset"
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/faize-ai/faize/internal/vm"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+const (
+	// topRefresh is how often faize top redraws
+	topRefresh = time.Second
+	// topStatsStale is how old a guest's stats may be before they're not shown,
+	// e.g. while the VM is paused
+	topStatsStale = 10 * time.Second
+	// topNetworkEvents is how many recent network events are shown
+	topNetworkEvents = 8
+)
+
+var topCmd = &cobra.Command{
+	Use:   "top",
+	Short: "Show a live dashboard of running sessions",
+	Long: `Show running sessions full-screen, refreshed every second: their uptime,
+guest CPU and memory use, and the recent network activity of the selected
+session.
+
+Keys:
+  up/down, j/k   select a session
+  a, enter       attach to the selected session (~. returns to top)
+  s              stop the selected session
+  q, ctrl-c      quit
+
+CPU and memory are sampled by the guest agent; sessions started from images
+built before faize top show '-' until they're rebuilt with 'faize claude
+rebuild'.`,
+	Args: cobra.NoArgs,
+	RunE: runTop,
+}
+
+func init() {
+	rootCmd.AddCommand(topCmd)
+}
+
+// topSession is a session as faize top shows it
+type topSession struct {
+	sess  *session.Session
+	stats *guest.Stats // nil when unknown or stale
+}
+
+// topState is what faize top shows and which session is selected
+type topState struct {
+	store    *session.Store
+	backend  *localBackend
+	sessions []topSession
+	selected string // session ID
+	status   string // last action's result, shown at the bottom
+	stopping chan string
+}
+
+func runTop(cmd *cobra.Command, args []string) error {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return fmt.Errorf("faize top needs a terminal; use 'faize ps' in scripts")
+	}
+	store, err := session.NewStore()
+	if err != nil {
+		return fmt.Errorf("failed to open session store: %w", err)
+	}
+	st := &topState{
+		store:    store,
+		backend:  &localBackend{store: store, manager: newSessionManager()},
+		stopping: make(chan string, 8),
+	}
+
+	restore, err := enterTopScreen(fd)
+	if err != nil {
+		return err
+	}
+	defer func() { restore() }()
+
+	buf := make([]byte, 16)
+	for {
+		st.refresh()
+		st.draw()
+		deadline := time.Now().Add(topRefresh)
+		for {
+			select {
+			case msg := <-st.stopping:
+				st.status = msg
+				st.refresh()
+				st.draw()
+			default:
+			}
+			wait := time.Until(deadline)
+			if wait <= 0 {
+				break
+			}
+			ready, err := waitForInput(os.Stdin, min(wait, 100*time.Millisecond))
+			if err != nil {
+				return err
+			}
+			if !ready {
+				continue
+			}
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				return err
+			}
+			switch key := string(buf[:n]); key {
+			case "q", "Q", "\x03":
+				return nil
+			case "j", "\x1b[B", "\x1bOB":
+				st.move(1)
+			case "k", "\x1b[A", "\x1bOA":
+				st.move(-1)
+			case "s":
+				st.stop()
+			case "a", "\r":
+				if st.selected == "" {
+					break
+				}
+				restore()
+				st.status = attachFromTop(st.backend.manager, st.selected)
+				if restore, err = enterTopScreen(fd); err != nil {
+					restore = func() {}
+					return err
+				}
+				st.refresh()
+			}
+			st.draw()
+		}
+	}
+}
+
+// enterTopScreen switches the terminal to raw mode on the alternate screen
+// and returns a function that switches it back
+func enterTopScreen(fd int) (func(), error) {
+	old, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set raw mode: %w", err)
+	}
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	return func() {
+		fmt.Print("\x1b[?25h\x1b[?1049l")
+		_ = term.Restore(fd, old)
+	}, nil
+}
+
+// attachFromTop attaches the terminal to a session's console and returns
+// a status line for when faize top resumes
+func attachFromTop(manager vm.Manager, id string) string {
+	fmt.Printf("Attaching to session %s... (~. to return to faize top)\n", id)
+	err := manager.Attach(id)
+	switch {
+	case errors.Is(err, vm.ErrUserDetach):
+		return fmt.Sprintf("Detached from session %s", id)
+	case err != nil:
+		return fmt.Sprintf("Failed to attach to session %s: %v", id, err)
+	}
+	return fmt.Sprintf("Session %s ended", id)
+}
+
+// refresh reloads running sessions and their stats
+func (st *topState) refresh() {
+	list, err := st.backend.manager.List()
+	if err != nil {
+		st.status = fmt.Sprintf("Failed to list sessions: %v", err)
+		return
+	}
+	st.sessions = st.sessions[:0]
+	for _, sess := range list {
+		if sess.Status != "running" && sess.Status != "paused" {
+			continue
+		}
+		ts := topSession{sess: sess}
+		stats, err := guest.ReadStats(st.bootstrapDir(sess.ID))
+		if err == nil && sess.Status == "running" && time.Since(stats.Time) < topStatsStale {
+			ts.stats = stats
+		}
+		st.sessions = append(st.sessions, ts)
+	}
+	sort.Slice(st.sessions, func(i, j int) bool {
+		return st.sessions[i].sess.StartedAt.Before(st.sessions[j].sess.StartedAt)
+	})
+	if st.index() < 0 {
+		st.selected = ""
+		if len(st.sessions) > 0 {
+			st.selected = st.sessions[0].sess.ID
+		}
+	}
+}
+
+func (st *topState) bootstrapDir(id string) string {
+	return filepath.Join(st.store.Dir(), id, "bootstrap")
+}
+
+// index returns the position of the selected session, or -1
+func (st *topState) index() int {
+	for i, ts := range st.sessions {
+		if ts.sess.ID == st.selected {
+			return i
+		}
+	}
+	return -1
+}
+
+func (st *topState) move(delta int) {
+	if len(st.sessions) == 0 {
+		return
+	}
+	i := min(max(st.index()+delta, 0), len(st.sessions)-1)
+	st.selected = st.sessions[i].sess.ID
+}
+
+// stop stops the selected session in the background, reporting on st.stopping
+func (st *topState) stop() {
+	id := st.selected
+	if id == "" {
+		return
+	}
+	st.status = fmt.Sprintf("Stopping session %s...", id)
+	go func() {
+		if err := st.backend.Stop(id, false); err != nil {
+			st.stopping <- fmt.Sprintf("Failed to stop session %s: %v", id, err)
+			return
+		}
+		st.stopping <- fmt.Sprintf("Stopped session %s", id)
+	}()
+}
+
+// draw redraws the screen in place
+func (st *topState) draw() {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		width, height = 80, 24
+	}
+	var lines []string
+	add := func(format string, args ...any) {
+		line := fmt.Sprintf(format, args...)
+		if len(line) > width {
+			line = line[:width]
+		}
+		lines = append(lines, line)
+	}
+
+	add("faize top - %d session(s) - %s", len(st.sessions), time.Now().Format(time.TimeOnly))
+	add("")
+	add("  %-12s %-16s %-8s %9s %6s %-19s %s", "ID", "NAME", "STATUS", "UPTIME", "CPU", "MEMORY", "PROJECT")
+	for _, ts := range st.sessions {
+		cpu, mem := "-", "-"
+		if ts.stats != nil {
+			cpu = fmt.Sprintf("%.0f%%", ts.stats.CPU)
+			mem = fmt.Sprintf("%s / %s", changeset.FormatSize(int64(ts.stats.MemUsed)), changeset.FormatSize(int64(ts.stats.MemTotal)))
+		}
+		status := ts.sess.Status
+		if ts.sess.Warm && status == "running" {
+			status = "warm"
+		}
+		name := ts.sess.Name
+		if name == "" {
+			name = "-"
+		} else if len(name) > 16 {
+			name = name[:15] + "~"
+		}
+		line := fmt.Sprintf("  %-12s %-16s %-8s %9s %6s %-19s %s", ts.sess.ID, name, status,
+			time.Since(ts.sess.StartedAt).Round(time.Second), cpu, mem, ts.sess.ProjectDir)
+		if len(line) > width {
+			line = line[:width]
+		}
+		if ts.sess.ID == st.selected {
+			line = "\x1b[7m" + line + strings.Repeat(" ", max(width-len(line), 0)) + "\x1b[0m"
+		}
+		lines = append(lines, line)
+	}
+	if len(st.sessions) == 0 {
+		add("  No running sessions.")
+	}
+
+	if st.selected != "" {
+		add("")
+		add("Recent network activity of %s:", st.selected)
+		events := recentNetworkEvents(st.bootstrapDir(st.selected), topNetworkEvents)
+		for _, e := range events {
+			dst := e.Domain
+			if e.DstIP != "" {
+				if dst != "" {
+					dst += " "
+				}
+				dst += fmt.Sprintf("%s:%d", e.DstIP, e.DstPort)
+			}
+			add("  %-8s %-5s %s", e.Timestamp, e.Action, dst)
+		}
+		if len(events) == 0 {
+			add("  none")
+		}
+	}
+
+	// The status line and key help stay at the bottom
+	footer := []string{st.status, "up/down select  a attach  s stop  q quit"}
+	for len(lines) < height-len(footer) {
+		lines = append(lines, "")
+	}
+	lines = append(lines[:max(height-len(footer), 0)], footer...)
+
+	var b bytes.Buffer
+	b.WriteString("\x1b[H")
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(line)
+		b.WriteString("\x1b[K")
+	}
+	_, _ = os.Stdout.Write(b.Bytes())
+}
+
+// recentNetworkEvents returns the last n connections and denials a session's
+// guest logged, leaving out DNS queries
+func recentNetworkEvents(bootstrapDir string, n int) []changeset.NetworkEvent {
+	events, err := changeset.CollectNetworkEvents(bootstrapDir)
+	if err != nil {
+		return nil
+	}
+	var recent []changeset.NetworkEvent
+	for _, e := range events {
+		if e.Action != "DNS" {
+			recent = append(recent, e)
+		}
+	}
+	if len(recent) > n {
+		recent = recent[len(recent)-n:]
+	}
+	return recent
+}
@@ -0,0 +1,14 @@
+//go:build windows
+
+package cmd
+
+import (
+	"os"
+	"time"
+)
+
+// waitForInput can't poll the console on Windows, so input is always read,
+// blocking until a key is pressed
+func waitForInput(f *os.File, timeout time.Duration) (bool, error) {
+	return true, nil
+}
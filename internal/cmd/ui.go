@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/faize-ai/faize/internal/ui"
+	"github.com/faize-ai/faize/internal/vm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	uiPort int
+	uiOpen bool
+)
+
+var uiCmd = &cobra.Command{
+	Use:   "ui",
+	Short: "Serve a web dashboard of sessions",
+	Long: `Serve a web page on localhost listing sessions, with each session's live
+console, network activity, and changes: faize ps, logs, and diff in a browser.
+
+The dashboard is read-only, and only reachable at the URL printed on start,
+which carries a token generated for this run.
+
+Examples:
+  faize ui
+  faize ui --open
+  faize ui --port 8080`,
+	Args: cobra.NoArgs,
+	RunE: runUI,
+}
+
+func init() {
+	uiCmd.Flags().IntVar(&uiPort, "port", 7777, "port to listen on at 127.0.0.1 (0 picks a free port)")
+	uiCmd.Flags().BoolVar(&uiOpen, "open", false, "open the dashboard in the default browser")
+	rootCmd.AddCommand(uiCmd)
+}
+
+func runUI(cmd *cobra.Command, args []string) error {
+	store, err := session.NewStore()
+	if err != nil {
+		return fmt.Errorf("failed to open session store: %w", err)
+	}
+	secret := make([]byte, 16)
+	if _, err := rand.Read(secret); err != nil {
+		return fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := hex.EncodeToString(secret)
+
+	l, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(uiPort)))
+	if err != nil {
+		return fmt.Errorf("failed to listen on port %d: %w", uiPort, err)
+	}
+	srv := &http.Server{
+		Handler:           ui.Handler(&localBackend{store: store, manager: newSessionManager()}, token),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(sigCh)
+	go func() {
+		<-sigCh
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(ctx)
+	}()
+
+	url := fmt.Sprintf("http://%s/?token=%s", l.Addr(), token)
+	fmt.Printf("Serving the faize dashboard at %s\n", url)
+	if uiOpen {
+		if err := vm.OpenBrowser(url); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to open browser: %v\n", err)
+		}
+	}
+	if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("server failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+
+	"github.com/faize-ai/faize/internal/config"
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/faize-ai/faize/internal/vm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	warmCount  int
+	warmStop   bool
+	warmDaemon bool
+)
+
+// errNoWarmRoot is returned where warm VMs need warm.root and it isn't set
+var errNoWarmRoot = errors.New("warm VMs share warm.root on this platform: set it in ~/.faize/config.yaml to the directory holding your projects")
+
+var warmCmd = &cobra.Command{
+	Use:   "warm",
+	Short: "Keep pre-booted VMs ready for instant starts",
+	Long: `Boot VMs in the background and leave them idle, with the network and
+Claude configuration already set up. 'faize start' claims an idle VM instead of
+booting a new one: the project is bound into the running VM and Claude launches
+straight away.
+
+Idle warm VMs share no project directory. On Linux the start that claims one
+adds the project and its mounts to the running VM. On macOS, which can't add
+shares to a running VM, warm VMs share the warm root (warm.root in
+~/.faize/config.yaml, which must be set and may not contain a blocked path);
+on claim the guest binds only the project and its mounts from it and unmounts
+the rest before Claude starts. A start boots a new VM instead when a mount is
+outside the warm root, when it publishes ports or captures network traffic,
+when it runs detached, or when resources or the network allowlist differ from
+the warm VM's.
+
+Set warm.pool to keep that many VMs ready; each start refills the pool.
+
+Examples:
+  faize warm                 # boot warm.pool VMs (at least one)
+  faize warm --count 2
+  faize warm --stop          # stop idle warm VMs (e.g. after changing config)
+  faize start --cold         # ignore warm VMs for one start`,
+	Args: cobra.NoArgs,
+	RunE: runWarm,
+}
+
+func init() {
+	warmCmd.Flags().IntVarP(&warmCount, "count", "n", 0, "number of idle VMs to keep ready (default: warm.pool, at least 1)")
+	warmCmd.Flags().BoolVar(&warmStop, "stop", false, "stop all idle warm VMs")
+	warmCmd.Flags().BoolVar(&warmDaemon, "daemon", false, "run as the background owner of a warm VM")
+	_ = warmCmd.Flags().MarkHidden("daemon")
+	rootCmd.AddCommand(warmCmd)
+}
+
+func runWarm(cmd *cobra.Command, args []string) error {
+	if warmDaemon {
+		openDaemonReady()
+		err := startSession(true)
+		if err != nil {
+			notifyDaemonParent("error " + err.Error())
+		}
+		return err
+	}
+
+	store, err := session.NewStore()
+	if err != nil {
+		return fmt.Errorf("failed to open session store: %w", err)
+	}
+	idle, err := vm.IdleWarm(store)
+	if err != nil {
+		return fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	if warmStop {
+		if len(idle) == 0 {
+			fmt.Println("No warm sessions running.")
+			return nil
+		}
+		manager, err := vm.NewManager()
+		if err != nil {
+			return err
+		}
+		for _, sess := range idle {
+			fmt.Printf("Stopping warm session %s...\n", sess.ID)
+			if err := manager.Stop(sess.ID); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to stop %s: %v\n", sess.ID, err)
+			}
+		}
+		return nil
+	}
+
+	cfg, err := config.Load("")
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if vm.WarmNeedsRoot() && cfg.Warm.Root == "" {
+		return errNoWarmRoot
+	}
+	count := warmCount
+	if count <= 0 {
+		count = max(cfg.Warm.Pool, 1)
+	}
+
+	for i := len(idle); i < count; i++ {
+		id, err := runDetached()
+		if err != nil {
+			return fmt.Errorf("failed to boot warm session: %w", err)
+		}
+		fmt.Printf("Warm session %s ready.\n", id)
+	}
+	fmt.Printf("%d warm session(s) idle. 'faize start' will claim one.\n", max(len(idle), count))
+	return nil
+}
+
+// claimWarmSession hands the project in cfg to an idle warm VM booted with the
+// same configuration and returns its session, or nil to boot a new VM
+func claimWarmSession(cfg *vm.Config, root string) *session.Session {
+	if vm.WarmNeedsRoot() && root == "" {
+		return nil
+	}
+	claim, err := vm.NewWarmClaim(cfg, root)
+	if err != nil {
+		Debug("Not using a warm session: %v", err)
+		return nil
+	}
+	store, err := session.NewStore()
+	if err != nil {
+		return nil
+	}
+	sess, err := vm.ClaimWarm(store, vm.WarmKey(cfg, root), claim)
+	if err != nil {
+		if !errors.Is(err, vm.ErrNoWarmSession) {
+			Debug("Failed to claim warm session: %v", err)
+		}
+		return nil
+	}
+	if sess.PreventSleep != cfg.PreventSleep || sess.NetworkPrompt != cfg.NetworkPrompt || sess.Notify != cfg.Notify ||
+		cfg.Name != "" || len(cfg.Labels) > 0 {
+		sess.PreventSleep = cfg.PreventSleep
+		sess.NetworkPrompt = cfg.NetworkPrompt
+		sess.Notify = cfg.Notify
+		sess.Name = cfg.Name
+		sess.Labels = cfg.Labels
+		if err := store.Save(sess); err != nil {
+			Debug("Failed to save session: %v", err)
+		}
+	}
+	return sess
+}
+
+// refillWarmPool tops the warm pool back up to size in the background, so the
+// next start finds a warm VM while this one runs
+func refillWarmPool(size int) {
+	if size <= 0 {
+		return
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return
+	}
+	child := exec.Command(exe, "warm", "--count", strconv.Itoa(size))
+	child.SysProcAttr = detachSysProcAttr()
+	if err := child.Start(); err != nil {
+		Debug("Failed to refill warm pool: %v", err)
+		return
+	}
+	_ = child.Process.Release()
+}
@@ -23,26 +23,132 @@ var HardcodedBlockedPaths = []string{
 
 // Config represents the Faize CLI configuration
 type Config struct {
-	Resources    Resources `yaml:"resources"`
-	Timeout      string    `yaml:"timeout"`
-	Networks     []string  `yaml:"networks"`
-	BlockedPaths []string  `yaml:"blocked_paths"`
-	Claude       Claude    `yaml:"claude"`
+	Resources     Resources         `yaml:"resources"`
+	Timeout       string            `yaml:"timeout"`
+	IdleTimeout   string            `yaml:"idle_timeout"` // stop after this long without console output or file changes; empty or "0" disables
+	AutoSuspend   string            `yaml:"auto_suspend"` // pause detached sessions after this long idle; empty or "0" disables
+	Watchdog      string            `yaml:"watchdog"`     // guest powers off after this long without a host heartbeat; "0" disables
+	Networks      []string          `yaml:"networks"`
+	NetworkPrompt bool              `yaml:"network_prompt"` // ask the attached user about connections the allowlist denies
+	Notify        *bool             `yaml:"notify"`         // desktop notification when a detached session needs attention (default: on)
+	Network       Network           `yaml:"network"`
+	Hosts         map[string]string `yaml:"hosts"` // extra guest /etc/hosts entries, name: ip
+	Proxy         Proxy             `yaml:"proxy"`
+	CACerts       []string          `yaml:"ca_certs"` // PEM files the guest trusts, e.g. for a TLS-intercepting proxy
+	BlockedPaths  []string          `yaml:"blocked_paths"`
+	Claude        Claude            `yaml:"claude"`
+	Limits        Limits            `yaml:"limits"`
+	Changeset     Changeset         `yaml:"changeset"`
+	Warm          Warm              `yaml:"warm"`
+	Power         Power             `yaml:"power"`
+	Redact        Redact            `yaml:"redact"`
+
+	// ProjectFile is the project's .faize.yaml merged into this config, if any
+	ProjectFile string `yaml:"-"`
+	// ProjectMounts are the mount specs from ProjectFile, as absolute paths
+	ProjectMounts []string `yaml:"-"`
 }
 
 // Resources contains resource allocation for sandbox execution
 type Resources struct {
-	CPUs   int    `yaml:"cpus"`
-	Memory string `yaml:"memory"`
+	CPUs     int    `yaml:"cpus"`
+	Memory   string `yaml:"memory"`
+	NetLimit string `yaml:"net_limit"` // guest bandwidth cap in tc syntax (10mbit); empty is unlimited
+	// MaxCPUs and MaxMemory size the VM, so 'faize resize' can grow a session
+	// up to them; they default to CPUs and Memory
+	MaxCPUs   int    `yaml:"max_cpus"`
+	MaxMemory string `yaml:"max_memory"`
+	// Disk is the size of a scratch disk mounted at /scratch (faize start
+	// --disk); empty for none
+	Disk string `yaml:"disk"`
+}
+
+// Network configures guest networking apart from the allowlist (networks)
+type Network struct {
+	Resolvers []string `yaml:"resolvers"` // DNS servers (IPv4) used instead of the public defaults, e.g. for a VPN
+	Strict    bool     `yaml:"strict"`    // only allow addresses DNS returned for allowed names
+}
+
+// Proxy routes guest web traffic through an upstream HTTP proxy, such as a
+// corporate proxy that is the only way out of the network
+type Proxy struct {
+	URL     string   `yaml:"url"`      // http://[user:password@]host:port
+	NoProxy []string `yaml:"no_proxy"` // hosts reached directly, in NO_PROXY syntax
+}
+
+// Limits caps how many VM resources faize may use at once.
+// Zero values mean unlimited.
+type Limits struct {
+	MaxRunningSessions int    `yaml:"max_running_sessions"`
+	MaxTotalMemory     string `yaml:"max_total_memory"` // e.g., "16GB"
+	// WriteQuota is how much a session may write into each writable mount
+	// before it is made read-only (faize start --write-quota), e.g., "10GB"
+	WriteQuota string `yaml:"write_quota"`
+}
+
+// Warm configures the pool of pre-booted VMs that faize start claims (faize warm)
+type Warm struct {
+	Pool int    `yaml:"pool"` // idle VMs to keep ready; zero disables the pool
+	Root string `yaml:"root"` // shared with warm VMs on macOS; projects must be under it (no default)
+}
+
+// Power controls host power management while sessions run (macOS)
+type Power struct {
+	PreventSleep string `yaml:"prevent_sleep"` // "attached" (default), "always", or "never"
+}
+
+// Redact adds patterns masked in debug logs and session output, on top of the
+// built-in token formats
+type Redact struct {
+	Patterns []string `yaml:"patterns"` // regular expressions; only the first capture group is masked when present
+}
+
+// Changeset controls which paths are excluded from session change tracking
+type Changeset struct {
+	Profiles []string `yaml:"profiles"` // ecosystem ignore profiles; empty means auto-detect
+	Ignore   []string `yaml:"ignore"`   // extra directory names to ignore at any depth, or .gitignore-style patterns
+	// HashLimit compares files up to this size (e.g. "1MB") by content, so
+	// touched or reverted files aren't reported as modified; empty disables it
+	HashLimit string `yaml:"hash_limit"`
+	// KeepContents keeps copies of files within the hash limit (1MB if unset)
+	// in the session directory, for 'faize diff --patch', 'faize review', and
+	// 'faize revert'
+	KeepContents bool `yaml:"keep_contents"`
+	// KeepDays and KeepSessions bound how long 'faize prune' keeps saved
+	// changesets: those older than KeepDays days or beyond the newest
+	// KeepSessions are removed. Zero keeps them.
+	KeepDays     int `yaml:"keep_days"`
+	KeepSessions int `yaml:"keep_sessions"`
+	// GitBranch commits each session's project changes to a faize/session-<id>
+	// branch, leaving the checked-out branch and the working tree alone
+	GitBranch bool `yaml:"git_branch"`
 }
 
 // Claude contains Claude-specific configuration
 type Claude struct {
 	AutoMounts         []string `yaml:"auto_mounts"`
 	PersistCredentials *bool    `yaml:"persist_credentials"`
+	PersistRootfs      bool     `yaml:"persist_rootfs"` // keep each project's rootfs changes (faize start --persist-rootfs)
+	PersistHome        bool     `yaml:"persist_home"`   // keep each project's /home/claude (faize start --persist-home)
+	SyncSettings       bool     `yaml:"sync_settings"`  // offer to write settings.json changes back to ~/.claude (faize start --sync-settings)
+	SyncSkills         bool     `yaml:"sync_skills"`    // offer to copy skills and plugin changes back to ~/.claude (faize start --sync-skills)
+	Kernel             string   `yaml:"kernel"`         // kernel image booted instead of the downloaded one (faize start --kernel)
+	Rootfs             string   `yaml:"rootfs"`         // rootfs image booted instead of the downloaded one (faize start --rootfs)
+	Image              string   `yaml:"image"`          // OCI image the rootfs is built from (faize start --image)
+	Flavor             string   `yaml:"flavor"`         // distribution of the Claude rootfs: alpine (default), debian or ubuntu
 	ExtraDeps          []string `yaml:"extra_deps"`
 	GitContext         *bool    `yaml:"git_context"`
 	ShowDiff           *bool    `yaml:"show_diff"`
+	Devcontainer       *bool    `yaml:"devcontainer"` // apply the project's devcontainer.json (default: true)
+}
+
+// ShouldNotify returns whether detached sessions post desktop notifications.
+// Defaults to true when not explicitly set.
+func (c *Config) ShouldNotify() bool {
+	if c.Notify == nil {
+		return true
+	}
+	return *c.Notify
 }
 
 // ShouldPersistCredentials returns whether credential persistence is enabled.
@@ -72,8 +178,19 @@ func (c *Claude) ShouldShowDiff() bool {
 	return *c.ShowDiff
 }
 
-// Load loads the configuration from ~/.faize/config.yaml or returns defaults
-func Load() (*Config, error) {
+// ShouldUseDevcontainer returns whether a project's devcontainer.json is applied.
+// Defaults to true when not explicitly set.
+func (c *Claude) ShouldUseDevcontainer() bool {
+	if c.Devcontainer == nil {
+		return true
+	}
+	return *c.Devcontainer
+}
+
+// Load loads the configuration from ~/.faize/config.yaml or returns defaults.
+// When projectDir is set, the project's .faize.yaml (see FindProjectConfig)
+// is merged on top of it.
+func Load(projectDir string) (*Config, error) {
 	home, err := homedir.Dir()
 	if err != nil {
 		return nil, err
@@ -97,8 +214,23 @@ func Load() (*Config, error) {
 	applyDefaults(&cfg)
 	cfg.BlockedPaths = expandPaths(cfg.BlockedPaths)
 	cfg.Claude.AutoMounts = expandPaths(cfg.Claude.AutoMounts)
+	cfg.CACerts = expandPaths(cfg.CACerts)
+	cfg.Warm.Root = expandPaths([]string{cfg.Warm.Root})[0]
 	cfg.BlockedPaths = mergeBlockedPaths(cfg.BlockedPaths, expandPaths(HardcodedBlockedPaths))
 
+	if projectDir != "" {
+		if path := FindProjectConfig(projectDir); path != "" {
+			pc, err := LoadProjectConfig(path)
+			if err != nil {
+				return nil, err
+			}
+			if err := mergeProject(&cfg, pc, filepath.Dir(path)); err != nil {
+				return nil, err
+			}
+			cfg.ProjectFile = path
+		}
+	}
+
 	return &cfg, nil
 }
 
@@ -145,12 +277,18 @@ func applyDefaults(cfg *Config) {
 	if cfg.Timeout == "" {
 		cfg.Timeout = "2h"
 	}
+	if cfg.Watchdog == "" {
+		cfg.Watchdog = "5m"
+	}
 	if len(cfg.Networks) == 0 {
 		cfg.Networks = []string{"npm", "pypi", "github", "anthropic"}
 	}
 	if len(cfg.BlockedPaths) == 0 {
 		cfg.BlockedPaths = defaultBlockedPaths()
 	}
+	if cfg.Power.PreventSleep == "" {
+		cfg.Power.PreventSleep = "attached"
+	}
 }
 
 // expandPaths expands ~ in paths to home directory
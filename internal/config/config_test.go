@@ -17,7 +17,7 @@ func TestLoadDefaults(t *testing.T) {
 	homedir.DisableCache = true
 	t.Cleanup(func() { homedir.DisableCache = false })
 
-	cfg, err := Load()
+	cfg, err := Load("")
 	require.NoError(t, err)
 	require.NotNil(t, cfg)
 
@@ -25,6 +25,10 @@ func TestLoadDefaults(t *testing.T) {
 	assert.Equal(t, 2, cfg.Resources.CPUs)
 	assert.Equal(t, "4GB", cfg.Resources.Memory)
 	assert.Equal(t, "2h", cfg.Timeout)
+	assert.Equal(t, "5m", cfg.Watchdog)
+	assert.Equal(t, 0, cfg.Warm.Pool)
+	assert.Equal(t, "attached", cfg.Power.PreventSleep)
+	assert.Empty(t, cfg.Warm.Root, "nothing is shared with warm VMs unless configured")
 	assert.Contains(t, cfg.Networks, "npm")
 	assert.Contains(t, cfg.Networks, "pypi")
 	assert.Contains(t, cfg.Networks, "github")
@@ -136,6 +140,13 @@ func TestShouldMountGitContext(t *testing.T) {
 	assert.False(t, c.ShouldMountGitContext())
 }
 
+func TestShouldNotify(t *testing.T) {
+	assert.True(t, (&Config{}).ShouldNotify())
+
+	falseVal := false
+	assert.False(t, (&Config{Notify: &falseVal}).ShouldNotify())
+}
+
 // Helper function to expand a single path for test assertions
 func expandPath(path string) string {
 	expanded, err := homedir.Expand(path)
@@ -0,0 +1,211 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DevcontainerFiles are where a project's dev container configuration is
+// looked for, in order
+var DevcontainerFiles = []string{".devcontainer/devcontainer.json", ".devcontainer.json"}
+
+// Devcontainer is the part of a project's devcontainer.json that faize
+// applies to its sessions
+type Devcontainer struct {
+	Path         string   // the devcontainer.json
+	Image        string   // the image the session's rootfs is built from
+	Build        bool     // the container is built from a Dockerfile or Compose file instead, which faize can't do
+	Features     []string // IDs of the features, which faize can't install
+	ForwardPorts []int    // guest ports published on the same host ports
+	PostCreate   []string // shell commands run in the guest before Claude starts
+}
+
+// FindDevcontainer returns the devcontainer.json that applies to projectDir,
+// searched for like .faize.yaml (see FindProjectConfig). Returns "" if there
+// is none.
+func FindDevcontainer(projectDir string) string {
+	return findProjectFile(projectDir, DevcontainerFiles...)
+}
+
+// LoadDevcontainer reads a devcontainer.json, which may have comments and
+// trailing commas
+func LoadDevcontainer(path string) (*Devcontainer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw struct {
+		Image             string                     `json:"image"`
+		Build             json.RawMessage            `json:"build"`
+		DockerFile        string                     `json:"dockerFile"`
+		DockerComposeFile json.RawMessage            `json:"dockerComposeFile"`
+		Features          map[string]json.RawMessage `json:"features"`
+		ForwardPorts      []json.RawMessage          `json:"forwardPorts"`
+		PostCreateCommand json.RawMessage            `json:"postCreateCommand"`
+	}
+	if err := json.Unmarshal(stripJSONC(data), &raw); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+
+	dc := &Devcontainer{
+		Path:  path,
+		Image: raw.Image,
+		Build: len(raw.Build) > 0 || raw.DockerFile != "" || len(raw.DockerComposeFile) > 0,
+	}
+	for id := range raw.Features {
+		dc.Features = append(dc.Features, id)
+	}
+	sort.Strings(dc.Features)
+	for _, p := range raw.ForwardPorts {
+		port, err := forwardPort(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid forwardPorts entry %s in %s: %w", p, path, err)
+		}
+		dc.ForwardPorts = append(dc.ForwardPorts, port)
+	}
+	if dc.PostCreate, err = lifecycleCommands(raw.PostCreateCommand); err != nil {
+		return nil, fmt.Errorf("invalid postCreateCommand in %s: %w", path, err)
+	}
+	return dc, nil
+}
+
+// forwardPort parses a forwardPorts entry: a port number, or "host:port"
+// for a port of another service, of which only the port is used
+func forwardPort(raw json.RawMessage) (int, error) {
+	var port int
+	if err := json.Unmarshal(raw, &port); err != nil {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return 0, fmt.Errorf("must be a number or a \"host:port\" string")
+		}
+		if i := strings.LastIndex(s, ":"); i >= 0 {
+			s = s[i+1:]
+		}
+		if port, err = strconv.Atoi(s); err != nil {
+			return 0, fmt.Errorf("invalid port %q", s)
+		}
+	}
+	if port < 1 || port > 65535 {
+		return 0, fmt.Errorf("port must be between 1 and 65535")
+	}
+	return port, nil
+}
+
+// lifecycleCommands turns a devcontainer lifecycle command into shell
+// commands: a string is one, an array is one command's arguments, and an
+// object holds named commands of either kind, which are run in name order
+func lifecycleCommands(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var named map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &named); err == nil {
+		names := make([]string, 0, len(named))
+		for name := range named {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var commands []string
+		for _, name := range names {
+			command, err := lifecycleCommand(named[name])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			commands = append(commands, command)
+		}
+		return commands, nil
+	}
+	command, err := lifecycleCommand(raw)
+	if err != nil {
+		return nil, err
+	}
+	return []string{command}, nil
+}
+
+// lifecycleCommand turns a command string or argument array into a shell
+// command
+func lifecycleCommand(raw json.RawMessage) (string, error) {
+	var command string
+	if err := json.Unmarshal(raw, &command); err == nil {
+		return command, nil
+	}
+	var args []string
+	if err := json.Unmarshal(raw, &args); err != nil || len(args) == 0 {
+		return "", fmt.Errorf("must be a string, an array of strings, or an object of those")
+	}
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+	}
+	return strings.Join(quoted, " "), nil
+}
+
+// stripJSONC removes the comments and trailing commas JSON with comments
+// allows, leaving strings alone, so the result parses as JSON
+func stripJSONC(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == '"':
+			// Copy the string through its closing quote
+			out = append(out, c)
+			for i++; i < len(data); i++ {
+				out = append(out, data[i])
+				if data[i] == '\\' && i+1 < len(data) {
+					i++
+					out = append(out, data[i])
+				} else if data[i] == '"' {
+					break
+				}
+			}
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			i--
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			i += 2
+			for i+1 < len(data) && (data[i] != '*' || data[i+1] != '/') {
+				i++
+			}
+			i++
+		case c == ',':
+			// Drop a comma that only whitespace and comments separate from a closing bracket
+			if next := nextToken(data[i+1:]); next == '}' || next == ']' {
+				continue
+			}
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// nextToken returns the first byte of data that isn't whitespace or part of
+// a comment, or 0 if there is none
+func nextToken(data []byte) byte {
+	for i := 0; i < len(data); i++ {
+		switch {
+		case data[i] == ' ' || data[i] == '\t' || data[i] == '\n' || data[i] == '\r':
+		case data[i] == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+		case data[i] == '/' && i+1 < len(data) && data[i+1] == '*':
+			i += 2
+			for i+1 < len(data) && (data[i] != '*' || data[i+1] != '/') {
+				i++
+			}
+			i++
+		default:
+			return data[i]
+		}
+	}
+	return 0
+}
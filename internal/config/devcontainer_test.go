@@ -0,0 +1,75 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindDevcontainer(t *testing.T) {
+	repo := t.TempDir()
+	assert.Empty(t, FindDevcontainer(repo))
+
+	writeFile(t, filepath.Join(repo, ".devcontainer.json"), "{}")
+	assert.Equal(t, filepath.Join(repo, ".devcontainer.json"), FindDevcontainer(repo))
+
+	writeFile(t, filepath.Join(repo, ".devcontainer", "devcontainer.json"), "{}")
+	assert.Equal(t, filepath.Join(repo, ".devcontainer", "devcontainer.json"), FindDevcontainer(repo), "the directory form comes first")
+}
+
+func TestLoadDevcontainer(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "devcontainer.json")
+	writeFile(t, path, `{
+	// Team dev image
+	"name": "app",
+	"image": "ghcr.io/org/dev:latest", /* pinned by CI */
+	"features": {
+		"ghcr.io/devcontainers/features/go:1": {"version": "1.24"},
+		"ghcr.io/devcontainers/features/node:1": {},
+	},
+	"forwardPorts": [3000, "db:5432"],
+	"postCreateCommand": "npm ci // not a comment",
+}`)
+	dc, err := LoadDevcontainer(path)
+	require.NoError(t, err)
+	assert.Equal(t, "ghcr.io/org/dev:latest", dc.Image)
+	assert.False(t, dc.Build)
+	assert.Equal(t, []string{"ghcr.io/devcontainers/features/go:1", "ghcr.io/devcontainers/features/node:1"}, dc.Features)
+	assert.Equal(t, []int{3000, 5432}, dc.ForwardPorts)
+	assert.Equal(t, []string{"npm ci // not a comment"}, dc.PostCreate)
+
+	writeFile(t, path, `{"build": {"dockerfile": "Dockerfile"}, "postCreateCommand": ["pip", "install", "-r", "it's.txt"]}`)
+	dc, err = LoadDevcontainer(path)
+	require.NoError(t, err)
+	assert.True(t, dc.Build)
+	assert.Equal(t, []string{`'pip' 'install' '-r' 'it'\''s.txt'`}, dc.PostCreate)
+
+	writeFile(t, path, `{"postCreateCommand": {"web": "npm ci", "api": ["go", "mod", "download"]}}`)
+	dc, err = LoadDevcontainer(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"'go' 'mod' 'download'", "npm ci"}, dc.PostCreate, "named commands run in name order")
+
+	writeFile(t, path, `{"forwardPorts": [70000]}`)
+	_, err = LoadDevcontainer(path)
+	assert.Error(t, err)
+
+	writeFile(t, path, `{"postCreateCommand": 42}`)
+	_, err = LoadDevcontainer(path)
+	assert.Error(t, err)
+}
+
+func TestStripJSONC(t *testing.T) {
+	tests := map[string]string{
+		`{"a": 1, // one` + "\n" + `}`: `{"a": 1 ` + "\n" + `}`,
+		`[1, 2, /* last */ ]`:          `[1, 2  ]`,
+		`{"url": "http://x/*y*/"}`:     `{"url": "http://x/*y*/"}`,
+		`{"q": "say \"hi\", // ok"}`:   `{"q": "say \"hi\", // ok"}`,
+		`{"a": [1,], "b": {"c": 2,},}`: `{"a": [1], "b": {"c": 2}}`,
+	}
+	for in, want := range tests {
+		assert.Equal(t, want, string(stripJSONC([]byte(in))), in)
+	}
+}
@@ -0,0 +1,168 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectConfigFile is the per-project configuration a repository can ship
+const ProjectConfigFile = ".faize.yaml"
+
+// ProjectConfig is the subset of settings a project's .faize.yaml may set.
+// Other keys are rejected so a repository can't change security settings
+// such as blocked paths or credential persistence.
+type ProjectConfig struct {
+	// Mounts are paths relative to the directory holding .faize.yaml, with
+	// an optional :ro or :rw suffix (read-only by default). They must stay
+	// inside that directory.
+	Mounts    []string  `yaml:"mounts"`
+	Networks  []string  `yaml:"networks"`   // replaces the user's networks
+	ExtraDeps []string  `yaml:"extra_deps"` // added to claude.extra_deps
+	Resources Resources `yaml:"resources"`  // overrides the user's values that it sets
+}
+
+// FindProjectConfig returns the .faize.yaml that applies to projectDir: the
+// one in projectDir itself or, inside a git repository, the nearest one in
+// a parent directory up to the repository root. Returns "" if there is none.
+func FindProjectConfig(projectDir string) string {
+	return findProjectFile(projectDir, ProjectConfigFile)
+}
+
+// findProjectFile returns the first of names, relative paths, found in
+// projectDir or, inside a git repository, the nearest parent directory up to
+// the repository root. Returns "" if there is none.
+func findProjectFile(projectDir string, names ...string) string {
+	dir, err := filepath.Abs(projectDir)
+	if err != nil {
+		return ""
+	}
+
+	var candidates []string
+	for d := dir; ; d = filepath.Dir(d) {
+		for _, name := range names {
+			candidates = append(candidates, filepath.Join(d, name))
+		}
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			break
+		}
+		if filepath.Dir(d) == d {
+			// Not in a git repository: only the project directory itself counts
+			candidates = candidates[:len(names)]
+			break
+		}
+	}
+
+	for _, path := range candidates {
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return path
+		}
+	}
+	return ""
+}
+
+// LoadProjectConfig reads a project's .faize.yaml, rejecting unknown keys
+func LoadProjectConfig(path string) (*ProjectConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var pc ProjectConfig
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&pc); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return &pc, nil
+}
+
+// mergeProject applies a project config on top of the user config:
+//   - resources: the project's values override the user's
+//   - networks: the project's list replaces the user's, but may only include
+//     "all" (unrestricted access) if the user's list does
+//   - extra_deps: added to the user's
+//   - mounts: resolved against dir and added as ProjectMounts
+func mergeProject(cfg *Config, pc *ProjectConfig, dir string) error {
+	if pc.Resources.CPUs != 0 {
+		cfg.Resources.CPUs = pc.Resources.CPUs
+	}
+	if pc.Resources.Memory != "" {
+		cfg.Resources.Memory = pc.Resources.Memory
+	}
+
+	if len(pc.Networks) > 0 {
+		if containsFold(pc.Networks, "all") && !containsFold(cfg.Networks, "all") {
+			return fmt.Errorf(`%s requests unrestricted network access ("all"), which needs "all" in the networks of ~/.faize/config.yaml`, ProjectConfigFile)
+		}
+		cfg.Networks = pc.Networks
+	}
+
+	for _, dep := range pc.ExtraDeps {
+		if !containsFold(cfg.Claude.ExtraDeps, dep) {
+			cfg.Claude.ExtraDeps = append(cfg.Claude.ExtraDeps, dep)
+		}
+	}
+
+	for _, m := range pc.Mounts {
+		spec, err := projectMountSpec(m, dir)
+		if err != nil {
+			return err
+		}
+		cfg.ProjectMounts = append(cfg.ProjectMounts, spec)
+	}
+	return nil
+}
+
+// projectMountSpec resolves a project mount ("path[:ro|:rw]") against dir
+// into an absolute mount spec, rejecting paths that leave dir
+func projectMountSpec(m, dir string) (string, error) {
+	path, mode := m, ""
+	if i := strings.LastIndex(m, ":"); i >= 0 {
+		path, mode = m[:i], m[i+1:]
+		if mode != "ro" && mode != "rw" {
+			return "", fmt.Errorf("invalid mount %q in %s: mode must be ro or rw", m, ProjectConfigFile)
+		}
+	}
+	if path == "" || filepath.IsAbs(path) || strings.HasPrefix(path, "~") {
+		return "", fmt.Errorf("invalid mount %q in %s: must be a path relative to the project", m, ProjectConfigFile)
+	}
+
+	abs := filepath.Join(dir, path)
+	inside := within(dir, abs)
+	// A symlink in the repository must not point the mount elsewhere
+	if realDir, err := filepath.EvalSymlinks(dir); err == nil {
+		if realPath, err := filepath.EvalSymlinks(abs); err == nil {
+			inside = inside && within(realDir, realPath)
+		}
+	}
+	if !inside {
+		return "", fmt.Errorf("invalid mount %q in %s: must stay inside %s", m, ProjectConfigFile, dir)
+	}
+	if mode == "" {
+		mode = "ro"
+	}
+	return abs + ":" + mode, nil
+}
+
+// within reports whether path is dir or inside it
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// containsFold reports whether list contains s, ignoring case
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+func TestFindProjectConfig(t *testing.T) {
+	t.Run("in the project directory", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, ProjectConfigFile), "networks: [npm]\n")
+		assert.Equal(t, filepath.Join(dir, ProjectConfigFile), FindProjectConfig(dir))
+	})
+
+	t.Run("nearest parent up to the git root", func(t *testing.T) {
+		repo := t.TempDir()
+		require.NoError(t, os.Mkdir(filepath.Join(repo, ".git"), 0755))
+		writeFile(t, filepath.Join(repo, ProjectConfigFile), "networks: [npm]\n")
+		pkg := filepath.Join(repo, "packages", "web")
+		require.NoError(t, os.MkdirAll(pkg, 0755))
+
+		assert.Equal(t, filepath.Join(repo, ProjectConfigFile), FindProjectConfig(pkg))
+	})
+
+	t.Run("not above the git root", func(t *testing.T) {
+		outer := t.TempDir()
+		writeFile(t, filepath.Join(outer, ProjectConfigFile), "networks: [npm]\n")
+		repo := filepath.Join(outer, "repo")
+		require.NoError(t, os.MkdirAll(filepath.Join(repo, ".git"), 0755))
+
+		assert.Empty(t, FindProjectConfig(repo))
+	})
+
+	t.Run("only the project directory outside a repository", func(t *testing.T) {
+		outer := t.TempDir()
+		writeFile(t, filepath.Join(outer, ProjectConfigFile), "networks: [npm]\n")
+		project := filepath.Join(outer, "project")
+		require.NoError(t, os.Mkdir(project, 0755))
+
+		assert.Empty(t, FindProjectConfig(project))
+	})
+}
+
+func TestLoadProjectConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "ok.yaml")
+	writeFile(t, path, "mounts: [fixtures]\nnetworks: [npm, pypi]\nextra_deps: [jq]\nresources:\n  cpus: 4\n")
+	pc, err := LoadProjectConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fixtures"}, pc.Mounts)
+	assert.Equal(t, []string{"npm", "pypi"}, pc.Networks)
+	assert.Equal(t, []string{"jq"}, pc.ExtraDeps)
+	assert.Equal(t, 4, pc.Resources.CPUs)
+
+	empty := filepath.Join(dir, "empty.yaml")
+	writeFile(t, empty, "")
+	_, err = LoadProjectConfig(empty)
+	assert.NoError(t, err)
+
+	// Security settings can't be set by a project
+	bad := filepath.Join(dir, "bad.yaml")
+	writeFile(t, bad, "blocked_paths: []\n")
+	_, err = LoadProjectConfig(bad)
+	assert.ErrorContains(t, err, "blocked_paths")
+}
+
+func TestMergeProject(t *testing.T) {
+	base := func() *Config {
+		cfg := &Config{}
+		applyDefaults(cfg)
+		cfg.Claude.ExtraDeps = []string{"git-lfs"}
+		return cfg
+	}
+
+	t.Run("precedence", func(t *testing.T) {
+		cfg := base()
+		pc := &ProjectConfig{
+			Networks:  []string{"npm"},
+			ExtraDeps: []string{"jq", "git-lfs"},
+			Resources: Resources{Memory: "8GB"},
+		}
+		require.NoError(t, mergeProject(cfg, pc, t.TempDir()))
+
+		assert.Equal(t, []string{"npm"}, cfg.Networks)
+		assert.Equal(t, []string{"git-lfs", "jq"}, cfg.Claude.ExtraDeps)
+		assert.Equal(t, "8GB", cfg.Resources.Memory)
+		assert.Equal(t, 2, cfg.Resources.CPUs, "unset project resources keep the user's value")
+	})
+
+	t.Run("unrestricted network needs the user's consent", func(t *testing.T) {
+		cfg := base()
+		err := mergeProject(cfg, &ProjectConfig{Networks: []string{"all"}}, t.TempDir())
+		assert.ErrorContains(t, err, "unrestricted")
+
+		cfg.Networks = []string{"all"}
+		assert.NoError(t, mergeProject(cfg, &ProjectConfig{Networks: []string{"all"}}, t.TempDir()))
+	})
+
+	t.Run("mounts", func(t *testing.T) {
+		dir := t.TempDir()
+		cfg := base()
+		require.NoError(t, mergeProject(cfg, &ProjectConfig{Mounts: []string{"fixtures", "../repo/cache:rw"}}, filepath.Join(dir, "repo")))
+		assert.Equal(t, []string{
+			filepath.Join(dir, "repo", "fixtures") + ":ro",
+			filepath.Join(dir, "repo", "cache") + ":rw",
+		}, cfg.ProjectMounts)
+	})
+
+	for _, m := range []string{"/etc", "~/.npmrc", "..", "../secrets", "data:wx", ""} {
+		t.Run("rejects mount "+m, func(t *testing.T) {
+			err := mergeProject(base(), &ProjectConfig{Mounts: []string{m}}, t.TempDir())
+			assert.Error(t, err)
+		})
+	}
+
+	t.Run("rejects a symlink out of the project", func(t *testing.T) {
+		dir := t.TempDir()
+		outside := t.TempDir()
+		require.NoError(t, os.Symlink(outside, filepath.Join(dir, "link")))
+
+		err := mergeProject(base(), &ProjectConfig{Mounts: []string{"link"}}, dir)
+		assert.ErrorContains(t, err, "must stay inside")
+	})
+}
+
+func TestLoadMergesProjectConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	homedir.DisableCache = true
+	t.Cleanup(func() { homedir.DisableCache = false })
+
+	writeFile(t, filepath.Join(home, ".faize", "config.yaml"), "resources:\n  cpus: 2\nnetworks: [anthropic]\n")
+	project := filepath.Join(home, "code", "app")
+	writeFile(t, filepath.Join(project, ProjectConfigFile), "resources:\n  cpus: 6\nnetworks: [anthropic, npm]\n")
+
+	cfg, err := Load(project)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(project, ProjectConfigFile), cfg.ProjectFile)
+	assert.Equal(t, 6, cfg.Resources.CPUs)
+	assert.Equal(t, []string{"anthropic", "npm"}, cfg.Networks)
+
+	cfg, err = Load("")
+	require.NoError(t, err)
+	assert.Empty(t, cfg.ProjectFile)
+	assert.Equal(t, 2, cfg.Resources.CPUs)
+}
@@ -0,0 +1,379 @@
+package doctor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/faize-ai/faize/internal/artifacts"
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/faize-ai/faize/internal/network"
+)
+
+// credentialsRefreshWindow is how close to expiry a token is reported as expiring soon
+const credentialsRefreshWindow = 24 * time.Hour
+
+// ClaudeEnv describes the host environment a Claude session is built from
+type ClaudeEnv struct {
+	ClaudeDir      string   // host ~/.claude
+	CredentialsDir string   // ~/.faize/credentials; empty when credentials are not persisted
+	RootfsPath     string   // claude-rootfs.img
+	ManifestPath   string   // versions recorded when the rootfs was built
+	ToolchainDir   string   // ~/.faize/toolchain, mounted at /opt/toolchain
+	Networks       []string // configured network presets and domains
+
+	// LookupHost resolves a domain; typically net.DefaultResolver.LookupHost
+	LookupHost func(ctx context.Context, host string) ([]string, error)
+	// LatestClaude returns the newest published Claude Code version; nil skips the comparison
+	LatestClaude func(ctx context.Context) (string, error)
+	// Now returns the current time; defaults to time.Now
+	Now func() time.Time
+}
+
+// CheckClaude verifies everything a Claude session depends on: the host
+// ~/.claude layout, settings and plugin files, persisted credentials, the
+// rootfs contents, and that the anthropic network preset resolves.
+func CheckClaude(ctx context.Context, env ClaudeEnv) []Result {
+	if env.Now == nil {
+		env.Now = time.Now
+	}
+
+	results := []Result{checkClaudeDir(env.ClaudeDir)}
+	results = append(results, checkSettings(env.ClaudeDir))
+	results = append(results, checkPlugins(env.ClaudeDir))
+	results = append(results, checkCredentials(env.CredentialsDir, env.Now()))
+	results = append(results, checkRootfs(ctx, env)...)
+	results = append(results, checkAnthropicNetwork(ctx, env))
+	return results
+}
+
+// checkClaudeDir verifies ~/.claude and the files shared read-only with the guest
+func checkClaudeDir(dir string) Result {
+	const name = "~/.claude"
+	info, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		return fail(name, "not found at "+dir, "install Claude Code and run 'claude' once on the host")
+	}
+	if err != nil {
+		return fail(name, err.Error(), "check the permissions of "+dir)
+	}
+	if !info.IsDir() {
+		return fail(name, dir+" is not a directory", "move the file aside and run 'claude' once on the host")
+	}
+	if _, err := os.ReadDir(dir); err != nil {
+		return fail(name, err.Error(), "check the permissions of "+dir)
+	}
+
+	// CLAUDE.md and keybindings.json are linked into the guest and must be readable files
+	for _, f := range []string{"CLAUDE.md", "keybindings.json"} {
+		path := filepath.Join(dir, f)
+		info, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return warn(name, fmt.Sprintf("%s: %v", f, err), "fix or remove "+path)
+		}
+		if !info.Mode().IsRegular() {
+			return warn(name, f+" is not a regular file", "replace "+path+" with a regular file")
+		}
+	}
+	return pass(name, dir)
+}
+
+// checkSettings verifies settings.json is valid JSON
+func checkSettings(dir string) Result {
+	const name = "settings.json"
+	path := filepath.Join(dir, "settings.json")
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return pass(name, "not present (defaults apply)")
+	}
+	if err != nil {
+		return fail(name, err.Error(), "check the permissions of "+path)
+	}
+	var settings map[string]any
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return fail(name, "invalid JSON: "+err.Error(), "fix the syntax in "+path)
+	}
+	return pass(name, fmt.Sprintf("valid (%d keys)", len(settings)))
+}
+
+// checkPlugins verifies that plugin registries (plugins/*.json) and plugin
+// manifests (.claude-plugin/*.json) parse
+func checkPlugins(dir string) Result {
+	const name = "plugins"
+	root := filepath.Join(dir, "plugins")
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return pass(name, "none installed")
+	}
+
+	var files, broken []string
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		if filepath.Dir(path) != root && filepath.Base(filepath.Dir(path)) != ".claude-plugin" {
+			return nil
+		}
+		files = append(files, path)
+		data, err := os.ReadFile(path)
+		if err != nil || !json.Valid(data) {
+			rel, _ := filepath.Rel(dir, path)
+			broken = append(broken, rel)
+		}
+		return nil
+	})
+
+	if len(broken) > 0 {
+		return fail(name, "invalid JSON: "+strings.Join(broken, ", "),
+			"reinstall the affected plugins with 'claude plugin' on the host")
+	}
+	return pass(name, fmt.Sprintf("%d manifest(s) parse", len(files)))
+}
+
+// checkCredentials reports whether persisted credentials exist and when they expire
+func checkCredentials(dir string, now time.Time) Result {
+	const name = "credentials"
+	if dir == "" {
+		return warn(name, "not persisted; every session starts logged out",
+			"set claude.persist_credentials: true in ~/.faize/config.yaml")
+	}
+
+	path := filepath.Join(dir, ".credentials.json")
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return warn(name, "no saved credentials yet", "log in once inside a session; the login is saved on exit")
+	}
+	if err != nil {
+		return fail(name, err.Error(), "check the permissions of "+path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fail(name, err.Error(), "check the permissions of "+path)
+	}
+
+	var creds struct {
+		OAuth *struct {
+			AccessToken  string `json:"accessToken"`
+			RefreshToken string `json:"refreshToken"`
+			ExpiresAt    int64  `json:"expiresAt"` // milliseconds since the epoch
+		} `json:"claudeAiOauth"`
+	}
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return fail(name, "invalid JSON", "delete "+path+" and log in again inside a session")
+	}
+	if creds.OAuth == nil || creds.OAuth.AccessToken == "" {
+		return warn(name, "no OAuth token saved", "log in inside a session with /login")
+	}
+
+	if r, ok := checkCredentialPermissions(dir, path, info); !ok {
+		return r
+	}
+
+	if creds.OAuth.ExpiresAt <= 0 {
+		return pass(name, "saved (no expiry recorded)")
+	}
+	expiry := time.UnixMilli(creds.OAuth.ExpiresAt)
+	stamp := expiry.Local().Format("2006-01-02 15:04")
+	canRefresh := creds.OAuth.RefreshToken != ""
+	switch {
+	case !expiry.After(now) && !canRefresh:
+		return fail(name, "expired "+stamp+" and cannot be refreshed", "log in again inside a session with /login")
+	case !expiry.After(now):
+		return warn(name, "access token expired "+stamp+"; refreshed on next start",
+			"if Claude asks you to log in, run /login inside the session")
+	case expiry.Sub(now) < credentialsRefreshWindow && !canRefresh:
+		return warn(name, "expires "+stamp+" and cannot be refreshed", "log in again inside a session with /login")
+	}
+	return pass(name, "valid until "+stamp)
+}
+
+// checkCredentialPermissions warns when another user account could read the
+// credentials file at path or anything else in dir. faize tightens them after
+// each session, so this only fires for sessions that didn't end cleanly.
+func checkCredentialPermissions(dir, path string, info os.FileInfo) (Result, bool) {
+	const name = "credentials"
+	issues, err := artifacts.CredentialPermissionIssues(dir)
+	if err != nil {
+		return fail(name, err.Error(), "check the permissions of "+dir), false
+	}
+	switch {
+	case len(issues) == 0:
+		return Result{}, true
+	case len(issues) == 1 && issues[0].Path == path:
+		return warn(name, fmt.Sprintf("readable by other users (%#o)", info.Mode().Perm()), "chmod 600 "+path), false
+	}
+	paths := make([]string, len(issues))
+	for i, issue := range issues {
+		paths[i] = fmt.Sprintf("%s (%#o)", issue.Path, issue.Mode)
+	}
+	return warn(name, "readable by other users: "+strings.Join(paths, ", "), "chmod -R go-rwx "+dir), false
+}
+
+// checkRootfs verifies the rootfs image and the tools recorded in its manifest
+func checkRootfs(ctx context.Context, env ClaudeEnv) []Result {
+	const name = "rootfs"
+	rebuild := "run 'faize claude rebuild'"
+
+	image, err := os.Stat(env.RootfsPath)
+	if os.IsNotExist(err) {
+		return []Result{warn(name, "not built yet", rebuild+", or let the next 'faize start' build it")}
+	}
+	if err != nil {
+		return []Result{fail(name, err.Error(), "check the permissions of "+env.RootfsPath)}
+	}
+
+	data, err := os.ReadFile(env.ManifestPath)
+	if err != nil {
+		return []Result{warn(name, "built without a version manifest; contents can't be verified", rebuild)}
+	}
+	manifest := ParseManifest(data)
+
+	var results []Result
+	if m, err := os.Stat(env.ManifestPath); err == nil && m.ModTime().Before(image.ModTime()) {
+		results = append(results, warn(name, "manifest is older than the image", rebuild))
+	} else {
+		results = append(results, pass(name, fmt.Sprintf("%s (%d MB)", env.RootfsPath, image.Size()>>20)))
+	}
+
+	results = append(results, checkClaudeBinary(ctx, manifest["claude"], env.LatestClaude))
+
+	if v := manifest["node"]; v != "" {
+		results = append(results, pass("node", v))
+	} else {
+		results = append(results, fail("node", "not found in rootfs", rebuild))
+	}
+
+	switch {
+	case manifest["bun"] != "":
+		results = append(results, pass("bun", manifest["bun"]))
+	case fileExists(filepath.Join(env.ToolchainDir, "bin", "bun")):
+		results = append(results, pass("bun", "installed in toolchain"))
+	default:
+		results = append(results, warn("bun", "not found in rootfs or toolchain",
+			"install bun inside a session with BUN_INSTALL=/opt/toolchain so it persists"))
+	}
+
+	switch agent := manifest["faize-agent"]; agent {
+	case strconv.Itoa(guest.AgentVersion):
+		results = append(results, pass("faize-agent", "version "+agent))
+	case "":
+		results = append(results, fail("faize-agent", "not found in rootfs", rebuild))
+	default:
+		results = append(results, fail("faize-agent", fmt.Sprintf("outdated (version %s, this faize needs %d); sessions won't start", agent, guest.AgentVersion), rebuild))
+	}
+	return results
+}
+
+// checkClaudeBinary compares the Claude Code version in the rootfs with the latest release
+func checkClaudeBinary(ctx context.Context, installed string, latest func(context.Context) (string, error)) Result {
+	const name = "claude"
+	if installed == "" {
+		return fail(name, "not found in rootfs", "run 'faize claude rebuild'")
+	}
+	if latest == nil {
+		return pass(name, installed)
+	}
+	newest, err := latest(ctx)
+	if err != nil || newest == "" {
+		return pass(name, installed+" (latest release unknown)")
+	}
+	current, _, _ := strings.Cut(installed, " ")
+	if compareVersions(current, newest) < 0 {
+		return warn(name, fmt.Sprintf("%s (latest is %s)", current, newest), "run 'faize claude rebuild' to update")
+	}
+	return pass(name, installed)
+}
+
+// checkAnthropicNetwork verifies the anthropic preset is allowed and its domains resolve
+func checkAnthropicNetwork(ctx context.Context, env ClaudeEnv) Result {
+	const name = "network"
+	if len(env.Networks) > 0 && !slices.Contains(env.Networks, "anthropic") && !slices.Contains(env.Networks, network.NetworkAll) {
+		return fail(name, "anthropic preset not in networks; Claude can't reach the API",
+			"add anthropic to networks in ~/.faize/config.yaml")
+	}
+	if env.LookupHost == nil {
+		return pass(name, "anthropic preset allowed")
+	}
+
+	var failed []string
+	for _, domain := range network.Presets["anthropic"] {
+		lookupCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		_, err := env.LookupHost(lookupCtx, domain)
+		cancel()
+		if err != nil {
+			failed = append(failed, domain)
+		}
+	}
+	if len(failed) > 0 {
+		return fail(name, "cannot resolve "+strings.Join(failed, ", "), "check the host's DNS and internet connection")
+	}
+	return pass(name, "anthropic preset resolves")
+}
+
+// ParseManifest parses the key=value lines the rootfs build records, skipping
+// blank values
+func ParseManifest(data []byte) map[string]string {
+	manifest := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok || strings.TrimSpace(value) == "" {
+			continue
+		}
+		manifest[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return manifest
+}
+
+// compareVersions compares dotted numeric versions such as 1.0.51, returning
+// -1, 0, or 1. A leading "v" and pre-release suffixes are ignored.
+func compareVersions(a, b string) int {
+	pa := versionParts(a)
+	pb := versionParts(b)
+	for i := 0; i < max(len(pa), len(pb)); i++ {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func versionParts(v string) []int {
+	v = strings.TrimPrefix(v, "v")
+	v, _, _ = strings.Cut(v, "-")
+	var parts []int
+	for _, p := range strings.Split(v, ".") {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			break
+		}
+		parts = append(parts, n)
+	}
+	return parts
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,223 @@
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+}
+
+func TestCheckClaudeDir(t *testing.T) {
+	dir := t.TempDir()
+	assert.Equal(t, Pass, checkClaudeDir(dir).Status)
+	assert.Equal(t, Fail, checkClaudeDir(filepath.Join(dir, "missing")).Status)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "CLAUDE.md"), 0755))
+	assert.Equal(t, Warn, checkClaudeDir(dir).Status)
+}
+
+func TestCheckSettings(t *testing.T) {
+	dir := t.TempDir()
+	assert.Equal(t, Pass, checkSettings(dir).Status, "missing settings use defaults")
+
+	writeFile(t, filepath.Join(dir, "settings.json"), `{"model":"opus","theme":"dark"}`)
+	r := checkSettings(dir)
+	assert.Equal(t, Pass, r.Status)
+	assert.Equal(t, "valid (2 keys)", r.Detail)
+
+	writeFile(t, filepath.Join(dir, "settings.json"), `{"model":`)
+	r = checkSettings(dir)
+	assert.Equal(t, Fail, r.Status)
+	assert.Contains(t, r.Fix, "settings.json")
+}
+
+func TestCheckPlugins(t *testing.T) {
+	dir := t.TempDir()
+	assert.Equal(t, Pass, checkPlugins(dir).Status)
+
+	writeFile(t, filepath.Join(dir, "plugins", "installed_plugins.json"), `{"plugins":{}}`)
+	writeFile(t, filepath.Join(dir, "plugins", "cache", "x", ".claude-plugin", "plugin.json"), `{"name":"x"}`)
+	writeFile(t, filepath.Join(dir, "plugins", "cache", "x", "data.json"), `not checked`)
+	r := checkPlugins(dir)
+	assert.Equal(t, Pass, r.Status)
+	assert.Equal(t, "2 manifest(s) parse", r.Detail)
+
+	writeFile(t, filepath.Join(dir, "plugins", "cache", "y", ".claude-plugin", "plugin.json"), `{`)
+	r = checkPlugins(dir)
+	assert.Equal(t, Fail, r.Status)
+	assert.Contains(t, r.Detail, filepath.Join("plugins", "cache", "y", ".claude-plugin", "plugin.json"))
+}
+
+func TestCheckCredentials(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	creds := func(refresh string, expiry time.Time) string {
+		return fmt.Sprintf(`{"claudeAiOauth":{"accessToken":"a","refreshToken":%q,"expiresAt":%d}}`, refresh, expiry.UnixMilli())
+	}
+
+	tests := []struct {
+		name    string
+		content string // empty means no file
+		want    Status
+	}{
+		{"no file", "", Warn},
+		{"invalid", `{`, Fail},
+		{"no token", `{}`, Warn},
+		{"valid", creds("r", now.Add(72*time.Hour)), Pass},
+		{"expiring, refreshable", creds("r", now.Add(time.Hour)), Pass},
+		{"expiring, not refreshable", creds("", now.Add(time.Hour)), Warn},
+		{"expired, refreshable", creds("r", now.Add(-time.Hour)), Warn},
+		{"expired, not refreshable", creds("", now.Add(-time.Hour)), Fail},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, os.Chmod(dir, 0700))
+			if tt.content != "" {
+				writeFile(t, filepath.Join(dir, ".credentials.json"), tt.content)
+			}
+			assert.Equal(t, tt.want, checkCredentials(dir, now).Status)
+		})
+	}
+
+	assert.Equal(t, Warn, checkCredentials("", now).Status, "persistence disabled")
+}
+
+func TestCheckCredentials_Permissions(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Chmod(dir, 0700))
+	path := filepath.Join(dir, ".credentials.json")
+	writeFile(t, path, `{"claudeAiOauth":{"accessToken":"a"}}`)
+	require.NoError(t, os.Chmod(path, 0644))
+
+	r := checkCredentials(dir, time.Now())
+	assert.Equal(t, Warn, r.Status)
+	assert.Equal(t, "chmod 600 "+path, r.Fix)
+
+	// Other files and the directory itself count too
+	require.NoError(t, os.Chmod(dir, 0755))
+	r = checkCredentials(dir, time.Now())
+	assert.Equal(t, Warn, r.Status)
+	assert.Contains(t, r.Detail, dir+" (0755)")
+	assert.Equal(t, "chmod -R go-rwx "+dir, r.Fix)
+
+	require.NoError(t, os.Chmod(dir, 0700))
+	require.NoError(t, os.Chmod(path, 0600))
+	assert.Equal(t, Pass, checkCredentials(dir, time.Now()).Status)
+}
+
+func TestCheckRootfs(t *testing.T) {
+	dir := t.TempDir()
+	env := ClaudeEnv{
+		RootfsPath:   filepath.Join(dir, "claude-rootfs.img"),
+		ManifestPath: filepath.Join(dir, "claude-rootfs.img.manifest"),
+		ToolchainDir: filepath.Join(dir, "toolchain"),
+	}
+	ctx := context.Background()
+
+	results := checkRootfs(ctx, env)
+	require.Len(t, results, 1)
+	assert.Equal(t, Warn, results[0].Status, "not built yet")
+
+	writeFile(t, env.RootfsPath, "image")
+	results = checkRootfs(ctx, env)
+	require.Len(t, results, 1)
+	assert.Contains(t, results[0].Detail, "manifest")
+
+	writeFile(t, env.ManifestPath, fmt.Sprintf("claude=1.0.51 (Claude Code)\nnode=v22.1.0\nbun=\nfaize-agent=%d\n", guest.AgentVersion))
+	writeFile(t, filepath.Join(env.ToolchainDir, "bin", "bun"), "")
+	statuses := map[string]Status{}
+	for _, r := range checkRootfs(ctx, env) {
+		statuses[r.Name] = r.Status
+	}
+	assert.Equal(t, map[string]Status{"rootfs": Pass, "claude": Pass, "node": Pass, "bun": Pass, "faize-agent": Pass}, statuses)
+
+	// An image from before the agent recorded its version
+	writeFile(t, env.ManifestPath, "claude=1.0.51 (Claude Code)\nnode=v22.1.0\nfaize-agent=present\n")
+	for _, r := range checkRootfs(ctx, env) {
+		if r.Name == "faize-agent" {
+			assert.Equal(t, Fail, r.Status)
+			assert.Contains(t, r.Detail, "outdated")
+		}
+	}
+
+	writeFile(t, env.ManifestPath, "node=v22.1.0\n")
+	require.NoError(t, os.Remove(filepath.Join(env.ToolchainDir, "bin", "bun")))
+	statuses = map[string]Status{}
+	for _, r := range checkRootfs(ctx, env) {
+		statuses[r.Name] = r.Status
+	}
+	assert.Equal(t, map[string]Status{"rootfs": Pass, "claude": Fail, "node": Pass, "bun": Warn, "faize-agent": Fail}, statuses)
+}
+
+func TestCheckClaudeBinary(t *testing.T) {
+	ctx := context.Background()
+	latest := func(v string, err error) func(context.Context) (string, error) {
+		return func(context.Context) (string, error) { return v, err }
+	}
+
+	assert.Equal(t, Pass, checkClaudeBinary(ctx, "1.0.51 (Claude Code)", nil).Status)
+	assert.Equal(t, Pass, checkClaudeBinary(ctx, "1.0.51 (Claude Code)", latest("1.0.51", nil)).Status)
+	assert.Equal(t, Pass, checkClaudeBinary(ctx, "1.0.51 (Claude Code)", latest("", errors.New("offline"))).Status)
+
+	r := checkClaudeBinary(ctx, "1.0.51 (Claude Code)", latest("1.2.0", nil))
+	assert.Equal(t, Warn, r.Status)
+	assert.Equal(t, "1.0.51 (latest is 1.2.0)", r.Detail)
+}
+
+func TestCheckAnthropicNetwork(t *testing.T) {
+	ctx := context.Background()
+	resolves := func(context.Context, string) ([]string, error) { return []string{"192.0.2.1"}, nil }
+
+	assert.Equal(t, Pass, checkAnthropicNetwork(ctx, ClaudeEnv{LookupHost: resolves}).Status)
+	assert.Equal(t, Pass, checkAnthropicNetwork(ctx, ClaudeEnv{Networks: []string{"all"}, LookupHost: resolves}).Status)
+	assert.Equal(t, Fail, checkAnthropicNetwork(ctx, ClaudeEnv{Networks: []string{"npm"}, LookupHost: resolves}).Status)
+
+	r := checkAnthropicNetwork(ctx, ClaudeEnv{
+		Networks: []string{"anthropic"},
+		LookupHost: func(_ context.Context, host string) ([]string, error) {
+			if host == "api.anthropic.com" {
+				return nil, errors.New("no such host")
+			}
+			return []string{"192.0.2.1"}, nil
+		},
+	})
+	assert.Equal(t, Fail, r.Status)
+	assert.Equal(t, "cannot resolve api.anthropic.com", r.Detail)
+}
+
+func TestCompareVersions(t *testing.T) {
+	assert.Equal(t, 0, compareVersions("1.0.51", "v1.0.51"))
+	assert.Equal(t, -1, compareVersions("1.0.9", "1.0.10"))
+	assert.Equal(t, 1, compareVersions("2.0", "1.9.9"))
+	assert.Equal(t, 0, compareVersions("1.0.0-beta", "1.0"))
+}
+
+func TestPrint(t *testing.T) {
+	var buf bytes.Buffer
+	results := []Result{
+		pass("settings.json", "valid (2 keys)"),
+		fail("network", "cannot resolve api.anthropic.com", "check the host's DNS"),
+	}
+	Print(&buf, results)
+
+	out := buf.String()
+	assert.Contains(t, out, "CHECK")
+	assert.Contains(t, out, "settings.json  pass    valid (2 keys)")
+	assert.Contains(t, out, "Fixes:\n  network: check the host's DNS\n")
+	assert.True(t, Failed(results))
+	assert.False(t, Failed(results[:1]))
+}
@@ -0,0 +1,73 @@
+// Package doctor runs environment checks and reports them as pass/warn/fail
+// results with suggested fixes.
+package doctor
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// Status is the outcome of a single check
+type Status string
+
+const (
+	Pass Status = "pass"
+	Warn Status = "warn" // works, but something is likely to go wrong
+	Fail Status = "fail" // sessions won't work until this is fixed
+)
+
+// Result is the outcome of a single check
+type Result struct {
+	Name   string
+	Status Status
+	Detail string
+	Fix    string // how to resolve a warn or fail; empty for pass
+}
+
+func pass(name, detail string) Result {
+	return Result{Name: name, Status: Pass, Detail: detail}
+}
+
+func warn(name, detail, fix string) Result {
+	return Result{Name: name, Status: Warn, Detail: detail, Fix: fix}
+}
+
+func fail(name, detail, fix string) Result {
+	return Result{Name: name, Status: Fail, Detail: detail, Fix: fix}
+}
+
+// Failed reports whether any result failed
+func Failed(results []Result) bool {
+	for _, r := range results {
+		if r.Status == Fail {
+			return true
+		}
+	}
+	return false
+}
+
+// Print writes results as a table, followed by the fixes for every warning
+// and failure
+func Print(w io.Writer, results []Result) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tSTATUS\tDETAIL")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", r.Name, r.Status, r.Detail)
+	}
+	_ = tw.Flush()
+
+	var fixes []Result
+	for _, r := range results {
+		if r.Status != Pass && r.Fix != "" {
+			fixes = append(fixes, r)
+		}
+	}
+	if len(fixes) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "\nFixes:")
+	for _, r := range fixes {
+		fmt.Fprintf(w, "  %s: %s\n", r.Name, r.Fix)
+	}
+}
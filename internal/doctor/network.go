@@ -0,0 +1,275 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/faize-ai/faize/internal/network"
+)
+
+// NetworkEnv describes a connection to test against a session's network policy
+type NetworkEnv struct {
+	Host      string
+	Port      int
+	SessionID string          // empty without a running session
+	Policy    *network.Policy // the session's allowlist, or the config's without a session
+	Agent     *guest.Config   // the session's agent config; nil without a session
+
+	// Run runs a command in the guest as root and returns its combined output
+	// and exit code; nil without a running session
+	Run func(ctx context.Context, args ...string) (string, int, error)
+	// ProxyLog returns the session's egress proxy log; nil without one
+	ProxyLog func() string
+}
+
+// CheckNetwork explains what happens to a connection to env.Host:env.Port:
+// whether the allowlist permits it, how the session enforces the allowlist,
+// what the name resolves to in the guest, which firewall rule accepts the
+// addresses, and whether a connection succeeds.
+func CheckNetwork(ctx context.Context, env NetworkEnv) []Result {
+	results := []Result{checkPolicy(env)}
+	if env.Run == nil || env.Agent == nil {
+		return append(results, warn("session", "no running session; only the allowlist was checked",
+			"start a session with 'faize start' to test name resolution and connections"))
+	}
+
+	results = append(results, pass("enforcement", enforcement(env.Agent)))
+	ips, resolved := checkResolve(ctx, env)
+	results = append(results, resolved)
+	if guestFirewall(env.Agent) && len(ips) > 0 {
+		results = append(results, checkFirewall(ctx, env, ips))
+	}
+	return append(results, checkConnect(ctx, env))
+}
+
+// checkPolicy reports the allowlist entry that permits the connection, or why none does
+func checkPolicy(env NetworkEnv) Result {
+	const name = "allowlist"
+	policy := env.Policy
+	allowFix := "add it to networks in ~/.faize/config.yaml"
+	if env.SessionID != "" {
+		allowFix = fmt.Sprintf("run 'faize allow %s %s', or add it to networks in ~/.faize/config.yaml", env.SessionID, hostPort(env))
+	}
+
+	switch {
+	case policy == nil || policy.AllowAll:
+		return pass(name, "all traffic is allowed")
+	case policy.Blocked:
+		return fail(name, "all network access is blocked (networks: none)", "set networks in ~/.faize/config.yaml")
+	case policy.BlocksDoH(env.Host):
+		return fail(name, env.Host+" is a DNS-over-HTTPS endpoint, blocked unless listed by name",
+			"add "+env.Host+" itself to networks")
+	}
+	if entry, ok := policy.Match(env.Host, env.Port); ok {
+		return pass(name, "allowed by "+entry)
+	}
+	if entry, ok := policy.Match(env.Host, 0); ok {
+		return fail(name, fmt.Sprintf("only allowed as %s, not on port %d", entry, env.Port), allowFix)
+	}
+	return fail(name, env.Host+" is not in the allowlist", allowFix)
+}
+
+// enforcement describes how a session enforces its allowlist
+func enforcement(cfg *guest.Config) string {
+	policy := cfg.Network
+	switch {
+	case policy == nil || policy.AllowAll:
+		return "none (all traffic allowed)"
+	case policy.Blocked:
+		return "guest firewall (all traffic blocked)"
+	case cfg.EgressPort != 0:
+		return "host egress proxy, by name"
+	case cfg.Proxy != "":
+		return "guest firewall, upstream proxy only"
+	case cfg.StrictNetwork:
+		return "guest firewall, resolved addresses only (strict)"
+	}
+	return "guest firewall, addresses resolved at boot and TLS SNI"
+}
+
+// guestFirewall reports whether the guest's iptables rules decide by address
+func guestFirewall(cfg *guest.Config) bool {
+	policy := cfg.Network
+	return policy != nil && !policy.AllowAll && !policy.Blocked && cfg.EgressPort == 0 && cfg.Proxy == ""
+}
+
+// checkResolve resolves the host in the guest and returns its IPv4 addresses
+func checkResolve(ctx context.Context, env NetworkEnv) ([]string, Result) {
+	const name = "dns"
+	if net.ParseIP(env.Host) != nil {
+		return []string{env.Host}, pass(name, env.Host+" is an address")
+	}
+	out, code, err := env.Run(ctx, "getent", "hosts", env.Host)
+	if err != nil {
+		return nil, fail(name, err.Error(), "check that the session is running")
+	}
+	if code != 0 {
+		fix := "check the name, network.resolvers, and hosts in ~/.faize/config.yaml"
+		if env.Agent.DNSPort != 0 {
+			fix = "names outside the allowlist don't resolve; " + fix
+		}
+		return nil, fail(name, env.Host+" does not resolve in the guest", fix)
+	}
+
+	var ips []string
+	for _, line := range strings.Split(out, "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 && net.ParseIP(fields[0]) != nil {
+			ips = append(ips, fields[0])
+		}
+	}
+	if len(ips) == 0 {
+		return nil, fail(name, env.Host+" resolves to no address", "check the name")
+	}
+	return ips, pass(name, strings.Join(ips, ", "))
+}
+
+// checkFirewall finds the iptables rule that accepts each IPv4 address
+func checkFirewall(ctx context.Context, env NetworkEnv, ips []string) Result {
+	const name = "firewall"
+	out, code, err := env.Run(ctx, "iptables", "-S", "OUTPUT")
+	if err != nil || code != 0 {
+		return warn(name, "could not list the iptables rules", "run 'faize exec "+env.SessionID+" --user root -- iptables -S OUTPUT'")
+	}
+	rules := strings.Split(strings.TrimSpace(out), "\n")
+
+	var accepted, dropped []string
+	for _, ip := range ips {
+		addr, err := netip.ParseAddr(ip)
+		if err != nil || !addr.Is4() {
+			continue
+		}
+		if rule := matchingRule(ctx, env, rules, addr); rule != "" {
+			accepted = append(accepted, ip+" by '"+rule+"'")
+		} else {
+			dropped = append(dropped, ip)
+		}
+	}
+
+	fix := "run 'faize allow " + env.SessionID + " " + hostPort(env) + "' to resolve and allow it again"
+	switch {
+	case len(accepted) == 0 && len(dropped) == 0:
+		return pass(name, "no IPv4 addresses to check")
+	case len(dropped) == 0:
+		return pass(name, "accepted: "+strings.Join(accepted, "; "))
+	case len(accepted) == 0:
+		return fail(name, fmt.Sprintf("no rule accepts %s on port %d; the OUTPUT policy drops them", strings.Join(dropped, ", "), env.Port), fix)
+	}
+	return warn(name, fmt.Sprintf("accepted: %s; dropped: %s", strings.Join(accepted, "; "), strings.Join(dropped, ", ")), fix)
+}
+
+// matchingRule returns the first OUTPUT rule that sends addr:port anywhere
+// but the default DROP, or "" if none does. A DNS-over-HTTPS drop counts as
+// no match.
+func matchingRule(ctx context.Context, env NetworkEnv, rules []string, addr netip.Addr) string {
+	for _, rule := range rules {
+		args := strings.Fields(rule)
+		target := flagValue(args, "-j")
+		if target != "ACCEPT" && target != "FAIZE_DOH" {
+			continue
+		}
+		if flagValue(args, "-o") == "lo" || flagValue(args, "--state") != "" {
+			continue
+		}
+		if dport := flagValue(args, "--dport"); dport != "" && dport != strconv.Itoa(env.Port) {
+			continue
+		}
+		if dest := flagValue(args, "-d"); dest != "" {
+			prefix, err := netip.ParsePrefix(dest)
+			if err != nil {
+				prefix, err = netip.ParsePrefix(dest + "/32")
+			}
+			if err != nil || !prefix.Contains(addr) {
+				continue
+			}
+		}
+		if pattern := flagValue(args, "--string"); pattern != "" {
+			host := "." + strings.ToLower(env.Host)
+			if !strings.HasSuffix(host, strings.Trim(pattern, `"`)) {
+				continue
+			}
+		}
+		if set := flagValue(args, "--match-set"); set != "" {
+			if _, code, err := env.Run(ctx, "ipset", "test", set, addr.String()); err != nil || code != 0 {
+				continue
+			}
+		}
+		if target == "FAIZE_DOH" {
+			return ""
+		}
+		return rule
+	}
+	return ""
+}
+
+// flagValue returns the argument after flag in an iptables rule, or ""
+func flagValue(args []string, flag string) string {
+	for i := 0; i < len(args)-1; i++ {
+		if args[i] == flag {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// checkConnect connects from the guest with curl, through the session's
+// proxy settings, and reports the egress proxy's decision if there is one
+func checkConnect(ctx context.Context, env NetworkEnv) Result {
+	const name = "connect"
+	scheme := "https"
+	if env.Port == 80 {
+		scheme = "http"
+	}
+	url := fmt.Sprintf("%s://%s/", scheme, hostPort(env))
+	out, code, err := env.Run(ctx, "curl", "-sSk", "-o", "/dev/null", "--max-time", "10", "-w", "HTTP %{http_code}\n", url)
+	if err != nil {
+		return fail(name, err.Error(), "check that the session is running")
+	}
+
+	detail := lastLine(out)
+	if env.ProxyLog != nil {
+		if decision := proxyDecision(env.ProxyLog(), hostPort(env)); decision != "" {
+			detail += " (proxy: " + decision + ")"
+		}
+	}
+	switch code {
+	case 0:
+		return pass(name, detail)
+	case 35, 52, 56:
+		// The TCP connection was made; the service just doesn't speak TLS or HTTP
+		if !strings.Contains(out, "CONNECT tunnel failed") {
+			return pass(name, "connected; "+detail)
+		}
+	case 28:
+		return fail(name, "timed out: "+detail, "the firewall drops the connection silently; see the checks above")
+	}
+	return fail(name, detail, "see the checks above for why the connection is refused")
+}
+
+// proxyDecision returns the last egress proxy decision for hostport, such as
+// "DENY CONNECT example.com:443"
+func proxyDecision(log, hostport string) string {
+	lines := strings.Split(strings.TrimSpace(log), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		_, decision, ok := strings.Cut(lines[i], network.ProxyLogPrefix)
+		if ok && strings.HasSuffix(decision, " "+hostport) {
+			return decision
+		}
+	}
+	return ""
+}
+
+// hostPort formats the tested host and port
+func hostPort(env NetworkEnv) string {
+	return net.JoinHostPort(env.Host, strconv.Itoa(env.Port))
+}
+
+// lastLine returns the last non-empty line of out
+func lastLine(out string) string {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -0,0 +1,170 @@
+package doctor
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/faize-ai/faize/internal/network"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeGuest answers commands run in the guest by their first two arguments
+type fakeGuest map[string]struct {
+	out  string
+	code int
+}
+
+func (g fakeGuest) run(_ context.Context, args ...string) (string, int, error) {
+	key := strings.Join(args[:2], " ")
+	if r, ok := g[key]; ok {
+		return r.out, r.code, nil
+	}
+	return "", 1, nil
+}
+
+func TestCheckPolicy(t *testing.T) {
+	policy := network.Parse([]string{"github.com", "*.npmjs.org", "db.internal:5432"})
+
+	tests := []struct {
+		host   string
+		port   int
+		status Status
+		detail string
+	}{
+		{"github.com", 443, Pass, "allowed by github.com"},
+		{"registry.npmjs.org", 443, Pass, "allowed by *.npmjs.org"},
+		{"db.internal", 5432, Pass, "allowed by db.internal:5432"},
+		{"db.internal", 443, Fail, "only allowed as db.internal:5432, not on port 443"},
+		{"example.com", 443, Fail, "example.com is not in the allowlist"},
+		{"dns.google", 443, Fail, "dns.google is a DNS-over-HTTPS endpoint, blocked unless listed by name"},
+	}
+	for _, tt := range tests {
+		r := checkPolicy(NetworkEnv{Host: tt.host, Port: tt.port, Policy: policy})
+		assert.Equal(t, tt.status, r.Status, tt.host)
+		assert.Equal(t, tt.detail, r.Detail, tt.host)
+	}
+
+	r := checkPolicy(NetworkEnv{Host: "example.com", Port: 443, SessionID: "abc123", Policy: policy})
+	assert.Contains(t, r.Fix, "faize allow abc123 example.com:443")
+
+	assert.Equal(t, Pass, checkPolicy(NetworkEnv{Host: "example.com", Port: 443, Policy: network.Parse([]string{"all"})}).Status)
+	assert.Equal(t, Fail, checkPolicy(NetworkEnv{Host: "example.com", Port: 443, Policy: network.Parse([]string{"none"})}).Status)
+}
+
+func TestCheckNetwork_NoSession(t *testing.T) {
+	results := CheckNetwork(context.Background(), NetworkEnv{
+		Host:   "github.com",
+		Port:   443,
+		Policy: network.Parse([]string{"github.com"}),
+	})
+	require.Len(t, results, 2)
+	assert.Equal(t, Pass, results[0].Status)
+	assert.Equal(t, "session", results[1].Name)
+	assert.Equal(t, Warn, results[1].Status)
+}
+
+func TestCheckNetwork_GuestFirewall(t *testing.T) {
+	policy := network.Parse([]string{"github.com"})
+	g := fakeGuest{
+		"getent hosts": {out: "140.82.112.3    github.com\n140.82.112.4    github.com\n"},
+		"iptables -S": {out: strings.Join([]string{
+			"-P OUTPUT DROP",
+			"-A OUTPUT -o lo -j ACCEPT",
+			"-A OUTPUT -m state --state RELATED,ESTABLISHED -j ACCEPT",
+			"-A OUTPUT -d 140.82.112.3/32 -p tcp -m tcp --dport 443 -j ACCEPT",
+		}, "\n")},
+		"curl -sSk": {out: "HTTP 200\n"},
+	}
+	env := NetworkEnv{
+		Host:      "github.com",
+		Port:      443,
+		SessionID: "abc123",
+		Policy:    policy,
+		Agent:     &guest.Config{Network: policy},
+		Run:       g.run,
+	}
+
+	results := CheckNetwork(context.Background(), env)
+	names := make([]string, len(results))
+	for i, r := range results {
+		names[i] = r.Name
+	}
+	assert.Equal(t, []string{"allowlist", "enforcement", "dns", "firewall", "connect"}, names)
+	assert.Equal(t, "140.82.112.3, 140.82.112.4", results[2].Detail)
+
+	firewall := results[3]
+	assert.Equal(t, Warn, firewall.Status)
+	assert.Contains(t, firewall.Detail, "140.82.112.3 by '-A OUTPUT -d 140.82.112.3/32")
+	assert.Contains(t, firewall.Detail, "dropped: 140.82.112.4")
+	assert.Equal(t, Pass, results[4].Status)
+	assert.False(t, Failed(results[:3]))
+}
+
+func TestCheckNetwork_EgressProxy(t *testing.T) {
+	policy := network.Parse([]string{"github.com"})
+	g := fakeGuest{
+		"getent hosts": {out: "10.0.2.3    example.com\n"},
+		"curl -sSk":    {out: "curl: (56) CONNECT tunnel failed, response 403\nHTTP 000\n", code: 56},
+	}
+	env := NetworkEnv{
+		Host:      "example.com",
+		Port:      443,
+		SessionID: "abc123",
+		Policy:    policy,
+		Agent:     &guest.Config{Network: policy, EgressPort: 1024},
+		Run:       g.run,
+		ProxyLog: func() string {
+			return "2026-03-01T12:00:00Z FAIZE_PROXY: DENY CONNECT example.com:443\n"
+		},
+	}
+
+	results := CheckNetwork(context.Background(), env)
+	require.Len(t, results, 4, "no firewall check behind the egress proxy")
+	assert.Equal(t, "host egress proxy, by name", results[1].Detail)
+	connect := results[3]
+	assert.Equal(t, Fail, connect.Status)
+	assert.Equal(t, "HTTP 000 (proxy: DENY CONNECT example.com:443)", connect.Detail)
+}
+
+func TestMatchingRule_IPSet(t *testing.T) {
+	g := fakeGuest{"ipset test": {}}
+	env := NetworkEnv{Host: "github.com", Port: 443, Run: g.run}
+	rules := []string{"-A OUTPUT -p tcp -m set --match-set faize-allow-443 dst -j ACCEPT"}
+	addr := mustAddr(t, "140.82.112.3")
+	assert.Equal(t, rules[0], matchingRule(context.Background(), env, rules, addr))
+
+	env.Port = 22
+	rules = []string{"-A OUTPUT -p tcp -m tcp --dport 443 -j ACCEPT"}
+	assert.Empty(t, matchingRule(context.Background(), env, rules, addr))
+}
+
+func TestMatchingRule_DoH(t *testing.T) {
+	env := NetworkEnv{Host: "1.1.1.1", Port: 443}
+	rules := []string{
+		"-A OUTPUT -d 1.1.1.1/32 -p tcp -m tcp --dport 443 -j FAIZE_DOH",
+		"-A OUTPUT -d 1.1.1.1/32 -p tcp -m tcp --dport 443 -j ACCEPT",
+	}
+	assert.Empty(t, matchingRule(context.Background(), env, rules, mustAddr(t, "1.1.1.1")))
+}
+
+func TestProxyDecision(t *testing.T) {
+	log := strings.Join([]string{
+		"2026-03-01T12:00:00Z FAIZE_PROXY: DENY CONNECT example.com:443",
+		"2026-03-01T12:00:01Z FAIZE_PROXY: ALLOW CONNECT github.com:443",
+		"2026-03-01T12:00:02Z FAIZE_PROXY: ALLOW CONNECT example.com:443",
+	}, "\n")
+	assert.Equal(t, "ALLOW CONNECT example.com:443", proxyDecision(log, "example.com:443"))
+	assert.Empty(t, proxyDecision(log, "example.com:80"))
+	assert.Empty(t, proxyDecision("", "example.com:443"))
+}
+
+func mustAddr(t *testing.T, s string) netip.Addr {
+	t.Helper()
+	addr, err := netip.ParseAddr(s)
+	require.NoError(t, err)
+	return addr
+}
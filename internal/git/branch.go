@@ -0,0 +1,239 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// CommitToBranch commits the working tree content of paths, relative to the
+// repository root, onto branch without touching the checked-out branch, the
+// index, or the working tree. The commit's parent is the branch's tip if the
+// branch exists, and HEAD otherwise. Paths that no longer exist are committed
+// as deletions; untracked paths the repository ignores, paths inside .git,
+// and paths beyond a symbolic link are left out. Files are hashed without
+// filters, so no clean filter or other program named by the repository's
+// config or .gitattributes runs. Call r.Check first, since a session could
+// have written the repository. Returns the new commit, or "" when nothing
+// differs from the parent.
+func CommitToBranch(r *Repo, branch, message string, paths []string) (string, error) {
+	ref := "refs/heads/" + branch
+	if _, err := r.run(nil, nil, "check-ref-format", ref); err != nil {
+		return "", fmt.Errorf("invalid branch name %q", branch)
+	}
+	parent, err := r.run(nil, nil, "rev-parse", "--verify", "-q", ref+"^{commit}")
+	if err != nil {
+		// A repository without commits has no HEAD; the commit becomes a root
+		parent, _ = r.run(nil, nil, "rev-parse", "--verify", "-q", "HEAD^{commit}")
+	}
+
+	index, err := os.CreateTemp("", "faize-index-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create index: %w", err)
+	}
+	_ = index.Close()
+	// git won't read an empty file as an index; read-tree creates it
+	_ = os.Remove(index.Name())
+	defer func() { _ = os.Remove(index.Name()) }()
+	env := []string{"GIT_INDEX_FILE=" + index.Name()}
+
+	readTree := []string{"read-tree", "--empty"}
+	if parent != "" {
+		readTree = []string{"read-tree", parent}
+	}
+	if _, err := r.run(env, nil, readTree...); err != nil {
+		return "", err
+	}
+
+	var list []string
+	for _, p := range paths {
+		p = filepath.ToSlash(p)
+		if p != "" && !inGitDir(p) && !strings.Contains(p, "\n") && !beyondSymlink(r.Root, p) {
+			list = append(list, p)
+		}
+	}
+	list, err = notIgnored(r, env, list)
+	if err != nil {
+		return "", err
+	}
+	if err := stagePaths(r, env, list); err != nil {
+		return "", err
+	}
+
+	tree, err := r.run(env, nil, "write-tree")
+	if err != nil {
+		return "", err
+	}
+	commitTree := []string{"commit-tree", tree, "-F", "-"}
+	if parent != "" {
+		if parentTree, _ := r.run(nil, nil, "rev-parse", parent+"^{tree}"); parentTree == tree {
+			return "", nil
+		}
+		commitTree = append(commitTree, "-p", parent)
+	}
+	commit, err := r.run(nil, strings.NewReader(message), commitTree...)
+	if err != nil {
+		return "", err
+	}
+	if _, err := r.run(nil, nil, "update-ref", ref, commit); err != nil {
+		return "", err
+	}
+	return commit, nil
+}
+
+// stagePaths puts the working tree content of paths in the index env points
+// at, hashing files with hash-object --no-filters rather than update-index
+// --add, which would run the repository's clean filters
+func stagePaths(r *Repo, env, paths []string) error {
+	var removed, files []string
+	var entries strings.Builder
+	for _, p := range paths {
+		full := filepath.Join(r.Root, filepath.FromSlash(p))
+		info, err := os.Lstat(full)
+		switch {
+		case errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.ENOTDIR):
+			removed = append(removed, p)
+		case err != nil:
+			return fmt.Errorf("failed to read %s: %w", full, err)
+		case info.Mode().IsRegular():
+			files = append(files, p)
+		case info.Mode()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(full)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", full, err)
+			}
+			blob, err := r.run(nil, strings.NewReader(target), "hash-object", "-w", "--no-filters", "--stdin")
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(&entries, "120000 %s\t%s\x00", blob, p)
+		}
+		// Directories, such as a submodule, are left as they are
+	}
+
+	if len(files) > 0 {
+		abs := make([]string, len(files))
+		for i, p := range files {
+			abs[i] = filepath.Join(r.Root, filepath.FromSlash(p))
+		}
+		out, err := r.run(nil, strings.NewReader(strings.Join(abs, "\n")+"\n"), "hash-object", "-w", "--no-filters", "--stdin-paths")
+		if err != nil {
+			return err
+		}
+		blobs := strings.Split(out, "\n")
+		if len(blobs) != len(files) {
+			return fmt.Errorf("git hash-object: got %d objects for %d files", len(blobs), len(files))
+		}
+		for i, p := range files {
+			mode := "100644"
+			if info, err := os.Lstat(abs[i]); err == nil && info.Mode()&0111 != 0 {
+				mode = "100755"
+			}
+			fmt.Fprintf(&entries, "%s %s\t%s\x00", mode, blobs[i], p)
+		}
+	}
+
+	if len(removed) > 0 {
+		stdin := strings.NewReader(strings.Join(removed, "\x00") + "\x00")
+		if _, err := r.run(env, stdin, "update-index", "--force-remove", "-z", "--stdin"); err != nil {
+			return err
+		}
+	}
+	if entries.Len() > 0 {
+		if _, err := r.run(env, strings.NewReader(entries.String()), "update-index", "-z", "--index-info"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// beyondSymlink reports whether a slash-separated path below root has a
+// symbolic link among its parent directories, which git won't add through
+// and which could point outside the repository
+func beyondSymlink(root, path string) bool {
+	parts := strings.Split(path, "/")
+	dir := root
+	for _, part := range parts[:len(parts)-1] {
+		dir = filepath.Join(dir, part)
+		info, err := os.Lstat(dir)
+		if err != nil {
+			return false
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// notIgnored drops the paths the repository ignores and doesn't track in
+// the index env points at
+func notIgnored(r *Repo, env, paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	stdin := strings.NewReader(strings.Join(paths, "\x00") + "\x00")
+	out, err := r.run(env, stdin, "check-ignore", "-z", "--stdin")
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		// None of the paths are ignored
+		return paths, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ignored := make(map[string]bool)
+	for _, p := range strings.Split(out, "\x00") {
+		ignored[p] = true
+	}
+	var kept []string
+	for _, p := range paths {
+		if !ignored[p] {
+			kept = append(kept, p)
+		}
+	}
+	return kept, nil
+}
+
+// inGitDir reports whether a slash-separated path is inside a .git directory
+func inGitDir(path string) bool {
+	for _, part := range strings.Split(path, "/") {
+		if part == ".git" {
+			return true
+		}
+	}
+	return false
+}
+
+// runHardened runs git in dir with the hardened options and extra
+// environment and input, returning its trimmed output. Errors include git's
+// stderr. Every git command faize runs goes through it.
+func runHardened(dir string, env []string, stdin *strings.Reader, args ...string) (string, error) {
+	options := make([]string, 0, 2*len(hardened))
+	for _, option := range hardened {
+		options = append(options, "-c", option)
+	}
+	return runWith(dir, options, env, stdin, args...)
+}
+
+// runWith runs git like runHardened, with options before the subcommand
+func runWith(dir string, options, env []string, stdin *strings.Reader, args ...string) (string, error) {
+	cmd := exec.Command("git", append(append([]string{"-C", dir}, options...), args...)...)
+	cmd.Env = append(os.Environ(), env...)
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
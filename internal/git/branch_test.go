@@ -0,0 +1,103 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gitOutput runs git in dir and returns its trimmed output
+func gitOutput(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(), "GIT_CONFIG_GLOBAL=/dev/null")
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, "git %v: %s", args, out)
+	return strings.TrimSpace(string(out))
+}
+
+func TestCommitToBranch(t *testing.T) {
+	t.Setenv("GIT_CONFIG_GLOBAL", "/dev/null")
+	dir := t.TempDir()
+	initGitRepo(t, dir)
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	write(".gitignore", "*.log\n")
+	write("main.go", "package main\n")
+	write("old.go", "package old\n")
+	gitOutput(t, dir, "add", "-A")
+	gitOutput(t, dir, "commit", "-qm", "initial")
+	head := gitOutput(t, dir, "rev-parse", "HEAD")
+
+	// A change made before the session stays out of the commit
+	write("README.md", "unrelated\n")
+	write("main.go", "package main\n\nfunc main() {}\n")
+	write("new.go", "package new\n")
+	write("debug.log", "noise\n")
+	require.NoError(t, os.Remove(filepath.Join(dir, "old.go")))
+
+	repo := OpenRepo(dir)
+	require.NotNil(t, repo)
+	commit, err := CommitToBranch(repo, "faize/session-abc123", "faize session abc123\n",
+		[]string{"main.go", "new.go", "old.go", "debug.log", ".git/config", "never-existed.go"})
+	require.NoError(t, err)
+	require.NotEmpty(t, commit)
+
+	assert.Equal(t, commit, gitOutput(t, dir, "rev-parse", "faize/session-abc123"))
+	assert.Equal(t, head, gitOutput(t, dir, "rev-parse", "HEAD"), "the checked-out branch doesn't move")
+	assert.Equal(t, head, gitOutput(t, dir, "rev-parse", commit+"^"))
+	assert.Equal(t, "faize session abc123", gitOutput(t, dir, "log", "-1", "--format=%B", commit))
+	assert.Equal(t, "M\tmain.go\nA\tnew.go\nD\told.go", gitOutput(t, dir, "diff", "--name-status", head, commit))
+	assert.Equal(t, "package main\n\nfunc main() {}", gitOutput(t, dir, "show", commit+":main.go"))
+
+	// The index still matches HEAD
+	assert.Equal(t, "", gitOutput(t, dir, "diff", "--cached", "--name-only"))
+
+	// Nothing new to commit
+	again, err := CommitToBranch(repo, "faize/session-abc123", "again\n", []string{"main.go"})
+	require.NoError(t, err)
+	assert.Empty(t, again)
+}
+
+func TestCommitToBranch_InvalidBranch(t *testing.T) {
+	dir := t.TempDir()
+	initGitRepo(t, dir)
+	_, err := CommitToBranch(OpenRepo(dir), "bad..name", "msg\n", nil)
+	assert.Error(t, err)
+}
+
+func TestCommitToBranch_NoFilters(t *testing.T) {
+	t.Setenv("GIT_CONFIG_GLOBAL", "/dev/null")
+	dir := t.TempDir()
+	initGitRepo(t, dir)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0644))
+	gitOutput(t, dir, "add", "-A")
+	gitOutput(t, dir, "commit", "-qm", "initial")
+
+	// A clean filter named by .gitattributes must not run on the host
+	marker := filepath.Join(t.TempDir(), "ran")
+	gitOutput(t, dir, "config", "filter.evil.clean", "touch "+marker+"; cat")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".gitattributes"), []byte("*.go filter=evil\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644))
+	require.NoError(t, os.Chmod(filepath.Join(dir, "main.go"), 0755))
+	require.NoError(t, os.Symlink("main.go", filepath.Join(dir, "link.go")))
+
+	// Nor may a path through a symlinked directory read outside the repository
+	outside := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "secret"), []byte("secret\n"), 0644))
+	require.NoError(t, os.Symlink(outside, filepath.Join(dir, "out")))
+
+	commit, err := CommitToBranch(OpenRepo(dir), "faize/session-abc123", "msg\n", []string{"main.go", "link.go", ".gitattributes", "out/secret"})
+	require.NoError(t, err)
+	assert.NoFileExists(t, marker)
+	assert.Equal(t, "package main\n\nfunc main() {}", gitOutput(t, dir, "show", commit+":main.go"))
+	assert.Equal(t, "100755 blob", gitOutput(t, dir, "ls-tree", commit, "main.go")[:11])
+	assert.Equal(t, "120000 blob", gitOutput(t, dir, "ls-tree", commit, "link.go")[:11])
+	assert.Empty(t, gitOutput(t, dir, "ls-tree", "-r", commit, "out"))
+}
@@ -0,0 +1,62 @@
+package git
+
+import (
+	"strconv"
+	"strings"
+)
+
+// FileStat is the number of lines added and deleted in one file
+type FileStat struct {
+	Path    string
+	Added   int
+	Deleted int
+	Binary  bool // binary files have no line counts
+}
+
+// Head returns the commit checked out in dir
+func Head(dir string) (string, error) {
+	return runHardened(dir, nil, nil, "rev-parse", "--verify", "HEAD^{commit}")
+}
+
+// CommitAll commits everything in dir's working tree, including untracked
+// files the repository doesn't ignore, to the checked-out branch. Returns
+// the new commit, or "" when there was nothing to commit.
+func CommitAll(dir, message string) (string, error) {
+	if _, err := runHardened(dir, nil, nil, "add", "-A"); err != nil {
+		return "", err
+	}
+	if _, err := runHardened(dir, nil, nil, "diff", "--cached", "--quiet"); err == nil {
+		return "", nil
+	}
+	if _, err := runHardened(dir, nil, strings.NewReader(message), "commit", "-q", "--no-verify", "-F", "-"); err != nil {
+		return "", err
+	}
+	return Head(dir)
+}
+
+// DiffStat lists the files that differ between two commits with their line
+// counts, ordered by path
+func DiffStat(dir, from, to string) ([]FileStat, error) {
+	out, err := runHardened(dir, nil, nil, "diff", "--numstat", "--no-renames", from, to, "--")
+	if err != nil {
+		return nil, err
+	}
+	return parseNumstat(out), nil
+}
+
+// parseNumstat parses git diff --numstat output: added and deleted line
+// counts and a path per line, with "-" counts for binary files
+func parseNumstat(out string) []FileStat {
+	var stats []FileStat
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		stat := FileStat{Path: fields[2], Binary: fields[0] == "-"}
+		stat.Added, _ = strconv.Atoi(fields[0])
+		stat.Deleted, _ = strconv.Atoi(fields[1])
+		stats = append(stats, stat)
+	}
+	return stats
+}
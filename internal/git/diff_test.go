@@ -0,0 +1,50 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommitAll(t *testing.T) {
+	t.Setenv("GIT_CONFIG_GLOBAL", "/dev/null")
+	dir := t.TempDir()
+	initGitRepo(t, dir)
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	write(".gitignore", "*.log\n")
+	write("main.go", "package main\n")
+	write("old.go", "package old\n")
+	gitOutput(t, dir, "add", "-A")
+	gitOutput(t, dir, "commit", "-qm", "initial")
+	base, err := Head(dir)
+	require.NoError(t, err)
+
+	commit, err := CommitAll(dir, "no changes\n")
+	require.NoError(t, err)
+	assert.Empty(t, commit)
+
+	write("main.go", "package main\n\nfunc main() {}\n")
+	write("new.go", "package new\n")
+	write("debug.log", "noise\n")
+	write("logo.png", "\x89PNG\x00\x01")
+	require.NoError(t, os.Remove(filepath.Join(dir, "old.go")))
+
+	commit, err = CommitAll(dir, "candidate 1\n")
+	require.NoError(t, err)
+	require.NotEmpty(t, commit)
+	assert.Equal(t, "candidate 1", gitOutput(t, dir, "log", "-1", "--format=%s"))
+
+	stats, err := DiffStat(dir, base, commit)
+	require.NoError(t, err)
+	assert.Equal(t, []FileStat{
+		{Path: "logo.png", Binary: true},
+		{Path: "main.go", Added: 2},
+		{Path: "new.go", Added: 1},
+		{Path: "old.go", Deleted: 1},
+	}, stats)
+}
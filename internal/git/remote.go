@@ -0,0 +1,94 @@
+package git
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// hardened are config options for git commands faize runs in repositories
+// a session could write to, so the repository's own config can't run
+// programs on the host through hooks, an fsmonitor, an SSH command or the
+// ext:: transport
+var hardened = []string{
+	"core.hooksPath=/dev/null",
+	"core.fsmonitor=",
+	"core.sshCommand=ssh",
+	"protocol.ext.allow=never",
+}
+
+// BranchExists reports whether a repository has a local branch
+func BranchExists(r *Repo, branch string) bool {
+	_, err := r.run(nil, nil, "rev-parse", "--verify", "-q", "refs/heads/"+branch)
+	return err == nil
+}
+
+// RemoteURL returns the fetch URL of a remote
+func RemoteURL(r *Repo, remote string) (string, error) {
+	return r.run(nil, nil, "remote", "get-url", remote)
+}
+
+// DefaultBranch returns the branch a remote's HEAD points at, such as
+// "main", or "" if the remote's HEAD isn't known locally
+func DefaultBranch(r *Repo, remote string) string {
+	ref, err := r.run(nil, nil, "symbolic-ref", "-q", "refs/remotes/"+remote+"/HEAD")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(ref, "refs/remotes/"+remote+"/")
+}
+
+// Push pushes a local branch to the branch of the same name on remote
+func Push(r *Repo, remote, branch string) error {
+	_, err := r.run(nil, nil, "push", remote, "refs/heads/"+branch+":refs/heads/"+branch)
+	return err
+}
+
+// ConfigFingerprint returns the fingerprint of the config and hooks of the
+// repository dir is in (see Repo.ConfigFingerprint), or "" if it isn't in one
+func ConfigFingerprint(dir string) string {
+	r := OpenRepo(dir)
+	if r == nil {
+		return ""
+	}
+	return r.ConfigFingerprint()
+}
+
+// fingerprint hashes the config and hooks in a repository's common git
+// directory and the worktree config in its git directory
+func fingerprint(common, gitDir string) string {
+	h := sha256.New()
+	files := []string{filepath.Join(common, "config"), filepath.Join(gitDir, "config.worktree")}
+	hooks := filepath.Join(common, "hooks")
+	_ = filepath.WalkDir(hooks, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	for _, path := range files {
+		// Name files relative to the git directory, which a symlinked
+		// project path would spell differently
+		name := filepath.Base(path)
+		if rel, err := filepath.Rel(common, path); err == nil {
+			name = rel
+		}
+		info, err := os.Lstat(path)
+		if err != nil {
+			fmt.Fprintf(h, "%s missing\n", name)
+			continue
+		}
+		data, _ := os.ReadFile(path)
+		if info.Mode()&fs.ModeSymlink != 0 {
+			target, _ := os.Readlink(path)
+			data = []byte(target)
+		}
+		fmt.Fprintf(h, "%s %s %d\n", name, info.Mode(), len(data))
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
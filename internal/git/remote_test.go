@@ -0,0 +1,93 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPush_IgnoresRepositoryHooks(t *testing.T) {
+	t.Setenv("GIT_CONFIG_GLOBAL", "/dev/null")
+	dir := t.TempDir()
+	initGitRepo(t, dir)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0644))
+	gitOutput(t, dir, "add", "-A")
+	gitOutput(t, dir, "commit", "-qm", "initial")
+	gitOutput(t, dir, "branch", "faize/session-abc123")
+
+	remote := t.TempDir()
+	gitOutput(t, remote, "init", "-q", "--bare")
+	gitOutput(t, dir, "remote", "add", "origin", remote)
+
+	// A hook planted in the repository must not run on the host
+	marker := filepath.Join(t.TempDir(), "ran")
+	hook := filepath.Join(dir, ".git", "hooks", "pre-push")
+	require.NoError(t, os.WriteFile(hook, []byte("#!/bin/sh\ntouch "+marker+"\n"), 0755))
+
+	require.NoError(t, Push(OpenRepo(dir), "origin", "faize/session-abc123"))
+	assert.NoFileExists(t, marker)
+	assert.Equal(t, gitOutput(t, dir, "rev-parse", "HEAD"), gitOutput(t, remote, "rev-parse", "faize/session-abc123"))
+}
+
+func TestConfigFingerprint(t *testing.T) {
+	t.Setenv("GIT_CONFIG_GLOBAL", "/dev/null")
+	dir := t.TempDir()
+	initGitRepo(t, dir)
+	assert.Empty(t, ConfigFingerprint(t.TempDir()), "not a repository")
+
+	before := ConfigFingerprint(dir)
+	require.NotEmpty(t, before)
+	assert.Equal(t, before, ConfigFingerprint(dir))
+
+	gitOutput(t, dir, "remote", "add", "origin", "https://example.com/repo.git")
+	changed := ConfigFingerprint(dir)
+	assert.NotEqual(t, before, changed, "a config change is noticed")
+
+	hook := filepath.Join(dir, ".git", "hooks", "pre-push")
+	require.NoError(t, os.WriteFile(hook, []byte("#!/bin/sh\n"), 0755))
+	assert.NotEqual(t, changed, ConfigFingerprint(dir), "a new hook is noticed")
+}
+
+func TestRepoCheck(t *testing.T) {
+	t.Setenv("GIT_CONFIG_GLOBAL", "/dev/null")
+	dir := t.TempDir()
+	initGitRepo(t, dir)
+	repo := OpenRepo(dir)
+	require.NotNil(t, repo)
+	assert.Nil(t, OpenRepo(t.TempDir()), "not a repository")
+
+	fingerprint := repo.ConfigFingerprint()
+	require.NoError(t, repo.Check(fingerprint))
+	assert.Error(t, repo.Check(""), "no fingerprint recorded")
+
+	gitOutput(t, dir, "config", "core.editor", "vi")
+	assert.ErrorContains(t, repo.Check(fingerprint), "changed")
+	fingerprint = repo.ConfigFingerprint()
+
+	// A .git the session replaced with a pointer to its own repository
+	planted := t.TempDir()
+	initGitRepo(t, planted)
+	require.NoError(t, os.Rename(filepath.Join(dir, ".git"), filepath.Join(t.TempDir(), "moved")))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".git"), []byte("gitdir: "+filepath.Join(planted, ".git")+"\n"), 0644))
+	assert.ErrorContains(t, repo.Check(fingerprint), "points at git directory")
+}
+
+func TestRepoEnv(t *testing.T) {
+	t.Setenv("GIT_CONFIG_GLOBAL", "/dev/null")
+	dir := t.TempDir()
+	initGitRepo(t, dir)
+	repo := OpenRepo(dir)
+	require.NotNil(t, repo)
+
+	// Tools such as gh run git with the recorded git directory and hardened options
+	cmd := exec.Command("git", "config", "--get", "core.hooksPath")
+	cmd.Dir = t.TempDir()
+	cmd.Env = append(os.Environ(), repo.Env()...)
+	out, err := cmd.Output()
+	require.NoError(t, err)
+	assert.Equal(t, "/dev/null\n", string(out))
+}
@@ -0,0 +1,118 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Repo is a repository as located before a session could write to it: its
+// working tree root and git directory. Git runs in it with GIT_DIR and
+// GIT_WORK_TREE set instead of discovering them, so a .git the session
+// planted or rewrote can't send git to a repository it controls.
+type Repo struct {
+	Root   string
+	GitDir string
+}
+
+// OpenRepo locates the repository dir is in, or returns nil if it isn't in one
+func OpenRepo(dir string) *Repo {
+	out, err := runHardened(dir, nil, nil, "rev-parse", "--show-toplevel", "--absolute-git-dir")
+	if err != nil {
+		return nil
+	}
+	root, gitDir, ok := strings.Cut(out, "\n")
+	if !ok || root == "" || gitDir == "" {
+		return nil
+	}
+	return &Repo{Root: realPath(root), GitDir: realPath(gitDir)}
+}
+
+// realPath returns path with symlinks resolved, so paths git prints and
+// paths read from a .git file compare equal, or path cleaned if it can't
+func realPath(path string) string {
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		return real
+	}
+	return filepath.Clean(path)
+}
+
+// Check returns an error unless the repository's working tree still points
+// at its git directory and its config and hooks match fingerprint, taken
+// with ConfigFingerprint before the session. Nothing else may run git in
+// the repository until Check passes.
+func (r *Repo) Check(fingerprint string) error {
+	if fingerprint == "" {
+		return fmt.Errorf("no git config fingerprint was recorded for %s", r.Root)
+	}
+	gitDir, err := dotGitTarget(r.Root)
+	if err != nil {
+		return err
+	}
+	if gitDir != r.GitDir {
+		return fmt.Errorf("%s now points at git directory %s instead of %s", filepath.Join(r.Root, ".git"), gitDir, r.GitDir)
+	}
+	if r.ConfigFingerprint() != fingerprint {
+		return fmt.Errorf("the git config or hooks in %s changed", r.Root)
+	}
+	return nil
+}
+
+// dotGitTarget returns the git directory root/.git is or, in a worktree,
+// names, without running git
+func dotGitTarget(root string) (string, error) {
+	path := filepath.Join(root, ".git")
+	info, err := os.Lstat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	switch {
+	case info.IsDir():
+		return realPath(path), nil
+	case !info.Mode().IsRegular():
+		return "", fmt.Errorf("%s is neither a directory nor a file", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	target, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir: ")
+	if !ok {
+		return "", fmt.Errorf("%s doesn't name a git directory", path)
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(root, target)
+	}
+	return realPath(target), nil
+}
+
+// ConfigFingerprint returns a hash of the repository's config and hooks,
+// which say where a push goes and what runs on the host. Comparing it tells
+// whether a session changed them.
+func (r *Repo) ConfigFingerprint() string {
+	common, err := r.run(nil, nil, "rev-parse", "--git-common-dir")
+	if err != nil {
+		return ""
+	}
+	if !filepath.IsAbs(common) {
+		common = filepath.Join(r.Root, common)
+	}
+	return fingerprint(filepath.Clean(common), r.GitDir)
+}
+
+// Env returns the environment that points git, and tools that run it such
+// as gh, at the repository with the hardened options
+func (r *Repo) Env() []string {
+	env := []string{"GIT_DIR=" + r.GitDir, "GIT_WORK_TREE=" + r.Root, fmt.Sprintf("GIT_CONFIG_COUNT=%d", len(hardened))}
+	for i, option := range hardened {
+		key, value, _ := strings.Cut(option, "=")
+		env = append(env, fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", i, key), fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", i, value))
+	}
+	return env
+}
+
+// run runs git in the repository like runHardened
+func (r *Repo) run(env []string, stdin *strings.Reader, args ...string) (string, error) {
+	return runHardened(r.Root, append(r.Env(), env...), stdin, args...)
+}
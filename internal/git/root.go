@@ -1,17 +1,11 @@
 package git
 
-import (
-	"os/exec"
-	"strings"
-)
-
 // FindRoot returns the git repository root for the given directory,
 // or an empty string if the directory is not inside a git repository.
 func FindRoot(dir string) string {
-	cmd := exec.Command("git", "-C", dir, "rev-parse", "--show-toplevel")
-	out, err := cmd.Output()
+	root, err := runHardened(dir, nil, nil, "rev-parse", "--show-toplevel")
 	if err != nil {
 		return ""
 	}
-	return strings.TrimSpace(string(out))
+	return root
 }
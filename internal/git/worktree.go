@@ -0,0 +1,64 @@
+package git
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Worktree is a working tree of a repository, as listed by git worktree list
+type Worktree struct {
+	Path   string
+	Branch string // "" when detached
+	Main   bool   // the repository's main working tree
+}
+
+// Worktrees lists the working trees of the repository at root
+func Worktrees(root string) ([]Worktree, error) {
+	out, err := runHardened(root, nil, nil, "worktree", "list", "--porcelain")
+	if err != nil {
+		return nil, err
+	}
+	return parseWorktrees(out), nil
+}
+
+// parseWorktrees parses git worktree list --porcelain output: a block of
+// lines per worktree, the first of which is the main one
+func parseWorktrees(out string) []Worktree {
+	var trees []Worktree
+	for _, line := range strings.Split(out, "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "worktree":
+			trees = append(trees, Worktree{Path: value, Main: len(trees) == 0})
+		case "branch":
+			if len(trees) > 0 {
+				trees[len(trees)-1].Branch = strings.TrimPrefix(value, "refs/heads/")
+			}
+		}
+	}
+	return trees
+}
+
+// AddWorktree creates a worktree at path with branch checked out, creating
+// the branch from HEAD if it doesn't exist
+func AddWorktree(root, path, branch string) error {
+	args := []string{"worktree", "add", path, branch}
+	if _, err := runHardened(root, nil, nil, "rev-parse", "--verify", "-q", "refs/heads/"+branch); err != nil {
+		args = []string{"worktree", "add", "-b", branch, path}
+	}
+	_, err := runHardened(root, nil, nil, args...)
+	return err
+}
+
+// CommonDir returns the absolute path of the .git directory shared by all of
+// dir's repository's worktrees, or "" if dir is not in a git repository
+func CommonDir(dir string) string {
+	out, err := runHardened(dir, nil, nil, "rev-parse", "--git-common-dir")
+	if err != nil || out == "" {
+		return ""
+	}
+	if !filepath.IsAbs(out) {
+		out = filepath.Join(dir, out)
+	}
+	return filepath.Clean(out)
+}
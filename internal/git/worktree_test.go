@@ -0,0 +1,35 @@
+package git
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorktrees(t *testing.T) {
+	t.Setenv("GIT_CONFIG_GLOBAL", "/dev/null")
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	initGitRepo(t, dir)
+	gitOutput(t, dir, "commit", "-q", "--allow-empty", "-m", "initial")
+
+	path := filepath.Join(t.TempDir(), "feature")
+	require.NoError(t, AddWorktree(dir, path, "agent/feature"))
+	assert.True(t, BranchExists(OpenRepo(dir), "agent/feature"))
+	// A branch can only be checked out in one worktree
+	assert.Error(t, AddWorktree(dir, filepath.Join(t.TempDir(), "again"), "agent/feature"))
+
+	trees, err := Worktrees(dir)
+	require.NoError(t, err)
+	require.Len(t, trees, 2)
+	assert.Equal(t, dir, trees[0].Path)
+	assert.True(t, trees[0].Main)
+	assert.Equal(t, "agent/feature", trees[1].Branch)
+	assert.False(t, trees[1].Main)
+
+	assert.Equal(t, filepath.Join(dir, ".git"), CommonDir(path))
+	assert.Equal(t, filepath.Join(dir, ".git"), CommonDir(dir))
+	assert.Empty(t, CommonDir(t.TempDir()))
+}
@@ -0,0 +1,106 @@
+// Package github opens pull requests with the GitHub REST API.
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// APIURL is the GitHub REST API endpoint
+const APIURL = "https://api.github.com"
+
+// PullRequest is a pull request to open
+type PullRequest struct {
+	Title string `json:"title"`
+	Head  string `json:"head"` // branch with the changes
+	Base  string `json:"base"` // branch to merge into
+	Body  string `json:"body"`
+	Draft bool   `json:"draft,omitempty"`
+}
+
+// Client calls the GitHub REST API with a token
+type Client struct {
+	BaseURL string // APIURL, or a GitHub Enterprise API endpoint
+	Token   string
+	HTTP    *http.Client // nil uses http.DefaultClient
+}
+
+// CreatePullRequest opens pr in owner/repo and returns its web URL
+func (c *Client) CreatePullRequest(ctx context.Context, owner, repo string, pr PullRequest) (string, error) {
+	body, err := json.Marshal(pr)
+	if err != nil {
+		return "", err
+	}
+	endpoint := fmt.Sprintf("%s/repos/%s/%s/pulls", strings.TrimSuffix(c.BaseURL, "/"), url.PathEscape(owner), url.PathEscape(repo))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to create pull request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+	var result struct {
+		HTMLURL string `json:"html_url"`
+		Message string `json:"message"`
+		Errors  []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	_ = json.Unmarshal(data, &result)
+	if resp.StatusCode != http.StatusCreated {
+		msg := result.Message
+		for _, e := range result.Errors {
+			if e.Message != "" {
+				msg += ": " + e.Message
+			}
+		}
+		if msg == "" {
+			msg = http.StatusText(resp.StatusCode)
+		}
+		return "", fmt.Errorf("failed to create pull request: %s (HTTP %d)", msg, resp.StatusCode)
+	}
+	return result.HTMLURL, nil
+}
+
+// ParseRemote returns the owner and repository of a github.com remote URL
+// in HTTPS, SSH, or scp-like form
+func ParseRemote(remote string) (owner, repo string, ok bool) {
+	var path string
+	switch {
+	case strings.HasPrefix(remote, "git@github.com:"):
+		path = strings.TrimPrefix(remote, "git@github.com:")
+	default:
+		u, err := url.Parse(remote)
+		if err != nil || !strings.EqualFold(u.Hostname(), "github.com") {
+			return "", "", false
+		}
+		path = strings.TrimPrefix(u.Path, "/")
+	}
+	path = strings.TrimSuffix(strings.TrimSuffix(path, "/"), ".git")
+	owner, repo, ok = strings.Cut(path, "/")
+	if !ok || owner == "" || repo == "" || strings.Contains(repo, "/") {
+		return "", "", false
+	}
+	return owner, repo, true
+}
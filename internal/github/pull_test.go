@@ -0,0 +1,65 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreatePullRequest(t *testing.T) {
+	var got PullRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/repos/acme/app/pulls", r.URL.Path)
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"html_url": "https://github.com/acme/app/pull/7"}`))
+	}))
+	defer srv.Close()
+
+	c := &Client{BaseURL: srv.URL, Token: "secret"}
+	pr := PullRequest{Title: "t", Head: "faize/session-abc123", Base: "main", Body: "b", Draft: true}
+	u, err := c.CreatePullRequest(context.Background(), "acme", "app", pr)
+	require.NoError(t, err)
+	assert.Equal(t, "https://github.com/acme/app/pull/7", u)
+	assert.Equal(t, pr, got)
+}
+
+func TestCreatePullRequest_Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = w.Write([]byte(`{"message": "Validation Failed", "errors": [{"message": "A pull request already exists"}]}`))
+	}))
+	defer srv.Close()
+
+	c := &Client{BaseURL: srv.URL, Token: "secret"}
+	_, err := c.CreatePullRequest(context.Background(), "acme", "app", PullRequest{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Validation Failed: A pull request already exists (HTTP 422)")
+}
+
+func TestParseRemote(t *testing.T) {
+	tests := []struct {
+		remote      string
+		owner, repo string
+		ok          bool
+	}{
+		{"https://github.com/acme/app.git", "acme", "app", true},
+		{"https://github.com/acme/app", "acme", "app", true},
+		{"git@github.com:acme/app.git", "acme", "app", true},
+		{"ssh://git@github.com/acme/app.git", "acme", "app", true},
+		{"https://gitlab.com/acme/app.git", "", "", false},
+		{"https://github.com/acme", "", "", false},
+	}
+	for _, tt := range tests {
+		owner, repo, ok := ParseRemote(tt.remote)
+		assert.Equal(t, tt.ok, ok, tt.remote)
+		assert.Equal(t, tt.owner, owner, tt.remote)
+		assert.Equal(t, tt.repo, repo, tt.remote)
+	}
+}
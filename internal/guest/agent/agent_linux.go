@@ -0,0 +1,1677 @@
+//go:build linux
+
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/faize-ai/faize/internal/network"
+	"golang.org/x/sys/unix"
+)
+
+// claudeLaunch runs Claude Code as the non-root user. script allocates the PTY
+// Claude/Ink requires for raw mode; ${PWD} is expanded by script's shell.
+const claudeLaunch = "su -s /bin/sh claude -c '" + claudeShell + "'"
+
+// oauthCallbackURL matches the localhost OAuth redirects the relay may replay
+var oauthCallbackURL = regexp.MustCompile(`^http://localhost:[0-9]+/`)
+
+// Agent runs a faize session inside the guest. It is started as PID 1 (via the
+// bootstrap init.sh) and powers the VM off when the session ends.
+type Agent struct {
+	cfg        *guest.Config
+	configPath string
+	debug      bool
+
+	mu       sync.Mutex
+	session  *exec.Cmd     // Claude or the shell
+	exited   chan struct{} // closed once session has exited; nil when not running
+	capture  *exec.Cmd     // tcpdump, when capturing network traffic
+	dnsmasq  bool
+	packages *packageState // packages when the session started; nil without apk or dpkg
+	stop     chan struct{}
+	cleaning sync.Once
+
+	state initState // completed one-time setup steps
+}
+
+// Run loads the configuration, prepares the guest, runs the session, and powers off.
+// It only returns on setup errors; the caller is expected to power off.
+func Run(configPath string) error {
+	cfg, err := guest.ReadConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	a := newAgent(cfg, configPath)
+	_ = os.Remove(filepath.Join(guest.BootstrapDir, guest.BootLogFile))
+	if err := a.state.reset(); err != nil {
+		a.warnf("%v", err)
+	}
+	a.handleSignals()
+	go a.serveExec()
+	go a.recordStats()
+	go a.watchCPUs()
+	if cfg.HeartbeatTimeout > 0 {
+		go a.watchHeartbeat(time.Duration(cfg.HeartbeatTimeout) * time.Second)
+	}
+
+	if !cfg.ClaudeMode {
+		return a.runShell()
+	}
+	return a.runClaude()
+}
+
+// Reinit re-runs the re-entrant parts of the Claude setup in a running VM,
+// for after Claude restarts or the session resumes from pause: the shims,
+// the git safe directory, and the plugin paths. Steps that must only run once
+// per boot (ownership, copied config, restored credentials) are skipped if
+// they completed.
+func Reinit(configPath string) error {
+	cfg, err := guest.ReadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	if !cfg.ClaudeMode {
+		return nil
+	}
+
+	a := newAgent(cfg, configPath)
+	a.installShims()
+	if cfg.Warm {
+		// An unclaimed warm VM has no project to set up yet
+		claim, err := guest.ReadClaim(filepath.Join(guest.BootstrapDir, guest.ClaimFile))
+		if err != nil {
+			return nil
+		}
+		a.cfg.ProjectDir = claim.ProjectDir
+	}
+	a.prepareClaudeHome()
+	return nil
+}
+
+// newAgent returns an agent for cfg; Run starts its background services
+func newAgent(cfg *guest.Config, configPath string) *Agent {
+	return &Agent{
+		cfg:        cfg,
+		configPath: configPath,
+		debug:      fileExists(filepath.Join(guest.BootstrapDir, "debug")),
+		stop:       make(chan struct{}),
+		state:      initState{dir: initStateDir},
+	}
+}
+
+// Poweroff syncs filesystems and powers the VM off
+func Poweroff() {
+	syscall.Sync()
+	if err := syscall.Reboot(syscall.LINUX_REBOOT_CMD_POWER_OFF); err != nil {
+		// Not PID 1 (or not permitted): nothing left to do but exit
+		os.Exit(1)
+	}
+}
+
+// logf records a progress message in the boot log and prints it in debug mode
+func (a *Agent) logf(format string, args ...any) {
+	a.record(false, format, args...)
+}
+
+// warnf records a warning in the boot log. It stays off the console, where
+// Claude draws, unless in debug mode; the host reports the warning count.
+func (a *Agent) warnf(format string, args ...any) {
+	a.record(true, format, args...)
+}
+
+// errorf prints an error the user must see to the console and records it
+func (a *Agent) errorf(format string, args ...any) {
+	fmt.Printf(format+"\n", args...)
+	_ = appendBootLog(filepath.Join(guest.BootstrapDir, guest.BootLogFile), time.Now(), true, fmt.Sprintf(format, args...))
+}
+
+// record appends a message to the boot log, echoing it in debug mode
+func (a *Agent) record(warning bool, format string, args ...any) {
+	if a.debug {
+		fmt.Printf(format+"\n", args...)
+	}
+	_ = appendBootLog(filepath.Join(guest.BootstrapDir, guest.BootLogFile), time.Now(), warning, fmt.Sprintf(format, args...))
+}
+
+// stage records the boot stage for the host's status line
+func (a *Agent) stage(stage string) {
+	if err := writeBootStage(guest.BootstrapDir, stage); err != nil {
+		a.logf("Warning: boot stage: %v", err)
+	}
+}
+
+// run executes a command, returning its error with combined output for context
+func run(name string, args ...string) error {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s: %w: %s", name, strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// handleSignals runs cleanup and powers off on SIGTERM/SIGINT
+func (a *Agent) handleSignals() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		<-ch
+		a.shutdown()
+	}()
+}
+
+// recordStats samples CPU and memory usage into the bootstrap directory for
+// faize top until the session stops
+func (a *Agent) recordStats() {
+	prev, _ := readCPUSample()
+	ticker := time.NewTicker(statsInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+		}
+		cur, err := readCPUSample()
+		if err != nil {
+			continue
+		}
+		stats := &guest.Stats{Time: time.Now(), CPU: cpuPercent(prev, cur)}
+		prev = cur
+		if data, err := os.ReadFile("/proc/meminfo"); err == nil {
+			stats.MemUsed, stats.MemTotal, _ = parseMemInfo(data)
+		}
+		_ = guest.WriteStats(guest.BootstrapDir, stats)
+	}
+}
+
+// watchCPUs keeps as many vCPUs online as the host asks for in CPUsFile
+// (faize resize) until the session stops
+func (a *Agent) watchCPUs() {
+	applied := 0
+	ticker := time.NewTicker(cpuPoll)
+	defer ticker.Stop()
+	for {
+		n := guest.ReadCPUs(guest.BootstrapDir)
+		if n > 0 && n != applied {
+			a.logf("Keeping %d CPUs online", n)
+			if err := setOnlineCPUs(cpuSysDir, n); err != nil {
+				a.warnf("%v", err)
+			}
+			applied = n
+		}
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func readCPUSample() (cpuSample, error) {
+	data, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return cpuSample{}, err
+	}
+	return parseCPUSample(data)
+}
+
+// watchHeartbeat cleans up and powers off when the host process that owns the
+// VM stops touching the heartbeat file (e.g. it was SIGKILLed), so orphaned
+// VMs don't run forever.
+func (a *Agent) watchHeartbeat(timeout time.Duration) {
+	monitor := newHeartbeatMonitor(filepath.Join(guest.BootstrapDir, guest.HeartbeatFile), timeout, time.Now())
+	ticker := time.NewTicker(heartbeatPoll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+		}
+		if monitor.expired(time.Now()) {
+			a.errorf("\nNo heartbeat from the faize host process for %s. Shutting down.", timeout)
+			a.shutdown()
+			return
+		}
+	}
+}
+
+// serveExec accepts faize exec requests from the host on the vsock control port
+func (a *Agent) serveExec() {
+	listener, err := guest.ListenVsock(guest.ExecPort)
+	if err != nil {
+		a.logf("faize exec unavailable: %v", err)
+		return
+	}
+	for {
+		conn, err := guest.AcceptVsock(listener)
+		if err != nil {
+			a.logf("faize exec: %v", err)
+			return
+		}
+		go handleExec(conn, a.cfg.WorkDir(), a.prepareExec)
+	}
+}
+
+// prepareExec runs exec commands as the session user (claude in Claude mode,
+// otherwise root) unless the request names another user.
+func (a *Agent) prepareExec(cmd *exec.Cmd, req *guest.ExecRequest) error {
+	name := req.User
+	if name == "" {
+		name = "root"
+		if a.cfg.ClaudeMode {
+			name = "claude"
+		}
+	}
+
+	u, err := user.Lookup(name)
+	if err != nil {
+		return fmt.Errorf("unknown user %q", name)
+	}
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid uid for %s: %w", name, err)
+	}
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid gid for %s: %w", name, err)
+	}
+
+	// The command gets its own process group, so killing it when the host
+	// hangs up kills what it started too
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if uid != 0 {
+		cmd.SysProcAttr.Credential = &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}
+	}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.Env = append(cmd.Env, "HOME="+u.HomeDir, "USER="+u.Username)
+	if a.cfg.ClaudeMode {
+		cmd.Env = append(cmd.Env, "GIT_DISCOVERY_ACROSS_FILESYSTEM=1")
+	}
+	cmd.Env = append(cmd.Env, a.sessionEnv()...)
+	return nil
+}
+
+// sessionEnv returns the environment every session process gets on top of
+// its own: the egress proxy settings when the host proxy enforces the
+// policy, or the upstream proxy's, and the CA bundle for Node with custom CAs
+func (a *Agent) sessionEnv() []string {
+	var env []string
+	switch {
+	case a.cfg.EgressPort != 0:
+		env = ProxyEnv()
+	case a.cfg.Proxy != "":
+		env = UpstreamProxyEnv(a.cfg.Proxy, a.cfg.NoProxy)
+	}
+	if len(a.cfg.CACerts) > 0 {
+		// Node ships its own CA list; point it at the updated system bundle
+		env = append(env, "NODE_EXTRA_CA_CERTS="+caBundle)
+	}
+	return env
+}
+
+// runShell is the plain (non-Claude) session: mounts, clock, and an interactive shell
+func (a *Agent) runShell() error {
+	a.stage(guest.BootMounting)
+	if err := a.mountShares(); err != nil {
+		return err
+	}
+	a.syncClock()
+
+	if err := os.Chdir(a.cfg.WorkDir()); err != nil {
+		a.warnf("cd %s: %v", a.cfg.WorkDir(), err)
+	}
+
+	cmd := exec.Command("setsid", "/bin/sh")
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	a.stage(guest.BootReady)
+	a.startSession(cmd)
+	_ = a.waitSession(cmd)
+
+	a.shutdown()
+	return nil
+}
+
+// runClaude prepares the guest for Claude Code, runs it, and shuts down
+func (a *Agent) runClaude() error {
+	a.stage(guest.BootMounting)
+	if err := a.mountShares(); err != nil {
+		return err
+	}
+	if err := mountDevPts(); err != nil {
+		return err
+	}
+	a.syncClock()
+	a.applyInitialTermSize()
+
+	a.stage(guest.BootNetwork)
+	if len(a.cfg.CACerts) > 0 {
+		a.installCACerts()
+	}
+	if a.cfg.CaptureNetwork {
+		a.startCapture()
+	}
+	if err := a.setupNetwork(); err != nil {
+		return err
+	}
+	if UsesDNSForwarder(a.cfg.Network) {
+		go a.collectNetworkLog()
+	}
+
+	a.installShims()
+	if a.cfg.Warm {
+		a.stage(guest.BootWaiting)
+		if err := a.waitForClaim(); err != nil {
+			return err
+		}
+		a.applyInitialTermSize()
+	}
+	a.stage(guest.BootPreparing)
+	a.prepareClaudeHome()
+	if a.cfg.SSH {
+		a.startSSH()
+	}
+	if len(a.cfg.PostCreate) > 0 {
+		_, _ = a.state.once(stepPostCreate, func() error {
+			a.runPostCreate()
+			return nil
+		})
+	}
+
+	if err := os.Chdir(a.cfg.WorkDir()); err != nil {
+		a.warnf("cd %s: %v", a.cfg.WorkDir(), err)
+	}
+	if a.debug {
+		if checkURL("https://registry.npmjs.org", a.cfg.Proxy) {
+			fmt.Println("npm registry OK")
+		} else {
+			fmt.Println("npm registry FAILED")
+		}
+	}
+
+	go a.relayOAuthCallbacks()
+	go a.watchTermSize()
+
+	cmd := exec.Command("script", "-q", "-c", claudeLaunch, "/dev/null")
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = append(os.Environ(), "PWD="+a.cfg.WorkDir())
+	cmd.Env = append(cmd.Env, a.sessionEnv()...)
+	if state, ok := readInstalledPackages(); ok {
+		a.mu.Lock()
+		a.packages = &state
+		a.mu.Unlock()
+	}
+	if len(a.cfg.Tasks) > 0 {
+		a.stage(guest.BootReady)
+		a.runTasks()
+		a.shutdown()
+		return nil
+	}
+	if a.cfg.Prompt != "" {
+		a.stage(guest.BootReady)
+		fmt.Printf("Claude exited with code: %d\n", a.runPrompt(guest.BootstrapDir, a.cfg.Prompt, a.cfg.PromptArgs))
+		a.shutdown()
+		return nil
+	}
+	a.stage(guest.BootReady)
+	a.startSession(cmd)
+	err := a.waitSession(cmd)
+
+	code := 0
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		code = exitErr.ExitCode()
+	} else if err != nil {
+		code = -1
+	}
+	fmt.Printf("Claude exited with code: %d\n", code)
+	if err := os.WriteFile(filepath.Join(guest.BootstrapDir, guest.ExitFile), []byte(strconv.Itoa(code)+"\n"), 0644); err != nil {
+		a.warnf("failed to record the exit code: %v", err)
+	}
+
+	a.shutdown()
+	return nil
+}
+
+// runTasks runs the task queue one prompt at a time. After each task it
+// waits for the host to record the task's changes and say whether to go on.
+func (a *Agent) runTasks() {
+	for i, t := range a.cfg.Tasks {
+		dir := filepath.Join(guest.BootstrapDir, guest.TaskDir(i))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			a.errorf("failed to create %s: %v", dir, err)
+			return
+		}
+		fmt.Printf("Task %d exited with code: %d\n", i+1, a.runPrompt(dir, t.Prompt, t.Args))
+		if i == len(a.cfg.Tasks)-1 || !a.waitForNextTask(dir) {
+			return
+		}
+	}
+}
+
+// waitForNextTask waits for the host's answer in a finished task's
+// directory and reports whether to run the next task
+func (a *Agent) waitForNextTask(dir string) bool {
+	path := filepath.Join(dir, guest.TaskNextFile)
+	ticker := time.NewTicker(claimPoll)
+	defer ticker.Stop()
+	for {
+		if data, err := os.ReadFile(path); err == nil {
+			return strings.TrimSpace(string(data)) == guest.TaskContinue
+		}
+		select {
+		case <-a.stop:
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// runPrompt runs claude -p with prompt on stdin and extra arguments, writing
+// its output to dir for faize run, and records its exit code there
+func (a *Agent) runPrompt(dir, prompt string, args []string) int {
+	stdout, err := os.Create(filepath.Join(dir, guest.RunOutputFile))
+	if err != nil {
+		a.errorf("failed to create %s: %v", guest.RunOutputFile, err)
+		return -1
+	}
+	defer func() { _ = stdout.Close() }()
+	stderr, err := os.Create(filepath.Join(dir, guest.RunErrorFile))
+	if err != nil {
+		a.errorf("failed to create %s: %v", guest.RunErrorFile, err)
+		return -1
+	}
+	defer func() { _ = stderr.Close() }()
+
+	cmd := exec.Command("su", "-s", "/bin/sh", "claude", "-c", promptCommand(args))
+	cmd.Stdin = strings.NewReader(prompt)
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	cmd.Env = append(os.Environ(), "PWD="+a.cfg.WorkDir())
+	cmd.Env = append(cmd.Env, a.sessionEnv()...)
+	a.startSession(cmd)
+	err = a.waitSession(cmd)
+
+	code := 0
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		code = exitErr.ExitCode()
+	} else if err != nil {
+		code = -1
+	}
+	_ = os.WriteFile(filepath.Join(dir, guest.RunExitFile), []byte(strconv.Itoa(code)+"\n"), 0644)
+	return code
+}
+
+// startSession starts the session process and records it for cleanup
+func (a *Agent) startSession(cmd *exec.Cmd) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := cmd.Start(); err != nil {
+		a.errorf("failed to start %s: %v", cmd.Path, err)
+		return
+	}
+	a.session = cmd
+	a.exited = make(chan struct{})
+}
+
+// waitSession waits for a session process started with startSession to exit,
+// and lets shutdown know it has
+func (a *Agent) waitSession(cmd *exec.Cmd) error {
+	err := cmd.Wait()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.session == cmd && a.exited != nil {
+		close(a.exited)
+		a.exited = nil
+	}
+	return err
+}
+
+// mountShares mounts the session's VirtioFS shares
+func (a *Agent) mountShares() error {
+	if a.cfg.ClaudeMode && a.cfg.PersistHome {
+		a.mountHome()
+	}
+	for i, m := range a.cfg.Mounts {
+		tag := m.Tag
+		if tag == "" {
+			tag = fmt.Sprintf("mount%d", i)
+		}
+		if err := mountVirtioFS(tag, m.Target, m.ReadOnly); err != nil {
+			return err
+		}
+	}
+	if a.cfg.ClaudeMode && a.cfg.PersistCredentials {
+		if err := mountVirtioFS("credentials", hostCredsDir, false); err != nil {
+			return err
+		}
+	}
+	if a.cfg.ScratchDisk {
+		a.mountScratch()
+	}
+	return nil
+}
+
+// mountHome mounts the project's persistent home over the claude user's
+// home, first seeding it with the rootfs's home if it is empty. The session
+// works with the rootfs's home, so failures only warn.
+func (a *Agent) mountHome() {
+	if err := mountVirtioFS("home", hostHomeDir, false); err != nil {
+		a.warnf("persistent home unavailable: %v", err)
+		return
+	}
+	empty, err := isEmptyDir(hostHomeDir)
+	if err != nil {
+		a.warnf("persistent home unavailable: %v", err)
+		return
+	}
+	if empty {
+		if err := run("cp", "-a", claudeHome+"/.", hostHomeDir+"/"); err != nil {
+			a.warnf("failed to seed persistent home: %v", err)
+			return
+		}
+	}
+	if err := bindMount(hostHomeDir, claudeHome, false); err != nil {
+		a.warnf("failed to mount persistent home: %v", err)
+	}
+}
+
+// mountScratch formats the scratch disk if it is blank and mounts it at
+// guest.ScratchDir. The session works without it, so failures only warn.
+func (a *Agent) mountScratch() {
+	if err := os.MkdirAll(guest.ScratchDir, 0755); err != nil {
+		a.warnf("failed to create %s: %v", guest.ScratchDir, err)
+		return
+	}
+	formatted, err := hasExt4(scratchDevice)
+	if err != nil {
+		a.warnf("scratch disk unavailable: %v", err)
+		return
+	}
+	if !formatted {
+		if err := run("mkfs.ext4", "-q", "-F", "-m", "0", "-L", "faize-scratch", scratchDevice); err != nil {
+			a.warnf("failed to format scratch disk: %v", err)
+			return
+		}
+	}
+	if err := syscall.Mount(scratchDevice, guest.ScratchDir, "ext4", syscall.MS_NOATIME, ""); err != nil {
+		a.warnf("failed to mount scratch disk at %s: %v", guest.ScratchDir, err)
+	}
+}
+
+// mountVirtioFS mounts a VirtioFS share by tag
+func mountVirtioFS(tag, target string, readOnly bool) error {
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return fmt.Errorf("failed to create mount point %s: %w", target, err)
+	}
+	var flags uintptr
+	if readOnly {
+		flags |= syscall.MS_RDONLY
+	}
+	if err := syscall.Mount(tag, target, "virtiofs", flags, ""); err != nil {
+		return fmt.Errorf("failed to mount %s at %s: %w", tag, target, err)
+	}
+	return nil
+}
+
+// claimPoll is how often an idle warm VM checks for a claim
+const claimPoll = 50 * time.Millisecond
+
+// claimShareTimeout bounds the wait for a claim's hotplugged shares to appear
+const claimShareTimeout = 10 * time.Second
+
+// waitForClaim idles a warm VM until the host hands it a project, then binds
+// the project mounts from the shares added for it, or from the warm root,
+// and unmounts those so nothing else under them stays visible to the session
+func (a *Agent) waitForClaim() error {
+	a.logf("Warm VM ready, waiting for a project")
+	path := filepath.Join(guest.BootstrapDir, guest.ClaimFile)
+	ticker := time.NewTicker(claimPoll)
+	defer ticker.Stop()
+	for !fileExists(path) {
+		select {
+		case <-a.stop:
+			return fmt.Errorf("shut down before a project was claimed")
+		case <-ticker.C:
+		}
+	}
+
+	claim, err := guest.ReadClaim(path)
+	if err != nil {
+		return err
+	}
+	for _, s := range claim.Shares {
+		if err := mountHotplugged(s.Tag, s.Path); err != nil {
+			return err
+		}
+	}
+	for _, b := range claim.Binds {
+		if err := bindMount(b.Source, b.Target, b.ReadOnly); err != nil {
+			return err
+		}
+	}
+	shared := []string{guest.WarmRoot}
+	if len(claim.Shares) > 0 {
+		shared = shared[:0]
+		for _, s := range claim.Shares {
+			shared = append(shared, s.Path)
+		}
+	}
+	for _, dir := range shared {
+		if err := syscall.Unmount(dir, syscall.MNT_DETACH); err != nil {
+			return fmt.Errorf("failed to unmount %s: %w", dir, err)
+		}
+	}
+	a.cfg.ProjectDir = claim.ProjectDir
+	a.cfg.SyncSettings = claim.SyncSettings
+	a.cfg.SyncSkills = claim.SyncSkills
+	a.logf("Claimed for %s", claim.ProjectDir)
+	return nil
+}
+
+// mountHotplugged mounts a share the host has just added, retrying until the
+// guest has picked up the device
+func mountHotplugged(tag, target string) error {
+	deadline := time.Now().Add(claimShareTimeout)
+	for {
+		err := mountVirtioFS(tag, target, false)
+		if err == nil || time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(claimPoll)
+	}
+}
+
+// bindMount exposes source at target, optionally read-only
+func bindMount(source, target string, readOnly bool) error {
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return fmt.Errorf("failed to create mount point %s: %w", target, err)
+	}
+	if err := syscall.Mount(source, target, "", syscall.MS_BIND, ""); err != nil {
+		return fmt.Errorf("failed to bind %s at %s: %w", source, target, err)
+	}
+	if readOnly {
+		// MS_RDONLY only takes effect on a bind mount through a remount
+		if err := syscall.Mount("", target, "", syscall.MS_REMOUNT|syscall.MS_BIND|syscall.MS_RDONLY, ""); err != nil {
+			return fmt.Errorf("failed to make %s read-only: %w", target, err)
+		}
+	}
+	return nil
+}
+
+// mountDevPts mounts devpts for PTY support (required by script)
+func mountDevPts() error {
+	if err := os.MkdirAll("/dev/pts", 0755); err != nil {
+		return err
+	}
+	if err := syscall.Mount("devpts", "/dev/pts", "devpts", 0, "gid=5,mode=620"); err != nil {
+		return fmt.Errorf("failed to mount devpts: %w", err)
+	}
+	return nil
+}
+
+// syncClock sets the system time from the host timestamp in the bootstrap dir
+func (a *Agent) syncClock() {
+	data, err := os.ReadFile(filepath.Join(guest.BootstrapDir, "hosttime"))
+	if err != nil {
+		return
+	}
+	var sec int64
+	if _, err := fmt.Sscanf(strings.TrimSpace(string(data)), "%d", &sec); err != nil {
+		a.warnf("Clock sync failed")
+		return
+	}
+	tv := syscall.Timeval{Sec: sec}
+	if err := syscall.Settimeofday(&tv); err != nil {
+		a.warnf("Clock sync failed")
+		return
+	}
+	a.logf("Clock synced from host")
+}
+
+// readTermSize returns the host terminal size from the bootstrap dir
+func readTermSize() (cols, rows int, ok bool) {
+	data, err := os.ReadFile(filepath.Join(guest.BootstrapDir, "termsize"))
+	if err != nil {
+		return 0, 0, false
+	}
+	return ParseTermSize(string(data))
+}
+
+// setWinsize applies a terminal size to fd; the kernel signals the foreground process group
+func setWinsize(fd int, cols, rows int) error {
+	return unix.IoctlSetWinsize(fd, unix.TIOCSWINSZ, &unix.Winsize{Col: uint16(cols), Row: uint16(rows)})
+}
+
+// applyInitialTermSize sizes the console to the host terminal (keeps URLs unwrapped)
+func (a *Agent) applyInitialTermSize() {
+	if cols, rows, ok := readTermSize(); ok {
+		if err := setWinsize(int(os.Stdin.Fd()), cols, rows); err == nil {
+			a.logf("Terminal size: %dx%d", cols, rows)
+		}
+	}
+}
+
+// watchTermSize resizes the session PTY when the host terminal size changes
+func (a *Agent) watchTermSize() {
+	last := ""
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+		}
+		cols, rows, ok := readTermSize()
+		if !ok {
+			continue
+		}
+		size := fmt.Sprintf("%d %d", cols, rows)
+		if size == last {
+			continue
+		}
+		last = size
+
+		// Resize only the first PTY slave (created by script)
+		ptys, _ := filepath.Glob("/dev/pts/[0-9]*")
+		if len(ptys) == 0 {
+			continue
+		}
+		sort.Strings(ptys)
+		f, err := os.OpenFile(ptys[0], os.O_RDWR|syscall.O_NOCTTY, 0)
+		if err != nil {
+			continue
+		}
+		_ = setWinsize(int(f.Fd()), cols, rows)
+		_ = f.Close()
+	}
+}
+
+// setupNetwork brings up the interface via DHCP, configures DNS, and applies the policy
+func (a *Agent) setupNetwork() error {
+	a.logf("Setting up network...")
+	if err := run("ifconfig", "lo", "127.0.0.1", "up"); err != nil {
+		a.warnf("loopback: %v", err)
+	}
+	if len(a.cfg.Hosts) > 0 {
+		existing, _ := os.ReadFile("/etc/hosts")
+		if err := os.WriteFile("/etc/hosts", []byte(HostsFile(string(existing), a.cfg.Hosts)), 0644); err != nil {
+			a.warnf("failed to write /etc/hosts: %v", err)
+		}
+	}
+
+	if iface := firstInterface(); iface != "" {
+		a.logf("Found interface: %s", iface)
+		if err := run("ifconfig", iface, "up"); err != nil {
+			a.warnf("%v", err)
+		}
+		a.logf("Running DHCP...")
+		if err := run("udhcpc", "-i", iface, "-n", "-q", "-t", "10"); err != nil {
+			a.warnf("DHCP failed")
+		} else {
+			a.logf("DHCP successful")
+		}
+		a.publishGuestIP(iface)
+		if a.cfg.NetLimit != 0 {
+			a.limitBandwidth(iface)
+		}
+	}
+
+	policy := a.cfg.Network
+	if UsesDNSForwarder(policy) {
+		// Logging DNS forwarder for network-restricted sessions, resolving on
+		// the host when it enforces the allowlist for names
+		servers := a.upstreamDNS()
+		if a.cfg.DNSPort != 0 {
+			if err := a.startDNSRelay(); err != nil {
+				a.warnf("Host DNS resolver unavailable, name lookups will fail: %v", err)
+			}
+			servers = []string{DNSRelayServer}
+		}
+		conf := DNSMasqConfig(filepath.Join(guest.BootstrapDir, "dns.log"), servers)
+		if a.strictNetwork() {
+			// The sets must exist before dnsmasq caches any answer
+			if err := createIPSets(policy); err != nil {
+				return err
+			}
+			conf += DNSMasqIPSets(policy)
+		}
+		if err := os.WriteFile("/etc/dnsmasq.conf", []byte(conf), 0644); err != nil {
+			return fmt.Errorf("failed to write dnsmasq config: %w", err)
+		}
+		if err := run("dnsmasq"); err != nil {
+			return fmt.Errorf("dnsmasq: failed to start: %w", err)
+		}
+		a.mu.Lock()
+		a.dnsmasq = true
+		a.mu.Unlock()
+		if err := os.WriteFile("/etc/resolv.conf", []byte("nameserver 127.0.0.1\n"), 0644); err != nil {
+			return fmt.Errorf("failed to write resolv.conf: %w", err)
+		}
+	} else if data, _ := os.ReadFile("/etc/resolv.conf"); len(a.cfg.Resolvers) > 0 || !bytes.Contains(data, []byte("nameserver")) {
+		// Configured resolvers replace DHCP's; public DNS is only injected
+		// if DHCP didn't provide any
+		var sb strings.Builder
+		for _, server := range a.upstreamDNS() {
+			fmt.Fprintf(&sb, "nameserver %s\n", server)
+		}
+		_ = os.WriteFile("/etc/resolv.conf", []byte(sb.String()), 0644)
+	}
+
+	// Brief wait for network/DNS to stabilize after DHCP, then test with retries
+	time.Sleep(2 * time.Second)
+	a.logf("Testing connectivity...")
+	ok := false
+	for _, delay := range []time.Duration{0, time.Second, 2 * time.Second} {
+		time.Sleep(delay)
+		if checkURL("https://api.anthropic.com", a.cfg.Proxy) {
+			ok = true
+			break
+		}
+	}
+	if ok {
+		a.logf("Network OK")
+	} else {
+		a.warnf("Network check failed (may still work)")
+	}
+
+	if a.cfg.EgressPort != 0 {
+		if err := a.startEgressForwarder(); err != nil {
+			a.warnf("Egress proxy unavailable, network access will fail: %v", err)
+		}
+	}
+	if err := a.applyFirewall(); err != nil {
+		return err
+	}
+
+	if policy != nil && !policy.AllowAll && !policy.Blocked {
+		go a.watchAllowlist()
+	}
+	return nil
+}
+
+// caCertsDir is where update-ca-certificates picks up local CAs, which it
+// adds to caBundle, the system bundle most TLS clients read
+const (
+	caCertsDir = "/usr/local/share/ca-certificates"
+	caBundle   = "/etc/ssl/certs/ca-certificates.crt"
+)
+
+// installCACerts adds the configured CA certificates to the system trust
+// store. Failures only warn: TLS to hosts signed by them fails instead.
+func (a *Agent) installCACerts() {
+	a.logf("Installing %d CA certificate(s)", len(a.cfg.CACerts))
+	if err := os.MkdirAll(caCertsDir, 0755); err != nil {
+		a.warnf("CA certificates: %v", err)
+		return
+	}
+	for _, name := range a.cfg.CACerts {
+		data, err := os.ReadFile(filepath.Join(guest.BootstrapDir, name))
+		if err != nil {
+			a.warnf("CA certificates: %v", err)
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(caCertsDir, filepath.Base(name)), data, 0644); err != nil {
+			a.warnf("CA certificates: %v", err)
+		}
+	}
+	if err := run("update-ca-certificates"); err != nil {
+		a.warnf("CA certificates: %v", err)
+	}
+}
+
+// limitBandwidth caps the interface's bandwidth with tc. Failures only
+// warn: the limit protects the host's uplink, not the allowlist.
+func (a *Agent) limitBandwidth(iface string) {
+	a.logf("Limiting bandwidth to %d bit/s", a.cfg.NetLimit)
+	for _, args := range ShapingCommands(iface, a.cfg.NetLimit) {
+		if err := run("tc", args...); err != nil {
+			a.warnf("bandwidth limit: %v", err)
+			return
+		}
+	}
+}
+
+// allowPoll is how often the guest checks for domains added with faize allow
+const allowPoll = time.Second
+
+// watchAllowlist adds the domains appended to the bootstrap AllowFile to the
+// iptables allowlist and acknowledges them in AllowedFile. With the egress
+// proxy, the host applies and acknowledges additions; only IP ranges, which
+// bypass the proxy, are added here.
+func (a *Agent) watchAllowlist() {
+	egress := a.cfg.EgressPort != 0
+	applied := 0
+	ticker := time.NewTicker(allowPoll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+		}
+		specs, err := guest.ReadAllow(guest.BootstrapDir)
+		if err != nil || len(specs) <= applied {
+			continue
+		}
+
+		a.logf("Allowing %s", strings.Join(specs[applied:], ", "))
+		if a.strictNetwork() {
+			a.allowStrict(specs, applied)
+		} else {
+			for _, rule := range AllowRules(specs[applied:], a.resolveIPv4, egress) {
+				if err := run("iptables", rule.Args...); err != nil {
+					a.warnf("%v", err)
+				}
+			}
+		}
+		applied = len(specs)
+		if egress {
+			continue
+		}
+		if err := guest.WriteAllowed(guest.BootstrapDir, applied); err != nil {
+			a.warnf("failed to acknowledge allowlist additions: %v", err)
+		}
+	}
+}
+
+// strictNetwork reports whether the in-guest allowlist only accepts addresses
+// the DNS forwarder resolved for allowed names (network.strict)
+func (a *Agent) strictNetwork() bool {
+	policy := a.cfg.Network
+	return a.cfg.StrictNetwork && UsesDNSForwarder(policy) && !policy.Blocked &&
+		a.cfg.EgressPort == 0 && a.cfg.Proxy == ""
+}
+
+// createIPSets creates the strict mode sets for policy. Strict mode fails
+// closed, so a missing ipset tool or kernel support is an error.
+func createIPSets(policy *network.Policy) error {
+	for _, args := range IPSetCommands(policy) {
+		if err := run("ipset", args...); err != nil {
+			return fmt.Errorf("strict network mode needs ipset (rebuild the image with faize claude rebuild): %w", err)
+		}
+	}
+	return nil
+}
+
+// allowStrict adds specs[applied:] to a strict mode allowlist: new sets and
+// rules, then a dnsmasq restart so it fills the sets for the new names
+func (a *Agent) allowStrict(specs []string, applied int) {
+	policy := a.cfg.Network.Extend(specs)
+	if err := createIPSets(policy); err != nil {
+		a.warnf("%v", err)
+		return
+	}
+	for _, rule := range StrictAllowRules(specs[applied:]) {
+		if err := run("iptables", rule.Args...); err != nil {
+			a.warnf("%v", err)
+		}
+	}
+
+	conf := DNSMasqConfig(filepath.Join(guest.BootstrapDir, "dns.log"), a.upstreamDNS()) + DNSMasqIPSets(policy)
+	if err := os.WriteFile("/etc/dnsmasq.conf", []byte(conf), 0644); err != nil {
+		a.warnf("failed to write dnsmasq config: %v", err)
+		return
+	}
+	_ = run("killall", "dnsmasq")
+	// The old instance may still hold port 53 for a moment
+	var err error
+	for range 10 {
+		if err = run("dnsmasq"); err == nil {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	a.warnf("dnsmasq: failed to restart: %v", err)
+}
+
+// startEgressForwarder accepts proxy clients on EgressProxyAddr and relays
+// each connection to the host egress proxy over vsock
+func (a *Agent) startEgressForwarder() error {
+	listener, err := net.Listen("tcp", EgressProxyAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", EgressProxyAddr, err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go a.forwardHost(conn, a.cfg.EgressPort, "egress proxy")
+		}
+	}()
+	a.logf("Egress proxy listening on %s", EgressProxyAddr)
+	return nil
+}
+
+// dnsRelayTimeout bounds one UDP query relayed to the host resolver
+const dnsRelayTimeout = 5 * time.Second
+
+// startDNSRelay accepts dnsmasq's queries on DNSRelayAddr and relays them to
+// the host DNS resolver over vsock. TCP connections already carry DNS over
+// TCP framing and are relayed as they are.
+func (a *Agent) startDNSRelay() error {
+	pc, err := net.ListenPacket("udp", DNSRelayAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", DNSRelayAddr, err)
+	}
+	listener, err := net.Listen("tcp", DNSRelayAddr)
+	if err != nil {
+		_ = pc.Close()
+		return fmt.Errorf("failed to listen on %s: %w", DNSRelayAddr, err)
+	}
+	go func() {
+		buf := make([]byte, 65535)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			go a.relayDNS(pc, addr, append([]byte(nil), buf[:n]...))
+		}
+	}()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go a.forwardHost(conn, a.cfg.DNSPort, "DNS resolver")
+		}
+	}()
+	a.logf("DNS relay listening on %s", DNSRelayAddr)
+	return nil
+}
+
+// relayDNS sends one UDP query to the host resolver and returns the answer to addr
+func (a *Agent) relayDNS(pc net.PacketConn, addr net.Addr, query []byte) {
+	upstream, err := guest.DialVsock(unix.VMADDR_CID_HOST, a.cfg.DNSPort)
+	if err != nil {
+		a.logf("Warning: DNS resolver: %v", err)
+		return
+	}
+	defer func() { _ = upstream.Close() }()
+	_ = upstream.SetDeadline(time.Now().Add(dnsRelayTimeout))
+
+	if err := network.WriteDNSMessage(upstream, query); err != nil {
+		return
+	}
+	resp, err := network.ReadDNSMessage(upstream)
+	if err != nil {
+		return
+	}
+	_, _ = pc.WriteTo(resp, addr)
+}
+
+// forwardHost relays one local client to a host service listening on a vsock port
+func (a *Agent) forwardHost(conn net.Conn, port uint32, name string) {
+	defer func() { _ = conn.Close() }()
+	upstream, err := guest.DialVsock(unix.VMADDR_CID_HOST, port)
+	if err != nil {
+		a.logf("Warning: %s: %v", name, err)
+		return
+	}
+	defer func() { _ = upstream.Close() }()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		_, _ = io.Copy(upstream, conn)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(conn, upstream)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// applyFirewall installs the iptables rules for the network policy.
+// Required rules fail closed: setup aborts rather than leaving the network open.
+func (a *Agent) applyFirewall() error {
+	policy := a.cfg.Network
+	if policy == nil || policy.AllowAll {
+		return nil
+	}
+	if policy.Blocked {
+		a.warnf("Applying network policy: blocked")
+	} else if a.cfg.EgressPort != 0 {
+		a.logf("Applying network policy: domain allowlist via the host egress proxy")
+	} else if a.cfg.Proxy != "" {
+		a.warnf("Applying network policy: upstream proxy only (the allowlist is up to the proxy)")
+	} else if a.strictNetwork() {
+		a.logf("Applying network policy: domain allowlist, strict (resolved addresses only)")
+	} else {
+		a.logf("Applying network policy: domain allowlist")
+	}
+
+	var rules []Rule
+	if a.cfg.EgressPort != 0 && !policy.Blocked {
+		var dnsServers []string
+		if a.cfg.DNSPort == 0 {
+			dnsServers = a.upstreamDNS()
+		}
+		rules = EgressFirewallRules(policy, dnsServers)
+	} else if a.cfg.Proxy != "" && !policy.Blocked {
+		rules = ProxyFirewallRules(a.cfg.Proxy, a.resolveIPv4, a.upstreamDNS())
+	} else if a.strictNetwork() {
+		rules = StrictFirewallRules(policy, a.upstreamDNS())
+	} else {
+		rules = FirewallRules(policy, a.resolveIPv4, a.upstreamDNS())
+	}
+
+	for _, rule := range rules {
+		if err := run("iptables", rule.Args...); err != nil {
+			if !rule.Optional {
+				return fmt.Errorf("failed to apply network policy: %w", err)
+			}
+			a.logf("Warning: %v", err)
+		}
+	}
+
+	if policy.Blocked {
+		a.warnf("Network blocked (loopback only)")
+	} else {
+		a.logf("Network policy applied")
+	}
+	return nil
+}
+
+// upstreamDNS returns the resolvers the guest queries: the configured ones,
+// or UpstreamDNS
+func (a *Agent) upstreamDNS() []string {
+	if len(a.cfg.Resolvers) > 0 {
+		return a.cfg.Resolvers
+	}
+	return UpstreamDNS
+}
+
+// resolveIPv4 returns the IPv4 addresses of host
+func (a *Agent) resolveIPv4(host string) []string {
+	a.logf("Resolving %s...", host)
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return nil
+	}
+	var v4 []string
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			v4 = append(v4, ip.String())
+		}
+	}
+	return v4
+}
+
+// publishGuestIP writes the interface's IPv4 address to the bootstrap share so
+// the host can forward published ports to it
+func (a *Agent) publishGuestIP(iface string) {
+	ifi, err := net.InterfaceByName(iface)
+	if err != nil {
+		return
+	}
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+			_ = os.WriteFile(filepath.Join(guest.BootstrapDir, guest.GuestIPFile), []byte(ipNet.IP.String()), 0644)
+			a.logf("Guest IP: %s", ipNet.IP)
+			return
+		}
+	}
+}
+
+// startCapture records traffic on all interfaces (including DNS to the local
+// forwarder) into rotating pcap files in the bootstrap share. Packets dropped by
+// the firewall never reach an interface; see network.log for denials.
+func (a *Agent) startCapture() {
+	cmd := exec.Command("tcpdump", "-i", "any", "-n", "-U", "-Z", "root",
+		"-C", strconv.Itoa(captureFileSizeMB), "-W", strconv.Itoa(captureFileCount),
+		"-w", filepath.Join(guest.BootstrapDir, network.CaptureFile))
+	if err := cmd.Start(); err != nil {
+		a.warnf("Network capture unavailable: %v", err)
+		return
+	}
+	a.mu.Lock()
+	a.capture = cmd
+	a.mu.Unlock()
+	a.logf("Capturing network traffic")
+}
+
+// firstInterface returns the first non-loopback network interface
+func firstInterface() string {
+	entries, err := os.ReadDir("/sys/class/net")
+	if err != nil {
+		return ""
+	}
+	for _, e := range entries {
+		if e.Name() != "lo" {
+			return e.Name()
+		}
+	}
+	return ""
+}
+
+// checkURL reports whether target answers within a short timeout, through
+// proxy unless it is empty
+func checkURL(target, proxy string) bool {
+	client := &http.Client{Timeout: 3 * time.Second}
+	if proxyURL, err := url.Parse(proxy); err == nil && proxy != "" {
+		client.Transport = &http.Transport{Proxy: http.ProxyURL(proxyURL)}
+	}
+	resp, err := client.Head(target)
+	if err != nil {
+		return false
+	}
+	_ = resp.Body.Close()
+	return true
+}
+
+// collectNetworkLog appends firewall LOG messages from the kernel ring buffer
+// to network.log, each prefixed with the time it was collected (UTC) since
+// the kernel only records uptime
+func (a *Agent) collectNetworkLog() {
+	logPath := filepath.Join(guest.BootstrapDir, "network.log")
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	for {
+		out, err := exec.Command("dmesg", "-c").Output()
+		if err == nil {
+			var lines []string
+			stamp := time.Now().UTC().Format(time.RFC3339)
+			scanner := bufio.NewScanner(bytes.NewReader(out))
+			for scanner.Scan() {
+				if strings.Contains(scanner.Text(), "FAIZE_") {
+					lines = append(lines, stamp+" "+scanner.Text())
+				}
+			}
+			if len(lines) > 0 {
+				if f, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err == nil {
+					_, _ = f.WriteString(strings.Join(lines, "\n") + "\n")
+					_ = f.Close()
+				}
+			}
+		}
+
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// prepareClaudeHome sets up ownership, git, and the Claude configuration from
+// the host. It is safe to run again (see Reinit): one-time steps are skipped
+// once they complete, and the rest leave an already prepared home unchanged.
+func (a *Agent) prepareClaudeHome() {
+	if _, err := a.state.once(stepOwnership, a.fixOwnership); err != nil {
+		a.logf("Warning: %v", err)
+	}
+	a.addSafeDirectory()
+	if _, err := a.state.once(stepClaudeFiles, a.copyClaudeFiles); err != nil {
+		a.logf("Warning: %v", err)
+	}
+	if a.cfg.PersistCredentials {
+		_, _ = a.state.once(stepCredentials, func() error {
+			a.restoreCredentials()
+			return nil
+		})
+	}
+	a.rewritePlugins()
+}
+
+// runPostCreate runs the project's devcontainer postCreateCommand as the
+// claude user in the project, showing its output. A failing command is
+// reported and the session starts anyway.
+func (a *Agent) runPostCreate() {
+	for _, command := range a.cfg.PostCreate {
+		fmt.Printf("Running postCreateCommand: %s\n", command)
+		cmd := exec.Command("/bin/sh", "-c", command)
+		cmd.Dir = a.cfg.WorkDir()
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+		cmd.Env = []string{execPath}
+		if err := a.prepareExec(cmd, &guest.ExecRequest{User: "claude"}); err != nil {
+			a.warnf("postCreateCommand: %v", err)
+			return
+		}
+		if err := cmd.Run(); err != nil {
+			a.warnf("postCreateCommand %q failed: %v", command, err)
+		}
+	}
+}
+
+// fixOwnership hands the writable directories to the claude user
+func (a *Agent) fixOwnership() error {
+	dirs := []string{claudeHome, "/opt/toolchain", a.cfg.ProjectDir}
+	if a.cfg.ScratchDisk {
+		dirs = append(dirs, guest.ScratchDir)
+	}
+	for _, dir := range dirs {
+		if dir != "" {
+			if err := run("chown", "-R", "claude:claude", dir); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// addSafeDirectory marks the project safe for git, since VirtioFS mounts have
+// different ownership. The entry is only added if it is missing.
+func (a *Agent) addSafeDirectory() {
+	workDir := a.cfg.WorkDir()
+	out, _ := exec.Command("git", "config", "--system", "--get-all", "safe.directory").Output()
+	if outputHasLine(string(out), workDir) {
+		return
+	}
+	if err := run("git", "config", "--system", "--add", "safe.directory", workDir); err != nil {
+		a.logf("Warning: %v", err)
+	}
+}
+
+// copyClaudeFiles links and copies the host's Claude configuration into the
+// guest home. Copies overwrite guest changes, so this only runs once per boot.
+func (a *Agent) copyClaudeFiles() error {
+	_ = os.MkdirAll(claudeConfigDir, 0755)
+	_ = run("chown", "claude:claude", claudeConfigDir)
+
+	// Symlink read-only configuration files
+	for _, name := range claudeReadOnlyFiles {
+		src := filepath.Join(hostClaudeDir, name)
+		if _, err := os.Lstat(src); err == nil {
+			dst := filepath.Join(claudeConfigDir, name)
+			_ = os.Remove(dst)
+			_ = os.Symlink(src, dst)
+		}
+	}
+
+	// Copy settings.json (Claude may need to modify it) - only if not already present
+	settings := filepath.Join(claudeConfigDir, "settings.json")
+	if !fileExists(settings) && a.copyFile(filepath.Join(hostClaudeDir, "settings.json"), settings) {
+		_ = run("chown", "claude:claude", settings)
+	}
+
+	// Create writable directories with host content
+	for _, dir := range claudeWritableDirs {
+		dst := filepath.Join(claudeConfigDir, dir)
+		_ = os.MkdirAll(dst, 0755)
+		if src := filepath.Join(hostClaudeDir, dir); fileExists(src) {
+			_ = run("cp", "-r", src+"/.", dst+"/")
+		}
+		_ = run("chown", "-R", "claude:claude", dst)
+	}
+	return nil
+}
+
+// rewritePlugins points host paths in plugin configs at the guest. The
+// rewrite is idempotent, so it runs again to fix plugins installed since.
+func (a *Agent) rewritePlugins() {
+	plugins, _ := filepath.Glob(filepath.Join(claudeConfigDir, "plugins", "*.json"))
+	for _, path := range plugins {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		rewritten := RewritePluginPaths(data)
+		if filepath.Base(path) == "installed_plugins.json" {
+			rewritten = RewriteProjectPath(rewritten, a.cfg.WorkDir())
+		}
+		if !bytes.Equal(rewritten, data) {
+			_ = os.WriteFile(path, rewritten, 0644)
+		}
+	}
+}
+
+// copyFile copies a regular file if the source exists and is non-empty
+func (a *Agent) copyFile(src, dst string) bool {
+	data, err := os.ReadFile(src)
+	if err != nil || len(data) == 0 {
+		return false
+	}
+	if err := os.WriteFile(dst, data, 0600); err != nil {
+		a.warnf("failed to copy %s: %v", src, err)
+		return false
+	}
+	return true
+}
+
+// restoreCredentials copies persisted credentials from the host share
+func (a *Agent) restoreCredentials() {
+	if a.copyFile(filepath.Join(hostCredsDir, credentialsFile), filepath.Join(claudeConfigDir, credentialsFile)) {
+		_ = run("chown", "claude:claude", filepath.Join(claudeConfigDir, credentialsFile))
+		a.logf("Restored .credentials.json from host")
+	}
+	if a.copyFile(filepath.Join(hostCredsDir, claudeJSONStored), claudeJSON) {
+		_ = run("chown", "claude:claude", claudeJSON)
+		a.logf("Restored .claude.json from host")
+	}
+}
+
+// persistCredentials copies credentials back to the host share
+func (a *Agent) persistCredentials() {
+	if !fileExists(hostCredsDir) {
+		return
+	}
+	a.copyFile(filepath.Join(claudeConfigDir, credentialsFile), filepath.Join(hostCredsDir, credentialsFile))
+	a.copyFile(claudeJSON, filepath.Join(hostCredsDir, claudeJSONStored))
+}
+
+// saveSettings copies the session's settings.json to the bootstrap dir for
+// the host to sync back
+func (a *Agent) saveSettings() {
+	a.copyFile(filepath.Join(claudeConfigDir, "settings.json"), filepath.Join(guest.BootstrapDir, guest.SettingsFile))
+}
+
+// saveClaudeDirs copies the skills and plugin files added or changed since
+// they were copied in at boot to the bootstrap dir, for the host to sync back
+func (a *Agent) saveClaudeDirs() {
+	copied, err := os.Stat(filepath.Join(a.state.dir, stepClaudeFiles))
+	if err != nil {
+		return
+	}
+	for _, dir := range claudeWritableDirs {
+		for _, path := range ListChangedFiles(filepath.Join(claudeConfigDir, dir), copied.ModTime(), nil) {
+			info, err := os.Lstat(path)
+			if err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			rel, err := filepath.Rel(claudeConfigDir, path)
+			if err != nil {
+				continue
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			dst := filepath.Join(guest.BootstrapDir, guest.ClaudeSyncDir, rel)
+			if err := os.MkdirAll(filepath.Dir(dst), 0755); err == nil {
+				err = os.WriteFile(dst, data, info.Mode().Perm())
+			}
+			if err != nil {
+				a.warnf("failed to save %s: %v", rel, err)
+			}
+		}
+	}
+}
+
+// startSSH runs sshd with the keys the host generated for the session, so
+// faize ssh can open shells alongside the console
+func (a *Agent) startSSH() {
+	if !fileExists(sshdPath) {
+		a.warnf("sshd not found - rebuild the rootfs with 'faize claude rebuild' to use faize ssh")
+		return
+	}
+	keys := filepath.Join(guest.BootstrapDir, guest.SSHDir)
+	// Privilege separation: Alpine's sshd uses /var/empty, Debian's /run/sshd
+	for _, dir := range []string{sshAuthorizedDir, "/var/empty", "/run", "/run/sshd"} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			a.warnf("failed to set up sshd: %v", err)
+			return
+		}
+	}
+	if !a.copyFile(filepath.Join(keys, guest.SSHHostKeyFile), sshHostKeyPath) ||
+		!a.copyFile(filepath.Join(keys, guest.SSHAuthorizedKeysFile), filepath.Join(sshAuthorizedDir, "claude")) {
+		a.warnf("SSH keys missing from the bootstrap share; faize ssh is unavailable")
+		return
+	}
+	_ = os.Chmod(filepath.Join(sshAuthorizedDir, "claude"), 0644)
+
+	if err := os.WriteFile(sshdConfigPath, []byte(SSHDConfig()), 0644); err != nil {
+		a.warnf("failed to write sshd config: %v", err)
+		return
+	}
+	if err := os.WriteFile(sshLoginProfile, []byte(SSHLoginProfile(a.cfg.WorkDir(), a.sessionEnv())), 0644); err != nil {
+		a.logf("Warning: %v", err)
+	}
+	if err := run(sshdPath, "-f", sshdConfigPath); err != nil {
+		a.warnf("failed to start sshd: %v", err)
+		return
+	}
+	a.logf("sshd started")
+}
+
+// installShims links the clipboard and browser shims to the agent binary,
+// leaving links that are already in place
+func (a *Agent) installShims() {
+	for _, name := range Shims {
+		link := filepath.Join("/usr/local/bin", name)
+		if target, err := os.Readlink(link); err == nil && target == guest.AgentPath {
+			continue
+		}
+		_ = os.Remove(link)
+		if err := os.Symlink(guest.AgentPath, link); err != nil {
+			a.warnf("failed to install %s shim: %v", name, err)
+		}
+	}
+}
+
+// relayOAuthCallbacks replays OAuth redirects the host relay captured to the
+// guest-local callback server
+func (a *Agent) relayOAuthCallbacks() {
+	callbackFile := filepath.Join(guest.BootstrapDir, "auth-callback")
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+		}
+		data, err := os.ReadFile(callbackFile)
+		if err != nil {
+			continue
+		}
+		_ = os.Remove(callbackFile)
+		url := strings.TrimSpace(string(data))
+		if !oauthCallbackURL.MatchString(url) {
+			continue
+		}
+		if resp, err := http.Get(url); err == nil {
+			_ = resp.Body.Close()
+		}
+	}
+}
+
+// sessionExitTimeout is how long shutdown waits for the session process to
+// exit after SIGTERM
+const sessionExitTimeout = 5 * time.Second
+
+// shutdown runs the cleanup steps once and powers off
+func (a *Agent) shutdown() {
+	a.cleaning.Do(func() {
+		fmt.Println("Shutting down...")
+		close(a.stop)
+
+		a.mu.Lock()
+		session, exited, dnsmasq, capture, packages := a.session, a.exited, a.dnsmasq, a.capture, a.packages
+		a.mu.Unlock()
+
+		// Claude writes its credentials and settings as it exits, so they
+		// are saved below only once it has
+		if session != nil && exited != nil {
+			_ = session.Process.Signal(syscall.SIGTERM)
+			select {
+			case <-exited:
+			case <-time.After(sessionExitTimeout):
+				a.warnf("session did not exit within %s of SIGTERM; saving its state anyway", sessionExitTimeout)
+			}
+		}
+		if dnsmasq {
+			_ = run("killall", "dnsmasq")
+		}
+		if capture != nil {
+			// SIGTERM lets tcpdump flush the current pcap file
+			_ = capture.Process.Signal(syscall.SIGTERM)
+			_ = capture.Wait()
+		}
+
+		if a.cfg.ClaudeMode && a.cfg.PersistCredentials {
+			a.persistCredentials()
+		}
+		if a.cfg.ClaudeMode && a.cfg.SyncSettings {
+			a.saveSettings()
+		}
+		if a.cfg.ClaudeMode && a.cfg.SyncSkills {
+			a.saveClaudeDirs()
+		}
+
+		// Record files modified during the session (rootfs overlay changes)
+		if a.cfg.ClaudeMode {
+			since := time.Now()
+			if info, err := os.Stat(a.configPath); err == nil {
+				since = info.ModTime()
+			}
+			changed := ListChangedFiles("/", since, changeScanExclude)
+			content := strings.Join(changed, "\n")
+			if content != "" {
+				content += "\n"
+			}
+			_ = os.WriteFile(filepath.Join(guest.BootstrapDir, "guest-changes.txt"), []byte(content), 0644)
+		}
+		if packages != nil {
+			a.recordPackages(*packages)
+		}
+
+		Poweroff()
+	})
+}
+
+// recordPackages writes the packages installed since before to the
+// bootstrap dir for the host's changeset
+func (a *Agent) recordPackages(before packageState) {
+	after, ok := readInstalledPackages()
+	if !ok {
+		return
+	}
+	content := strings.Join(installedSince(before, after), "\n")
+	if content != "" {
+		content += "\n"
+	}
+	if err := os.WriteFile(filepath.Join(guest.BootstrapDir, guest.PackagesFile), []byte(content), 0644); err != nil {
+		a.warnf("failed to record installed packages: %v", err)
+	}
+}
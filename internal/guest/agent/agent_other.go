@@ -0,0 +1,23 @@
+//go:build !linux
+
+package agent
+
+import (
+	"fmt"
+	"os"
+)
+
+// Run is only supported inside the Linux guest
+func Run(configPath string) error {
+	return fmt.Errorf("the faize guest agent only runs on Linux")
+}
+
+// Reinit is only supported inside the Linux guest
+func Reinit(configPath string) error {
+	return fmt.Errorf("the faize guest agent only runs on Linux")
+}
+
+// Poweroff exits; there is no VM to power off outside the guest
+func Poweroff() {
+	os.Exit(1)
+}
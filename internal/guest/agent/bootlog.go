@@ -0,0 +1,38 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/faize-ai/faize/internal/guest"
+)
+
+// appendBootLog appends a timestamped status message to the boot log at path
+func appendBootLog(path string, now time.Time, warning bool, msg string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	prefix := ""
+	if warning {
+		prefix = guest.BootWarningPrefix
+	}
+	_, err = fmt.Fprintf(f, "%s %s%s\n", now.Format(time.TimeOnly), prefix, strings.TrimSpace(msg))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// writeBootStage records the current boot stage in dir for the host. The
+// file is replaced atomically so the host never reads a partial stage.
+func writeBootStage(dir, stage string) error {
+	tmp := filepath.Join(dir, guest.BootStageFile+".tmp")
+	if err := os.WriteFile(tmp, []byte(stage), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, guest.BootStageFile))
+}
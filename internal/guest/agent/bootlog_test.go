@@ -0,0 +1,50 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/faize-ai/faize/internal/guest"
+)
+
+func TestAppendBootLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), guest.BootLogFile)
+	now := time.Date(2026, 10, 16, 9, 30, 5, 0, time.UTC)
+
+	if err := appendBootLog(path, now, false, "Network OK"); err != nil {
+		t.Fatal(err)
+	}
+	if err := appendBootLog(path, now, true, "\nDHCP failed"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "09:30:05 Network OK\n09:30:05 warning: DHCP failed\n"
+	if string(data) != want {
+		t.Errorf("boot log = %q, want %q", data, want)
+	}
+}
+
+func TestWriteBootStage(t *testing.T) {
+	dir := t.TempDir()
+	for _, stage := range []string{guest.BootMounting, guest.BootReady} {
+		if err := writeBootStage(dir, stage); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(dir, guest.BootStageFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != guest.BootReady {
+		t.Errorf("stage = %q, want %q", data, guest.BootReady)
+	}
+	if _, err := os.Stat(filepath.Join(dir, guest.BootStageFile+".tmp")); !os.IsNotExist(err) {
+		t.Error("temporary stage file left behind")
+	}
+}
@@ -0,0 +1,106 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/faize-ai/faize/internal/guest"
+)
+
+// Guest paths used for Claude Code configuration
+const (
+	claudeHome       = "/home/claude"
+	claudeConfigDir  = guest.ClaudeConfigDir
+	hostClaudeDir    = "/mnt/host-claude"
+	hostCredsDir     = "/mnt/host-credentials"
+	hostHomeDir      = "/mnt/host-home"    // the project's persistent home (faize start --persist-home)
+	credentialsFile  = ".credentials.json" // in claudeConfigDir and hostCredsDir
+	claudeJSON       = "/home/claude/.claude.json"
+	claudeJSONStored = "claude.json" // name of claudeJSON in hostCredsDir
+)
+
+// Host config files symlinked read-only into the guest
+var claudeReadOnlyFiles = []string{"CLAUDE.md", "keybindings.json"}
+
+// Host config directories copied into writable guest directories
+var claudeWritableDirs = []string{"skills", "plugins"}
+
+var (
+	// Plugins store absolute host paths like /Users/<user>/.claude/... which don't exist in the VM
+	macHomeClaudePath   = regexp.MustCompile(`/Users/[^/"]*/\.claude/`)
+	linuxHomeClaudePath = regexp.MustCompile(`/home/[^/"]*/\.claude/`)
+	projectPathField    = regexp.MustCompile(`"projectPath": "[^"]*"`)
+)
+
+// RewritePluginPaths replaces host home paths in plugin config files with guest paths
+func RewritePluginPaths(data []byte) []byte {
+	data = macHomeClaudePath.ReplaceAll(data, []byte(claudeConfigDir+"/"))
+	return linuxHomeClaudePath.ReplaceAll(data, []byte(claudeConfigDir+"/"))
+}
+
+// RewriteProjectPath points every plugin projectPath at the guest workspace
+func RewriteProjectPath(data []byte, workspace string) []byte {
+	workspace = strings.NewReplacer(`"`, "", `\`, "").Replace(workspace)
+	return projectPathField.ReplaceAllLiteral(data, []byte(`"projectPath": "`+workspace+`"`))
+}
+
+// claudeShell is the shell command that runs Claude Code as the non-root user
+const claudeShell = "export HOME=/home/claude && export PATH=/usr/local/bin:/usr/bin:/bin && export GIT_DISCOVERY_ACROSS_FILESYSTEM=1 && cd ${PWD} && exec claude"
+
+// promptCommand returns the shell command that runs claude -p with extra
+// arguments, each quoted for the shell
+func promptCommand(args []string) string {
+	cmd := claudeShell + " -p"
+	for _, arg := range args {
+		cmd += " '" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+	}
+	return cmd
+}
+
+// changeScanExclude are guest paths never reported as session changes
+var changeScanExclude = []string{"/proc", "/sys", "/dev", "/mnt", "/tmp", "/run"}
+
+// ListChangedFiles walks root and returns paths modified after since,
+// skipping the excluded top-level trees. Unreadable entries are ignored.
+func ListChangedFiles(root string, since time.Time, exclude []string) []string {
+	skip := make(map[string]bool, len(exclude))
+	for _, e := range exclude {
+		skip[filepath.Join(root, e)] = true
+	}
+
+	var changed []string
+	_ = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() && skip[path] {
+			return filepath.SkipDir
+		}
+		if info.ModTime().After(since) {
+			changed = append(changed, path)
+		}
+		return nil
+	})
+	return changed
+}
+
+// ParseTermSize parses the host's "cols rows" terminal size file content
+func ParseTermSize(s string) (cols, rows int, ok bool) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return 0, 0, false
+	}
+	cols, err := strconv.Atoi(fields[0])
+	if err != nil || cols <= 0 {
+		return 0, 0, false
+	}
+	rows, err = strconv.Atoi(fields[1])
+	if err != nil || rows <= 0 {
+		return 0, 0, false
+	}
+	return cols, rows, true
+}
@@ -0,0 +1,100 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRewritePluginPaths(t *testing.T) {
+	in := `{"path": "/Users/alice/.claude/plugins/x", "other": "/home/bob/.claude/skills/y"}`
+	want := `{"path": "/home/claude/.claude/plugins/x", "other": "/home/claude/.claude/skills/y"}`
+	if got := string(RewritePluginPaths([]byte(in))); got != want {
+		t.Errorf("RewritePluginPaths = %s, want %s", got, want)
+	}
+}
+
+func TestRewriteProjectPath(t *testing.T) {
+	in := `{"a": {"projectPath": "/Users/alice/old"}, "b": {"projectPath": "/tmp/x"}}`
+	want := `{"a": {"projectPath": "/Users/alice/proj"}, "b": {"projectPath": "/Users/alice/proj"}}`
+	if got := string(RewriteProjectPath([]byte(in), "/Users/alice/proj")); got != want {
+		t.Errorf("RewriteProjectPath = %s, want %s", got, want)
+	}
+}
+
+func TestRewriteProjectPathStripsQuotes(t *testing.T) {
+	got := string(RewriteProjectPath([]byte(`{"projectPath": "x"}`), `/weird"path`))
+	if got != `{"projectPath": "/weirdpath"}` {
+		t.Errorf("RewriteProjectPath = %s", got)
+	}
+}
+
+func TestParseTermSize(t *testing.T) {
+	tests := []struct {
+		in         string
+		cols, rows int
+		ok         bool
+	}{
+		{"120 40", 120, 40, true},
+		{"80 24\n", 80, 24, true},
+		{"", 0, 0, false},
+		{"80", 0, 0, false},
+		{"0 24", 0, 0, false},
+		{"abc 24", 0, 0, false},
+	}
+	for _, tt := range tests {
+		cols, rows, ok := ParseTermSize(tt.in)
+		if cols != tt.cols || rows != tt.rows || ok != tt.ok {
+			t.Errorf("ParseTermSize(%q) = %d, %d, %v", tt.in, cols, rows, ok)
+		}
+	}
+}
+
+func TestListChangedFiles(t *testing.T) {
+	root := t.TempDir()
+	since := time.Now().Add(-time.Hour)
+
+	old := filepath.Join(root, "etc", "old")
+	changed := filepath.Join(root, "etc", "changed")
+	excluded := filepath.Join(root, "proc", "changed")
+	for _, p := range []string{old, changed, excluded} {
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	past := since.Add(-time.Hour)
+	if err := os.Chtimes(old, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	got := ListChangedFiles(root, since, []string{"/proc"})
+
+	seen := map[string]bool{}
+	for _, p := range got {
+		seen[p] = true
+	}
+	if !seen[changed] {
+		t.Errorf("Expected %s in %v", changed, got)
+	}
+	if seen[old] {
+		t.Errorf("Unmodified file reported: %s", old)
+	}
+	if seen[excluded] || seen[filepath.Join(root, "proc")] {
+		t.Errorf("Excluded tree reported: %v", got)
+	}
+}
+
+func TestPromptCommand(t *testing.T) {
+	got := promptCommand([]string{"--output-format", "json", "it's"})
+	want := claudeShell + ` -p '--output-format' 'json' 'it'\''s'`
+	if got != want {
+		t.Errorf("promptCommand() = %q, want %q", got, want)
+	}
+	if got := promptCommand(nil); got != claudeShell+" -p" {
+		t.Errorf("promptCommand(nil) = %q", got)
+	}
+}
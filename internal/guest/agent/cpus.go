@@ -0,0 +1,44 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cpuPoll is how often the guest checks how many vCPUs the host wants online
+const cpuPoll = 2 * time.Second
+
+// cpuSysDir holds the guest kernel's per-CPU controls
+const cpuSysDir = "/sys/devices/system/cpu"
+
+// setOnlineCPUs brings the first n CPUs under sysDir online and takes the
+// rest offline. cpu0 has no online control and always stays online.
+func setOnlineCPUs(sysDir string, n int) error {
+	paths, err := filepath.Glob(filepath.Join(sysDir, "cpu[0-9]*", "online"))
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, path := range paths {
+		i, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(filepath.Dir(path)), "cpu"))
+		if err != nil || i == 0 {
+			continue
+		}
+		want := "0"
+		if i < n {
+			want = "1"
+		}
+		if data, err := os.ReadFile(path); err == nil && strings.TrimSpace(string(data)) == want {
+			continue
+		}
+		if err := os.WriteFile(path, []byte(want), 0644); err != nil {
+			errs = append(errs, fmt.Errorf("failed to set cpu%d online to %s: %w", i, want, err))
+		}
+	}
+	return errors.Join(errs...)
+}
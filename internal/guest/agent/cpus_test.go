@@ -0,0 +1,46 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetOnlineCPUs(t *testing.T) {
+	dir := t.TempDir()
+	for _, cpu := range []string{"cpu0", "cpu1", "cpu2", "cpu3", "cpufreq"} {
+		if err := os.MkdirAll(filepath.Join(dir, cpu), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, cpu := range []string{"cpu1", "cpu2", "cpu3"} {
+		if err := os.WriteFile(filepath.Join(dir, cpu, "online"), []byte("1\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	online := func() string {
+		var states []string
+		for _, cpu := range []string{"cpu1", "cpu2", "cpu3"} {
+			data, _ := os.ReadFile(filepath.Join(dir, cpu, "online"))
+			states = append(states, strings.TrimSpace(string(data)))
+		}
+		return strings.Join(states, ",")
+	}
+
+	if err := setOnlineCPUs(dir, 2); err != nil {
+		t.Fatalf("setOnlineCPUs: %v", err)
+	}
+	if got := online(); got != "1,0,0" {
+		t.Errorf("online after 2 CPUs = %s, want 1,0,0", got)
+	}
+	if err := setOnlineCPUs(dir, 8); err != nil {
+		t.Fatalf("setOnlineCPUs: %v", err)
+	}
+	if got := online(); got != "1,1,1" {
+		t.Errorf("online after 8 CPUs = %s, want 1,1,1", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cpu0", "online")); !os.IsNotExist(err) {
+		t.Errorf("cpu0 was given an online control")
+	}
+}
@@ -0,0 +1,96 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/faize-ai/faize/internal/guest"
+)
+
+// execPath is the PATH for commands run through faize exec
+const execPath = "PATH=/usr/local/bin:/usr/bin:/bin"
+
+// execWaitDelay is how long a killed command's output is still read, in case
+// something it started keeps its stdout open
+const execWaitDelay = time.Second
+
+// frameWriter forwards command output to the host as frames of one kind.
+// Stdout and stderr share a mutex so their frames don't interleave.
+type frameWriter struct {
+	mu   *sync.Mutex
+	w    io.Writer
+	kind byte
+}
+
+func (f *frameWriter) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := guest.WriteFrame(f.w, f.kind, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// handleExec runs the single exec request read from conn and streams its output
+// and exit code back. prepare applies platform specifics, such as switching to
+// the session user, before the command starts. The command is killed if the
+// host closes conn before it exits.
+func handleExec(conn io.ReadWriteCloser, workDir string, prepare func(*exec.Cmd, *guest.ExecRequest) error) {
+	defer func() { _ = conn.Close() }()
+
+	req, err := guest.ReadExecRequest(conn)
+	if err != nil {
+		_ = guest.WriteFrame(conn, guest.FrameError, []byte(err.Error()))
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cmd := exec.CommandContext(ctx, req.Args[0], req.Args[1:]...)
+	cmd.WaitDelay = execWaitDelay
+	cmd.Dir = req.Dir
+	if cmd.Dir == "" {
+		cmd.Dir = workDir
+	}
+	cmd.Env = []string{execPath}
+	if prepare != nil {
+		if err := prepare(cmd, req); err != nil {
+			_ = guest.WriteFrame(conn, guest.FrameError, []byte(err.Error()))
+			return
+		}
+	}
+	cmd.Env = append(cmd.Env, req.Env...)
+
+	var mu sync.Mutex
+	cmd.Stdout = &frameWriter{mu: &mu, w: conn, kind: guest.FrameStdout}
+	cmd.Stderr = &frameWriter{mu: &mu, w: conn, kind: guest.FrameStderr}
+
+	// The host sends nothing after the request, so reading ends when it hangs up
+	go func() {
+		_, _ = io.Copy(io.Discard, conn)
+		cancel()
+	}()
+
+	code := 0
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			_ = guest.WriteFrame(conn, guest.FrameError, []byte(fmt.Sprintf("failed to run %s: %v", req.Args[0], err)))
+			return
+		}
+		code = exitErr.ExitCode()
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			code = 128 + int(status.Signal()) // shell convention for signal deaths
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	_ = guest.WriteExitFrame(conn, code)
+}
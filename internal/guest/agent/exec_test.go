@@ -0,0 +1,96 @@
+package agent
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/faize-ai/faize/internal/guest"
+)
+
+// runHandleExec runs req through handleExec and returns the client's view
+func runHandleExec(t *testing.T, req *guest.ExecRequest, prepare func(*exec.Cmd, *guest.ExecRequest) error) (int, string, string, error) {
+	t.Helper()
+	client, server := net.Pipe()
+	defer func() { _ = client.Close() }()
+	go handleExec(server, t.TempDir(), prepare)
+
+	var stdout, stderr bytes.Buffer
+	code, err := guest.RunExec(client, req, &stdout, &stderr)
+	return code, stdout.String(), stderr.String(), err
+}
+
+func TestHandleExec(t *testing.T) {
+	code, stdout, stderr, err := runHandleExec(t, &guest.ExecRequest{
+		Args: []string{"sh", "-c", "echo out; echo err >&2; exit 3"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != 3 || stdout != "out\n" || stderr != "err\n" {
+		t.Errorf("got code %d, stdout %q, stderr %q", code, stdout, stderr)
+	}
+}
+
+func TestHandleExecDirAndEnv(t *testing.T) {
+	dir := t.TempDir()
+	_, stdout, _, err := runHandleExec(t, &guest.ExecRequest{
+		Args: []string{"sh", "-c", "pwd; echo $FOO"},
+		Dir:  dir,
+		Env:  []string{"FOO=bar"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stdout != dir+"\nbar\n" {
+		t.Errorf("stdout = %q", stdout)
+	}
+}
+
+func TestHandleExecSignalExitCode(t *testing.T) {
+	code, _, _, err := runHandleExec(t, &guest.ExecRequest{Args: []string{"sh", "-c", "kill -9 $$"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != 137 {
+		t.Errorf("code = %d, want 137", code)
+	}
+}
+
+func TestHandleExecMissingCommand(t *testing.T) {
+	_, _, _, err := runHandleExec(t, &guest.ExecRequest{Args: []string{"faize-no-such-command"}}, nil)
+	if err == nil || !strings.Contains(err.Error(), "failed to run") {
+		t.Errorf("Expected start error, got %v", err)
+	}
+}
+
+func TestHandleExecPrepareError(t *testing.T) {
+	_, _, _, err := runHandleExec(t, &guest.ExecRequest{Args: []string{"true"}, User: "nobody"},
+		func(*exec.Cmd, *guest.ExecRequest) error { return errors.New(`unknown user "nobody"`) })
+	if err == nil || err.Error() != `unknown user "nobody"` {
+		t.Errorf("Expected prepare error, got %v", err)
+	}
+}
+
+func TestHandleExecKilledOnHangup(t *testing.T) {
+	client, server := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		handleExec(server, t.TempDir(), nil)
+		close(done)
+	}()
+
+	if err := guest.WriteFrame(client, guest.FrameRequest, []byte(`{"args":["sleep","60"]}`)); err != nil {
+		t.Fatal(err)
+	}
+	_ = client.Close()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("command still running after the host hung up")
+	}
+}
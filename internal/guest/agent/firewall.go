@@ -0,0 +1,421 @@
+package agent
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/faize-ai/faize/internal/network"
+)
+
+// UpstreamDNS are the resolvers the guest queries unless the config names
+// others (network.resolvers)
+var UpstreamDNS = []string{"8.8.8.8", "1.1.1.1"}
+
+// EgressProxyAddr is the guest's local HTTP proxy, forwarded to the host
+// egress proxy over vsock
+const EgressProxyAddr = "127.0.0.1:3128"
+
+// DNSRelayAddr is where the agent relays dnsmasq's queries to the host DNS
+// resolver over vsock; DNSRelayServer is the same address in dnsmasq syntax
+const (
+	DNSRelayAddr   = "127.0.0.1:5353"
+	DNSRelayServer = "127.0.0.1#5353"
+)
+
+// localNoProxy are the NO_PROXY entries every proxy environment includes
+const localNoProxy = "localhost,127.0.0.1,::1"
+
+// ProxyEnv returns the environment that points HTTP clients at the local
+// egress proxy. Most tools read one spelling or the other, so both are set.
+func ProxyEnv() []string {
+	return proxyEnv("http://"+EgressProxyAddr, localNoProxy)
+}
+
+// UpstreamProxyEnv returns the environment that points HTTP clients at an
+// upstream proxy, bypassing it for loopback and the noProxy entries
+func UpstreamProxyEnv(proxyURL string, noProxy []string) []string {
+	return proxyEnv(proxyURL, strings.Join(append([]string{localNoProxy}, noProxy...), ","))
+}
+
+// proxyEnv returns both spellings of the proxy variables
+func proxyEnv(proxyURL, noProxy string) []string {
+	return []string{
+		"HTTP_PROXY=" + proxyURL, "HTTPS_PROXY=" + proxyURL,
+		"http_proxy=" + proxyURL, "https_proxy=" + proxyURL,
+		"NO_PROXY=" + noProxy, "no_proxy=" + noProxy,
+	}
+}
+
+// Network capture bounds: tcpdump rotates through captureFileCount files of
+// captureFileSizeMB megabytes each
+const (
+	captureFileSizeMB = 10
+	captureFileCount  = 5
+)
+
+// Kernel log prefixes for connection events, collected into network.log
+const (
+	LogPrefixNet  = "FAIZE_NET: "
+	LogPrefixDeny = "FAIZE_DENY: "
+	LogPrefixDoH  = "FAIZE_DOH: "
+)
+
+// Rule is a single iptables invocation (arguments only, without "iptables").
+// Optional rules may fail without aborting setup, e.g. when a kernel module is missing.
+type Rule struct {
+	Args     []string
+	Optional bool
+}
+
+// Resolver returns the IPv4 addresses for a host name
+type Resolver func(host string) []string
+
+// UsesDNSForwarder reports whether the policy is restricted, which requires the
+// local logging DNS forwarder (started before FirewallRules are applied) and the
+// kernel log collector for network.log.
+func UsesDNSForwarder(policy *network.Policy) bool {
+	return policy != nil && !policy.AllowAll
+}
+
+// DNSMasqConfig returns the dnsmasq configuration for the logging DNS
+// forwarder, which queries servers (UpstreamDNS, or DNSRelayServer when the
+// host resolves names)
+func DNSMasqConfig(logPath string, servers []string) string {
+	var sb strings.Builder
+	sb.WriteString("listen-address=127.0.0.1\n")
+	sb.WriteString("port=53\n")
+	sb.WriteString("no-resolv\n")
+	for _, server := range servers {
+		fmt.Fprintf(&sb, "server=%s\n", server)
+	}
+	sb.WriteString("log-queries\n")
+	fmt.Fprintf(&sb, "log-facility=%s\n", logPath)
+	sb.WriteString("cache-size=200\n")
+	sb.WriteString("pid-file=\n")
+	return sb.String()
+}
+
+// logRule returns a rate-limited LOG rule; prefix marks the event type
+func logRule(prefix string, limit string, match ...string) Rule {
+	args := append([]string{"-A", "OUTPUT"}, match...)
+	args = append(args, "-j", "LOG", "--log-prefix", prefix, "--log-level", "4", "-m", "limit", "--limit", limit)
+	return Rule{Args: args, Optional: true}
+}
+
+// FirewallRules returns the iptables OUTPUT rules enforcing the network policy,
+// letting the DNS forwarder reach dnsServers. Literal domains and wildcard
+// base domains are resolved with resolve; IPv6 addresses are skipped because
+// IPv6 is disabled in the guest kernel.
+func FirewallRules(policy *network.Policy, resolve Resolver, dnsServers []string) []Rule {
+	if policy == nil || policy.AllowAll {
+		return nil
+	}
+
+	rules := baseRules()
+	if policy.Blocked {
+		return append(rules, logRule(LogPrefixDeny, "5/sec"))
+	}
+	if policy.Len() == 0 {
+		return rules
+	}
+
+	// Log all new outbound connections (non-terminating)
+	rules = append(rules, logRule(LogPrefixNet, "10/sec", "-m", "state", "--state", "NEW"))
+
+	rules = append(rules, dohRules(policy)...)
+	rules = append(rules, dnsRules(dnsServers)...)
+	rules = append(rules, allowRules(policy, resolve)...)
+
+	// Log denied connections (catch-all before policy DROP)
+	return append(rules, logRule(LogPrefixDeny, "5/sec"))
+}
+
+// AllowRules returns the iptables rules that add specs (faize allow) to an
+// allowlist already installed by FirewallRules, or only their IP ranges with
+// egress, for EgressFirewallRules. The deny log rule is moved after the new
+// ACCEPT rules so it stays the catch-all.
+func AllowRules(specs []string, resolve Resolver, egress bool) []Rule {
+	deny := logRule(LogPrefixDeny, "5/sec")
+	rules := []Rule{{Args: append([]string{"-D"}, deny.Args[1:]...), Optional: true}}
+	policy := (&network.Policy{}).Extend(specs)
+	if egress {
+		rules = append(rules, ipRules(policy)...)
+	} else {
+		rules = append(rules, allowRules(policy, resolve)...)
+	}
+	return append(rules, deny)
+}
+
+// acceptRule accepts traffic to dest, on TCP port only if port is not zero
+func acceptRule(dest string, port int) Rule {
+	args := []string{"-A", "OUTPUT", "-d", dest}
+	if port != 0 {
+		args = append(args, "-p", "tcp", "--dport", strconv.Itoa(port))
+	}
+	return Rule{Args: append(args, "-j", "ACCEPT"), Optional: true}
+}
+
+// allowRules accepts traffic to the policy's domains, wildcards, IP ranges,
+// and port rules
+func allowRules(policy *network.Policy, resolve Resolver) []Rule {
+	var rules []Rule
+	allowHost := func(host string, port int) {
+		for _, ip := range resolve(host) {
+			if strings.Contains(ip, ":") {
+				continue
+			}
+			rules = append(rules, acceptRule(ip, port))
+		}
+	}
+	allow := func(entry string, port int) {
+		switch {
+		case network.IsCIDR(entry):
+			if !strings.Contains(entry, ":") {
+				rules = append(rules, acceptRule(entry, port))
+			}
+		case network.IsWildcard(entry):
+			// Wildcards match the TLS SNI (on port 443 unless limited to
+			// another), with the base domain's IPs as a fallback
+			dport := port
+			if dport == 0 {
+				dport = 443
+			}
+			base := network.ExtractBaseDomain(entry)
+			for _, pattern := range []string{"." + base, base} {
+				rules = append(rules, Rule{
+					Args:     []string{"-A", "OUTPUT", "-p", "tcp", "--dport", strconv.Itoa(dport), "-m", "string", "--string", pattern, "--algo", "bm", "-j", "ACCEPT"},
+					Optional: true,
+				})
+			}
+			allowHost(base, port)
+		default:
+			allowHost(entry, port)
+		}
+	}
+
+	for _, domain := range policy.Domains {
+		allow(domain, 0)
+	}
+	for _, wildcard := range policy.Wildcards {
+		allow(wildcard, 0)
+	}
+	for _, cidr := range policy.CIDRs {
+		allow(cidr, 0)
+	}
+	for _, rule := range policy.Ports {
+		allow(rule.Host, rule.Port)
+	}
+	return rules
+}
+
+// ipRules accepts traffic to the policy's IPv4 ranges, which need no name
+// and so can't go through the host egress proxy
+func ipRules(policy *network.Policy) []Rule {
+	var rules []Rule
+	for _, cidr := range policy.CIDRs {
+		if !strings.Contains(cidr, ":") {
+			rules = append(rules, acceptRule(cidr, 0))
+		}
+	}
+	for _, rule := range policy.Ports {
+		if network.IsCIDR(rule.Host) && !strings.Contains(rule.Host, ":") {
+			rules = append(rules, acceptRule(rule.Host, rule.Port))
+		}
+	}
+	return rules
+}
+
+// EgressFirewallRules returns the iptables OUTPUT rules when the host egress
+// proxy enforces the allowlist: traffic reaches the proxy over vsock, which
+// iptables doesn't see, so only the policy's IP ranges and DNS to dnsServers
+// may leave the guest directly. dnsServers is empty when names are resolved
+// on the host.
+func EgressFirewallRules(policy *network.Policy, dnsServers []string) []Rule {
+	rules := baseRules()
+	rules = append(rules, dohRules(policy)...)
+	rules = append(rules, dnsRules(dnsServers)...)
+	rules = append(rules, ipRules(policy)...)
+	return append(rules, logRule(LogPrefixDeny, "5/sec"))
+}
+
+// ProxyFirewallRules returns the iptables OUTPUT rules when an upstream
+// proxy is the only way out: DNS may reach dnsServers and TCP may reach the
+// proxy at proxyURL, whose host is resolved with resolve unless it is an
+// address. The allowlist can't be enforced per host past the proxy.
+func ProxyFirewallRules(proxyURL string, resolve Resolver, dnsServers []string) []Rule {
+	rules := baseRules()
+	rules = append(rules, logRule(LogPrefixNet, "10/sec", "-m", "state", "--state", "NEW"))
+	rules = append(rules, dnsRules(dnsServers)...)
+	if u, err := url.Parse(proxyURL); err == nil && u.Hostname() != "" {
+		port, _ := strconv.Atoi(u.Port())
+		if port == 0 {
+			port = 80
+		}
+		ips := []string{u.Hostname()}
+		if net.ParseIP(u.Hostname()) == nil {
+			ips = resolve(u.Hostname())
+		}
+		for _, ip := range ips {
+			if !strings.Contains(ip, ":") {
+				rules = append(rules, acceptRule(ip, port))
+			}
+		}
+	}
+	return append(rules, logRule(LogPrefixDeny, "5/sec"))
+}
+
+// allowSet is the ipset dnsmasq fills with the addresses of allowed names in
+// strict mode; names limited to a port get a set per port (allowSetFor)
+const allowSet = "faize-allow"
+
+// allowSetFor returns the ipset for names allowed on port, or any port if zero
+func allowSetFor(port int) string {
+	if port == 0 {
+		return allowSet
+	}
+	return allowSet + "-" + strconv.Itoa(port)
+}
+
+// strictPorts returns the ports the policy's named entries are allowed on,
+// without duplicates. Zero, for any port, always comes first so names
+// allowed later have a set.
+func strictPorts(policy *network.Policy) []int {
+	ports := []int{0}
+	seen := map[int]bool{0: true}
+	for _, rule := range policy.Ports {
+		if !network.IsCIDR(rule.Host) && !seen[rule.Port] {
+			seen[rule.Port] = true
+			ports = append(ports, rule.Port)
+		}
+	}
+	return ports
+}
+
+// IPSetCommands returns the ipset invocations (arguments only, without
+// "ipset") that create the strict mode sets for the policy. Existing sets
+// are kept, so additions can be created the same way.
+func IPSetCommands(policy *network.Policy) [][]string {
+	var cmds [][]string
+	for _, port := range strictPorts(policy) {
+		cmds = append(cmds, []string{"create", allowSetFor(port), "hash:ip", "-exist"})
+	}
+	return cmds
+}
+
+// DNSMasqIPSets returns the dnsmasq lines that add the addresses resolved for
+// the policy's names, and their subdomains, to the strict mode sets
+func DNSMasqIPSets(policy *network.Policy) string {
+	var sb strings.Builder
+	add := func(name string, port int) {
+		fmt.Fprintf(&sb, "ipset=/%s/%s\n", name, allowSetFor(port))
+	}
+	for _, domain := range policy.Domains {
+		add(domain, 0)
+	}
+	for _, wildcard := range policy.Wildcards {
+		add(network.ExtractBaseDomain(wildcard), 0)
+	}
+	for _, rule := range policy.Ports {
+		if network.IsWildcard(rule.Host) {
+			add(network.ExtractBaseDomain(rule.Host), rule.Port)
+		} else if !network.IsCIDR(rule.Host) {
+			add(rule.Host, rule.Port)
+		}
+	}
+	return sb.String()
+}
+
+// setRules accept traffic to the addresses in the strict mode sets for ports
+func setRules(ports []int) []Rule {
+	var rules []Rule
+	for _, port := range ports {
+		args := []string{"-A", "OUTPUT", "-m", "set", "--match-set", allowSetFor(port), "dst"}
+		if port != 0 {
+			args = append(args, "-p", "tcp", "--dport", strconv.Itoa(port))
+		}
+		rules = append(rules, Rule{Args: append(args, "-j", "ACCEPT")})
+	}
+	return rules
+}
+
+// StrictFirewallRules returns the iptables OUTPUT rules enforcing the
+// allowlist in strict mode: instead of addresses resolved at boot and TLS
+// SNI matches, only addresses the DNS forwarder returned for an allowed name
+// (IPSetCommands, DNSMasqIPSets) and the policy's IP ranges are accepted.
+// A process can't reach an arbitrary address that shares a CDN with an
+// allowed name.
+func StrictFirewallRules(policy *network.Policy, dnsServers []string) []Rule {
+	rules := baseRules()
+	rules = append(rules, logRule(LogPrefixNet, "10/sec", "-m", "state", "--state", "NEW"))
+	rules = append(rules, dohRules(policy)...)
+	rules = append(rules, dnsRules(dnsServers)...)
+	rules = append(rules, setRules(strictPorts(policy))...)
+	rules = append(rules, ipRules(policy)...)
+	return append(rules, logRule(LogPrefixDeny, "5/sec"))
+}
+
+// StrictAllowRules returns the iptables rules that add specs (faize allow)
+// to a strict mode allowlist: sets for new ports and IP ranges. Names are
+// added by restarting the DNS forwarder with the extended DNSMasqIPSets.
+func StrictAllowRules(specs []string) []Rule {
+	deny := logRule(LogPrefixDeny, "5/sec")
+	rules := []Rule{{Args: append([]string{"-D"}, deny.Args[1:]...), Optional: true}}
+	policy := (&network.Policy{}).Extend(specs)
+	// The set for any port is accepted from the start
+	rules = append(rules, setRules(strictPorts(policy)[1:])...)
+	rules = append(rules, ipRules(policy)...)
+	return append(rules, deny)
+}
+
+// dohChain logs and drops DNS-over-HTTPS and DNS-over-TLS attempts
+const dohChain = "FAIZE_DOH"
+
+// dohRules send connections to well-known DNS-over-HTTPS endpoints the policy
+// doesn't list explicitly, and all DNS-over-TLS, to dohChain. They go before
+// the ACCEPT rules so an allowed CDN or IP range can't carry them. Endpoint
+// names are matched in the TLS SNI like wildcards.
+func dohRules(policy *network.Policy) []Rule {
+	rules := []Rule{
+		{Args: []string{"-N", dohChain}},
+		{Args: []string{"-A", dohChain, "-j", "LOG", "--log-prefix", LogPrefixDoH, "--log-level", "4", "-m", "limit", "--limit", "5/sec"}, Optional: true},
+		{Args: []string{"-A", dohChain, "-j", "DROP"}},
+		{Args: []string{"-A", "OUTPUT", "-p", "tcp", "--dport", strconv.Itoa(network.DoTPort), "-j", dohChain}},
+	}
+	for _, addr := range network.DoHAddrs {
+		if policy.BlocksDoH(addr) {
+			rules = append(rules, Rule{Args: []string{"-A", "OUTPUT", "-d", addr, "-p", "tcp", "--dport", "443", "-j", dohChain}})
+		}
+	}
+	for _, host := range network.DoHHosts {
+		if policy.BlocksDoH(host) {
+			rules = append(rules, Rule{
+				Args:     []string{"-A", "OUTPUT", "-p", "tcp", "--dport", "443", "-m", "string", "--string", host, "--algo", "bm", "-j", dohChain},
+				Optional: true,
+			})
+		}
+	}
+	return rules
+}
+
+// baseRules drops all outbound traffic except established connections and loopback
+func baseRules() []Rule {
+	return []Rule{
+		{Args: []string{"-P", "OUTPUT", "DROP"}},
+		{Args: []string{"-A", "OUTPUT", "-m", "state", "--state", "ESTABLISHED,RELATED", "-j", "ACCEPT"}},
+		{Args: []string{"-A", "OUTPUT", "-o", "lo", "-j", "ACCEPT"}},
+	}
+}
+
+// dnsRules let the DNS forwarder talk to the given resolvers only
+func dnsRules(servers []string) []Rule {
+	var rules []Rule
+	for _, server := range servers {
+		for _, proto := range []string{"udp", "tcp"} {
+			rules = append(rules, Rule{Args: []string{"-A", "OUTPUT", "-p", proto, "-d", server, "--dport", "53", "-j", "ACCEPT"}})
+		}
+	}
+	return rules
+}
@@ -0,0 +1,24 @@
+package agent
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// isEmptyDir reports whether the directory at path has no entries, as a
+// persistent home has before its first session seeds it
+func isEmptyDir(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = f.Close() }()
+	if _, err := f.Readdirnames(1); err != nil {
+		if errors.Is(err, io.EOF) {
+			return true, nil
+		}
+		return false, err
+	}
+	return false, nil
+}
@@ -0,0 +1,27 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsEmptyDir(t *testing.T) {
+	dir := t.TempDir()
+	empty, err := isEmptyDir(dir)
+	if err != nil || !empty {
+		t.Fatalf("isEmptyDir(new dir) = %v, %v; want true", empty, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, ".ash_history"), []byte("ls\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	empty, err = isEmptyDir(dir)
+	if err != nil || empty {
+		t.Fatalf("isEmptyDir(seeded dir) = %v, %v; want false", empty, err)
+	}
+
+	if _, err := isEmptyDir(filepath.Join(dir, "missing")); err == nil {
+		t.Fatal("isEmptyDir(missing dir) succeeded")
+	}
+}
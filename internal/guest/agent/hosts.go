@@ -0,0 +1,29 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/faize-ai/faize/internal/session"
+)
+
+// hostsMarker tags the /etc/hosts lines the agent adds
+const hostsMarker = "# faize"
+
+// HostsFile returns the /etc/hosts content with entries appended to
+// existing, replacing lines added by an earlier boot
+func HostsFile(existing string, entries []session.HostEntry) string {
+	var sb strings.Builder
+	for _, line := range strings.SplitAfter(existing, "\n") {
+		if line != "" && !strings.HasSuffix(strings.TrimRight(line, "\n"), hostsMarker) {
+			sb.WriteString(line)
+		}
+	}
+	if sb.Len() > 0 && !strings.HasSuffix(sb.String(), "\n") {
+		sb.WriteString("\n")
+	}
+	for _, e := range entries {
+		fmt.Fprintf(&sb, "%s\t%s\t%s\n", e.IP, e.Name, hostsMarker)
+	}
+	return sb.String()
+}
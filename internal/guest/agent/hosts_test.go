@@ -0,0 +1,20 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/faize-ai/faize/internal/session"
+)
+
+func TestHostsFile(t *testing.T) {
+	existing := "127.0.0.1\tlocalhost\n10.0.0.9\told.local\t# faize\n::1\tlocalhost"
+	got := HostsFile(existing, []session.HostEntry{{Name: "db.local", IP: "192.168.1.20"}, {Name: "v6.local", IP: "fd00::1"}})
+
+	want := "127.0.0.1\tlocalhost\n::1\tlocalhost\n192.168.1.20\tdb.local\t# faize\nfd00::1\tv6.local\t# faize\n"
+	if got != want {
+		t.Errorf("HostsFile =\n%q\nwant\n%q", got, want)
+	}
+	if got := HostsFile("", nil); got != "" {
+		t.Errorf("HostsFile with nothing to add = %q, want empty", got)
+	}
+}
@@ -0,0 +1,76 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// initStateDir holds the markers of completed setup steps for the current boot
+const initStateDir = "/run/faize/init"
+
+// Setup steps that must only run once per boot. Re-running them would undo
+// changes made during the session (copied config, restored credentials) or
+// repeat slow work (chown -R of the project).
+const (
+	stepOwnership   = "ownership"
+	stepClaudeFiles = "claude-files"
+	stepCredentials = "credentials"
+	stepPostCreate  = "post-create"
+)
+
+// initState records completed setup steps as marker files, so the setup can
+// be run again (faize-agent --reinit) without repeating one-time work
+type initState struct {
+	dir string
+}
+
+// done reports whether step has completed
+func (s initState) done(step string) bool {
+	return fileExists(filepath.Join(s.dir, step))
+}
+
+// mark records step as completed
+func (s initState) mark(step string) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create init state directory: %w", err)
+	}
+	stamp := time.Now().UTC().Format(time.RFC3339) + "\n"
+	if err := os.WriteFile(filepath.Join(s.dir, step), []byte(stamp), 0644); err != nil {
+		return fmt.Errorf("failed to record init step %s: %w", step, err)
+	}
+	return nil
+}
+
+// once runs fn unless step has completed, and marks it when fn succeeds.
+// It reports whether fn ran.
+func (s initState) once(step string, fn func() error) (bool, error) {
+	if s.done(step) {
+		return false, nil
+	}
+	if err := fn(); err != nil {
+		return true, err
+	}
+	return true, s.mark(step)
+}
+
+// reset forgets all completed steps; the agent calls it at boot so markers
+// left in the image by an earlier session don't skip setup
+func (s initState) reset() error {
+	if err := os.RemoveAll(s.dir); err != nil {
+		return fmt.Errorf("failed to reset init state: %w", err)
+	}
+	return nil
+}
+
+// outputHasLine reports whether output (one value per line) contains value
+func outputHasLine(output, value string) bool {
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package agent
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestInitStateOnce(t *testing.T) {
+	s := initState{dir: filepath.Join(t.TempDir(), "init")}
+
+	calls := 0
+	step := func() error {
+		calls++
+		return nil
+	}
+
+	ran, err := s.once(stepOwnership, step)
+	if err != nil || !ran {
+		t.Fatalf("first run: ran=%v err=%v", ran, err)
+	}
+	ran, err = s.once(stepOwnership, step)
+	if err != nil || ran {
+		t.Fatalf("second run: ran=%v err=%v", ran, err)
+	}
+	if calls != 1 {
+		t.Errorf("Step ran %d times, want 1", calls)
+	}
+	if !s.done(stepOwnership) {
+		t.Error("Expected step to be marked done")
+	}
+	if s.done(stepCredentials) {
+		t.Error("Unrelated step marked done")
+	}
+}
+
+func TestInitStateOnceFailure(t *testing.T) {
+	s := initState{dir: filepath.Join(t.TempDir(), "init")}
+
+	ran, err := s.once(stepClaudeFiles, func() error { return errors.New("boom") })
+	if err == nil || !ran {
+		t.Fatalf("ran=%v err=%v, want the step's error", ran, err)
+	}
+	if s.done(stepClaudeFiles) {
+		t.Error("Failed step must not be marked done")
+	}
+
+	// A failed step is retried on the next run
+	ran, err = s.once(stepClaudeFiles, func() error { return nil })
+	if err != nil || !ran {
+		t.Fatalf("retry: ran=%v err=%v", ran, err)
+	}
+}
+
+func TestInitStateReset(t *testing.T) {
+	s := initState{dir: filepath.Join(t.TempDir(), "init")}
+	if err := s.mark(stepCredentials); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.reset(); err != nil {
+		t.Fatal(err)
+	}
+	if s.done(stepCredentials) {
+		t.Error("Expected reset to clear completed steps")
+	}
+	// Resetting missing state is not an error
+	if err := s.reset(); err != nil {
+		t.Errorf("reset of missing state: %v", err)
+	}
+}
+
+func TestOutputHasLine(t *testing.T) {
+	output := "/workspace\n /home/claude/project \n"
+	if !outputHasLine(output, "/home/claude/project") {
+		t.Error("Expected trimmed line to match")
+	}
+	if outputHasLine(output, "/home") {
+		t.Error("Prefix must not match")
+	}
+	if outputHasLine("", "/workspace") {
+		t.Error("Empty output must not match")
+	}
+}
@@ -0,0 +1,189 @@
+package agent
+
+import (
+	"bufio"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	apkWorld     = "/etc/apk/world"        // packages installed by name, one per line
+	apkInstalled = "/lib/apk/db/installed" // every installed package, with its version
+
+	dpkgStatus        = "/var/lib/dpkg/status"         // every package dpkg knows, with its state and version
+	aptExtendedStates = "/var/lib/apt/extended_states" // packages apt installed as dependencies
+)
+
+// packageState is what the package manager has installed: the packages
+// installed by name (apk's world file) and the installed version of each
+// package
+type packageState struct {
+	world    map[string]bool
+	versions map[string]string
+}
+
+// readPackageState reads apk's world file and installed database. Returns
+// false when the guest has no apk world file.
+func readPackageState(worldPath, installedPath string) (packageState, bool) {
+	world, err := readAPKWorld(worldPath)
+	if err != nil {
+		return packageState{}, false
+	}
+	versions, _ := readAPKInstalled(installedPath)
+	return packageState{world: world, versions: versions}, true
+}
+
+// readInstalledPackages reads the guest's package state from apk, or from
+// dpkg and apt on the Debian and Ubuntu rootfs flavors. Returns false when
+// the guest has neither.
+func readInstalledPackages() (packageState, bool) {
+	if state, ok := readPackageState(apkWorld, apkInstalled); ok {
+		return state, true
+	}
+	return readDpkgState(dpkgStatus, aptExtendedStates)
+}
+
+// readDpkgState reads dpkg's status database and apt's extended states: the
+// installed packages apt didn't mark as automatically installed are those
+// installed by name. Returns false when the guest has no dpkg status file.
+func readDpkgState(statusPath, extendedPath string) (packageState, bool) {
+	versions, err := readDpkgStatus(statusPath)
+	if err != nil {
+		return packageState{}, false
+	}
+	auto, _ := readAptAuto(extendedPath)
+	world := make(map[string]bool, len(versions))
+	for name := range versions {
+		if !auto[name] {
+			world[name] = true
+		}
+	}
+	return packageState{world: world, versions: versions}, true
+}
+
+// readDpkgStatus returns the version of each installed package in dpkg's
+// status file, where each package is a block of "Package:", "Status:" and
+// "Version:" fields
+func readDpkgStatus(path string) (map[string]string, error) {
+	versions := make(map[string]string)
+	err := readControlBlocks(path, func(fields map[string]string) {
+		if fields["Package"] != "" && strings.HasSuffix(fields["Status"], " installed") {
+			versions[fields["Package"]] = fields["Version"]
+		}
+	})
+	return versions, err
+}
+
+// readAptAuto returns the packages apt's extended states mark with
+// "Auto-Installed: 1"
+func readAptAuto(path string) (map[string]bool, error) {
+	auto := make(map[string]bool)
+	err := readControlBlocks(path, func(fields map[string]string) {
+		if fields["Auto-Installed"] == "1" {
+			auto[fields["Package"]] = true
+		}
+	})
+	return auto, err
+}
+
+// readControlBlocks calls fn with the fields of each blank-line separated
+// block of a Debian control file. Continuation lines are skipped.
+func readControlBlocks(path string, fn func(map[string]string)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	fields := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if len(fields) > 0 {
+				fn(fields)
+				fields = make(map[string]string)
+			}
+		case line[0] == ' ' || line[0] == '\t':
+		default:
+			if key, value, ok := strings.Cut(line, ":"); ok {
+				fields[key] = strings.TrimSpace(value)
+			}
+		}
+	}
+	if len(fields) > 0 {
+		fn(fields)
+	}
+	return scanner.Err()
+}
+
+// readAPKWorld returns the package names in an apk world file, without
+// version constraints or repository tags. Conflicts ("!name") are skipped.
+func readAPKWorld(path string) (map[string]bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool)
+	for _, entry := range strings.Fields(string(data)) {
+		if strings.HasPrefix(entry, "!") {
+			continue
+		}
+		if i := strings.IndexAny(entry, "=<>~@"); i >= 0 {
+			entry = entry[:i]
+		}
+		if entry != "" {
+			names[entry] = true
+		}
+	}
+	return names, nil
+}
+
+// readAPKInstalled returns the version of each package in apk's installed
+// database, where each package is a block of "P:name" and "V:version" lines
+func readAPKInstalled(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	versions := make(map[string]string)
+	var name string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			name = ""
+		case strings.HasPrefix(line, "P:"):
+			name = line[2:]
+		case strings.HasPrefix(line, "V:") && name != "":
+			versions[name] = line[2:]
+		}
+	}
+	return versions, scanner.Err()
+}
+
+// installedSince lists the packages installed by name after before, as
+// sorted "name version" lines. Dependencies pulled in with them are left
+// out.
+func installedSince(before, after packageState) []string {
+	var lines []string
+	for name := range after.world {
+		if before.world[name] {
+			continue
+		}
+		line := name
+		if v := after.versions[name]; v != "" {
+			line += " " + v
+		}
+		lines = append(lines, line)
+	}
+	sort.Strings(lines)
+	return lines
+}
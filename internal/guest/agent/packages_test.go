@@ -0,0 +1,92 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadPackageState(t *testing.T) {
+	dir := t.TempDir()
+	world := filepath.Join(dir, "world")
+	installed := filepath.Join(dir, "installed")
+	if err := os.WriteFile(world, []byte("alpine-base\nbash\ngo>=1.22\nripgrep@community\n!busybox-extras\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	db := "C:Q1abc=\nP:go\nV:1.22.5-r0\nA:aarch64\n\nP:ripgrep\nV:14.1.0-r0\n\nP:pcre2\nV:10.43-r0\n"
+	if err := os.WriteFile(installed, []byte(db), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	state, ok := readPackageState(world, installed)
+	if !ok {
+		t.Fatal("readPackageState() = false")
+	}
+	wantWorld := map[string]bool{"alpine-base": true, "bash": true, "go": true, "ripgrep": true}
+	if !reflect.DeepEqual(state.world, wantWorld) {
+		t.Errorf("world = %v, want %v", state.world, wantWorld)
+	}
+	if state.versions["go"] != "1.22.5-r0" || state.versions["pcre2"] != "10.43-r0" {
+		t.Errorf("versions = %v", state.versions)
+	}
+
+	if _, ok := readPackageState(filepath.Join(dir, "missing"), installed); ok {
+		t.Error("readPackageState() without a world file = true")
+	}
+}
+
+func TestReadDpkgState(t *testing.T) {
+	dir := t.TempDir()
+	status := filepath.Join(dir, "status")
+	extended := filepath.Join(dir, "extended_states")
+	db := "Package: bash\nStatus: install ok installed\nVersion: 5.2.15-2+b7\nDescription: GNU Bourne Again SHell\n Bash is an sh-compatible shell.\n\n" +
+		"Package: golang-go\nStatus: install ok installed\nVersion: 2:1.19~1\n\n" +
+		"Package: golang-1.19-go\nStatus: install ok installed\nVersion: 1.19.8-2\n\n" +
+		"Package: vim\nStatus: deinstall ok config-files\nVersion: 2:9.0.1378-2\n"
+	if err := os.WriteFile(status, []byte(db), 0644); err != nil {
+		t.Fatal(err)
+	}
+	states := "Package: golang-1.19-go\nArchitecture: arm64\nAuto-Installed: 1\n\nPackage: bash\nArchitecture: arm64\nAuto-Installed: 0\n"
+	if err := os.WriteFile(extended, []byte(states), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	state, ok := readDpkgState(status, extended)
+	if !ok {
+		t.Fatal("readDpkgState() = false")
+	}
+	wantWorld := map[string]bool{"bash": true, "golang-go": true}
+	if !reflect.DeepEqual(state.world, wantWorld) {
+		t.Errorf("world = %v, want %v", state.world, wantWorld)
+	}
+	wantVersions := map[string]string{"bash": "5.2.15-2+b7", "golang-go": "2:1.19~1", "golang-1.19-go": "1.19.8-2"}
+	if !reflect.DeepEqual(state.versions, wantVersions) {
+		t.Errorf("versions = %v, want %v", state.versions, wantVersions)
+	}
+
+	// Without extended states, every installed package counts as installed by name
+	state, ok = readDpkgState(status, filepath.Join(dir, "missing"))
+	if !ok || !state.world["golang-1.19-go"] {
+		t.Errorf("readDpkgState() without extended states = %v, %v", state.world, ok)
+	}
+	if _, ok := readDpkgState(filepath.Join(dir, "missing"), extended); ok {
+		t.Error("readDpkgState() without a status file = true")
+	}
+}
+
+func TestInstalledSince(t *testing.T) {
+	before := packageState{world: map[string]bool{"alpine-base": true, "bash": true}}
+	after := packageState{
+		world:    map[string]bool{"alpine-base": true, "ripgrep": true, "go": true, ".build-deps": true},
+		versions: map[string]string{"go": "1.22.5-r0", "ripgrep": "14.1.0-r0", "pcre2": "10.43-r0"},
+	}
+	got := installedSince(before, after)
+	want := []string{".build-deps", "go 1.22.5-r0", "ripgrep 14.1.0-r0"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("installedSince() = %v, want %v", got, want)
+	}
+	if got := installedSince(after, after); got != nil {
+		t.Errorf("installedSince() without changes = %v", got)
+	}
+}
@@ -0,0 +1,36 @@
+package agent
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// scratchDevice is the scratch disk: the second virtio-blk device, after the
+// rootfs
+const scratchDevice = "/dev/vdb"
+
+// ext4MagicOffset is where the ext2/3/4 superblock magic sits on a device
+const ext4MagicOffset = 1024 + 56
+
+// ext4Magic is the little-endian superblock magic of ext2/3/4
+const ext4Magic = 0xEF53
+
+// hasExt4 reports whether the device at path already holds an ext4 (or
+// ext2/3) filesystem, so a formatted scratch disk is never wiped
+func hasExt4(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = f.Close() }()
+	var magic [2]byte
+	if _, err := f.ReadAt(magic[:], ext4MagicOffset); err != nil {
+		if err == io.EOF {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return binary.LittleEndian.Uint16(magic[:]) == ext4Magic, nil
+}
@@ -0,0 +1,44 @@
+package agent
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHasExt4(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scratch.img")
+	if err := os.WriteFile(path, make([]byte, 4096), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := hasExt4(path); err != nil || ok {
+		t.Fatalf("blank disk: hasExt4 = %v, %v", ok, err)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	magic := binary.LittleEndian.AppendUint16(nil, ext4Magic)
+	if _, err := f.WriteAt(magic, ext4MagicOffset); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+	if ok, err := hasExt4(path); err != nil || !ok {
+		t.Fatalf("formatted disk: hasExt4 = %v, %v", ok, err)
+	}
+
+	// A device too small to hold a superblock is blank
+	short := filepath.Join(t.TempDir(), "short.img")
+	if err := os.WriteFile(short, make([]byte, 512), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := hasExt4(short); err != nil || ok {
+		t.Fatalf("short disk: hasExt4 = %v, %v", ok, err)
+	}
+
+	if _, err := hasExt4(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Fatal("expected an error for a missing device")
+	}
+}
@@ -0,0 +1,19 @@
+package agent
+
+import "strconv"
+
+// ShapingCommands returns the tc invocations (arguments only, without "tc")
+// that cap iface's bandwidth at bitsPerSecond in each direction: a token
+// bucket shapes what the guest sends, and a policer on the ingress qdisc
+// drops what arrives faster, which makes TCP senders back off.
+func ShapingCommands(iface string, bitsPerSecond uint64) [][]string {
+	rate := strconv.FormatUint(bitsPerSecond, 10) + "bit"
+	// A tenth of a second's worth of data, and at least a few full-size packets
+	burst := strconv.FormatUint(max(bitsPerSecond/8/10, 16*1024), 10)
+	return [][]string{
+		{"qdisc", "replace", "dev", iface, "root", "tbf", "rate", rate, "burst", burst, "latency", "100ms"},
+		{"qdisc", "replace", "dev", iface, "handle", "ffff:", "ingress"},
+		{"filter", "replace", "dev", iface, "parent", "ffff:", "protocol", "all", "prio", "1", "u32", "match", "u32", "0", "0",
+			"police", "rate", rate, "burst", burst, "drop", "flowid", ":1"},
+	}
+}
@@ -0,0 +1,30 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestShapingCommands(t *testing.T) {
+	cmds := ShapingCommands("eth0", 10_000_000)
+	if len(cmds) != 3 {
+		t.Fatalf("Expected egress, ingress qdisc and policer commands, got %v", cmds)
+	}
+	want := []string{
+		"tc qdisc replace dev eth0 root tbf rate 10000000bit burst 125000 latency 100ms",
+		"tc qdisc replace dev eth0 handle ffff: ingress",
+	}
+	for i, w := range want {
+		if got := "tc " + strings.Join(cmds[i], " "); got != w {
+			t.Errorf("command %d = %q, want %q", i, got, w)
+		}
+	}
+	if police := strings.Join(cmds[2], " "); !strings.Contains(police, "police rate 10000000bit burst 125000 drop") {
+		t.Errorf("Unexpected policer %q", police)
+	}
+
+	// Slow limits still allow a few full-size packets per burst
+	if slow := strings.Join(ShapingCommands("eth0", 64_000)[0], " "); !strings.Contains(slow, "burst 16384") {
+		t.Errorf("Expected the minimum burst, got %q", slow)
+	}
+}
@@ -0,0 +1,208 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/faize-ai/faize/internal/guest"
+)
+
+// Shims are the tools the agent impersonates when invoked through a symlink.
+// Clipboard reads are served from files the host syncs into the bootstrap share;
+// URL opens are forwarded to the host browser; faize-notify reports events
+// from hooks to the host.
+var Shims = []string{"xclip", "xsel", "xdg-open", "open", "faize-notify"}
+
+// hookEvents names the notifications for Claude Code hook events
+var hookEvents = map[string]string{
+	"Notification": "needs-attention",
+	"Stop":         "task-complete",
+	"SubagentStop": "subagent-complete",
+}
+
+// openURLWait is how long xdg-open waits for the host to acknowledge a URL
+const openURLWait = 5 * time.Second
+
+// IsShim reports whether name (the invoked program's base name) is a shim
+func IsShim(name string) bool {
+	for _, s := range Shims {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+// RunShim executes the named shim and returns the process exit code
+func RunShim(name string, args []string) int {
+	if err := runShim(name, args, os.Stdin, os.Stdout, guest.BootstrapDir); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		return 1
+	}
+	return 0
+}
+
+// runShim dispatches a shim invocation against the given bootstrap directory
+func runShim(name string, args []string, stdin io.Reader, stdout io.Writer, dir string) error {
+	clipDir := filepath.Join(dir, "clipboard")
+	switch name {
+	case "xclip":
+		return runXclip(args, stdin, stdout, clipDir)
+	case "xsel":
+		return runXsel(args, stdin, stdout, clipDir)
+	case "xdg-open", "open":
+		if len(args) == 0 || args[0] == "" {
+			return nil
+		}
+		return requestOpenURL(dir, args[0], openURLWait)
+	case "faize-notify":
+		return runNotify(args, stdin, dir)
+	}
+	return fmt.Errorf("unknown shim")
+}
+
+// runXclip implements the subset of xclip used by Claude Code for paste support
+func runXclip(args []string, stdin io.Reader, stdout io.Writer, clipDir string) error {
+	output := false
+	target := ""
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-o", "-out":
+			output = true
+		case "-t", "-selection":
+			if i+1 < len(args) {
+				if args[i] == "-t" {
+					target = args[i+1]
+				}
+				i++
+			}
+		}
+	}
+
+	if !output {
+		return writeClipboardText(stdin, clipDir)
+	}
+
+	imagePath := filepath.Join(clipDir, "clipboard-image")
+	textPath := filepath.Join(clipDir, "clipboard-text")
+	switch {
+	case target == "TARGETS":
+		// Report available clipboard types
+		if fileExists(imagePath) {
+			_, _ = fmt.Fprint(stdout, "image/png\n")
+		}
+		if fileExists(textPath) {
+			_, _ = fmt.Fprint(stdout, "UTF8_STRING\ntext/plain\n")
+		}
+		return nil
+	case target == "image/png" && fileExists(imagePath):
+		return copyFileTo(stdout, imagePath)
+	case fileExists(textPath):
+		return copyFileTo(stdout, textPath)
+	}
+	return nil
+}
+
+// runXsel implements the subset of xsel used for text copy and paste
+func runXsel(args []string, stdin io.Reader, stdout io.Writer, clipDir string) error {
+	for _, arg := range args {
+		if arg == "-o" || arg == "--output" {
+			textPath := filepath.Join(clipDir, "clipboard-text")
+			if fileExists(textPath) {
+				return copyFileTo(stdout, textPath)
+			}
+			return nil
+		}
+	}
+	return writeClipboardText(stdin, clipDir)
+}
+
+// writeClipboardText stores stdin as the clipboard text
+func writeClipboardText(stdin io.Reader, clipDir string) error {
+	if err := os.MkdirAll(clipDir, 0755); err != nil {
+		return err
+	}
+	data, err := io.ReadAll(stdin)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(clipDir, "clipboard-text"), data, 0644)
+}
+
+// runNotify reports an event to the host: "faize-notify <event> [message]",
+// or without arguments, the JSON payload of a Claude Code hook on stdin
+func runNotify(args []string, stdin io.Reader, dir string) error {
+	n := guest.Notification{Time: time.Now().UTC()}
+	if len(args) > 0 {
+		n.Event = args[0]
+		n.Message = strings.Join(args[1:], " ")
+	} else {
+		var payload struct {
+			HookEventName string `json:"hook_event_name"`
+			Message       string `json:"message"`
+		}
+		if err := json.NewDecoder(stdin).Decode(&payload); err != nil {
+			return fmt.Errorf("usage: faize-notify <event> [message], or a hook payload on stdin: %w", err)
+		}
+		n.Event, n.Message = payload.HookEventName, payload.Message
+		if event, ok := hookEvents[payload.HookEventName]; ok {
+			n.Event = event
+		}
+		if n.Message == "" && n.Event == "task-complete" {
+			n.Message = "Claude finished"
+		}
+	}
+	if n.Event == "" {
+		return fmt.Errorf("no event given")
+	}
+	return guest.AppendNotification(dir, n)
+}
+
+// requestOpenURL asks the host to open url by atomically writing it to
+// open-url in the bootstrap dir, then waits for the host to remove the file.
+func requestOpenURL(dir, url string, wait time.Duration) error {
+	tmp, err := os.CreateTemp(dir, ".open-url.")
+	if err != nil {
+		return nil // host bridge unavailable, nothing to do
+	}
+	if _, err := tmp.WriteString(url); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	_ = tmp.Close()
+
+	target := filepath.Join(dir, "open-url")
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	deadline := time.Now().Add(wait)
+	for time.Now().Before(deadline) && fileExists(target) {
+		time.Sleep(500 * time.Millisecond)
+	}
+	return nil
+}
+
+// fileExists reports whether path exists
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// copyFileTo streams a file to w
+func copyFileTo(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	_, err = io.Copy(w, f)
+	return err
+}
@@ -0,0 +1,177 @@
+package agent
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/faize-ai/faize/internal/guest"
+)
+
+// newClipDir creates a bootstrap dir with optional clipboard contents
+func newClipDir(t *testing.T, text string, image []byte) string {
+	t.Helper()
+	dir := t.TempDir()
+	clipDir := filepath.Join(dir, "clipboard")
+	if err := os.MkdirAll(clipDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if text != "" {
+		if err := os.WriteFile(filepath.Join(clipDir, "clipboard-text"), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if image != nil {
+		if err := os.WriteFile(filepath.Join(clipDir, "clipboard-image"), image, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestIsShim(t *testing.T) {
+	for _, name := range []string{"xclip", "xsel", "xdg-open", "open", "faize-notify"} {
+		if !IsShim(name) {
+			t.Errorf("IsShim(%q) = false", name)
+		}
+	}
+	if IsShim("faize-agent") {
+		t.Error("IsShim(faize-agent) = true")
+	}
+}
+
+func TestXclipTargets(t *testing.T) {
+	dir := newClipDir(t, "hello", []byte("PNG"))
+	var out bytes.Buffer
+	if err := runShim("xclip", []string{"-selection", "clipboard", "-t", "TARGETS", "-o"}, nil, &out, dir); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "image/png\nUTF8_STRING\ntext/plain\n" {
+		t.Errorf("TARGETS = %q", out.String())
+	}
+}
+
+func TestXclipImageAndText(t *testing.T) {
+	dir := newClipDir(t, "hello", []byte("PNG"))
+
+	var out bytes.Buffer
+	if err := runShim("xclip", []string{"-selection", "clipboard", "-t", "image/png", "-o"}, nil, &out, dir); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "PNG" {
+		t.Errorf("image output = %q", out.String())
+	}
+
+	out.Reset()
+	if err := runShim("xclip", []string{"-selection", "clipboard", "-o"}, nil, &out, dir); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "hello" {
+		t.Errorf("text output = %q", out.String())
+	}
+}
+
+func TestXclipEmptyClipboard(t *testing.T) {
+	dir := newClipDir(t, "", nil)
+	var out bytes.Buffer
+	if err := runShim("xclip", []string{"-t", "TARGETS", "-o"}, nil, &out, dir); err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected no targets, got %q", out.String())
+	}
+}
+
+func TestXselCopyAndPaste(t *testing.T) {
+	dir := newClipDir(t, "", nil)
+	if err := runShim("xsel", []string{"--clipboard", "--input"}, strings.NewReader("copied"), nil, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := runShim("xsel", []string{"--clipboard", "--output"}, nil, &out, dir); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "copied" {
+		t.Errorf("paste = %q", out.String())
+	}
+}
+
+func TestRequestOpenURL(t *testing.T) {
+	dir := t.TempDir()
+	if err := requestOpenURL(dir, "https://claude.ai/oauth", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "open-url"))
+	if err != nil {
+		t.Fatalf("open-url not written: %v", err)
+	}
+	if string(data) != "https://claude.ai/oauth" {
+		t.Errorf("open-url = %q", data)
+	}
+
+	leftovers, _ := filepath.Glob(filepath.Join(dir, ".open-url.*"))
+	if len(leftovers) != 0 {
+		t.Errorf("Temporary files left behind: %v", leftovers)
+	}
+}
+
+func TestRequestOpenURLWaitsForHost(t *testing.T) {
+	dir := t.TempDir()
+	go func() {
+		target := filepath.Join(dir, "open-url")
+		for i := 0; i < 50; i++ {
+			if os.Remove(target) == nil {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	start := time.Now()
+	if err := requestOpenURL(dir, "https://example.com", 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) >= 5*time.Second {
+		t.Error("Expected to return once the host consumed the URL")
+	}
+}
+
+func TestNotifyShim(t *testing.T) {
+	dir := t.TempDir()
+	if err := runShim("faize-notify", []string{"task-complete", "tests", "pass"}, nil, nil, dir); err != nil {
+		t.Fatal(err)
+	}
+	hook := `{"session_id":"s1","hook_event_name":"Notification","message":"Claude needs your permission to use Bash"}`
+	if err := runShim("faize-notify", nil, strings.NewReader(hook), nil, dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := runShim("faize-notify", nil, strings.NewReader(`{"hook_event_name":"Stop"}`), nil, dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := runShim("faize-notify", nil, strings.NewReader("not json"), nil, dir); err == nil {
+		t.Error("expected an error for an invalid payload")
+	}
+
+	notes, _, err := guest.ReadNotifications(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"task-complete: tests pass",
+		"needs-attention: Claude needs your permission to use Bash",
+		"task-complete: Claude finished",
+	}
+	if len(notes) != len(want) {
+		t.Fatalf("notes = %+v", notes)
+	}
+	for i, n := range notes {
+		if got := n.Event + ": " + n.Message; got != want[i] {
+			t.Errorf("note %d = %q, want %q", i, got, want[i])
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/faize-ai/faize/internal/guest"
+)
+
+// Guest paths for the session's SSH server
+const (
+	sshdPath         = "/usr/sbin/sshd"
+	sshdConfigPath   = "/etc/ssh/sshd_faize_config"
+	sshHostKeyPath   = "/etc/ssh/ssh_host_ed25519_key"
+	sshAuthorizedDir = "/etc/ssh/authorized_keys" // one file per user, named after the user
+	sshLoginProfile  = "/etc/profile.d/faize.sh"
+)
+
+// SSHDConfig returns the sshd configuration: key-only logins as the session
+// user. Authorized keys live outside the home directory so StrictModes never
+// trips over VirtioFS ownership.
+func SSHDConfig() string {
+	return fmt.Sprintf(`Port 22
+HostKey %s
+AuthorizedKeysFile %s
+PasswordAuthentication no
+KbdInteractiveAuthentication no
+PermitRootLogin no
+AllowUsers claude
+PidFile /run/sshd.pid
+Subsystem sftp internal-sftp
+`, sshHostKeyPath, sshAuthorizedDir+"/%u")
+}
+
+// SSHLoginProfile returns the login shell profile for SSH sessions: the
+// toolchain on PATH, the extra env (KEY=value), and the project as the
+// starting directory
+func SSHLoginProfile(workDir string, env []string) string {
+	var sb strings.Builder
+	sb.WriteString("export PATH=/opt/toolchain/bin:/usr/local/bin:/usr/bin:/bin\n")
+	sb.WriteString("export GIT_DISCOVERY_ACROSS_FILESYSTEM=1\n")
+	for _, kv := range env {
+		if key, value, ok := strings.Cut(kv, "="); ok {
+			fmt.Fprintf(&sb, "export %s=%s\n", key, guest.ShellQuote(value))
+		}
+	}
+	fmt.Fprintf(&sb, "cd %s 2>/dev/null || true\n", guest.ShellQuote(workDir))
+	return sb.String()
+}
@@ -0,0 +1,37 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSSHDConfig(t *testing.T) {
+	cfg := SSHDConfig()
+	for _, want := range []string{
+		"HostKey /etc/ssh/ssh_host_ed25519_key\n",
+		"AuthorizedKeysFile /etc/ssh/authorized_keys/%u\n",
+		"PasswordAuthentication no\n",
+		"PermitRootLogin no\n",
+		"AllowUsers claude\n",
+		"Subsystem sftp internal-sftp\n",
+	} {
+		if !strings.Contains(cfg, want) {
+			t.Errorf("sshd config missing %q", want)
+		}
+	}
+}
+
+func TestSSHLoginProfile(t *testing.T) {
+	profile := SSHLoginProfile("/Users/me/it's here", nil)
+	if !strings.Contains(profile, `cd '/Users/me/it'\''s here'`) {
+		t.Errorf("work dir not quoted: %s", profile)
+	}
+	if !strings.Contains(profile, "/opt/toolchain/bin") {
+		t.Error("toolchain missing from PATH")
+	}
+
+	profile = SSHLoginProfile("/workspace", ProxyEnv())
+	if !strings.Contains(profile, "export HTTPS_PROXY='http://127.0.0.1:3128'\n") {
+		t.Errorf("proxy env not exported: %s", profile)
+	}
+}
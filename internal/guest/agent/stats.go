@@ -0,0 +1,88 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// statsInterval is how often the agent samples CPU and memory usage
+const statsInterval = 2 * time.Second
+
+// cpuSample is the aggregate CPU time from /proc/stat, in clock ticks
+type cpuSample struct {
+	idle  uint64 // idle and waiting for I/O
+	total uint64
+}
+
+// parseCPUSample reads the aggregate "cpu" line of /proc/stat
+func parseCPUSample(data []byte) (cpuSample, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || fields[0] != "cpu" {
+			continue
+		}
+		// Fields are user, nice, system, idle, iowait, irq, softirq, steal,
+		// then guest times, which are already counted in user and nice
+		var s cpuSample
+		for i, f := range fields[1:] {
+			if i >= 8 {
+				break
+			}
+			v, err := strconv.ParseUint(f, 10, 64)
+			if err != nil {
+				return cpuSample{}, fmt.Errorf("invalid cpu time %q", f)
+			}
+			s.total += v
+			if i == 3 || i == 4 {
+				s.idle += v
+			}
+		}
+		return s, nil
+	}
+	return cpuSample{}, fmt.Errorf("no cpu line in /proc/stat")
+}
+
+// cpuPercent returns how busy the CPUs were between two samples
+func cpuPercent(prev, cur cpuSample) float64 {
+	if cur.total <= prev.total {
+		return 0
+	}
+	busy := float64((cur.total - prev.total) - (cur.idle - prev.idle))
+	return 100 * busy / float64(cur.total-prev.total)
+}
+
+// parseMemInfo returns memory in use and total memory in bytes from
+// /proc/meminfo, counting MemAvailable as free
+func parseMemInfo(data []byte) (used, total uint64, err error) {
+	var available uint64
+	var haveTotal, haveAvailable bool
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		v, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "MemTotal:":
+			total, haveTotal = v*1024, true
+		case "MemAvailable:":
+			available, haveAvailable = v*1024, true
+		}
+	}
+	if !haveTotal || !haveAvailable {
+		return 0, 0, fmt.Errorf("MemTotal or MemAvailable missing from /proc/meminfo")
+	}
+	if available > total {
+		available = total
+	}
+	return total - available, total, nil
+}
@@ -0,0 +1,43 @@
+package agent
+
+import "testing"
+
+func TestParseCPUSample(t *testing.T) {
+	data := []byte("cpu  100 5 50 800 45 0 0 0 30 0\ncpu0 50 2 25 400 20 0 0 0 15 0\nintr 12345\n")
+	s, err := parseCPUSample(data)
+	if err != nil {
+		t.Fatalf("parseCPUSample: %v", err)
+	}
+	if s.total != 1000 || s.idle != 845 {
+		t.Errorf("sample = %+v, want total 1000, idle 845", s)
+	}
+
+	if _, err := parseCPUSample([]byte("intr 1\n")); err == nil {
+		t.Error("expected an error without a cpu line")
+	}
+}
+
+func TestCPUPercent(t *testing.T) {
+	prev := cpuSample{idle: 800, total: 1000}
+	if got := cpuPercent(prev, cpuSample{idle: 950, total: 1200}); got != 25 {
+		t.Errorf("cpuPercent = %v, want 25", got)
+	}
+	if got := cpuPercent(prev, prev); got != 0 {
+		t.Errorf("cpuPercent without elapsed time = %v, want 0", got)
+	}
+}
+
+func TestParseMemInfo(t *testing.T) {
+	data := []byte("MemTotal:        4028468 kB\nMemFree:          123456 kB\nMemAvailable:    3028468 kB\nBuffers:           1024 kB\n")
+	used, total, err := parseMemInfo(data)
+	if err != nil {
+		t.Fatalf("parseMemInfo: %v", err)
+	}
+	if total != 4028468*1024 || used != 1000000*1024 {
+		t.Errorf("used, total = %d, %d", used, total)
+	}
+
+	if _, _, err := parseMemInfo([]byte("MemTotal: 1024 kB\n")); err == nil {
+		t.Error("expected an error without MemAvailable")
+	}
+}
@@ -0,0 +1,44 @@
+package agent
+
+import (
+	"os"
+	"time"
+)
+
+// heartbeatPoll is how often the guest checks the host heartbeat file
+const heartbeatPoll = 10 * time.Second
+
+// heartbeatMonitor tracks the host heartbeat file. It compares file contents
+// against the guest's own clock, so host/guest clock skew doesn't matter.
+type heartbeatMonitor struct {
+	path       string
+	timeout    time.Duration
+	last       string
+	lastChange time.Time
+}
+
+// newHeartbeatMonitor starts monitoring path as of now
+func newHeartbeatMonitor(path string, timeout time.Duration, now time.Time) *heartbeatMonitor {
+	m := &heartbeatMonitor{path: path, timeout: timeout, lastChange: now}
+	m.last, _ = m.read()
+	return m
+}
+
+// read returns the current heartbeat value
+func (m *heartbeatMonitor) read() (string, bool) {
+	data, err := os.ReadFile(m.path)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// expired records any new heartbeat and reports whether none has been seen
+// within the timeout. A missing or unreadable file counts as no heartbeat.
+func (m *heartbeatMonitor) expired(now time.Time) bool {
+	if value, ok := m.read(); ok && value != m.last {
+		m.last = value
+		m.lastChange = now
+	}
+	return now.Sub(m.lastChange) >= m.timeout
+}
@@ -0,0 +1,51 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHeartbeatMonitor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "heartbeat")
+	write := func(v string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(v), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	start := time.Unix(1000, 0)
+	write("1")
+	m := newHeartbeatMonitor(path, time.Minute, start)
+
+	if m.expired(start.Add(30 * time.Second)) {
+		t.Error("Expired before timeout")
+	}
+
+	// A fresh heartbeat resets the timer
+	write("2")
+	if m.expired(start.Add(50 * time.Second)) {
+		t.Error("Expired right after a heartbeat")
+	}
+	if m.expired(start.Add(100 * time.Second)) {
+		t.Error("Expired less than a timeout after the last heartbeat")
+	}
+	if !m.expired(start.Add(110 * time.Second)) {
+		t.Error("Expected expiry a full timeout after the last heartbeat")
+	}
+}
+
+func TestHeartbeatMonitorMissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "heartbeat")
+	start := time.Unix(1000, 0)
+	m := newHeartbeatMonitor(path, time.Minute, start)
+
+	if m.expired(start.Add(59 * time.Second)) {
+		t.Error("Expired before timeout")
+	}
+	if !m.expired(start.Add(time.Minute)) {
+		t.Error("Expected expiry without any heartbeat")
+	}
+}
@@ -0,0 +1,13 @@
+package guest
+
+import "bytes"
+
+// ClaudeConfigDir is the session user's Claude configuration directory
+const ClaudeConfigDir = "/home/claude/.claude"
+
+// RestorePluginPaths points the guest paths the agent wrote into plugin
+// config files back at the host's Claude directory, for files synced back
+// to the host
+func RestorePluginPaths(data []byte, hostClaudeDir string) []byte {
+	return bytes.ReplaceAll(data, []byte(ClaudeConfigDir+"/"), []byte(hostClaudeDir+"/"))
+}
@@ -0,0 +1,11 @@
+package guest
+
+import "testing"
+
+func TestRestorePluginPaths(t *testing.T) {
+	in := `{"installLocation": "/home/claude/.claude/plugins/marketplaces/acme", "projectPath": "/home/claude/app"}`
+	want := `{"installLocation": "/Users/dev/.claude/plugins/marketplaces/acme", "projectPath": "/home/claude/app"}`
+	if got := string(RestorePluginPaths([]byte(in), "/Users/dev/.claude")); got != want {
+		t.Errorf("RestorePluginPaths = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,470 @@
+package guest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/faize-ai/faize/internal/network"
+	"github.com/faize-ai/faize/internal/session"
+)
+
+// AgentVersion is the version of the interface between the host and the
+// guest agent, such as the configuration it reads. The host refuses to boot
+// a rootfs whose agent reports another, which must be rebuilt.
+const AgentVersion = 1
+
+// Guest-side paths shared by the host and the guest agent
+const (
+	BootstrapDir  = "/mnt/bootstrap"             // faize-bootstrap VirtioFS share
+	AgentPath     = "/usr/local/bin/faize-agent" // guest agent baked into the rootfs
+	ConfigFile    = "config.json"                // agent configuration in the bootstrap dir
+	HeartbeatFile = "heartbeat"                  // touched by the host process that owns the VM
+	GuestIPFile   = "guest-ip"                   // guest IPv4 address, written after DHCP
+	ClaimFile     = "claim.json"                 // hands an idle warm VM its project
+	WarmRoot      = "/mnt/warm"                  // guest mount point of a warm VM's shared root or claimed shares
+	ScratchDir    = "/scratch"                   // guest mount point of the scratch disk (faize start --disk)
+	RootfsUpper   = "/mnt/rootfs-upper"          // read-only view of the rootfs overlay's writable layer (faize snapshot save)
+	BootLogFile   = "boot.log"                   // agent status messages, kept off the console
+	BootStageFile = "boot-stage"                 // current BootStage, for the host's status line
+	AllowFile     = "allow"                      // network specs added with faize allow, one per line
+	AllowedFile   = "allow-applied"              // number of AllowFile entries in effect
+	PackagesFile  = "guest-packages.txt"         // apk or apt packages installed during the session, "name version" per line
+	ExitFile      = "claude-exit"                // exit code of the interactive claude session, written when it exits
+	RunOutputFile = "run-output"                 // stdout of claude -p in a faize run session
+	RunErrorFile  = "run-error"                  // stderr of claude -p
+	RunExitFile   = "run-exit"                   // exit code of claude -p, written when it exits
+	TasksDir      = "tasks"                      // a directory per task of a task queue, with the Run* files
+	TaskNextFile  = "next"                       // written by the host in a task's directory once it recorded the task
+	StatsFile     = "stats"                      // guest CPU and memory usage as Stats JSON, rewritten every few seconds
+	CPUsFile      = "cpus"                       // how many vCPUs the guest keeps online (faize resize); all of them if missing
+	NotifyFile    = "notifications.jsonl"        // events from guest hooks (faize-notify), a Notification per line
+	SettingsFile  = "claude-settings.json"       // the session's Claude settings.json, saved at shutdown to sync back (claude.sync_settings)
+	SettingsBase  = "claude-settings-base.json"  // the host's settings.json when the session started, to tell what the session changed
+	ClaudeSyncDir = "claude-sync"                // skills and plugin files added or changed in the session, by path in ~/.claude (claude.sync_skills)
+	BuildDir      = "build"                      // a builder VM's rootfs build step, with the Build* files
+
+	SSHDir                = "ssh"                  // sshd keys in the bootstrap dir
+	SSHHostKeyFile        = "ssh_host_ed25519_key" // generated on the host so faize ssh can pin it
+	SSHAuthorizedKeysFile = "authorized_keys"      // the session's client public key
+)
+
+// Files of a builder VM's build step in BuildDir: the host writes the Alpine
+// minirootfs, the step's script and its input, and the guest writes the
+// step's output, its log and its exit code
+const (
+	BuildAlpineFile = "alpine.tar.gz"
+	BuildScriptFile = "step.sh"
+	BuildInputFile  = "stdin"
+	BuildOutputFile = "stdout"
+	BuildLogFile    = "log"
+	BuildExitFile   = "exit" // written last
+)
+
+// BuildSetupFailed is the exit code a builder VM reports when it couldn't
+// set up the build step, as opposed to the step failing
+const BuildSetupFailed = 125
+
+// Answers in TaskNextFile
+const (
+	TaskContinue = "continue" // run the next task
+	TaskStop     = "stop"     // stop the queue and shut down
+)
+
+// Task is one prompt of a task queue (faize run --tasks)
+type Task struct {
+	Name   string   `json:"name,omitempty"`
+	Prompt string   `json:"prompt"`
+	Args   []string `json:"args,omitempty"` // extra Claude arguments
+}
+
+// TaskDir returns the directory of the i-th task (from 0), relative to the
+// bootstrap dir
+func TaskDir(i int) string {
+	return filepath.Join(TasksDir, strconv.Itoa(i+1))
+}
+
+// Boot stages the agent records in BootStageFile while it sets up the session
+const (
+	BootMounting  = "mounting shares"
+	BootNetwork   = "configuring network"
+	BootWaiting   = "waiting for a project" // idle warm VM
+	BootPreparing = "preparing Claude"
+	BootReady     = "ready" // the session process is about to start
+)
+
+// BootWarningPrefix marks warnings in BootLogFile, after the timestamp
+const BootWarningPrefix = "warning: "
+
+// ConfigVersion is bumped when the agent configuration changes incompatibly
+const ConfigVersion = 1
+
+// Config is the session configuration the host hands to the guest agent.
+// It replaces the generated init script: the agent reads it from the bootstrap
+// share and performs mounts, network setup, and launch itself.
+type Config struct {
+	Version            int               `json:"version"`
+	ClaudeMode         bool              `json:"claude_mode"`
+	Mounts             []session.VMMount `json:"mounts"`
+	ProjectDir         string            `json:"project_dir"`
+	Network            *network.Policy   `json:"network,omitempty"`
+	PersistCredentials bool              `json:"persist_credentials,omitempty"`
+
+	// HeartbeatTimeout is how many seconds the guest tolerates an unchanged
+	// heartbeat file before cleaning up and powering off. Zero disables the watchdog.
+	HeartbeatTimeout int `json:"heartbeat_timeout,omitempty"`
+
+	// CaptureNetwork records guest traffic with tcpdump to rotating pcap files
+	// in the bootstrap share (network.CaptureFile)
+	CaptureNetwork bool `json:"capture_network,omitempty"`
+
+	// Warm boots the VM idle: the agent sets up the network and waits for a
+	// Claim before binding the project and launching Claude (faize warm)
+	Warm bool `json:"warm,omitempty"`
+
+	// SSH starts sshd on port 22 for the session user with the keys in SSHDir
+	SSH bool `json:"ssh,omitempty"`
+
+	// ScratchDisk is set when the VM has a second block device for
+	// ScratchDir; the agent formats it if it is blank and mounts it there
+	ScratchDisk bool `json:"scratch_disk,omitempty"`
+
+	// PersistHome is set when the project's home directory is shared as
+	// "home"; the agent seeds it from the rootfs if it is empty and mounts it
+	// over the claude user's home
+	PersistHome bool `json:"persist_home,omitempty"`
+
+	// SyncSettings saves the session's Claude settings.json to SettingsFile
+	// at shutdown, for the host to offer writing back to ~/.claude
+	SyncSettings bool `json:"sync_settings,omitempty"`
+
+	// SyncSkills saves the skills and plugin files added or changed in the
+	// session to ClaudeSyncDir at shutdown, for the host to offer copying
+	// back to ~/.claude
+	SyncSkills bool `json:"sync_skills,omitempty"`
+
+	// EgressPort is the host vsock port of the session's egress proxy, which
+	// enforces the domain allowlist by host name. The agent forwards a local
+	// HTTP proxy to it and the firewall only lets DNS out, or nothing with a
+	// DNSPort. Zero keeps the in-guest iptables allowlist.
+	EgressPort uint32 `json:"egress_port,omitempty"`
+
+	// DNSPort is the host vsock port of the session's DNS resolver, which
+	// applies the allowlist to name lookups. The agent relays dnsmasq's
+	// queries to it. Zero keeps querying public resolvers directly.
+	DNSPort uint32 `json:"dns_port,omitempty"`
+
+	// NetLimit caps the guest's network bandwidth in each direction, in bits
+	// per second, with tc on its interface. Zero is unlimited.
+	NetLimit uint64 `json:"net_limit,omitempty"`
+
+	// Resolvers replace the public DNS servers the guest queries and the
+	// firewall lets it reach (network.resolvers in the config)
+	Resolvers []string `json:"resolvers,omitempty"`
+
+	// Hosts are appended to /etc/hosts before the network comes up
+	Hosts []session.HostEntry `json:"hosts,omitempty"`
+
+	// Proxy is the upstream HTTP proxy URL that sessions get in HTTP_PROXY
+	// and HTTPS_PROXY, with NoProxy in NO_PROXY. With a restricted policy the
+	// firewall only lets traffic reach the proxy. Unset with an EgressPort,
+	// where the host egress proxy relays through it instead.
+	Proxy   string   `json:"proxy,omitempty"`
+	NoProxy []string `json:"no_proxy,omitempty"`
+
+	// CACerts are PEM files in the bootstrap share, relative to it, that are
+	// added to the guest's trusted CAs before the network comes up
+	CACerts []string `json:"ca_certs,omitempty"`
+
+	// StrictNetwork makes the in-guest allowlist accept only addresses the
+	// DNS forwarder returned for allowed names, and the policy's IP ranges,
+	// instead of addresses resolved at boot and TLS SNI matches. The host
+	// egress proxy already connects by name, so it only matters without one.
+	StrictNetwork bool `json:"strict_network,omitempty"`
+
+	// Prompt runs claude -p with this prompt on stdin instead of an
+	// interactive session, with PromptArgs as extra Claude arguments. Output
+	// goes to RunOutputFile and RunErrorFile (faize run).
+	Prompt     string   `json:"prompt,omitempty"`
+	PromptArgs []string `json:"prompt_args,omitempty"`
+
+	// Tasks runs claude -p once per task, one after another, writing each
+	// task's output to its TaskDir and waiting for the host's TaskNextFile
+	// before the next one (faize run --tasks)
+	Tasks []Task `json:"tasks,omitempty"`
+
+	// PostCreate are shell commands run as the claude user in the project
+	// before Claude starts: the project's devcontainer postCreateCommand
+	PostCreate []string `json:"post_create,omitempty"`
+}
+
+// NewConfig builds the agent configuration for a session
+func NewConfig(claudeMode bool, mounts []session.VMMount, projectDir string, policy *network.Policy, persistCredentials bool) *Config {
+	return &Config{
+		Version:            ConfigVersion,
+		ClaudeMode:         claudeMode,
+		Mounts:             mounts,
+		ProjectDir:         projectDir,
+		Network:            policy,
+		PersistCredentials: persistCredentials,
+	}
+}
+
+// WorkDir returns the guest directory the session starts in
+func (c *Config) WorkDir() string {
+	if c.ProjectDir == "" {
+		return "/workspace"
+	}
+	return c.ProjectDir
+}
+
+// WriteConfig writes the agent configuration to the bootstrap directory
+func WriteConfig(bootstrapDir string, cfg *Config) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal agent config: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(bootstrapDir, ConfigFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to write agent config: %w", err)
+	}
+	return nil
+}
+
+// ReadConfig reads an agent configuration file
+func ReadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read agent config: %w", err)
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse agent config: %w", err)
+	}
+	if cfg.Version > ConfigVersion {
+		return nil, fmt.Errorf("agent config version %d is newer than supported version %d", cfg.Version, ConfigVersion)
+	}
+	return &cfg, nil
+}
+
+// Share is a VirtioFS share the host added to a warm VM for its claim, to
+// mount at Path under WarmRoot
+type Share struct {
+	Tag  string `json:"tag"`
+	Path string `json:"path"`
+}
+
+// Bind maps a directory under a warm VM's shared root or claimed shares
+// (WarmRoot) onto a guest path
+type Bind struct {
+	Source   string `json:"source"` // guest path under WarmRoot
+	Target   string `json:"target"`
+	ReadOnly bool   `json:"read_only,omitempty"`
+}
+
+// Claim assigns a project to an idle warm VM. The host writes it to the
+// bootstrap directory; the agent mounts its shares, binds the mounts and
+// launches the session. A VM sharing a root gets no shares.
+type Claim struct {
+	ProjectDir   string  `json:"project_dir"`
+	Shares       []Share `json:"shares,omitempty"`
+	Binds        []Bind  `json:"binds"`
+	SyncSettings bool    `json:"sync_settings,omitempty"` // see Config.SyncSettings
+	SyncSkills   bool    `json:"sync_skills,omitempty"`   // see Config.SyncSkills
+}
+
+// WriteClaim writes a claim to the bootstrap directory. The file is renamed
+// into place so the agent never reads a partial claim.
+func WriteClaim(bootstrapDir string, claim *Claim) error {
+	data, err := json.MarshalIndent(claim, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal claim: %w", err)
+	}
+	tmp := filepath.Join(bootstrapDir, ClaimFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write claim: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(bootstrapDir, ClaimFile)); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write claim: %w", err)
+	}
+	return nil
+}
+
+// ReadClaim reads a claim file
+func ReadClaim(path string) (*Claim, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read claim: %w", err)
+	}
+	var claim Claim
+	if err := json.Unmarshal(data, &claim); err != nil {
+		return nil, fmt.Errorf("failed to parse claim: %w", err)
+	}
+	if claim.ProjectDir == "" {
+		return nil, fmt.Errorf("claim has no project directory")
+	}
+	for _, s := range claim.Shares {
+		if s.Tag == "" {
+			return nil, fmt.Errorf("claim share has no tag")
+		}
+		if !strings.HasPrefix(filepath.Clean(s.Path), WarmRoot+"/") {
+			return nil, fmt.Errorf("claim share %s is outside %s", s.Path, WarmRoot)
+		}
+	}
+	for _, b := range claim.Binds {
+		if src := filepath.Clean(b.Source); src != WarmRoot && !strings.HasPrefix(src, WarmRoot+"/") {
+			return nil, fmt.Errorf("claim bind %s is outside %s", b.Source, WarmRoot)
+		}
+	}
+	return &claim, nil
+}
+
+// AppendAllow adds a network spec to the allowlist additions in the
+// bootstrap directory and returns how many entries the file now holds
+func AppendAllow(bootstrapDir, spec string) (int, error) {
+	f, err := os.OpenFile(filepath.Join(bootstrapDir, AllowFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open allowlist additions: %w", err)
+	}
+	_, err = fmt.Fprintln(f, strings.TrimSpace(spec))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to write allowlist additions: %w", err)
+	}
+	specs, err := ReadAllow(bootstrapDir)
+	return len(specs), err
+}
+
+// ReadAllow returns the allowlist additions in the bootstrap directory, in
+// the order they were added. A missing file has none.
+func ReadAllow(bootstrapDir string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(bootstrapDir, AllowFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read allowlist additions: %w", err)
+	}
+	var specs []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			specs = append(specs, line)
+		}
+	}
+	return specs, nil
+}
+
+// WriteAllowed records that the first n allowlist additions are in effect
+func WriteAllowed(bootstrapDir string, n int) error {
+	return os.WriteFile(filepath.Join(bootstrapDir, AllowedFile), []byte(strconv.Itoa(n)), 0644)
+}
+
+// ReadAllowed returns how many allowlist additions are in effect
+func ReadAllowed(bootstrapDir string) int {
+	data, err := os.ReadFile(filepath.Join(bootstrapDir, AllowedFile))
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return n
+}
+
+// WriteCPUs records how many vCPUs the guest should keep online
+func WriteCPUs(bootstrapDir string, n int) error {
+	if err := os.WriteFile(filepath.Join(bootstrapDir, CPUsFile), []byte(strconv.Itoa(n)), 0644); err != nil {
+		return fmt.Errorf("failed to write CPU count: %w", err)
+	}
+	return nil
+}
+
+// ReadCPUs returns how many vCPUs the guest should keep online, or 0 for all
+func ReadCPUs(bootstrapDir string) int {
+	data, err := os.ReadFile(filepath.Join(bootstrapDir, CPUsFile))
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return max(n, 0)
+}
+
+// BootstrapScript returns the init.sh executed by the rootfs /init.
+// It only hands off to the guest agent; all session logic lives in the agent.
+// Without one the VM powers off rather than leave a root shell on the
+// console; the host checks the rootfs's agent before boot.
+func BootstrapScript() string {
+	return fmt.Sprintf(`#!/bin/sh
+# Faize bootstrap: hand off to the guest agent
+if [ -x %[1]s ]; then
+  exec %[1]s -config %[2]s
+fi
+echo "faize: guest agent not found in rootfs - rebuild it with 'faize claude rebuild'"
+sync
+exec poweroff -f
+`, AgentPath, filepath.Join(BootstrapDir, ConfigFile))
+}
+
+// BuilderScript returns the init.sh of a builder VM, which boots the base
+// rootfs to run a rootfs build step instead of a session. It unpacks the
+// Alpine minirootfs on the scratch disk, runs the step's script there as
+// root with chroot, records its exit code and powers off. The base rootfs
+// only has busybox, so the first Alpine, on tmpfs, gets DHCP going and
+// formats the scratch disk.
+func BuilderScript() string {
+	return fmt.Sprintf(`#!/bin/sh
+# Faize builder VM: run one rootfs build step and power off
+export PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin
+bb=/bin/busybox
+job=%[1]s
+log=$job/%[2]s
+
+finish() {
+  echo "$1" > "$job/%[3]s"
+  $bb sync
+  $bb poweroff -f
+}
+
+fail() {
+  echo "faize builder: $*" >> "$log"
+  finish %[4]d
+}
+
+# alpine unpacks Alpine at $1 with the virtual filesystems its tools need
+alpine() {
+  mkdir -p "$1" &&
+    $bb tar -xzf "$job/%[5]s" -C "$1" &&
+    mount -t proc proc "$1/proc" &&
+    mount -t sysfs sys "$1/sys" &&
+    mount -t devtmpfs dev "$1/dev"
+}
+
+# TLS needs the host's clock
+$bb date -s "@$(cat %[6]s/hosttime)" >/dev/null 2>&1
+
+alpine /tmp/alpine || fail "failed to unpack Alpine"
+$bb ip link set lo up
+for dev in /sys/class/net/*; do
+  iface=${dev##*/}
+  [ "$iface" = lo ] && continue
+  $bb ip link set "$iface" up
+  $bb chroot /tmp/alpine udhcpc -i "$iface" -n -q -t 10 >> "$log" 2>&1 || fail "DHCP failed on $iface"
+  break
+done
+grep -q nameserver /tmp/alpine/etc/resolv.conf 2>/dev/null || echo "nameserver 8.8.8.8" > /tmp/alpine/etc/resolv.conf
+$bb chroot /tmp/alpine apk add --no-cache e2fsprogs >> "$log" 2>&1 || fail "failed to install e2fsprogs"
+$bb chroot /tmp/alpine mkfs.ext4 -q -F /dev/vdb >> "$log" 2>&1 || fail "failed to format the scratch disk"
+
+mkdir -p /build
+mount -t ext4 /dev/vdb /build || fail "failed to mount the scratch disk"
+alpine /build || fail "failed to unpack Alpine"
+$bb cp /tmp/alpine/etc/resolv.conf /build/etc/resolv.conf
+$bb cp "$job/%[7]s" /build/tmp/faize-step.sh
+$bb chroot /build /bin/sh /tmp/faize-step.sh < "$job/%[8]s" > "$job/%[9]s" 2>> "$log"
+finish $?
+`, filepath.Join(BootstrapDir, BuildDir), BuildLogFile, BuildExitFile, BuildSetupFailed,
+		BuildAlpineFile, BootstrapDir, BuildScriptFile, BuildInputFile, BuildOutputFile)
+}
@@ -0,0 +1,185 @@
+package guest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/faize-ai/faize/internal/network"
+	"github.com/faize-ai/faize/internal/session"
+)
+
+func TestWriteReadConfig(t *testing.T) {
+	dir := t.TempDir()
+	mounts := []session.VMMount{
+		{Source: "/host/project", Target: "/Users/me/project", Tag: "mount0"},
+		{Source: "/host/data", Target: "/data", ReadOnly: true, Tag: "mount1"},
+	}
+	policy := &network.Policy{Domains: []string{"api.anthropic.com"}, Wildcards: []string{"*.github.com"}}
+
+	if err := WriteConfig(dir, NewConfig(true, mounts, "/Users/me/project", policy, true)); err != nil {
+		t.Fatalf("WriteConfig: %v", err)
+	}
+
+	cfg, err := ReadConfig(filepath.Join(dir, ConfigFile))
+	if err != nil {
+		t.Fatalf("ReadConfig: %v", err)
+	}
+	if cfg.Version != ConfigVersion {
+		t.Errorf("Version = %d, want %d", cfg.Version, ConfigVersion)
+	}
+	if !cfg.ClaudeMode || !cfg.PersistCredentials {
+		t.Error("Expected claude mode and credential persistence to round-trip")
+	}
+	if len(cfg.Mounts) != 2 || !cfg.Mounts[1].ReadOnly || cfg.Mounts[1].Tag != "mount1" {
+		t.Errorf("Mounts did not round-trip: %+v", cfg.Mounts)
+	}
+	if cfg.Network == nil || cfg.Network.Domains[0] != "api.anthropic.com" || cfg.Network.Wildcards[0] != "*.github.com" {
+		t.Errorf("Network policy did not round-trip: %+v", cfg.Network)
+	}
+	if cfg.WorkDir() != "/Users/me/project" {
+		t.Errorf("WorkDir = %q", cfg.WorkDir())
+	}
+}
+
+func TestConfigWorkDirDefault(t *testing.T) {
+	cfg := NewConfig(false, nil, "", nil, false)
+	if cfg.WorkDir() != "/workspace" {
+		t.Errorf("WorkDir = %q, want /workspace", cfg.WorkDir())
+	}
+}
+
+func TestReadConfigRejectsNewerVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ConfigFile)
+	if err := os.WriteFile(path, []byte(`{"version": 99}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadConfig(path); err == nil {
+		t.Error("Expected error for newer config version")
+	}
+}
+
+func TestWriteReadClaim(t *testing.T) {
+	dir := t.TempDir()
+	claim := &Claim{
+		ProjectDir: "/Users/me/code/app",
+		Shares:     []Share{{Tag: "warm0", Path: "/mnt/warm/0"}},
+		Binds: []Bind{
+			{Source: "/mnt/warm/code/app", Target: "/Users/me/code/app"},
+			{Source: "/mnt/warm/code/.git", Target: "/Users/me/code/.git", ReadOnly: true},
+		},
+	}
+	if err := WriteClaim(dir, claim); err != nil {
+		t.Fatalf("WriteClaim: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ClaimFile+".tmp")); !os.IsNotExist(err) {
+		t.Error("Expected the temporary claim file to be renamed away")
+	}
+
+	got, err := ReadClaim(filepath.Join(dir, ClaimFile))
+	if err != nil {
+		t.Fatalf("ReadClaim: %v", err)
+	}
+	if got.ProjectDir != claim.ProjectDir || len(got.Shares) != 1 || len(got.Binds) != 2 || !got.Binds[1].ReadOnly {
+		t.Errorf("Claim did not round-trip: %+v", got)
+	}
+}
+
+func TestReadClaimRejectsInvalid(t *testing.T) {
+	tests := map[string]string{
+		"no project":     `{"binds": []}`,
+		"outside root":   `{"project_dir": "/p", "binds": [{"source": "/etc", "target": "/p"}]}`,
+		"escapes root":   `{"project_dir": "/p", "binds": [{"source": "/mnt/warm/../../etc", "target": "/p"}]}`,
+		"prefix sibling": `{"project_dir": "/p", "binds": [{"source": "/mnt/warmer", "target": "/p"}]}`,
+		"share outside":  `{"project_dir": "/p", "shares": [{"tag": "warm0", "path": "/etc"}]}`,
+		"share as root":  `{"project_dir": "/p", "shares": [{"tag": "warm0", "path": "/mnt/warm"}]}`,
+		"untagged share": `{"project_dir": "/p", "shares": [{"path": "/mnt/warm/0"}]}`,
+		"malformed json": `{`,
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), ClaimFile)
+			if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ReadClaim(path); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
+
+func TestBootstrapScript(t *testing.T) {
+	script := BootstrapScript()
+
+	if !strings.HasPrefix(script, "#!/bin/sh\n") {
+		t.Error("Missing shebang")
+	}
+	if !strings.Contains(script, "exec /usr/local/bin/faize-agent -config /mnt/bootstrap/config.json") {
+		t.Error("Missing agent hand-off")
+	}
+	if !strings.Contains(script, "faize claude rebuild") {
+		t.Error("Missing rebuild hint for images without the agent")
+	}
+	if strings.Contains(script, "exec /bin/sh") {
+		t.Error("Images without the agent must not fall back to a shell")
+	}
+}
+
+func TestBuilderScript(t *testing.T) {
+	script := BuilderScript()
+
+	if !strings.HasPrefix(script, "#!/bin/sh\n") {
+		t.Error("Missing shebang")
+	}
+	for _, want := range []string{
+		"job=/mnt/bootstrap/build\n",
+		`$bb tar -xzf "$job/alpine.tar.gz"`,
+		`< "$job/stdin" > "$job/stdout" 2>> "$log"`,
+		`echo "$1" > "$job/exit"`,
+		"finish 125",
+		"$bb poweroff -f",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("Builder script missing %q", want)
+		}
+	}
+	if strings.Contains(script, "%!") {
+		t.Error("Builder script has a formatting error")
+	}
+}
+
+func TestAllowAdditions(t *testing.T) {
+	dir := t.TempDir()
+	if specs, err := ReadAllow(dir); err != nil || specs != nil {
+		t.Fatalf("ReadAllow on empty dir = %v, %v", specs, err)
+	}
+	if n := ReadAllowed(dir); n != 0 {
+		t.Errorf("ReadAllowed on empty dir = %d, want 0", n)
+	}
+
+	for i, spec := range []string{"example.com", " *.corp.dev\n"} {
+		n, err := AppendAllow(dir, spec)
+		if err != nil {
+			t.Fatalf("AppendAllow: %v", err)
+		}
+		if n != i+1 {
+			t.Errorf("AppendAllow returned %d entries, want %d", n, i+1)
+		}
+	}
+	specs, err := ReadAllow(dir)
+	if err != nil {
+		t.Fatalf("ReadAllow: %v", err)
+	}
+	if strings.Join(specs, ",") != "example.com,*.corp.dev" {
+		t.Errorf("ReadAllow = %v", specs)
+	}
+
+	if err := WriteAllowed(dir, 2); err != nil {
+		t.Fatalf("WriteAllowed: %v", err)
+	}
+	if n := ReadAllowed(dir); n != 2 {
+		t.Errorf("ReadAllowed = %d, want 2", n)
+	}
+}
@@ -0,0 +1,127 @@
+package guest
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ExecPort is the vsock port the guest agent accepts exec requests on
+const ExecPort = 1024
+
+// Frame kinds on the exec control channel. The client sends one FrameRequest;
+// the agent streams output frames and finishes with FrameExit or FrameError.
+const (
+	FrameRequest byte = iota + 1 // JSON ExecRequest
+	FrameStdout                  // raw stdout bytes
+	FrameStderr                  // raw stderr bytes
+	FrameExit                    // 4-byte big-endian exit code
+	FrameError                   // error message; the command did not run
+)
+
+// maxFrameSize bounds a single frame so a corrupt header can't exhaust memory
+const maxFrameSize = 1 << 20
+
+// ExecRequest is a command to run inside the guest
+type ExecRequest struct {
+	Args []string `json:"args"`
+	Dir  string   `json:"dir,omitempty"`  // defaults to the session work dir
+	Env  []string `json:"env,omitempty"`  // KEY=VALUE, added to the default environment
+	User string   `json:"user,omitempty"` // defaults to the session user
+}
+
+// WriteFrame writes a single frame: kind, 4-byte big-endian length, payload
+func WriteFrame(w io.Writer, kind byte, payload []byte) error {
+	if len(payload) > maxFrameSize {
+		return fmt.Errorf("frame too large: %d bytes", len(payload))
+	}
+	header := make([]byte, 5)
+	header[0] = kind
+	binary.BigEndian.PutUint32(header[1:], uint32(len(payload)))
+	if _, err := w.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ReadFrame reads a single frame written by WriteFrame
+func ReadFrame(r io.Reader) (kind byte, payload []byte, err error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > maxFrameSize {
+		return 0, nil, fmt.Errorf("frame too large: %d bytes", size)
+	}
+	payload = make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return header[0], payload, nil
+}
+
+// WriteExitFrame reports the command's exit code
+func WriteExitFrame(w io.Writer, code int) error {
+	payload := make([]byte, 4)
+	binary.BigEndian.PutUint32(payload, uint32(int32(code)))
+	return WriteFrame(w, FrameExit, payload)
+}
+
+// RunExec sends req over conn and copies the command's output to stdout and
+// stderr. It returns the command's exit code once the guest reports it.
+func RunExec(conn io.ReadWriter, req *ExecRequest, stdout, stderr io.Writer) (int, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return -1, fmt.Errorf("failed to marshal exec request: %w", err)
+	}
+	if err := WriteFrame(conn, FrameRequest, data); err != nil {
+		return -1, fmt.Errorf("failed to send exec request: %w", err)
+	}
+
+	for {
+		kind, payload, err := ReadFrame(conn)
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return -1, fmt.Errorf("connection closed before the command finished")
+			}
+			return -1, fmt.Errorf("failed to read exec output: %w", err)
+		}
+		switch kind {
+		case FrameStdout:
+			_, _ = stdout.Write(payload)
+		case FrameStderr:
+			_, _ = stderr.Write(payload)
+		case FrameExit:
+			if len(payload) != 4 {
+				return -1, fmt.Errorf("malformed exit frame")
+			}
+			return int(int32(binary.BigEndian.Uint32(payload))), nil
+		case FrameError:
+			return -1, errors.New(string(payload))
+		default:
+			return -1, fmt.Errorf("unexpected frame kind %d", kind)
+		}
+	}
+}
+
+// ReadExecRequest reads the client's request frame
+func ReadExecRequest(r io.Reader) (*ExecRequest, error) {
+	kind, payload, err := ReadFrame(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read exec request: %w", err)
+	}
+	if kind != FrameRequest {
+		return nil, fmt.Errorf("expected exec request, got frame kind %d", kind)
+	}
+	var req ExecRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return nil, fmt.Errorf("failed to parse exec request: %w", err)
+	}
+	if len(req.Args) == 0 {
+		return nil, fmt.Errorf("exec request has no command")
+	}
+	return &req, nil
+}
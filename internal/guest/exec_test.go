@@ -0,0 +1,108 @@
+package guest
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestFrameRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteFrame(&buf, FrameStdout, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFrame(&buf, FrameStderr, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	kind, payload, err := ReadFrame(&buf)
+	if err != nil || kind != FrameStdout || string(payload) != "hello" {
+		t.Errorf("ReadFrame = %d, %q, %v", kind, payload, err)
+	}
+	kind, payload, err = ReadFrame(&buf)
+	if err != nil || kind != FrameStderr || len(payload) != 0 {
+		t.Errorf("ReadFrame = %d, %q, %v", kind, payload, err)
+	}
+}
+
+func TestReadFrameRejectsOversizedFrame(t *testing.T) {
+	header := []byte{FrameStdout, 0xff, 0xff, 0xff, 0xff}
+	if _, _, err := ReadFrame(bytes.NewReader(header)); err == nil {
+		t.Error("Expected error for oversized frame")
+	}
+}
+
+func TestReadExecRequest(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteFrame(&buf, FrameRequest, []byte(`{"args":["ls","-la"],"user":"root"}`)); err != nil {
+		t.Fatal(err)
+	}
+	req, err := ReadExecRequest(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(req.Args) != 2 || req.Args[0] != "ls" || req.User != "root" {
+		t.Errorf("Unexpected request: %+v", req)
+	}
+
+	buf.Reset()
+	_ = WriteFrame(&buf, FrameRequest, []byte(`{"args":[]}`))
+	if _, err := ReadExecRequest(&buf); err == nil {
+		t.Error("Expected error for empty command")
+	}
+}
+
+// fakeGuest answers one exec request with the given frames
+func fakeGuest(t *testing.T, conn net.Conn, frames func(conn net.Conn)) {
+	t.Helper()
+	go func() {
+		defer func() { _ = conn.Close() }()
+		if _, err := ReadExecRequest(conn); err != nil {
+			return
+		}
+		frames(conn)
+	}()
+}
+
+func TestRunExec(t *testing.T) {
+	client, server := net.Pipe()
+	defer func() { _ = client.Close() }()
+	fakeGuest(t, server, func(conn net.Conn) {
+		_ = WriteFrame(conn, FrameStdout, []byte("out"))
+		_ = WriteFrame(conn, FrameStderr, []byte("err"))
+		_ = WriteExitFrame(conn, 3)
+	})
+
+	var stdout, stderr bytes.Buffer
+	code, err := RunExec(client, &ExecRequest{Args: []string{"true"}}, &stdout, &stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != 3 || stdout.String() != "out" || stderr.String() != "err" {
+		t.Errorf("RunExec = %d, stdout %q, stderr %q", code, stdout.String(), stderr.String())
+	}
+}
+
+func TestRunExecError(t *testing.T) {
+	client, server := net.Pipe()
+	defer func() { _ = client.Close() }()
+	fakeGuest(t, server, func(conn net.Conn) {
+		_ = WriteFrame(conn, FrameError, []byte("unknown user \"nobody\""))
+	})
+
+	_, err := RunExec(client, &ExecRequest{Args: []string{"true"}}, &bytes.Buffer{}, &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "unknown user") {
+		t.Errorf("Expected guest error, got %v", err)
+	}
+}
+
+func TestRunExecConnectionClosed(t *testing.T) {
+	client, server := net.Pipe()
+	defer func() { _ = client.Close() }()
+	fakeGuest(t, server, func(conn net.Conn) {})
+
+	if _, err := RunExec(client, &ExecRequest{Args: []string{"true"}}, &bytes.Buffer{}, &bytes.Buffer{}); err == nil {
+		t.Error("Expected error when the guest closes without an exit code")
+	}
+}
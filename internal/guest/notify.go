@@ -0,0 +1,83 @@
+package guest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// maxNotificationMessage is the longest notification message kept
+const maxNotificationMessage = 500
+
+// Notification is an event a guest hook reported for the host to surface,
+// such as a finished task or a pending approval
+type Notification struct {
+	Time    time.Time `json:"time"`
+	Event   string    `json:"event"` // e.g. "task-complete", "needs-approval", or a Claude hook name
+	Message string    `json:"message,omitempty"`
+}
+
+// AppendNotification adds a notification to NotifyFile in the bootstrap
+// directory. Each is written in one append, so concurrent hooks don't
+// interleave.
+func AppendNotification(bootstrapDir string, n Notification) error {
+	if len(n.Message) > maxNotificationMessage {
+		n.Message = n.Message[:maxNotificationMessage]
+	}
+	data, err := json.Marshal(n)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+	f, err := os.OpenFile(filepath.Join(bootstrapDir, NotifyFile), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open notifications: %w", err)
+	}
+	_, err = f.Write(append(data, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write notification: %w", err)
+	}
+	return nil
+}
+
+// ReadNotifications returns the notifications in NotifyFile from offset on
+// and the offset after the last complete line, to read new ones from later.
+// A missing file has none.
+func ReadNotifications(bootstrapDir string, offset int64) ([]Notification, int64, error) {
+	f, err := os.Open(filepath.Join(bootstrapDir, NotifyFile))
+	if os.IsNotExist(err) {
+		return nil, offset, nil
+	} else if err != nil {
+		return nil, offset, err
+	}
+	defer func() { _ = f.Close() }()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, offset, err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, offset, err
+	}
+
+	var notes []Notification
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		line := data[:i]
+		data = data[i+1:]
+		offset += int64(i + 1)
+		var n Notification
+		if err := json.Unmarshal(line, &n); err == nil && n.Event != "" {
+			notes = append(notes, n)
+		}
+	}
+	return notes, offset, nil
+}
@@ -0,0 +1,49 @@
+package guest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNotifications(t *testing.T) {
+	dir := t.TempDir()
+
+	notes, offset, err := ReadNotifications(dir, 0)
+	if err != nil || len(notes) != 0 || offset != 0 {
+		t.Fatalf("ReadNotifications without a file = %v, %d, %v", notes, offset, err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	if err := AppendNotification(dir, Notification{Time: now, Event: "task-complete", Message: "tests pass"}); err != nil {
+		t.Fatalf("AppendNotification: %v", err)
+	}
+	if err := AppendNotification(dir, Notification{Time: now, Event: "needs-approval", Message: strings.Repeat("x", 1000)}); err != nil {
+		t.Fatalf("AppendNotification: %v", err)
+	}
+
+	notes, offset, err = ReadNotifications(dir, 0)
+	if err != nil {
+		t.Fatalf("ReadNotifications: %v", err)
+	}
+	if len(notes) != 2 || notes[0].Event != "task-complete" || notes[0].Message != "tests pass" || !notes[0].Time.Equal(now) {
+		t.Fatalf("notes = %+v", notes)
+	}
+	if len(notes[1].Message) != maxNotificationMessage {
+		t.Errorf("long message kept %d bytes, want %d", len(notes[1].Message), maxNotificationMessage)
+	}
+
+	// A partial line is left for the next read
+	f, err := os.OpenFile(filepath.Join(dir, NotifyFile), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(`{"event":"partial"`)
+	_ = f.Close()
+	notes, next, err := ReadNotifications(dir, offset)
+	if err != nil || len(notes) != 0 || next != offset {
+		t.Errorf("ReadNotifications after the last line = %v, %d, %v", notes, next, err)
+	}
+}
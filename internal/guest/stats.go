@@ -0,0 +1,48 @@
+package guest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Stats is the guest's resource usage, sampled by the agent
+type Stats struct {
+	Time     time.Time `json:"time"`
+	CPU      float64   `json:"cpu"`       // percent of all vCPUs busy since the previous sample
+	MemUsed  uint64    `json:"mem_used"`  // bytes in use, not counting reclaimable caches
+	MemTotal uint64    `json:"mem_total"` // bytes
+}
+
+// WriteStats records stats in the bootstrap directory. The file is renamed
+// into place so the host never reads a partial sample.
+func WriteStats(bootstrapDir string, stats *Stats) error {
+	data, err := json.Marshal(stats)
+	if err != nil {
+		return fmt.Errorf("failed to marshal stats: %w", err)
+	}
+	tmp := filepath.Join(bootstrapDir, StatsFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write stats: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(bootstrapDir, StatsFile)); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write stats: %w", err)
+	}
+	return nil
+}
+
+// ReadStats reads the latest stats from a session's bootstrap directory
+func ReadStats(bootstrapDir string) (*Stats, error) {
+	data, err := os.ReadFile(filepath.Join(bootstrapDir, StatsFile))
+	if err != nil {
+		return nil, err
+	}
+	var stats Stats
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return nil, fmt.Errorf("failed to parse stats: %w", err)
+	}
+	return &stats, nil
+}
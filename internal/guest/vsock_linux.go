@@ -0,0 +1,54 @@
+//go:build linux
+
+package guest
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// ListenVsock listens for vsock stream connections on port from any CID
+func ListenVsock(port uint32) (int, error) {
+	fd, err := unix.Socket(unix.AF_VSOCK, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return -1, fmt.Errorf("failed to create vsock socket: %w", err)
+	}
+	if err := unix.Bind(fd, &unix.SockaddrVM{CID: unix.VMADDR_CID_ANY, Port: port}); err != nil {
+		_ = unix.Close(fd)
+		return -1, fmt.Errorf("failed to bind vsock port %d: %w", port, err)
+	}
+	if err := unix.Listen(fd, 16); err != nil {
+		_ = unix.Close(fd)
+		return -1, fmt.Errorf("failed to listen on vsock port %d: %w", port, err)
+	}
+	return fd, nil
+}
+
+// AcceptVsock accepts a connection on a listener returned by ListenVsock
+func AcceptVsock(listener int) (*os.File, error) {
+	fd, _, err := unix.Accept4(listener, unix.SOCK_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	return os.NewFile(uintptr(fd), "vsock"), nil
+}
+
+// DialVsock connects to port on the VM with the given context ID
+func DialVsock(cid, port uint32) (*os.File, error) {
+	fd, err := unix.Socket(unix.AF_VSOCK, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create vsock socket: %w", err)
+	}
+	if err := unix.Connect(fd, &unix.SockaddrVM{CID: cid, Port: port}); err != nil {
+		_ = unix.Close(fd)
+		return nil, fmt.Errorf("failed to connect to vsock %d:%d: %w", cid, port, err)
+	}
+	// Non-blocking so the runtime poller can interrupt reads on Close
+	if err := unix.SetNonblock(fd, true); err != nil {
+		_ = unix.Close(fd)
+		return nil, fmt.Errorf("failed to configure vsock socket: %w", err)
+	}
+	return os.NewFile(uintptr(fd), "vsock"), nil
+}
@@ -0,0 +1,217 @@
+// Package mcp serves tools over the Model Context Protocol: JSON-RPC 2.0
+// messages, one per line, on stdin and stdout.
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ProtocolVersion is the MCP revision the server implements
+const ProtocolVersion = "2024-11-05"
+
+// JSON-RPC error codes
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+// Tool is a function the client can call
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"inputSchema"` // a JSON Schema object
+
+	// Handler runs the tool with its arguments and returns its text result.
+	// An error is reported to the client as a failed tool call.
+	Handler func(ctx context.Context, args json.RawMessage) (string, error) `json:"-"`
+}
+
+// Server answers MCP requests with its tools
+type Server struct {
+	name    string
+	version string
+	tools   []Tool
+
+	mu       sync.Mutex // guards enc and calls
+	enc      *json.Encoder
+	calls    map[string]*call // tool calls in flight, by request ID
+	inFlight sync.WaitGroup
+}
+
+// call is a tool call in flight, which the client may cancel
+type call struct {
+	cancel    context.CancelFunc
+	cancelled bool
+}
+
+// NewServer returns a server identifying itself as name and version
+func NewServer(name, version string, tools []Tool) *Server {
+	return &Server{name: name, version: version, tools: tools}
+}
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"` // absent for notifications
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// content is a text item of a tool result
+type content struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// Serve reads requests from r and writes responses to w until r ends or ctx
+// is canceled. Tool calls run concurrently and answer in the order they
+// finish; a client cancels one with notifications/cancelled. Serve returns
+// once the calls in flight have finished.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	s.enc = json.NewEncoder(w)
+	s.calls = make(map[string]*call)
+	defer s.inFlight.Wait()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var req request
+		if err := json.Unmarshal(line, &req); err != nil {
+			s.write(response{ID: json.RawMessage("null"), Error: &rpcError{codeParseError, "invalid JSON: " + err.Error()}})
+			continue
+		}
+		if len(req.ID) == 0 {
+			// Notifications, such as notifications/initialized, need no answer
+			if req.Method == "notifications/cancelled" {
+				s.cancel(req.Params)
+			}
+			continue
+		}
+		if req.Method == "tools/call" {
+			s.startCall(ctx, req)
+			continue
+		}
+		result, rpcErr := s.handle(ctx, req)
+		s.write(response{ID: req.ID, Result: result, Error: rpcErr})
+	}
+	return scanner.Err()
+}
+
+// startCall runs a tool call in the background and answers it unless the
+// client cancels it first
+func (s *Server) startCall(ctx context.Context, req request) {
+	callCtx, cancel := context.WithCancel(ctx)
+	c := &call{cancel: cancel}
+	id := string(req.ID)
+	s.mu.Lock()
+	s.calls[id] = c
+	s.mu.Unlock()
+
+	s.inFlight.Add(1)
+	go func() {
+		defer s.inFlight.Done()
+		defer cancel()
+		result, rpcErr := s.handle(callCtx, req)
+
+		s.mu.Lock()
+		delete(s.calls, id)
+		cancelled := c.cancelled
+		s.mu.Unlock()
+		// A cancelled request gets no answer
+		if !cancelled {
+			s.write(response{ID: req.ID, Result: result, Error: rpcErr})
+		}
+	}()
+}
+
+// cancel cancels the tool call a notifications/cancelled names, if it is
+// still running
+func (s *Server) cancel(params json.RawMessage) {
+	var p struct {
+		RequestID json.RawMessage `json:"requestId"`
+	}
+	if json.Unmarshal(params, &p) != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.calls[string(p.RequestID)]; ok {
+		c.cancelled = true
+		c.cancel()
+	}
+}
+
+// handle runs one request
+func (s *Server) handle(ctx context.Context, req request) (any, *rpcError) {
+	if req.JSONRPC != "2.0" {
+		return nil, &rpcError{codeInvalidRequest, "jsonrpc must be 2.0"}
+	}
+	switch req.Method {
+	case "initialize":
+		return map[string]any{
+			"protocolVersion": ProtocolVersion,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]string{"name": s.name, "version": s.version},
+		}, nil
+	case "ping":
+		return map[string]any{}, nil
+	case "tools/list":
+		return map[string]any{"tools": s.tools}, nil
+	case "tools/call":
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &rpcError{codeInvalidParams, "invalid params: " + err.Error()}
+		}
+		for _, t := range s.tools {
+			if t.Name != params.Name {
+				continue
+			}
+			args := params.Arguments
+			if len(args) == 0 {
+				args = json.RawMessage("{}")
+			}
+			text, err := t.Handler(ctx, args)
+			if err != nil {
+				return map[string]any{"content": []content{{"text", err.Error()}}, "isError": true}, nil
+			}
+			return map[string]any{"content": []content{{"text", text}}}, nil
+		}
+		return nil, &rpcError{codeInvalidParams, fmt.Sprintf("unknown tool %q", params.Name)}
+	}
+	return nil, &rpcError{codeMethodNotFound, "method not found: " + req.Method}
+}
+
+func (s *Server) write(resp response) {
+	resp.JSONRPC = "2.0"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_ = s.enc.Encode(resp)
+}
@@ -0,0 +1,152 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// serve runs a server on input and returns its responses by ID; the parse
+// error's is "<nil>"
+func serve(t *testing.T, tools []Tool, input ...string) map[string]map[string]any {
+	t.Helper()
+	var out bytes.Buffer
+	s := NewServer("faize", "test", tools)
+	if err := s.Serve(context.Background(), strings.NewReader(strings.Join(input, "\n")+"\n"), &out); err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+	return decodeResponses(t, &out)
+}
+
+func decodeResponses(t *testing.T, r io.Reader) map[string]map[string]any {
+	t.Helper()
+	responses := make(map[string]map[string]any)
+	dec := json.NewDecoder(r)
+	for dec.More() {
+		var resp map[string]any
+		if err := dec.Decode(&resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		responses[fmt.Sprint(resp["id"])] = resp
+	}
+	return responses
+}
+
+func TestServe(t *testing.T) {
+	echo := Tool{
+		Name:        "echo",
+		Description: "Echo the text",
+		InputSchema: json.RawMessage(`{"type":"object","properties":{"text":{"type":"string"}}}`),
+		Handler: func(_ context.Context, args json.RawMessage) (string, error) {
+			var a struct{ Text string }
+			if err := json.Unmarshal(args, &a); err != nil {
+				return "", err
+			}
+			if a.Text == "" {
+				return "", errors.New("text is required")
+			}
+			return a.Text, nil
+		},
+	}
+
+	responses := serve(t, []Tool{echo},
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05"}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"echo","arguments":{"text":"hi"}}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"echo"}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"missing"}}`,
+		`{"jsonrpc":"2.0","id":"six","method":"resources/list"}`,
+		`not json`,
+	)
+	if len(responses) != 7 {
+		t.Fatalf("got %d responses, want 7 (no answer to the notification): %v", len(responses), responses)
+	}
+
+	info := responses["1"]["result"].(map[string]any)
+	if info["protocolVersion"] != ProtocolVersion {
+		t.Errorf("protocolVersion = %v", info["protocolVersion"])
+	}
+	if name := info["serverInfo"].(map[string]any)["name"]; name != "faize" {
+		t.Errorf("serverInfo.name = %v", name)
+	}
+
+	tools := responses["2"]["result"].(map[string]any)["tools"].([]any)
+	if len(tools) != 1 || tools[0].(map[string]any)["name"] != "echo" || tools[0].(map[string]any)["inputSchema"] == nil {
+		t.Errorf("tools/list = %v", tools)
+	}
+
+	result := responses["3"]["result"].(map[string]any)
+	if text := result["content"].([]any)[0].(map[string]any)["text"]; text != "hi" || result["isError"] != nil {
+		t.Errorf("echo result = %v", result)
+	}
+
+	result = responses["4"]["result"].(map[string]any)
+	if text := result["content"].([]any)[0].(map[string]any)["text"]; text != "text is required" || result["isError"] != true {
+		t.Errorf("failed call = %v", result)
+	}
+
+	for id, code := range map[string]float64{"5": codeInvalidParams, "six": codeMethodNotFound, "<nil>": codeParseError} {
+		rpcErr, ok := responses[id]["error"].(map[string]any)
+		if !ok || rpcErr["code"] != code {
+			t.Errorf("response %s = %v, want error %v", id, responses[id], code)
+		}
+	}
+}
+
+func TestServeConcurrentCalls(t *testing.T) {
+	// The slow call finishes only after the fast one, so it doesn't hold it up
+	release := make(chan struct{})
+	tools := []Tool{
+		{Name: "slow", Handler: func(ctx context.Context, _ json.RawMessage) (string, error) {
+			<-release
+			return "slow", nil
+		}},
+		{Name: "fast", Handler: func(ctx context.Context, _ json.RawMessage) (string, error) {
+			close(release)
+			return "fast", nil
+		}},
+	}
+	responses := serve(t, tools,
+		`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"slow"}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"fast"}}`,
+	)
+	if len(responses) != 2 {
+		t.Fatalf("got %d responses, want 2: %v", len(responses), responses)
+	}
+}
+
+func TestServeCancel(t *testing.T) {
+	started := make(chan struct{})
+	stopped := make(chan error, 1)
+	tools := []Tool{{Name: "wait", Handler: func(ctx context.Context, _ json.RawMessage) (string, error) {
+		close(started)
+		<-ctx.Done()
+		stopped <- ctx.Err()
+		return "", ctx.Err()
+	}}}
+
+	in, input := io.Pipe()
+	var out bytes.Buffer
+	done := make(chan error)
+	go func() { done <- NewServer("faize", "test", tools).Serve(context.Background(), in, &out) }()
+
+	fmt.Fprintln(input, `{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"wait"}}`)
+	<-started
+	fmt.Fprintln(input, `{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":7,"reason":"user"}}`)
+	if err := <-stopped; !errors.Is(err, context.Canceled) {
+		t.Errorf("handler context = %v, want canceled", err)
+	}
+	_ = input.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+	if responses := decodeResponses(t, &out); len(responses) != 0 {
+		t.Errorf("cancelled call answered: %v", responses)
+	}
+}
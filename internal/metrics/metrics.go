@@ -0,0 +1,190 @@
+// Package metrics exposes per-session metrics in the Prometheus text
+// exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/faize-ai/faize/internal/changeset"
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/faize-ai/faize/internal/session"
+)
+
+// Session is what is reported about one session
+type Session struct {
+	ID      string
+	Project string
+	Status  string
+	Uptime  time.Duration
+	// BootTime is how long the guest took to become ready; zero if it hasn't
+	BootTime     time.Duration
+	ConsoleBytes int64
+	// NetworkEvents counts the network events the guest logged, by action
+	NetworkEvents map[string]int
+	// Changes is the recorded changeset's totals; nil until the session ends
+	Changes *changeset.MountStats
+}
+
+// Collect gathers metrics for every session in the store
+func Collect(store *session.Store, now time.Time) ([]Session, error) {
+	sessions, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	var all []Session
+	for _, sess := range sessions {
+		all = append(all, collectSession(filepath.Join(store.Dir(), sess.ID), sess, now))
+	}
+	return all, nil
+}
+
+func collectSession(dir string, sess *session.Session, now time.Time) Session {
+	m := Session{ID: sess.ID, Project: sess.ProjectDir, Status: sess.Status}
+	end := now
+	if sess.StoppedAt != nil {
+		end = *sess.StoppedAt
+	}
+	m.Uptime = max(end.Sub(sess.StartedAt), 0)
+
+	bootstrapDir := filepath.Join(dir, "bootstrap")
+	// The stage file is written through the bootstrap share, so its
+	// modification time is on the host's clock like StartedAt
+	stagePath := filepath.Join(bootstrapDir, guest.BootStageFile)
+	if data, err := os.ReadFile(stagePath); err == nil && strings.TrimSpace(string(data)) == guest.BootReady {
+		if info, err := os.Stat(stagePath); err == nil && info.ModTime().After(sess.StartedAt) {
+			m.BootTime = info.ModTime().Sub(sess.StartedAt)
+		}
+	}
+	if info, err := os.Stat(filepath.Join(dir, "console.log")); err == nil {
+		m.ConsoleBytes = info.Size()
+	}
+	if events, err := changeset.CollectNetworkEvents(bootstrapDir); err == nil {
+		m.NetworkEvents = make(map[string]int)
+		for _, e := range events {
+			m.NetworkEvents[e.Action]++
+		}
+	}
+	if cs, err := changeset.LoadChangeset(filepath.Join(bootstrapDir, "changeset.json")); err == nil {
+		var total changeset.MountStats
+		for _, mc := range cs.MountChanges {
+			s := mc.Statistics()
+			total.Files += s.Files
+			total.Created += s.Created
+			total.Modified += s.Modified
+			total.Deleted += s.Deleted
+			total.Renamed += s.Renamed
+			total.BytesAdded += s.BytesAdded
+			total.BytesRemoved += s.BytesRemoved
+		}
+		m.Changes = &total
+	}
+	return m
+}
+
+// Write writes sessions' metrics in the Prometheus text format
+func Write(w io.Writer, sessions []Session) error {
+	bw := bufio.NewWriter(w)
+	family := func(name, kind, help string) {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+	sample := func(name string, value float64, labels ...string) {
+		bw.WriteString(name)
+		if len(labels) > 0 {
+			bw.WriteByte('{')
+			for i := 0; i < len(labels); i += 2 {
+				if i > 0 {
+					bw.WriteByte(',')
+				}
+				fmt.Fprintf(bw, "%s=\"%s\"", labels[i], escapeLabel(labels[i+1]))
+			}
+			bw.WriteByte('}')
+		}
+		fmt.Fprintf(bw, " %g\n", value)
+	}
+
+	byStatus := make(map[string]int)
+	for _, s := range sessions {
+		byStatus[s.Status]++
+	}
+	family("faize_sessions", "gauge", "Sessions by status.")
+	for _, status := range sortedKeys(byStatus) {
+		sample("faize_sessions", float64(byStatus[status]), "status", status)
+	}
+
+	family("faize_session_info", "gauge", "Session details; always 1.")
+	for _, s := range sessions {
+		sample("faize_session_info", 1, "session", s.ID, "project", s.Project, "status", s.Status)
+	}
+	family("faize_session_uptime_seconds", "gauge", "Time since the session started, or how long it ran if it stopped.")
+	for _, s := range sessions {
+		sample("faize_session_uptime_seconds", s.Uptime.Seconds(), "session", s.ID)
+	}
+	family("faize_session_boot_seconds", "gauge", "Time from the session starting to the guest being ready.")
+	for _, s := range sessions {
+		if s.BootTime > 0 {
+			sample("faize_session_boot_seconds", s.BootTime.Seconds(), "session", s.ID)
+		}
+	}
+	family("faize_session_console_bytes_total", "counter", "Bytes the session printed to its console.")
+	for _, s := range sessions {
+		sample("faize_session_console_bytes_total", float64(s.ConsoleBytes), "session", s.ID)
+	}
+	family("faize_session_network_events_total", "counter", "Network events the guest logged, by action (CONN, DENY, DNS, DOH).")
+	for _, s := range sessions {
+		for _, action := range sortedKeys(s.NetworkEvents) {
+			sample("faize_session_network_events_total", float64(s.NetworkEvents[action]), "session", s.ID, "action", action)
+		}
+	}
+	family("faize_session_changed_files", "gauge", "Files the session changed, by change type, once its changeset is recorded.")
+	for _, s := range sessions {
+		if c := s.Changes; c != nil {
+			sample("faize_session_changed_files", float64(c.Created), "session", s.ID, "type", "created")
+			sample("faize_session_changed_files", float64(c.Modified), "session", s.ID, "type", "modified")
+			sample("faize_session_changed_files", float64(c.Deleted), "session", s.ID, "type", "deleted")
+			sample("faize_session_changed_files", float64(c.Renamed), "session", s.ID, "type", "renamed")
+		}
+	}
+	family("faize_session_changed_bytes", "gauge", "Bytes added and removed by the session's file changes, once its changeset is recorded.")
+	for _, s := range sessions {
+		if c := s.Changes; c != nil {
+			sample("faize_session_changed_bytes", float64(c.BytesAdded), "session", s.ID, "direction", "added")
+			sample("faize_session_changed_bytes", float64(c.BytesRemoved), "session", s.ID, "direction", "removed")
+		}
+	}
+	return bw.Flush()
+}
+
+// Handler serves the metrics of the store's sessions
+func Handler(store *session.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sessions, err := Collect(store, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = Write(w, sessions)
+	})
+}
+
+// escapeLabel escapes a label value for the text format
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/faize-ai/faize/internal/changeset"
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectSession(t *testing.T) {
+	dir := t.TempDir()
+	bootstrapDir := filepath.Join(dir, "bootstrap")
+	require.NoError(t, os.MkdirAll(bootstrapDir, 0755))
+
+	started := time.Now().Add(-time.Minute)
+	stopped := started.Add(30 * time.Second)
+	sess := &session.Session{ID: "abc123", ProjectDir: "/p", Status: "stopped", StartedAt: started, StoppedAt: &stopped}
+
+	stagePath := filepath.Join(bootstrapDir, guest.BootStageFile)
+	require.NoError(t, os.WriteFile(stagePath, []byte(guest.BootReady), 0644))
+	booted := started.Add(4 * time.Second)
+	require.NoError(t, os.Chtimes(stagePath, booted, booted))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "console.log"), []byte("hello\n"), 0644))
+	require.NoError(t, changeset.SaveChangeset(filepath.Join(bootstrapDir, "changeset.json"), &changeset.SessionChangeset{
+		SessionID: "abc123",
+		MountChanges: []changeset.MountChanges{{Changes: []changeset.Change{
+			{Path: "a.go", Type: "created", NewSize: 100},
+			{Path: "b.go", Type: "modified", OldSize: 50, NewSize: 20},
+		}}},
+	}))
+
+	m := collectSession(dir, sess, time.Now())
+	assert.Equal(t, 30*time.Second, m.Uptime)
+	assert.Equal(t, 4*time.Second, m.BootTime)
+	assert.Equal(t, int64(6), m.ConsoleBytes)
+	require.NotNil(t, m.Changes)
+	assert.Equal(t, 1, m.Changes.Created)
+	assert.Equal(t, 1, m.Changes.Modified)
+	assert.Equal(t, int64(100), m.Changes.BytesAdded)
+	assert.Equal(t, int64(30), m.Changes.BytesRemoved)
+
+	// A session still booting has no boot time or changeset
+	require.NoError(t, os.WriteFile(stagePath, []byte(guest.BootNetwork), 0644))
+	require.NoError(t, os.Remove(filepath.Join(bootstrapDir, "changeset.json")))
+	sess.Status, sess.StoppedAt = "running", nil
+	m = collectSession(dir, sess, started.Add(10*time.Second))
+	assert.Equal(t, 10*time.Second, m.Uptime)
+	assert.Zero(t, m.BootTime)
+	assert.Nil(t, m.Changes)
+}
+
+func TestWrite(t *testing.T) {
+	var b strings.Builder
+	require.NoError(t, Write(&b, []Session{
+		{
+			ID: "abc123", Project: `/home/me/"quoted"`, Status: "running",
+			Uptime: 90 * time.Second, BootTime: 2500 * time.Millisecond, ConsoleBytes: 2048,
+			NetworkEvents: map[string]int{"DENY": 2, "CONN": 5},
+		},
+		{
+			ID: "def456", Project: "/p", Status: "stopped", Uptime: time.Minute,
+			Changes: &changeset.MountStats{Created: 3, BytesAdded: 1200},
+		},
+	}))
+	out := b.String()
+
+	for _, want := range []string{
+		"# TYPE faize_sessions gauge\n",
+		`faize_sessions{status="running"} 1`,
+		`faize_session_info{session="abc123",project="/home/me/\"quoted\"",status="running"} 1`,
+		`faize_session_uptime_seconds{session="abc123"} 90`,
+		`faize_session_boot_seconds{session="abc123"} 2.5`,
+		`faize_session_console_bytes_total{session="abc123"} 2048`,
+		`faize_session_network_events_total{session="abc123",action="CONN"} 5`,
+		`faize_session_network_events_total{session="abc123",action="DENY"} 2`,
+		`faize_session_changed_files{session="def456",type="created"} 3`,
+		`faize_session_changed_bytes{session="def456",direction="added"} 1200`,
+	} {
+		assert.Contains(t, out, want)
+	}
+	assert.NotContains(t, out, `faize_session_boot_seconds{session="def456"}`)
+	assert.NotContains(t, out, `faize_session_changed_files{session="abc123"`)
+}
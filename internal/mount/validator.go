@@ -52,8 +52,8 @@ func NewValidator(blockedPaths []string) (*Validator, error) {
 	}, nil
 }
 
-// Validate checks if the mount's source path is under or equal to any blocked path.
-// Returns an error if the mount is blocked.
+// Validate checks if the mount's source path is under, equal to, or contains
+// any blocked path. Returns an error if the mount is blocked.
 func (v *Validator) Validate(m *Mount) error {
 	if m == nil {
 		return fmt.Errorf("mount cannot be nil")
@@ -85,6 +85,10 @@ func (v *Validator) Validate(m *Mount) error {
 			}
 			return fmt.Errorf("mount blocked: %s is a protected path", blocked)
 		}
+		// A parent directory would expose the blocked path beneath it
+		if isUnderOrEqual(blocked, realPath) {
+			return fmt.Errorf("mount blocked: %s contains protected path %s", m.Source, blocked)
+		}
 	}
 
 	return nil
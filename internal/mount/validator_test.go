@@ -148,6 +148,24 @@ func TestValidate(t *testing.T) {
 			wantErr:  true,
 			errMatch: "protected path",
 		},
+		{
+			name: "parent of blocked path",
+			mount: &Mount{
+				Source: homeDir,
+				Target: homeDir,
+			},
+			wantErr:  true,
+			errMatch: "contains protected path",
+		},
+		{
+			name: "root contains system path",
+			mount: &Mount{
+				Source: "/",
+				Target: "/mnt/root",
+			},
+			wantErr:  true,
+			errMatch: "contains protected path",
+		},
 		{
 			name:     "nil mount",
 			mount:    nil,
@@ -246,6 +264,12 @@ func TestValidateSymlinkBypass(t *testing.T) {
 		t.Fatalf("Failed to create blocked directory: %v", err)
 	}
 
+	// And an unrelated directory beside it
+	projectDir := filepath.Join(tmpDir, "project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatalf("Failed to create project directory: %v", err)
+	}
+
 	// Create a symlink that points to the blocked directory
 	symlinkPath := filepath.Join(tmpDir, "innocent-looking-link")
 	if err := os.Symlink(blockedDir, symlinkPath); err != nil {
@@ -285,12 +309,22 @@ func TestValidateSymlinkBypass(t *testing.T) {
 			errMatch: "protected path",
 		},
 		{
-			name: "allowed path should pass",
+			name: "parent of blocked path should be blocked",
 			mount: &Mount{
 				Source:   tmpDir,
 				Target:   "/mnt/data",
 				ReadOnly: true,
 			},
+			wantErr:  true,
+			errMatch: "contains protected path",
+		},
+		{
+			name: "allowed path should pass",
+			mount: &Mount{
+				Source:   projectDir,
+				Target:   "/mnt/data",
+				ReadOnly: true,
+			},
 			wantErr: false,
 		},
 	}
@@ -2,6 +2,10 @@ package network
 
 import (
 	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -23,10 +27,29 @@ const (
 
 // Policy represents network access permissions
 type Policy struct {
-	AllowAll  bool     // Allow all traffic
-	Blocked   bool     // No network access
-	Domains   []string // Allowed literal domains
-	Wildcards []string // Allowed wildcard patterns (*.example.com)
+	AllowAll  bool       `json:"allow_all,omitempty"` // Allow all traffic
+	Blocked   bool       `json:"blocked,omitempty"`   // No network access
+	Domains   []string   `json:"domains,omitempty"`   // Allowed literal domains
+	Wildcards []string   `json:"wildcards,omitempty"` // Allowed wildcard patterns (*.example.com)
+	CIDRs     []string   `json:"cidrs,omitempty"`     // Allowed IP ranges; single addresses are /32 or /128
+	Ports     []PortRule `json:"ports,omitempty"`     // Entries allowed on one port only
+}
+
+// PortRule allows a domain, wildcard, or IP range on a single TCP port,
+// written as host:port (db.internal:5432, 10.0.0.0/8:22, *.example.com:443)
+type PortRule struct {
+	Host string `json:"host"` // literal domain, wildcard, or CIDR
+	Port int    `json:"port"`
+}
+
+// String formats the rule as it is written in a network spec
+func (r PortRule) String() string {
+	return net.JoinHostPort(r.Host, strconv.Itoa(r.Port))
+}
+
+// Len returns the number of allowlist entries
+func (p *Policy) Len() int {
+	return len(p.Domains) + len(p.Wildcards) + len(p.CIDRs) + len(p.Ports)
 }
 
 // IsWildcard returns true if the domain is a wildcard pattern (*.example.com)
@@ -75,12 +98,55 @@ func ExtractBaseDomain(pattern string) string {
 	return strings.TrimPrefix(pattern, "*.")
 }
 
+// IsCIDR reports whether an allowlist entry is an IP range
+func IsCIDR(entry string) bool {
+	_, err := netip.ParsePrefix(entry)
+	return err == nil
+}
+
+// parseCIDR returns the IP range an address or CIDR spec covers, in
+// canonical form (192.168.1.5 -> 192.168.1.5/32, 10.1.0.0/8 -> 10.0.0.0/8)
+func parseCIDR(spec string) (string, bool) {
+	if addr, err := netip.ParseAddr(spec); err == nil {
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()).String(), true
+	}
+	if prefix, err := netip.ParsePrefix(spec); err == nil {
+		return prefix.Masked().String(), true
+	}
+	return "", false
+}
+
+// splitPort splits a trailing :port off a spec, returning port 0 if there is
+// none. Bare IPv6 addresses and ranges are left whole; write [::1]:5432 to
+// add a port to them.
+func splitPort(spec string) (string, int, error) {
+	if _, ok := parseCIDR(spec); ok {
+		return spec, 0, nil
+	}
+	i := strings.LastIndex(spec, ":")
+	if i < 0 || i == len(spec)-1 || strings.Trim(spec[i+1:], "0123456789") != "" {
+		return spec, 0, nil
+	}
+	port, err := strconv.Atoi(spec[i+1:])
+	if err != nil || port < 1 || port > 65535 {
+		return spec, 0, fmt.Errorf("invalid port %s", spec[i+1:])
+	}
+	host := spec[:i]
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		host = host[1 : len(host)-1]
+	}
+	return host, port, nil
+}
+
 // Parse converts network specs like "npm,pypi,github" into a Policy.
 // Examples:
 //   - Parse([]string{"npm", "pypi"}) -> Policy{Domains: ["registry.npmjs.org", "npmjs.com", "pypi.org", ...]}
 //   - Parse([]string{"all"}) -> Policy{AllowAll: true}
 //   - Parse([]string{"none"}) -> Policy{Blocked: true}
 //   - Parse([]string{"*.example.com"}) -> Policy{Wildcards: ["*.example.com"]}
+//   - Parse([]string{"10.0.0.0/8"}) -> Policy{CIDRs: ["10.0.0.0/8"]}
+//   - Parse([]string{"db.internal:5432"}) -> Policy{Ports: [{db.internal 5432}]}
 func Parse(specs []string) *Policy {
 	policy := &Policy{
 		Domains:   []string{},
@@ -113,32 +179,141 @@ func Parse(specs []string) *Policy {
 		}
 	}
 
-	// Second pass: process presets, wildcards, and domains
+	// Second pass: process presets, wildcards, IP ranges, and domains,
+	// each optionally limited to a port
 	for _, spec := range specs {
 		spec = strings.TrimSpace(strings.ToLower(spec))
+		host, port, err := splitPort(spec)
+		if err != nil {
+			// Invalid ports are ignored; ParseStrict reports them
+			continue
+		}
 
-		// Check if it's a preset
-		if presetDomains, ok := Presets[spec]; ok {
-			policy.Domains = append(policy.Domains, presetDomains...)
-		} else if IsWildcard(spec) {
-			// Validate and add wildcard pattern
-			if err := ValidateWildcard(spec); err == nil {
-				policy.Wildcards = append(policy.Wildcards, spec)
+		var hosts []string
+		if presetDomains, ok := Presets[host]; ok {
+			hosts = presetDomains
+		} else if cidr, ok := parseCIDR(host); ok {
+			hosts = []string{cidr}
+		} else if IsWildcard(host) {
+			// Invalid wildcards are ignored; ParseStrict reports them
+			if err := ValidateWildcard(host); err == nil {
+				hosts = []string{host}
 			}
-			// Invalid wildcards are silently ignored (or could log a warning)
 		} else {
 			// Treat as a literal domain
-			policy.Domains = append(policy.Domains, spec)
+			hosts = []string{host}
+		}
+
+		for _, h := range hosts {
+			switch {
+			case port != 0:
+				policy.Ports = append(policy.Ports, PortRule{Host: h, Port: port})
+			case IsCIDR(h):
+				policy.CIDRs = append(policy.CIDRs, h)
+			case IsWildcard(h):
+				policy.Wildcards = append(policy.Wildcards, h)
+			default:
+				policy.Domains = append(policy.Domains, h)
+			}
 		}
 	}
 
 	// Remove duplicates
 	policy.Domains = deduplicateDomains(policy.Domains)
 	policy.Wildcards = deduplicateDomains(policy.Wildcards)
+	policy.CIDRs = deduplicateDomains(policy.CIDRs)
+	policy.Ports = deduplicatePorts(policy.Ports)
 
 	return policy
 }
 
+// Allows reports whether the policy permits connections to host on some
+// port. Literal domains match exactly; a wildcard matches its base domain and
+// any name below it, like the SNI match of the in-guest firewall; an IP range
+// matches the IP addresses in it.
+func (p *Policy) Allows(host string) bool {
+	return p.AllowsPort(host, 0)
+}
+
+// AllowsPort reports whether the policy permits connections to host:port.
+// Port 0 matches entries limited to any port.
+func (p *Policy) AllowsPort(host string, port int) bool {
+	_, ok := p.Match(host, port)
+	return ok
+}
+
+// Match returns the allowlist entry that permits connections to host:port,
+// as AllowsPort decides, or "all" for an unrestricted policy
+func (p *Policy) Match(host string, port int) (string, bool) {
+	if p == nil || p.Blocked {
+		return "", false
+	}
+	if p.AllowAll {
+		return NetworkAll, true
+	}
+
+	host = strings.TrimSuffix(strings.ToLower(strings.Trim(host, "[]")), ".")
+	if host == "" {
+		return "", false
+	}
+	for _, entries := range [][]string{p.Domains, p.Wildcards, p.CIDRs} {
+		for _, entry := range entries {
+			if matchesHost(entry, host) {
+				return entry, true
+			}
+		}
+	}
+	for _, rule := range p.Ports {
+		if (port == 0 || rule.Port == port) && matchesHost(rule.Host, host) {
+			return rule.String(), true
+		}
+	}
+	return "", false
+}
+
+// matchesHost reports whether an allowlist entry (literal domain, wildcard,
+// or CIDR) covers host
+func matchesHost(entry, host string) bool {
+	if IsWildcard(entry) {
+		base := ExtractBaseDomain(entry)
+		return host == base || strings.HasSuffix(host, "."+base)
+	}
+	if prefix, err := netip.ParsePrefix(entry); err == nil {
+		addr, err := netip.ParseAddr(host)
+		return err == nil && prefix.Contains(addr.Unmap())
+	}
+	return host == entry
+}
+
+// Extend returns a copy of the policy that also allows the presets, domains,
+// wildcards, IP ranges, and port rules in specs, as added to a running session with faize allow.
+// "all" and "none" are ignored, and unrestricted or blocked policies are
+// returned unchanged.
+func (p *Policy) Extend(specs []string) *Policy {
+	if p == nil || p.AllowAll || p.Blocked {
+		return p
+	}
+
+	var added []string
+	for _, spec := range specs {
+		spec = strings.TrimSpace(strings.ToLower(spec))
+		if spec != "" && spec != NetworkAll && spec != NetworkNone {
+			added = append(added, spec)
+		}
+	}
+	extra := Parse(added)
+	if extra.Blocked {
+		extra = &Policy{}
+	}
+
+	return &Policy{
+		Domains:   deduplicateDomains(append(append([]string{}, p.Domains...), extra.Domains...)),
+		Wildcards: deduplicateDomains(append(append([]string{}, p.Wildcards...), extra.Wildcards...)),
+		CIDRs:     deduplicateDomains(append(append([]string{}, p.CIDRs...), extra.CIDRs...)),
+		Ports:     deduplicatePorts(append(append([]PortRule{}, p.Ports...), extra.Ports...)),
+	}
+}
+
 // deduplicateDomains removes duplicate domains from a slice
 func deduplicateDomains(domains []string) []string {
 	seen := make(map[string]bool)
@@ -153,3 +328,181 @@ func deduplicateDomains(domains []string) []string {
 
 	return result
 }
+
+// deduplicatePorts removes duplicate port rules from a slice
+func deduplicatePorts(rules []PortRule) []PortRule {
+	seen := make(map[PortRule]bool)
+	var result []PortRule
+
+	for _, rule := range rules {
+		if !seen[rule] {
+			seen[rule] = true
+			result = append(result, rule)
+		}
+	}
+
+	return result
+}
+
+// Problem describes a network spec that Parse drops or probably misreads
+type Problem struct {
+	Spec    string
+	Message string
+	Fatal   bool // Parse drops or misinterprets the spec; warnings are only suspicious
+}
+
+// String formats the problem for display
+func (p Problem) String() string {
+	return fmt.Sprintf("%q: %s", p.Spec, p.Message)
+}
+
+// maxTypoDistance is the largest edit distance reported as a likely typo
+const maxTypoDistance = 2
+
+// typoDistance scales the typo threshold down for short names, where a couple
+// of edits turn any word into any other
+func typoDistance(s string) int {
+	return min(maxTypoDistance, len(s)/3)
+}
+
+// ParseStrict parses specs like Parse and also reports problems: unknown
+// presets, invalid wildcards or ports, and malformed domains are fatal and left out of
+// the returned policy, while near-misses of known domains and "all"/"none"
+// mixed with other entries are warnings. The CLI uses it to explain a policy
+// before the VM boots.
+func ParseStrict(specs []string) (*Policy, []Problem) {
+	var problems []Problem
+	add := func(spec, message string, fatal bool) {
+		problems = append(problems, Problem{Spec: spec, Message: message, Fatal: fatal})
+	}
+
+	valid := make([]string, 0, len(specs))
+	for _, raw := range specs {
+		before := len(problems)
+		spec := strings.TrimSpace(strings.ToLower(raw))
+		host, port, portErr := splitPort(spec)
+		_, isCIDR := parseCIDR(host)
+		switch {
+		case spec == "":
+			add(raw, "empty network entry", true)
+		case portErr != nil:
+			add(raw, portErr.Error(), true)
+		case spec == NetworkAll || spec == NetworkNone:
+			if len(specs) > 1 {
+				add(raw, fmt.Sprintf("'%s' overrides every other network entry", spec), false)
+			}
+		case port != 0 && (host == NetworkAll || host == NetworkNone):
+			add(raw, fmt.Sprintf("'%s' can't be limited to a port", host), true)
+		case Presets[host] != nil:
+			// known preset
+		case isCIDR:
+			// IP address or range
+		case IsWildcard(host):
+			if err := ValidateWildcard(host); err != nil {
+				add(raw, err.Error(), true)
+			} else if err := validateDomain(ExtractBaseDomain(host)); err != nil {
+				add(raw, err.Error(), true)
+			}
+		case !strings.Contains(host, "."):
+			add(raw, unknownPresetMessage(host), true)
+		default:
+			if err := validateDomain(host); err != nil {
+				add(raw, err.Error(), true)
+			} else if suggestion := closestKnownDomain(host); suggestion != "" {
+				add(raw, fmt.Sprintf("unknown domain, did you mean '%s'?", suggestion), false)
+			}
+		}
+		if len(problems) == before || !problems[len(problems)-1].Fatal {
+			valid = append(valid, raw)
+		}
+	}
+
+	return Parse(valid), problems
+}
+
+// validateDomain checks that s is a plain host name (no scheme, port, or path)
+func validateDomain(s string) error {
+	if strings.Contains(s, "://") || strings.ContainsAny(s, "/:@") {
+		return fmt.Errorf("expected a domain name, IP range, or host:port without scheme or path")
+	}
+	if len(s) > 253 {
+		return fmt.Errorf("domain name too long")
+	}
+	for _, label := range strings.Split(s, ".") {
+		if label == "" || len(label) > 63 {
+			return fmt.Errorf("invalid domain name")
+		}
+		if strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return fmt.Errorf("invalid domain name")
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+				return fmt.Errorf("invalid character %q in domain name", r)
+			}
+		}
+	}
+	return nil
+}
+
+// unknownPresetMessage explains an unknown preset, suggesting the closest one
+func unknownPresetMessage(spec string) string {
+	names := presetNames()
+	best, bestDist := "", typoDistance(spec)+1
+	for _, name := range names {
+		if d := editDistance(spec, name); d < bestDist {
+			best, bestDist = name, d
+		}
+	}
+	if best != "" {
+		return fmt.Sprintf("unknown preset, did you mean '%s'?", best)
+	}
+	return fmt.Sprintf("unknown preset (available: %s, %s, %s)", strings.Join(names, ", "), NetworkAll, NetworkNone)
+}
+
+// closestKnownDomain returns a preset domain within typo distance of domain,
+// or "" if domain is known or not close to any
+func closestKnownDomain(domain string) string {
+	best, bestDist := "", typoDistance(domain)+1
+	for _, name := range presetNames() {
+		for _, known := range Presets[name] {
+			if known == domain {
+				return ""
+			}
+			if d := editDistance(domain, known); d < bestDist {
+				best, bestDist = known, d
+			}
+		}
+	}
+	return best
+}
+
+// presetNames returns the preset names in sorted order
+func presetNames() []string {
+	names := make([]string, 0, len(Presets))
+	for name := range Presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
@@ -2,6 +2,7 @@ package network
 
 import (
 	"sort"
+	"strings"
 	"testing"
 )
 
@@ -285,3 +286,120 @@ func TestExtractBaseDomain(t *testing.T) {
 		})
 	}
 }
+
+func TestParseStrict(t *testing.T) {
+	tests := []struct {
+		name      string
+		specs     []string
+		wantFatal []string // specs with fatal problems
+		wantWarn  []string // specs with warnings
+		wantMsg   string   // substring of the first problem message
+	}{
+		{name: "valid presets and domains", specs: []string{"npm", "github", "example.com", "*.example.org"}},
+		{name: "preset typo", specs: []string{"gihub"}, wantFatal: []string{"gihub"}, wantMsg: "did you mean 'github'"},
+		{name: "unknown preset", specs: []string{"pip"}, wantFatal: []string{"pip"}, wantMsg: "available: anthropic"},
+		{name: "TLD wildcard", specs: []string{"*.com"}, wantFatal: []string{"*.com"}, wantMsg: "TLD wildcards"},
+		{name: "mid-level wildcard", specs: []string{"a.*.example.com"}, wantFatal: []string{"a.*.example.com"}},
+		{name: "URL instead of domain", specs: []string{"https://example.com/path"}, wantFatal: []string{"https://example.com/path"}, wantMsg: "without scheme"},
+		{name: "invalid characters", specs: []string{"exa_mple.com"}, wantFatal: []string{"exa_mple.com"}, wantMsg: "invalid character"},
+		{name: "empty entry", specs: []string{"npm", " "}, wantFatal: []string{" "}},
+		{name: "domain typo", specs: []string{"gihub.com"}, wantWarn: []string{"gihub.com"}, wantMsg: "did you mean 'github.com'"},
+		{name: "all mixed with others", specs: []string{"npm", "all"}, wantWarn: []string{"all"}, wantMsg: "overrides"},
+		{name: "all alone", specs: []string{"all"}},
+		{name: "IP ranges and ports", specs: []string{"10.0.0.0/8", "192.168.1.5:5432", "*.example.com:443", "[fd00::1]:22", "npm:443"}},
+		{name: "port out of range", specs: []string{"example.com:70000"}, wantFatal: []string{"example.com:70000"}, wantMsg: "invalid port"},
+		{name: "port on all", specs: []string{"all:443"}, wantFatal: []string{"all:443"}, wantMsg: "can't be limited to a port"},
+		{name: "invalid wildcard with port", specs: []string{"*.com:443"}, wantFatal: []string{"*.com:443"}, wantMsg: "TLD wildcards"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, problems := ParseStrict(tt.specs)
+			if policy == nil {
+				t.Fatal("Expected a policy")
+			}
+
+			var fatal, warn []string
+			for _, p := range problems {
+				if p.Fatal {
+					fatal = append(fatal, p.Spec)
+				} else {
+					warn = append(warn, p.Spec)
+				}
+			}
+			if !equalStrings(fatal, tt.wantFatal) {
+				t.Errorf("fatal = %v, want %v (problems: %v)", fatal, tt.wantFatal, problems)
+			}
+			if !equalStrings(warn, tt.wantWarn) {
+				t.Errorf("warnings = %v, want %v (problems: %v)", warn, tt.wantWarn, problems)
+			}
+			if tt.wantMsg != "" && (len(problems) == 0 || !strings.Contains(problems[0].Message, tt.wantMsg)) {
+				t.Errorf("Expected message containing %q, got %v", tt.wantMsg, problems)
+			}
+		})
+	}
+}
+
+func TestParseStrictDropsInvalidEntries(t *testing.T) {
+	policy, _ := ParseStrict([]string{"npm", "*.com", "gihub", "https://example.com", "*.example.com", "gihub.com"})
+	want := Parse([]string{"npm", "*.example.com", "gihub.com"})
+	if !equalStrings(policy.Domains, want.Domains) || !equalStrings(policy.Wildcards, want.Wildcards) {
+		t.Errorf("ParseStrict policy = %+v, want %+v", policy, want)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestParseCIDRsAndPorts(t *testing.T) {
+	policy := Parse([]string{"10.1.0.0/8", "192.168.1.5", "192.168.1.5:5432", "[fd00::1]:22", "*.example.com:443", "npm:443", "10.0.0.0/8"})
+
+	if want := []string{"10.0.0.0/8", "192.168.1.5/32"}; !equalStrings(policy.CIDRs, want) {
+		t.Errorf("CIDRs = %v, want %v", policy.CIDRs, want)
+	}
+	var ports []string
+	for _, rule := range policy.Ports {
+		ports = append(ports, rule.String())
+	}
+	want := []string{"192.168.1.5/32:5432", "[fd00::1/128]:22", "*.example.com:443", "registry.npmjs.org:443", "npmjs.com:443"}
+	if !equalStrings(ports, want) {
+		t.Errorf("Ports = %v, want %v", ports, want)
+	}
+	if len(policy.Domains) != 0 || len(policy.Wildcards) != 0 || policy.Len() != 7 {
+		t.Errorf("Expected only IP ranges and port rules, got %+v", policy)
+	}
+}
+
+func TestPolicyExtend(t *testing.T) {
+	base := Parse([]string{"npm", "*.example.com"})
+	extended := base.Extend([]string{"PyPI", "registry.npmjs.org", "*.corp.dev", "all"})
+
+	wantDomains := []string{"registry.npmjs.org", "npmjs.com", "pypi.org", "files.pythonhosted.org"}
+	if !equalStrings(extended.Domains, wantDomains) {
+		t.Errorf("Domains = %v, want %v", extended.Domains, wantDomains)
+	}
+	if want := []string{"*.example.com", "*.corp.dev"}; !equalStrings(extended.Wildcards, want) {
+		t.Errorf("Wildcards = %v, want %v", extended.Wildcards, want)
+	}
+	if cidrs := base.Extend([]string{"10.0.0.0/8", "db.internal:5432"}); cidrs.Len() != base.Len()+2 || len(cidrs.Ports) != 1 {
+		t.Errorf("Extend must add IP ranges and port rules: %+v", cidrs)
+	}
+	if extended.AllowAll || len(base.Domains) != 2 {
+		t.Errorf("Extend must not allow everything or modify the base policy: %+v, %+v", extended, base)
+	}
+
+	for _, p := range []*Policy{Parse([]string{"all"}), Parse([]string{"none"})} {
+		if got := p.Extend([]string{"example.com"}); got != p {
+			t.Errorf("Extend(%+v) = %+v, want the policy unchanged", p, got)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package network
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultNameservers are used when the host's resolv.conf lists none
+var DefaultNameservers = []string{"8.8.8.8", "1.1.1.1"}
+
+// dnsTimeout bounds each upstream exchange
+const dnsTimeout = 3 * time.Second
+
+// DNS response codes used by the resolver
+const (
+	rcodeFormErr  = 1
+	rcodeServFail = 2
+	rcodeNXDomain = 3
+)
+
+// Resolver answers the guest's DNS queries on the host. It only resolves
+// names the policy allows, so wildcard entries are enforced when a name is
+// looked up and the guest needs no direct route to a DNS server. Queries
+// arrive as DNS over TCP (a two-byte length before each message) and are
+// forwarded to the host's nameservers. Denied names get NXDOMAIN and are
+// logged like egress proxy decisions.
+type Resolver struct {
+	policy      atomic.Pointer[Policy]
+	nameservers []string // host:port
+
+	logMu sync.Mutex
+	log   io.Writer // nil disables logging
+
+	// exchange sends a query upstream and returns the response
+	exchange func(query []byte) ([]byte, error)
+}
+
+// NewResolver returns a resolver enforcing policy that forwards allowed
+// queries to nameservers (IP addresses, port 53 implied) and logs denied
+// names to log
+func NewResolver(policy *Policy, nameservers []string, log io.Writer) *Resolver {
+	r := &Resolver{log: log}
+	for _, ns := range nameservers {
+		r.nameservers = append(r.nameservers, net.JoinHostPort(ns, "53"))
+	}
+	r.policy.Store(policy)
+	r.exchange = r.forward
+	return r
+}
+
+// SetPolicy replaces the policy applied to new queries
+func (r *Resolver) SetPolicy(policy *Policy) {
+	r.policy.Store(policy)
+}
+
+// Serve accepts guest connections on l until it is closed
+func (r *Resolver) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go r.ServeConn(conn)
+	}
+}
+
+// ServeConn answers DNS over TCP messages on conn until it is closed
+func (r *Resolver) ServeConn(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	br := bufio.NewReader(conn)
+	for {
+		query, err := ReadDNSMessage(br)
+		if err != nil {
+			return
+		}
+		resp := r.Resolve(query)
+		if resp == nil {
+			continue
+		}
+		if err := WriteDNSMessage(conn, resp); err != nil {
+			return
+		}
+	}
+}
+
+// Resolve answers one DNS query message. Returns nil for messages too short
+// to answer.
+func (r *Resolver) Resolve(query []byte) []byte {
+	name, end, err := parseQuestion(query)
+	if err != nil {
+		if len(query) < 12 {
+			return nil
+		}
+		return dnsError(query, 12, rcodeFormErr)
+	}
+	if policy := r.policy.Load(); !policy.Allows(name) || policy.BlocksDoH(name) {
+		r.logf("%sDENY DNS %s", ProxyLogPrefix, net.JoinHostPort(name, "53"))
+		return dnsError(query, end, rcodeNXDomain)
+	}
+
+	resp, err := r.exchange(query)
+	if err != nil {
+		return dnsError(query, end, rcodeServFail)
+	}
+	return resp
+}
+
+// forward sends query to the first nameserver that answers, over UDP and,
+// if the answer is truncated, again over TCP
+func (r *Resolver) forward(query []byte) ([]byte, error) {
+	var lastErr error = errors.New("no nameservers")
+	for _, ns := range r.nameservers {
+		resp, err := exchangeUDP(ns, query)
+		if err == nil && len(resp) > 2 && resp[2]&0x02 != 0 {
+			resp, err = exchangeTCP(ns, query)
+		}
+		if err == nil {
+			return resp, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// logf appends a timestamped line to the log
+func (r *Resolver) logf(format string, args ...any) {
+	if r.log == nil {
+		return
+	}
+	r.logMu.Lock()
+	defer r.logMu.Unlock()
+	_, _ = fmt.Fprintf(r.log, "%s %s\n", time.Now().UTC().Format(time.RFC3339), fmt.Sprintf(format, args...))
+}
+
+// exchangeUDP sends a query over UDP and waits for the response with its ID
+func exchangeUDP(addr string, query []byte) ([]byte, error) {
+	conn, err := net.DialTimeout("udp", addr, dnsTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(dnsTimeout))
+
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 65535)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		if n >= 12 && buf[0] == query[0] && buf[1] == query[1] {
+			return append([]byte(nil), buf[:n]...), nil
+		}
+	}
+}
+
+// exchangeTCP sends a query over TCP and reads the response
+func exchangeTCP(addr string, query []byte) ([]byte, error) {
+	conn, err := net.DialTimeout("tcp", addr, dnsTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(dnsTimeout))
+
+	if err := WriteDNSMessage(conn, query); err != nil {
+		return nil, err
+	}
+	return ReadDNSMessage(bufio.NewReader(conn))
+}
+
+// ReadDNSMessage reads one length-prefixed DNS over TCP message
+func ReadDNSMessage(r io.Reader) ([]byte, error) {
+	var size [2]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+	msg := make([]byte, binary.BigEndian.Uint16(size[:]))
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// WriteDNSMessage writes msg with its DNS over TCP length prefix
+func WriteDNSMessage(w io.Writer, msg []byte) error {
+	if len(msg) > 65535 {
+		return fmt.Errorf("DNS message too large: %d bytes", len(msg))
+	}
+	buf := make([]byte, 2+len(msg))
+	binary.BigEndian.PutUint16(buf, uint16(len(msg)))
+	copy(buf[2:], msg)
+	_, err := w.Write(buf)
+	return err
+}
+
+// parseQuestion returns the lowercased name of a query's first question
+// and the offset just past it
+func parseQuestion(msg []byte) (string, int, error) {
+	if len(msg) < 12 {
+		return "", 0, errors.New("short DNS message")
+	}
+	if msg[2]&0x80 != 0 || binary.BigEndian.Uint16(msg[4:6]) == 0 {
+		return "", 0, errors.New("not a DNS query")
+	}
+
+	var labels []string
+	off := 12
+	for {
+		if off >= len(msg) {
+			return "", 0, errors.New("truncated question")
+		}
+		n := int(msg[off])
+		off++
+		if n == 0 {
+			break
+		}
+		if n > 63 || off+n > len(msg) {
+			// Queries never use compression pointers
+			return "", 0, errors.New("invalid question name")
+		}
+		labels = append(labels, string(msg[off:off+n]))
+		off += n
+	}
+	if off+4 > len(msg) {
+		return "", 0, errors.New("truncated question")
+	}
+	return strings.ToLower(strings.Join(labels, ".")), off + 4, nil
+}
+
+// dnsError builds a response to query carrying only its question (the first
+// end bytes) and rcode
+func dnsError(query []byte, end int, rcode byte) []byte {
+	resp := append([]byte(nil), query[:end]...)
+	resp[2] = 0x80 | resp[2]&0x01 // QR, keep RD
+	resp[3] = 0x80 | rcode        // RA
+	qdcount := uint16(1)
+	if end == 12 {
+		qdcount = 0
+	}
+	binary.BigEndian.PutUint16(resp[4:6], qdcount)
+	clear(resp[6:12])
+	return resp
+}
+
+// ParseResolvers validates configured DNS resolvers (network.resolvers),
+// which must be IPv4 addresses: the guest firewall only allows DNS to them
+// by address, and IPv6 is disabled in the guest
+func ParseResolvers(specs []string) ([]string, error) {
+	var resolvers []string
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		ip := net.ParseIP(spec)
+		if ip == nil || ip.To4() == nil {
+			return nil, fmt.Errorf("invalid DNS resolver %q: expected an IPv4 address", spec)
+		}
+		resolvers = append(resolvers, ip.String())
+	}
+	return resolvers, nil
+}
+
+// Nameservers returns the nameservers listed in a resolv.conf file, or
+// DefaultNameservers if it lists none
+func Nameservers(resolvConf string) []string {
+	data, err := os.ReadFile(resolvConf)
+	if err != nil {
+		return DefaultNameservers
+	}
+	var servers []string
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "nameserver" && net.ParseIP(fields[1]) != nil {
+			servers = append(servers, fields[1])
+		}
+	}
+	if len(servers) == 0 {
+		return DefaultNameservers
+	}
+	return servers
+}
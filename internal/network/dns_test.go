@@ -0,0 +1,121 @@
+package network
+
+import (
+	"bufio"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// dnsQuery builds an A query for name
+func dnsQuery(id uint16, name string) []byte {
+	msg := make([]byte, 12)
+	binary.BigEndian.PutUint16(msg, id)
+	msg[2] = 0x01 // RD
+	binary.BigEndian.PutUint16(msg[4:], 1)
+	for _, label := range strings.Split(name, ".") {
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	return append(msg, 0, 0, 1, 0, 1)
+}
+
+func TestParseQuestion(t *testing.T) {
+	query := dnsQuery(7, "API.Example.com")
+	name, end, err := parseQuestion(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "api.example.com" || end != len(query) {
+		t.Errorf("parseQuestion = %q, %d", name, end)
+	}
+
+	for _, bad := range [][]byte{query[:10], query[:len(query)-2], append([]byte{}, query[:12]...)} {
+		if _, _, err := parseQuestion(bad); err == nil {
+			t.Errorf("parseQuestion(%x) succeeded", bad)
+		}
+	}
+}
+
+func TestResolverDeniesNames(t *testing.T) {
+	log := &lockedBuffer{}
+	r := NewResolver(&Policy{Wildcards: []string{"*.example.com"}}, nil, log)
+	r.exchange = func([]byte) ([]byte, error) {
+		t.Fatal("denied query forwarded")
+		return nil, nil
+	}
+
+	query := dnsQuery(0x1234, "evil.test")
+	resp := r.Resolve(query)
+	if len(resp) != len(query) {
+		t.Fatalf("response length %d, want the question echoed (%d)", len(resp), len(query))
+	}
+	if resp[0] != 0x12 || resp[1] != 0x34 || resp[2]&0x80 == 0 || resp[3]&0x0f != rcodeNXDomain {
+		t.Errorf("response header %x, want NXDOMAIN for the query ID", resp[:4])
+	}
+	if !strings.Contains(log.String(), ProxyLogPrefix+"DENY DNS evil.test:53") {
+		t.Errorf("log %q missing the denial", log.String())
+	}
+}
+
+func TestResolverServeConn(t *testing.T) {
+	answer := []byte("answer")
+	r := NewResolver(&Policy{Wildcards: []string{"*.example.com"}}, nil, nil)
+	r.exchange = func([]byte) ([]byte, error) { return answer, nil }
+
+	client, server := net.Pipe()
+	defer func() { _ = client.Close() }()
+	go r.ServeConn(server)
+
+	br := bufio.NewReader(client)
+	for _, name := range []string{"api.example.com", "example.org"} {
+		if err := WriteDNSMessage(client, dnsQuery(1, name)); err != nil {
+			t.Fatal(err)
+		}
+		resp, err := ReadDNSMessage(br)
+		if err != nil {
+			t.Fatal(err)
+		}
+		allowed := string(resp) == string(answer)
+		if allowed != (name == "api.example.com") {
+			t.Errorf("%s: response %x", name, resp)
+		}
+	}
+
+	// Policy changes apply to the next query
+	r.SetPolicy(&Policy{Domains: []string{"example.org"}})
+	if resp := r.Resolve(dnsQuery(2, "example.org")); string(resp) != string(answer) {
+		t.Error("updated policy not applied")
+	}
+}
+
+func TestNameservers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resolv.conf")
+	if err := os.WriteFile(path, []byte("# comment\nsearch lan\nnameserver 192.168.1.1\nnameserver fe80::1%en0\nnameserver 127.0.0.53\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := Nameservers(path); strings.Join(got, ",") != "192.168.1.1,127.0.0.53" {
+		t.Errorf("Nameservers = %v", got)
+	}
+	if got := Nameservers(filepath.Join(t.TempDir(), "missing")); strings.Join(got, ",") != strings.Join(DefaultNameservers, ",") {
+		t.Errorf("Nameservers without resolv.conf = %v", got)
+	}
+}
+
+func TestParseResolvers(t *testing.T) {
+	got, err := ParseResolvers([]string{" 10.0.0.53", "192.168.1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"10.0.0.53", "192.168.1.1"}; !equalStrings(got, want) {
+		t.Errorf("ParseResolvers = %v, want %v", got, want)
+	}
+	for _, bad := range []string{"dns.corp.example", "fd00::53", "10.0.0.53:53", ""} {
+		if _, err := ParseResolvers([]string{bad}); err == nil {
+			t.Errorf("ParseResolvers(%q) succeeded, want an error", bad)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package network
+
+import (
+	"net"
+	"slices"
+	"strings"
+)
+
+// DoHHosts are well-known DNS-over-HTTPS endpoints. A guest process could
+// use them to resolve names outside the policy's DNS, and tunnel data in
+// the queries, through an allowed CDN. Subdomains match too.
+var DoHHosts = []string{
+	"dns.google",
+	"dns.google.com",
+	"cloudflare-dns.com",
+	"one.one.one.one",
+	"dns.quad9.net",
+	"dns9.quad9.net",
+	"doh.opendns.com",
+	"dns.adguard.com",
+	"dns.adguard-dns.com",
+	"dns.nextdns.io",
+	"doh.cleanbrowsing.org",
+	"doh.mullvad.net",
+	"doh.dns.sb",
+}
+
+// DoHAddrs are the addresses of public resolvers that also answer
+// DNS-over-HTTPS by IP
+var DoHAddrs = []string{
+	"8.8.8.8", "8.8.4.4",
+	"1.1.1.1", "1.0.0.1",
+	"9.9.9.9", "149.112.112.112",
+	"208.67.222.222", "208.67.220.220",
+	"94.140.14.14", "94.140.15.15",
+}
+
+// DoTPort is the DNS-over-TLS port
+const DoTPort = 853
+
+// IsDoH reports whether host, a name or an address, is a well-known
+// DNS-over-HTTPS endpoint
+func IsDoH(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if ip := net.ParseIP(host); ip != nil {
+		return slices.Contains(DoHAddrs, ip.String())
+	}
+	for _, doh := range DoHHosts {
+		if host == doh || strings.HasSuffix(host, "."+doh) {
+			return true
+		}
+	}
+	return false
+}
+
+// BlocksDoH reports whether host is a DNS-over-HTTPS endpoint the policy
+// doesn't list explicitly. Wildcards, presets, and IP ranges that happen to
+// cover an endpoint don't count: only an entry for the name or the single
+// address allows it.
+func (p *Policy) BlocksDoH(host string) bool {
+	if p == nil || p.AllowAll || !IsDoH(host) {
+		return false
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return !slices.Contains(p.CIDRs, ip.String()+"/32")
+	}
+	return !slices.Contains(p.Domains, strings.TrimSuffix(strings.ToLower(host), "."))
+}
@@ -0,0 +1,64 @@
+package network
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsDoH(t *testing.T) {
+	for host, want := range map[string]bool{
+		"dns.google":                 true,
+		"DNS.Google.":                true,
+		"mozilla.cloudflare-dns.com": true,
+		"8.8.8.8":                    true,
+		"1.1.1.1":                    true,
+		"google.com":                 false,
+		"notcloudflare-dns.com":      false,
+		"140.82.114.4":               false,
+	} {
+		if got := IsDoH(host); got != want {
+			t.Errorf("IsDoH(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
+
+func TestPolicyBlocksDoH(t *testing.T) {
+	policy := Parse([]string{"dns.google", "*.cloudflare-dns.com", "1.0.0.0/8", "9.9.9.9"})
+	for host, want := range map[string]bool{
+		"dns.google":         false, // listed by name
+		"cloudflare-dns.com": true,  // only covered by a wildcard
+		"1.1.1.1":            true,  // only covered by a range
+		"9.9.9.9":            false, // listed by address
+		"github.com":         false, // not a DoH endpoint
+	} {
+		if got := policy.BlocksDoH(host); got != want {
+			t.Errorf("BlocksDoH(%q) = %v, want %v", host, got, want)
+		}
+	}
+	if (&Policy{AllowAll: true}).BlocksDoH("dns.google") {
+		t.Error("Expected an unrestricted policy not to block DoH")
+	}
+}
+
+func TestProxyBlocksDoH(t *testing.T) {
+	log := &lockedBuffer{}
+	p := NewProxy(Parse([]string{"*.cloudflare-dns.com", "dns.google"}), log)
+	asked := false
+	p.SetApprover(func(host, port string) Decision {
+		asked = true
+		return DecisionSession
+	})
+
+	if p.check("CONNECT", "mozilla.cloudflare-dns.com", "443") {
+		t.Error("Expected a DoH endpoint only covered by a wildcard to be refused")
+	}
+	if asked {
+		t.Error("Expected DoH endpoints not to be put to the user")
+	}
+	if !p.check("CONNECT", "dns.google", "443") {
+		t.Error("Expected an explicitly allowed DoH endpoint to be allowed")
+	}
+	if want := ProxyLogPrefix + "DENY CONNECT mozilla.cloudflare-dns.com:443"; !strings.Contains(log.String(), want) {
+		t.Errorf("log %q missing %q", log.String(), want)
+	}
+}
@@ -0,0 +1,103 @@
+package network
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// CaptureFile is the pcap file name the guest writes in the bootstrap share.
+// tcpdump rotates it as capture.pcap0, capture.pcap1, ...
+const CaptureFile = "capture.pcap"
+
+// pcapHeaderSize is the size of the pcap global header
+const pcapHeaderSize = 24
+
+// CaptureFiles returns a session's rotated capture files in the bootstrap
+// directory, oldest first
+func CaptureFiles(bootstrapDir string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(bootstrapDir, CaptureFile+"*"))
+	if err != nil {
+		return nil, err
+	}
+
+	type capture struct {
+		path string
+		info os.FileInfo
+	}
+	var captures []capture
+	for _, path := range matches {
+		info, err := os.Stat(path)
+		if err != nil || info.Size() < pcapHeaderSize {
+			continue
+		}
+		captures = append(captures, capture{path, info})
+	}
+	sort.Slice(captures, func(i, j int) bool {
+		return captures[i].info.ModTime().Before(captures[j].info.ModTime())
+	})
+
+	files := make([]string, len(captures))
+	for i, c := range captures {
+		files[i] = c.path
+	}
+	return files, nil
+}
+
+// MergePcap writes the given pcap files to w as a single capture. Rotated
+// files share a link type, so merging keeps the first global header and
+// appends the packet records of the rest.
+func MergePcap(w io.Writer, files []string) error {
+	var header []byte
+	for _, path := range files {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open capture: %w", err)
+		}
+
+		h := make([]byte, pcapHeaderSize)
+		if _, err := io.ReadFull(f, h); err != nil {
+			_ = f.Close()
+			return fmt.Errorf("failed to read pcap header of %s: %w", filepath.Base(path), err)
+		}
+		if !isPcapMagic(h[:4]) {
+			_ = f.Close()
+			return fmt.Errorf("%s is not a pcap file", filepath.Base(path))
+		}
+
+		if header == nil {
+			header = h
+			if _, err := w.Write(h); err != nil {
+				_ = f.Close()
+				return err
+			}
+		} else if !bytes.Equal(h[:4], header[:4]) || !bytes.Equal(h[20:24], header[20:24]) {
+			_ = f.Close()
+			return fmt.Errorf("%s has a different pcap format than the first capture file", filepath.Base(path))
+		}
+
+		_, err = io.Copy(w, f)
+		_ = f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to copy capture: %w", err)
+		}
+	}
+	return nil
+}
+
+// isPcapMagic reports whether magic is a pcap magic number (micro- or
+// nanosecond timestamps, either byte order)
+func isPcapMagic(magic []byte) bool {
+	for _, m := range [][]byte{
+		{0xd4, 0xc3, 0xb2, 0xa1}, {0xa1, 0xb2, 0xc3, 0xd4},
+		{0x4d, 0x3c, 0xb2, 0xa1}, {0xa1, 0xb2, 0x3c, 0x4d},
+	} {
+		if bytes.Equal(magic, m) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package network
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// pcapHeader returns a little-endian pcap global header with the given link type
+func pcapHeader(linkType byte) []byte {
+	return []byte{0xd4, 0xc3, 0xb2, 0xa1, 2, 0, 4, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 0, 0, linkType, 0, 0, 0}
+}
+
+func writeCapture(t *testing.T, path string, data []byte, mtime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCaptureFiles(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	writeCapture(t, filepath.Join(dir, "capture.pcap1"), pcapHeader(1), now)
+	writeCapture(t, filepath.Join(dir, "capture.pcap0"), pcapHeader(1), now.Add(-time.Minute))
+	writeCapture(t, filepath.Join(dir, "capture.pcap2"), []byte("short"), now)
+	writeCapture(t, filepath.Join(dir, "dns.log"), []byte("query"), now)
+
+	files, err := CaptureFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "capture.pcap0"), filepath.Join(dir, "capture.pcap1")}
+	if len(files) != len(want) || files[0] != want[0] || files[1] != want[1] {
+		t.Errorf("CaptureFiles = %v, want %v", files, want)
+	}
+}
+
+func TestMergePcap(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "capture.pcap0")
+	second := filepath.Join(dir, "capture.pcap1")
+	writeCapture(t, first, append(pcapHeader(1), "AAAA"...), time.Now())
+	writeCapture(t, second, append(pcapHeader(1), "BBBB"...), time.Now())
+
+	var out bytes.Buffer
+	if err := MergePcap(&out, []string{first, second}); err != nil {
+		t.Fatal(err)
+	}
+	want := append(pcapHeader(1), "AAAABBBB"...)
+	if !bytes.Equal(out.Bytes(), want) {
+		t.Errorf("MergePcap = %x, want %x", out.Bytes(), want)
+	}
+}
+
+func TestMergePcapRejectsMismatchedLinkType(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "capture.pcap0")
+	second := filepath.Join(dir, "capture.pcap1")
+	writeCapture(t, first, pcapHeader(1), time.Now())
+	writeCapture(t, second, pcapHeader(113), time.Now())
+
+	if err := MergePcap(&bytes.Buffer{}, []string{first, second}); err == nil {
+		t.Error("Expected error for mismatched link types")
+	}
+}
+
+func TestMergePcapRejectsNonPcap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.pcap0")
+	writeCapture(t, path, bytes.Repeat([]byte("x"), 32), time.Now())
+
+	if err := MergePcap(&bytes.Buffer{}, []string{path}); err == nil {
+		t.Error("Expected error for non-pcap file")
+	}
+}
@@ -0,0 +1,345 @@
+package network
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ProxyLogFile is the egress proxy's connection log in the bootstrap directory
+const ProxyLogFile = "proxy.log"
+
+// ProxyLogPrefix marks egress proxy decisions in ProxyLogFile.
+// Example line: "2026-03-01T12:00:00Z FAIZE_PROXY: ALLOW CONNECT api.anthropic.com:443"
+const ProxyLogPrefix = "FAIZE_PROXY: "
+
+// proxyDialTimeout bounds connecting to an upstream host
+const proxyDialTimeout = 30 * time.Second
+
+// hopHeaders are connection-scoped headers a proxy must not forward
+var hopHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization",
+	"Proxy-Connection", "TE", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+// Proxy is an HTTP proxy that enforces a Policy by host name. It tunnels
+// CONNECT requests (HTTPS and other TLS traffic) and forwards plain HTTP
+// requests in absolute form. Names are resolved on the host, so the policy
+// holds when a CDN rotates its addresses, and connections to loopback,
+// link-local, and multicast addresses are refused so an allowed name can't
+// be pointed at services on the host.
+type Proxy struct {
+	policy atomic.Pointer[Policy]
+
+	// approve is asked about hosts the policy denies; nil denies them
+	approve func(host, port string) Decision
+
+	// up and down throttle what clients send and receive; nil is unlimited
+	up, down *Limiter
+
+	// hosts resolves names before DNS, like the guest's /etc/hosts
+	hosts map[string]net.IP
+
+	// upstream relays connections through another proxy, except to hosts
+	// matching noProxy; nil connects directly
+	upstream *url.URL
+	noProxy  []string
+
+	logMu sync.Mutex
+	log   io.Writer // nil disables logging
+
+	// allowIP reports whether an upstream address may be dialed
+	allowIP   func(net.IP) bool
+	transport *http.Transport
+}
+
+// NewProxy returns a proxy enforcing policy that logs its decisions to log
+func NewProxy(policy *Policy, log io.Writer) *Proxy {
+	p := &Proxy{log: log, allowIP: publicIP}
+	p.policy.Store(policy)
+	p.transport = &http.Transport{
+		Proxy:              p.proxyFor,
+		DialContext:        p.dial,
+		DisableCompression: true,
+		MaxIdleConns:       16,
+		IdleConnTimeout:    90 * time.Second,
+	}
+	return p
+}
+
+// SetPolicy replaces the policy applied to new requests. Tunnels that are
+// already open are not affected.
+func (p *Proxy) SetPolicy(policy *Policy) {
+	p.policy.Store(policy)
+}
+
+// Decision answers a connection the policy denies, as chosen by the user
+type Decision string
+
+const (
+	DecisionDeny    Decision = "deny"
+	DecisionOnce    Decision = "once"    // allow this connection only
+	DecisionSession Decision = "session" // allow the host for the rest of the session
+)
+
+// SetApprover sets a function asked about connections to hosts the policy
+// denies. It may block while the user decides; the connection waits. With
+// DecisionSession the host is added to the policy. Must be called before Serve.
+func (p *Proxy) SetApprover(approve func(host, port string) Decision) {
+	p.approve = approve
+}
+
+// SetRateLimit limits the bandwidth shared by all clients to bitsPerSecond
+// in each direction. Must be called before Serve.
+func (p *Proxy) SetRateLimit(bitsPerSecond uint64) {
+	p.up, p.down = NewLimiter(bitsPerSecond), NewLimiter(bitsPerSecond)
+}
+
+// SetHosts makes the proxy connect to the given address for each host name
+// instead of resolving it, matching the guest's /etc/hosts entries. The
+// allowlist still applies to the name. Must be called before Serve.
+func (p *Proxy) SetHosts(hosts map[string]string) {
+	p.hosts = make(map[string]net.IP, len(hosts))
+	for name, addr := range hosts {
+		if ip := net.ParseIP(addr); ip != nil {
+			p.hosts[strings.ToLower(name)] = ip
+		}
+	}
+}
+
+// SetUpstream relays allowed connections through the HTTP proxy at u, such
+// as a corporate proxy, except to hosts matching noProxy (NO_PROXY syntax)
+// and hosts with SetHosts entries. The allowlist is still checked first.
+// Must be called before Serve.
+func (p *Proxy) SetUpstream(u *url.URL, noProxy []string) {
+	p.upstream, p.noProxy = u, noProxy
+}
+
+// useUpstream reports whether connections to host go through the upstream proxy
+func (p *Proxy) useUpstream(host string) bool {
+	if p.upstream == nil || BypassesProxy(host, p.noProxy) {
+		return false
+	}
+	_, mapped := p.hosts[strings.ToLower(host)]
+	return !mapped
+}
+
+// proxyFor returns the upstream proxy for a plain HTTP request, if any
+func (p *Proxy) proxyFor(req *http.Request) (*url.URL, error) {
+	if !p.useUpstream(req.URL.Hostname()) {
+		return nil, nil
+	}
+	return p.upstream, nil
+}
+
+// Serve accepts proxy clients on l until it is closed
+func (p *Proxy) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go p.ServeConn(conn)
+	}
+}
+
+// ServeConn handles the requests of one client connection and closes it
+func (p *Proxy) ServeConn(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	if p.up != nil {
+		conn = &limitedConn{Conn: conn, read: p.up, write: p.down}
+	}
+
+	br := bufio.NewReader(conn)
+	for {
+		req, err := http.ReadRequest(br)
+		if err != nil {
+			return
+		}
+		if req.Method == http.MethodConnect {
+			p.tunnel(conn, br, req)
+			return
+		}
+		if !p.forward(conn, req) {
+			return
+		}
+	}
+}
+
+// tunnel answers a CONNECT request and relays bytes in both directions
+func (p *Proxy) tunnel(conn net.Conn, br *bufio.Reader, req *http.Request) {
+	host, port := splitHostPort(req.Host, "443")
+	if !p.check(req.Method, host, port) {
+		writeStatus(conn, http.StatusForbidden, "blocked by the faize network policy")
+		return
+	}
+
+	upstream, err := p.dial(req.Context(), "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		writeStatus(conn, http.StatusBadGateway, err.Error())
+		return
+	}
+	defer func() { _ = upstream.Close() }()
+
+	if _, err := io.WriteString(conn, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+		return
+	}
+
+	done := make(chan struct{}, 2)
+	go func() {
+		// Bytes the client sent after the request are already buffered
+		_, _ = io.Copy(upstream, br)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(conn, upstream)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// forward relays a plain HTTP request. Returns false when the client
+// connection can't be reused.
+func (p *Proxy) forward(conn net.Conn, req *http.Request) bool {
+	if req.URL.Host == "" || req.URL.Scheme != "http" {
+		writeStatus(conn, http.StatusBadRequest, "only CONNECT and absolute http:// requests are proxied")
+		return false
+	}
+	host, port := splitHostPort(req.URL.Host, "80")
+	if !p.check(req.Method, host, port) {
+		writeStatus(conn, http.StatusForbidden, "blocked by the faize network policy")
+		return false
+	}
+
+	out := req.Clone(req.Context())
+	out.RequestURI = ""
+	for _, h := range hopHeaders {
+		out.Header.Del(h)
+	}
+
+	resp, err := p.transport.RoundTrip(out)
+	if err != nil {
+		writeStatus(conn, http.StatusBadGateway, err.Error())
+		return false
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if err := resp.Write(conn); err != nil {
+		return false
+	}
+	return !req.Close && !resp.Close
+}
+
+// check applies the policy to host and logs the decision
+func (p *Proxy) check(method, host, port string) bool {
+	portNum, _ := strconv.Atoi(port)
+	policy := p.policy.Load()
+	if policy.BlocksDoH(host) {
+		// DNS-over-HTTPS would bypass the policy's DNS; never prompt for it
+		p.logf("%sDENY %s %s", ProxyLogPrefix, method, net.JoinHostPort(host, port))
+		return false
+	}
+	allowed := policy.AllowsPort(host, portNum)
+	if !allowed && p.approve != nil {
+		switch p.approve(host, port) {
+		case DecisionSession:
+			p.SetPolicy(p.policy.Load().Extend([]string{host}))
+			allowed = true
+		case DecisionOnce:
+			allowed = true
+		}
+	}
+	action := "DENY"
+	if allowed {
+		action = "ALLOW"
+	}
+	p.logf("%s%s %s %s", ProxyLogPrefix, action, method, net.JoinHostPort(host, port))
+	return allowed
+}
+
+// dial connects to the first permitted address of addr's host, or through
+// the upstream proxy. The upstream proxy itself was configured by the user
+// and may be on a local address.
+func (p *Proxy) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, proxyDialTimeout)
+	defer cancel()
+
+	if p.upstream != nil {
+		if addr == p.upstream.Host {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, addr)
+		}
+		if p.useUpstream(host) {
+			return p.dialUpstream(ctx, addr)
+		}
+	}
+
+	var ips []net.IP
+	if ip, ok := p.hosts[strings.ToLower(host)]; ok {
+		ips = []net.IP{ip}
+	} else if ips, err = net.DefaultResolver.LookupIP(ctx, "ip", host); err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", host, err)
+	}
+
+	var dialer net.Dialer
+	lastErr := fmt.Errorf("%s resolves to a local address", host)
+	for _, ip := range ips {
+		if !p.allowIP(ip) {
+			continue
+		}
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// logf appends a timestamped line to the proxy log
+func (p *Proxy) logf(format string, args ...any) {
+	if p.log == nil {
+		return
+	}
+	p.logMu.Lock()
+	defer p.logMu.Unlock()
+	_, _ = fmt.Fprintf(p.log, "%s %s\n", time.Now().UTC().Format(time.RFC3339), fmt.Sprintf(format, args...))
+}
+
+// publicIP reports whether ip may be reached through the proxy
+func publicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsMulticast() && !ip.IsUnspecified()
+}
+
+// splitHostPort splits a request host, falling back to defaultPort
+func splitHostPort(hostport, defaultPort string) (string, string) {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return strings.Trim(hostport, "[]"), defaultPort
+	}
+	return host, port
+}
+
+// writeStatus sends a short plain-text error response
+func writeStatus(w io.Writer, code int, msg string) {
+	_, _ = fmt.Fprintf(w, "HTTP/1.1 %d %s\r\nContent-Type: text/plain\r\nContent-Length: %d\r\n\r\n%s\n",
+		code, http.StatusText(code), len(msg)+1, msg)
+}
@@ -0,0 +1,323 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// startProxy serves a proxy for policy on a loopback listener. Loopback
+// upstreams are allowed so tests can use httptest servers.
+func startProxy(t *testing.T, policy *Policy) (string, *lockedBuffer) {
+	t.Helper()
+	log := &lockedBuffer{}
+	p := NewProxy(policy, log)
+	p.allowIP = func(net.IP) bool { return true }
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	go func() { _ = p.Serve(l) }()
+	return l.Addr().String(), log
+}
+
+// lockedBuffer lets the test read the log while the proxy writes it
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestPolicyAllows(t *testing.T) {
+	policy := &Policy{Domains: []string{"api.anthropic.com"}, Wildcards: []string{"*.github.com"}}
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"api.anthropic.com", true},
+		{"API.Anthropic.com.", true},
+		{"anthropic.com", false},
+		{"evil.api.anthropic.com", false},
+		{"github.com", true},
+		{"api.github.com", true},
+		{"a.b.github.com", true},
+		{"notgithub.com", false},
+		{"github.com.evil.io", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := policy.Allows(tt.host); got != tt.want {
+			t.Errorf("Allows(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+
+	if (&Policy{Blocked: true}).Allows("api.anthropic.com") {
+		t.Error("Blocked policy must deny")
+	}
+	if !(&Policy{AllowAll: true}).Allows("example.com") {
+		t.Error("AllowAll policy must allow")
+	}
+	var nilPolicy *Policy
+	if nilPolicy.Allows("example.com") {
+		t.Error("nil policy must deny")
+	}
+}
+
+func TestPolicyAllowsPort(t *testing.T) {
+	policy := Parse([]string{"10.0.0.0/8", "192.168.1.5:5432", "*.example.com:443", "db.internal:5432", "fd00::/8"})
+	tests := []struct {
+		host string
+		port int
+		want bool
+	}{
+		{"10.1.2.3", 22, true},
+		{"11.0.0.1", 22, false},
+		{"192.168.1.5", 5432, true},
+		{"192.168.1.5", 22, false},
+		{"api.example.com", 443, true},
+		{"api.example.com", 80, false},
+		{"db.internal", 5432, true},
+		{"db.internal", 0, true},
+		{"[fd00::1]", 443, true},
+		{"fe80::1", 443, false},
+		{"10.example.com", 443, true},
+	}
+	for _, tt := range tests {
+		if got := policy.AllowsPort(tt.host, tt.port); got != tt.want {
+			t.Errorf("AllowsPort(%q, %d) = %v, want %v", tt.host, tt.port, got, tt.want)
+		}
+	}
+	if !policy.Allows("192.168.1.5") || policy.Allows("192.168.1.6") {
+		t.Error("Allows must match port rules on any port")
+	}
+}
+
+func TestProxyConnect(t *testing.T) {
+	upstream, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = upstream.Close() }()
+	go func() {
+		conn, err := upstream.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		_, _ = io.WriteString(conn, "echo "+line)
+	}()
+	_, port, _ := net.SplitHostPort(upstream.Addr().String())
+
+	addr, log := startProxy(t, &Policy{Domains: []string{"localhost"}})
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	// The first tunnelled bytes arrive together with the request
+	fmt.Fprintf(conn, "CONNECT localhost:%s HTTP/1.1\r\nHost: localhost:%s\r\n\r\nhello\n", port, port)
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("CONNECT status = %d, want 200", resp.StatusCode)
+	}
+	line, err := br.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "echo hello\n" {
+		t.Errorf("tunnel returned %q", line)
+	}
+	if want := ProxyLogPrefix + "ALLOW CONNECT localhost:" + port; !strings.Contains(log.String(), want) {
+		t.Errorf("log %q missing %q", log.String(), want)
+	}
+}
+
+func TestProxyDenies(t *testing.T) {
+	addr, log := startProxy(t, &Policy{Domains: []string{"api.anthropic.com"}})
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	fmt.Fprint(conn, "CONNECT example.com:443 HTTP/1.1\r\nHost: example.com:443\r\n\r\n")
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("status = %d, want 403", resp.StatusCode)
+	}
+	if want := ProxyLogPrefix + "DENY CONNECT example.com:443"; !strings.Contains(log.String(), want) {
+		t.Errorf("log %q missing %q", log.String(), want)
+	}
+}
+
+func TestProxySetPolicy(t *testing.T) {
+	policy := &Policy{Domains: []string{"api.anthropic.com"}}
+	p := NewProxy(policy, nil)
+	if p.check("CONNECT", "example.com", "443") {
+		t.Fatal("example.com allowed before the policy was extended")
+	}
+	p.SetPolicy(policy.Extend([]string{"example.com"}))
+	if !p.check("CONNECT", "example.com", "443") || !p.check("CONNECT", "api.anthropic.com", "443") {
+		t.Error("extended policy not applied")
+	}
+}
+
+func TestProxyChecksPorts(t *testing.T) {
+	p := NewProxy(Parse([]string{"db.internal:5432", "*.example.com:443"}), nil)
+	if !p.check("CONNECT", "db.internal", "5432") || !p.check("CONNECT", "www.example.com", "443") {
+		t.Error("port rules must allow their port")
+	}
+	if p.check("CONNECT", "db.internal", "22") || p.check("GET", "www.example.com", "80") {
+		t.Error("port rules must deny other ports")
+	}
+}
+
+func TestProxyApprover(t *testing.T) {
+	log := &lockedBuffer{}
+	p := NewProxy(&Policy{Domains: []string{"api.anthropic.com"}}, log)
+	answers := map[string]Decision{"once.example.com": DecisionOnce, "session.example.com": DecisionSession}
+	asked := 0
+	p.SetApprover(func(host, port string) Decision {
+		asked++
+		if d, ok := answers[host]; ok {
+			return d
+		}
+		return DecisionDeny
+	})
+
+	if !p.check("CONNECT", "once.example.com", "443") || !p.check("CONNECT", "session.example.com", "443") {
+		t.Fatal("approved hosts denied")
+	}
+	if p.check("CONNECT", "denied.example.com", "443") {
+		t.Error("host denied by the approver was allowed")
+	}
+	if !p.check("CONNECT", "session.example.com", "443") || !p.check("CONNECT", "api.anthropic.com", "443") {
+		t.Error("allowed hosts denied")
+	}
+	if asked != 3 {
+		t.Errorf("approver asked %d times, want 3 (a session approval is remembered, allowed hosts never ask)", asked)
+	}
+	p.check("CONNECT", "once.example.com", "443")
+	if asked != 4 {
+		t.Error("a one-time approval must not be remembered")
+	}
+	if want := ProxyLogPrefix + "ALLOW CONNECT once.example.com:443"; !strings.Contains(log.String(), want) {
+		t.Errorf("log %q missing %q", log.String(), want)
+	}
+}
+
+func TestProxyHTTP(t *testing.T) {
+	var gotProxyHeader string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotProxyHeader = r.Header.Get("Proxy-Authorization")
+		_, _ = io.WriteString(w, "ok "+r.URL.Path)
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+
+	addr, log := startProxy(t, &Policy{Domains: []string{"localhost"}})
+	proxyURL, _ := url.Parse("http://" + addr)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+	req, _ := http.NewRequest(http.MethodGet, "http://localhost:"+port+"/path", nil)
+	req.Header.Set("Proxy-Authorization", "secret")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if string(body) != "ok /path" {
+		t.Errorf("body = %q", body)
+	}
+	if gotProxyHeader != "" {
+		t.Error("Proxy-Authorization must not be forwarded")
+	}
+	if want := ProxyLogPrefix + "ALLOW GET localhost:" + port; !strings.Contains(log.String(), want) {
+		t.Errorf("log %q missing %q", log.String(), want)
+	}
+
+	resp, err = client.Get("http://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("denied status = %d, want 403", resp.StatusCode)
+	}
+}
+
+func TestProxyRefusesLocalAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	p := NewProxy(&Policy{Domains: []string{"localhost"}}, nil)
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+	if _, err := p.dial(t.Context(), "tcp", "localhost:"+port); err == nil {
+		t.Error("Expected dialing a loopback address to fail")
+	}
+
+	for _, ip := range []string{"127.0.0.1", "::1", "169.254.169.254", "0.0.0.0", "224.0.0.1"} {
+		if publicIP(net.ParseIP(ip)) {
+			t.Errorf("publicIP(%s) = true", ip)
+		}
+	}
+	if !publicIP(net.ParseIP("104.18.32.47")) {
+		t.Error("publicIP(104.18.32.47) = false")
+	}
+}
+
+func TestProxyHosts(t *testing.T) {
+	upstream, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = upstream.Close() }()
+	_, port, _ := net.SplitHostPort(upstream.Addr().String())
+
+	p := NewProxy(&Policy{Domains: []string{"db.local"}}, nil)
+	p.allowIP = func(net.IP) bool { return true }
+	p.SetHosts(map[string]string{"DB.local": "127.0.0.1"})
+
+	conn, err := p.dial(t.Context(), "tcp", "db.local:"+port)
+	if err != nil {
+		t.Fatalf("Expected db.local to connect to its hosts entry: %v", err)
+	}
+	_ = conn.Close()
+
+	// The address is still checked like a resolved one
+	p.allowIP = publicIP
+	if _, err := p.dial(t.Context(), "tcp", "db.local:"+port); err == nil {
+		t.Error("Expected a hosts entry pointing at loopback to be refused")
+	}
+}
@@ -0,0 +1,130 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateUnits maps the tc rate units to bits per second. As in tc, "bit"
+// units count bits and "bps" units count bytes.
+var rateUnits = map[string]uint64{
+	"bit":  1,
+	"kbit": 1000,
+	"mbit": 1000 * 1000,
+	"gbit": 1000 * 1000 * 1000,
+	"bps":  8,
+	"kbps": 8 * 1000,
+	"mbps": 8 * 1000 * 1000,
+	"gbps": 8 * 1000 * 1000 * 1000,
+}
+
+// minRate keeps a bandwidth limit high enough for the guest to stay usable
+const minRate = 64 * 1000
+
+// ParseRate parses a bandwidth limit like "10mbit" or "500kbps" (tc rate
+// syntax) into bits per second
+func ParseRate(s string) (uint64, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	i := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i <= 0 {
+		return 0, fmt.Errorf("invalid rate %q: expected a number with a unit like 10mbit or 2mbps", s)
+	}
+	unit, ok := rateUnits[s[i:]]
+	if !ok {
+		return 0, fmt.Errorf("invalid rate unit %q (use bit, kbit, mbit, gbit, bps, kbps, mbps or gbps)", s[i:])
+	}
+	n, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate %q: %w", s, err)
+	}
+	rate := uint64(n * float64(unit))
+	if rate < minRate {
+		return 0, fmt.Errorf("rate %q is below the minimum of 64kbit", s)
+	}
+	return rate, nil
+}
+
+// FormatRate formats bits per second for display
+func FormatRate(bitsPerSecond uint64) string {
+	for _, u := range []struct {
+		name string
+		size uint64
+	}{{"gbit", 1000 * 1000 * 1000}, {"mbit", 1000 * 1000}, {"kbit", 1000}} {
+		if bitsPerSecond >= u.size && bitsPerSecond%u.size == 0 {
+			return fmt.Sprintf("%d%s", bitsPerSecond/u.size, u.name)
+		}
+	}
+	return fmt.Sprintf("%dbit", bitsPerSecond)
+}
+
+// Limiter is a token bucket shared by the connections it throttles
+type Limiter struct {
+	rate  float64 // bytes per second
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter returns a limiter allowing bitsPerSecond with a tenth of a
+// second's worth of burst
+func NewLimiter(bitsPerSecond uint64) *Limiter {
+	rate := float64(bitsPerSecond) / 8
+	burst := max(rate/10, 16*1024)
+	return &Limiter{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// chunk returns the largest read that keeps transfers smooth
+func (l *Limiter) chunk(n int) int {
+	return min(n, int(l.burst))
+}
+
+// wait blocks until n bytes may pass
+func (l *Limiter) wait(n int) {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens -= float64(n)
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+	time.Sleep(delay)
+}
+
+// limitedConn throttles reads with one limiter and writes with another
+type limitedConn struct {
+	net.Conn
+	read, write *Limiter
+}
+
+// Read reads at most a burst of data and waits for it to fit the read limit
+func (c *limitedConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p[:c.read.chunk(len(p))])
+	if n > 0 {
+		c.read.wait(n)
+	}
+	return n, err
+}
+
+// Write writes p in bursts, waiting for each to fit the write limit
+func (c *limitedConn) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		n := c.write.chunk(len(p) - written)
+		c.write.wait(n)
+		m, err := c.Conn.Write(p[written : written+n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
@@ -0,0 +1,76 @@
+package network
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    uint64
+		wantErr bool
+	}{
+		{"10mbit", 10_000_000, false},
+		{"10Mbit", 10_000_000, false},
+		{"1.5gbit", 1_500_000_000, false},
+		{"500kbit", 500_000, false},
+		{"2mbps", 16_000_000, false},
+		{"100000bit", 100_000, false},
+		{"10", 0, true},
+		{"mbit", 0, true},
+		{"10mb", 0, true},
+		{"1kbit", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRate(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRate(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseRate(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFormatRate(t *testing.T) {
+	for rate, want := range map[uint64]string{
+		10_000_000:    "10mbit",
+		1_500_000_000: "1500mbit",
+		500_000:       "500kbit",
+		100_001:       "100001bit",
+	} {
+		if got := FormatRate(rate); got != want {
+			t.Errorf("FormatRate(%d) = %q, want %q", rate, got, want)
+		}
+	}
+}
+
+func TestLimitedConn(t *testing.T) {
+	client, server := net.Pipe()
+	defer func() { _ = client.Close() }()
+
+	// 1 Mbit/s with a 16 KiB burst: 64 KiB needs about 0.4s
+	limit := NewLimiter(1_000_000)
+	conn := &limitedConn{Conn: server, read: limit, write: limit}
+	go func() {
+		defer func() { _ = conn.Close() }()
+		_, _ = conn.Write(make([]byte, 64*1024))
+	}()
+
+	start := time.Now()
+	n, err := io.Copy(io.Discard, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 64*1024 {
+		t.Fatalf("read %d bytes, want %d", n, 64*1024)
+	}
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Errorf("64 KiB at 1mbit took %s, want at least 300ms", elapsed)
+	}
+}
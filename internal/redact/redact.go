@@ -0,0 +1,183 @@
+// Package redact masks tokens and credentials in text faize writes to logs
+// and session output.
+package redact
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Mask replaces redacted text
+const Mask = "[REDACTED]"
+
+// builtinPatterns match common token formats. When a pattern has a capture
+// group, only the first group is masked so the surrounding context (a JSON key,
+// "Bearer", a query parameter name) stays readable.
+var builtinPatterns = []string{
+	// Anthropic API keys and OAuth tokens
+	`sk-ant-[A-Za-z0-9_-]{16,}`,
+	// OpenAI-style API keys
+	`sk-(?:proj-)?[A-Za-z0-9_-]{32,}`,
+	// GitHub tokens
+	`gh[pousr]_[A-Za-z0-9]{30,}`,
+	// GitHub fine-grained tokens
+	`github_pat_[A-Za-z0-9_]{22,}`,
+	// Slack tokens
+	`xox[abposr]-[A-Za-z0-9-]{10,}`,
+	// AWS access key IDs
+	`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`,
+	// JWTs
+	`eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`,
+	// Authorization headers
+	`(?i)\bbearer\s+([A-Za-z0-9._~+/=-]{16,})`,
+	// Credential fields in JSON
+	`(?i)"(?:access_?token|refresh_?token|id_?token|client_?secret|api_?key|password)"\s*:\s*"([^"]+)"`,
+	// OAuth query parameters
+	`(?i)[?&](?:code|state|access_token|refresh_token|token)=([^&\s"'#]+)`,
+}
+
+// Redactor masks matches of a set of patterns
+type Redactor struct {
+	patterns []*regexp.Regexp
+}
+
+// New returns a Redactor for the built-in patterns plus extra user patterns
+func New(extra []string) (*Redactor, error) {
+	r := &Redactor{}
+	for _, p := range builtinPatterns {
+		r.patterns = append(r.patterns, regexp.MustCompile(p))
+	}
+	for _, p := range extra {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %q: %w", p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// String returns s with every match masked
+func (r *Redactor) String(s string) string {
+	for _, re := range r.patterns {
+		s = mask(re, s)
+	}
+	return s
+}
+
+// Bytes returns b with every match masked
+func (r *Redactor) Bytes(b []byte) []byte {
+	return []byte(r.String(string(b)))
+}
+
+// mask replaces each match of re in s, or just its first capture group when it has one
+func mask(re *regexp.Regexp, s string) string {
+	matches := re.FindAllStringSubmatchIndex(s, -1)
+	if matches == nil {
+		return s
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if len(m) >= 4 && m[2] >= 0 {
+			start, end = m[2], m[3]
+		}
+		b.WriteString(s[last:start])
+		b.WriteString(Mask)
+		last = end
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+var (
+	defaultMu       sync.RWMutex
+	defaultRedactor = mustNew()
+)
+
+func mustNew() *Redactor {
+	r, _ := New(nil)
+	return r
+}
+
+// Configure sets the extra patterns used by the package-level functions.
+// On error the previous patterns stay in effect.
+func Configure(extra []string) error {
+	r, err := New(extra)
+	if err != nil {
+		return err
+	}
+	defaultMu.Lock()
+	defaultRedactor = r
+	defaultMu.Unlock()
+	return nil
+}
+
+// Default returns the configured Redactor
+func Default() *Redactor {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultRedactor
+}
+
+// String masks s with the configured patterns
+func String(s string) string {
+	return Default().String(s)
+}
+
+// Bytes masks b with the configured patterns
+func Bytes(b []byte) []byte {
+	return Default().Bytes(b)
+}
+
+// Writer masks text on its way to an underlying writer. Output is buffered
+// until a newline so tokens split across writes are still caught; Flush
+// writes any trailing partial line.
+type Writer struct {
+	w   io.Writer
+	r   *Redactor
+	buf []byte
+}
+
+// maxPending bounds how much of an unterminated line is held back
+const maxPending = 64 << 10
+
+// NewWriter returns a Writer that masks with r, or the configured patterns when r is nil
+func NewWriter(w io.Writer, r *Redactor) *Writer {
+	if r == nil {
+		r = Default()
+	}
+	return &Writer{w: w, r: r}
+}
+
+// Write masks complete lines and holds back a trailing partial line
+func (w *Writer) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	cut := bytes.LastIndexByte(w.buf, '\n') + 1
+	if cut == 0 && len(w.buf) < maxPending {
+		return len(p), nil
+	}
+	if cut == 0 {
+		cut = len(w.buf)
+	}
+	if _, err := w.w.Write(w.r.Bytes(w.buf[:cut])); err != nil {
+		return 0, err
+	}
+	w.buf = append(w.buf[:0], w.buf[cut:]...)
+	return len(p), nil
+}
+
+// Flush writes any buffered partial line
+func (w *Writer) Flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	_, err := w.w.Write(w.r.Bytes(w.buf))
+	w.buf = w.buf[:0]
+	return err
+}
@@ -0,0 +1,138 @@
+package rootfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// agentBuildScript builds the guest agent from the source tree on stdin and
+// writes the binary to stdout
+const agentBuildScript = `set -e
+apk add --no-cache go >/dev/null
+mkdir -p /src
+tar -x -f - -C /src
+cd /src
+CGO_ENABLED=0 GOFLAGS=-buildvcs=false go build -trimpath -ldflags="-s -w" -o /tmp/faize-agent ./cmd/faize-agent >&2
+cat /tmp/faize-agent
+`
+
+// BuildAgent builds faize-agent, the guest agent, for the guest from the
+// faize source tree: with the host's Go toolchain if it has one, or else
+// with runner
+func BuildAgent(ctx context.Context, runner Runner) ([]byte, error) {
+	src, err := findSource()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := exec.LookPath("go"); err == nil {
+		return buildAgentLocal(ctx, src)
+	}
+
+	pr, pw := io.Pipe()
+	go func() { pw.CloseWithError(tarSource(src, pw)) }()
+	defer func() { _ = pr.Close() }()
+	var out bytes.Buffer
+	if err := runner.Run(ctx, agentBuildScript, pr, &out); err != nil {
+		return nil, fmt.Errorf("failed to build faize-agent: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// buildAgentLocal cross-compiles the guest agent with the host's Go
+// toolchain
+func buildAgentLocal(ctx context.Context, src string) ([]byte, error) {
+	tmp, err := os.MkdirTemp("", "faize-agent-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+
+	bin := filepath.Join(tmp, "faize-agent")
+	cmd := exec.CommandContext(ctx, "go", "build", "-trimpath", "-ldflags=-s -w", "-o", bin, "./cmd/faize-agent")
+	cmd.Dir = src
+	cmd.Env = append(os.Environ(), "CGO_ENABLED=0", "GOOS=linux", "GOARCH="+runtime.GOARCH, "GOFLAGS=-buildvcs=false")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("failed to build faize-agent: %w\n%s", err, out)
+	}
+	return os.ReadFile(bin)
+}
+
+// findSource locates the faize source tree the guest agent is built from,
+// next to the faize binary: the tree above it (installed) or the one it was
+// built in. The working directory is never searched, as building there
+// would bake a project's code into the guest agent.
+func findSource() (string, error) {
+	execPath, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate the faize binary: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(execPath); err == nil {
+		execPath = resolved
+	}
+	binDir := filepath.Dir(execPath)
+	if src, ok := sourceNextTo(binDir); ok {
+		return src, nil
+	}
+	return "", fmt.Errorf("faize-agent source not found next to the faize binary (%s): "+
+		"building rootfs images needs faize built from its source tree (make build) or installed with it", binDir)
+}
+
+// sourceNextTo returns the faize source tree above or at binDir, if any
+func sourceNextTo(binDir string) (string, bool) {
+	for _, dir := range []string{filepath.Join(binDir, ".."), binDir} {
+		if _, err := os.Stat(filepath.Join(dir, "cmd", "faize-agent")); err == nil {
+			return filepath.Clean(dir), true
+		}
+	}
+	return "", false
+}
+
+// tarSource writes what building the guest agent needs from the source
+// tree: the module files and the packages under cmd/ and internal/,
+// without their tests
+func tarSource(src string, w io.Writer) error {
+	tw := tar.NewWriter(w)
+	for _, name := range []string{"go.mod", "go.sum"} {
+		if err := addSourceFile(tw, src, name); err != nil {
+			return err
+		}
+	}
+	for _, dir := range []string{"cmd", "internal"} {
+		err := filepath.WalkDir(filepath.Join(src, dir), func(path string, d fs.DirEntry, err error) error {
+			if err != nil || !d.Type().IsRegular() || strings.HasSuffix(path, "_test.go") {
+				return err
+			}
+			rel, err := filepath.Rel(src, path)
+			if err != nil {
+				return err
+			}
+			return addSourceFile(tw, src, rel)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// addSourceFile adds the file at rel in src to tw
+func addSourceFile(tw *tar.Writer, src, rel string) error {
+	data, err := os.ReadFile(filepath.Join(src, rel))
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: filepath.ToSlash(rel), Mode: 0644, Size: int64(len(data))}); err != nil {
+		return err
+	}
+	_, err = tw.Write(data)
+	return err
+}
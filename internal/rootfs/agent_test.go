@@ -0,0 +1,36 @@
+package rootfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSourceNextTo(t *testing.T) {
+	src := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "cmd", "faize-agent"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "bin"), 0755))
+
+	// Installed under the tree, or built in it
+	found, ok := sourceNextTo(filepath.Join(src, "bin"))
+	assert.True(t, ok)
+	assert.Equal(t, src, found)
+	found, ok = sourceNextTo(src)
+	assert.True(t, ok)
+	assert.Equal(t, src, found)
+
+	_, ok = sourceNextTo(filepath.Join(src, "cmd", "faize-agent"))
+	assert.False(t, ok)
+}
+
+func TestFindSourceIgnoresWorkingDirectory(t *testing.T) {
+	// A project holding what looks like faize's source isn't built from
+	project := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(project, "cmd", "faize-agent"), 0755))
+	t.Chdir(filepath.Join(project, "cmd"))
+	_, err := findSource()
+	assert.ErrorContains(t, err, "not found next to the faize binary")
+}
@@ -0,0 +1,546 @@
+// Package rootfs builds faize's guest root filesystems: a base tree, such as
+// the Alpine minirootfs, and stages applied to it in turn, each kept as a
+// cached layer, packed into an ext4 image at the end.
+package rootfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Epoch is the latest modification time a file in a built image has, and
+// the time the image's filesystem is created at, so the same inputs build
+// the same image
+var Epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Runner runs the build steps that need Linux: a shell script run as root
+// in an Alpine Linux environment with network access, where apk add works,
+// reading stdin and writing stdout
+type Runner interface {
+	Run(ctx context.Context, script string, stdin io.Reader, stdout io.Writer) error
+}
+
+// ImageExporter is a Runner that can also write the filesystem of a
+// container image as a tar archive, for builds based on an image
+type ImageExporter interface {
+	ExportImage(ctx context.Context, image string, w io.Writer) error
+}
+
+// Base is the tree a build starts from: a gzipped tar archive downloaded
+// and checked against its published SHA-256 checksum, or a container image
+type Base struct {
+	URL       string
+	SHA256URL string
+	Image     string
+}
+
+// File is a regular file, directory or symlink a stage adds to the tree
+type File struct {
+	Path     string      // relative to the root, e.g. "usr/local/bin/faize-agent"
+	Mode     os.FileMode // permission bits, plus os.ModeDir or os.ModeSymlink
+	Data     []byte      // the contents, or a symlink's target
+	UID, GID int
+}
+
+// Stage is a step of a build. The tree after it is cached as a layer, keyed
+// by the stage and everything before it, so a build only runs the stages
+// after the first one that changed.
+type Stage struct {
+	Name   string
+	Script string   // run by the Runner with the tree at /rootfs, where it leaves the next tree
+	Files  []File   // or added to the tree without a Runner
+	Inputs []string // anything else the stage's result depends on, e.g. a version it installs
+}
+
+// Spec describes an image to build
+type Spec struct {
+	Name     string // tells apart the cached layers of different images, e.g. "claude-rootfs"
+	Base     Base
+	Stages   []Stage
+	Label    string // the ext4 volume label
+	SizeMB   int    // the ext4 filesystem size
+	Manifest string // script printing the image's manifest, run in the final tree; "" for none
+}
+
+// Builder builds images from specs, keeping each stage's result as a
+// gzipped tar archive in CacheDir
+type Builder struct {
+	Runner   Runner
+	CacheDir string
+	Out      io.Writer // progress
+}
+
+// layerPrelude unpacks the previous layer, read from stdin, at /rootfs and
+// gives commands run in it with chroot device nodes and DNS
+const layerPrelude = `set -e
+apk add --no-cache tar >/dev/null
+mkdir -p /rootfs
+tar -x -p --numeric-owner -f - -C /rootfs
+mkdir -p /rootfs/dev /rootfs/etc
+for node in null:1:3 zero:1:5 full:1:7 random:1:8 urandom:1:9 tty:5:0; do
+    name=${node%%:*}
+    nums=${node#*:}
+    [ -e "/rootfs/dev/$name" ] || mknod -m 666 "/rootfs/dev/$name" c "${nums%:*}" "${nums#*:}"
+done
+rm -f /rootfs/etc/resolv.conf
+cp /etc/resolv.conf /rootfs/etc/resolv.conf
+`
+
+// layerCleanup removes what layerPrelude set up; the guest mounts /dev and
+// writes its own resolv.conf
+const layerCleanup = `
+rm -rf /rootfs/dev/* /rootfs/etc/resolv.conf
+`
+
+// Build builds the image spec describes at output, and its manifest, if
+// it has one, next to it as output.manifest
+func (b *Builder) Build(ctx context.Context, spec *Spec, output string) error {
+	if err := os.MkdirAll(b.CacheDir, 0755); err != nil {
+		return fmt.Errorf("failed to create build cache: %w", err)
+	}
+	steps := len(spec.Stages) + 2
+
+	l, err := b.base(ctx, spec, steps)
+	if err != nil {
+		return err
+	}
+	for i, stage := range spec.Stages {
+		if l, err = b.stage(ctx, spec, stage, l, i+2, steps); err != nil {
+			return fmt.Errorf("failed to build %s stage: %w", stage.Name, err)
+		}
+	}
+
+	b.progress("==> [%d/%d] image (%dMB)\n", steps, steps, spec.SizeMB)
+	if err := b.image(ctx, spec, l, output); err != nil {
+		return fmt.Errorf("failed to create ext4 image: %w", err)
+	}
+	return nil
+}
+
+// layer is a cached stage result
+type layer struct {
+	key  string
+	path string
+}
+
+// layerPath returns where the layer of spec's stage with key is cached
+func (b *Builder) layerPath(spec *Spec, stage, key string) string {
+	return filepath.Join(b.CacheDir, fmt.Sprintf("%s-%s-%s.tar.gz", spec.Name, stage, key[:16]))
+}
+
+// stageKey derives a layer's cache key from the one before it and the
+// stage's definition
+func stageKey(parent string, stage Stage) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00", parent, stage.Name, stage.Script)
+	for _, f := range stage.Files {
+		fmt.Fprintf(h, "%s\x00%o\x00%d:%d\x00", f.Path, f.Mode, f.UID, f.GID)
+		sum := sha256.Sum256(f.Data)
+		h.Write(sum[:])
+	}
+	for _, input := range stage.Inputs {
+		fmt.Fprintf(h, "%s\x00", input)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// base returns the layer of the spec's base tree, downloading or exporting
+// it unless it is cached
+func (b *Builder) base(ctx context.Context, spec *Spec, steps int) (layer, error) {
+	var inputs []string
+	var fill func(*tar.Writer) error
+	switch {
+	case spec.Base.URL != "":
+		sum, err := fetchChecksum(ctx, spec.Base.SHA256URL)
+		if err != nil {
+			return layer{}, err
+		}
+		inputs = []string{spec.Base.URL, sum}
+		fill = func(tw *tar.Writer) error {
+			return b.downloadBase(ctx, spec.Base.URL, sum, tw)
+		}
+	case spec.Base.Image != "":
+		inputs = []string{spec.Base.Image}
+		fill = func(tw *tar.Writer) error {
+			exporter, ok := b.Runner.(ImageExporter)
+			if !ok {
+				return fmt.Errorf("building from the %s image needs Docker", spec.Base.Image)
+			}
+			pr, pw := io.Pipe()
+			go func() { pw.CloseWithError(exporter.ExportImage(ctx, spec.Base.Image, pw)) }()
+			defer func() { _ = pr.Close() }()
+			return copyNormalized(tw, tar.NewReader(pr))
+		}
+	default:
+		return layer{}, fmt.Errorf("%s has no base", spec.Name)
+	}
+
+	key := stageKey("", Stage{Name: "base", Inputs: inputs})
+	l := layer{key: key, path: b.layerPath(spec, "base", key)}
+	if b.cached(l, 1, steps, "base") {
+		return l, nil
+	}
+	if err := b.writeLayer(spec, "base", l, fill); err != nil {
+		return layer{}, fmt.Errorf("failed to build base: %w", err)
+	}
+	return l, nil
+}
+
+// downloadBase downloads a gzipped tar archive, checks it against sum and
+// writes its entries to tw
+func (b *Builder) downloadBase(ctx context.Context, url, sum string, tw *tar.Writer) error {
+	tmp, err := os.CreateTemp(b.CacheDir, "download-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+	if err := download(ctx, url, sum, tmp, b.Out); err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	zr, err := gzip.NewReader(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", url, err)
+	}
+	return copyNormalized(tw, tar.NewReader(zr))
+}
+
+// stage returns the layer after stage, building it from parent unless it
+// is cached
+func (b *Builder) stage(ctx context.Context, spec *Spec, stage Stage, parent layer, step, steps int) (layer, error) {
+	key := stageKey(parent.key, stage)
+	l := layer{key: key, path: b.layerPath(spec, stage.Name, key)}
+	if b.cached(l, step, steps, stage.Name) {
+		return l, nil
+	}
+
+	if stage.Script == "" {
+		return l, b.writeLayer(spec, stage.Name, l, func(tw *tar.Writer) error {
+			return addFiles(tw, parent.path, stage.Files)
+		})
+	}
+	return l, b.writeLayer(spec, stage.Name, l, func(tw *tar.Writer) error {
+		// The script's output goes to stderr, as stdout carries the layer
+		script := layerPrelude + "{\n" + stage.Script + "\n} </dev/null >&2\n" + layerCleanup +
+			"tar -c --sort=name --numeric-owner -f - -C /rootfs .\n"
+		return b.runLayer(ctx, parent.path, script, func(out io.Reader) error {
+			return copyNormalized(tw, tar.NewReader(out))
+		})
+	})
+}
+
+// cached reports whether l is in the cache, noting the step as cached if so
+func (b *Builder) cached(l layer, step, steps int, name string) bool {
+	if _, err := os.Stat(l.path); err == nil {
+		b.progress("==> [%d/%d] %s (cached)\n", step, steps, name)
+		return true
+	}
+	b.progress("==> [%d/%d] %s\n", step, steps, name)
+	return false
+}
+
+// runLayer runs script with the layer at path, uncompressed, on its stdin,
+// handing its stdout to read
+func (b *Builder) runLayer(ctx context.Context, path, script string, read func(io.Reader) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("failed to read cached layer %s: %w", path, err)
+	}
+
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := read(pr)
+		// Drain what the script still writes so it can exit
+		_, _ = io.Copy(io.Discard, pr)
+		done <- err
+	}()
+	runErr := b.Runner.Run(ctx, script, zr, pw)
+	_ = pw.CloseWithError(runErr)
+	readErr := <-done
+	if runErr != nil {
+		return runErr
+	}
+	return readErr
+}
+
+// writeLayer writes a layer with fill, replacing the spec's earlier layers
+// of the stage
+func (b *Builder) writeLayer(spec *Spec, stage string, l layer, fill func(*tar.Writer) error) error {
+	tmp, err := os.CreateTemp(b.CacheDir, "layer-*")
+	if err != nil {
+		return fmt.Errorf("failed to create layer: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	zw, _ := gzip.NewWriterLevel(tmp, gzip.BestSpeed)
+	tw := tar.NewWriter(zw)
+	err = fill(tw)
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	old, _ := filepath.Glob(filepath.Join(b.CacheDir, fmt.Sprintf("%s-%s-*.tar.gz", spec.Name, stage)))
+	if err := os.Rename(tmp.Name(), l.path); err != nil {
+		return fmt.Errorf("failed to save layer: %w", err)
+	}
+	for _, p := range old {
+		if p != l.path {
+			_ = os.Remove(p)
+		}
+	}
+	return nil
+}
+
+// copyNormalized copies a tar archive's entries, dropping what varies from
+// build to build: modification times after Epoch, access and change times,
+// user and group names (ownership is kept by ID) and extended headers
+func copyNormalized(tw *tar.Writer, tr *tar.Reader) error {
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read layer: %w", err)
+		}
+		normalize(hdr)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// normalize clears the parts of hdr that vary from build to build
+func normalize(hdr *tar.Header) {
+	if hdr.ModTime.After(Epoch) {
+		hdr.ModTime = Epoch
+	}
+	hdr.ModTime = hdr.ModTime.Truncate(time.Second)
+	hdr.AccessTime = time.Time{}
+	hdr.ChangeTime = time.Time{}
+	hdr.Uname, hdr.Gname = "", ""
+	hdr.PAXRecords = nil
+	hdr.Format = tar.FormatUnknown
+}
+
+// entryPath returns a tar entry's path relative to the root, e.g.
+// "usr/bin" for "./usr/bin/"
+func entryPath(name string) string {
+	return strings.Trim(path.Clean("/"+name), "/")
+}
+
+// addFiles copies the layer at parent, leaving out the entries files
+// replace, and adds files after it in path order
+func addFiles(tw *tar.Writer, parent string, files []File) error {
+	replaced := make(map[string]bool, len(files))
+	for _, f := range files {
+		replaced[entryPath(f.Path)] = true
+	}
+
+	f, err := os.Open(parent)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("failed to read cached layer %s: %w", parent, err)
+	}
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read cached layer %s: %w", parent, err)
+		}
+		if replaced[entryPath(hdr.Name)] {
+			continue
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+
+	sorted := append([]File(nil), files...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Path < sorted[j].Path })
+	for _, file := range sorted {
+		hdr := &tar.Header{
+			Name:    "./" + entryPath(file.Path),
+			Mode:    int64(file.Mode.Perm()),
+			Uid:     file.UID,
+			Gid:     file.GID,
+			ModTime: Epoch,
+		}
+		switch {
+		case file.Mode&os.ModeDir != 0:
+			hdr.Typeflag = tar.TypeDir
+			hdr.Name += "/"
+		case file.Mode&os.ModeSymlink != 0:
+			hdr.Typeflag = tar.TypeSymlink
+			hdr.Linkname = string(file.Data)
+		default:
+			hdr.Typeflag = tar.TypeReg
+			hdr.Size = int64(len(file.Data))
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			if _, err := tw.Write(file.Data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// image packs the final layer into an ext4 image at output, with the same
+// UUID and timestamps for the same layer, and writes the manifest
+func (b *Builder) image(ctx context.Context, spec *Spec, l layer, output string) error {
+	id := uuid.NewSHA1(uuid.Nil, []byte(spec.Name+"\x00"+l.key)).String()
+	var script strings.Builder
+	script.WriteString(layerPrelude)
+	script.WriteString("apk add --no-cache e2fsprogs >/dev/null\nmkdir -p /out\n")
+	if spec.Manifest != "" {
+		script.WriteString("mkdir -p /rootfs/tmp\n")
+		fmt.Fprintf(&script, "cat > /rootfs/tmp/faize-manifest.sh << 'FAIZE_MANIFEST'\n%s\nFAIZE_MANIFEST\n", spec.Manifest)
+		script.WriteString("chroot /rootfs /bin/sh /tmp/faize-manifest.sh > /out/manifest\nrm -f /rootfs/tmp/faize-manifest.sh\n")
+	}
+	script.WriteString(layerCleanup)
+	fmt.Fprintf(&script, "export E2FSPROGS_FAKE_TIME=%d\n", Epoch.Unix())
+	fmt.Fprintf(&script, "mke2fs -q -t ext4 -d /rootfs -L %s -U %s -E no_copy_xattrs,hash_seed=%s,root_owner=0:0 -b 4096 /out/rootfs.img %dM\n",
+		spec.Label, id, id, spec.SizeMB)
+	script.WriteString("e2fsck -f -y /out/rootfs.img >/dev/null 2>&1 || true\n")
+	script.WriteString("tar -c -S -f - -C /out .\n")
+
+	tmp := output + ".tmp"
+	var manifest []byte
+	err := b.runLayer(ctx, l.path, script.String(), func(out io.Reader) error {
+		tr := tar.NewReader(out)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			switch entryPath(hdr.Name) {
+			case "manifest":
+				if manifest, err = io.ReadAll(tr); err != nil {
+					return err
+				}
+			case "rootfs.img":
+				if err := writeSparse(tmp, tr); err != nil {
+					return err
+				}
+			}
+		}
+	})
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, output); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if spec.Manifest != "" {
+		// Written after the image: faize claude doctor flags a manifest older than it
+		if err := os.WriteFile(output+".manifest", manifest, 0644); err != nil {
+			return fmt.Errorf("failed to write manifest: %w", err)
+		}
+	}
+	return nil
+}
+
+// writeSparse writes r to a new file at path, seeking over blocks of
+// zeros instead of writing them
+func writeSparse(path string, r io.Reader) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	const block = 4096
+	buf := make([]byte, 256*block)
+	zero := make([]byte, block)
+	var size int64
+	for {
+		n, readErr := io.ReadFull(r, buf)
+		for off := 0; off < n; off += block {
+			chunk := buf[off:min(off+block, n)]
+			if bytes.Equal(chunk, zero[:len(chunk)]) {
+				_, err = f.Seek(int64(len(chunk)), io.SeekCurrent)
+			} else {
+				_, err = f.Write(chunk)
+			}
+			if err != nil {
+				_ = f.Close()
+				return err
+			}
+		}
+		size += int64(n)
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			_ = f.Close()
+			return readErr
+		}
+	}
+	// A trailing hole needs the size set explicitly
+	if err := f.Truncate(size); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// progress prints a progress line, if the builder has somewhere to
+func (b *Builder) progress(format string, args ...any) {
+	if b.Out != nil {
+		fmt.Fprintf(b.Out, format, args...)
+	}
+}
@@ -0,0 +1,309 @@
+package rootfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tarEntry is a file of a test archive
+type tarEntry struct {
+	name string
+	data string
+}
+
+// makeTar returns a tar archive of entries
+func makeTar(t *testing.T, entries ...tarEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     e.name,
+			Mode:     0644,
+			Size:     int64(len(e.data)),
+			Typeflag: tar.TypeReg,
+			ModTime:  time.Now(),
+			Uname:    "builder",
+		}))
+		_, err := tw.Write([]byte(e.data))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	return buf.Bytes()
+}
+
+// gzipped compresses data
+func gzipped(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+// readTar returns the names and contents of a tar archive's files
+func readTar(t *testing.T, r io.Reader) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		require.NoError(t, err)
+		data, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[hdr.Name] = string(data)
+	}
+}
+
+// readLayer returns the names and contents of a cached layer's files
+func readLayer(t *testing.T, path string) map[string]string {
+	t.Helper()
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+	zr, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	return readTar(t, zr)
+}
+
+// fakeRunner stands in for Docker: stage scripts pass the tree through
+// unchanged and the image step returns a fixed image and manifest
+type fakeRunner struct {
+	scripts []string
+}
+
+func (f *fakeRunner) Run(_ context.Context, script string, stdin io.Reader, stdout io.Writer) error {
+	f.scripts = append(f.scripts, script)
+	if !strings.Contains(script, "mke2fs") {
+		_, err := io.Copy(stdout, stdin)
+		return err
+	}
+	if _, err := io.Copy(io.Discard, stdin); err != nil {
+		return err
+	}
+	image := append(bytes.Repeat([]byte{0}, 8192), "ext4"...)
+	tw := tar.NewWriter(stdout)
+	for _, e := range []struct {
+		name string
+		data []byte
+	}{{"./manifest", []byte("claude=1.0.0\n")}, {"./rootfs.img", image}} {
+		if err := tw.WriteHeader(&tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.data))}); err != nil {
+			return err
+		}
+		if _, err := tw.Write(e.data); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// serveBase serves a base archive and its checksum file
+func serveBase(t *testing.T, archive []byte) *httptest.Server {
+	t.Helper()
+	sum := sha256.Sum256(archive)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/base.tar.gz":
+			_, _ = w.Write(archive)
+		case "/base.tar.gz.sha256":
+			_, _ = io.WriteString(w, hex.EncodeToString(sum[:])+"  base.tar.gz\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestStageKey(t *testing.T) {
+	stage := Stage{Name: "packages", Script: "apk add bash", Files: []File{{Path: "init", Mode: 0755, Data: []byte("a")}}}
+	key := stageKey("parent", stage)
+	assert.Equal(t, key, stageKey("parent", stage))
+	assert.NotEqual(t, key, stageKey("other", stage))
+
+	changed := stage
+	changed.Script = "apk add bash git"
+	assert.NotEqual(t, key, stageKey("parent", changed))
+
+	changed = stage
+	changed.Files = []File{{Path: "init", Mode: 0755, Data: []byte("b")}}
+	assert.NotEqual(t, key, stageKey("parent", changed))
+
+	changed = stage
+	changed.Inputs = []string{"1.0.0"}
+	assert.NotEqual(t, key, stageKey("parent", changed))
+}
+
+func TestNormalize(t *testing.T) {
+	hdr := &tar.Header{
+		Name:       "usr/bin/tool",
+		ModTime:    time.Now(),
+		AccessTime: time.Now(),
+		ChangeTime: time.Now(),
+		Uname:      "root",
+		Gname:      "root",
+		Uid:        1000,
+		PAXRecords: map[string]string{"SCHILY.xattr.user.x": "y"},
+	}
+	normalize(hdr)
+	assert.Equal(t, Epoch, hdr.ModTime)
+	assert.True(t, hdr.AccessTime.IsZero())
+	assert.True(t, hdr.ChangeTime.IsZero())
+	assert.Empty(t, hdr.Uname)
+	assert.Empty(t, hdr.Gname)
+	assert.Equal(t, 1000, hdr.Uid)
+	assert.Nil(t, hdr.PAXRecords)
+
+	old := time.Date(2020, 5, 1, 12, 0, 0, 500, time.UTC)
+	hdr = &tar.Header{Name: "etc/hosts", ModTime: old}
+	normalize(hdr)
+	assert.Equal(t, old.Truncate(time.Second), hdr.ModTime)
+}
+
+func TestAddFiles(t *testing.T) {
+	parent := filepath.Join(t.TempDir(), "parent.tar.gz")
+	require.NoError(t, os.WriteFile(parent, gzipped(t, makeTar(t,
+		tarEntry{"./etc/hosts", "localhost"},
+		tarEntry{"./init", "old init"},
+	)), 0644))
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	require.NoError(t, addFiles(tw, parent, []File{
+		{Path: "usr/local/bin/faize-agent", Mode: 0755, Data: []byte("agent")},
+		{Path: "init", Mode: 0755, Data: []byte("new init")},
+		{Path: "bin/sh", Mode: os.ModeSymlink | 0777, Data: []byte("busybox")},
+	}))
+	require.NoError(t, tw.Close())
+
+	var names []string
+	tr := tar.NewReader(&buf)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, hdr.Name)
+		switch hdr.Name {
+		case "./init":
+			data, _ := io.ReadAll(tr)
+			assert.Equal(t, "new init", string(data))
+			assert.Equal(t, int64(0755), hdr.Mode)
+		case "./bin/sh":
+			assert.Equal(t, byte(tar.TypeSymlink), hdr.Typeflag)
+			assert.Equal(t, "busybox", hdr.Linkname)
+		}
+	}
+	assert.Equal(t, []string{"./etc/hosts", "./bin/sh", "./init", "./usr/local/bin/faize-agent"}, names)
+}
+
+func TestWriteSparse(t *testing.T) {
+	data := append(append([]byte("head"), make([]byte, 3*4096)...), "tail"...)
+	data = append(data, make([]byte, 4096)...)
+	path := filepath.Join(t.TempDir(), "rootfs.img")
+	require.NoError(t, writeSparse(path, bytes.NewReader(data)))
+
+	got, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+}
+
+func TestFetchChecksum(t *testing.T) {
+	srv := serveBase(t, []byte("archive"))
+	sum, err := fetchChecksum(context.Background(), srv.URL+"/base.tar.gz.sha256")
+	require.NoError(t, err)
+	want := sha256.Sum256([]byte("archive"))
+	assert.Equal(t, hex.EncodeToString(want[:]), sum)
+
+	_, err = fetchChecksum(context.Background(), srv.URL+"/base.tar.gz")
+	assert.Error(t, err)
+	_, err = fetchChecksum(context.Background(), srv.URL+"/missing")
+	assert.Error(t, err)
+}
+
+func TestDownloadChecksumMismatch(t *testing.T) {
+	srv := serveBase(t, []byte("archive"))
+	var buf bytes.Buffer
+	err := download(context.Background(), srv.URL+"/base.tar.gz", strings.Repeat("0", 64), &buf, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "checksum mismatch")
+}
+
+func TestBuild(t *testing.T) {
+	srv := serveBase(t, gzipped(t, makeTar(t, tarEntry{"./etc/os-release", "alpine"})))
+	runner := &fakeRunner{}
+	b := &Builder{Runner: runner, CacheDir: t.TempDir()}
+	spec := func(agent string) *Spec {
+		return &Spec{
+			Name: "test-rootfs",
+			Base: Base{URL: srv.URL + "/base.tar.gz", SHA256URL: srv.URL + "/base.tar.gz.sha256"},
+			Stages: []Stage{
+				{Name: "packages", Script: "chroot /rootfs apk add bash"},
+				{Name: "faize", Files: []File{agentFile([]byte(agent))}},
+			},
+			Label:    "faize-test",
+			SizeMB:   16,
+			Manifest: "echo claude=1.0.0",
+		}
+	}
+	output := filepath.Join(t.TempDir(), "rootfs.img")
+
+	require.NoError(t, b.Build(context.Background(), spec("v1"), output))
+	require.Len(t, runner.scripts, 2)
+	assert.Contains(t, runner.scripts[0], "chroot /rootfs apk add bash")
+	assert.Contains(t, runner.scripts[1], "-L faize-test")
+
+	img, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Equal(t, append(bytes.Repeat([]byte{0}, 8192), "ext4"...), img)
+	manifest, err := os.ReadFile(output + ".manifest")
+	require.NoError(t, err)
+	assert.Equal(t, "claude=1.0.0\n", string(manifest))
+
+	layers, err := filepath.Glob(filepath.Join(b.CacheDir, "test-rootfs-faize-*.tar.gz"))
+	require.NoError(t, err)
+	require.Len(t, layers, 1)
+	files := readLayer(t, layers[0])
+	assert.Equal(t, "alpine", files["./etc/os-release"])
+	assert.Equal(t, "v1", files["./usr/local/bin/faize-agent"])
+
+	// A new agent only repeats the stage adding it, and the image
+	runner.scripts = nil
+	require.NoError(t, b.Build(context.Background(), spec("v2"), output))
+	require.Len(t, runner.scripts, 1)
+	assert.Contains(t, runner.scripts[0], "mke2fs")
+
+	layers, err = filepath.Glob(filepath.Join(b.CacheDir, "test-rootfs-faize-*.tar.gz"))
+	require.NoError(t, err)
+	require.Len(t, layers, 1)
+	assert.Equal(t, "v2", readLayer(t, layers[0])["./usr/local/bin/faize-agent"])
+}
+
+func TestBuildImageBaseNeedsExporter(t *testing.T) {
+	b := &Builder{Runner: &fakeRunner{}, CacheDir: t.TempDir()}
+	spec := &Spec{Name: "test-rootfs", Base: Base{Image: "debian:bookworm-slim"}, SizeMB: 16}
+	err := b.Build(context.Background(), spec, filepath.Join(t.TempDir(), "rootfs.img"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "needs Docker")
+}
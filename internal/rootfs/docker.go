@@ -0,0 +1,49 @@
+package rootfs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// DockerRunner runs build steps in throwaway Docker containers
+type DockerRunner struct {
+	Image  string    // the Alpine image steps run in; AlpineImage if empty
+	Stderr io.Writer // where the steps' output goes
+}
+
+// Run runs script with sh in a new container of the runner's image
+func (d *DockerRunner) Run(ctx context.Context, script string, stdin io.Reader, stdout io.Writer) error {
+	image := d.Image
+	if image == "" {
+		image = AlpineImage
+	}
+	cmd := exec.CommandContext(ctx, "docker", "run", "--rm", "-i", image, "sh", "-c", script)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = stdin, stdout, d.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("build step failed in Docker: %w", err)
+	}
+	return nil
+}
+
+// ExportImage writes the filesystem of a container created from image,
+// pulling it if it isn't local, as a tar archive
+func (d *DockerRunner) ExportImage(ctx context.Context, image string, w io.Writer) error {
+	cmd := exec.CommandContext(ctx, "docker", "create", image, "/bin/true")
+	cmd.Stderr = d.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("failed to create a container from %s: %w", image, err)
+	}
+	id := strings.TrimSpace(string(out))
+	defer func() { _ = exec.Command("docker", "rm", id).Run() }()
+
+	cmd = exec.CommandContext(ctx, "docker", "export", id)
+	cmd.Stdout, cmd.Stderr = w, d.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to export %s: %w", image, err)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package rootfs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// claudeLatestURL is the npm registry entry for the newest Claude Code release
+const claudeLatestURL = "https://registry.npmjs.org/@anthropic-ai/claude-code/latest"
+
+// get requests url, failing unless the response is 200 OK
+func get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("failed to download %s: HTTP %d", url, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// fetchChecksum returns the SHA-256 checksum published at url, a file
+// whose first field is the hex digest
+func fetchChecksum(ctx context.Context, url string) (string, error) {
+	resp, err := get(ctx, url)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 || len(fields[0]) != sha256.Size*2 {
+		return "", fmt.Errorf("no SHA-256 checksum in %s", url)
+	}
+	if _, err := hex.DecodeString(fields[0]); err != nil {
+		return "", fmt.Errorf("no SHA-256 checksum in %s", url)
+	}
+	return strings.ToLower(fields[0]), nil
+}
+
+// download copies url to w, reporting progress to out, and fails unless
+// its SHA-256 checksum is sum
+func download(ctx context.Context, url, sum string, w io.Writer, out io.Writer) error {
+	resp, err := get(ctx, url)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	h := sha256.New()
+	var body io.Reader = resp.Body
+	if out != nil {
+		body = &progressReader{r: resp.Body, total: resp.ContentLength, out: out}
+	}
+	if _, err := io.Copy(io.MultiWriter(w, h), body); err != nil {
+		return fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	if out != nil {
+		fmt.Fprintln(out)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != sum {
+		return fmt.Errorf("checksum mismatch for %s: got %s, want %s", url, got, sum)
+	}
+	return nil
+}
+
+// DownloadAlpine downloads the Alpine minirootfs of AlpineVersion to path,
+// unless it is already there, checking it against its published checksum
+func DownloadAlpine(ctx context.Context, path string, out io.Writer) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	base := alpineBase()
+	sum, err := fetchChecksum(ctx, base.SHA256URL)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", tmp, err)
+	}
+	err = download(ctx, base.URL, sum, f, out)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// progressReader prints how much of a download has been read, at most
+// every half second
+type progressReader struct {
+	r       io.Reader
+	total   int64
+	read    int64
+	printed time.Time
+	out     io.Writer
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.read += int64(n)
+	if now := time.Now(); now.Sub(p.printed) >= 500*time.Millisecond || err == io.EOF {
+		p.printed = now
+		if p.total > 0 {
+			fmt.Fprintf(p.out, "\r    %.1f of %.1f MB", float64(p.read)/1e6, float64(p.total)/1e6)
+		} else {
+			fmt.Fprintf(p.out, "\r    %.1f MB", float64(p.read)/1e6)
+		}
+	}
+	return n, err
+}
+
+// LatestClaudeVersion asks the npm registry for the newest Claude Code
+// version
+func LatestClaudeVersion(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	resp, err := get(ctx, claudeLatestURL)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var pkg struct {
+		Version string `json:"version"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&pkg); err != nil {
+		return "", fmt.Errorf("failed to parse npm registry response: %w", err)
+	}
+	return pkg.Version, nil
+}
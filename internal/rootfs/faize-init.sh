@@ -0,0 +1,73 @@
+#!/bin/sh
+# Faize VM init - overlay root, shared by the Claude and OCI image rootfs builds
+# Stage 1: Set up overlay so all rootfs writes go to tmpfs (discarded on shutdown),
+# or to the project's overlay disk named by faize.overlay= on the kernel command line.
+# A rootfs snapshot named by faize.snapshot= (a tar archive) seeds the writable layer.
+
+export PATH=/usr/local/bin:/usr/bin:/bin:/usr/sbin:/sbin
+
+# Mount essential virtual filesystems
+/bin/mount -t proc proc /proc 2>/dev/null || true
+/bin/mount -t sysfs sys /sys 2>/dev/null || true
+/bin/mount -t devtmpfs dev /dev 2>/dev/null || true
+
+# Set up overlay (writable layer over read-only rootfs)
+OVERLAY_DEV=$(/bin/sed -n 's/.*faize\.overlay=\([^ ]*\).*/\1/p' /proc/cmdline)
+SNAPSHOT_DEV=$(/bin/sed -n 's/.*faize\.snapshot=\([^ ]*\).*/\1/p' /proc/cmdline)
+if /bin/grep -q overlay /proc/filesystems; then
+    /bin/mount -t tmpfs -o size=512M tmpfs /tmp
+    /bin/mkdir -p /tmp/overlay/merged /tmp/overlay/lower
+    LAYER=/tmp/overlay
+    if [ -n "$OVERLAY_DEV" ]; then
+        # Persistent overlay disk: formatted on first use, kept between sessions
+        /bin/mkdir -p /tmp/overlay/disk
+        if ! blkid "$OVERLAY_DEV" >/dev/null 2>&1; then
+            mkfs.ext4 -q -F -m 0 -L faize-overlay "$OVERLAY_DEV" >/dev/null 2>&1
+        fi
+        if /bin/mount -t ext4 "$OVERLAY_DEV" /tmp/overlay/disk 2>/dev/null; then
+            LAYER=/tmp/overlay/disk
+        else
+            echo "WARNING: rootfs overlay disk $OVERLAY_DEV could not be mounted - rootfs changes will not persist"
+        fi
+    fi
+    /bin/mkdir -p "$LAYER/upper" "$LAYER/work"
+    if [ -n "$SNAPSHOT_DEV" ] && ! tar -x -f "$SNAPSHOT_DEV" -C "$LAYER/upper" 2>/dev/null; then
+        echo "WARNING: rootfs snapshot $SNAPSHOT_DEV could not be restored"
+    fi
+    /bin/mount --bind / /tmp/overlay/lower
+    /bin/mount -t overlay overlay \
+        -o lowerdir=/tmp/overlay/lower,upperdir="$LAYER/upper",workdir="$LAYER/work" \
+        /tmp/overlay/merged
+
+    # Pivot into the overlay root
+    cd /tmp/overlay/merged
+    /bin/mkdir -p old_root
+    pivot_root . old_root
+
+    # Re-mount essentials in the new overlay root
+    /bin/mount -t proc proc /proc 2>/dev/null || true
+    /bin/mount -t sysfs sys /sys 2>/dev/null || true
+    /bin/mount -t devtmpfs dev /dev 2>/dev/null || true
+
+    # Keep the writable layer readable for faize snapshot save
+    /bin/mkdir -p /mnt/rootfs-upper
+    /bin/mount --bind "/old_root$LAYER/upper" /mnt/rootfs-upper 2>/dev/null &&
+        /bin/mount -o remount,bind,ro /mnt/rootfs-upper 2>/dev/null || true
+
+    # Detach old root (overlay keeps internal references to lower layer)
+    /bin/umount -l /old_root 2>/dev/null || true
+else
+    echo "WARNING: overlayfs not available - rootfs is read-only, some operations may fail"
+fi
+
+# Stage 2: Mount bootstrap and hand off
+/bin/mkdir -p /mnt/bootstrap
+if /bin/mount -t virtiofs faize-bootstrap /mnt/bootstrap 2>/dev/null; then
+    if [ -x /mnt/bootstrap/init.sh ]; then
+        exec /mnt/bootstrap/init.sh
+    fi
+fi
+
+# Fallback to shell
+echo "Faize Claude: bootstrap mount failed or no init.sh found"
+exec /bin/sh
@@ -0,0 +1,211 @@
+package rootfs
+
+import (
+	_ "embed"
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+const (
+	// AlpineVersion is the Alpine Linux release the images are built on
+	AlpineVersion = "3.22.1"
+	// AlpineImage is the container image build steps run in, of the same
+	// release
+	AlpineImage = "alpine:3.22"
+)
+
+// flavorImages are the container images the Claude rootfs flavors other
+// than Alpine start from
+var flavorImages = map[string]string{
+	"debian": "debian:bookworm-slim",
+	"ubuntu": "ubuntu:24.04",
+}
+
+// initScript is the Claude rootfs's /init: overlay root, persistent overlay
+// disk and snapshot support (shared with scripts/build-image-rootfs.sh)
+//
+//go:embed faize-init.sh
+var initScript []byte
+
+// baseInitScript is the base rootfs's /init: an ephemeral overlay root
+//
+//go:embed base-init.sh
+var baseInitScript []byte
+
+// toolchainEnv is /opt/toolchain/env.sh, populated by the first boot
+const toolchainEnv = `#!/bin/bash
+# Toolchain environment (populated by first boot)
+export PATH=/opt/toolchain/bin:/usr/local/bin:$PATH
+`
+
+// Packages of the Claude rootfs: Alpine's, and the same tools on Debian and
+// Ubuntu, with the ones busybox provides on Alpine (udhcpc, ifconfig,
+// killall) installed separately
+var (
+	alpinePackages = []string{"bash", "curl", "ca-certificates", "git", "build-base", "python3", "coreutils",
+		"nodejs", "npm", "util-linux", "iptables", "ip6tables", "dnsmasq", "tcpdump", "iproute2-tc", "ipset",
+		"openssh-server", "e2fsprogs"}
+	aptPackages = []string{"bash", "curl", "ca-certificates", "git", "build-essential", "python3", "coreutils",
+		"nodejs", "npm", "util-linux", "iptables", "dnsmasq-base", "tcpdump", "iproute2", "ipset",
+		"openssh-server", "e2fsprogs", "udhcpc", "net-tools", "psmisc", "procps"}
+)
+
+// claudeLayout makes the directories the init and guest agent expect and
+// the non-root claude user that runs the Claude CLI; %s adds the user
+const claudeLayout = `
+mkdir -p /rootfs/mnt/bootstrap /rootfs/mnt/host-claude /rootfs/opt/toolchain /rootfs/workspace \
+    /rootfs/usr/local/bin /rootfs/usr/local/lib /rootfs/var/empty /rootfs/run /rootfs/tmp
+chmod 1777 /rootfs/tmp
+chroot /rootfs %s
+# No password, but not locked: sshd refuses key logins to locked (!) accounts
+sed -i 's/^claude:!/claude:*/' /rootfs/etc/shadow
+mkdir -p /rootfs/home/claude/.claude
+chroot /rootfs chown -R claude:claude /home/claude
+`
+
+// claudeManifest records tool versions so 'faize claude doctor' can check
+// the image without booting it
+const claudeManifest = `export PATH=/usr/local/bin:/usr/bin:/bin HOME=/tmp/faize-manifest
+version() { command -v "$1" >/dev/null 2>&1 && "$1" --version 2>/dev/null | head -n 1; }
+echo "claude=$(version claude)"
+echo "node=$(version node)"
+echo "bun=$(version bun)"
+[ -x /usr/local/bin/faize-agent ] && echo "faize-agent=$(/usr/local/bin/faize-agent -version 2>/dev/null || echo 0)"
+rm -rf /tmp/faize-manifest`
+
+// alpineArch returns the Alpine name of the guest's architecture, which is
+// the host's
+func alpineArch() string {
+	switch runtime.GOARCH {
+	case "arm64":
+		return "aarch64"
+	case "amd64":
+		return "x86_64"
+	}
+	return runtime.GOARCH
+}
+
+// alpineBase returns the Alpine minirootfs of AlpineVersion
+func alpineBase() Base {
+	branch := "v" + AlpineVersion[:strings.LastIndex(AlpineVersion, ".")]
+	url := fmt.Sprintf("https://dl-cdn.alpinelinux.org/alpine/%s/releases/%s/alpine-minirootfs-%s-%s.tar.gz",
+		branch, alpineArch(), AlpineVersion, alpineArch())
+	return Base{URL: url, SHA256URL: url + ".sha256"}
+}
+
+// quote quotes s for sh
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// packageArgs quotes packages for a package manager's command line
+func packageArgs(packages []string) string {
+	quoted := make([]string, len(packages))
+	for i, p := range packages {
+		quoted[i] = quote(p)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// agentFile is the guest agent in the tree
+func agentFile(agent []byte) File {
+	return File{Path: "usr/local/bin/faize-agent", Mode: 0755, Data: agent}
+}
+
+// BaseSpec describes the base rootfs: a statically linked busybox, the
+// guest agent and an init with an ephemeral overlay root
+func BaseSpec(agent []byte) *Spec {
+	return &Spec{
+		Name: "rootfs",
+		Base: alpineBase(),
+		Stages: []Stage{
+			{
+				Name: "busybox",
+				Script: `chroot /rootfs apk add --no-cache busybox-static
+mkdir -p /next/bin /next/dev /next/etc /next/mnt/bootstrap /next/proc /next/sys /next/tmp /next/usr/local/bin
+cp /rootfs/bin/busybox.static /next/bin/busybox
+for cmd in sh mount umount mkdir cat ls chmod chown echo setsid grep pivot_root; do
+    ln -s busybox "/next/bin/$cmd"
+done
+rm -rf /rootfs
+mv /next /rootfs`,
+			},
+			{
+				Name: "faize",
+				Files: []File{
+					{Path: "init", Mode: 0755, Data: baseInitScript},
+					agentFile(agent),
+				},
+			},
+		},
+		Label:  "faize-root",
+		SizeMB: 64,
+	}
+}
+
+// ClaudeSpec describes the Claude rootfs of flavor ("" for Alpine, or
+// "debian" or "ubuntu"): development tools and extraDeps, packages of the
+// flavor's distribution, the Claude CLI of claudeVersion ("" for the
+// newest), the guest agent and the init
+func ClaudeSpec(flavor string, extraDeps []string, claudeVersion string, agent []byte) (*Spec, error) {
+	spec := &Spec{
+		Name:     "claude-rootfs",
+		Label:    "faize-claude",
+		SizeMB:   1024,
+		Manifest: claudeManifest,
+	}
+
+	var packages string
+	if flavor == "" {
+		spec.Base = alpineBase()
+		packages = fmt.Sprintf("chroot /rootfs apk add --no-cache %s\n",
+			packageArgs(append(append([]string(nil), alpinePackages...), extraDeps...))) +
+			fmt.Sprintf(claudeLayout, "adduser -D -h /home/claude -s /bin/sh claude")
+	} else {
+		image, ok := flavorImages[flavor]
+		if !ok {
+			return nil, fmt.Errorf("unknown rootfs flavor %q", flavor)
+		}
+		spec.Name += "-" + flavor
+		spec.Base = Base{Image: image}
+		// glibc userlands and build-essential take more room
+		spec.SizeMB = 2048
+		// policy-rc.d keeps packages from starting services in the build
+		packages = `printf '#!/bin/sh\nexit 101\n' > /rootfs/usr/sbin/policy-rc.d
+chmod 755 /rootfs/usr/sbin/policy-rc.d
+chroot /rootfs env DEBIAN_FRONTEND=noninteractive apt-get update
+` + fmt.Sprintf("chroot /rootfs env DEBIAN_FRONTEND=noninteractive apt-get install -y --no-install-recommends %s\n",
+			packageArgs(append(append([]string(nil), aptPackages...), extraDeps...))) +
+			`chroot /rootfs apt-get clean
+rm -rf /rootfs/var/lib/apt/lists/* /rootfs/usr/sbin/policy-rc.d
+mkdir -p /rootfs/run/sshd` +
+			fmt.Sprintf(claudeLayout, "useradd -m -d /home/claude -s /bin/sh claude")
+	}
+
+	cli := "@anthropic-ai/claude-code"
+	if claudeVersion != "" {
+		cli += "@" + claudeVersion
+	}
+	spec.Stages = []Stage{
+		{Name: "packages", Script: packages},
+		{
+			Name: "claude",
+			Script: fmt.Sprintf(`chroot /rootfs npm install -g %s
+if [ ! -x /rootfs/usr/local/bin/claude ]; then
+    echo "Error: claude CLI not found after npm install"
+    exit 1
+fi
+rm -rf /rootfs/root/.npm /rootfs/tmp/*`, quote(cli)),
+		},
+		{
+			Name: "faize",
+			Files: []File{
+				{Path: "init", Mode: 0755, Data: initScript},
+				{Path: "opt/toolchain/env.sh", Mode: 0755, Data: []byte(toolchainEnv)},
+				agentFile(agent),
+			},
+		},
+	}
+	return spec, nil
+}
@@ -0,0 +1,40 @@
+package rootfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClaudeSpec(t *testing.T) {
+	spec, err := ClaudeSpec("", []string{"ripgrep", "it's"}, "1.2.3", []byte("agent"))
+	require.NoError(t, err)
+	assert.Equal(t, "claude-rootfs", spec.Name)
+	assert.NotEmpty(t, spec.Base.URL)
+	assert.Equal(t, spec.Base.URL+".sha256", spec.Base.SHA256URL)
+	assert.Equal(t, 1024, spec.SizeMB)
+	require.Len(t, spec.Stages, 3)
+	assert.Contains(t, spec.Stages[0].Script, "apk add --no-cache 'bash'")
+	assert.Contains(t, spec.Stages[0].Script, `'ripgrep' 'it'\''s'`)
+	assert.Contains(t, spec.Stages[1].Script, "npm install -g '@anthropic-ai/claude-code@1.2.3'")
+	assert.Equal(t, "faize", spec.Stages[2].Name)
+
+	spec, err = ClaudeSpec("debian", nil, "", []byte("agent"))
+	require.NoError(t, err)
+	assert.Equal(t, "claude-rootfs-debian", spec.Name)
+	assert.Equal(t, "debian:bookworm-slim", spec.Base.Image)
+	assert.Equal(t, 2048, spec.SizeMB)
+	assert.Contains(t, spec.Stages[0].Script, "apt-get install -y --no-install-recommends")
+	assert.Contains(t, spec.Stages[1].Script, "npm install -g '@anthropic-ai/claude-code'\n")
+
+	_, err = ClaudeSpec("arch", nil, "", nil)
+	assert.Error(t, err)
+}
+
+func TestBaseSpec(t *testing.T) {
+	spec := BaseSpec([]byte("agent"))
+	assert.Equal(t, "faize-root", spec.Label)
+	require.Len(t, spec.Stages, 2)
+	assert.Equal(t, agentFile([]byte("agent")), spec.Stages[1].Files[1])
+}
@@ -0,0 +1,62 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// OwnerLock is held by the process that runs a session's VM. The operating
+// system releases it when that process exits, however it exits, so other
+// processes can tell a session whose owner is gone from one still running.
+type OwnerLock struct {
+	f    *os.File
+	path string
+}
+
+// ownerLockPath returns the path of a session's owner lock file
+func (s *Store) ownerLockPath(id string) string {
+	return filepath.Join(s.dir, id+".lock")
+}
+
+// LockOwner takes the owner lock of a session for the current process
+func (s *Store) LockOwner(id string) (*OwnerLock, error) {
+	if err := validateSessionID(id); err != nil {
+		return nil, err
+	}
+	path := s.ownerLockPath(id)
+	f, err := lockFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &OwnerLock{f: f, path: path}, nil
+}
+
+// Release gives up the lock once the session's VM has stopped
+func (l *OwnerLock) Release() {
+	if l == nil || l.f == nil {
+		return
+	}
+	_ = os.Remove(l.path)
+	_ = l.f.Close()
+	l.f = nil
+}
+
+// ClearOwner removes the owner lock file of a session whose owner is gone
+func (s *Store) ClearOwner(id string) {
+	if validateSessionID(id) != nil {
+		return
+	}
+	if held, _ := lockHeld(s.ownerLockPath(id)); !held {
+		_ = os.Remove(s.ownerLockPath(id))
+	}
+}
+
+// OwnerAlive reports whether a process holds the session's owner lock. known
+// is false when it can't be told, e.g. for sessions started by versions of
+// faize that didn't take the lock.
+func (s *Store) OwnerAlive(id string) (alive, known bool) {
+	if validateSessionID(id) != nil {
+		return false, false
+	}
+	return lockHeld(s.ownerLockPath(id))
+}
@@ -0,0 +1,55 @@
+//go:build !windows
+
+package session
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOwnerLock(t *testing.T) {
+	store := &Store{dir: t.TempDir()}
+
+	_, known := store.OwnerAlive("abc123")
+	assert.False(t, known, "no lock file")
+
+	owner, err := store.LockOwner("abc123")
+	require.NoError(t, err)
+	alive, known := store.OwnerAlive("abc123")
+	assert.True(t, known)
+	assert.True(t, alive)
+
+	_, err = store.LockOwner("abc123")
+	assert.EqualError(t, err, "session is owned by another process")
+
+	// A held lock survives clearing
+	store.ClearOwner("abc123")
+	alive, _ = store.OwnerAlive("abc123")
+	assert.True(t, alive)
+
+	owner.Release()
+	owner.Release()
+	_, known = store.OwnerAlive("abc123")
+	assert.False(t, known, "releasing removes the lock file")
+
+	_, err = store.LockOwner("../escape")
+	assert.Error(t, err)
+}
+
+func TestOwnerGone(t *testing.T) {
+	store := &Store{dir: t.TempDir()}
+	owner, err := store.LockOwner("abc123")
+	require.NoError(t, err)
+
+	// Closing without removing the file is what a crashed owner leaves behind
+	require.NoError(t, owner.f.Close())
+	alive, known := store.OwnerAlive("abc123")
+	assert.True(t, known)
+	assert.False(t, alive)
+
+	store.ClearOwner("abc123")
+	_, known = store.OwnerAlive("abc123")
+	assert.False(t, known)
+}
@@ -0,0 +1,45 @@
+//go:build !windows
+
+package session
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// lockFile creates path and takes an exclusive lock on it
+func lockFile(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create owner lock: %w", err)
+	}
+	if err := unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB); err != nil {
+		_ = f.Close()
+		if errors.Is(err, unix.EWOULDBLOCK) {
+			return nil, fmt.Errorf("session is owned by another process")
+		}
+		return nil, fmt.Errorf("failed to take owner lock: %w", err)
+	}
+	return f, nil
+}
+
+// lockHeld reports whether another open file holds the lock on path
+func lockHeld(path string) (held, known bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, false
+	}
+	defer func() { _ = f.Close() }()
+	err = unix.Flock(int(f.Fd()), unix.LOCK_SH|unix.LOCK_NB)
+	switch {
+	case err == nil:
+		_ = unix.Flock(int(f.Fd()), unix.LOCK_UN)
+		return false, true
+	case errors.Is(err, unix.EWOULDBLOCK):
+		return true, true
+	}
+	return false, false
+}
@@ -1,15 +1,57 @@
 package session
 
 import (
+	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"time"
 
 	"github.com/mitchellh/go-homedir"
+	bolt "go.etcd.io/bbolt"
 )
 
-// Store manages session persistence at ~/.faize/sessions/
+// DBFile is the session database in the store directory
+const DBFile = "sessions.db"
+
+// lockTimeout is how long an operation waits for another faize process
+// holding the database
+const lockTimeout = 10 * time.Second
+
+// ErrNotFound is returned when no session has the given ID
+var ErrNotFound = errors.New("session not found")
+
+// Buckets of the session database
+var (
+	metaBucket      = []byte("meta")       // schema version
+	sessionsBucket  = []byte("sessions")   // ID -> session JSON
+	byStatusBucket  = []byte("by_status")  // status NUL ID -> nothing
+	byStartedBucket = []byte("by_started") // big-endian start time in ns, ID -> nothing
+)
+
+// schemaKey holds the schema version in the meta bucket
+var schemaKey = []byte("schema")
+
+// migrations bring the database schema up to date; the schema version is
+// the number of migrations applied
+var migrations = []func(tx *bolt.Tx) error{
+	func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{sessionsBucket, byStatusBucket, byStartedBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+// Store manages session persistence in ~/.faize/sessions/. Session records
+// live in a database that several faize processes can use at once; each
+// session's files (console log, bootstrap share) are in a directory named
+// after it.
 type Store struct {
 	dir string
 }
@@ -20,28 +62,189 @@ func NewStore() (*Store, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to get home directory: %w", err)
 	}
-
 	dir := filepath.Join(home, ".faize", "sessions")
 	if err := os.MkdirAll(dir, 0700); err != nil {
 		return nil, fmt.Errorf("failed to create sessions directory: %w", err)
 	}
-
 	return &Store{dir: dir}, nil
 }
 
-// Save persists a session to disk
-func (s *Store) Save(session *Session) error {
-	path := filepath.Join(s.dir, session.ID+".json")
+// open opens the database, creating and migrating it as needed. Read-only
+// handles share the database with other readers; a writable one has it to
+// itself until closed.
+func (s *Store) open(readOnly bool) (*bolt.DB, error) {
+	path := filepath.Join(s.dir, DBFile)
+	if readOnly {
+		if _, err := os.Stat(path); err == nil {
+			db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: lockTimeout, ReadOnly: true})
+			if err != nil {
+				return nil, fmt.Errorf("failed to open session database: %w", err)
+			}
+			var version int
+			err = db.View(func(tx *bolt.Tx) error {
+				version, err = schemaVersion(tx)
+				return err
+			})
+			if err == nil && version == len(migrations) && !s.hasLegacy() {
+				return db, nil
+			}
+			_ = db.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+		// Fall through to create or migrate the database
+	}
 
-	data, err := json.MarshalIndent(session, "", "  ")
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: lockTimeout})
 	if err != nil {
-		return fmt.Errorf("failed to marshal session: %w", err)
+		return nil, fmt.Errorf("failed to open session database: %w", err)
+	}
+	if err := s.migrate(db); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// migrate applies the pending schema migrations and imports session files
+// written by earlier versions. Files that can't be imported are renamed to
+// <name>.invalid so they aren't tried again.
+func (s *Store) migrate(db *bolt.DB) error {
+	legacy, _ := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	var invalid []string
+	err := db.Update(func(tx *bolt.Tx) error {
+		version, err := schemaVersion(tx)
+		if err != nil {
+			return err
+		}
+		for ; version < len(migrations); version++ {
+			if err := migrations[version](tx); err != nil {
+				return fmt.Errorf("failed to migrate session database to version %d: %w", version+1, err)
+			}
+		}
+		meta, err := tx.CreateBucketIfNotExists(metaBucket)
+		if err != nil {
+			return err
+		}
+		if err := meta.Put(schemaKey, binary.BigEndian.AppendUint64(nil, uint64(version))); err != nil {
+			return err
+		}
+
+		for _, path := range legacy {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			var sess Session
+			if json.Unmarshal(data, &sess) != nil || validateSessionID(sess.ID) != nil || sess.ID == "" {
+				invalid = append(invalid, path)
+				continue
+			}
+			if err := put(tx, &sess); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
+	// The records are in the database now
+	for _, path := range legacy {
+		if slices.Contains(invalid, path) {
+			_ = os.Rename(path, path+".invalid")
+		} else {
+			_ = os.Remove(path)
+		}
+	}
+	return nil
+}
+
+// hasLegacy reports whether session files from earlier versions are waiting
+// to be imported, e.g. saved by a session owner started before an upgrade
+func (s *Store) hasLegacy() bool {
+	legacy, _ := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	return len(legacy) > 0
+}
+
+func schemaVersion(tx *bolt.Tx) (int, error) {
+	meta := tx.Bucket(metaBucket)
+	if meta == nil {
+		return 0, nil
+	}
+	v := meta.Get(schemaKey)
+	if len(v) != 8 {
+		return 0, nil
+	}
+	version := int(binary.BigEndian.Uint64(v))
+	if version > len(migrations) {
+		return 0, fmt.Errorf("session database has schema version %d; this faize only knows %d (upgrade faize)", version, len(migrations))
+	}
+	return version, nil
+}
+
+// view runs fn on a read-only transaction
+func (s *Store) view(fn func(tx *bolt.Tx) error) error {
+	db, err := s.open(true)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+	return db.View(fn)
+}
 
-	if err := os.WriteFile(path, data, 0600); err != nil {
-		return fmt.Errorf("failed to write session file: %w", err)
+// update runs fn on a read-write transaction
+func (s *Store) update(fn func(tx *bolt.Tx) error) error {
+	db, err := s.open(false)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+	return db.Update(fn)
+}
+
+func statusKey(status, id string) []byte {
+	return []byte(status + "\x00" + id)
+}
+
+func startedKey(started time.Time, id string) []byte {
+	return append(binary.BigEndian.AppendUint64(nil, uint64(started.UnixNano())), id...)
+}
+
+// put writes a session's record and index entries, replacing its old ones
+func put(tx *bolt.Tx, session *Session) error {
+	sessions := tx.Bucket(sessionsBucket)
+	byStatus := tx.Bucket(byStatusBucket)
+	byStarted := tx.Bucket(byStartedBucket)
+	if old := sessions.Get([]byte(session.ID)); old != nil {
+		var prev Session
+		if json.Unmarshal(old, &prev) == nil {
+			_ = byStatus.Delete(statusKey(prev.Status, prev.ID))
+			_ = byStarted.Delete(startedKey(prev.StartedAt, prev.ID))
+		}
+	}
+	data, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("failed to marshal session: %w", err)
+	}
+	if err := sessions.Put([]byte(session.ID), data); err != nil {
+		return err
 	}
+	if err := byStatus.Put(statusKey(session.Status, session.ID), nil); err != nil {
+		return err
+	}
+	return byStarted.Put(startedKey(session.StartedAt, session.ID), nil)
+}
 
+// Save persists a session to disk
+func (s *Store) Save(session *Session) error {
+	if err := validateSessionID(session.ID); err != nil {
+		return err
+	}
+	if err := s.update(func(tx *bolt.Tx) error { return put(tx, session) }); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
 	return nil
 }
 
@@ -60,65 +263,192 @@ func (s *Store) Load(id string) (*Session, error) {
 	if err := validateSessionID(id); err != nil {
 		return nil, err
 	}
-	path := filepath.Join(s.dir, id+".json")
-
-	data, err := os.ReadFile(path)
+	var session *Session
+	err := s.view(func(tx *bolt.Tx) error {
+		var err error
+		session, err = get(tx, id)
+		return err
+	})
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("session not found: %s", id)
-		}
-		return nil, fmt.Errorf("failed to read session file: %w", err)
-	}
-
-	var session Session
-	if err := json.Unmarshal(data, &session); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal session: %w", err)
+		return nil, err
 	}
-
-	return &session, nil
+	return session, nil
 }
 
-// List returns all saved sessions
+// List returns all saved sessions, oldest first
 func (s *Store) List() ([]*Session, error) {
-	entries, err := os.ReadDir(s.dir)
+	var sessions []*Session
+	err := s.view(func(tx *bolt.Tx) error {
+		records := tx.Bucket(sessionsBucket)
+		return tx.Bucket(byStartedBucket).ForEach(func(k, _ []byte) error {
+			if len(k) < 8 {
+				return nil
+			}
+			var session Session
+			if json.Unmarshal(records.Get(k[8:]), &session) != nil {
+				return nil // Skip invalid sessions
+			}
+			sessions = append(sessions, &session)
+			return nil
+		})
+	})
 	if err != nil {
-		if os.IsNotExist(err) {
-			return []*Session{}, nil
-		}
-		return nil, fmt.Errorf("failed to read sessions directory: %w", err)
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
 	}
+	return sessions, nil
+}
 
+// ListByStatus returns the sessions with one of the statuses, oldest first
+func (s *Store) ListByStatus(statuses ...string) ([]*Session, error) {
 	var sessions []*Session
-	for _, entry := range entries {
-		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+	err := s.view(func(tx *bolt.Tx) error {
+		records := tx.Bucket(sessionsBucket)
+		c := tx.Bucket(byStatusBucket).Cursor()
+		for _, status := range statuses {
+			prefix := statusKey(status, "")
+			for k, _ := c.Seek(prefix); k != nil && len(k) >= len(prefix) && string(k[:len(prefix)]) == string(prefix); k, _ = c.Next() {
+				var session Session
+				if json.Unmarshal(records.Get(k[len(prefix):]), &session) != nil {
+					continue // Skip invalid sessions
+				}
+				sessions = append(sessions, &session)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	slices.SortStableFunc(sessions, func(a, b *Session) int { return a.StartedAt.Compare(b.StartedAt) })
+	return sessions, nil
+}
+
+// Resolve returns the ID of the session ref refers to: a session ID, or a
+// session name. A name reused by several sessions resolves to the one that
+// hasn't stopped, else to the most recently started.
+func (s *Store) Resolve(ref string) (string, error) {
+	if _, err := s.Load(ref); err == nil {
+		return ref, nil
+	}
+	sessions, err := s.List()
+	if err != nil {
+		return "", err
+	}
+	var found *Session
+	for _, sess := range sessions {
+		if sess.Name != ref {
 			continue
 		}
+		if found == nil {
+			found = sess
+		} else if stopped := found.Status == "stopped"; stopped != (sess.Status == "stopped") {
+			if stopped {
+				found = sess
+			}
+		} else if sess.StartedAt.After(found.StartedAt) {
+			found = sess
+		}
+	}
+	if found == nil {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, ref)
+	}
+	return found.ID, nil
+}
 
-		id := entry.Name()[:len(entry.Name())-5] // Remove .json extension
-		session, err := s.Load(id)
+// Update loads a session, lets fn change it, and saves it if fn returns true,
+// without another process saving the session in between. It returns whether
+// the session was saved.
+func (s *Store) Update(id string, fn func(sess *Session) bool) (bool, error) {
+	if err := validateSessionID(id); err != nil {
+		return false, err
+	}
+	saved := false
+	err := s.update(func(tx *bolt.Tx) error {
+		session, err := get(tx, id)
 		if err != nil {
-			continue // Skip invalid sessions
+			return err
+		}
+		if !fn(session) {
+			return nil
 		}
-		sessions = append(sessions, session)
+		saved = true
+		return put(tx, session)
+	})
+	if err != nil {
+		return false, err
 	}
+	return saved, nil
+}
 
-	return sessions, nil
+// Rename sets a session's name. Names are unique among sessions that haven't
+// stopped, so a session that hasn't can't take the name of another.
+func (s *Store) Rename(id, name string) error {
+	if err := validateSessionID(id); err != nil {
+		return err
+	}
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	return s.update(func(tx *bolt.Tx) error {
+		session, err := get(tx, id)
+		if err != nil {
+			return err
+		}
+		if session.Status != "stopped" {
+			err := tx.Bucket(sessionsBucket).ForEach(func(k, v []byte) error {
+				var other Session
+				if string(k) == id || json.Unmarshal(v, &other) != nil {
+					return nil
+				}
+				if other.Name == name && other.Status != "stopped" {
+					return fmt.Errorf("session %s is already named %q; stop it or choose another name", other.ID, name)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		session.Name = name
+		return put(tx, session)
+	})
 }
 
-// Delete removes a session file
+// get reads a session's record in a transaction
+func get(tx *bolt.Tx, id string) (*Session, error) {
+	data := tx.Bucket(sessionsBucket).Get([]byte(id))
+	if data == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal session: %w", err)
+	}
+	return &session, nil
+}
+
+// Delete removes a session's record
 func (s *Store) Delete(id string) error {
 	if err := validateSessionID(id); err != nil {
 		return err
 	}
-	path := filepath.Join(s.dir, id+".json")
-
-	if err := os.Remove(path); err != nil {
-		if os.IsNotExist(err) {
+	err := s.update(func(tx *bolt.Tx) error {
+		sessions := tx.Bucket(sessionsBucket)
+		data := sessions.Get([]byte(id))
+		if data == nil {
 			return nil // Already deleted
 		}
-		return fmt.Errorf("failed to delete session file: %w", err)
+		var prev Session
+		if json.Unmarshal(data, &prev) == nil {
+			_ = tx.Bucket(byStatusBucket).Delete(statusKey(prev.Status, prev.ID))
+			_ = tx.Bucket(byStartedBucket).Delete(startedKey(prev.StartedAt, prev.ID))
+		}
+		return sessions.Delete([]byte(id))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
 	}
-
+	s.ClearOwner(id)
 	return nil
 }
 
@@ -0,0 +1,210 @@
+package session
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestStoreResolve(t *testing.T) {
+	store := &Store{dir: t.TempDir()}
+	now := time.Now()
+	for _, s := range []*Session{
+		{ID: "aaa111", Name: "auth", Status: "stopped", StartedAt: now.Add(-3 * time.Hour)},
+		{ID: "bbb222", Name: "auth", Status: "running", StartedAt: now.Add(-2 * time.Hour)},
+		{ID: "ccc333", Name: "auth", Status: "stopped", StartedAt: now.Add(-time.Hour)},
+		{ID: "ddd444", Name: "docs", Status: "stopped", StartedAt: now.Add(-2 * time.Hour)},
+		{ID: "eee555", Name: "docs", Status: "stopped", StartedAt: now.Add(-time.Hour)},
+	} {
+		require.NoError(t, store.Save(s))
+	}
+
+	for ref, want := range map[string]string{
+		"aaa111": "aaa111", // IDs resolve to themselves
+		"auth":   "bbb222", // the session that hasn't stopped wins
+		"docs":   "eee555", // else the most recent
+	} {
+		id, err := store.Resolve(ref)
+		require.NoError(t, err, ref)
+		assert.Equal(t, want, id, ref)
+	}
+
+	_, err := store.Resolve("missing")
+	assert.EqualError(t, err, "session not found: missing")
+	_, err = store.Resolve("fff666")
+	assert.Error(t, err)
+}
+
+func TestStoreSaveLoadDelete(t *testing.T) {
+	store := &Store{dir: t.TempDir()}
+	now := time.Now().UTC().Truncate(time.Second)
+	sess := &Session{ID: "abc123", ProjectDir: "/p", Status: "running", StartedAt: now}
+	require.NoError(t, store.Save(sess))
+
+	got, err := store.Load("abc123")
+	require.NoError(t, err)
+	assert.Equal(t, sess, got)
+
+	// Saving again moves the index entries with the status
+	sess.Status = "stopped"
+	require.NoError(t, store.Save(sess))
+	running, err := store.ListByStatus("running")
+	require.NoError(t, err)
+	assert.Empty(t, running)
+	stopped, err := store.ListByStatus("stopped")
+	require.NoError(t, err)
+	require.Len(t, stopped, 1)
+	assert.Equal(t, "abc123", stopped[0].ID)
+
+	require.NoError(t, store.Delete("abc123"))
+	require.NoError(t, store.Delete("abc123"))
+	_, err = store.Load("abc123")
+	assert.ErrorIs(t, err, ErrNotFound)
+	all, err := store.List()
+	require.NoError(t, err)
+	assert.Empty(t, all)
+
+	assert.Error(t, store.Save(&Session{ID: "../escape"}))
+}
+
+func TestStoreUpdate(t *testing.T) {
+	store := &Store{dir: t.TempDir()}
+	require.NoError(t, store.Save(&Session{ID: "abc123", Status: "running"}))
+
+	saved, err := store.Update("abc123", func(s *Session) bool {
+		s.Status = "stopped"
+		return true
+	})
+	require.NoError(t, err)
+	assert.True(t, saved)
+	stopped, err := store.ListByStatus("stopped")
+	require.NoError(t, err)
+	assert.Len(t, stopped, 1)
+
+	// Declining leaves the record as it was
+	saved, err = store.Update("abc123", func(s *Session) bool {
+		s.Status = "running"
+		return false
+	})
+	require.NoError(t, err)
+	assert.False(t, saved)
+	sess, err := store.Load("abc123")
+	require.NoError(t, err)
+	assert.Equal(t, "stopped", sess.Status)
+
+	_, err = store.Update("ffffff", func(*Session) bool { return true })
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestStoreRename(t *testing.T) {
+	store := &Store{dir: t.TempDir()}
+	for _, s := range []*Session{
+		{ID: "aaa111", Name: "auth", Status: "running"},
+		{ID: "bbb222", Name: "docs", Status: "running"},
+		{ID: "ccc333", Name: "old", Status: "stopped"},
+	} {
+		require.NoError(t, store.Save(s))
+	}
+
+	require.NoError(t, store.Rename("aaa111", "auth-tests"))
+	sess, err := store.Load("aaa111")
+	require.NoError(t, err)
+	assert.Equal(t, "auth-tests", sess.Name)
+	assert.Equal(t, "running", sess.Status)
+
+	assert.EqualError(t, store.Rename("aaa111", "docs"), `session bbb222 is already named "docs"; stop it or choose another name`)
+	// Stopped sessions don't hold on to names, nor are they held to them
+	require.NoError(t, store.Rename("bbb222", "old"))
+	require.NoError(t, store.Rename("ccc333", "old"))
+
+	assert.Error(t, store.Rename("aaa111", "-bad"))
+	assert.ErrorIs(t, store.Rename("ddd444", "new"), ErrNotFound)
+}
+
+func TestStoreListOrder(t *testing.T) {
+	store := &Store{dir: t.TempDir()}
+	now := time.Now()
+	for i, id := range []string{"ccc", "aaa", "bbb"} {
+		status := "running"
+		if i == 1 {
+			status = "paused"
+		}
+		require.NoError(t, store.Save(&Session{ID: id, Status: status, StartedAt: now.Add(time.Duration(i) * time.Minute)}))
+	}
+
+	all, err := store.List()
+	require.NoError(t, err)
+	var ids []string
+	for _, s := range all {
+		ids = append(ids, s.ID)
+	}
+	assert.Equal(t, []string{"ccc", "aaa", "bbb"}, ids)
+
+	active, err := store.ListByStatus("running", "paused")
+	require.NoError(t, err)
+	ids = nil
+	for _, s := range active {
+		ids = append(ids, s.ID)
+	}
+	assert.Equal(t, []string{"ccc", "aaa", "bbb"}, ids)
+}
+
+func TestStoreImportsLegacyFiles(t *testing.T) {
+	dir := t.TempDir()
+	legacy := `{"id":"abc123","project_dir":"/p","status":"stopped","started_at":"2024-01-15T10:00:00Z"}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "abc123.json"), []byte(legacy), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bad.json"), []byte("{"), 0600))
+	store := &Store{dir: dir}
+
+	sess, err := store.Load("abc123")
+	require.NoError(t, err)
+	assert.Equal(t, "/p", sess.ProjectDir)
+	assert.NoFileExists(t, filepath.Join(dir, "abc123.json"))
+	assert.FileExists(t, filepath.Join(dir, "bad.json.invalid"))
+
+	// A file saved later by an older faize is picked up too
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "def456.json"), []byte(`{"id":"def456","status":"running"}`), 0600))
+	all, err := store.List()
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+}
+
+func TestStoreConcurrentSaves(t *testing.T) {
+	store := &Store{dir: t.TempDir()}
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, store.Save(&Session{ID: fmt.Sprintf("%06x", i), Status: "running"}))
+			_, err := store.List()
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	all, err := store.List()
+	require.NoError(t, err)
+	assert.Len(t, all, 20)
+}
+
+func TestStoreNewerSchema(t *testing.T) {
+	store := &Store{dir: t.TempDir()}
+	require.NoError(t, store.Save(&Session{ID: "abc123"}))
+	db, err := bolt.Open(filepath.Join(store.dir, DBFile), 0600, nil)
+	require.NoError(t, err)
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(metaBucket).Put(schemaKey, binary.BigEndian.AppendUint64(nil, uint64(len(migrations)+1)))
+	}))
+	require.NoError(t, db.Close())
+
+	_, err = store.Load("abc123")
+	assert.ErrorContains(t, err, "upgrade faize")
+}
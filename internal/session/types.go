@@ -1,6 +1,11 @@
 package session
 
-import "time"
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
 
 // VMMount represents a VirtioFS mount between host and guest
 type VMMount struct {
@@ -10,18 +15,258 @@ type VMMount struct {
 	Tag      string `json:"tag"`       // VirtioFS mount tag
 }
 
+// PortForward publishes a guest TCP port on the host loopback interface
+type PortForward struct {
+	HostPort  int `json:"host_port"`
+	GuestPort int `json:"guest_port"`
+}
+
+// String formats the forward as HOST:GUEST
+func (p PortForward) String() string {
+	return fmt.Sprintf("%d:%d", p.HostPort, p.GuestPort)
+}
+
+// HostEntry maps a host name to an address in the guest's /etc/hosts
+type HostEntry struct {
+	Name string `json:"name"`
+	IP   string `json:"ip"`
+}
+
+// String formats the entry as NAME:IP
+func (h HostEntry) String() string {
+	return h.Name + ":" + h.IP
+}
+
+// Attach is a period a terminal was attached to a session's console
+type Attach struct {
+	AttachedAt time.Time  `json:"attached_at"`
+	DetachedAt *time.Time `json:"detached_at,omitempty"` // nil while attached
+}
+
+// maxAttaches bounds the attach history kept in a session record; older
+// periods still count towards AttachedSeconds
+const maxAttaches = 100
+
 // Session represents a VM session with its configuration
 type Session struct {
-	ID         string     `json:"id"`
-	ProjectDir string     `json:"project_dir"`
-	Mounts     []VMMount  `json:"mounts"`
-	Network    []string   `json:"network"`
-	CPUs       int        `json:"cpus"`
-	Memory     string     `json:"memory"`
-	Status     string     `json:"status"` // "created", "running", "stopped"
-	StartedAt  time.Time  `json:"started_at"`
-	ClaudeMode bool       `json:"claude_mode"`       // Whether using Claude rootfs
-	Timeout    string     `json:"timeout,omitempty"` // e.g., "2h" - human-readable timeout
-	StoppedAt  *time.Time `json:"stopped_at,omitempty"`
-	ExitReason string     `json:"exit_reason,omitempty"` // "normal" | "timeout" | "detach" | "killed"
+	ID string `json:"id"`
+	// Name and Labels are set by the user to tell sessions apart (faize start
+	// --name, --label); names are unique among sessions that haven't stopped
+	Name       string            `json:"name,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	ProjectDir string            `json:"project_dir"`
+	Mounts     []VMMount         `json:"mounts"`
+	// SystemMounts are faize's own VirtioFS shares (bootstrap, host-claude, toolchain, credentials)
+	SystemMounts []VMMount  `json:"system_mounts,omitempty"`
+	Network      []string   `json:"network"`
+	CPUs         int        `json:"cpus"`
+	Memory       string     `json:"memory"`
+	Status       string     `json:"status"` // "created", "running", "paused", "stopped"
+	StartedAt    time.Time  `json:"started_at"`
+	ClaudeMode   bool       `json:"claude_mode"`       // Whether using Claude rootfs
+	Timeout      string     `json:"timeout,omitempty"` // e.g., "2h" - human-readable timeout
+	StoppedAt    *time.Time `json:"stopped_at,omitempty"`
+	ExitReason   string     `json:"exit_reason,omitempty"` // "normal" | "timeout" | "idle" | "quota" | "detach" | "killed" | "lost"
+	// Deadline is when the timeout stops a running session; faize extend
+	// pushes it out
+	Deadline *time.Time `json:"deadline,omitempty"`
+	// TimeoutLeft is what was left of the timeout when the session was
+	// paused, e.g. "1h20m0s"; resuming sets Deadline from it
+	TimeoutLeft string `json:"timeout_left,omitempty"`
+	// IdleTimeout is how long the session may go without console output or
+	// file changes before it is stopped, e.g. "30m"
+	IdleTimeout string `json:"idle_timeout,omitempty"`
+	// AutoSuspend is how long the session may be detached and idle before
+	// it is paused, e.g. "30m"; AutoSuspended is set while it is paused so
+	AutoSuspend   string `json:"auto_suspend,omitempty"`
+	AutoSuspended bool   `json:"auto_suspended,omitempty"`
+	// MaxCPUs and MaxMemory are the size of the VM, which faize resize can
+	// grow CPUs and Memory up to; unset for sessions from before resizing
+	MaxCPUs   int    `json:"max_cpus,omitempty"`
+	MaxMemory string `json:"max_memory,omitempty"`
+	// Disk is the size of the session's scratch disk, e.g. "20GB"
+	Disk string `json:"disk,omitempty"`
+	// WriteQuota is how much may be written into each writable mount
+	// before it is made read-only, e.g. "10GB"
+	WriteQuota string `json:"write_quota,omitempty"`
+	// GitConfig fingerprints the project repository's git config and hooks
+	// before the session could change them; faize pr won't push otherwise
+	GitConfig string `json:"git_config,omitempty"`
+	// GitRoot and GitDir locate the project repository before the session
+	// could plant or rewrite a .git; session branches are only committed there
+	GitRoot string `json:"git_root,omitempty"`
+	GitDir  string `json:"git_dir,omitempty"`
+	// Overlay is the project's rootfs overlay disk that keeps the session's
+	// changes to the rootfs, such as installed packages, for later sessions
+	Overlay string `json:"overlay,omitempty"`
+	// Home is the project's guest home directory that keeps /home/claude,
+	// such as shell history and caches, for later sessions
+	Home string `json:"home,omitempty"`
+	// Snapshot is the rootfs snapshot the session started from (faize
+	// start --from-snapshot), attached to its VM read-only
+	Snapshot string `json:"snapshot,omitempty"`
+	// Kernel and Rootfs are the images the session boots instead of the
+	// downloaded ones (faize start --kernel and --rootfs)
+	Kernel string `json:"kernel,omitempty"`
+	Rootfs string `json:"rootfs,omitempty"`
+	// Image is the OCI image Rootfs was built from (faize start --image)
+	Image string `json:"image,omitempty"`
+	// Flavor is the flavor of the Claude rootfs the session boots, e.g.
+	// "debian"; empty for the default Alpine one (claude.flavor)
+	Flavor string `json:"flavor,omitempty"`
+	// PID is the host process that owns the VM; set for detached sessions
+	PID      int  `json:"pid,omitempty"`
+	Detached bool `json:"detached,omitempty"`
+	// CaptureNetwork is set when guest traffic is recorded (faize network pcap)
+	CaptureNetwork bool `json:"capture_network,omitempty"`
+	// Ports are guest ports published on the host (faize start --publish)
+	Ports []PortForward `json:"ports,omitempty"`
+	// MACAddress is the guest NIC address, kept stable so a paused session restores
+	MACAddress string `json:"mac_address,omitempty"`
+	// Warm is set while the session is an idle pre-booted VM waiting for a
+	// project (faize warm); WarmKey identifies the configuration it booted with
+	Warm    bool   `json:"warm,omitempty"`
+	WarmKey string `json:"warm_key,omitempty"`
+	// SSHPort is the host loopback port forwarded to the guest's sshd (faize ssh)
+	SSHPort int `json:"ssh_port,omitempty"`
+	// PreventSleep is when the macOS host is kept awake: "attached", "always", or "never"
+	PreventSleep string `json:"prevent_sleep,omitempty"`
+	// NetworkPrompt asks the attached user about connections the allowlist denies
+	NetworkPrompt bool `json:"network_prompt,omitempty"`
+	// Notify posts a desktop notification when the session rings the
+	// terminal bell or sends a notification while detached
+	Notify bool `json:"notify,omitempty"`
+	// NetLimit caps the guest's bandwidth in bits per second (faize start --net-limit)
+	NetLimit uint64 `json:"net_limit,omitempty"`
+	// Resolvers are the configured DNS servers (network.resolvers), if any
+	Resolvers []string `json:"resolvers,omitempty"`
+	// Hosts are extra /etc/hosts entries (hosts in the config, --add-host)
+	Hosts []HostEntry `json:"hosts,omitempty"`
+	// Proxy is the upstream HTTP proxy URL (proxy.url in the config), and
+	// NoProxy the hosts reached without it
+	Proxy   string   `json:"proxy,omitempty"`
+	NoProxy []string `json:"no_proxy,omitempty"`
+	// Attaches are the most recent periods a terminal was attached, oldest
+	// first, and AttachedSeconds the total length of those that ended
+	Attaches        []Attach `json:"attaches,omitempty"`
+	AttachedSeconds float64  `json:"attached_seconds,omitempty"`
+	// PeakCPU (percent of all vCPUs) and PeakMemory (bytes) are the highest
+	// guest usage sampled while the session ran
+	PeakCPU    float64 `json:"peak_cpu,omitempty"`
+	PeakMemory uint64  `json:"peak_memory,omitempty"`
+}
+
+// RecordAttach starts an attach period
+func (s *Session) RecordAttach(at time.Time) {
+	s.RecordDetach(at)
+	s.Attaches = append(s.Attaches, Attach{AttachedAt: at})
+	if len(s.Attaches) > maxAttaches {
+		s.Attaches = s.Attaches[len(s.Attaches)-maxAttaches:]
+	}
+}
+
+// RecordDetach ends the current attach period, if any
+func (s *Session) RecordDetach(at time.Time) {
+	if len(s.Attaches) == 0 {
+		return
+	}
+	last := &s.Attaches[len(s.Attaches)-1]
+	if last.DetachedAt != nil {
+		return
+	}
+	last.DetachedAt = &at
+	s.AttachedSeconds += max(at.Sub(last.AttachedAt), 0).Seconds()
+}
+
+// Attached reports whether a terminal is attached, going by the attach history
+func (s *Session) Attached() bool {
+	n := len(s.Attaches)
+	return n > 0 && s.Attaches[n-1].DetachedAt == nil
+}
+
+// AttachedTime returns how long terminals have been attached in total,
+// counting a period still open until now
+func (s *Session) AttachedTime(now time.Time) time.Duration {
+	total := time.Duration(s.AttachedSeconds * float64(time.Second))
+	if s.Attached() {
+		end := now
+		if s.StoppedAt != nil {
+			end = *s.StoppedAt
+		}
+		total += max(end.Sub(s.Attaches[len(s.Attaches)-1].AttachedAt), 0)
+	}
+	return total
+}
+
+// RecordUsage raises the peak CPU and memory to a sample's, returning
+// whether either changed
+func (s *Session) RecordUsage(cpu float64, memory uint64) bool {
+	changed := false
+	if cpu > s.PeakCPU {
+		s.PeakCPU = cpu
+		changed = true
+	}
+	if memory > s.PeakMemory {
+		s.PeakMemory = memory
+		changed = true
+	}
+	return changed
+}
+
+// maxNameLen bounds session names and label keys so they fit in ps
+const maxNameLen = 63
+
+// ValidateName checks a session name: letters, digits, '.', '_' and '-',
+// starting with a letter or digit
+func ValidateName(name string) error {
+	if name == "" || len(name) > maxNameLen {
+		return fmt.Errorf("invalid session name %q: must be 1 to %d characters", name, maxNameLen)
+	}
+	for i, c := range name {
+		if !isNameChar(c) || (i == 0 && !isAlnum(c)) {
+			return fmt.Errorf("invalid session name %q: use letters, digits, '.', '_' and '-', starting with a letter or digit", name)
+		}
+	}
+	return nil
+}
+
+// ParseLabels parses KEY=VALUE labels into a map; a key given twice keeps
+// the last value
+func ParseLabels(specs []string) (map[string]string, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+	labels := make(map[string]string, len(specs))
+	for _, spec := range specs {
+		key, value, ok := strings.Cut(spec, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid label %q: expected KEY=VALUE", spec)
+		}
+		if key == "" || len(key) > maxNameLen || strings.IndexFunc(key, func(c rune) bool { return !isNameChar(c) && c != '/' }) >= 0 {
+			return nil, fmt.Errorf("invalid label key %q: use letters, digits, '.', '_', '-' and '/'", key)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("invalid label %q: value must be a single line", spec)
+		}
+		labels[key] = value
+	}
+	return labels, nil
+}
+
+// FormatLabels formats labels as KEY=VALUE pairs sorted by key, comma-separated
+func FormatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func isAlnum(c rune) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func isNameChar(c rune) bool {
+	return isAlnum(c) || c == '.' || c == '_' || c == '-'
 }
@@ -90,3 +90,68 @@ func TestSessionSerialization(t *testing.T) {
 		assert.NotContains(t, m, "exit_reason")
 	})
 }
+
+func TestValidateName(t *testing.T) {
+	for _, name := range []string{"refactor-auth", "a", "v1.2_fix", "A9"} {
+		assert.NoError(t, ValidateName(name), name)
+	}
+	for _, name := range []string{"", "-lead", ".hidden", "has space", "a/b", string(make([]byte, 64))} {
+		assert.Error(t, ValidateName(name), name)
+	}
+}
+
+func TestParseLabels(t *testing.T) {
+	labels, err := ParseLabels([]string{"team=backend", "ticket=", "k8s.io/app=web", "team=infra"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "infra", "ticket": "", "k8s.io/app": "web"}, labels)
+	assert.Equal(t, "k8s.io/app=web,team=infra,ticket=", FormatLabels(labels))
+
+	labels, err = ParseLabels(nil)
+	require.NoError(t, err)
+	assert.Nil(t, labels)
+
+	for _, spec := range []string{"team", "=backend", "bad key=x", "note=a\nb"} {
+		_, err := ParseLabels([]string{spec})
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestSessionAttachHistory(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	var s Session
+
+	s.RecordDetach(start) // nothing to end
+	assert.Empty(t, s.Attaches)
+
+	s.RecordAttach(start)
+	assert.True(t, s.Attached())
+	s.RecordDetach(start.Add(time.Minute))
+	assert.False(t, s.Attached())
+	s.RecordDetach(start.Add(time.Hour)) // already ended
+	assert.Equal(t, 60.0, s.AttachedSeconds)
+
+	// An open period counts until now, and attaching again ends it
+	s.RecordAttach(start.Add(2 * time.Minute))
+	assert.Equal(t, 2*time.Minute, s.AttachedTime(start.Add(3*time.Minute)))
+	s.RecordAttach(start.Add(4 * time.Minute))
+	require.Len(t, s.Attaches, 3)
+	assert.Equal(t, 180.0, s.AttachedSeconds)
+
+	stopped := start.Add(5 * time.Minute)
+	s.StoppedAt = &stopped
+	assert.Equal(t, 4*time.Minute, s.AttachedTime(start.Add(time.Hour)))
+
+	for range maxAttaches {
+		s.RecordAttach(start.Add(5 * time.Minute))
+	}
+	assert.Len(t, s.Attaches, maxAttaches)
+}
+
+func TestSessionRecordUsage(t *testing.T) {
+	var s Session
+	assert.True(t, s.RecordUsage(40, 1<<30))
+	assert.False(t, s.RecordUsage(30, 1<<29))
+	assert.True(t, s.RecordUsage(30, 2<<30))
+	assert.Equal(t, 40.0, s.PeakCPU)
+	assert.Equal(t, uint64(2<<30), s.PeakMemory)
+}
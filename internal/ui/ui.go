@@ -0,0 +1,90 @@
+// Package ui serves faize's web dashboard: a page listing sessions with their
+// console, network activity, and changes, on top of the read-only part of
+// the local API.
+package ui
+
+import (
+	"crypto/subtle"
+	_ "embed"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/faize-ai/faize/internal/api"
+)
+
+// CookieName is the cookie that carries the dashboard's token once the
+// browser has opened its URL
+const CookieName = "faize_ui"
+
+//go:embed index.html
+var page []byte
+
+// Handler serves the dashboard page at / and the API's GET endpoints under
+// /api/. Requests must carry token, either as ?token= on the page, which
+// sets a same-site cookie, or in that cookie, and must be addressed to a
+// loopback host, so other pages the browser has open can't read sessions.
+func Handler(backend api.Backend, token string) http.Handler {
+	apiHandler := http.StripPrefix("/api", api.NewServer(backend))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !loopbackHost(r.Host) {
+			http.Error(w, "forbidden host", http.StatusForbidden)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "the dashboard is read-only", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if r.URL.Path == "/" && r.URL.Query().Has("token") {
+			if !validToken(r.URL.Query().Get("token"), token) {
+				http.Error(w, "invalid token; open the URL printed by faize ui", http.StatusForbidden)
+				return
+			}
+			http.SetCookie(w, &http.Cookie{
+				Name:     CookieName,
+				Value:    token,
+				Path:     "/",
+				HttpOnly: true,
+				SameSite: http.SameSiteStrictMode,
+			})
+			// Drop the token from the address bar and history
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+			return
+		}
+		cookie, err := r.Cookie(CookieName)
+		if err != nil || !validToken(cookie.Value, token) {
+			http.Error(w, "not authorized; open the URL printed by faize ui", http.StatusForbidden)
+			return
+		}
+
+		switch {
+		case r.URL.Path == "/":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'unsafe-inline'; style-src 'unsafe-inline'")
+			_, _ = w.Write(page)
+		case strings.HasPrefix(r.URL.Path, "/api/"):
+			apiHandler.ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func validToken(got, want string) bool {
+	return subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
+
+// loopbackHost reports whether a Host header names this machine, rejecting
+// other names that a DNS rebinding attack could point at it
+func loopbackHost(hostport string) bool {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = hostport
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	return ip != nil && ip.IsLoopback()
+}
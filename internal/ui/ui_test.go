@@ -0,0 +1,104 @@
+package ui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/faize-ai/faize/internal/api"
+	"github.com/faize-ai/faize/internal/changeset"
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeBackend has one session and fails everything that changes state
+type fakeBackend struct{}
+
+func (fakeBackend) List() ([]*session.Session, error) {
+	return []*session.Session{{ID: "abc123", Status: "running"}}, nil
+}
+func (fakeBackend) Get(id string) (*session.Session, error) {
+	return &session.Session{ID: id, Status: "running"}, nil
+}
+func (fakeBackend) Start(api.StartRequest) (*session.Session, error) { panic("start") }
+func (fakeBackend) Stop(string, bool) error                          { panic("stop") }
+func (fakeBackend) Allow(string, string) (string, error)             { panic("allow") }
+func (fakeBackend) Changeset(string) (*changeset.SessionChangeset, error) {
+	return &changeset.SessionChangeset{}, nil
+}
+func (fakeBackend) ConsoleSocket(string) (string, error) { return "", api.ErrInvalid }
+func (fakeBackend) ConsoleLog(string) (string, error)    { return "", api.ErrNotFound }
+func (fakeBackend) Network(string) ([]changeset.NetworkEvent, error) {
+	return nil, nil
+}
+func (fakeBackend) Resize(string, int, int) error { panic("resize") }
+
+func serve(h http.Handler, method, target string, cookie bool) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	req.Host = "127.0.0.1:7777"
+	if cookie {
+		req.AddCookie(&http.Cookie{Name: CookieName, Value: "secret"})
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandler_Token(t *testing.T) {
+	h := Handler(fakeBackend{}, "secret")
+
+	rec := serve(h, "GET", "/", false)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	rec = serve(h, "GET", "/?token=wrong", false)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	rec = serve(h, "GET", "/api/v1/sessions", false)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = serve(h, "GET", "/?token=secret", false)
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.Equal(t, "/", rec.Header().Get("Location"))
+	cookies := rec.Result().Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, "secret", cookies[0].Value)
+		assert.True(t, cookies[0].HttpOnly)
+		assert.Equal(t, http.SameSiteStrictMode, cookies[0].SameSite)
+	}
+
+	rec = serve(h, "GET", "/", true)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "faize sessions")
+	rec = serve(h, "GET", "/api/v1/sessions", true)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"id":"abc123"`)
+}
+
+func TestHandler_ReadOnly(t *testing.T) {
+	h := Handler(fakeBackend{}, "secret")
+
+	for _, target := range []string{"/api/v1/sessions", "/api/v1/sessions/abc123/allow"} {
+		rec := serve(h, "POST", target, true)
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code, target)
+	}
+	rec := serve(h, "DELETE", "/api/v1/sessions/abc123", true)
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestHandler_Host(t *testing.T) {
+	h := Handler(fakeBackend{}, "secret")
+
+	for host, ok := range map[string]bool{
+		"127.0.0.1:7777":    true,
+		"localhost:7777":    true,
+		"[::1]:7777":        true,
+		"localhost":         true,
+		"evil.example:7777": false,
+		"192.168.1.5:7777":  false,
+	} {
+		req := httptest.NewRequest("GET", "/api/v1/sessions", nil)
+		req.Host = host
+		req.AddCookie(&http.Cookie{Name: CookieName, Value: "secret"})
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		assert.Equal(t, ok, rec.Code == http.StatusOK, host)
+	}
+}
@@ -0,0 +1,289 @@
+package vm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/faize-ai/faize/internal/network"
+)
+
+// Approval requests are files in the session directory, which the guest
+// can't write: the process that owns the VM writes <seq>.json for a denied
+// connection it holds, and the attached console client writes the user's
+// network.Decision to <seq>.answer.
+const (
+	approvalsDir    = "approvals"
+	approvalTimeout = 30 * time.Second
+	approvalPoll    = 100 * time.Millisecond
+	promptPoll      = 250 * time.Millisecond
+	// promptGrace is how long after a question is drawn input is still
+	// taken to have been typed before it, and discarded
+	promptGrace = 500 * time.Millisecond
+)
+
+// approvalRequest describes a connection waiting for the user's decision
+type approvalRequest struct {
+	Host string `json:"host"`
+	Port string `json:"port"`
+}
+
+// approver holds connections the egress policy denies while the attached
+// user decides about them (network_prompt in the config)
+type approver struct {
+	dir     string
+	enabled func() bool             // prompting is on and a client is attached
+	persist func(host string) error // records a session-wide approval
+	timeout time.Duration
+
+	mu      sync.Mutex
+	seq     int
+	pending map[string]*pendingApproval // by host:port
+}
+
+// pendingApproval lets connections to the same host and port share one prompt
+type pendingApproval struct {
+	done     chan struct{}
+	decision network.Decision
+}
+
+// newApprover prepares the approvals directory in sessionDir, clearing
+// requests left over from a previous owner of the session
+func newApprover(sessionDir string, enabled func() bool, persist func(host string) error) (*approver, error) {
+	dir := filepath.Join(sessionDir, approvalsDir)
+	_ = os.RemoveAll(dir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create approvals directory: %w", err)
+	}
+	return &approver{
+		dir:     dir,
+		enabled: enabled,
+		persist: persist,
+		timeout: approvalTimeout,
+		pending: make(map[string]*pendingApproval),
+	}, nil
+}
+
+// Approve asks the attached user about a connection the policy denies and
+// waits for the answer. Without an attached client, or when the user doesn't
+// answer in time, the connection is denied.
+func (a *approver) Approve(host, port string) network.Decision {
+	if !a.enabled() {
+		return network.DecisionDeny
+	}
+
+	key := net.JoinHostPort(host, port)
+	a.mu.Lock()
+	if p, ok := a.pending[key]; ok {
+		a.mu.Unlock()
+		<-p.done
+		return p.decision
+	}
+	p := &pendingApproval{done: make(chan struct{})}
+	a.pending[key] = p
+	a.seq++
+	seq := a.seq
+	a.mu.Unlock()
+
+	p.decision = a.ask(seq, approvalRequest{Host: host, Port: port})
+	if p.decision == network.DecisionSession {
+		if err := a.persist(host); err != nil {
+			debugLog("Failed to record approval for %s: %v", host, err)
+		}
+	}
+
+	a.mu.Lock()
+	delete(a.pending, key)
+	a.mu.Unlock()
+	close(p.done)
+	return p.decision
+}
+
+// ask writes a request and polls for its answer
+func (a *approver) ask(seq int, req approvalRequest) network.Decision {
+	base := filepath.Join(a.dir, fmt.Sprintf("%06d", seq))
+	defer func() {
+		_ = os.Remove(base + ".json")
+		_ = os.Remove(base + ".answer")
+	}()
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return network.DecisionDeny
+	}
+	if err := os.WriteFile(base+".json.tmp", data, 0600); err != nil {
+		return network.DecisionDeny
+	}
+	if err := os.Rename(base+".json.tmp", base+".json"); err != nil {
+		return network.DecisionDeny
+	}
+
+	deadline := time.Now().Add(a.timeout)
+	for time.Now().Before(deadline) && a.enabled() {
+		if answer, err := os.ReadFile(base + ".answer"); err == nil {
+			switch d := network.Decision(strings.TrimSpace(string(answer))); d {
+			case network.DecisionOnce, network.DecisionSession:
+				return d
+			default:
+				return network.DecisionDeny
+			}
+		}
+		time.Sleep(approvalPoll)
+	}
+	return network.DecisionDeny
+}
+
+// approvalPrompt asks the user at the terminal about the requests in an
+// approvals directory. While a question is open it takes the user's input
+// instead of forwarding it to the guest. An answer is a decision key
+// confirmed with Enter, so typing meant for Claude can't answer by accident.
+type approvalPrompt struct {
+	dir   string
+	out   io.Writer // the terminal, in raw mode
+	w     io.Writer // where input goes when no question is open
+	grace time.Duration
+
+	mu      sync.Mutex
+	current string // base path of the request being asked, "" if none
+	host    string
+	askedAt time.Time
+	choice  network.Decision // key typed, waiting for Enter
+}
+
+// newApprovalPrompt returns a prompt that writes questions to out and passes
+// other input through to w
+func newApprovalPrompt(dir string, out, w io.Writer) *approvalPrompt {
+	return &approvalPrompt{dir: dir, out: out, w: w, grace: promptGrace}
+}
+
+// watch polls for requests until done is closed
+func (p *approvalPrompt) watch(done <-chan struct{}) {
+	ticker := time.NewTicker(promptPoll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		p.poll()
+	}
+}
+
+// poll asks about the oldest open request, or notices that the owner gave
+// up on the one being asked
+func (p *approvalPrompt) poll() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.current != "" {
+		if _, err := os.Stat(p.current + ".json"); os.IsNotExist(err) {
+			_, _ = fmt.Fprintf(p.out, "\r\n[faize] No answer, denied %s\r\n", p.host)
+			p.current, p.choice = "", ""
+		}
+		return
+	}
+
+	requests, _ := filepath.Glob(filepath.Join(p.dir, "*.json"))
+	sort.Strings(requests)
+	for _, path := range requests {
+		base := strings.TrimSuffix(path, ".json")
+		if _, err := os.Stat(base + ".answer"); err == nil {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var req approvalRequest
+		if err := json.Unmarshal(data, &req); err != nil || req.Host == "" {
+			continue
+		}
+		p.current, p.host, p.choice = base, req.Host, ""
+		_, _ = fmt.Fprintf(p.out, "\r\n[faize] Claude wants to reach %s:%s - allow [o]nce, for the [s]ession, or [d]eny, then Enter? ", req.Host, req.Port)
+		p.askedAt = time.Now()
+		return
+	}
+}
+
+// Write takes a keypress for the open question: a decision key, confirmed
+// with Enter, or Ctrl-C or Esc to deny. Input that arrives right after the
+// question is drawn, and pasted text, arriving as several bytes at once,
+// never answers it. Without an open question data passes through.
+func (p *approvalPrompt) Write(data []byte) (int, error) {
+	p.mu.Lock()
+	if p.current == "" {
+		p.mu.Unlock()
+		return p.w.Write(data)
+	}
+	defer p.mu.Unlock()
+
+	// Input typed while answering is never forwarded to the guest
+	if len(data) != 1 || time.Since(p.askedAt) < p.grace {
+		return len(data), nil
+	}
+	switch b := data[0]; b {
+	case '\r', '\n':
+		if p.choice != "" {
+			p.answer(p.choice)
+		}
+	case 0x03, 0x1b:
+		p.answer(network.DecisionDeny)
+	case 0x7f, 0x08:
+		if p.choice != "" {
+			p.choice = ""
+			_, _ = io.WriteString(p.out, "\b \b")
+		}
+	default:
+		if decision, ok := promptDecision(b); ok {
+			if p.choice != "" {
+				_, _ = io.WriteString(p.out, "\b")
+			}
+			p.choice = decision
+			_, _ = p.out.Write(data)
+		}
+	}
+	return len(data), nil
+}
+
+// answer records the decision for the open question and closes it
+func (p *approvalPrompt) answer(decision network.Decision) {
+	if err := os.WriteFile(p.current+".answer", []byte(decision), 0600); err != nil {
+		_, _ = fmt.Fprintf(p.out, "\r\n[faize] Failed to answer: %v\r\n", err)
+	} else {
+		_, _ = fmt.Fprintf(p.out, "\r\n[faize] %s\r\n", promptResult(decision, p.host))
+	}
+	p.current, p.choice = "", ""
+}
+
+// promptDecision maps a key to a decision
+func promptDecision(b byte) (network.Decision, bool) {
+	switch b {
+	case 'o', 'O', 'y', 'Y':
+		return network.DecisionOnce, true
+	case 's', 'S':
+		return network.DecisionSession, true
+	case 'd', 'D', 'n', 'N':
+		return network.DecisionDeny, true
+	}
+	return "", false
+}
+
+// promptResult describes an answer for the terminal
+func promptResult(d network.Decision, host string) string {
+	switch d {
+	case network.DecisionOnce:
+		return "allowed once"
+	case network.DecisionSession:
+		return fmt.Sprintf("allowed %s for this session", host)
+	default:
+		return "denied"
+	}
+}
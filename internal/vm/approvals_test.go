@@ -0,0 +1,172 @@
+package vm
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/faize-ai/faize/internal/network"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syncBuffer lets the test read terminal output written by the prompt
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// askedHost polls the prompt until it shows a question
+func askedHost(t *testing.T, p *approvalPrompt) string {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		p.poll()
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return p.current != ""
+	}, 5*time.Second, 10*time.Millisecond)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.host
+}
+
+func TestApprovalPromptSession(t *testing.T) {
+	sessionDir := t.TempDir()
+	var persisted []string
+	a, err := newApprover(sessionDir, func() bool { return true }, func(host string) error {
+		persisted = append(persisted, host)
+		return nil
+	})
+	require.NoError(t, err)
+
+	out, input := &syncBuffer{}, &syncBuffer{}
+	prompt := newApprovalPrompt(a.dir, out, input)
+	prompt.grace = 0
+
+	decisions := make(chan network.Decision, 2)
+	go func() { decisions <- a.Approve("example.com", "443") }()
+	assert.Equal(t, "example.com", askedHost(t, prompt))
+	// A second connection to the same host and port shares the question
+	go func() { decisions <- a.Approve("example.com", "443") }()
+
+	// A key is only an answer once confirmed with Enter
+	for _, key := range []string{"x", "o", "\x7f", "s"} {
+		_, err = prompt.Write([]byte(key))
+		require.NoError(t, err)
+	}
+	select {
+	case <-decisions:
+		t.Fatal("answered without Enter")
+	case <-time.After(300 * time.Millisecond):
+	}
+	_, err = prompt.Write([]byte("\r"))
+	require.NoError(t, err)
+	for range 2 {
+		select {
+		case d := <-decisions:
+			assert.Equal(t, network.DecisionSession, d)
+		case <-time.After(5 * time.Second):
+			t.Fatal("approval not answered")
+		}
+	}
+	assert.Equal(t, []string{"example.com"}, persisted)
+	assert.Contains(t, out.String(), "Claude wants to reach example.com:443")
+	assert.Contains(t, out.String(), "allowed example.com for this session")
+	assert.Empty(t, input.String(), "answers must not reach the guest")
+
+	// Without an open question input passes through
+	_, err = prompt.Write([]byte("ls\r"))
+	require.NoError(t, err)
+	assert.Equal(t, "ls\r", input.String())
+}
+
+func TestApprovalPromptIgnoresEarlyAndPastedInput(t *testing.T) {
+	a, err := newApprover(t.TempDir(), func() bool { return true }, func(string) error { return nil })
+	require.NoError(t, err)
+	a.timeout = time.Second
+
+	out, input := &syncBuffer{}, &syncBuffer{}
+	prompt := newApprovalPrompt(a.dir, out, input)
+	prompt.grace = time.Hour
+
+	decision := make(chan network.Decision, 1)
+	go func() { decision <- a.Approve("example.com", "443") }()
+	askedHost(t, prompt)
+
+	// Typed before the question could be read
+	for _, key := range []string{"s", "\r"} {
+		_, err = prompt.Write([]byte(key))
+		require.NoError(t, err)
+	}
+	prompt.mu.Lock()
+	prompt.grace = 0
+	prompt.mu.Unlock()
+
+	// Pasted, arriving at once
+	_, err = prompt.Write([]byte("s\r"))
+	require.NoError(t, err)
+
+	assert.Equal(t, network.DecisionDeny, <-decision, "unanswered until the timeout")
+	assert.Empty(t, input.String())
+}
+
+func TestApproverKeysByPort(t *testing.T) {
+	a, err := newApprover(t.TempDir(), func() bool { return true }, func(string) error { return nil })
+	require.NoError(t, err)
+	prompt := newApprovalPrompt(a.dir, &syncBuffer{}, &syncBuffer{})
+	prompt.grace = 0
+
+	decisions := make(chan network.Decision, 2)
+	go func() { decisions <- a.Approve("example.com", "443") }()
+	askedHost(t, prompt)
+	go func() { decisions <- a.Approve("example.com", "22") }()
+	require.Eventually(t, func() bool {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		return len(a.pending) == 2
+	}, 5*time.Second, 10*time.Millisecond, "another port gets its own question")
+
+	for range 2 {
+		askedHost(t, prompt)
+		for _, key := range []string{"o", "\r"} {
+			_, err = prompt.Write([]byte(key))
+			require.NoError(t, err)
+		}
+		assert.Equal(t, network.DecisionOnce, <-decisions)
+	}
+}
+
+func TestApprovalPromptTimeout(t *testing.T) {
+	a, err := newApprover(t.TempDir(), func() bool { return true }, func(string) error { return nil })
+	require.NoError(t, err)
+	a.timeout = 200 * time.Millisecond
+
+	out := &syncBuffer{}
+	prompt := newApprovalPrompt(a.dir, out, &syncBuffer{})
+	decision := make(chan network.Decision, 1)
+	go func() { decision <- a.Approve("example.com", "443") }()
+	askedHost(t, prompt)
+
+	assert.Equal(t, network.DecisionDeny, <-decision)
+	prompt.poll()
+	assert.Contains(t, out.String(), "No answer, denied example.com")
+}
+
+func TestApproverDisabled(t *testing.T) {
+	a, err := newApprover(t.TempDir(), func() bool { return false }, func(string) error { return nil })
+	require.NoError(t, err)
+	assert.Equal(t, network.DecisionDeny, a.Approve("example.com", "443"))
+}
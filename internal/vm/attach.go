@@ -0,0 +1,80 @@
+//go:build darwin || linux
+
+package vm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/faize-ai/faize/internal/session"
+	"golang.org/x/term"
+)
+
+// attachConsole connects the current terminal to a session's console proxy.
+// It is shared by every backend so initial attach and reattach behave identically.
+func attachConsole(sessions *session.Store, sessionDir, socketPath, id string) error {
+	// Wait briefly for proxy to be ready (relevant for fresh start)
+	for range 10 {
+		if _, err := os.Stat(socketPath); err == nil {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if _, err := os.Stat(socketPath); err != nil {
+		return fmt.Errorf("console not found for session: %s (VM may have stopped)", id)
+	}
+
+	client, err := NewConsoleClient(socketPath)
+	if err != nil {
+		// Connection failed - socket is stale (process crashed)
+		// Clean up the orphaned socket file
+		_ = os.Remove(socketPath)
+
+		// Update session status to stopped
+		if sess, loadErr := sessions.Load(id); loadErr == nil {
+			sess.Status = "stopped"
+			sess.PID = 0
+			if saveErr := sessions.Save(sess); saveErr != nil {
+				debugLog("Failed to save session state: %v", saveErr)
+			}
+		}
+
+		return fmt.Errorf("session %s is no longer running (cleaned up stale socket)", id)
+	}
+	defer func() { _ = client.Close() }()
+
+	// Set up terminal resize propagation via VirtioFS termsize file
+	termsizePath := filepath.Join(sessionDir, "bootstrap", "termsize")
+	client.SetTermsizePath(termsizePath)
+
+	// Set up clipboard sync via VirtioFS clipboard directory
+	clipboardDir := filepath.Join(sessionDir, "bootstrap", "clipboard")
+	client.SetClipboardDir(clipboardDir)
+
+	// Set up URL open watcher via VirtioFS bootstrap directory
+	client.SetOpenURLDir(filepath.Join(sessionDir, "bootstrap"))
+
+	// Set up the boot status line via the stage file the guest agent writes
+	client.SetBootStatus(filepath.Join(sessionDir, "bootstrap"), id)
+
+	// Set up prompts for connections the egress proxy holds (network_prompt)
+	client.SetApprovalsDir(filepath.Join(sessionDir, approvalsDir))
+
+	// Write current terminal size immediately (handles reattach from different-sized terminal)
+	if term.IsTerminal(int(os.Stdout.Fd())) {
+		if w, h, err := term.GetSize(int(os.Stdout.Fd())); err == nil && w > 0 && h > 0 {
+			_ = os.WriteFile(termsizePath, []byte(fmt.Sprintf("%d %d", w, h)), 0644)
+		}
+	}
+
+	return client.Attach(os.Stdin, os.Stdout)
+}
+
+// proxySocketPath returns the console proxy socket path for a session
+func proxySocketPath(id string) string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".faize", "sessions", fmt.Sprintf("%s.sock", id))
+}
@@ -0,0 +1,188 @@
+package vm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/faize-ai/faize/internal/session"
+)
+
+// notifyPollInterval is how often the notifications guest hooks write are checked
+const notifyPollInterval = time.Second
+
+// attentionCooldown is the least time between notifications for a session,
+// so a program ringing the bell repeatedly posts one
+const attentionCooldown = 30 * time.Second
+
+// maxOSC is the longest OSC sequence the attention scanner keeps
+const maxOSC = 1024
+
+// attentionScanner finds requests for the user's attention in console
+// output: the terminal bell, and desktop notifications sent as OSC 9
+// (iTerm2) or OSC 777 (rxvt) sequences, which Claude Code uses when it
+// waits for input or permission. Sequences may be split across writes.
+type attentionScanner struct {
+	state int
+	osc   []byte
+}
+
+const (
+	scanText = iota
+	scanEscape
+	scanOSC
+	scanOSCEscape
+)
+
+// Scan returns the attention requests in p: the notification text, or ""
+// for a bell
+func (s *attentionScanner) Scan(p []byte) []string {
+	var found []string
+	for _, c := range p {
+		switch s.state {
+		case scanText:
+			switch c {
+			case '\a':
+				found = append(found, "")
+			case 0x1b:
+				s.state = scanEscape
+			}
+		case scanEscape:
+			s.state = scanText
+			if c == ']' {
+				s.state = scanOSC
+				s.osc = s.osc[:0]
+			}
+		case scanOSC:
+			switch c {
+			case '\a':
+				found = appendNotification(found, string(s.osc))
+				s.state = scanText
+			case 0x1b:
+				s.state = scanOSCEscape
+			default:
+				if len(s.osc) < maxOSC {
+					s.osc = append(s.osc, c)
+				}
+			}
+		case scanOSCEscape:
+			s.state = scanText
+			if c == '\\' {
+				found = appendNotification(found, string(s.osc))
+			}
+		}
+	}
+	return found
+}
+
+// appendNotification adds the text of an OSC notification; other OSC
+// sequences, such as window titles and OSC 9;4 progress, are ignored
+func appendNotification(found []string, osc string) []string {
+	if msg, ok := strings.CutPrefix(osc, "9;"); ok && !strings.HasPrefix(msg, "4;") {
+		return append(found, msg)
+	}
+	if rest, ok := strings.CutPrefix(osc, "777;notify;"); ok {
+		title, body, _ := strings.Cut(rest, ";")
+		if body == "" {
+			return append(found, title)
+		}
+		return append(found, body)
+	}
+	return found
+}
+
+// attentionNotifier posts desktop notifications for a session's attention
+// requests while no terminal is attached, and for the events its guest hooks
+// report with faize-notify
+type attentionNotifier struct {
+	id       string
+	scanner  attentionScanner
+	attached func() bool
+	load     func() (*session.Session, error)
+	notify   func(title, message string) error
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+func newAttentionNotifier(id string, attached func() bool) *attentionNotifier {
+	load := func() (*session.Session, error) {
+		store, err := session.NewStore()
+		if err != nil {
+			return nil, err
+		}
+		return store.Load(id)
+	}
+	return &attentionNotifier{id: id, attached: attached, load: load, notify: notifyUser}
+}
+
+// Write scans console output, notifying about attention requests when the
+// session is detached and has notifications on
+func (n *attentionNotifier) Write(p []byte) {
+	found := n.scanner.Scan(p)
+	if len(found) == 0 || n.attached() {
+		return
+	}
+	n.mu.Lock()
+	if time.Since(n.last) < attentionCooldown {
+		n.mu.Unlock()
+		return
+	}
+	n.last = time.Now()
+	n.mu.Unlock()
+
+	sess, err := n.load()
+	if err != nil || !sess.Notify {
+		return
+	}
+	msg := found[len(found)-1]
+	if msg == "" {
+		msg = "Claude needs your attention"
+	}
+	go n.post(sess, msg)
+}
+
+// watch posts the notifications guest hooks append to the bootstrap
+// directory from now on, until done is closed
+func (n *attentionNotifier) watch(done <-chan struct{}, bootstrapDir string) {
+	var offset int64
+	if info, err := os.Stat(filepath.Join(bootstrapDir, guest.NotifyFile)); err == nil {
+		offset = info.Size()
+	}
+	ticker := time.NewTicker(notifyPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		var notes []guest.Notification
+		var err error
+		if notes, offset, err = guest.ReadNotifications(bootstrapDir, offset); err != nil || len(notes) == 0 {
+			continue
+		}
+		sess, err := n.load()
+		if err != nil || !sess.Notify {
+			continue
+		}
+		for _, note := range notes {
+			msg := note.Message
+			if msg == "" {
+				msg = note.Event
+			}
+			n.post(sess, msg)
+		}
+	}
+}
+
+// post posts a desktop notification about the session
+func (n *attentionNotifier) post(sess *session.Session, msg string) {
+	title := "faize: " + filepath.Base(sess.ProjectDir)
+	if err := n.notify(title, msg+" (faize attach "+n.id+")"); err != nil {
+		debugLog("Failed to post notification: %v", err)
+	}
+}
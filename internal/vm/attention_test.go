@@ -0,0 +1,88 @@
+package vm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttentionScanner(t *testing.T) {
+	var s attentionScanner
+
+	assert.Empty(t, s.Scan([]byte("plain output\r\n")))
+	assert.Equal(t, []string{""}, s.Scan([]byte("done\a")))
+
+	// A window title ends with BEL but isn't a bell
+	assert.Empty(t, s.Scan([]byte("\x1b]0;claude\a")))
+	// Progress reports use OSC 9;4
+	assert.Empty(t, s.Scan([]byte("\x1b]9;4;1;50\a")))
+
+	assert.Equal(t, []string{"Claude is waiting for your input"},
+		s.Scan([]byte("\x1b]9;Claude is waiting for your input\a")))
+	assert.Equal(t, []string{"Claude needs your permission to use Bash"},
+		s.Scan([]byte("\x1b]777;notify;Claude Code;Claude needs your permission to use Bash\x1b\\")))
+
+	// Sequences split across writes
+	assert.Empty(t, s.Scan([]byte("\x1b]9;Task ")))
+	assert.Equal(t, []string{"Task finished"}, s.Scan([]byte("finished\x1b\\")))
+
+	// Other escape sequences don't start an OSC
+	assert.Empty(t, s.Scan([]byte("\x1b[31mred\x1b[0m")))
+}
+
+func TestAttentionNotifier(t *testing.T) {
+	attached, notify := true, false
+	n := newAttentionNotifier("abc123", func() bool { return attached })
+	n.load = func() (*session.Session, error) {
+		return &session.Session{ID: "abc123", ProjectDir: "/home/me/app", Notify: notify}, nil
+	}
+	posted := make(chan string, 4)
+	n.notify = func(title, message string) error {
+		posted <- title + ": " + message
+		return nil
+	}
+
+	n.Write([]byte("\a"))
+	assert.True(t, n.last.IsZero(), "no notification while attached")
+
+	// Detached, but notifications are off for the session
+	attached = false
+	n.Write([]byte("\a"))
+	assert.Empty(t, posted)
+
+	n.last, notify = time.Time{}, true
+	n.Write([]byte("\x1b]9;Claude is waiting for your input\a"))
+	assert.Equal(t, "faize: app: Claude is waiting for your input (faize attach abc123)", <-posted)
+
+	n.Write([]byte("\a"))
+	assert.Empty(t, posted, "bells within the cooldown are ignored")
+}
+
+func TestAttentionNotifierWatch(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, guest.AppendNotification(dir, guest.Notification{Event: "old"}))
+
+	n := newAttentionNotifier("abc123", func() bool { return true })
+	n.load = func() (*session.Session, error) {
+		return &session.Session{ID: "abc123", ProjectDir: "/home/me/app", Notify: true}, nil
+	}
+	posted := make(chan string, 4)
+	n.notify = func(title, message string) error {
+		posted <- message
+		return nil
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go n.watch(done, dir)
+
+	// Notifications written before the watch started aren't posted
+	time.Sleep(100 * time.Millisecond)
+	require.NoError(t, guest.AppendNotification(dir, guest.Notification{Event: "task-complete", Message: "tests pass"}))
+	require.NoError(t, guest.AppendNotification(dir, guest.Notification{Event: "needs-approval"}))
+	assert.Equal(t, "tests pass (faize attach abc123)", <-posted)
+	assert.Equal(t, "needs-approval (faize attach abc123)", <-posted)
+}
@@ -0,0 +1,101 @@
+package vm
+
+import (
+	"time"
+
+	"github.com/faize-ai/faize/internal/guest"
+)
+
+const (
+	// balloonInterval is how often the owner of a VM reconsiders its balloon
+	balloonInterval = 10 * time.Second
+	// balloonIdleAfter is how long the guest's CPU use must stay below
+	// balloonIdleCPU before its unused memory is reclaimed
+	balloonIdleAfter = 2 * time.Minute
+	balloonIdleCPU   = 5.0
+	// balloonHeadroom is left free above what an idle guest uses, so it can
+	// pick up work before the balloon deflates
+	balloonHeadroom = 512 << 20
+	// balloonMinimum is the least memory an idle guest is left with
+	balloonMinimum = 1 << 30
+	// balloonStep is the smallest reduction worth inflating the balloon for
+	// an idle guest; growth and resizing are always applied straight away
+	balloonStep = 256 << 20
+	// balloonStatsStale is how old a stats sample may be before the guest's
+	// usage is treated as unknown
+	balloonStatsStale = 3 * balloonInterval
+)
+
+// balloon decides how much memory a guest gets from its recent activity:
+// all it may use while busy, and little more than it uses once it has been
+// idle
+type balloon struct {
+	memory uint64 // memory the guest may use in bytes, up to the VM's size
+	target uint64 // memory the guest currently gets; 0 until first set
+	busyAt time.Time
+}
+
+// newBalloon starts with the guest's target unknown, since a resumed guest
+// keeps the balloon it was saved with, so the first update always sets it
+func newBalloon(memory uint64, now time.Time) *balloon {
+	return &balloon{memory: memory, busyAt: now}
+}
+
+// update takes a stats sample read at now, nil if there is none, and returns
+// the guest's new memory target and whether it changed. Without a recent
+// sample the guest's usage is unknown, so it gets all of its memory back.
+func (b *balloon) update(stats *guest.Stats, now time.Time) (uint64, bool) {
+	target := b.memory
+	if stats != nil && now.Sub(stats.Time) < balloonStatsStale {
+		if stats.CPU >= balloonIdleCPU {
+			b.busyAt = now
+		}
+		if now.Sub(b.busyAt) >= balloonIdleAfter {
+			target = balloonTarget(b.memory, stats.MemUsed)
+		}
+	} else {
+		b.busyAt = now
+	}
+	if target == b.target || (target < b.target && target != b.memory && b.target-target < balloonStep) {
+		return b.target, false
+	}
+	b.target = target
+	return target, true
+}
+
+// balloonTarget returns how much of a guest's memory to leave it while idle,
+// in whole megabytes as the balloon devices require
+func balloonTarget(memory, used uint64) uint64 {
+	target := max(used+balloonHeadroom, balloonMinimum)
+	target = (target + 1<<20 - 1) &^ (1<<20 - 1)
+	return min(target, memory)
+}
+
+// runBalloon resizes a guest's memory balloon with setTarget as its activity
+// and the memory it may use (limit, see faize resize) change, until alive
+// reports that the VM has stopped. The first target is set straight away, as
+// the VM may be larger than the session.
+func runBalloon(bootstrapDir string, limit func() uint64, alive func() bool, setTarget func(uint64) error) {
+	ticker := time.NewTicker(balloonInterval)
+	defer ticker.Stop()
+	b := newBalloon(limit(), time.Now())
+	for {
+		if !alive() {
+			return
+		}
+		stats, err := guest.ReadStats(bootstrapDir)
+		if err != nil {
+			stats = nil
+		}
+		b.memory = limit()
+		previous := b.target
+		if target, changed := b.update(stats, time.Now()); changed {
+			debugLog("Setting guest memory to %d MB of %d MB", target>>20, b.memory>>20)
+			if err := setTarget(target); err != nil {
+				debugLog("Failed to resize memory balloon: %v", err)
+				b.target = previous // try again on the next tick
+			}
+		}
+		<-ticker.C
+	}
+}
@@ -0,0 +1,70 @@
+package vm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBalloonTarget(t *testing.T) {
+	const gb = 1 << 30
+	assert.Equal(t, uint64(gb), balloonTarget(4*gb, 100<<20))
+	assert.Equal(t, uint64(2*gb+512<<20), balloonTarget(4*gb, 2*gb))
+	assert.Equal(t, uint64(2*gb+513<<20), balloonTarget(4*gb, 2*gb+1)) // rounded up to whole MB
+	assert.Equal(t, uint64(4*gb), balloonTarget(4*gb, 4*gb))
+}
+
+func TestBalloonUpdate(t *testing.T) {
+	const gb = 1 << 30
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	b := newBalloon(4*gb, start)
+	sample := func(at time.Time, cpu float64, used uint64) *guest.Stats {
+		return &guest.Stats{Time: at, CPU: cpu, MemUsed: used, MemTotal: 4 * gb}
+	}
+
+	// The first update sets the target; busy, or not idle for long enough,
+	// the guest gets all of its memory
+	now := start.Add(time.Minute)
+	target, changed := b.update(sample(now, 50, gb), now)
+	assert.True(t, changed)
+	assert.Equal(t, uint64(4*gb), target)
+	now = now.Add(time.Minute)
+	_, changed = b.update(sample(now, 1, gb), now)
+	assert.False(t, changed)
+
+	// Idle long enough: memory above usage and headroom is reclaimed
+	now = now.Add(2 * time.Minute)
+	target, changed = b.update(sample(now, 1, gb), now)
+	assert.True(t, changed)
+	assert.Equal(t, uint64(gb+512<<20), target)
+
+	// Small reductions aren't worth it, growth is applied straight away
+	now = now.Add(balloonInterval)
+	_, changed = b.update(sample(now, 1, gb-100<<20), now)
+	assert.False(t, changed)
+	target, changed = b.update(sample(now, 1, gb+10<<20), now)
+	assert.True(t, changed)
+	assert.Equal(t, uint64(gb+522<<20), target)
+
+	// Busy again: the balloon deflates
+	target, changed = b.update(sample(now, 80, gb), now)
+	assert.True(t, changed)
+	assert.Equal(t, uint64(4*gb), target)
+
+	// Stale or missing stats give the guest all of its memory back
+	b.target = gb
+	target, changed = b.update(sample(start, 1, gb), now.Add(time.Hour))
+	assert.True(t, changed)
+	assert.Equal(t, uint64(4*gb), target)
+	b.target = gb
+	target, _ = b.update(nil, now)
+	assert.Equal(t, uint64(4*gb), target)
+
+	// Resizing applies even a small reduction
+	b.memory = 4*gb - 100<<20
+	target, changed = b.update(nil, now)
+	assert.True(t, changed)
+	assert.Equal(t, b.memory, target)
+}
@@ -0,0 +1,142 @@
+package vm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/faize-ai/faize/internal/guest"
+)
+
+// bootStatusPoll is how often the boot status line is refreshed
+const bootStatusPoll = 100 * time.Millisecond
+
+// bootStatus shows a single status line while the guest boots, following the
+// stage the agent records in the bootstrap directory. It is written through
+// as the console's output: the line is replaced by a summary when the guest
+// is ready, or cleared as soon as the guest prints anything, so console
+// output always starts on a clean line.
+type bootStatus struct {
+	out   io.Writer
+	dir   string // host bootstrap directory
+	id    string
+	start time.Time
+
+	mu    sync.Mutex
+	done  bool
+	shown bool
+	stop  chan struct{}
+	once  sync.Once
+}
+
+// startBootStatus starts the status line for a booting session. Returns nil
+// if the guest is already past booting, e.g. when reattaching.
+func startBootStatus(out io.Writer, bootstrapDir, id string) *bootStatus {
+	if readBootStage(bootstrapDir) == guest.BootReady {
+		return nil
+	}
+	b := &bootStatus{out: out, dir: bootstrapDir, id: id, start: time.Now(), stop: make(chan struct{})}
+	go b.run()
+	return b
+}
+
+// run refreshes the status line until the guest is ready or output arrives
+func (b *bootStatus) run() {
+	ticker := time.NewTicker(bootStatusPoll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+		}
+
+		stage := readBootStage(b.dir)
+		b.mu.Lock()
+		if b.done {
+			b.mu.Unlock()
+			return
+		}
+		if stage == guest.BootReady {
+			b.finishLocked(true)
+			b.mu.Unlock()
+			return
+		}
+		if stage == "" {
+			stage = "starting"
+		}
+		_, _ = fmt.Fprintf(b.out, "\r\x1b[KBooting: %s (%.1fs)", stage, time.Since(b.start).Seconds())
+		b.shown = true
+		b.mu.Unlock()
+	}
+}
+
+// Write passes console output through, ending the status line first
+func (b *bootStatus) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	if !b.done {
+		b.finishLocked(false)
+	}
+	b.mu.Unlock()
+	return b.out.Write(p)
+}
+
+// Stop ends the status line, e.g. when the client detaches during boot
+func (b *bootStatus) Stop() {
+	b.mu.Lock()
+	if !b.done {
+		b.finishLocked(false)
+	}
+	b.mu.Unlock()
+	b.once.Do(func() { close(b.stop) })
+}
+
+// finishLocked replaces the status line with the boot summary when the guest
+// is ready, or clears it. The terminal is in raw mode, hence "\r\n".
+func (b *bootStatus) finishLocked(ready bool) {
+	b.done = true
+	if !ready {
+		if b.shown {
+			_, _ = io.WriteString(b.out, "\r\x1b[K")
+		}
+		return
+	}
+
+	summary := fmt.Sprintf("Booted in %.1fs", time.Since(b.start).Seconds())
+	if n := countBootWarnings(filepath.Join(b.dir, guest.BootLogFile)); n > 0 {
+		summary += fmt.Sprintf(" with %d warning(s), see 'faize logs --boot %s'", n, b.id)
+	}
+	_, _ = fmt.Fprintf(b.out, "\r\x1b[K%s\r\n", summary)
+}
+
+// readBootStage returns the boot stage the agent last recorded, or "" if none
+func readBootStage(bootstrapDir string) string {
+	data, err := os.ReadFile(filepath.Join(bootstrapDir, guest.BootStageFile))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// countBootWarnings counts the warnings in a boot log
+func countBootWarnings(path string) int {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer func() { _ = f.Close() }()
+
+	n := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if _, msg, ok := strings.Cut(scanner.Text(), " "); ok && strings.HasPrefix(msg, guest.BootWarningPrefix) {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,66 @@
+package vm
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (b *bootStatus) finished() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.done
+}
+
+func TestBootStatusReady(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, guest.BootStageFile), []byte(guest.BootNetwork), 0644))
+	log := "09:30:05 Network OK\n09:30:06 " + guest.BootWarningPrefix + "DHCP failed\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, guest.BootLogFile), []byte(log), 0644))
+
+	var out bytes.Buffer
+	status := startBootStatus(&out, dir, "abc123")
+	require.NotNil(t, status)
+	defer status.Stop()
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, guest.BootStageFile), []byte(guest.BootReady), 0644))
+	assert.Eventually(t, status.finished, 2*time.Second, 10*time.Millisecond)
+	status.Stop()
+
+	_, err := status.Write([]byte("claude"))
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), "Booted in ")
+	assert.Contains(t, out.String(), "with 1 warning(s), see 'faize logs --boot abc123'\r\n")
+	assert.True(t, bytes.HasSuffix(out.Bytes(), []byte("\r\nclaude")))
+}
+
+func TestBootStatusClearedByOutput(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, guest.BootStageFile), []byte(guest.BootMounting), 0644))
+
+	var out bytes.Buffer
+	status := startBootStatus(&out, dir, "abc123")
+	require.NotNil(t, status)
+	defer status.Stop()
+
+	time.Sleep(3 * bootStatusPoll)
+	_, err := status.Write([]byte("fatal: no project"))
+	require.NoError(t, err)
+	status.Stop()
+
+	assert.Contains(t, out.String(), "Booting: "+guest.BootMounting)
+	assert.True(t, bytes.HasSuffix(out.Bytes(), []byte("\r\x1b[Kfatal: no project")))
+	assert.NotContains(t, out.String(), "Booted in")
+}
+
+func TestBootStatusAlreadyReady(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, guest.BootStageFile), []byte(guest.BootReady), 0644))
+	assert.Nil(t, startBootStatus(&bytes.Buffer{}, dir, "abc123"))
+}
@@ -0,0 +1,288 @@
+package vm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/faize-ai/faize/internal/artifacts"
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/google/uuid"
+	"golang.org/x/term"
+)
+
+// bootstrap is the host-side state prepared for a new session, shared by all backends
+type bootstrap struct {
+	id           string
+	dir          string            // ~/.faize/sessions/{id}/bootstrap
+	allMounts    []session.VMMount // every VirtioFS share, in device order
+	systemMounts []session.VMMount // faize's own shares (bootstrap first)
+	sshPort      int               // host port for the guest's sshd; zero without SSH
+}
+
+// bootstrapPath returns the bootstrap directory inside a session directory
+func bootstrapPath(sessionDir string) string {
+	return filepath.Join(sessionDir, "bootstrap")
+}
+
+// ensureImages downloads or builds the kernel and rootfs a session boots,
+// leaving out those it was given (faize start --kernel and --rootfs)
+func ensureImages(artifactMgr *artifacts.Manager, cfg *Config) error {
+	switch {
+	case cfg.Rootfs != "":
+		if cfg.Kernel == "" {
+			if err := artifactMgr.EnsureKernel(); err != nil {
+				return fmt.Errorf("failed to ensure kernel: %w", err)
+			}
+		}
+	case cfg.ClaudeMode:
+		if err := artifactMgr.EnsureFlavorRootfs(cfg.Flavor); err != nil {
+			return fmt.Errorf("failed to ensure claude rootfs: %w", err)
+		}
+	default:
+		if err := artifactMgr.EnsureArtifacts(); err != nil {
+			return fmt.Errorf("failed to ensure artifacts: %w", err)
+		}
+	}
+	return nil
+}
+
+// prepareBootstrap ensures artifacts exist, allocates a session ID, populates the
+// bootstrap directory (agent config, init shim, host time, terminal size, clipboard, debug flag)
+// and assembles the VirtioFS share list.
+func prepareBootstrap(artifactMgr *artifacts.Manager, cfg *Config) (*bootstrap, error) {
+	// Ensure artifacts are downloaded
+	debugLog("Ensuring artifacts...")
+	if err := ensureImages(artifactMgr, cfg); err != nil {
+		return nil, err
+	}
+	if cfg.ClaudeMode {
+		if err := artifactMgr.EnsureToolchainDir(); err != nil {
+			return nil, fmt.Errorf("failed to ensure toolchain dir: %w", err)
+		}
+		if cfg.CredentialsDir != "" {
+			if err := artifactMgr.EnsureCredentialsDir(); err != nil {
+				return nil, fmt.Errorf("failed to ensure credentials dir: %w", err)
+			}
+		}
+		if cfg.Home != "" {
+			if err := prepareHome(cfg.Home); err != nil {
+				return nil, err
+			}
+		}
+		if cfg.Overlay != "" {
+			_, rootfsPath := bootImages(artifactMgr, cfg.Kernel, cfg.Rootfs, cfg.Flavor, cfg.ClaudeMode)
+			replaced, err := prepareOverlay(cfg.Overlay, rootfsPath)
+			if err != nil {
+				return nil, err
+			}
+			if replaced {
+				fmt.Printf("The Claude rootfs was rebuilt; starting %s's rootfs overlay afresh\n", filepath.Base(cfg.ProjectDir))
+			}
+		}
+	}
+
+	// Generate session ID
+	id := uuid.New().String()[:12]
+	debugLog("Session ID: %s", id)
+
+	// Create bootstrap directory for the agent config
+	bootstrapDir := bootstrapPath(artifactMgr.SessionDir(id))
+	if err := os.MkdirAll(bootstrapDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create bootstrap directory: %w", err)
+	}
+
+	// Generate SSH keys for faize ssh; the session works without them
+	var sshPort int
+	if cfg.ClaudeMode {
+		port, err := prepareSSH(artifactMgr.SessionDir(id), bootstrapDir, id)
+		if err != nil {
+			debugLog("SSH disabled for this session: %v", err)
+		} else {
+			sshPort = port
+		}
+	}
+
+	// Write the guest agent configuration and the init.sh shim that launches the agent
+	agentCfg := guest.NewConfig(cfg.ClaudeMode, cfg.Mounts, cfg.ProjectDir, cfg.NetworkPolicy, cfg.CredentialsDir != "")
+	agentCfg.HeartbeatTimeout = int(cfg.Watchdog / time.Second)
+	agentCfg.CaptureNetwork = cfg.CaptureNetwork
+	agentCfg.NetLimit = cfg.NetLimit
+	agentCfg.Resolvers = cfg.Resolvers
+	agentCfg.Hosts = cfg.Hosts
+	agentCfg.StrictNetwork = cfg.StrictNetwork
+	agentCfg.Warm = cfg.Warm
+	agentCfg.Prompt = cfg.Prompt
+	agentCfg.PromptArgs = cfg.PromptArgs
+	agentCfg.Tasks = cfg.Tasks
+	agentCfg.PostCreate = cfg.PostCreate
+	agentCfg.PersistHome = cfg.ClaudeMode && cfg.Home != ""
+	agentCfg.SyncSettings = cfg.SyncSettings
+	agentCfg.SyncSkills = cfg.SyncSkills
+	if cfg.Disk != "" {
+		if err := createScratchDisk(artifactMgr.SessionDir(id), cfg.Disk); err != nil {
+			return nil, err
+		}
+		agentCfg.ScratchDisk = true
+	}
+	caCerts, err := writeCACerts(bootstrapDir, cfg.CACerts)
+	if err != nil {
+		return nil, err
+	}
+	agentCfg.CACerts = caCerts
+	agentCfg.SSH = sshPort != 0
+	if usesEgressProxy(cfg) {
+		agentCfg.EgressPort = egressPort(id)
+		agentCfg.DNSPort = dnsPort(id)
+	} else {
+		agentCfg.Proxy = cfg.Proxy
+		agentCfg.NoProxy = cfg.NoProxy
+	}
+	if err := guest.WriteConfig(bootstrapDir, agentCfg); err != nil {
+		return nil, err
+	}
+	initScript := guest.BootstrapScript()
+	if cfg.Build {
+		initScript = guest.BuilderScript()
+	}
+	initScriptPath := filepath.Join(bootstrapDir, "init.sh")
+	if err := os.WriteFile(initScriptPath, []byte(initScript), 0755); err != nil {
+		return nil, fmt.Errorf("failed to write init script: %w", err)
+	}
+
+	// Write host time to bootstrap directory for guest clock sync
+	hostTime := time.Now().Unix()
+	hostTimePath := filepath.Join(bootstrapDir, "hosttime")
+	if err := os.WriteFile(hostTimePath, []byte(fmt.Sprintf("%d", hostTime)), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write host time: %w", err)
+	}
+
+	// Write terminal size to bootstrap directory for guest terminal setup
+	writeTermSize(bootstrapDir)
+
+	// Seed the heartbeat so the guest watchdog starts from a known value
+	if err := writeHeartbeat(bootstrapDir); err != nil {
+		return nil, err
+	}
+
+	// A VM larger than the session keeps its extra CPUs offline until resized
+	if maxCPUs, _ := vmSize(cfg.CPUs, cfg.MaxCPUs, cfg.Memory, cfg.MaxMemory); maxCPUs > cfg.CPUs {
+		if err := guest.WriteCPUs(bootstrapDir, cfg.CPUs); err != nil {
+			return nil, err
+		}
+	}
+
+	// Create clipboard directory for host-to-guest clipboard sync
+	clipboardDir := filepath.Join(bootstrapDir, "clipboard")
+	if err := os.MkdirAll(clipboardDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create clipboard directory: %w", err)
+	}
+
+	// Write debug flag to bootstrap directory if debug mode is enabled
+	if os.Getenv("FAIZE_DEBUG") == "1" {
+		debugPath := filepath.Join(bootstrapDir, "debug")
+		if err := os.WriteFile(debugPath, []byte("1"), 0644); err != nil {
+			debugLog("Failed to write debug flag: %v", err)
+		}
+	}
+
+	// Create bootstrap mount (first system share)
+	bootstrapMount := session.VMMount{
+		Source:   bootstrapDir,
+		Target:   "/mnt/bootstrap",
+		Tag:      TagBootstrap,
+		ReadOnly: false,
+	}
+	systemMounts := []session.VMMount{bootstrapMount}
+
+	// Add Claude mode specific mounts
+	if cfg.ClaudeMode {
+		// Add host-claude mount
+		if cfg.HostClaudeDir != "" {
+			claudeMount := session.VMMount{
+				Source:   cfg.HostClaudeDir,
+				Target:   "/mnt/host-claude",
+				Tag:      TagHostClaude,
+				ReadOnly: true,
+			}
+			systemMounts = append(systemMounts, claudeMount)
+		}
+
+		// Add toolchain mount
+		if cfg.ToolchainDir != "" {
+			toolchainMount := session.VMMount{
+				Source:   cfg.ToolchainDir,
+				Target:   "/opt/toolchain",
+				Tag:      TagToolchain,
+				ReadOnly: false,
+			}
+			systemMounts = append(systemMounts, toolchainMount)
+		}
+
+		// Add credentials mount
+		if cfg.CredentialsDir != "" {
+			credentialsMount := session.VMMount{
+				Source:   cfg.CredentialsDir,
+				Target:   "/mnt/host-credentials",
+				Tag:      TagCredentials,
+				ReadOnly: false,
+			}
+			systemMounts = append(systemMounts, credentialsMount)
+		}
+
+		// Add persistent home mount
+		if cfg.Home != "" {
+			homeMount := session.VMMount{
+				Source:   cfg.Home,
+				Target:   "/mnt/host-home",
+				Tag:      TagHome,
+				ReadOnly: false,
+			}
+			systemMounts = append(systemMounts, homeMount)
+		}
+	}
+
+	allMounts := orderShares(cfg.Mounts, systemMounts)
+
+	// Audit tags across user and system shares before configuring devices
+	if err := ValidateTags(allMounts); err != nil {
+		return nil, err
+	}
+
+	return &bootstrap{
+		id:           id,
+		dir:          bootstrapDir,
+		allMounts:    allMounts,
+		systemMounts: systemMounts,
+		sshPort:      sshPort,
+	}, nil
+}
+
+// writeTermSize records the host terminal size in the bootstrap directory, if
+// stdout is a terminal
+func writeTermSize(bootstrapDir string) {
+	if !term.IsTerminal(int(os.Stdout.Fd())) {
+		return
+	}
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		return
+	}
+	termSizePath := filepath.Join(bootstrapDir, "termsize")
+	if err := os.WriteFile(termSizePath, []byte(fmt.Sprintf("%d %d", width, height)), 0644); err != nil {
+		debugLog("Failed to write terminal size: %v", err)
+	}
+}
+
+// orderShares returns every VirtioFS share in device order: the bootstrap share
+// first, then user mounts, then the remaining system shares. The order must be
+// reproducible from a saved session so a paused VM restores onto the same devices.
+func orderShares(mounts, systemMounts []session.VMMount) []session.VMMount {
+	if len(systemMounts) == 0 {
+		return append([]session.VMMount{}, mounts...)
+	}
+	all := append([]session.VMMount{systemMounts[0]}, mounts...)
+	return append(all, systemMounts[1:]...)
+}
@@ -0,0 +1,30 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderShares(t *testing.T) {
+	bootstrap := session.VMMount{Source: "/b", Tag: TagBootstrap}
+	toolchain := session.VMMount{Source: "/t", Tag: TagToolchain}
+	user := session.VMMount{Source: "/u", Tag: "mount0"}
+
+	t.Run("bootstrap first, then user mounts, then system shares", func(t *testing.T) {
+		got := orderShares([]session.VMMount{user}, []session.VMMount{bootstrap, toolchain})
+		assert.Equal(t, []session.VMMount{bootstrap, user, toolchain}, got)
+	})
+
+	t.Run("does not modify the user mounts", func(t *testing.T) {
+		mounts := make([]session.VMMount, 1, 4)
+		mounts[0] = user
+		_ = orderShares(mounts, []session.VMMount{bootstrap})
+		assert.Equal(t, []session.VMMount{user}, mounts)
+	})
+
+	t.Run("no system shares", func(t *testing.T) {
+		assert.Equal(t, []session.VMMount{user}, orderShares([]session.VMMount{user}, nil))
+	})
+}
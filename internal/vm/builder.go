@@ -0,0 +1,229 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/faize-ai/faize/internal/artifacts"
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/faize-ai/faize/internal/rootfs"
+	"github.com/faize-ai/faize/internal/session"
+)
+
+// Resources of a builder VM: enough for npm and mke2fs, and a sparse
+// scratch disk for the Alpine environment, the tree being built and its
+// image
+const (
+	builderCPUs   = 2
+	builderMemory = "2GB"
+	builderDisk   = "16GB"
+)
+
+// builderLogPoll is how often a build step's log is copied to the terminal
+const builderLogPoll = 200 * time.Millisecond
+
+// BuildRunner runs the rootfs builder's Linux steps in builder VMs, so
+// building the Claude rootfs needs neither Docker nor Linux tools on the
+// host. Each step boots the base rootfs with a fresh scratch disk, runs in
+// an Alpine chroot there (see guest.BuilderScript) and powers the VM off;
+// its input and output pass through the bootstrap share.
+type BuildRunner struct {
+	manager   Manager
+	artifacts *artifacts.Manager
+	out       io.Writer // the steps' output
+}
+
+// NewBuildRunner returns a BuildRunner booting builder VMs with manager, or
+// an error if this host can't run them
+func NewBuildRunner(manager Manager, a *artifacts.Manager, out io.Writer) (*BuildRunner, error) {
+	if err := buildVMAvailable(); err != nil {
+		return nil, err
+	}
+	return &BuildRunner{manager: manager, artifacts: a, out: out}, nil
+}
+
+// alpinePath returns where the Alpine minirootfs builder VMs unpack is kept
+func (r *BuildRunner) alpinePath() string {
+	return filepath.Join(r.artifacts.BuildCacheDir(), fmt.Sprintf("alpine-minirootfs-%s.tar.gz", rootfs.AlpineVersion))
+}
+
+// Run runs script as root in a new builder VM, with stdin and stdout
+// spooled through files in its bootstrap share
+func (r *BuildRunner) Run(ctx context.Context, script string, stdin io.Reader, stdout io.Writer) error {
+	if err := rootfs.DownloadAlpine(ctx, r.alpinePath(), r.out); err != nil {
+		return fmt.Errorf("failed to download Alpine for the builder VM: %w", err)
+	}
+
+	sess, err := r.manager.Create(&Config{
+		CPUs:   builderCPUs,
+		Memory: builderMemory,
+		Disk:   builderDisk,
+		Build:  true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create builder VM: %w", err)
+	}
+	defer r.discard(sess.ID)
+
+	job := filepath.Join(bootstrapPath(r.artifacts.SessionDir(sess.ID)), guest.BuildDir)
+	if err := writeBuildJob(job, r.alpinePath(), script, stdin); err != nil {
+		return err
+	}
+
+	if err := r.manager.Start(sess); err != nil {
+		return fmt.Errorf("failed to start builder VM: %w", err)
+	}
+	stopped := r.manager.WaitForVMStop(sess.ID)
+	tailDone := make(chan struct{})
+	go func() {
+		tailFile(filepath.Join(job, guest.BuildLogFile), r.out, stopped)
+		close(tailDone)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		// discard stops the VM
+		return ctx.Err()
+	}
+	<-tailDone
+
+	code, err := readBuildExit(job)
+	if err != nil {
+		return err
+	}
+	switch code {
+	case 0:
+	case guest.BuildSetupFailed:
+		return fmt.Errorf("builder VM failed to set up the build step")
+	default:
+		return fmt.Errorf("build step failed in the builder VM: exit status %d", code)
+	}
+
+	f, err := os.Open(filepath.Join(job, guest.BuildOutputFile))
+	if err != nil {
+		return fmt.Errorf("failed to read build step output: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	if _, err := io.Copy(stdout, f); err != nil {
+		return fmt.Errorf("failed to read build step output: %w", err)
+	}
+	return nil
+}
+
+// discard stops a builder VM and removes its session, which is only kept
+// while the step runs
+func (r *BuildRunner) discard(id string) {
+	if err := r.manager.Stop(id); err != nil {
+		debugLog("Failed to stop builder VM %s: %v", id, err)
+	}
+	if store, err := session.NewStore(); err == nil {
+		if err := store.Delete(id); err != nil {
+			debugLog("Failed to delete builder VM session %s: %v", id, err)
+		}
+	}
+	_ = os.RemoveAll(r.artifacts.SessionDir(id))
+}
+
+// writeBuildJob writes a build step's files for the builder VM to job: the
+// Alpine minirootfs at alpine, the script and its input
+func writeBuildJob(job, alpine, script string, stdin io.Reader) error {
+	if err := os.MkdirAll(job, 0755); err != nil {
+		return fmt.Errorf("failed to create build step directory: %w", err)
+	}
+	if err := copyFile(alpine, filepath.Join(job, guest.BuildAlpineFile)); err != nil {
+		return fmt.Errorf("failed to copy Alpine for the builder VM: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(job, guest.BuildScriptFile), []byte(script), 0644); err != nil {
+		return fmt.Errorf("failed to write build step: %w", err)
+	}
+	f, err := os.Create(filepath.Join(job, guest.BuildInputFile))
+	if err != nil {
+		return fmt.Errorf("failed to write build step input: %w", err)
+	}
+	if stdin != nil {
+		_, err = io.Copy(f, stdin)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write build step input: %w", err)
+	}
+	return nil
+}
+
+// copyFile copies the regular file at src to dst
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// readBuildExit returns the exit code a builder VM recorded for its step
+func readBuildExit(job string) (int, error) {
+	data, err := os.ReadFile(filepath.Join(job, guest.BuildExitFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, fmt.Errorf("builder VM stopped before the build step finished")
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read build step result: %w", err)
+	}
+	code, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid build step result %q", strings.TrimSpace(string(data)))
+	}
+	return code, nil
+}
+
+// tailFile copies what is written to the file at path to w, which may be
+// nil, until done is closed, then copies the rest
+func tailFile(path string, w io.Writer, done <-chan struct{}) {
+	if w == nil {
+		w = io.Discard
+	}
+	var f *os.File
+	defer func() {
+		if f != nil {
+			_ = f.Close()
+		}
+	}()
+	ticker := time.NewTicker(builderLogPoll)
+	defer ticker.Stop()
+	for {
+		stopping := false
+		select {
+		case <-done:
+			stopping = true
+		case <-ticker.C:
+		}
+		if f == nil {
+			if opened, err := os.Open(path); err == nil {
+				f = opened
+			}
+		}
+		if f != nil {
+			_, _ = io.Copy(w, f)
+		}
+		if stopping {
+			return
+		}
+	}
+}
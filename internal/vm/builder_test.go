@@ -0,0 +1,70 @@
+package vm
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteBuildJob(t *testing.T) {
+	dir := t.TempDir()
+	alpine := filepath.Join(dir, "alpine-minirootfs.tar.gz")
+	require.NoError(t, os.WriteFile(alpine, []byte("minirootfs"), 0644))
+
+	job := filepath.Join(dir, "bootstrap", guest.BuildDir)
+	require.NoError(t, writeBuildJob(job, alpine, "apk add bash", strings.NewReader("layer")))
+
+	for file, want := range map[string]string{
+		guest.BuildAlpineFile: "minirootfs",
+		guest.BuildScriptFile: "apk add bash",
+		guest.BuildInputFile:  "layer",
+	} {
+		data, err := os.ReadFile(filepath.Join(job, file))
+		require.NoError(t, err)
+		assert.Equal(t, want, string(data), file)
+	}
+
+	// Steps without input get an empty one
+	require.NoError(t, writeBuildJob(job, alpine, "true", nil))
+	data, err := os.ReadFile(filepath.Join(job, guest.BuildInputFile))
+	require.NoError(t, err)
+	assert.Empty(t, data)
+}
+
+func TestReadBuildExit(t *testing.T) {
+	job := t.TempDir()
+	_, err := readBuildExit(job)
+	assert.ErrorContains(t, err, "stopped before the build step finished")
+
+	require.NoError(t, os.WriteFile(filepath.Join(job, guest.BuildExitFile), []byte("2\n"), 0644))
+	code, err := readBuildExit(job)
+	require.NoError(t, err)
+	assert.Equal(t, 2, code)
+
+	require.NoError(t, os.WriteFile(filepath.Join(job, guest.BuildExitFile), []byte("oops"), 0644))
+	_, err = readBuildExit(job)
+	assert.Error(t, err)
+}
+
+func TestTailFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), guest.BuildLogFile)
+	done := make(chan struct{})
+	var out bytes.Buffer
+	finished := make(chan struct{})
+	go func() {
+		tailFile(path, &out, done)
+		close(finished)
+	}()
+
+	// The log appears after the tail starts and is read to the end once done
+	require.NoError(t, os.WriteFile(path, []byte("fetch\ninstall\n"), 0644))
+	close(done)
+	<-finished
+	assert.Equal(t, "fetch\ninstall\n", out.String())
+}
@@ -0,0 +1,76 @@
+package vm
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// caCertsDir holds the CA certificates copied into the bootstrap directory
+const caCertsDir = "ca-certificates"
+
+// CheckCACerts verifies that each path is a PEM file with at least one CA
+// certificate, so a typo fails before boot rather than as TLS errors in the guest
+func CheckCACerts(paths []string) error {
+	for _, path := range paths {
+		if _, err := readCACert(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readCACert reads a PEM file and checks that its certificates parse
+func readCACert(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+	}
+	found := false
+	for rest := data; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return nil, fmt.Errorf("invalid CA certificate %s: %w", path, err)
+		}
+		found = true
+	}
+	if !found {
+		return nil, fmt.Errorf("invalid CA certificate %s: no PEM CERTIFICATE block", path)
+	}
+	return data, nil
+}
+
+// writeCACerts copies the CA certificates into the bootstrap directory and
+// returns their paths relative to it, for the agent to install
+func writeCACerts(bootstrapDir string, paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	dir := filepath.Join(bootstrapDir, caCertsDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create CA certificate directory: %w", err)
+	}
+	var names []string
+	for i, path := range paths {
+		data, err := readCACert(path)
+		if err != nil {
+			return nil, err
+		}
+		// update-ca-certificates only picks up .crt files
+		name := filepath.Join(caCertsDir, fmt.Sprintf("faize-%d.crt", i))
+		if err := os.WriteFile(filepath.Join(bootstrapDir, name), data, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write CA certificate: %w", err)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
@@ -0,0 +1,70 @@
+package vm
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestCA writes a self-signed CA certificate in PEM form to path
+func writeTestCA(t *testing.T, path string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Corp Root CA"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644))
+}
+
+func TestCheckCACerts(t *testing.T) {
+	dir := t.TempDir()
+	ca := filepath.Join(dir, "corp-ca.pem")
+	writeTestCA(t, ca)
+	assert.NoError(t, CheckCACerts([]string{ca}))
+
+	notPEM := filepath.Join(dir, "ca.der")
+	require.NoError(t, os.WriteFile(notPEM, []byte("not a certificate"), 0644))
+	assert.ErrorContains(t, CheckCACerts([]string{ca, notPEM}), "no PEM CERTIFICATE block")
+
+	assert.Error(t, CheckCACerts([]string{filepath.Join(dir, "missing.pem")}))
+}
+
+func TestWriteCACerts(t *testing.T) {
+	dir := t.TempDir()
+	ca := filepath.Join(dir, "corp-ca.pem")
+	writeTestCA(t, ca)
+
+	bootstrapDir := filepath.Join(dir, "bootstrap")
+	names, err := writeCACerts(bootstrapDir, []string{ca})
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(caCertsDir, "faize-0.crt")}, names)
+
+	want, err := os.ReadFile(ca)
+	require.NoError(t, err)
+	got, err := os.ReadFile(filepath.Join(bootstrapDir, names[0]))
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	names, err = writeCACerts(bootstrapDir, nil)
+	require.NoError(t, err)
+	assert.Empty(t, names)
+}
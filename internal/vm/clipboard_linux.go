@@ -0,0 +1,83 @@
+//go:build linux
+
+package vm
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// SyncClipboardToDir reads the Linux clipboard and writes contents to the specified directory.
+// Wayland (wl-paste) is tried first, then X11 (xclip). Files written match the macOS
+// implementation: clipboard-image, clipboard-text, and clipboard-meta.
+func SyncClipboardToDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create clipboard dir: %w", err)
+	}
+
+	// Remove stale image file before sync so the VM can't serve old data
+	_ = os.Remove(filepath.Join(dir, "clipboard-image"))
+
+	hasImage := syncClipboardImage(dir)
+	hasText := syncClipboardText(dir)
+
+	// Write metadata
+	contentType := "none"
+	if hasImage {
+		contentType = "image/png"
+	} else if hasText {
+		contentType = "text/plain"
+	}
+	meta := fmt.Sprintf("%s\n%d\n", contentType, time.Now().UnixNano())
+	metaPath := filepath.Join(dir, "clipboard-meta")
+	if err := os.WriteFile(metaPath, []byte(meta), 0644); err != nil {
+		return fmt.Errorf("failed to write clipboard meta: %w", err)
+	}
+
+	return nil
+}
+
+// clipboardCommands returns the candidate commands for reading the clipboard as mimeType
+func clipboardCommands(mimeType string) [][]string {
+	return [][]string{
+		{"wl-paste", "--no-newline", "--type", mimeType},
+		{"xclip", "-selection", "clipboard", "-o", "-t", mimeType},
+	}
+}
+
+// readClipboard returns clipboard content of the given type from the first tool that succeeds
+func readClipboard(mimeType string) []byte {
+	for _, args := range clipboardCommands(mimeType) {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			continue
+		}
+		output, err := exec.Command(args[0], args[1:]...).Output()
+		if err == nil && len(output) > 0 {
+			return output
+		}
+	}
+	return nil
+}
+
+// syncClipboardImage reads PNG data from the clipboard.
+// Returns true if image data was found and written successfully.
+func syncClipboardImage(dir string) bool {
+	data := readClipboard("image/png")
+	if len(data) == 0 {
+		return false
+	}
+	return os.WriteFile(filepath.Join(dir, "clipboard-image"), data, 0644) == nil
+}
+
+// syncClipboardText reads text content from the clipboard.
+// Returns true if text was found and written successfully.
+func syncClipboardText(dir string) bool {
+	data := readClipboard("text/plain")
+	if len(data) == 0 {
+		return false
+	}
+	return os.WriteFile(filepath.Join(dir, "clipboard-text"), data, 0644) == nil
+}
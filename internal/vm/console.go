@@ -1,4 +1,4 @@
-//go:build darwin
+//go:build darwin || linux
 
 package vm
 
@@ -8,7 +8,6 @@ import (
 	"os"
 	"sync"
 
-	"github.com/Code-Hex/vz/v3"
 	"golang.org/x/term"
 )
 
@@ -21,50 +20,28 @@ type Console struct {
 	closed bool
 }
 
-// createConsole creates a console and its VZ serial port configuration
-func createConsole() (*Console, *vz.VirtioConsoleDeviceSerialPortConfiguration, error) {
-	// Create pipes for console I/O
-	// Guest writes to readPipe, we read from it
+// newConsolePipes creates the host and guest ends of the console pipes.
+// The guest writes to guestWrite (we read from console.read) and reads from
+// guestRead (we write to console.write).
+func newConsolePipes() (console *Console, guestRead, guestWrite *os.File, err error) {
 	readPipe, guestWrite, err := os.Pipe()
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
-	// We write to writePipe, guest reads from it
 	guestRead, writePipe, err := os.Pipe()
 	if err != nil {
 		_ = readPipe.Close()
 		_ = guestWrite.Close()
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
-	// Create file handle attachment
-	attachment, err := vz.NewFileHandleSerialPortAttachment(guestRead, guestWrite)
-	if err != nil {
-		_ = readPipe.Close()
-		_ = guestWrite.Close()
-		_ = guestRead.Close()
-		_ = writePipe.Close()
-		return nil, nil, err
-	}
-
-	// Create serial port configuration
-	serialConfig, err := vz.NewVirtioConsoleDeviceSerialPortConfiguration(attachment)
-	if err != nil {
-		_ = readPipe.Close()
-		_ = guestWrite.Close()
-		_ = guestRead.Close()
-		_ = writePipe.Close()
-		return nil, nil, err
-	}
-
-	console := &Console{
+	console = &Console{
 		read:  readPipe,
 		write: writePipe,
 		done:  make(chan struct{}),
 	}
-
-	return console, serialConfig, nil
+	return console, guestRead, guestWrite, nil
 }
 
 // Attach connects stdin/stdout to the console with proper terminal handling
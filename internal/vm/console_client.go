@@ -1,4 +1,4 @@
-//go:build darwin
+//go:build darwin || linux
 
 package vm
 
@@ -111,6 +111,9 @@ type ConsoleClient struct {
 	termsizePath string
 	clipboardDir string
 	openURLDir   string
+	bootDir      string
+	sessionID    string
+	approvalsDir string
 }
 
 // SetTermsizePath sets the path to the termsize file used for propagating
@@ -131,6 +134,19 @@ func (c *ConsoleClient) SetOpenURLDir(path string) {
 	c.openURLDir = path
 }
 
+// SetBootStatus sets the bootstrap directory and session ID used for showing
+// a single boot status line until the guest is ready.
+func (c *ConsoleClient) SetBootStatus(dir, sessionID string) {
+	c.bootDir = dir
+	c.sessionID = sessionID
+}
+
+// SetApprovalsDir sets the session's approvals directory, where the egress
+// proxy asks about connections the network allowlist denies.
+func (c *ConsoleClient) SetApprovalsDir(path string) {
+	c.approvalsDir = path
+}
+
 // NewConsoleClient connects to a VM console Unix socket
 func NewConsoleClient(socketPath string) (*ConsoleClient, error) {
 	conn, err := net.Dial("unix", socketPath)
@@ -184,14 +200,25 @@ func (c *ConsoleClient) Attach(stdin io.Reader, stdout io.Writer) error {
 		if strings.HasPrefix(msg, "ERROR:") {
 			return fmt.Errorf("%s", strings.TrimSpace(msg))
 		}
-		// Not an error - it's VM output, write it through
-		_, _ = stdout.Write(initialBuf[:n])
 	}
 	// If err is timeout, that's expected - no immediate data, proceed normally
 	if err != nil && !os.IsTimeout(err) && err != io.EOF {
 		return fmt.Errorf("failed to read from console: %w", err)
 	}
 
+	// Show boot progress on one line until the guest is ready or prints
+	if c.bootDir != "" && term.IsTerminal(int(os.Stdout.Fd())) {
+		if status := startBootStatus(stdout, c.bootDir, c.sessionID); status != nil {
+			defer status.Stop()
+			stdout = status
+		}
+	}
+
+	if n > 0 {
+		// Not an error - it's VM output, write it through
+		_, _ = stdout.Write(initialBuf[:n])
+	}
+
 	// Start URL open watcher to handle guest browser-open requests via VirtioFS
 	openURLDone := make(chan struct{})
 	defer close(openURLDone)
@@ -217,6 +244,12 @@ func (c *ConsoleClient) Attach(stdin io.Reader, stdout io.Writer) error {
 		if c.clipboardDir != "" {
 			stdinWriter = NewClipboardWriter(escapeWriter, c.clipboardDir)
 		}
+		// Questions about denied connections take the next key press
+		if c.approvalsDir != "" && term.IsTerminal(stdinFd) {
+			prompt := newApprovalPrompt(c.approvalsDir, stdout, stdinWriter)
+			go prompt.watch(openURLDone)
+			stdinWriter = prompt
+		}
 		_, err := io.Copy(stdinWriter, stdin)
 		errCh <- err
 	}()
@@ -0,0 +1,37 @@
+//go:build darwin
+
+package vm
+
+import (
+	"github.com/Code-Hex/vz/v3"
+)
+
+// createConsole creates a console and its VZ serial port configuration
+func createConsole() (*Console, *vz.VirtioConsoleDeviceSerialPortConfiguration, error) {
+	console, guestRead, guestWrite, err := newConsolePipes()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Create file handle attachment
+	attachment, err := vz.NewFileHandleSerialPortAttachment(guestRead, guestWrite)
+	if err != nil {
+		_ = console.read.Close()
+		_ = console.write.Close()
+		_ = guestRead.Close()
+		_ = guestWrite.Close()
+		return nil, nil, err
+	}
+
+	// Create serial port configuration
+	serialConfig, err := vz.NewVirtioConsoleDeviceSerialPortConfiguration(attachment)
+	if err != nil {
+		_ = console.read.Close()
+		_ = console.write.Close()
+		_ = guestRead.Close()
+		_ = guestWrite.Close()
+		return nil, nil, err
+	}
+
+	return console, serialConfig, nil
+}
@@ -1,4 +1,4 @@
-//go:build darwin
+//go:build darwin || linux
 
 package vm
 
@@ -9,13 +9,33 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
+
+	"github.com/faize-ai/faize/internal/redact"
+	"github.com/faize-ai/faize/internal/session"
 )
 
+// consoleLogFile records all console output in the session directory (faize logs)
+const consoleLogFile = "console.log"
+
+// maxConsoleLog is the size above which a console log is rotated to
+// console.log.1 when a proxy opens it
+const maxConsoleLog = 32 << 20
+
+// openConsoleLog opens a session's console log for appending, rotating it first if it is too large
+func openConsoleLog(path string) (*os.File, error) {
+	if info, err := os.Stat(path); err == nil && info.Size() > maxConsoleLog {
+		_ = os.Rename(path, path+".1")
+	}
+	return os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+}
+
 // ConsoleProxyServer manages a Unix socket proxy for VM console access.
 // Only one client can be attached at a time. The proxy uses a single reader
 // goroutine that broadcasts console output to the current client, avoiding
 // the issue of orphaned io.Copy goroutines competing for console data.
 type ConsoleProxyServer struct {
+	sessionID  string
 	socketPath string
 	listener   net.Listener
 	console    *Console
@@ -26,6 +46,14 @@ type ConsoleProxyServer struct {
 	// Current client connection (nil if no client attached)
 	currentClient net.Conn
 	clientMu      sync.RWMutex
+
+	// Console output is also appended to the session's console log, with
+	// tokens redacted; nil if the log couldn't be opened
+	logFile *os.File
+	log     *redact.Writer
+
+	// attention posts a notification when detached output rings the bell
+	attention *attentionNotifier
 }
 
 // NewConsoleProxyServer creates a new console proxy server
@@ -46,11 +74,21 @@ func NewConsoleProxyServer(sessionID string, console *Console) (*ConsoleProxySer
 	// Remove existing socket file if present
 	_ = os.Remove(socketPath)
 
-	return &ConsoleProxyServer{
+	s := &ConsoleProxyServer{
+		sessionID:  sessionID,
 		socketPath: socketPath,
 		console:    console,
 		done:       make(chan struct{}),
-	}, nil
+	}
+	s.attention = newAttentionNotifier(sessionID, s.Attached)
+	logFile, err := openConsoleLog(sessionFile(sessionID, consoleLogFile))
+	if err != nil {
+		debugLog("Failed to open console log: %v", err)
+	} else {
+		s.logFile = logFile
+		s.log = redact.NewWriter(logFile, nil)
+	}
+	return s, nil
 }
 
 // Start begins accepting connections on the Unix socket
@@ -71,6 +109,13 @@ func (s *ConsoleProxyServer) Start() error {
 	s.wg.Add(1)
 	go s.monitorConsoleEOF()
 
+	// Post notifications from guest hooks
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.attention.watch(s.done, sessionFile(s.sessionID, "bootstrap"))
+	}()
+
 	// Accept connections
 	s.wg.Add(1)
 	go s.acceptLoop()
@@ -102,6 +147,13 @@ func (s *ConsoleProxyServer) consoleReaderLoop() {
 		}
 
 		if n > 0 {
+			if s.log != nil {
+				if _, err := s.log.Write(buf[:n]); err != nil {
+					debugLog("Console log write error: %v", err)
+				}
+			}
+			s.attention.Write(buf[:n])
+
 			// Write to current client if one is connected
 			s.clientMu.RLock()
 			client := s.currentClient
@@ -152,6 +204,7 @@ func (s *ConsoleProxyServer) acceptLoop() {
 		s.clientMu.Unlock()
 
 		debugLog("Client connected to console proxy")
+		s.recordAttach(true)
 
 		// Handle this client's input (client -> console direction)
 		s.wg.Add(1)
@@ -173,6 +226,7 @@ func (s *ConsoleProxyServer) handleClientInput(conn net.Conn) {
 
 		_ = conn.Close()
 		debugLog("Client disconnected from console proxy")
+		s.recordAttach(false)
 	}()
 
 	// Copy client input to console (client -> VM)
@@ -230,6 +284,11 @@ func (s *ConsoleProxyServer) Stop() error {
 	// Wait for all goroutines to finish
 	s.wg.Wait()
 
+	if s.logFile != nil {
+		_ = s.log.Flush()
+		_ = s.logFile.Close()
+	}
+
 	// Remove socket file
 	if err := os.Remove(s.socketPath); err != nil && !os.IsNotExist(err) {
 		debugLog("Failed to remove socket file: %v", err)
@@ -239,6 +298,55 @@ func (s *ConsoleProxyServer) Stop() error {
 	return nil
 }
 
+// Notice shows message to the attached client on a line of its own,
+// highlighted. Returns false if no client is attached.
+func (s *ConsoleProxyServer) Notice(message string) bool {
+	s.clientMu.RLock()
+	client := s.currentClient
+	s.clientMu.RUnlock()
+	if client == nil {
+		return false
+	}
+	if _, err := client.Write([]byte("\r\n\x1b[1;7m " + message + " \x1b[0m\r\n")); err != nil {
+		debugLog("Failed to write notice: %v", err)
+		return false
+	}
+	return true
+}
+
+// warnSession shows a warning in a session's attached console, or posts a
+// desktop notification if none is attached and the session has them on
+func warnSession(sessions *session.Store, proxy *ConsoleProxyServer, id, message string) {
+	if proxy != nil && proxy.Notice(message) {
+		return
+	}
+	sess, err := sessions.Load(id)
+	if err != nil || !sess.Notify {
+		return
+	}
+	if err := notifyUser("faize: "+filepath.Base(sess.ProjectDir), message); err != nil {
+		debugLog("Failed to post notification: %v", err)
+	}
+}
+
+// recordAttach adds an attach or detach to the session's history
+func (s *ConsoleProxyServer) recordAttach(attached bool) {
+	store, err := session.NewStore()
+	if err == nil {
+		err = recordAttach(store, s.sessionID, attached, time.Now())
+	}
+	if err != nil {
+		debugLog("Failed to record attach: %v", err)
+	}
+}
+
+// Attached reports whether a client is connected to the console
+func (s *ConsoleProxyServer) Attached() bool {
+	s.clientMu.RLock()
+	defer s.clientMu.RUnlock()
+	return s.currentClient != nil
+}
+
 // SocketPath returns the Unix socket path
 func (s *ConsoleProxyServer) SocketPath() string {
 	return s.socketPath
@@ -0,0 +1,62 @@
+//go:build darwin || linux
+
+package vm
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenConsoleLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), consoleLogFile)
+
+	f, err := openConsoleLog(path)
+	require.NoError(t, err)
+	_, _ = f.WriteString("first\n")
+	require.NoError(t, f.Close())
+
+	// Reopening appends
+	f, err = openConsoleLog(path)
+	require.NoError(t, err)
+	_, _ = f.WriteString("second\n")
+	require.NoError(t, f.Close())
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "first\nsecond\n", string(data))
+}
+
+func TestOpenConsoleLog_Rotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), consoleLogFile)
+	require.NoError(t, os.WriteFile(path, make([]byte, maxConsoleLog+1), 0600))
+
+	f, err := openConsoleLog(path)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Zero(t, info.Size())
+	assert.FileExists(t, path+".1")
+}
+
+func TestConsoleProxyNotice(t *testing.T) {
+	s := &ConsoleProxyServer{}
+	assert.False(t, s.Notice("not attached"))
+
+	server, client := net.Pipe()
+	defer func() { _ = client.Close() }()
+	s.currentClient = server
+	got := make(chan []byte)
+	go func() {
+		buf := make([]byte, 128)
+		n, _ := client.Read(buf)
+		got <- buf[:n]
+	}()
+	assert.True(t, s.Notice("times out in 5m0s"))
+	assert.Equal(t, "\r\n\x1b[1;7m times out in 5m0s \x1b[0m\r\n", string(<-got))
+}
@@ -0,0 +1,14 @@
+package vm
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/faize-ai/faize/internal/redact"
+)
+
+func debugLog(format string, args ...interface{}) {
+	if os.Getenv("FAIZE_DEBUG") == "1" {
+		fmt.Println(redact.String(fmt.Sprintf("[DEBUG:VM] "+format, args...)))
+	}
+}
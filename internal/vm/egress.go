@@ -0,0 +1,206 @@
+package vm
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/faize-ai/faize/internal/network"
+	"github.com/faize-ai/faize/internal/session"
+)
+
+// usesEgressProxy reports whether a session's allowlist is enforced by the
+// host egress proxy: Claude sessions with a restricted, non-empty policy on
+// a backend that gives the guest a vsock channel to the host.
+func usesEgressProxy(cfg *Config) bool {
+	policy := cfg.NetworkPolicy
+	return cfg.ClaudeMode && policy != nil && !policy.AllowAll && !policy.Blocked && egressAvailable()
+}
+
+// egressPort derives the vsock port the guest reaches the egress proxy on.
+// The port is distinct from guest.ExecPort and, because QEMU guests share
+// the host's port space, different for each session.
+func egressPort(id string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(id))
+	return 1<<16 + h.Sum32()%(1<<30)
+}
+
+// dnsPort derives the vsock port the guest reaches the host DNS resolver on,
+// in a range disjoint from egressPort's
+func dnsPort(id string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(id))
+	return 1<<31 + h.Sum32()%(1<<30)
+}
+
+// egressProxy serves a session's egress proxy while this process owns the VM
+type egressProxy struct {
+	listener net.Listener
+	dns      net.Listener // nil without a host DNS resolver
+	log      *os.File
+	done     chan struct{}
+}
+
+// startEgressProxy starts the egress proxy for the session in sessionDir, if
+// its agent config routes traffic through one. The allowlist comes from the
+// session's network entries, never from the bootstrap share, which the guest
+// can write. listen opens the vsock listeners for the config's ports; with a
+// DNSPort, the guest's name lookups are resolved here under the same policy.
+// Decisions are appended to network.ProxyLogFile. With network_prompt,
+// denied connections are put to the user while attached reports a console
+// client. Returns nil if no proxy is needed, and an error if it could not
+// start or the session's allowlist is invalid; the guest firewall still
+// blocks direct egress then. The session's bandwidth limit
+// applies to proxied traffic, which the guest's tc shaping never sees, and
+// allowed connections go through the session's upstream proxy, if any.
+func startEgressProxy(sessions *session.Store, sess *session.Session, sessionDir string, attached func() bool, listen func(port uint32) (net.Listener, error)) (*egressProxy, error) {
+	bootstrapDir := bootstrapPath(sessionDir)
+	cfg, err := guest.ReadConfig(filepath.Join(bootstrapDir, guest.ConfigFile))
+	if err != nil || cfg.EgressPort == 0 {
+		return nil, nil
+	}
+
+	// faize start refuses invalid entries, so one here means the session
+	// record was edited; enforcing what is left of it could allow more
+	policy, problems := network.ParseStrict(sess.Network)
+	for _, p := range problems {
+		if p.Fatal {
+			return nil, fmt.Errorf("invalid network entry %s", p)
+		}
+	}
+
+	log, err := os.OpenFile(filepath.Join(bootstrapDir, network.ProxyLogFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open egress proxy log: %w", err)
+	}
+	listener, err := listen(cfg.EgressPort)
+	if err != nil {
+		_ = log.Close()
+		return nil, fmt.Errorf("failed to listen for the guest: %w", err)
+	}
+
+	proxy := network.NewProxy(policy, log)
+	id := sess.ID
+	prompt := func() bool {
+		current, err := sessions.Load(id)
+		return err == nil && current.NetworkPrompt && attached()
+	}
+	if approver, err := newApprover(sessionDir, prompt, func(host string) error {
+		return allowForSession(sessions, id, sessionDir, host)
+	}); err != nil {
+		debugLog("Network prompts unavailable: %v", err)
+	} else {
+		proxy.SetApprover(approver.Approve)
+	}
+	if sess.NetLimit != 0 {
+		proxy.SetRateLimit(sess.NetLimit)
+	}
+	if len(sess.Hosts) > 0 {
+		hosts := make(map[string]string, len(sess.Hosts))
+		for _, entry := range sess.Hosts {
+			hosts[entry.Name] = entry.IP
+		}
+		proxy.SetHosts(hosts)
+	}
+	if sess.Proxy != "" {
+		if upstream, err := network.ParseProxyURL(sess.Proxy); err != nil {
+			debugLog("Ignoring upstream proxy: %v", err)
+		} else {
+			proxy.SetUpstream(upstream, sess.NoProxy)
+		}
+	}
+	go func() {
+		if err := proxy.Serve(listener); err != nil {
+			debugLog("Egress proxy stopped: %v", err)
+		}
+	}()
+	debugLog("Egress proxy listening on vsock port %d", cfg.EgressPort)
+
+	e := &egressProxy{listener: listener, log: log, done: make(chan struct{})}
+	var resolver *network.Resolver
+	if cfg.DNSPort != 0 {
+		if dns, err := listen(cfg.DNSPort); err != nil {
+			debugLog("Failed to start DNS resolver: %v", err)
+		} else {
+			e.dns = dns
+			nameservers := sess.Resolvers
+			if len(nameservers) == 0 {
+				nameservers = network.Nameservers("/etc/resolv.conf")
+			}
+			resolver = network.NewResolver(policy, nameservers, log)
+			go func() {
+				if err := resolver.Serve(dns); err != nil {
+					debugLog("DNS resolver stopped: %v", err)
+				}
+			}()
+			debugLog("DNS resolver listening on vsock port %d", cfg.DNSPort)
+		}
+	}
+
+	go watchAllowlist(e.done, sessionDir, func(specs []string) {
+		extended := policy.Extend(specs)
+		proxy.SetPolicy(extended)
+		if resolver != nil {
+			resolver.SetPolicy(extended)
+		}
+	})
+	return e, nil
+}
+
+// allowForSession records a host the user allowed for the rest of the
+// session, as faize allow does, so it survives a pause and resume
+func allowForSession(sessions *session.Store, id, sessionDir, host string) error {
+	if _, err := guest.AppendAllow(sessionDir, host); err != nil {
+		return err
+	}
+	_, err := sessions.Update(id, func(s *session.Session) bool {
+		s.Network = append(s.Network, host)
+		return true
+	})
+	return err
+}
+
+// Stop closes the proxy's listener and log. Tunnels already open end with the VM.
+func (e *egressProxy) Stop() {
+	close(e.done)
+	_ = e.listener.Close()
+	if e.dns != nil {
+		_ = e.dns.Close()
+	}
+	_ = e.log.Close()
+}
+
+// allowPoll is how often the host checks for domains added with faize allow
+const allowPoll = 500 * time.Millisecond
+
+// watchAllowlist calls apply with every allowlist addition in dir
+// (guest.AllowFile) whenever new ones are appended, then acknowledges them
+// in guest.AllowedFile for faize allow. dir is the host session directory;
+// the guest's copy in the bootstrap share is only used by its own firewall.
+func watchAllowlist(done <-chan struct{}, dir string, apply func(specs []string)) {
+	applied := 0
+	ticker := time.NewTicker(allowPoll)
+	defer ticker.Stop()
+	for {
+		specs, err := guest.ReadAllow(dir)
+		if err == nil && len(specs) > applied {
+			apply(specs)
+			applied = len(specs)
+			if err := guest.WriteAllowed(dir, applied); err != nil {
+				debugLog("Failed to acknowledge allowlist additions: %v", err)
+			}
+			debugLog("Egress proxy allowlist extended: %v", specs)
+		}
+
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
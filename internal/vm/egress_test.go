@@ -0,0 +1,128 @@
+package vm
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/faize-ai/faize/internal/network"
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEgressPort(t *testing.T) {
+	port := egressPort("0123456789ab")
+	assert.Equal(t, port, egressPort("0123456789ab"), "the port is derived from the session ID")
+	assert.NotEqual(t, port, egressPort("ba9876543210"))
+	assert.Greater(t, port, uint32(guest.ExecPort))
+	assert.Less(t, port, uint32(1<<32-1), "VMADDR_PORT_ANY is reserved")
+}
+
+func TestDNSPort(t *testing.T) {
+	for _, id := range []string{"0123456789ab", "ba9876543210", "ffffffffffff"} {
+		port := dnsPort(id)
+		assert.Equal(t, port, dnsPort(id), "the port is derived from the session ID")
+		assert.Greater(t, port, egressPort(id), "the port never collides with an egress port")
+		assert.Less(t, port, uint32(1<<32-1), "VMADDR_PORT_ANY is reserved")
+	}
+}
+
+func TestStartEgressProxy(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	store, err := session.NewStore()
+	require.NoError(t, err)
+	sess := &session.Session{ID: "0123456789ab", Network: []string{"anthropic"}, Status: "running"}
+	require.NoError(t, store.Save(sess))
+
+	sessionDir := t.TempDir()
+	dir := bootstrapPath(sessionDir)
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	listen := func(uint32) (net.Listener, error) { return net.Listen("tcp", "127.0.0.1:0") }
+	attached := func() bool { return false }
+
+	// No proxy without an egress port in the agent config
+	cfg := guest.NewConfig(true, nil, "", &network.Policy{Domains: []string{"api.anthropic.com"}}, false)
+	require.NoError(t, guest.WriteConfig(dir, cfg))
+	proxy, err := startEgressProxy(store, sess, sessionDir, attached, listen)
+	require.NoError(t, err)
+	assert.Nil(t, proxy)
+
+	// The guest can rewrite its config; the proxy only trusts the session's networks
+	cfg.Network = &network.Policy{AllowAll: true}
+
+	cfg.EgressPort = egressPort("0123456789ab")
+	cfg.DNSPort = dnsPort("0123456789ab")
+	require.NoError(t, guest.WriteConfig(dir, cfg))
+	var gotPorts []uint32
+	proxy, err = startEgressProxy(store, sess, sessionDir, attached, func(port uint32) (net.Listener, error) {
+		gotPorts = append(gotPorts, port)
+		return listen(port)
+	})
+	require.NoError(t, err)
+	require.NotNil(t, proxy)
+	defer proxy.Stop()
+	assert.Equal(t, []uint32{cfg.EgressPort, cfg.DNSPort}, gotPorts)
+	require.NotNil(t, proxy.dns, "the DNS resolver listens with a DNS port")
+
+	conn, err := net.Dial("tcp", proxy.listener.Addr().String())
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+	_, _ = fmt.Fprint(conn, "CONNECT example.com:443 HTTP/1.1\r\nHost: example.com:443\r\n\r\n")
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// Name lookups outside the allowlist get NXDOMAIN without leaving the host
+	dnsConn, err := net.Dial("tcp", proxy.dns.Addr().String())
+	require.NoError(t, err)
+	defer func() { _ = dnsConn.Close() }()
+	query := []byte{0, 1, 1, 0, 0, 1, 0, 0, 0, 0, 0, 0, 7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0, 0, 1, 0, 1}
+	require.NoError(t, network.WriteDNSMessage(dnsConn, query))
+	answer, err := network.ReadDNSMessage(dnsConn)
+	require.NoError(t, err)
+	assert.Equal(t, byte(3), answer[3]&0x0f, "NXDOMAIN")
+
+	log, err := os.ReadFile(filepath.Join(dir, network.ProxyLogFile))
+	require.NoError(t, err)
+	assert.Contains(t, string(log), network.ProxyLogPrefix+"DENY CONNECT example.com:443")
+	assert.Contains(t, string(log), network.ProxyLogPrefix+"DENY DNS example.com:53")
+
+	// An invalid entry in the session record is an error, not dropped
+	sess.Network = []string{"anthropic", "*.com"}
+	_, err = startEgressProxy(store, sess, sessionDir, attached, listen)
+	assert.ErrorContains(t, err, "invalid network entry")
+}
+
+func TestWatchAllowlist(t *testing.T) {
+	dir := t.TempDir()
+	got := make(chan []string, 4)
+	done := make(chan struct{})
+	defer close(done)
+	go watchAllowlist(done, dir, func(specs []string) { got <- specs })
+
+	_, err := guest.AppendAllow(dir, "example.com")
+	require.NoError(t, err)
+	select {
+	case specs := <-got:
+		assert.Equal(t, []string{"example.com"}, specs)
+	case <-time.After(5 * time.Second):
+		t.Fatal("allowlist addition not applied")
+	}
+	assert.Eventually(t, func() bool { return guest.ReadAllowed(dir) == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	_, err = guest.AppendAllow(dir, "*.corp.dev")
+	require.NoError(t, err)
+	select {
+	case specs := <-got:
+		assert.Equal(t, []string{"example.com", "*.corp.dev"}, specs)
+	case <-time.After(5 * time.Second):
+		t.Fatal("second allowlist addition not applied")
+	}
+}
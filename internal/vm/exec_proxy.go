@@ -0,0 +1,177 @@
+//go:build darwin || linux
+
+package vm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/faize-ai/faize/internal/guest"
+)
+
+// guestDialer opens a connection to the guest agent's exec port
+type guestDialer func() (io.ReadWriteCloser, error)
+
+// execSocketPath returns the Unix socket path for a session's exec proxy
+func execSocketPath(id string) string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".faize", "sessions", fmt.Sprintf("%s.exec.sock", id))
+}
+
+// ExecProxyServer forwards faize exec clients from a Unix socket to the guest
+// agent's vsock port. It runs in the process that owns the VM, since only that
+// process can reach the guest's socket device.
+type ExecProxyServer struct {
+	socketPath string
+	listener   net.Listener
+	dial       guestDialer
+	mu         sync.Mutex
+	done       chan struct{}
+	wg         sync.WaitGroup
+}
+
+// NewExecProxyServer creates a new exec proxy server
+func NewExecProxyServer(sessionID string, dial guestDialer) (*ExecProxyServer, error) {
+	socketPath := execSocketPath(sessionID)
+	if err := os.MkdirAll(filepath.Dir(socketPath), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create socket directory: %w", err)
+	}
+
+	// Remove existing socket file if present
+	_ = os.Remove(socketPath)
+
+	return &ExecProxyServer{
+		socketPath: socketPath,
+		dial:       dial,
+		done:       make(chan struct{}),
+	}, nil
+}
+
+// Start begins accepting connections on the Unix socket
+func (s *ExecProxyServer) Start() error {
+	listener, err := net.Listen("unix", s.socketPath)
+	if err != nil {
+		return fmt.Errorf("failed to create Unix socket listener: %w", err)
+	}
+	s.listener = listener
+	debugLog("Exec proxy listening on %s", s.socketPath)
+
+	s.wg.Add(1)
+	go s.acceptLoop()
+	return nil
+}
+
+// acceptLoop accepts exec clients; each one gets its own guest connection
+func (s *ExecProxyServer) acceptLoop() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			select {
+			case <-s.done:
+				return
+			default:
+				debugLog("Exec accept error: %v", err)
+				continue
+			}
+		}
+
+		s.wg.Add(1)
+		go s.forward(conn)
+	}
+}
+
+// forward relays one client connection to the guest agent until either side closes
+func (s *ExecProxyServer) forward(client net.Conn) {
+	defer s.wg.Done()
+	defer func() { _ = client.Close() }()
+
+	guestConn, err := s.dial()
+	if err != nil {
+		debugLog("Exec dial error: %v", err)
+		_ = guest.WriteFrame(client, guest.FrameError, []byte("guest agent not reachable (the VM image may predate faize exec; rebuild it with 'faize claude rebuild')"))
+		return
+	}
+	defer func() { _ = guestConn.Close() }()
+
+	// The client sends nothing after its request, so its end closing means it
+	// gave up; closing the guest side has the agent kill the command
+	go func() {
+		_, _ = io.Copy(guestConn, client)
+		_ = guestConn.Close()
+	}()
+	if _, err := io.Copy(client, guestConn); err != nil {
+		debugLog("Exec relay error: %v", err)
+	}
+}
+
+// SocketPath returns the path to the Unix socket
+func (s *ExecProxyServer) SocketPath() string {
+	return s.socketPath
+}
+
+// Stop closes the listener and removes the socket file. In-flight commands
+// end when the VM stops.
+func (s *ExecProxyServer) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return nil
+	default:
+	}
+	close(s.done)
+
+	if s.listener != nil {
+		_ = s.listener.Close()
+	}
+	_ = os.Remove(s.socketPath)
+	return nil
+}
+
+// startExecProxy starts an exec proxy for a session, returning nil if it can't
+// be started; faize exec is then unavailable but the session still runs.
+func startExecProxy(id string, dial guestDialer) *ExecProxyServer {
+	proxy, err := NewExecProxyServer(id, dial)
+	if err != nil {
+		debugLog("Failed to create exec proxy: %v", err)
+		return nil
+	}
+	if err := proxy.Start(); err != nil {
+		debugLog("Failed to start exec proxy: %v", err)
+		return nil
+	}
+	return proxy
+}
+
+// Exec runs a command inside a running session through the exec proxy of the
+// process that owns it, copying output to stdout and stderr. It returns the
+// command's exit code.
+func Exec(id string, req *guest.ExecRequest, stdout, stderr io.Writer) (int, error) {
+	return ExecContext(context.Background(), id, req, stdout, stderr)
+}
+
+// ExecContext is Exec that gives up on the command when ctx is done. Closing
+// the connection makes the guest agent kill the command.
+func ExecContext(ctx context.Context, id string, req *guest.ExecRequest, stdout, stderr io.Writer) (int, error) {
+	conn, err := net.Dial("unix", execSocketPath(id))
+	if err != nil {
+		return -1, fmt.Errorf("exec is not available for session %s (is it running?)", id)
+	}
+	defer func() { _ = conn.Close() }()
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	code, err := guest.RunExec(conn, req, stdout, stderr)
+	if ctx.Err() != nil {
+		return -1, ctx.Err()
+	}
+	return code, err
+}
@@ -0,0 +1,100 @@
+//go:build darwin || linux
+
+package vm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecProxyForwardsToGuest(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	dial := func() (io.ReadWriteCloser, error) {
+		client, server := net.Pipe()
+		go func() {
+			defer func() { _ = server.Close() }()
+			req, err := guest.ReadExecRequest(server)
+			if err != nil {
+				return
+			}
+			_ = guest.WriteFrame(server, guest.FrameStdout, []byte(req.Args[0]))
+			_ = guest.WriteExitFrame(server, 7)
+		}()
+		return client, nil
+	}
+
+	proxy := startExecProxy("sess1", dial)
+	require.NotNil(t, proxy)
+	defer func() { _ = proxy.Stop() }()
+
+	var stdout bytes.Buffer
+	code, err := Exec("sess1", &guest.ExecRequest{Args: []string{"whoami"}}, &stdout, io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, 7, code)
+	assert.Equal(t, "whoami", stdout.String())
+}
+
+func TestExecContextHangsUpOnCancel(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	hungUp := make(chan struct{})
+	dial := func() (io.ReadWriteCloser, error) {
+		client, server := net.Pipe()
+		go func() {
+			defer func() { _ = server.Close() }()
+			if _, err := guest.ReadExecRequest(server); err != nil {
+				return
+			}
+			// The command never finishes; the agent kills it when the host hangs up
+			_, _ = io.Copy(io.Discard, server)
+			close(hungUp)
+		}()
+		return client, nil
+	}
+
+	proxy := startExecProxy("sess3", dial)
+	require.NotNil(t, proxy)
+	defer func() { _ = proxy.Stop() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := ExecContext(ctx, "sess3", &guest.ExecRequest{Args: []string{"sleep", "60"}}, io.Discard, io.Discard)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	select {
+	case <-hungUp:
+	case <-time.After(5 * time.Second):
+		t.Fatal("guest connection still open after the context ended")
+	}
+}
+
+func TestExecProxyGuestUnreachable(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	proxy := startExecProxy("sess2", func() (io.ReadWriteCloser, error) {
+		return nil, errors.New("connection refused")
+	})
+	require.NotNil(t, proxy)
+	defer func() { _ = proxy.Stop() }()
+
+	_, err := Exec("sess2", &guest.ExecRequest{Args: []string{"true"}}, io.Discard, io.Discard)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "guest agent not reachable")
+}
+
+func TestExecWithoutProxy(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	_, err := Exec("missing", &guest.ExecRequest{Args: []string{"true"}}, io.Discard, io.Discard)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is it running?")
+}
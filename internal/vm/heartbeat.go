@@ -0,0 +1,44 @@
+package vm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/faize-ai/faize/internal/guest"
+)
+
+// heartbeatInterval is how often the host process that owns a VM touches the
+// heartbeat file. It must stay well below MinWatchdog.
+const heartbeatInterval = 15 * time.Second
+
+// MinWatchdog is the shortest accepted guest watchdog timeout
+const MinWatchdog = time.Minute
+
+// writeHeartbeat records a host heartbeat in the bootstrap directory. The guest
+// only compares contents, so host and guest clocks don't need to agree.
+func writeHeartbeat(bootstrapDir string) error {
+	path := filepath.Join(bootstrapDir, guest.HeartbeatFile)
+	if err := os.WriteFile(path, []byte(strconv.FormatInt(time.Now().UnixNano(), 10)), 0644); err != nil {
+		return fmt.Errorf("failed to write heartbeat: %w", err)
+	}
+	return nil
+}
+
+// runHeartbeat touches the heartbeat file until alive reports that the VM has
+// stopped. If the host process dies, the heartbeats stop and the guest watchdog
+// cleans up and powers the VM off.
+func runHeartbeat(bootstrapDir string, alive func() bool) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !alive() {
+			return
+		}
+		if err := writeHeartbeat(bootstrapDir); err != nil {
+			debugLog("%v", err)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package vm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteHeartbeat(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, guest.HeartbeatFile)
+
+	require.NoError(t, writeHeartbeat(dir))
+	first, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotEmpty(t, first)
+
+	time.Sleep(time.Millisecond)
+	require.NoError(t, writeHeartbeat(dir))
+	second, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotEqual(t, string(first), string(second), "each heartbeat must change the file contents")
+}
+
+func TestWriteHeartbeatMissingDir(t *testing.T) {
+	assert.Error(t, writeHeartbeat(filepath.Join(t.TempDir(), "missing")))
+}
@@ -0,0 +1,54 @@
+package vm
+
+import (
+	"time"
+
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/faize-ai/faize/internal/session"
+)
+
+// usageSampleInterval is how often the owner of a VM reads the guest's
+// resource usage; it matches how often the agent samples it
+const usageSampleInterval = 2 * time.Second
+
+// recordAttach adds the start or end of an attach to a session's history
+func recordAttach(sessions *session.Store, id string, attached bool, at time.Time) error {
+	_, err := sessions.Update(id, func(s *session.Session) bool {
+		if attached {
+			s.RecordAttach(at)
+		} else {
+			s.RecordDetach(at)
+		}
+		return true
+	})
+	return err
+}
+
+// runUsageSampler records the guest's peak CPU and memory use in the session
+// record until alive reports that the VM has stopped
+func runUsageSampler(sessions *session.Store, id, bootstrapDir string, alive func() bool) {
+	ticker := time.NewTicker(usageSampleInterval)
+	defer ticker.Stop()
+	var peak session.Session
+	for range ticker.C {
+		if !alive() {
+			return
+		}
+		stats, err := guest.ReadStats(bootstrapDir)
+		// Only samples above the peaks seen so far need the record updated
+		if err != nil || !peak.RecordUsage(stats.CPU, stats.MemUsed) {
+			continue
+		}
+		if err := recordUsage(sessions, id, stats); err != nil {
+			debugLog("Failed to record resource usage: %v", err)
+		}
+	}
+}
+
+// recordUsage raises a session's recorded peaks to a stats sample's
+func recordUsage(sessions *session.Store, id string, stats *guest.Stats) error {
+	_, err := sessions.Update(id, func(s *session.Session) bool {
+		return s.RecordUsage(stats.CPU, stats.MemUsed)
+	})
+	return err
+}
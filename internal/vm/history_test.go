@@ -0,0 +1,34 @@
+package vm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordHistory(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	store, err := session.NewStore()
+	require.NoError(t, err)
+	require.NoError(t, store.Save(&session.Session{ID: "abc789", Status: "running"}))
+
+	at := time.Now().Truncate(time.Second)
+	require.NoError(t, recordAttach(store, "abc789", true, at))
+	require.NoError(t, recordAttach(store, "abc789", false, at.Add(30*time.Second)))
+	require.NoError(t, recordUsage(store, "abc789", &guest.Stats{CPU: 75, MemUsed: 512 << 20}))
+	require.NoError(t, recordUsage(store, "abc789", &guest.Stats{CPU: 20, MemUsed: 256 << 20}))
+
+	sess, err := store.Load("abc789")
+	require.NoError(t, err)
+	require.Len(t, sess.Attaches, 1)
+	assert.True(t, at.Equal(sess.Attaches[0].AttachedAt))
+	assert.Equal(t, 30.0, sess.AttachedSeconds)
+	assert.Equal(t, 75.0, sess.PeakCPU)
+	assert.Equal(t, uint64(512<<20), sess.PeakMemory)
+
+	assert.ErrorIs(t, recordAttach(store, "fff999", true, at), session.ErrNotFound)
+}
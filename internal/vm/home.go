@@ -0,0 +1,21 @@
+package vm
+
+import (
+	"fmt"
+	"os"
+)
+
+// HomePath returns where a project's persistent guest home directory is kept
+// (faize start --persist-home): one per project directory, in ~/.faize/homes
+func HomePath(projectDir string) (string, error) {
+	return projectStatePath("homes", projectDir, "")
+}
+
+// prepareHome creates a project's persistent home directory. The guest agent
+// seeds it from the rootfs's home on first use.
+func prepareHome(path string) error {
+	if err := os.MkdirAll(path, 0700); err != nil {
+		return fmt.Errorf("failed to create home directory: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package vm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHomePath(t *testing.T) {
+	a, err := HomePath("/home/user/code/app")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(filepath.Base(a), "app-"))
+	assert.Equal(t, "homes", filepath.Base(filepath.Dir(a)))
+
+	// The home and the overlay of a project are named alike
+	overlay, err := OverlayPath("/home/user/code/app")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Base(a)+".img", filepath.Base(overlay))
+}
+
+func TestPrepareHome(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "homes", "app-0123456789ab")
+	require.NoError(t, prepareHome(path))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.True(t, info.IsDir())
+	assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+
+	// An existing home is kept
+	require.NoError(t, os.WriteFile(filepath.Join(path, ".ash_history"), []byte("ls\n"), 0600))
+	require.NoError(t, prepareHome(path))
+	assert.FileExists(t, filepath.Join(path, ".ash_history"))
+}
@@ -0,0 +1,14 @@
+//go:build darwin
+
+package vm
+
+import "golang.org/x/sys/unix"
+
+// physicalMemory returns the host's physical memory in bytes, or 0 if unknown
+func physicalMemory() uint64 {
+	size, err := unix.SysctlUint64("hw.memsize")
+	if err != nil {
+		return 0
+	}
+	return size
+}
@@ -0,0 +1,14 @@
+//go:build linux
+
+package vm
+
+import "golang.org/x/sys/unix"
+
+// physicalMemory returns the host's physical memory in bytes, or 0 if unknown
+func physicalMemory() uint64 {
+	var info unix.Sysinfo_t
+	if err := unix.Sysinfo(&info); err != nil {
+		return 0
+	}
+	return uint64(info.Totalram) * uint64(info.Unit)
+}
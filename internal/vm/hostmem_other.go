@@ -0,0 +1,8 @@
+//go:build !darwin && !linux
+
+package vm
+
+// physicalMemory returns the host's physical memory in bytes, or 0 if unknown
+func physicalMemory() uint64 {
+	return 0
+}
@@ -0,0 +1,54 @@
+package vm
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/faize-ai/faize/internal/session"
+)
+
+// ParseHosts parses --add-host specs of the form NAME:IP. A later entry for
+// the same name replaces an earlier one, so flags override the config.
+func ParseHosts(specs []string) ([]session.HostEntry, error) {
+	var entries []session.HostEntry
+	index := make(map[string]int)
+	for _, spec := range specs {
+		name, addr, found := strings.Cut(strings.TrimSpace(spec), ":")
+		name = strings.ToLower(name)
+		if !found || !validHostName(name) {
+			return nil, fmt.Errorf("invalid host entry '%s': expected NAME:IP", spec)
+		}
+		ip := net.ParseIP(strings.Trim(addr, "[]"))
+		if ip == nil {
+			return nil, fmt.Errorf("invalid host entry '%s': '%s' is not an IP address", spec, addr)
+		}
+
+		entry := session.HostEntry{Name: name, IP: ip.String()}
+		if i, ok := index[name]; ok {
+			entries[i] = entry
+			continue
+		}
+		index[name] = len(entries)
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// validHostName reports whether name can appear in /etc/hosts
+func validHostName(name string) bool {
+	if name == "" || len(name) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,24 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHosts(t *testing.T) {
+	entries, err := ParseHosts([]string{"db.local:192.168.1.20", "API.internal:10.0.0.5", "v6.local:fd00::1", "db.local:192.168.1.21"})
+	require.NoError(t, err)
+	assert.Equal(t, []session.HostEntry{
+		{Name: "db.local", IP: "192.168.1.21"},
+		{Name: "api.internal", IP: "10.0.0.5"},
+		{Name: "v6.local", IP: "fd00::1"},
+	}, entries, "later entries replace earlier ones for the same name")
+
+	for _, bad := range []string{"db.local", ":10.0.0.1", "db.local:nope", "bad name:10.0.0.1", "-db.local:10.0.0.1"} {
+		_, err := ParseHosts([]string{bad})
+		assert.Error(t, err, bad)
+	}
+}
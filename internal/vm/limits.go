@@ -0,0 +1,87 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/faize-ai/faize/internal/session"
+)
+
+// ErrLimitExceeded is returned when creating a session would exceed the configured resource limits
+var ErrLimitExceeded = errors.New("session limit exceeded")
+
+// runningSessions returns the sessions with status "running", oldest first
+func runningSessions(sessions []*session.Session) []*session.Session {
+	var running []*session.Session
+	for _, s := range sessions {
+		if s.Status == "running" {
+			running = append(running, s)
+		}
+	}
+	sort.Slice(running, func(i, j int) bool {
+		return running[i].StartedAt.Before(running[j].StartedAt)
+	})
+	return running
+}
+
+// checkLimits returns an error if adding a session with cfg.Memory to the
+// already running sessions would exceed the limits in cfg.
+func checkLimits(cfg *Config, running []*session.Session) error {
+	var reasons []string
+
+	if cfg.MaxRunningSessions > 0 && len(running)+1 > cfg.MaxRunningSessions {
+		reasons = append(reasons, fmt.Sprintf("max_running_sessions is %d", cfg.MaxRunningSessions))
+	}
+
+	if cfg.MaxTotalMemory != "" {
+		limit := parseMemory(cfg.MaxTotalMemory)
+		total := parseMemory(cfg.Memory)
+		for _, s := range running {
+			total += parseMemory(s.Memory)
+		}
+		if total > limit {
+			reasons = append(reasons, fmt.Sprintf("max_total_memory is %s", cfg.MaxTotalMemory))
+		}
+	}
+
+	if len(reasons) == 0 {
+		return nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Running sessions (%d):\n", len(running))
+	for _, s := range running {
+		fmt.Fprintf(&sb, "  %s  %s  %s  started %s\n",
+			s.ID, s.ProjectDir, s.Memory, s.StartedAt.Format("2006-01-02 15:04:05"))
+	}
+	sb.WriteString("Stop a session with 'faize kill --force' or retry with --replace-oldest")
+	return fmt.Errorf("%w (%s)\n%s", ErrLimitExceeded, strings.Join(reasons, ", "), sb.String())
+}
+
+// enforceLimits checks cfg against the sessions known to m. When cfg.ReplaceOldest
+// is set, the oldest running sessions are stopped until the new session fits.
+func enforceLimits(m Manager, cfg *Config) error {
+	if cfg.MaxRunningSessions <= 0 && cfg.MaxTotalMemory == "" {
+		return nil
+	}
+
+	sessions, err := m.List()
+	if err != nil {
+		return fmt.Errorf("failed to list sessions: %w", err)
+	}
+	running := runningSessions(sessions)
+
+	err = checkLimits(cfg, running)
+	for err != nil && cfg.ReplaceOldest && len(running) > 0 {
+		oldest := running[0]
+		fmt.Printf("Session limit reached, stopping oldest session %s (%s)...\n", oldest.ID, oldest.ProjectDir)
+		if stopErr := m.Stop(oldest.ID); stopErr != nil {
+			return fmt.Errorf("failed to stop session %s: %w", oldest.ID, stopErr)
+		}
+		running = running[1:]
+		err = checkLimits(cfg, running)
+	}
+	return err
+}
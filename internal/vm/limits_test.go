@@ -0,0 +1,87 @@
+package vm
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// limitsTestManager is a minimal Manager backed by an in-memory session list
+type limitsTestManager struct {
+	StubManager
+	sessions []*session.Session
+	stopped  []string
+}
+
+func (m *limitsTestManager) List() ([]*session.Session, error) {
+	return m.sessions, nil
+}
+
+func (m *limitsTestManager) Stop(id string) error {
+	m.stopped = append(m.stopped, id)
+	for _, s := range m.sessions {
+		if s.ID == id {
+			s.Status = "stopped"
+		}
+	}
+	return nil
+}
+
+func newRunning(id, memory string, started time.Time) *session.Session {
+	return &session.Session{ID: id, ProjectDir: "/tmp/" + id, Memory: memory, Status: "running", StartedAt: started}
+}
+
+func TestCheckLimits(t *testing.T) {
+	now := time.Now()
+	running := []*session.Session{
+		newRunning("aaa", "4GB", now.Add(-2*time.Hour)),
+		newRunning("bbb", "4GB", now.Add(-1*time.Hour)),
+	}
+
+	tests := []struct {
+		name    string
+		cfg     *Config
+		wantErr bool
+	}{
+		{name: "no limits", cfg: &Config{Memory: "4GB"}},
+		{name: "under session limit", cfg: &Config{Memory: "4GB", MaxRunningSessions: 3}},
+		{name: "at session limit", cfg: &Config{Memory: "4GB", MaxRunningSessions: 2}, wantErr: true},
+		{name: "under memory limit", cfg: &Config{Memory: "4GB", MaxTotalMemory: "12GB"}},
+		{name: "over memory limit", cfg: &Config{Memory: "4GB", MaxTotalMemory: "10GB"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkLimits(tt.cfg, running)
+			if !tt.wantErr {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, ErrLimitExceeded))
+			assert.Contains(t, err.Error(), "aaa")
+			assert.Contains(t, err.Error(), "bbb")
+		})
+	}
+}
+
+func TestEnforceLimits_ReplaceOldest(t *testing.T) {
+	now := time.Now()
+	m := &limitsTestManager{sessions: []*session.Session{
+		newRunning("newer", "4GB", now.Add(-1*time.Hour)),
+		newRunning("older", "4GB", now.Add(-2*time.Hour)),
+		{ID: "done", Memory: "4GB", Status: "stopped", StartedAt: now.Add(-3 * time.Hour)},
+	}}
+
+	err := enforceLimits(m, &Config{Memory: "4GB", MaxRunningSessions: 2})
+	require.Error(t, err)
+	assert.Empty(t, m.stopped)
+
+	err = enforceLimits(m, &Config{Memory: "4GB", MaxRunningSessions: 2, ReplaceOldest: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"older"}, m.stopped)
+}
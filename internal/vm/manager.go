@@ -2,6 +2,7 @@ package vm
 
 import (
 	"errors"
+	"time"
 
 	"github.com/faize-ai/faize/internal/session"
 )
@@ -21,6 +22,46 @@ type Manager interface {
 	WaitForVMStop(id string) <-chan struct{}
 }
 
+// Suspender is implemented by backends that can save a running VM's state to
+// disk and restore it later (faize pause / faize resume)
+type Suspender interface {
+	// Suspend saves the VM's state and releases it, leaving the session "paused"
+	Suspend(id string) error
+	// Resume restores a paused session's VM and starts it running again
+	Resume(id string) (*session.Session, error)
+}
+
+// PauseDeadline keeps what is left of a session's timeout while it is
+// paused, so the time it spends on disk doesn't count against it
+func PauseDeadline(sess *session.Session) {
+	if sess.Deadline == nil {
+		return
+	}
+	sess.TimeoutLeft = max(time.Until(*sess.Deadline), 0).Round(time.Second).String()
+	sess.Deadline = nil
+}
+
+// ResumeDeadline sets a resumed session's deadline from the timeout it had
+// left when it was paused
+func ResumeDeadline(sess *session.Session) {
+	left, err := time.ParseDuration(sess.TimeoutLeft)
+	sess.TimeoutLeft = ""
+	if err != nil {
+		return
+	}
+	deadline := time.Now().Add(left)
+	sess.Deadline = &deadline
+}
+
+// Warner is implemented by backends that can warn the user of a session,
+// e.g. that its timeout is about to stop it
+type Warner interface {
+	// Warn shows message in the session's console if a terminal is attached,
+	// else as a desktop notification if the session has notifications on.
+	// Returns false if this process doesn't own the session's VM.
+	Warn(id, message string) bool
+}
+
 type StubManager struct{}
 
 func NewStubManager() *StubManager {
@@ -0,0 +1,38 @@
+package vm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPauseResumeDeadline(t *testing.T) {
+	deadline := time.Now().Add(90 * time.Minute)
+	sess := &session.Session{Deadline: &deadline}
+
+	PauseDeadline(sess)
+	assert.Nil(t, sess.Deadline)
+	left, err := time.ParseDuration(sess.TimeoutLeft)
+	require.NoError(t, err)
+	assert.InDelta(t, 90*time.Minute, left, float64(2*time.Second))
+
+	ResumeDeadline(sess)
+	assert.Empty(t, sess.TimeoutLeft)
+	require.NotNil(t, sess.Deadline)
+	assert.WithinDuration(t, time.Now().Add(left), *sess.Deadline, 2*time.Second)
+
+	// A session without a timeout has none after resuming
+	sess = &session.Session{}
+	PauseDeadline(sess)
+	ResumeDeadline(sess)
+	assert.Nil(t, sess.Deadline)
+
+	// One that ran out while running resumes out of time
+	past := time.Now().Add(-time.Minute)
+	sess = &session.Session{Deadline: &past}
+	PauseDeadline(sess)
+	assert.Equal(t, "0s", sess.TimeoutLeft)
+}
@@ -0,0 +1,101 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ErrExceedsHostMemory is returned by ParseMemory for sizes larger than the
+// host's memory
+var ErrExceedsHostMemory = errors.New("larger than the host's memory")
+
+// hostMemory returns the host's memory in bytes, or 0 if unknown; tests
+// replace it
+var hostMemory = physicalMemory
+
+// memoryUnits are the suffixes ParseMemory accepts, longest first. Sizes are
+// binary whichever spelling is used, so "4GB" is 4GiB as it always was.
+var memoryUnits = []struct {
+	suffix string
+	size   uint64
+}{
+	{"TIB", 1 << 40}, {"GIB", 1 << 30}, {"MIB", 1 << 20}, {"KIB", 1 << 10},
+	{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+	{"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10},
+	{"B", 1},
+}
+
+// ParseMemory parses a memory size such as "4GB", "512MiB", "4.5GB", or
+// "50%" of the host's memory, rounded down to whole megabytes as the VM
+// backends require. Sizes larger than the host's memory are rejected with
+// ErrExceedsHostMemory.
+func ParseMemory(mem string) (uint64, error) {
+	host := hostMemory()
+	size, err := parseMemorySize(mem, host)
+	if err != nil {
+		return 0, err
+	}
+	if host > 0 && size > host {
+		return 0, fmt.Errorf("memory %s is %w (%s)", mem, ErrExceedsHostMemory, FormatMemory(host))
+	}
+	return size, nil
+}
+
+// parseMemorySize parses a memory size, with percentages taken of host
+// bytes (zero when the host's memory is unknown)
+func parseMemorySize(mem string, host uint64) (uint64, error) {
+	s := strings.ToUpper(strings.TrimSpace(mem))
+	invalid := fmt.Errorf("invalid memory %q: expected a size such as 4GB, 512MiB or 4.5GB, or a percentage of the host's memory such as 50%%", mem)
+
+	var bytes float64
+	if pct, ok := strings.CutSuffix(s, "%"); ok {
+		v, err := strconv.ParseFloat(strings.TrimSpace(pct), 64)
+		if err != nil || v <= 0 || v > 100 {
+			return 0, invalid
+		}
+		if host == 0 {
+			return 0, fmt.Errorf("memory %s is a percentage, but the host's memory is unknown", mem)
+		}
+		bytes = float64(host) * v / 100
+	} else {
+		unit := uint64(0)
+		for _, u := range memoryUnits {
+			if n, ok := strings.CutSuffix(s, u.suffix); ok {
+				s, unit = strings.TrimSpace(n), u.size
+				break
+			}
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if unit == 0 || err != nil || v <= 0 || math.IsInf(v, 0) {
+			return 0, invalid
+		}
+		bytes = v * float64(unit)
+	}
+	if bytes >= math.MaxUint64 {
+		return 0, invalid
+	}
+	size := uint64(bytes) &^ (1<<20 - 1)
+	if size == 0 {
+		return 0, fmt.Errorf("memory %s is less than 1MB", mem)
+	}
+	return size, nil
+}
+
+// parseMemory returns the size of a memory string that was validated with
+// ParseMemory when the session was configured, or 0 if it is invalid
+func parseMemory(mem string) uint64 {
+	size, _ := parseMemorySize(mem, hostMemory())
+	return size
+}
+
+// FormatMemory formats a size in whole megabytes the way ParseMemory reads
+// it, e.g. "4GB" or "4608MB"
+func FormatMemory(size uint64) string {
+	if size >= 1<<30 && size%(1<<30) == 0 {
+		return fmt.Sprintf("%dGB", size>>30)
+	}
+	return fmt.Sprintf("%dMB", size>>20)
+}
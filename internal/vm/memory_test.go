@@ -0,0 +1,71 @@
+package vm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubHostMemory makes the host appear to have size bytes of memory
+func stubHostMemory(t *testing.T, size uint64) {
+	t.Helper()
+	saved := hostMemory
+	hostMemory = func() uint64 { return size }
+	t.Cleanup(func() { hostMemory = saved })
+}
+
+func TestParseMemory(t *testing.T) {
+	stubHostMemory(t, 16<<30)
+	tests := map[string]uint64{
+		"4GB":      4 << 30,
+		"4G":       4 << 30,
+		"4gb":      4 << 30,
+		"512MiB":   512 << 20,
+		"512 MB":   512 << 20,
+		"4.5GB":    4<<30 + 512<<20,
+		"1.25GiB":  1<<30 + 256<<20,
+		"2097152K": 2 << 30,
+		"50%":      8 << 30,
+		"12.5%":    2 << 30,
+		"16GB":     16 << 30,
+		"1.0001MB": 1 << 20, // rounded down to whole megabytes
+	}
+	for in, want := range tests {
+		got, err := ParseMemory(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+
+	for _, in := range []string{"", "4", "GB", "-1GB", "0GB", "4XB", "four GB", "0%", "150%", "100KB", "1e400GB"} {
+		_, err := ParseMemory(in)
+		assert.Error(t, err, in)
+	}
+
+	_, err := ParseMemory("17GB")
+	assert.True(t, errors.Is(err, ErrExceedsHostMemory))
+	assert.ErrorContains(t, err, "16GB")
+
+	// With the host's memory unknown, sizes can't be checked against it and
+	// percentages can't be resolved
+	stubHostMemory(t, 0)
+	got, err := ParseMemory("64GB")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(64<<30), got)
+	_, err = ParseMemory("50%")
+	assert.Error(t, err)
+}
+
+func TestFormatMemory(t *testing.T) {
+	assert.Equal(t, "4GB", FormatMemory(4<<30))
+	assert.Equal(t, "4608MB", FormatMemory(4<<30+512<<20))
+	assert.Equal(t, "512MB", FormatMemory(512<<20))
+
+	stubHostMemory(t, 16<<30)
+	for _, size := range []uint64{4 << 30, 4<<30 + 512<<20, 512 << 20} {
+		got, err := ParseMemory(FormatMemory(size))
+		require.NoError(t, err)
+		assert.Equal(t, size, got)
+	}
+}
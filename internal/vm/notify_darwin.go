@@ -0,0 +1,15 @@
+//go:build darwin
+
+package vm
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// notifyUser posts a macOS notification
+func notifyUser(title, message string) error {
+	quote := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	script := `display notification "` + quote.Replace(message) + `" with title "` + quote.Replace(title) + `"`
+	return exec.Command("osascript", "-e", script).Run()
+}
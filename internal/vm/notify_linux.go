@@ -0,0 +1,10 @@
+//go:build linux
+
+package vm
+
+import "os/exec"
+
+// notifyUser posts a desktop notification with notify-send, when installed
+func notifyUser(title, message string) error {
+	return exec.Command("notify-send", "--app-name=faize", title, message).Run()
+}
@@ -0,0 +1,10 @@
+//go:build !darwin && !linux
+
+package vm
+
+import "fmt"
+
+// notifyUser is not supported on this platform
+func notifyUser(title, message string) error {
+	return fmt.Errorf("notifications are not supported on this platform")
+}
@@ -0,0 +1,10 @@
+//go:build darwin
+
+package vm
+
+import "os/exec"
+
+// OpenBrowser opens a URL in the default macOS browser
+func OpenBrowser(url string) error {
+	return exec.Command("open", url).Start()
+}
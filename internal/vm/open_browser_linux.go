@@ -0,0 +1,10 @@
+//go:build linux
+
+package vm
+
+import "os/exec"
+
+// OpenBrowser opens a URL in the desktop's default browser
+func OpenBrowser(url string) error {
+	return exec.Command("xdg-open", url).Start()
+}
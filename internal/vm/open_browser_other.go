@@ -0,0 +1,10 @@
+//go:build !darwin && !linux
+
+package vm
+
+import "fmt"
+
+// OpenBrowser is not supported on this platform
+func OpenBrowser(url string) error {
+	return fmt.Errorf("opening a browser is not supported on this platform")
+}
@@ -1,11 +1,10 @@
-//go:build darwin
+//go:build darwin || linux
 
 package vm
 
 import (
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
@@ -59,7 +58,9 @@ func watchOpenURL(done <-chan struct{}, bootstrapDir string) {
 				}
 			}
 
-			_ = exec.Command("open", url).Start()
+			if err := OpenBrowser(url); err != nil {
+				debugLog("Failed to open browser: %v", err)
+			}
 		}
 	}
 }
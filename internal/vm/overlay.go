@@ -0,0 +1,95 @@
+package vm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// overlayDiskSize is the size of a new persistent rootfs overlay disk. It is
+// sparse, so it only takes up the space the guest writes.
+const overlayDiskSize = 32 << 30
+
+// overlayBaseSuffix names the file next to an overlay disk that records the
+// rootfs image it is layered on
+const overlayBaseSuffix = ".base"
+
+// OverlayPath returns where a project's persistent rootfs overlay disk is
+// kept (faize start --persist-rootfs): one per project directory, in
+// ~/.faize/overlays
+func OverlayPath(projectDir string) (string, error) {
+	return projectStatePath("overlays", projectDir, ".img")
+}
+
+// projectStatePath returns where state kept per project directory is stored
+// in ~/.faize/<kind>, named after the project and a hash of its path
+func projectStatePath(kind, projectDir, ext string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	dir := resolvePath(projectDir)
+	sum := sha256.Sum256([]byte(dir))
+	name := fmt.Sprintf("%s-%s%s", filepath.Base(dir), hex.EncodeToString(sum[:])[:12], ext)
+	return filepath.Join(home, ".faize", kind, name), nil
+}
+
+// rootfsIdentity identifies a rootfs image by its size and modification
+// time, which change whenever it is rebuilt
+func rootfsIdentity(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d %d", info.Size(), info.ModTime().UnixNano()), nil
+}
+
+// prepareOverlay creates the overlay disk at path for the rootfs image at
+// rootfsPath. An overlay layered on an earlier build of the image is
+// replaced, since the files it changed would hide the new image's; replaced
+// reports that. The disk is left unformatted for the guest's init to format.
+func prepareOverlay(path, rootfsPath string) (replaced bool, err error) {
+	base, err := rootfsIdentity(rootfsPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to read rootfs image: %w", err)
+	}
+	if _, err := os.Stat(path); err == nil {
+		if recorded, _ := os.ReadFile(path + overlayBaseSuffix); string(recorded) == base {
+			return false, nil
+		}
+		if err := os.Remove(path); err != nil {
+			return false, fmt.Errorf("failed to remove outdated rootfs overlay: %w", err)
+		}
+		replaced = true
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return false, fmt.Errorf("failed to create overlays directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return false, fmt.Errorf("failed to create rootfs overlay: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	if err := f.Truncate(overlayDiskSize); err != nil {
+		_ = os.Remove(path)
+		return false, fmt.Errorf("failed to size rootfs overlay: %w", err)
+	}
+	if err := os.WriteFile(path+overlayBaseSuffix, []byte(base), 0600); err != nil {
+		_ = os.Remove(path)
+		return false, fmt.Errorf("failed to record rootfs overlay base: %w", err)
+	}
+	return replaced, nil
+}
+
+// overlayDevice returns the guest device of the overlay disk, which comes
+// after the rootfs and the scratch disk, if any. A rootfs snapshot, which
+// sessions can't combine with an overlay disk, takes its place.
+func overlayDevice(scratch bool) string {
+	if scratch {
+		return "/dev/vdc"
+	}
+	return "/dev/vdb"
+}
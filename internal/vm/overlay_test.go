@@ -0,0 +1,70 @@
+package vm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverlayPath(t *testing.T) {
+	a, err := OverlayPath("/home/user/code/app")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(filepath.Base(a), "app-"))
+	assert.Equal(t, ".img", filepath.Ext(a))
+	assert.Equal(t, "overlays", filepath.Base(filepath.Dir(a)))
+
+	again, err := OverlayPath("/home/user/code/app/")
+	require.NoError(t, err)
+	assert.Equal(t, a, again)
+
+	// Projects with the same name get their own overlays
+	other, err := OverlayPath("/home/user/forks/app")
+	require.NoError(t, err)
+	assert.NotEqual(t, a, other)
+}
+
+func TestPrepareOverlay(t *testing.T) {
+	dir := t.TempDir()
+	rootfs := filepath.Join(dir, "claude-rootfs.img")
+	require.NoError(t, os.WriteFile(rootfs, []byte("rootfs"), 0644))
+	path := filepath.Join(dir, "overlays", "app-0123456789ab.img")
+
+	replaced, err := prepareOverlay(path, rootfs)
+	require.NoError(t, err)
+	assert.False(t, replaced)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, int64(overlayDiskSize), info.Size())
+
+	// The guest's writes are kept while the rootfs stays the same
+	require.NoError(t, os.WriteFile(path, []byte("written"), 0600))
+	replaced, err = prepareOverlay(path, rootfs)
+	require.NoError(t, err)
+	assert.False(t, replaced)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "written", string(data))
+
+	// A rebuilt rootfs gets a fresh overlay
+	later := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(rootfs, later, later))
+	replaced, err = prepareOverlay(path, rootfs)
+	require.NoError(t, err)
+	assert.True(t, replaced)
+	info, err = os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, int64(overlayDiskSize), info.Size())
+
+	_, err = prepareOverlay(path, filepath.Join(dir, "missing.img"))
+	assert.Error(t, err)
+}
+
+func TestOverlayDevice(t *testing.T) {
+	assert.Equal(t, "/dev/vdb", overlayDevice(false))
+	assert.Equal(t, "/dev/vdc", overlayDevice(true))
+}
@@ -0,0 +1,320 @@
+//go:build darwin || linux
+
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/faize-ai/faize/internal/session"
+)
+
+// ownerStopTimeout is how long to wait for a detached session's owner process to exit
+const ownerStopTimeout = 15 * time.Second
+
+// ownerPauseTimeout is how long to wait for an owner to save a session's memory to disk
+const ownerPauseTimeout = 2 * time.Minute
+
+// pauseSignal asks a detached session's owner to suspend its VM
+const pauseSignal = syscall.SIGUSR1
+
+// Files in the session directory used by pause and resume
+const (
+	machineStateFile = "machine-state" // saved VM state of a paused session
+	pauseErrorFile   = "pause-error"   // why the owner failed to pause
+)
+
+// processAlive reports whether a process with the given PID exists
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// ownerHoldsLock reports whether a session's recorded PID is still its owner:
+// the PID may have been reused by an unrelated process once the owner exited,
+// so only a held owner lock makes it safe to signal
+func ownerHoldsLock(sessions *session.Store, sess *session.Session) bool {
+	if sess.PID == 0 || sess.PID == os.Getpid() || !processAlive(sess.PID) {
+		return false
+	}
+	alive, _ := sessions.OwnerAlive(sess.ID)
+	return alive
+}
+
+// stopOwner asks the background process that owns a detached session's VM to
+// shut it down and waits for it to exit. Returns false if there is no live owner
+// other than the current process.
+func stopOwner(sessions *session.Store, sess *session.Session) bool {
+	switch sess.Status {
+	case "created", "running", "paused":
+	default:
+		return false
+	}
+	if !ownerHoldsLock(sessions, sess) {
+		return false
+	}
+
+	debugLog("Signalling owner process %d of session %s", sess.PID, sess.ID)
+	if err := syscall.Kill(sess.PID, syscall.SIGTERM); err != nil {
+		debugLog("Failed to signal owner process: %v", err)
+		return false
+	}
+
+	deadline := time.Now().Add(ownerStopTimeout)
+	for time.Now().Before(deadline) {
+		if !processAlive(sess.PID) {
+			return true
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if !ownerHoldsLock(sessions, sess) {
+		return true
+	}
+	debugLog("Owner process %d did not exit, killing", sess.PID)
+	_ = syscall.Kill(sess.PID, syscall.SIGKILL)
+	return true
+}
+
+// stopUnowned stops a session whose VM is not held by this process.
+// Detached sessions are stopped through their owner; the status is then
+// marked stopped unless the owner already recorded it.
+func stopUnowned(sessions *session.Store, id string) error {
+	sess, err := sessions.Load(id)
+	if err != nil {
+		return fmt.Errorf("session not found: %s", id)
+	}
+
+	if stopOwner(sessions, sess) {
+		if reloaded, err := sessions.Load(id); err == nil {
+			sess = reloaded
+		}
+		if sess.Status == "stopped" {
+			return nil
+		}
+	}
+
+	if sess.Status == "paused" {
+		// The saved state can't be resumed once the session is stopped
+		_ = os.Remove(sessionFile(id, machineStateFile))
+	}
+	removeScratchDisk(id)
+	sess.Status = "stopped"
+	sess.PID = 0
+	return sessions.Save(sess)
+}
+
+// ReconcileSessions marks sessions stopped whose owner process is gone, e.g.
+// after a crash or host reboot, and removes their stale sockets and scratch
+// disks, so they aren't listed as running. Returns the sessions it stopped.
+func ReconcileSessions(sessions *session.Store) ([]*session.Session, error) {
+	active, err := sessions.ListByStatus("created", "running")
+	if err != nil {
+		return nil, err
+	}
+	var lost []*session.Session
+	for _, sess := range active {
+		if !ownerGone(sessions, sess) {
+			continue
+		}
+		stoppedAt := lastSeen(sess)
+		saved, err := sessions.Update(sess.ID, func(s *session.Session) bool {
+			// The owner may have recorded the stop itself meanwhile
+			if s.Status != "created" && s.Status != "running" {
+				return false
+			}
+			s.Status = "stopped"
+			s.StoppedAt = &stoppedAt
+			s.ExitReason = "lost"
+			s.PID = 0
+			s.RecordDetach(stoppedAt)
+			*sess = *s
+			return true
+		})
+		if errors.Is(err, session.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return lost, err
+		}
+		if !saved {
+			continue
+		}
+		_ = os.Remove(proxySocketPath(sess.ID))
+		_ = os.Remove(execSocketPath(sess.ID))
+		removeScratchDisk(sess.ID)
+		sessions.ClearOwner(sess.ID)
+		lost = append(lost, sess)
+	}
+	return lost, nil
+}
+
+// ownerGone reports whether the process that ran a session's VM has exited.
+// Sessions from versions of faize without owner locks are judged by their
+// recorded PID, and left alone without one.
+func ownerGone(sessions *session.Store, sess *session.Session) bool {
+	if alive, known := sessions.OwnerAlive(sess.ID); known {
+		return !alive
+	}
+	if sess.PID != 0 {
+		return !processAlive(sess.PID)
+	}
+	return false
+}
+
+// lastSeen estimates when a session whose owner is gone stopped: its last
+// heartbeat or console output, or now if there was neither
+func lastSeen(sess *session.Session) time.Time {
+	var last time.Time
+	for _, path := range []string{
+		filepath.Join(sessionFile(sess.ID, "bootstrap"), guest.HeartbeatFile),
+		sessionFile(sess.ID, "console.log"),
+	} {
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(last) {
+			last = info.ModTime()
+		}
+	}
+	if last.Before(sess.StartedAt) {
+		return time.Now()
+	}
+	return last
+}
+
+// NotifyPause relays pause requests for sessions owned by this process to c
+func NotifyPause(c chan<- os.Signal) {
+	signal.Notify(c, pauseSignal)
+}
+
+// Pause asks the owner of a detached session to save the VM to disk and exit,
+// and waits until the session is recorded as paused.
+func Pause(sessions *session.Store, id string) error {
+	sess, err := sessions.Load(id)
+	if err != nil {
+		return fmt.Errorf("session not found: %s", id)
+	}
+	if sess.Status != "running" {
+		return fmt.Errorf("session %s is %s, not running", id, sess.Status)
+	}
+	if !sess.Detached || sess.PID == 0 {
+		return fmt.Errorf("session %s is running in the foreground; only detached sessions (faize start --detach) can be paused", id)
+	}
+	if !ownerHoldsLock(sessions, sess) {
+		return fmt.Errorf("session %s has no running owner process", id)
+	}
+
+	errPath := sessionFile(id, pauseErrorFile)
+	_ = os.Remove(errPath)
+	debugLog("Signalling owner process %d of session %s to pause", sess.PID, id)
+	if err := syscall.Kill(sess.PID, pauseSignal); err != nil {
+		return fmt.Errorf("failed to signal session owner: %w", err)
+	}
+
+	deadline := time.Now().Add(ownerPauseTimeout)
+	for time.Now().Before(deadline) {
+		if data, err := os.ReadFile(errPath); err == nil {
+			_ = os.Remove(errPath)
+			return fmt.Errorf("failed to pause session %s: %s", id, strings.TrimSpace(string(data)))
+		}
+		if !processAlive(sess.PID) {
+			if reloaded, err := sessions.Load(id); err == nil && reloaded.Status == "paused" {
+				return nil
+			}
+			return fmt.Errorf("session %s stopped instead of pausing", id)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fmt.Errorf("timed out waiting for session %s to pause", id)
+}
+
+// RecordPauseError reports a failed pause request back to the waiting Pause call
+func RecordPauseError(id string, pauseErr error) {
+	if err := os.WriteFile(sessionFile(id, pauseErrorFile), []byte(pauseErr.Error()+"\n"), 0600); err != nil {
+		debugLog("Failed to record pause error: %v", err)
+	}
+}
+
+// sessionFile returns the path of a file in a session's directory
+func sessionFile(id, name string) string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".faize", "sessions", id, name)
+}
+
+// removeScratchDisk deletes a session's scratch disk once it has stopped;
+// like the rootfs overlay, its contents don't outlive the session
+func removeScratchDisk(id string) {
+	_ = os.Remove(sessionFile(id, scratchDiskFile))
+}
+
+// IdleWarm returns the warm VMs that are booted, owned by a live process, and
+// not yet claimed, oldest first
+func IdleWarm(sessions *session.Store) ([]*session.Session, error) {
+	all, err := sessions.List()
+	if err != nil {
+		return nil, err
+	}
+	var idle []*session.Session
+	for _, sess := range all {
+		if !sess.Warm || sess.Status != "running" || !processAlive(sess.PID) {
+			continue
+		}
+		if _, err := os.Stat(sessionFile(sess.ID, warmClaimFile)); err == nil {
+			continue
+		}
+		idle = append(idle, sess)
+	}
+	sort.Slice(idle, func(i, j int) bool { return idle[i].StartedAt.Before(idle[j].StartedAt) })
+	return idle, nil
+}
+
+// ClaimWarm hands claim to the oldest idle warm VM booted with key, after
+// adding the claim's shares to it, and returns its session, now assigned to
+// the claim's project. Returns ErrNoWarmSession when none is available.
+func ClaimWarm(sessions *session.Store, key string, claim *WarmClaim) (*session.Session, error) {
+	idle, err := IdleWarm(sessions)
+	if err != nil {
+		return nil, err
+	}
+	for _, sess := range idle {
+		if sess.WarmKey != key {
+			continue
+		}
+		lock, err := os.OpenFile(sessionFile(sess.ID, warmClaimFile), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err != nil {
+			continue // taken by another start
+		}
+		_ = lock.Close()
+
+		if len(claim.Mounts) > 0 {
+			if err := attachWarmShares(sessionFile(sess.ID, ""), claim.Mounts); err != nil {
+				_ = stopUnowned(sessions, sess.ID)
+				return nil, err
+			}
+		}
+		bootstrapDir := sessionFile(sess.ID, "bootstrap")
+		writeTermSize(bootstrapDir)
+		if err := guest.WriteClaim(bootstrapDir, claim.Claim); err != nil {
+			_ = stopUnowned(sessions, sess.ID)
+			return nil, err
+		}
+
+		sess.Warm = false
+		sess.ProjectDir = claim.ProjectDir
+		sess.StartedAt = time.Now()
+		if err := sessions.Save(sess); err != nil {
+			return nil, fmt.Errorf("failed to save session: %w", err)
+		}
+		debugLog("Claimed warm session %s for %s", sess.ID, claim.ProjectDir)
+		return sess, nil
+	}
+	return nil, ErrNoWarmSession
+}
@@ -0,0 +1,214 @@
+//go:build darwin || linux
+
+package vm
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStopUnowned(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	store, err := session.NewStore()
+	require.NoError(t, err)
+
+	t.Run("marks session stopped when owner is gone", func(t *testing.T) {
+		require.NoError(t, store.Save(&session.Session{ID: "abc123", Status: "running", PID: 999999999}))
+		require.NoError(t, stopUnowned(store, "abc123"))
+
+		sess, err := store.Load("abc123")
+		require.NoError(t, err)
+		assert.Equal(t, "stopped", sess.Status)
+		assert.Zero(t, sess.PID)
+	})
+
+	t.Run("does not signal a reused PID", func(t *testing.T) {
+		// A live process that doesn't hold the owner lock isn't the owner
+		other := exec.Command("sleep", "30")
+		require.NoError(t, other.Start())
+		defer func() { _ = other.Process.Kill(); _ = other.Wait() }()
+
+		sess := &session.Session{ID: "bcd234", Status: "running", PID: other.Process.Pid}
+		require.NoError(t, store.Save(sess))
+		assert.False(t, stopOwner(store, sess))
+		assert.True(t, processAlive(other.Process.Pid))
+	})
+
+	t.Run("leaves stopped sessions' PIDs alone", func(t *testing.T) {
+		other := exec.Command("sleep", "30")
+		require.NoError(t, other.Start())
+		defer func() { _ = other.Process.Kill(); _ = other.Wait() }()
+
+		owner, err := store.LockOwner("cde345")
+		require.NoError(t, err)
+		defer owner.Release()
+		assert.False(t, stopOwner(store, &session.Session{ID: "cde345", Status: "stopped", PID: other.Process.Pid}))
+		assert.True(t, processAlive(other.Process.Pid))
+	})
+
+	t.Run("does not signal the current process", func(t *testing.T) {
+		require.NoError(t, store.Save(&session.Session{ID: "def456", Status: "running", PID: os.Getpid()}))
+		require.NoError(t, stopUnowned(store, "def456"))
+
+		sess, err := store.Load("def456")
+		require.NoError(t, err)
+		assert.Equal(t, "stopped", sess.Status)
+	})
+
+	t.Run("discards the saved state of a paused session", func(t *testing.T) {
+		require.NoError(t, store.Save(&session.Session{ID: "aaa111", Status: "paused"}))
+		statePath := sessionFile("aaa111", machineStateFile)
+		require.NoError(t, os.MkdirAll(filepath.Dir(statePath), 0755))
+		require.NoError(t, os.WriteFile(statePath, []byte("state"), 0600))
+
+		require.NoError(t, stopUnowned(store, "aaa111"))
+
+		sess, err := store.Load("aaa111")
+		require.NoError(t, err)
+		assert.Equal(t, "stopped", sess.Status)
+		assert.NoFileExists(t, statePath)
+	})
+
+	t.Run("unknown session", func(t *testing.T) {
+		assert.Error(t, stopUnowned(store, "ffffff"))
+	})
+}
+
+func TestPause(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	store, err := session.NewStore()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		sess    *session.Session
+		wantErr string
+	}{
+		{"not running", &session.Session{ID: "abc123", Status: "stopped"}, "not running"},
+		{"foreground session", &session.Session{ID: "bcd234", Status: "running"}, "only detached sessions"},
+		{"owner gone", &session.Session{ID: "cde345", Status: "running", Detached: true, PID: 999999999}, "no running owner"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, store.Save(tt.sess))
+			err := Pause(store, tt.sess.ID)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+
+	t.Run("unknown session", func(t *testing.T) {
+		assert.Error(t, Pause(store, "ffffff"))
+	})
+}
+
+func TestClaimWarm(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	store, err := session.NewStore()
+	require.NoError(t, err)
+
+	now := time.Now()
+	for _, sess := range []*session.Session{
+		{ID: "aaa001", Status: "running", PID: os.Getpid(), Warm: true, WarmKey: "k1", StartedAt: now.Add(-time.Minute)},
+		{ID: "aaa002", Status: "running", PID: os.Getpid(), Warm: true, WarmKey: "k1", StartedAt: now},
+		{ID: "bbb001", Status: "running", PID: os.Getpid(), Warm: true, WarmKey: "k2", StartedAt: now},
+		{ID: "ddd001", Status: "running", PID: 999999999, Warm: true, WarmKey: "k1", StartedAt: now.Add(-time.Hour)},
+		{ID: "ccc001", Status: "running", PID: os.Getpid(), StartedAt: now.Add(-time.Hour)},
+	} {
+		require.NoError(t, store.Save(sess))
+		require.NoError(t, os.MkdirAll(sessionFile(sess.ID, "bootstrap"), 0755))
+	}
+
+	idle, err := IdleWarm(store)
+	require.NoError(t, err)
+	require.Len(t, idle, 3)
+	assert.Equal(t, "aaa001", idle[0].ID, "oldest first")
+
+	claim := &WarmClaim{Claim: &guest.Claim{ProjectDir: "/p", Binds: []guest.Bind{{Source: "/mnt/warm/p", Target: "/p"}}}}
+	sess, err := ClaimWarm(store, "k1", claim)
+	require.NoError(t, err)
+	assert.Equal(t, "aaa001", sess.ID)
+	assert.False(t, sess.Warm)
+	assert.Equal(t, "/p", sess.ProjectDir)
+
+	saved, err := guest.ReadClaim(filepath.Join(sessionFile("aaa001", "bootstrap"), guest.ClaimFile))
+	require.NoError(t, err)
+	assert.Equal(t, claim.Claim, saved)
+
+	sess, err = ClaimWarm(store, "k1", claim)
+	require.NoError(t, err)
+	assert.Equal(t, "aaa002", sess.ID, "a claimed VM is not handed out twice")
+
+	_, err = ClaimWarm(store, "k1", claim)
+	assert.ErrorIs(t, err, ErrNoWarmSession)
+}
+
+func TestReconcileSessions(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	store, err := session.NewStore()
+	require.NoError(t, err)
+	started := time.Now().Add(-time.Hour)
+
+	// Owner crashed: the lock file is left unheld
+	crashed, err := store.LockOwner("aaa111")
+	require.NoError(t, err)
+	require.NoError(t, store.Save(&session.Session{ID: "aaa111", Status: "running", PID: 999999999, StartedAt: started}))
+	crashed.Release()
+	require.NoError(t, os.WriteFile(filepath.Join(store.Dir(), "aaa111.lock"), nil, 0600))
+
+	// Owner alive
+	live, err := store.LockOwner("bbb222")
+	require.NoError(t, err)
+	defer live.Release()
+	require.NoError(t, store.Save(&session.Session{ID: "bbb222", Status: "running", StartedAt: started}))
+
+	// Started by an older faize: judged by PID
+	require.NoError(t, store.Save(&session.Session{ID: "ccc333", Status: "running", Detached: true, PID: 999999999, StartedAt: started}))
+	require.NoError(t, store.Save(&session.Session{ID: "ddd444", Status: "running", Detached: true, PID: os.Getpid(), StartedAt: started}))
+	// Nothing to judge by, or not expected to have an owner
+	require.NoError(t, store.Save(&session.Session{ID: "eee555", Status: "running", StartedAt: started}))
+	require.NoError(t, store.Save(&session.Session{ID: "fff666", Status: "paused", StartedAt: started}))
+
+	heartbeat := filepath.Join(sessionFile("aaa111", "bootstrap"), guest.HeartbeatFile)
+	require.NoError(t, os.MkdirAll(filepath.Dir(heartbeat), 0755))
+	require.NoError(t, os.WriteFile(heartbeat, nil, 0644))
+	lastBeat := started.Add(10 * time.Minute).Truncate(time.Second)
+	require.NoError(t, os.Chtimes(heartbeat, lastBeat, lastBeat))
+
+	lost, err := ReconcileSessions(store)
+	require.NoError(t, err)
+	var ids []string
+	for _, sess := range lost {
+		ids = append(ids, sess.ID)
+	}
+	assert.Subset(t, ids, []string{"aaa111", "ccc333"})
+	for _, id := range []string{"bbb222", "ddd444", "eee555", "fff666"} {
+		assert.NotContains(t, ids, id)
+	}
+
+	sess, err := store.Load("aaa111")
+	require.NoError(t, err)
+	assert.Equal(t, "stopped", sess.Status)
+	assert.Equal(t, "lost", sess.ExitReason)
+	require.NotNil(t, sess.StoppedAt)
+	assert.True(t, lastBeat.Equal(*sess.StoppedAt), "stopped at the last heartbeat")
+	assert.Zero(t, sess.PID)
+	assert.NoFileExists(t, filepath.Join(store.Dir(), "aaa111.lock"))
+
+	for _, id := range []string{"bbb222", "ddd444", "eee555"} {
+		sess, err := store.Load(id)
+		require.NoError(t, err)
+		assert.Equal(t, "running", sess.Status, id)
+	}
+	sess, err = store.Load("fff666")
+	require.NoError(t, err)
+	assert.Equal(t, "paused", sess.Status)
+}
@@ -0,0 +1,176 @@
+package vm
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/faize-ai/faize/internal/session"
+)
+
+// publishHost is the host address published ports listen on. Ports are only
+// reachable from the host itself, never from the network.
+const publishHost = "127.0.0.1"
+
+// guestDialTimeout bounds how long a forwarded connection waits for the guest
+const guestDialTimeout = 5 * time.Second
+
+// ParsePublish parses --publish specs of the form HOST:GUEST or PORT (same port
+// on both sides). Host ports must be unique.
+func ParsePublish(specs []string) ([]session.PortForward, error) {
+	var forwards []session.PortForward
+	seen := make(map[int]bool)
+	for _, spec := range specs {
+		hostPart, guestPart, found := strings.Cut(spec, ":")
+		if !found {
+			guestPart = hostPart
+		}
+		hostPort, err := parsePort(hostPart)
+		if err != nil {
+			return nil, fmt.Errorf("invalid publish spec '%s': %w", spec, err)
+		}
+		guestPort, err := parsePort(guestPart)
+		if err != nil {
+			return nil, fmt.Errorf("invalid publish spec '%s': %w", spec, err)
+		}
+		if seen[hostPort] {
+			return nil, fmt.Errorf("host port %d is published more than once", hostPort)
+		}
+		seen[hostPort] = true
+		forwards = append(forwards, session.PortForward{HostPort: hostPort, GuestPort: guestPort})
+	}
+	return forwards, nil
+}
+
+// parsePort parses a TCP port number
+func parsePort(s string) (int, error) {
+	port, err := strconv.Atoi(s)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("port must be a number between 1 and 65535, got '%s'", s)
+	}
+	return port, nil
+}
+
+// HostPortFree reports whether port can be published on the host
+func HostPortFree(port int) bool {
+	return checkHostPorts([]session.PortForward{{HostPort: port}}) == nil
+}
+
+// checkHostPorts verifies that every published host port is free, so a
+// conflict fails the session before the VM boots
+func checkHostPorts(forwards []session.PortForward) error {
+	for _, f := range forwards {
+		ln, err := net.Listen("tcp", net.JoinHostPort(publishHost, strconv.Itoa(f.HostPort)))
+		if err != nil {
+			return fmt.Errorf("cannot publish port %d: already in use on the host", f.HostPort)
+		}
+		_ = ln.Close()
+	}
+	return nil
+}
+
+// guestIPReader returns a function that reads the guest's IP address from the
+// file the guest agent writes to the bootstrap share after DHCP
+func guestIPReader(bootstrapDir string) func() (string, error) {
+	return func() (string, error) {
+		data, err := os.ReadFile(filepath.Join(bootstrapDir, guest.GuestIPFile))
+		if err != nil {
+			return "", fmt.Errorf("guest network not ready")
+		}
+		ip := strings.TrimSpace(string(data))
+		if net.ParseIP(ip) == nil {
+			return "", fmt.Errorf("invalid guest IP %q", ip)
+		}
+		return ip, nil
+	}
+}
+
+// PortForwarder relays connections from host loopback ports to the guest's
+// address on the NAT network
+type PortForwarder struct {
+	listeners []net.Listener
+	guestIP   func() (string, error)
+	done      chan struct{}
+	once      sync.Once
+}
+
+// StartPortForwarder listens on every published host port. The guest IP is
+// resolved per connection, since the guest only learns it after DHCP.
+func StartPortForwarder(forwards []session.PortForward, guestIP func() (string, error)) (*PortForwarder, error) {
+	f := &PortForwarder{guestIP: guestIP, done: make(chan struct{})}
+	for _, fwd := range forwards {
+		ln, err := net.Listen("tcp", net.JoinHostPort(publishHost, strconv.Itoa(fwd.HostPort)))
+		if err != nil {
+			f.Stop()
+			return nil, fmt.Errorf("cannot publish port %d: %w", fwd.HostPort, err)
+		}
+		f.listeners = append(f.listeners, ln)
+		go f.acceptLoop(ln, fwd)
+		debugLog("Publishing %s:%d -> guest:%d", publishHost, fwd.HostPort, fwd.GuestPort)
+	}
+	return f, nil
+}
+
+// acceptLoop accepts connections on one published port
+func (f *PortForwarder) acceptLoop(ln net.Listener, fwd session.PortForward) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			select {
+			case <-f.done:
+				return
+			default:
+				debugLog("Port forward accept error: %v", err)
+				continue
+			}
+		}
+		go f.relay(conn, fwd.GuestPort)
+	}
+}
+
+// relay copies data between a host client and the guest port
+func (f *PortForwarder) relay(client net.Conn, guestPort int) {
+	defer func() { _ = client.Close() }()
+
+	ip, err := f.guestIP()
+	if err != nil {
+		debugLog("Port forward: %v", err)
+		return
+	}
+	upstream, err := net.DialTimeout("tcp", net.JoinHostPort(ip, strconv.Itoa(guestPort)), guestDialTimeout)
+	if err != nil {
+		debugLog("Port forward dial error: %v", err)
+		return
+	}
+	defer func() { _ = upstream.Close() }()
+
+	go func() {
+		_, _ = io.Copy(upstream, client)
+		closeWrite(upstream)
+	}()
+	_, _ = io.Copy(client, upstream)
+}
+
+// closeWrite half-closes a TCP connection so the peer sees EOF
+func closeWrite(conn net.Conn) {
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		_ = tcp.CloseWrite()
+	}
+}
+
+// Stop closes all published ports
+func (f *PortForwarder) Stop() {
+	f.once.Do(func() {
+		close(f.done)
+		for _, ln := range f.listeners {
+			_ = ln.Close()
+		}
+	})
+}
@@ -0,0 +1,122 @@
+package vm
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePublish(t *testing.T) {
+	t.Run("host and guest ports", func(t *testing.T) {
+		forwards, err := ParsePublish([]string{"3000:3000", "8080:80"})
+		require.NoError(t, err)
+		assert.Equal(t, []session.PortForward{{HostPort: 3000, GuestPort: 3000}, {HostPort: 8080, GuestPort: 80}}, forwards)
+	})
+
+	t.Run("single port publishes the same port", func(t *testing.T) {
+		forwards, err := ParsePublish([]string{"5173"})
+		require.NoError(t, err)
+		assert.Equal(t, []session.PortForward{{HostPort: 5173, GuestPort: 5173}}, forwards)
+	})
+
+	t.Run("rejects invalid ports", func(t *testing.T) {
+		for _, spec := range []string{"abc", "0:80", "80:70000", "80:", ":80", "1:2:3"} {
+			_, err := ParsePublish([]string{spec})
+			assert.Error(t, err, spec)
+		}
+	})
+
+	t.Run("rejects duplicate host ports", func(t *testing.T) {
+		_, err := ParsePublish([]string{"3000:3000", "3000:4000"})
+		assert.ErrorContains(t, err, "more than once")
+	})
+}
+
+// freePort returns a loopback port that is currently unused
+func freePort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := ln.Addr().(*net.TCPAddr).Port
+	require.NoError(t, ln.Close())
+	return port
+}
+
+func TestCheckHostPorts(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = ln.Close() }()
+	busy := ln.Addr().(*net.TCPAddr).Port
+
+	assert.NoError(t, checkHostPorts([]session.PortForward{{HostPort: freePort(t), GuestPort: 80}}))
+	assert.ErrorContains(t, checkHostPorts([]session.PortForward{{HostPort: busy, GuestPort: 80}}), "already in use")
+}
+
+func TestPortForwarderRelays(t *testing.T) {
+	// Stand-in for a dev server inside the guest
+	upstream, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = upstream.Close() }()
+	go func() {
+		for {
+			conn, err := upstream.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() { _ = conn.Close() }()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	hostPort := freePort(t)
+	guestPort := upstream.Addr().(*net.TCPAddr).Port
+	fwd, err := StartPortForwarder([]session.PortForward{{HostPort: hostPort, GuestPort: guestPort}},
+		func() (string, error) { return "127.0.0.1", nil })
+	require.NoError(t, err)
+	defer fwd.Stop()
+
+	conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(hostPort)))
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err)
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "ping", string(buf))
+}
+
+func TestPortForwarderStopReleasesPorts(t *testing.T) {
+	hostPort := freePort(t)
+	fwd, err := StartPortForwarder([]session.PortForward{{HostPort: hostPort, GuestPort: 80}},
+		func() (string, error) { return "127.0.0.1", nil })
+	require.NoError(t, err)
+	fwd.Stop()
+	fwd.Stop() // idempotent
+
+	assert.NoError(t, checkHostPorts([]session.PortForward{{HostPort: hostPort, GuestPort: 80}}))
+}
+
+func TestGuestIPReader(t *testing.T) {
+	dir := t.TempDir()
+	read := guestIPReader(dir)
+
+	_, err := read()
+	assert.ErrorContains(t, err, "not ready")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, guest.GuestIPFile), []byte("192.168.64.5\n"), 0644))
+	ip, err := read()
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.64.5", ip)
+}
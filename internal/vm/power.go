@@ -0,0 +1,22 @@
+package vm
+
+import "fmt"
+
+// Sleep prevention modes (power.prevent_sleep). On macOS the VM manager holds a
+// power assertion that stops the host from idle sleeping, which would otherwise
+// suspend long sessions.
+const (
+	PreventSleepAttached = "attached" // while a terminal is attached to the console
+	PreventSleepAlways   = "always"   // while the VM runs, attached or not
+	PreventSleepNever    = "never"
+)
+
+// ValidatePreventSleep checks a power.prevent_sleep mode
+func ValidatePreventSleep(mode string) error {
+	switch mode {
+	case PreventSleepAttached, PreventSleepAlways, PreventSleepNever:
+		return nil
+	}
+	return fmt.Errorf("invalid prevent_sleep mode %q (want %s, %s, or %s)",
+		mode, PreventSleepAttached, PreventSleepAlways, PreventSleepNever)
+}
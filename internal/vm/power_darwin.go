@@ -0,0 +1,38 @@
+//go:build darwin
+
+package vm
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// powerAssertion keeps the Mac from idle sleeping while held. It is backed by
+// caffeinate(8) waiting on this process, so the assertion is also dropped if
+// faize dies without releasing it.
+type powerAssertion struct {
+	cmd *exec.Cmd
+}
+
+// acquirePowerAssertion prevents idle sleep until release. Failing only loses
+// the guarantee, so it is logged and nil is returned.
+func acquirePowerAssertion() *powerAssertion {
+	cmd := exec.Command("/usr/bin/caffeinate", "-i", "-w", strconv.Itoa(os.Getpid()))
+	if err := cmd.Start(); err != nil {
+		debugLog("Failed to take power assertion: %v", err)
+		return nil
+	}
+	debugLog("Holding power assertion (caffeinate pid %d)", cmd.Process.Pid)
+	return &powerAssertion{cmd: cmd}
+}
+
+// release drops the assertion; it is a no-op on nil
+func (p *powerAssertion) release() {
+	if p == nil {
+		return
+	}
+	_ = p.cmd.Process.Kill()
+	_ = p.cmd.Wait()
+	debugLog("Released power assertion")
+}
@@ -0,0 +1,16 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidatePreventSleep(t *testing.T) {
+	for _, mode := range []string{"attached", "always", "never"} {
+		assert.NoError(t, ValidatePreventSleep(mode), mode)
+	}
+	for _, mode := range []string{"", "Always", "sometimes"} {
+		assert.Error(t, ValidatePreventSleep(mode), mode)
+	}
+}
@@ -0,0 +1,825 @@
+//go:build linux
+
+package vm
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/faize-ai/faize/internal/artifacts"
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/faize-ai/faize/internal/session"
+)
+
+// qemuStopTimeout is how long Stop waits for QEMU to exit after SIGTERM before killing it
+const qemuStopTimeout = 5 * time.Second
+
+// virtiofsdCandidates are the paths virtiofsd is commonly installed at by distributions
+var virtiofsdCandidates = []string{
+	"virtiofsd",
+	"/usr/libexec/virtiofsd",
+	"/usr/lib/qemu/virtiofsd",
+	"/usr/lib/virtiofsd",
+}
+
+// qemuInstance tracks the host processes backing one session
+type qemuInstance struct {
+	cmd        *exec.Cmd
+	virtiofsd  []*exec.Cmd
+	mounts     []session.VMMount
+	sessionDir string
+	guestRead  *os.File // console pipe ends handed to QEMU's stdio chardev
+	guestWrite *os.File
+	vsockCID   uint32        // guest context ID for the exec channel; 0 if vsock is unavailable
+	done       chan struct{} // closed when QEMU exits
+	owner      *session.OwnerLock
+}
+
+// QEMUManager implements Manager using QEMU with KVM acceleration on Linux hosts.
+// Each VirtioFS share is served by its own virtiofsd process over vhost-user.
+type QEMUManager struct {
+	sessions  *session.Store
+	artifacts *artifacts.Manager
+	vms       map[string]*qemuInstance
+	consoles  map[string]*Console
+	proxies   map[string]*ConsoleProxyServer
+	execs     map[string]*ExecProxyServer
+	egress    map[string]*egressProxy
+	mu        sync.RWMutex
+}
+
+// NewQEMUManager creates a new QEMU/KVM-based VM manager
+func NewQEMUManager() (*QEMUManager, error) {
+	store, err := session.NewStore()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session store: %w", err)
+	}
+
+	artifactMgr, err := artifacts.NewManager()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create artifact manager: %w", err)
+	}
+
+	return &QEMUManager{
+		sessions:  store,
+		artifacts: artifactMgr,
+		vms:       make(map[string]*qemuInstance),
+		consoles:  make(map[string]*Console),
+		proxies:   make(map[string]*ConsoleProxyServer),
+		execs:     make(map[string]*ExecProxyServer),
+		egress:    make(map[string]*egressProxy),
+	}, nil
+}
+
+// NewManager returns the VM backend for this platform
+func NewManager() (Manager, error) {
+	m, err := NewQEMUManager()
+	if err != nil {
+		return nil, err
+	}
+	// Claude rootfs builds this manager needs run in builder VMs
+	if runner, err := NewBuildRunner(m, m.artifacts, os.Stdout); err == nil {
+		m.artifacts.SetBuildRunner(runner)
+	}
+	return m, nil
+}
+
+// buildVMAvailable reports why this host can't boot builder VMs, if it can't
+func buildVMAvailable() error {
+	if err := checkKVM(); err != nil {
+		return err
+	}
+	if _, err := qemuBinary(); err != nil {
+		return err
+	}
+	_, err := virtiofsdBinary()
+	return err
+}
+
+// checkKVM verifies that /dev/kvm exists and is accessible to the current user
+func checkKVM() error {
+	f, err := os.OpenFile("/dev/kvm", os.O_RDWR, 0)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("/dev/kvm not found: enable virtualization in firmware and load the kvm module")
+		}
+		if os.IsPermission(err) {
+			return fmt.Errorf("cannot access /dev/kvm: add your user to the 'kvm' group")
+		}
+		return fmt.Errorf("cannot open /dev/kvm: %w", err)
+	}
+	return f.Close()
+}
+
+// qemuBinary returns the QEMU system emulator for the host architecture
+func qemuBinary() (string, error) {
+	name := "qemu-system-x86_64"
+	if runtime.GOARCH == "arm64" {
+		name = "qemu-system-aarch64"
+	}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", fmt.Errorf("%s not found in PATH (install qemu)", name)
+	}
+	return path, nil
+}
+
+// virtiofsdBinary locates the virtiofsd daemon
+func virtiofsdBinary() (string, error) {
+	for _, candidate := range virtiofsdCandidates {
+		if path, err := exec.LookPath(candidate); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("virtiofsd not found (install virtiofsd)")
+}
+
+// qemuMachineType returns the machine type for the host architecture
+func qemuMachineType() string {
+	if runtime.GOARCH == "arm64" {
+		return "virt"
+	}
+	return "q35"
+}
+
+// vsockAvailable reports whether the host can give guests a vsock device
+func vsockAvailable() bool {
+	f, err := os.OpenFile("/dev/vhost-vsock", os.O_RDWR, 0)
+	if err != nil {
+		return false
+	}
+	_ = f.Close()
+	return true
+}
+
+// consoleAttached reports whether a client is attached to a session's console
+func (m *QEMUManager) consoleAttached(id string) func() bool {
+	return func() bool {
+		m.mu.RLock()
+		proxy := m.proxies[id]
+		m.mu.RUnlock()
+		return proxy != nil && proxy.Attached()
+	}
+}
+
+// Warn shows a warning to the user of a session this process owns
+func (m *QEMUManager) Warn(id, message string) bool {
+	m.mu.RLock()
+	_, owned := m.vms[id]
+	proxy := m.proxies[id]
+	m.mu.RUnlock()
+	if !owned {
+		return false
+	}
+	warnSession(m.sessions, proxy, id, message)
+	return true
+}
+
+// egressAvailable reports whether guests can reach a host egress proxy,
+// which needs the vsock device
+func egressAvailable() bool {
+	return vsockAvailable()
+}
+
+// vsockCID derives a guest context ID from the session ID. CIDs 0-2 are
+// reserved; the 12 hex digit session ID keeps collisions between concurrent
+// sessions unlikely.
+func vsockCID(id string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(id))
+	return 3 + h.Sum32()%(1<<31-3)
+}
+
+// virtiofsSocketPath returns the vhost-user socket path for the i-th share of a session
+func virtiofsSocketPath(sessionDir string, i int) string {
+	return filepath.Join(sessionDir, fmt.Sprintf("virtiofs-%d.sock", i))
+}
+
+// warmHotplug is set where a claimed warm VM is given its mounts as new
+// shares: QEMU hotplugs them onto PCIe root ports the VM booted with
+const warmHotplug = true
+
+// warmPortID names the root port a warm VM's i-th claimed share is plugged into
+func warmPortID(i int) string {
+	return fmt.Sprintf("warmport%d", i)
+}
+
+// warmShareSocketPath returns the vhost-user socket path for the i-th share
+// added to a claimed warm VM
+func warmShareSocketPath(sessionDir string, i int) string {
+	return filepath.Join(sessionDir, fmt.Sprintf("warm-virtiofs-%d.sock", i))
+}
+
+// qemuNetdev returns the user-mode network backend. QEMU's slirp NAT is not
+// reachable from the host, so published ports use its built-in hostfwd.
+func qemuNetdev(publish []session.PortForward) string {
+	netdev := "user,id=net0"
+	for _, p := range publish {
+		netdev += fmt.Sprintf(",hostfwd=tcp:%s:%d-:%d", publishHost, p.HostPort, p.GuestPort)
+	}
+	return netdev
+}
+
+// buildQEMUArgs assembles the QEMU command line for a session
+func buildQEMUArgs(cfg *Config, kernelPath, rootfsPath, sessionDir string, mounts []session.VMMount, forwards []session.PortForward, cid uint32) []string {
+	cmdLine := "console=hvc0 root=/dev/vda ro rootwait init=/init"
+	if cfg.Overlay != "" {
+		cmdLine += " faize.overlay=" + overlayDevice(cfg.Disk != "")
+	}
+	if cfg.Snapshot != "" {
+		cmdLine += " faize.snapshot=" + overlayDevice(cfg.Disk != "")
+	}
+	if os.Getenv("FAIZE_DEBUG") != "1" {
+		cmdLine += " quiet loglevel=0"
+	}
+
+	// The VM is sized to the maximums faize resize can grow the session to;
+	// VirtioFS requires guest memory shared with virtiofsd
+	cpus, memory := vmSize(cfg.CPUs, cfg.MaxCPUs, cfg.Memory, cfg.MaxMemory)
+	args := []string{
+		"-machine", qemuMachineType() + ",accel=kvm",
+		"-cpu", "host",
+		"-smp", fmt.Sprintf("%d", cpus),
+		"-m", fmt.Sprintf("%dM", parseMemory(memory)/(1024*1024)),
+		"-object", fmt.Sprintf("memory-backend-memfd,id=mem,size=%dM,share=on", parseMemory(memory)/(1024*1024)),
+		"-numa", "node,memdev=mem",
+		"-nographic",
+		"-nodefaults",
+		"-no-reboot",
+		"-kernel", kernelPath,
+		"-append", cmdLine,
+		"-drive", fmt.Sprintf("file=%s,if=virtio,readonly=on,format=raw", rootfsPath),
+		"-netdev", qemuNetdev(forwards),
+		"-device", "virtio-net-pci,netdev=net0",
+		"-device", "virtio-rng-pci",
+		"-device", "virtio-balloon-pci",
+		"-qmp", fmt.Sprintf("unix:%s,server=on,wait=off", qmpSocketPath(sessionDir)),
+		"-chardev", "stdio,id=con0,signal=off",
+		"-device", "virtio-serial-pci",
+		"-device", "virtconsole,chardev=con0",
+	}
+
+	if cfg.Disk != "" {
+		// Second virtio-blk device, /dev/vdb in the guest
+		args = append(args, "-drive", fmt.Sprintf("file=%s,if=virtio,format=raw", scratchDiskPath(sessionDir)))
+	}
+	if cfg.Overlay != "" {
+		// The project's persistent rootfs overlay comes after it
+		args = append(args, "-drive", fmt.Sprintf("file=%s,if=virtio,format=raw", cfg.Overlay))
+	}
+	if cfg.Snapshot != "" {
+		// Or the rootfs snapshot the session starts from, which the guest's
+		// init extracts into its overlay
+		args = append(args, "-drive", fmt.Sprintf("file=%s,if=virtio,readonly=on,format=raw", cfg.Snapshot))
+	}
+
+	if cid != 0 {
+		args = append(args, "-device", fmt.Sprintf("vhost-vsock-pci,guest-cid=%d", cid))
+	}
+
+	// Empty root ports for the shares a warm VM's claim adds
+	if cfg.Warm {
+		for i := range warmShareSlots {
+			args = append(args, "-device", fmt.Sprintf("pcie-root-port,id=%s,chassis=%d", warmPortID(i), i+1))
+		}
+	}
+
+	for i, mount := range mounts {
+		args = append(args,
+			"-chardev", fmt.Sprintf("socket,id=fs%d,path=%s", i, virtiofsSocketPath(sessionDir, i)),
+			"-device", fmt.Sprintf("vhost-user-fs-pci,chardev=fs%d,tag=%s", i, mount.Tag),
+		)
+	}
+
+	return args
+}
+
+// Create creates a new VM session
+func (m *QEMUManager) Create(cfg *Config) (*session.Session, error) {
+	// Enforce session quotas before allocating anything
+	if err := enforceLimits(m, cfg); err != nil {
+		return nil, err
+	}
+	if err := checkHostPorts(cfg.Publish); err != nil {
+		return nil, err
+	}
+
+	if err := checkKVM(); err != nil {
+		return nil, err
+	}
+	qemuPath, err := qemuBinary()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := virtiofsdBinary(); err != nil {
+		return nil, err
+	}
+
+	// Prepare artifacts, bootstrap directory and VirtioFS share list
+	bs, err := prepareBootstrap(m.artifacts, cfg)
+	if err != nil {
+		return nil, err
+	}
+	id := bs.id
+	sessionDir := m.artifacts.SessionDir(id)
+
+	kernelPath, rootfsPath := bootImages(m.artifacts, cfg.Kernel, cfg.Rootfs, cfg.Flavor, cfg.ClaudeMode)
+	debugLog("Kernel path: %s", kernelPath)
+	debugLog("Rootfs path: %s", rootfsPath)
+
+	// Configure console: QEMU's stdio chardev is wired to the guest pipe ends
+	debugLog("Configuring serial console...")
+	console, guestRead, guestWrite, err := newConsolePipes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create console: %w", err)
+	}
+
+	// vsock control channel for faize exec (optional: needs the vhost_vsock module)
+	var cid uint32
+	if vsockAvailable() {
+		cid = vsockCID(id)
+	} else {
+		debugLog("/dev/vhost-vsock not available: faize exec disabled for this session")
+	}
+
+	forwards := sessionForwards(&session.Session{Ports: cfg.Publish, SSHPort: bs.sshPort})
+	args := buildQEMUArgs(cfg, kernelPath, rootfsPath, sessionDir, bs.allMounts, forwards, cid)
+	debugLog("QEMU command: %s %s", qemuPath, strings.Join(args, " "))
+
+	cmd := exec.Command(qemuPath, args...)
+	cmd.Stdin = guestRead
+	cmd.Stdout = guestWrite
+	cmd.SysProcAttr = &syscall.SysProcAttr{Pdeathsig: syscall.SIGKILL}
+
+	maxCPUs, maxMemory := vmSize(cfg.CPUs, cfg.MaxCPUs, cfg.Memory, cfg.MaxMemory)
+	sess := &session.Session{
+		ID:           id,
+		Name:         cfg.Name,
+		Labels:       cfg.Labels,
+		ProjectDir:   cfg.ProjectDir,
+		Mounts:       cfg.Mounts,
+		SystemMounts: bs.systemMounts,
+		Network:      cfg.Network,
+		CPUs:         cfg.CPUs,
+		Memory:       cfg.Memory,
+		MaxCPUs:      maxCPUs,
+		MaxMemory:    maxMemory,
+		Disk:         cfg.Disk,
+		Overlay:      cfg.Overlay,
+		Home:         cfg.Home,
+		Snapshot:     cfg.Snapshot,
+		Kernel:       cfg.Kernel,
+		Rootfs:       cfg.Rootfs,
+		Image:        cfg.Image,
+		Flavor:       cfg.Flavor,
+		Status:       "created",
+		StartedAt:    time.Now(),
+		ClaudeMode:   cfg.ClaudeMode,
+
+		CaptureNetwork: cfg.CaptureNetwork,
+		Ports:          cfg.Publish,
+		NetworkPrompt:  cfg.NetworkPrompt,
+		Notify:         cfg.Notify,
+		NetLimit:       cfg.NetLimit,
+		Resolvers:      cfg.Resolvers,
+		Hosts:          cfg.Hosts,
+		Proxy:          cfg.Proxy,
+		NoProxy:        cfg.NoProxy,
+		SSHPort:        bs.sshPort,
+	}
+
+	// Mark this process as the session's owner, so a crash is noticed
+	owner, err := m.sessions.LockOwner(id)
+	if err != nil {
+		debugLog("Failed to lock session owner: %v", err)
+	}
+
+	// Store VM and console
+	m.mu.Lock()
+	m.vms[id] = &qemuInstance{
+		cmd:        cmd,
+		mounts:     bs.allMounts,
+		sessionDir: sessionDir,
+		guestRead:  guestRead,
+		guestWrite: guestWrite,
+		vsockCID:   cid,
+		done:       make(chan struct{}),
+		owner:      owner,
+	}
+	m.consoles[id] = console
+
+	// Create and start console proxy server
+	proxy, err := NewConsoleProxyServer(id, console)
+	if err != nil {
+		debugLog("Failed to create console proxy: %v", err)
+	} else {
+		if err := proxy.Start(); err != nil {
+			debugLog("Failed to start console proxy: %v", err)
+		} else {
+			m.proxies[id] = proxy
+			debugLog("Console proxy started at %s", proxy.SocketPath())
+		}
+	}
+
+	m.mu.Unlock()
+
+	// Persist session
+	if err := m.sessions.Save(sess); err != nil {
+		return nil, fmt.Errorf("failed to save session: %w", err)
+	}
+
+	return sess, nil
+}
+
+// startVirtiofsd launches one virtiofsd per share and waits for their sockets to appear
+func startVirtiofsd(inst *qemuInstance) error {
+	binary, err := virtiofsdBinary()
+	if err != nil {
+		return err
+	}
+
+	for i, mount := range inst.mounts {
+		cmd, err := launchVirtiofsd(binary, virtiofsSocketPath(inst.sessionDir, i), mount,
+			&syscall.SysProcAttr{Pdeathsig: syscall.SIGKILL})
+		if cmd != nil {
+			inst.virtiofsd = append(inst.virtiofsd, cmd)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// virtiofsdSandboxes are the sandboxes virtiofsd is tried with, in order:
+// its default namespace sandbox, which unprivileged needs user namespaces,
+// then, as root, a chroot. virtiofsd serves an untrusted guest, so it never
+// runs without one.
+func virtiofsdSandboxes() []string {
+	if os.Geteuid() == 0 {
+		return []string{"namespace", "chroot"}
+	}
+	return []string{"namespace"}
+}
+
+// launchVirtiofsd starts a sandboxed virtiofsd serving mount on socketPath
+// and waits for the socket to appear. The returned command is set whenever
+// the process was started and is still running.
+func launchVirtiofsd(binary, socketPath string, mount session.VMMount, attr *syscall.SysProcAttr) (*exec.Cmd, error) {
+	var failures []string
+	for _, sandbox := range virtiofsdSandboxes() {
+		cmd, exited, err := runVirtiofsd(binary, socketPath, mount, attr, sandbox)
+		if !exited {
+			return cmd, err
+		}
+		debugLog("virtiofsd for %s failed with --sandbox %s: %v", mount.Source, sandbox, err)
+		failures = append(failures, fmt.Sprintf("--sandbox %s: %v", sandbox, err))
+	}
+	return nil, fmt.Errorf("virtiofsd for %s could not start sandboxed (unprivileged user namespaces may be disabled, e.g. by kernel.unprivileged_userns_clone or an AppArmor restriction): %s",
+		mount.Source, strings.Join(failures, "; "))
+}
+
+// runVirtiofsd starts virtiofsd with one sandbox and waits for its socket.
+// exited reports that it exited before creating the socket, in which case
+// the command has been waited for and err includes its output.
+func runVirtiofsd(binary, socketPath string, mount session.VMMount, attr *syscall.SysProcAttr, sandbox string) (cmd *exec.Cmd, exited bool, err error) {
+	_ = os.Remove(socketPath)
+
+	args := []string{
+		"--socket-path", socketPath,
+		"--shared-dir", mount.Source,
+		"--sandbox", sandbox,
+		"--cache", "auto",
+	}
+	if mount.ReadOnly {
+		args = append(args, "--readonly")
+	}
+
+	// Output goes to an unlinked file rather than a pipe, which a daemon
+	// outliving this process would get SIGPIPE on
+	stderr, err := os.CreateTemp("", "faize-virtiofsd-*.log")
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to start virtiofsd for %s: %w", mount.Source, err)
+	}
+	_ = os.Remove(stderr.Name())
+	defer func() { _ = stderr.Close() }()
+
+	cmd = exec.Command(binary, args...)
+	cmd.SysProcAttr = attr
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		return nil, false, fmt.Errorf("failed to start virtiofsd for %s: %w", mount.Source, err)
+	}
+
+	for range 100 {
+		if _, err := os.Stat(socketPath); err == nil {
+			debugLog("virtiofsd ready for %s (tag %s, sandbox %s)", mount.Source, mount.Tag, sandbox)
+			return cmd, false, nil
+		}
+		if processZombie(cmd.Process.Pid) {
+			waitErr := cmd.Wait()
+			_, _ = stderr.Seek(0, io.SeekStart)
+			output, _ := io.ReadAll(stderr)
+			return nil, true, fmt.Errorf("%v: %s", waitErr, strings.TrimSpace(string(output)))
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return cmd, false, fmt.Errorf("virtiofsd for %s did not create socket %s", mount.Source, socketPath)
+}
+
+// processZombie reports whether a child process has exited and awaits
+// reaping, without reaping it
+func processZombie(pid int) bool {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
+	}
+	// The state follows the command name, which is in parentheses
+	i := bytes.LastIndexByte(data, ')')
+	return i >= 0 && i+2 < len(data) && data[i+2] == 'Z'
+}
+
+// attachWarmShares adds a claimed warm VM's mounts to it: each is served by
+// its own virtiofsd and hotplugged through QMP onto one of the VM's spare
+// root ports. The daemons outlive this process and exit with QEMU.
+func attachWarmShares(sessionDir string, mounts []session.VMMount) error {
+	binary, err := virtiofsdBinary()
+	if err != nil {
+		return err
+	}
+	var started []*exec.Cmd
+	fail := func(err error) error {
+		for _, cmd := range started {
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+		}
+		return err
+	}
+
+	monitor := qmpSocketPath(sessionDir)
+	for i, mount := range mounts {
+		socketPath := warmShareSocketPath(sessionDir, i)
+		cmd, err := launchVirtiofsd(binary, socketPath, mount, &syscall.SysProcAttr{Setsid: true})
+		if cmd != nil {
+			started = append(started, cmd)
+		}
+		if err != nil {
+			return fail(err)
+		}
+
+		id := fmt.Sprintf("warmfs%d", i)
+		if err := qmpExecute(monitor, "chardev-add", map[string]any{
+			"id": id,
+			"backend": map[string]any{
+				"type": "socket",
+				"data": map[string]any{
+					"addr":   map[string]any{"type": "unix", "data": map[string]any{"path": socketPath}},
+					"server": false,
+				},
+			},
+		}); err != nil {
+			return fail(fmt.Errorf("failed to add share for %s: %w", mount.Source, err))
+		}
+		if err := qmpExecute(monitor, "device_add", map[string]any{
+			"driver":  "vhost-user-fs-pci",
+			"id":      id,
+			"chardev": id,
+			"tag":     mount.Tag,
+			"bus":     warmPortID(i),
+		}); err != nil {
+			return fail(fmt.Errorf("failed to add share for %s: %w", mount.Source, err))
+		}
+		debugLog("Added share %s for %s", mount.Tag, mount.Source)
+	}
+	for _, cmd := range started {
+		_ = cmd.Process.Release()
+	}
+	return nil
+}
+
+// stopVirtiofsd kills all virtiofsd processes for an instance
+func stopVirtiofsd(inst *qemuInstance) {
+	for i, cmd := range inst.virtiofsd {
+		if cmd.Process != nil {
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+		}
+		_ = os.Remove(virtiofsSocketPath(inst.sessionDir, i))
+	}
+	inst.virtiofsd = nil
+	// Shares added to a claimed warm VM were started by the start that
+	// claimed it, and exit once QEMU is gone
+	for i := range warmShareSlots {
+		_ = os.Remove(warmShareSocketPath(inst.sessionDir, i))
+	}
+}
+
+// Start boots the VM
+func (m *QEMUManager) Start(sess *session.Session) error {
+	debugLog("Starting VM for session %s...", sess.ID)
+
+	m.mu.RLock()
+	inst, ok := m.vms[sess.ID]
+	m.mu.RUnlock()
+
+	if !ok {
+		debugLog("VM not found in map")
+		return fmt.Errorf("VM not found: %s", sess.ID)
+	}
+
+	if err := validateArtifacts(m.artifacts, sess); err != nil {
+		return err
+	}
+
+	if err := startVirtiofsd(inst); err != nil {
+		stopVirtiofsd(inst)
+		return err
+	}
+
+	// QEMU diagnostics go to a log file so they don't corrupt the console
+	logFile, err := os.Create(filepath.Join(inst.sessionDir, "qemu.log"))
+	if err != nil {
+		stopVirtiofsd(inst)
+		return fmt.Errorf("failed to create QEMU log: %w", err)
+	}
+	inst.cmd.Stderr = logFile
+
+	debugLog("Starting QEMU...")
+	if err := inst.cmd.Start(); err != nil {
+		_ = logFile.Close()
+		stopVirtiofsd(inst)
+		return fmt.Errorf("failed to start VM: %w", err)
+	}
+	debugLog("QEMU started (pid %d)", inst.cmd.Process.Pid)
+
+	// QEMU holds its own copies of the guest pipe ends
+	_ = inst.guestRead.Close()
+	_ = inst.guestWrite.Close()
+
+	// Reap QEMU and auto-detach the console when it exits
+	go func() {
+		err := inst.cmd.Wait()
+		debugLog("QEMU exited: %v", err)
+		_ = logFile.Close()
+		stopVirtiofsd(inst)
+		close(inst.done)
+
+		m.mu.RLock()
+		console := m.consoles[sess.ID]
+		m.mu.RUnlock()
+		if console != nil {
+			debugLog("Auto-detaching console due to VM exit")
+			_ = console.Detach()
+		}
+	}()
+
+	// Keep the guest watchdog fed and track peak usage while this process owns the VM
+	alive := func() bool {
+		select {
+		case <-inst.done:
+			return false
+		default:
+			return true
+		}
+	}
+	go runHeartbeat(bootstrapPath(inst.sessionDir), alive)
+	go runUsageSampler(m.sessions, sess.ID, bootstrapPath(inst.sessionDir), alive)
+
+	// Reclaim memory the guest isn't using while it is idle
+	_, size := sessionSize(sess)
+	go runBalloon(bootstrapPath(inst.sessionDir), sessionMemory(m.sessions, sess.ID, parseMemory(size)), alive, func(target uint64) error {
+		return qmpExecute(qmpSocketPath(inst.sessionDir), "balloon", map[string]uint64{"value": target})
+	})
+
+	// Forward faize exec clients to the guest agent over vsock
+	if inst.vsockCID != 0 {
+		if proxy := startExecProxy(sess.ID, func() (io.ReadWriteCloser, error) {
+			return guest.DialVsock(inst.vsockCID, guest.ExecPort)
+		}); proxy != nil {
+			m.mu.Lock()
+			m.execs[sess.ID] = proxy
+			m.mu.Unlock()
+		}
+
+		// Enforce the allowlist for traffic the guest sends to the egress proxy
+		proxy, err := startEgressProxy(m.sessions, sess, inst.sessionDir, m.consoleAttached(sess.ID), func(port uint32) (net.Listener, error) {
+			return listenVsock(port, inst.vsockCID)
+		})
+		if err != nil {
+			debugLog("Failed to start egress proxy: %v", err)
+			m.Warn(sess.ID, fmt.Sprintf("egress proxy not started, so the session has no network access: %v", err))
+		} else if proxy != nil {
+			m.mu.Lock()
+			m.egress[sess.ID] = proxy
+			m.mu.Unlock()
+		}
+	}
+
+	// Update session status
+	sess.Status = "running"
+	if err := m.sessions.Save(sess); err != nil {
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+
+	return nil
+}
+
+// Stop stops a running VM
+func (m *QEMUManager) Stop(id string) error {
+	m.mu.Lock()
+	inst, ok := m.vms[id]
+	if !ok {
+		m.mu.Unlock()
+		// Not owned by this process: stop via the detached owner, if any
+		return stopUnowned(m.sessions, id)
+	}
+
+	delete(m.vms, id)
+	delete(m.consoles, id)
+
+	// Stop and remove proxy
+	if proxy, ok := m.proxies[id]; ok {
+		_ = proxy.Stop()
+		delete(m.proxies, id)
+	}
+	if proxy, ok := m.execs[id]; ok {
+		_ = proxy.Stop()
+		delete(m.execs, id)
+	}
+	if proxy, ok := m.egress[id]; ok {
+		proxy.Stop()
+		delete(m.egress, id)
+	}
+
+	m.mu.Unlock()
+
+	if inst.cmd.Process != nil {
+		select {
+		case <-inst.done:
+			// VM already stopped
+		default:
+			_ = inst.cmd.Process.Signal(syscall.SIGTERM)
+			select {
+			case <-inst.done:
+			case <-time.After(qemuStopTimeout):
+				debugLog("QEMU did not exit after SIGTERM, killing")
+				_ = inst.cmd.Process.Kill()
+				<-inst.done
+			}
+		}
+	} else {
+		// Never started: release the pipe ends QEMU would have owned
+		_ = inst.guestRead.Close()
+		_ = inst.guestWrite.Close()
+	}
+
+	// Update session status
+	sess, err := m.sessions.Load(id)
+	if err == nil {
+		sess.Status = "stopped"
+		sess.PID = 0
+		if saveErr := m.sessions.Save(sess); saveErr != nil {
+			debugLog("Failed to save session state: %v", saveErr)
+		}
+	}
+	removeScratchDisk(id)
+	inst.owner.Release()
+
+	return nil
+}
+
+// List returns all sessions
+func (m *QEMUManager) List() ([]*session.Session, error) {
+	return m.sessions.List()
+}
+
+// Attach connects to the VM console via the session's proxy socket
+func (m *QEMUManager) Attach(id string) error {
+	return attachConsole(m.sessions, m.artifacts.SessionDir(id), proxySocketPath(id), id)
+}
+
+// WaitForVMStop blocks until the VM stops or an error occurs
+func (m *QEMUManager) WaitForVMStop(id string) <-chan struct{} {
+	m.mu.RLock()
+	inst, ok := m.vms[id]
+	m.mu.RUnlock()
+
+	if !ok {
+		done := make(chan struct{})
+		close(done)
+		return done
+	}
+	return inst.done
+}
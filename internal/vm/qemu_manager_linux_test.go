@@ -0,0 +1,64 @@
+//go:build linux
+
+package vm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQEMUNetdev(t *testing.T) {
+	assert.Equal(t, "user,id=net0", qemuNetdev(nil))
+	assert.Equal(t,
+		"user,id=net0,hostfwd=tcp:127.0.0.1:3000-:3000,hostfwd=tcp:127.0.0.1:8080-:80",
+		qemuNetdev([]session.PortForward{{HostPort: 3000, GuestPort: 3000}, {HostPort: 8080, GuestPort: 80}}))
+}
+
+func TestVsockCID(t *testing.T) {
+	cid := vsockCID("abcdef123456")
+	assert.GreaterOrEqual(t, cid, uint32(3), "CIDs 0-2 are reserved")
+	assert.Equal(t, cid, vsockCID("abcdef123456"), "CID must be stable per session")
+	assert.NotEqual(t, cid, vsockCID("123456abcdef"))
+}
+
+func TestBuildQEMUArgsWarmPorts(t *testing.T) {
+	cfg := &Config{CPUs: 2, Memory: "2GB"}
+	args := strings.Join(buildQEMUArgs(cfg, "/k", "/r", "/s", nil, nil, 0), " ")
+	assert.NotContains(t, args, "pcie-root-port")
+
+	// Warm VMs boot with a spare port per share a claim can add
+	cfg.Warm = true
+	args = strings.Join(buildQEMUArgs(cfg, "/k", "/r", "/s", nil, nil, 0), " ")
+	assert.Equal(t, warmShareSlots, strings.Count(args, "pcie-root-port,id=warmport"))
+	assert.Contains(t, args, "pcie-root-port,id=warmport0,chassis=1")
+}
+
+func TestLaunchVirtiofsdSandboxed(t *testing.T) {
+	dir := t.TempDir()
+	mount := session.VMMount{Source: dir, Tag: "mount0"}
+
+	// A virtiofsd that records its arguments and creates its socket
+	args := filepath.Join(dir, "args")
+	binary := filepath.Join(dir, "virtiofsd")
+	require.NoError(t, os.WriteFile(binary, []byte("#!/bin/sh\necho \"$@\" > "+args+"\ntouch \"$2\"\nexec sleep 60\n"), 0755))
+	cmd, err := launchVirtiofsd(binary, filepath.Join(dir, "ok.sock"), mount, nil)
+	require.NoError(t, err)
+	_ = cmd.Process.Kill()
+	_ = cmd.Wait()
+	data, err := os.ReadFile(args)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "--sandbox namespace")
+
+	// One that can't sandbox is an error, never a run without a sandbox
+	require.NoError(t, os.WriteFile(binary, []byte("#!/bin/sh\necho \"no user namespaces\" >&2\nexit 1\n"), 0755))
+	cmd, err = launchVirtiofsd(binary, filepath.Join(dir, "fail.sock"), mount, nil)
+	assert.Nil(t, cmd)
+	assert.ErrorContains(t, err, "could not start sandboxed")
+	assert.ErrorContains(t, err, "no user namespaces")
+}
@@ -0,0 +1,77 @@
+//go:build linux
+
+package vm
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"path/filepath"
+	"time"
+)
+
+// qmpTimeout bounds one exchange with QEMU's monitor
+const qmpTimeout = 5 * time.Second
+
+// qmpSocketPath is where QEMU serves its QMP monitor for a session
+func qmpSocketPath(sessionDir string) string {
+	return filepath.Join(sessionDir, "qmp.sock")
+}
+
+// qmpExecute runs one QMP command on the monitor at socketPath
+func qmpExecute(socketPath, command string, arguments any) error {
+	conn, err := net.DialTimeout("unix", socketPath, qmpTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to QEMU monitor: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(qmpTimeout))
+
+	r := bufio.NewReader(conn)
+	enc := json.NewEncoder(conn)
+	// The greeting comes first, then commands are only accepted once
+	// capabilities are negotiated
+	if _, err := r.ReadBytes('\n'); err != nil {
+		return fmt.Errorf("failed to read QEMU monitor greeting: %w", err)
+	}
+	for _, req := range []map[string]any{
+		{"execute": "qmp_capabilities"},
+		{"execute": command, "arguments": arguments},
+	} {
+		if err := enc.Encode(req); err != nil {
+			return fmt.Errorf("failed to send QMP command: %w", err)
+		}
+		if err := qmpResponse(r); err != nil {
+			return fmt.Errorf("QMP %s failed: %w", req["execute"], err)
+		}
+	}
+	return nil
+}
+
+// qmpResponse reads the reply to a command, skipping asynchronous events
+func qmpResponse(r *bufio.Reader) error {
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			return err
+		}
+		var resp struct {
+			Event  string          `json:"event"`
+			Return json.RawMessage `json:"return"`
+			Error  *struct {
+				Desc string `json:"desc"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(line, &resp); err != nil {
+			return fmt.Errorf("invalid reply: %w", err)
+		}
+		switch {
+		case resp.Event != "":
+			continue
+		case resp.Error != nil:
+			return fmt.Errorf("%s", resp.Error.Desc)
+		}
+		return nil
+	}
+}
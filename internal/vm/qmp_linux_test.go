@@ -0,0 +1,62 @@
+//go:build linux
+
+package vm
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQMPExecute(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "qmp.sock")
+	l, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+	defer func() { _ = l.Close() }()
+
+	// A fake monitor that records commands, sends an event before each
+	// reply, and rejects "fail"
+	received := make(chan string, 8)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			_, _ = fmt.Fprintln(conn, `{"QMP": {"version": {}, "capabilities": []}}`)
+			r := bufio.NewReader(conn)
+			for {
+				line, err := r.ReadBytes('\n')
+				if err != nil {
+					break
+				}
+				var req map[string]any
+				_ = json.Unmarshal(line, &req)
+				received <- string(line)
+				_, _ = fmt.Fprintln(conn, `{"event": "BALLOON_CHANGE", "data": {"actual": 1}}`)
+				if req["execute"] == "fail" {
+					_, _ = fmt.Fprintln(conn, `{"error": {"class": "GenericError", "desc": "no balloon"}}`)
+				} else {
+					_, _ = fmt.Fprintln(conn, `{"return": {}}`)
+				}
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	require.NoError(t, qmpExecute(socketPath, "balloon", map[string]uint64{"value": 1 << 30}))
+	assert.JSONEq(t, `{"execute": "qmp_capabilities"}`, <-received)
+	assert.JSONEq(t, `{"execute": "balloon", "arguments": {"value": 1073741824}}`, <-received)
+
+	err = qmpExecute(socketPath, "fail", nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no balloon")
+
+	assert.Error(t, qmpExecute(filepath.Join(t.TempDir(), "missing.sock"), "balloon", nil))
+}
@@ -0,0 +1,105 @@
+package vm
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/faize-ai/faize/internal/session"
+)
+
+// vmSize returns the CPUs and memory a VM is created with: a session's
+// resources, or the larger maximums faize resize can grow it up to
+func vmSize(cpus, maxCPUs int, memory, maxMemory string) (int, string) {
+	if maxMemory == "" || parseMemory(maxMemory) < parseMemory(memory) {
+		maxMemory = memory
+	}
+	return max(cpus, maxCPUs), maxMemory
+}
+
+// sessionSize returns the size of a session's VM
+func sessionSize(sess *session.Session) (int, string) {
+	return vmSize(sess.CPUs, sess.MaxCPUs, sess.Memory, sess.MaxMemory)
+}
+
+// sessionMemory returns a function reporting how much memory a session may
+// currently use, for its balloon. Resizing changes it while the VM runs.
+func sessionMemory(sessions *session.Store, id string, size uint64) func() uint64 {
+	return func() uint64 {
+		sess, err := sessions.Load(id)
+		if err != nil {
+			return size
+		}
+		return min(parseMemory(sess.Memory), size)
+	}
+}
+
+// Resize changes how many CPUs and how much memory a running session may use,
+// within the size of its VM: neither Virtualization.framework nor a restored
+// save can change a VM's size. The guest agent takes CPUs offline or brings
+// them back, and the owner's memory balloon applies the memory within
+// seconds. Zero cpus or empty memory leave that resource as it is;
+// maxTotalMemory is the limit on the memory of all running sessions.
+func Resize(store *session.Store, id string, cpus int, memory, maxTotalMemory string) (*session.Session, error) {
+	if memory != "" {
+		size, err := ParseMemory(memory)
+		if err != nil {
+			return nil, err
+		}
+		if size < balloonMinimum {
+			return nil, fmt.Errorf("memory must be at least %s", FormatMemory(balloonMinimum))
+		}
+		memory = FormatMemory(size)
+	}
+	if cpus < 0 {
+		return nil, fmt.Errorf("CPUs must be at least 1")
+	}
+	sessions, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	var others uint64
+	for _, s := range runningSessions(sessions) {
+		if s.ID != id {
+			others += parseMemory(s.Memory)
+		}
+	}
+
+	var resized *session.Session
+	var resizeErr error
+	_, err = store.Update(id, func(s *session.Session) bool {
+		maxCPUs, maxMemory := sessionSize(s)
+		switch {
+		case s.Status != "running":
+			resizeErr = fmt.Errorf("session %s is not running (status: %s)", id, s.Status)
+		case cpus > maxCPUs:
+			resizeErr = fmt.Errorf("session %s's VM has %d CPUs; start sessions with a larger resources.max_cpus to grow beyond that", id, maxCPUs)
+		case memory != "" && parseMemory(memory) > parseMemory(maxMemory):
+			resizeErr = fmt.Errorf("session %s's VM has %s of memory; start sessions with a larger resources.max_memory to grow beyond that", id, maxMemory)
+		case memory != "" && maxTotalMemory != "" && others+parseMemory(memory) > parseMemory(maxTotalMemory):
+			resizeErr = fmt.Errorf("%w (max_total_memory is %s)", ErrLimitExceeded, maxTotalMemory)
+		default:
+			if cpus > 0 {
+				s.CPUs = cpus
+			}
+			if memory != "" {
+				s.Memory = memory
+			}
+			resized = s
+			return true
+		}
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resizeErr != nil {
+		return nil, resizeErr
+	}
+	if cpus > 0 {
+		if err := guest.WriteCPUs(bootstrapPath(filepath.Join(store.Dir(), id)), cpus); err != nil {
+			return nil, err
+		}
+	}
+	return resized, nil
+}
@@ -0,0 +1,78 @@
+package vm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVMSize(t *testing.T) {
+	cpus, memory := vmSize(2, 0, "4GB", "")
+	assert.Equal(t, 2, cpus)
+	assert.Equal(t, "4GB", memory)
+	cpus, memory = vmSize(2, 8, "4GB", "16GB")
+	assert.Equal(t, 8, cpus)
+	assert.Equal(t, "16GB", memory)
+	// Maximums below the session's resources don't shrink the VM
+	cpus, memory = vmSize(4, 2, "8GB", "2GB")
+	assert.Equal(t, 4, cpus)
+	assert.Equal(t, "8GB", memory)
+}
+
+func TestResize(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	stubHostMemory(t, 64<<30)
+	store, err := session.NewStore()
+	require.NoError(t, err)
+	bootstrapDir := filepath.Join(store.Dir(), "abc901", "bootstrap")
+	require.NoError(t, os.MkdirAll(bootstrapDir, 0755))
+	require.NoError(t, store.Save(&session.Session{
+		ID: "abc901", Status: "running", CPUs: 2, Memory: "4GB", MaxCPUs: 8, MaxMemory: "16GB",
+	}))
+
+	sess, err := Resize(store, "abc901", 4, "8GB", "")
+	require.NoError(t, err)
+	assert.Equal(t, 4, sess.CPUs)
+	assert.Equal(t, "8GB", sess.Memory)
+	assert.Equal(t, 4, guest.ReadCPUs(bootstrapDir))
+	saved, err := store.Load("abc901")
+	require.NoError(t, err)
+	assert.Equal(t, "8GB", saved.Memory)
+
+	// Leaving CPUs out keeps them
+	sess, err = Resize(store, "abc901", 0, "6GB", "")
+	require.NoError(t, err)
+	assert.Equal(t, 4, sess.CPUs)
+	assert.Equal(t, "6GB", sess.Memory)
+
+	for _, tc := range []struct {
+		cpus   int
+		memory string
+		limit  string
+		want   string
+	}{
+		{cpus: 16, want: "has 8 CPUs"},
+		{memory: "32GB", want: "has 16GB of memory"},
+		{memory: "512MB", want: "at least 1GB"},
+		{memory: "128GB", want: "larger than the host's memory"},
+		{memory: "lots", want: "invalid memory"},
+		{memory: "8GB", limit: "2GB", want: "max_total_memory is 2GB"},
+	} {
+		_, err := Resize(store, "abc901", tc.cpus, tc.memory, tc.limit)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), tc.want)
+	}
+	saved, err = store.Load("abc901")
+	require.NoError(t, err)
+	assert.Equal(t, 4, saved.CPUs)
+	assert.Equal(t, "6GB", saved.Memory)
+
+	require.NoError(t, store.Save(&session.Session{ID: "abc902", Status: "stopped", CPUs: 2, Memory: "4GB"}))
+	_, err = Resize(store, "abc902", 1, "", "")
+	assert.ErrorContains(t, err, "not running")
+}
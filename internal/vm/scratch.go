@@ -0,0 +1,51 @@
+package vm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/faize-ai/faize/internal/changeset"
+)
+
+// scratchDiskFile is the sparse raw image of a session's scratch disk
+// (faize start --disk), in the session directory
+const scratchDiskFile = "scratch.img"
+
+// scratchDiskMinimum is the smallest scratch disk worth formatting
+const scratchDiskMinimum = 256 << 20
+
+// ParseDisk parses a scratch disk size such as "20GB" or "512MB"
+func ParseDisk(size string) (uint64, error) {
+	n, err := changeset.ParseSize(size)
+	if err != nil {
+		return 0, err
+	}
+	if n < scratchDiskMinimum {
+		return 0, fmt.Errorf("disk %s is smaller than the minimum of %s", size, FormatMemory(scratchDiskMinimum))
+	}
+	return uint64(n), nil
+}
+
+// scratchDiskPath returns where a session's scratch disk image is kept
+func scratchDiskPath(sessionDir string) string {
+	return filepath.Join(sessionDir, scratchDiskFile)
+}
+
+// createScratchDisk creates a sparse scratch disk image of size bytes. It is
+// left unformatted; the guest agent formats it on first boot.
+func createScratchDisk(sessionDir, size string) error {
+	n, err := ParseDisk(size)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(scratchDiskPath(sessionDir), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create scratch disk: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	if err := f.Truncate(int64(n)); err != nil {
+		return fmt.Errorf("failed to size scratch disk: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package vm
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDisk(t *testing.T) {
+	size, err := ParseDisk("20GB")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(20<<30), size)
+	size, err = ParseDisk("512MB")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(512<<20), size)
+
+	_, err = ParseDisk("100MB")
+	assert.ErrorContains(t, err, "smaller than the minimum of 256MB")
+	_, err = ParseDisk("lots")
+	assert.Error(t, err)
+}
+
+func TestCreateScratchDisk(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, createScratchDisk(dir, "20GB"))
+
+	info, err := os.Stat(scratchDiskPath(dir))
+	require.NoError(t, err)
+	assert.Equal(t, int64(20<<30), info.Size())
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// An existing disk is never replaced
+	assert.Error(t, createScratchDisk(dir, "1GB"))
+}
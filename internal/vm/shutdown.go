@@ -0,0 +1,67 @@
+//go:build darwin || linux
+
+package vm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/faize-ai/faize/internal/session"
+)
+
+// shutdownPoll is how often Shutdown checks whether the session has stopped
+const shutdownPoll = 250 * time.Millisecond
+
+// Shutdown asks a running session's guest agent to shut down the way it does
+// on SIGTERM: stop the session process, persist credentials, write
+// guest-changes.txt, and power off. It then waits up to timeout for the
+// session's owner to record the stop. An error means the guest did not shut
+// down in time and the VM should be stopped from the host.
+func Shutdown(sessions *session.Store, id string, timeout time.Duration) error {
+	sess, err := sessions.Load(id)
+	if err != nil {
+		return fmt.Errorf("session not found: %s", id)
+	}
+
+	// The agent is PID 1 and runs its cleanup before powering off on SIGTERM
+	var stderr bytes.Buffer
+	req := &guest.ExecRequest{Args: []string{"kill", "-TERM", "1"}, User: "root"}
+	code, err := Exec(id, req, io.Discard, &stderr)
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = fmt.Sprintf("kill exited with status %d", code)
+		}
+		return fmt.Errorf("failed to signal the guest agent: %s", msg)
+	}
+	debugLog("Requested guest shutdown of session %s", id)
+
+	deadline := time.Now().Add(timeout)
+	for {
+		if shutdownDone(sessions, sess) {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("session %s did not shut down within %s", id, timeout)
+		}
+		time.Sleep(shutdownPoll)
+	}
+}
+
+// shutdownDone reports whether a session's VM has stopped and its owner has
+// finished recording the session. A detached owner is done once it exits; a
+// foreground owner once the session is marked stopped.
+func shutdownDone(sessions *session.Store, sess *session.Session) bool {
+	if sess.PID != 0 && processAlive(sess.PID) {
+		return false
+	}
+	latest, err := sessions.Load(sess.ID)
+	return err != nil || latest.Status == "stopped"
+}
@@ -0,0 +1,54 @@
+//go:build darwin || linux
+
+package vm
+
+import (
+	"os"
+	"testing"
+
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShutdownDone(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	store, err := session.NewStore()
+	require.NoError(t, err)
+
+	t.Run("waits for a foreground session to be marked stopped", func(t *testing.T) {
+		sess := &session.Session{ID: "aaa001", Status: "running"}
+		require.NoError(t, store.Save(sess))
+		assert.False(t, shutdownDone(store, sess))
+
+		sess.Status = "stopped"
+		require.NoError(t, store.Save(sess))
+		assert.True(t, shutdownDone(store, sess))
+	})
+
+	t.Run("waits for a live owner to exit", func(t *testing.T) {
+		sess := &session.Session{ID: "aaa002", Status: "stopped", PID: os.Getpid()}
+		require.NoError(t, store.Save(sess))
+		assert.False(t, shutdownDone(store, sess))
+	})
+
+	t.Run("done once the owner is gone and the session stopped", func(t *testing.T) {
+		sess := &session.Session{ID: "aaa003", Status: "stopped", PID: 999999999}
+		require.NoError(t, store.Save(sess))
+		assert.True(t, shutdownDone(store, sess))
+	})
+
+	t.Run("done when the session was removed", func(t *testing.T) {
+		assert.True(t, shutdownDone(store, &session.Session{ID: "aaa004"}))
+	})
+}
+
+func TestShutdownWithoutExec(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	store, err := session.NewStore()
+	require.NoError(t, err)
+	require.NoError(t, store.Save(&session.Session{ID: "aaa005", Status: "running"}))
+
+	err = Shutdown(store, "aaa005", 0)
+	assert.ErrorContains(t, err, "exec is not available")
+}
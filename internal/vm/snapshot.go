@@ -0,0 +1,210 @@
+package vm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/faize-ai/faize/internal/session"
+)
+
+// snapshotName is what snapshot names may look like: they name files
+var snapshotName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,62}$`)
+
+// snapshotExclude are the paths of the overlay's writable layer left out of
+// snapshots: scratch space, mount points, and the Claude configuration and
+// credentials copied in at every boot
+var snapshotExclude = []string{"./tmp", "./run", "./mnt", "./old_root", "./home/claude/.claude", "./home/claude/.claude.json"}
+
+// RootfsSnapshot is a saved copy of a session's rootfs changes (faize
+// snapshot save), which new sessions can start from
+type RootfsSnapshot struct {
+	Name    string    `json:"name"`
+	Session string    `json:"session"`
+	Project string    `json:"project"`
+	Created time.Time `json:"created"`
+	Size    int64     `json:"size"`
+	Rootfs  string    `json:"rootfs"`           // the rootfs image it was taken on (see rootfsIdentity)
+	Flavor  string    `json:"flavor,omitempty"` // the flavor of that Claude rootfs (empty for Alpine)
+	Path    string    `json:"-"`                // the tar archive of the writable layer
+}
+
+// ValidateSnapshotName checks a snapshot name: letters, digits, '.', '_'
+// and '-', starting with a letter or digit
+func ValidateSnapshotName(name string) error {
+	if !snapshotName.MatchString(name) {
+		return fmt.Errorf("invalid snapshot name %q: use up to 63 letters, digits, '.', '_' and '-', starting with a letter or digit", name)
+	}
+	return nil
+}
+
+// snapshotsDir returns the directory snapshots are kept in
+func snapshotsDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".faize", "snapshots"), nil
+}
+
+// SaveSnapshot saves the rootfs changes of a running Claude session, such as
+// installed packages and configured tools, as the snapshot name. The guest
+// streams its overlay's writable layer as a tar archive over exec; rootfsPath
+// is the image the session runs, recorded to tell when it was rebuilt.
+func SaveSnapshot(sess *session.Session, name, rootfsPath string) (*RootfsSnapshot, error) {
+	if err := ValidateSnapshotName(name); err != nil {
+		return nil, err
+	}
+	if !sess.ClaudeMode {
+		return nil, fmt.Errorf("only Claude sessions can be snapshotted")
+	}
+	if sess.Status != "running" {
+		return nil, fmt.Errorf("session %s is not running (status: %s)", sess.ID, sess.Status)
+	}
+	dir, err := snapshotsDir()
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, name+".tar")
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("snapshot %s already exists; remove it first with faize snapshot rm", name)
+	}
+	base, err := rootfsIdentity(rootfsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rootfs image: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create snapshots directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+name+"-*.tar")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	args := []string{"tar", "-c", "-f", "-", "-C", guest.RootfsUpper}
+	for _, p := range snapshotExclude {
+		args = append(args, "--exclude="+p)
+	}
+	var stderr bytes.Buffer
+	code, err := Exec(sess.ID, &guest.ExecRequest{Args: append(args, "."), User: "root"}, tmp, &stderr)
+	if err == nil && code != 0 {
+		err = fmt.Errorf("tar exited with status %d: %s", code, strings.TrimSpace(stderr.String()))
+	}
+	if err != nil {
+		_ = tmp.Close()
+		return nil, fmt.Errorf("failed to save snapshot: %w", err)
+	}
+	// The archive is attached as a disk, which must be whole sectors
+	info, err := tmp.Stat()
+	if err == nil && info.Size()%512 != 0 {
+		err = tmp.Truncate(info.Size() + 512 - info.Size()%512)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to save snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return nil, fmt.Errorf("failed to save snapshot: %w", err)
+	}
+
+	snap := &RootfsSnapshot{
+		Name:    name,
+		Session: sess.ID,
+		Project: sess.ProjectDir,
+		Created: time.Now(),
+		Rootfs:  base,
+		Flavor:  sess.Flavor,
+		Path:    path,
+	}
+	if info, err := os.Stat(path); err == nil {
+		snap.Size = info.Size()
+	}
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err == nil {
+		err = os.WriteFile(filepath.Join(dir, name+".json"), data, 0600)
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return nil, fmt.Errorf("failed to record snapshot: %w", err)
+	}
+	return snap, nil
+}
+
+// LoadSnapshot returns the snapshot called name
+func LoadSnapshot(name string) (*RootfsSnapshot, error) {
+	if err := ValidateSnapshotName(name); err != nil {
+		return nil, err
+	}
+	dir, err := snapshotsDir()
+	if err != nil {
+		return nil, err
+	}
+	return readSnapshot(dir, name)
+}
+
+// readSnapshot reads the snapshot name in dir
+func readSnapshot(dir, name string) (*RootfsSnapshot, error) {
+	path := filepath.Join(dir, name+".tar")
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("snapshot %s not found", name)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, name+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot %s: %w", name, err)
+	}
+	var snap RootfsSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot %s: %w", name, err)
+	}
+	snap.Name, snap.Path = name, path
+	return &snap, nil
+}
+
+// ListSnapshots returns the saved snapshots, oldest first
+func ListSnapshots() ([]*RootfsSnapshot, error) {
+	dir, err := snapshotsDir()
+	if err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tar"))
+	if err != nil {
+		return nil, err
+	}
+	var snaps []*RootfsSnapshot
+	for _, p := range paths {
+		name := strings.TrimSuffix(filepath.Base(p), ".tar")
+		if snapshotName.MatchString(name) {
+			if snap, err := readSnapshot(dir, name); err == nil {
+				snaps = append(snaps, snap)
+			}
+		}
+	}
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].Created.Before(snaps[j].Created) })
+	return snaps, nil
+}
+
+// RemoveSnapshot deletes a snapshot
+func RemoveSnapshot(snap *RootfsSnapshot) error {
+	if err := os.Remove(snap.Path); err != nil {
+		return fmt.Errorf("failed to remove snapshot %s: %w", snap.Name, err)
+	}
+	_ = os.Remove(strings.TrimSuffix(snap.Path, ".tar") + ".json")
+	return nil
+}
+
+// Outdated reports whether the rootfs image at rootfsPath was rebuilt since
+// the snapshot was taken, so files it changed may hide the new image's
+func (s *RootfsSnapshot) Outdated(rootfsPath string) bool {
+	base, err := rootfsIdentity(rootfsPath)
+	return err == nil && base != s.Rootfs
+}
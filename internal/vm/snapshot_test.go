@@ -0,0 +1,97 @@
+package vm
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateSnapshotName(t *testing.T) {
+	for _, name := range []string{"node", "node-22.1", "py_tools"} {
+		assert.NoError(t, ValidateSnapshotName(name), name)
+	}
+	for _, name := range []string{"", ".hidden", "-x", "a/b", "../up", "with space"} {
+		assert.Error(t, ValidateSnapshotName(name), name)
+	}
+}
+
+// writeSnapshot saves a snapshot archive and its record as SaveSnapshot does
+func writeSnapshot(t *testing.T, snap RootfsSnapshot) {
+	t.Helper()
+	dir, err := snapshotsDir()
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(dir, 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, snap.Name+".tar"), make([]byte, 1024), 0600))
+	data, err := json.Marshal(snap)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, snap.Name+".json"), data, 0600))
+}
+
+func TestListLoadRemoveSnapshots(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	snaps, err := ListSnapshots()
+	require.NoError(t, err)
+	assert.Empty(t, snaps)
+
+	now := time.Now()
+	writeSnapshot(t, RootfsSnapshot{Name: "newer", Session: "bbb", Created: now})
+	writeSnapshot(t, RootfsSnapshot{Name: "older", Session: "aaa", Created: now.Add(-time.Hour)})
+
+	snaps, err = ListSnapshots()
+	require.NoError(t, err)
+	require.Len(t, snaps, 2)
+	assert.Equal(t, "older", snaps[0].Name)
+	assert.Equal(t, "newer", snaps[1].Name)
+
+	snap, err := LoadSnapshot("newer")
+	require.NoError(t, err)
+	assert.Equal(t, "bbb", snap.Session)
+	assert.FileExists(t, snap.Path)
+
+	_, err = LoadSnapshot("missing")
+	assert.ErrorContains(t, err, "not found")
+	_, err = LoadSnapshot("../newer")
+	assert.Error(t, err)
+
+	require.NoError(t, RemoveSnapshot(snap))
+	assert.NoFileExists(t, snap.Path)
+	snaps, err = ListSnapshots()
+	require.NoError(t, err)
+	assert.Len(t, snaps, 1)
+}
+
+func TestSaveSnapshot_Refuses(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	rootfs := filepath.Join(t.TempDir(), "claude-rootfs.img")
+	require.NoError(t, os.WriteFile(rootfs, []byte("rootfs"), 0644))
+
+	_, err := SaveSnapshot(&session.Session{ID: "abc", Status: "running"}, "tools", rootfs)
+	assert.ErrorContains(t, err, "only Claude sessions")
+	_, err = SaveSnapshot(&session.Session{ID: "abc", Status: "stopped", ClaudeMode: true}, "tools", rootfs)
+	assert.ErrorContains(t, err, "not running")
+	_, err = SaveSnapshot(&session.Session{ID: "abc", Status: "running", ClaudeMode: true}, "bad/name", rootfs)
+	assert.ErrorContains(t, err, "invalid snapshot name")
+
+	writeSnapshot(t, RootfsSnapshot{Name: "tools"})
+	_, err = SaveSnapshot(&session.Session{ID: "abc", Status: "running", ClaudeMode: true}, "tools", rootfs)
+	assert.ErrorContains(t, err, "already exists")
+}
+
+func TestSnapshotOutdated(t *testing.T) {
+	rootfs := filepath.Join(t.TempDir(), "claude-rootfs.img")
+	require.NoError(t, os.WriteFile(rootfs, []byte("rootfs"), 0644))
+	base, err := rootfsIdentity(rootfs)
+	require.NoError(t, err)
+
+	snap := &RootfsSnapshot{Rootfs: base}
+	assert.False(t, snap.Outdated(rootfs))
+
+	require.NoError(t, os.WriteFile(rootfs, []byte("rebuilt rootfs"), 0644))
+	assert.True(t, snap.Outdated(rootfs))
+}
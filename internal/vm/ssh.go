@@ -0,0 +1,157 @@
+package vm
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/faize-ai/faize/internal/session"
+)
+
+// sshGuestPort is the port sshd listens on inside the guest
+const sshGuestPort = 22
+
+// Host-side SSH files in the session's ssh directory. The client key and
+// known_hosts never enter the bootstrap share; the guest only gets its host key
+// and the authorized public key (guest.SSHDir).
+const (
+	sshClientKey  = "id_ed25519"
+	sshKnownHosts = "known_hosts"
+)
+
+// sessionForwards returns every guest port forwarded to host loopback for a
+// session: its published ports plus SSH
+func sessionForwards(sess *session.Session) []session.PortForward {
+	forwards := append([]session.PortForward{}, sess.Ports...)
+	if sess.SSHPort != 0 {
+		forwards = append(forwards, session.PortForward{HostPort: sess.SSHPort, GuestPort: sshGuestPort})
+	}
+	return forwards
+}
+
+// prepareSSH generates the session's SSH keys with ssh-keygen and picks a free
+// loopback port for the guest's sshd. The guest host key is generated on the
+// host so faize ssh can pin it. Returns the host port.
+func prepareSSH(sessionDir, bootstrapDir, id string) (int, error) {
+	keygen, err := exec.LookPath("ssh-keygen")
+	if err != nil {
+		return 0, fmt.Errorf("ssh-keygen not found")
+	}
+
+	hostDir := filepath.Join(sessionDir, guest.SSHDir)
+	guestDir := filepath.Join(bootstrapDir, guest.SSHDir)
+	for _, dir := range []string{hostDir, guestDir} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return 0, fmt.Errorf("failed to create SSH directory: %w", err)
+		}
+	}
+
+	clientKey := filepath.Join(hostDir, sshClientKey)
+	hostKey := filepath.Join(guestDir, guest.SSHHostKeyFile)
+	for _, key := range []string{clientKey, hostKey} {
+		out, err := exec.Command(keygen, "-q", "-t", "ed25519", "-N", "", "-C", "faize-"+id, "-f", key).CombinedOutput()
+		if err != nil {
+			return 0, fmt.Errorf("failed to generate SSH key: %w: %s", err, strings.TrimSpace(string(out)))
+		}
+	}
+
+	clientPub, err := os.ReadFile(clientKey + ".pub")
+	if err != nil {
+		return 0, fmt.Errorf("failed to read SSH key: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(guestDir, guest.SSHAuthorizedKeysFile), clientPub, 0600); err != nil {
+		return 0, fmt.Errorf("failed to write authorized keys: %w", err)
+	}
+
+	hostPub, err := os.ReadFile(hostKey + ".pub")
+	if err != nil {
+		return 0, fmt.Errorf("failed to read SSH host key: %w", err)
+	}
+	fields := strings.Fields(string(hostPub))
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("malformed SSH host key")
+	}
+	knownHosts := fmt.Sprintf("%s %s %s\n", sshHostAlias(id), fields[0], fields[1])
+	if err := os.WriteFile(filepath.Join(hostDir, sshKnownHosts), []byte(knownHosts), 0600); err != nil {
+		return 0, fmt.Errorf("failed to write known hosts: %w", err)
+	}
+
+	return freeLoopbackPort()
+}
+
+// freeLoopbackPort returns a TCP port that is currently free on publishHost
+func freeLoopbackPort() (int, error) {
+	ln, err := net.Listen("tcp", net.JoinHostPort(publishHost, "0"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to find a free port: %w", err)
+	}
+	defer func() { _ = ln.Close() }()
+	return ln.Addr().(*net.TCPAddr).Port, nil
+}
+
+// sshHostAlias is the name the guest's host key is pinned under in known_hosts
+func sshHostAlias(id string) string {
+	return "faize-" + id
+}
+
+// SSHOptions returns the ssh_config options that reach a session's guest, in
+// the order they are written to a config file
+func SSHOptions(sess *session.Session) ([][2]string, error) {
+	if sess.SSHPort == 0 {
+		return nil, fmt.Errorf("session %s was started without SSH", sess.ID)
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get home directory: %w", err)
+	}
+	dir := filepath.Join(homeDir, ".faize", "sessions", sess.ID, guest.SSHDir)
+	return [][2]string{
+		{"HostName", publishHost},
+		{"Port", strconv.Itoa(sess.SSHPort)},
+		{"User", "claude"},
+		{"IdentityFile", filepath.Join(dir, sshClientKey)},
+		{"IdentitiesOnly", "yes"},
+		{"UserKnownHostsFile", filepath.Join(dir, sshKnownHosts)},
+		{"HostKeyAlias", sshHostAlias(sess.ID)},
+		{"StrictHostKeyChecking", "yes"},
+		{"LogLevel", "ERROR"},
+	}, nil
+}
+
+// SSHConfig returns an ssh_config Host block for a session, so ssh, scp, and
+// editors can connect with `Host faize-<id>`
+func SSHConfig(sess *session.Session) (string, error) {
+	opts, err := SSHOptions(sess)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Host %s\n", sshHostAlias(sess.ID))
+	for _, o := range opts {
+		fmt.Fprintf(&b, "  %s %s\n", o[0], o[1])
+	}
+	return b.String(), nil
+}
+
+// SSHArgs returns the ssh command-line arguments that connect to a session,
+// followed by extra (a remote command, or more ssh options)
+func SSHArgs(sess *session.Session, extra ...string) ([]string, error) {
+	opts, err := SSHOptions(sess)
+	if err != nil {
+		return nil, err
+	}
+	var args []string
+	for _, o := range opts {
+		if o[0] == "HostName" {
+			continue
+		}
+		args = append(args, "-o", o[0]+"="+o[1])
+	}
+	args = append(args, publishHost)
+	return append(args, extra...), nil
+}
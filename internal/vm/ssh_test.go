@@ -0,0 +1,76 @@
+package vm
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionForwards(t *testing.T) {
+	sess := &session.Session{Ports: []session.PortForward{{HostPort: 3000, GuestPort: 3000}}}
+	assert.Equal(t, sess.Ports, sessionForwards(sess))
+
+	sess.SSHPort = 40022
+	assert.Equal(t, []session.PortForward{
+		{HostPort: 3000, GuestPort: 3000},
+		{HostPort: 40022, GuestPort: 22},
+	}, sessionForwards(sess))
+	assert.Len(t, sess.Ports, 1, "published ports are not modified")
+}
+
+func TestSSHConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	_, err := SSHConfig(&session.Session{ID: "abc123"})
+	assert.Error(t, err, "sessions without SSH have no config")
+
+	config, err := SSHConfig(&session.Session{ID: "abc123", SSHPort: 40022})
+	require.NoError(t, err)
+	keyDir := filepath.Join(home, ".faize", "sessions", "abc123", "ssh")
+	assert.True(t, strings.HasPrefix(config, "Host faize-abc123\n"))
+	assert.Contains(t, config, "  HostName 127.0.0.1\n")
+	assert.Contains(t, config, "  Port 40022\n")
+	assert.Contains(t, config, "  IdentityFile "+filepath.Join(keyDir, "id_ed25519")+"\n")
+	assert.Contains(t, config, "  HostKeyAlias faize-abc123\n")
+	assert.Contains(t, config, "  StrictHostKeyChecking yes\n")
+
+	args, err := SSHArgs(&session.Session{ID: "abc123", SSHPort: 40022}, "npm", "test")
+	require.NoError(t, err)
+	assert.Contains(t, args, "Port=40022")
+	assert.Equal(t, []string{"127.0.0.1", "npm", "test"}, args[len(args)-3:])
+}
+
+func TestPrepareSSH(t *testing.T) {
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen not available")
+	}
+	sessionDir := t.TempDir()
+	bootstrapDir := bootstrapPath(sessionDir)
+	require.NoError(t, os.MkdirAll(bootstrapDir, 0700))
+
+	port, err := prepareSSH(sessionDir, bootstrapDir, "abc123")
+	require.NoError(t, err)
+	assert.NotZero(t, port)
+
+	guestDir := filepath.Join(bootstrapDir, guest.SSHDir)
+	assert.FileExists(t, filepath.Join(guestDir, guest.SSHHostKeyFile))
+	assert.NoFileExists(t, filepath.Join(guestDir, "id_ed25519"), "the client key stays out of the guest")
+
+	clientPub, err := os.ReadFile(filepath.Join(sessionDir, "ssh", "id_ed25519.pub"))
+	require.NoError(t, err)
+	authorized, err := os.ReadFile(filepath.Join(guestDir, guest.SSHAuthorizedKeysFile))
+	require.NoError(t, err)
+	assert.Equal(t, clientPub, authorized)
+
+	knownHosts, err := os.ReadFile(filepath.Join(sessionDir, "ssh", "known_hosts"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(knownHosts), "faize-abc123 ssh-ed25519 "))
+}
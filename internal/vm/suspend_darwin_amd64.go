@@ -0,0 +1,17 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/Code-Hex/vz/v3"
+)
+
+// saveMachineState is unavailable: Virtualization.framework only saves VM state on Apple silicon
+func saveMachineState(vm *vz.VirtualMachine, path string) error {
+	return fmt.Errorf("saving VM state requires Apple silicon")
+}
+
+// restoreMachineState is unavailable: Virtualization.framework only restores VM state on Apple silicon
+func restoreMachineState(vm *vz.VirtualMachine, path string) error {
+	return fmt.Errorf("restoring VM state requires Apple silicon")
+}
@@ -0,0 +1,14 @@
+package vm
+
+import "github.com/Code-Hex/vz/v3"
+
+// saveMachineState writes a paused VM's state to path (macOS 14+)
+func saveMachineState(vm *vz.VirtualMachine, path string) error {
+	return vm.SaveMachineStateToPath(path)
+}
+
+// restoreMachineState loads state saved by saveMachineState into a stopped VM,
+// leaving it paused (macOS 14+)
+func restoreMachineState(vm *vz.VirtualMachine, path string) error {
+	return vm.RestoreMachineStateFromURL(path)
+}
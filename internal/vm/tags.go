@@ -0,0 +1,100 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/faize-ai/faize/internal/session"
+)
+
+// VirtioFS tags reserved for faize's own shares
+const (
+	TagBootstrap   = "faize-bootstrap"
+	TagHostClaude  = "host-claude"
+	TagToolchain   = "toolchain"
+	TagCredentials = "credentials"
+	TagHome        = "home"
+)
+
+// reservedTags cannot be assigned to user mounts
+var reservedTags = map[string]bool{
+	TagBootstrap:   true,
+	TagHostClaude:  true,
+	TagToolchain:   true,
+	TagCredentials: true,
+	TagHome:        true,
+}
+
+// maxTagLength is the longest tag the Linux virtiofs driver accepts
+const maxTagLength = 36
+
+// IsReservedTag reports whether tag is used by a faize system share
+func IsReservedTag(tag string) bool {
+	return reservedTags[tag]
+}
+
+// AllocateTags assigns a tag to every user mount that doesn't have one and
+// rejects explicit tags that are reserved, too long, or duplicated.
+// Auto-generated tags use the mountN scheme, skipping any N already taken.
+func AllocateTags(mounts []session.VMMount) ([]session.VMMount, error) {
+	result := make([]session.VMMount, len(mounts))
+	copy(result, mounts)
+
+	used := make(map[string]bool)
+	for _, m := range result {
+		if m.Tag == "" {
+			continue
+		}
+		if IsReservedTag(m.Tag) {
+			return nil, fmt.Errorf("mount tag %q for %s is reserved for faize system shares", m.Tag, m.Source)
+		}
+		if err := validateTag(m.Tag); err != nil {
+			return nil, fmt.Errorf("invalid mount tag for %s: %w", m.Source, err)
+		}
+		if used[m.Tag] {
+			return nil, fmt.Errorf("duplicate mount tag %q for %s", m.Tag, m.Source)
+		}
+		used[m.Tag] = true
+	}
+
+	next := 0
+	for i := range result {
+		if result[i].Tag != "" {
+			continue
+		}
+		for used[fmt.Sprintf("mount%d", next)] {
+			next++
+		}
+		result[i].Tag = fmt.Sprintf("mount%d", next)
+		used[result[i].Tag] = true
+		next++
+	}
+
+	return result, nil
+}
+
+// ValidateTags checks a complete device list (user and system shares) for
+// missing or duplicate tags before it is handed to the hypervisor.
+func ValidateTags(mounts []session.VMMount) error {
+	seen := make(map[string]string)
+	for _, m := range mounts {
+		if err := validateTag(m.Tag); err != nil {
+			return fmt.Errorf("invalid mount tag for %s: %w", m.Source, err)
+		}
+		if prev, ok := seen[m.Tag]; ok {
+			return fmt.Errorf("VirtioFS tag collision: %q used by both %s and %s", m.Tag, prev, m.Source)
+		}
+		seen[m.Tag] = m.Source
+	}
+	return nil
+}
+
+// validateTag checks that a tag is non-empty and within the guest driver's length limit
+func validateTag(tag string) error {
+	if tag == "" {
+		return fmt.Errorf("tag cannot be empty")
+	}
+	if len(tag) > maxTagLength {
+		return fmt.Errorf("tag %q exceeds %d characters", tag, maxTagLength)
+	}
+	return nil
+}
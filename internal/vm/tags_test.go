@@ -0,0 +1,67 @@
+package vm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllocateTags(t *testing.T) {
+	t.Run("assigns sequential tags", func(t *testing.T) {
+		mounts, err := AllocateTags([]session.VMMount{{Source: "/a"}, {Source: "/b"}})
+		require.NoError(t, err)
+		assert.Equal(t, "mount0", mounts[0].Tag)
+		assert.Equal(t, "mount1", mounts[1].Tag)
+	})
+
+	t.Run("skips tags already taken", func(t *testing.T) {
+		mounts, err := AllocateTags([]session.VMMount{{Source: "/a"}, {Source: "/b", Tag: "mount0"}})
+		require.NoError(t, err)
+		assert.Equal(t, "mount1", mounts[0].Tag)
+		assert.Equal(t, "mount0", mounts[1].Tag)
+	})
+
+	t.Run("does not modify input", func(t *testing.T) {
+		in := []session.VMMount{{Source: "/a"}}
+		_, err := AllocateTags(in)
+		require.NoError(t, err)
+		assert.Empty(t, in[0].Tag)
+	})
+
+	t.Run("rejects reserved tags", func(t *testing.T) {
+		_, err := AllocateTags([]session.VMMount{{Source: "/a", Tag: TagCredentials}})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "reserved")
+	})
+
+	t.Run("rejects duplicate tags", func(t *testing.T) {
+		_, err := AllocateTags([]session.VMMount{{Source: "/a", Tag: "data"}, {Source: "/b", Tag: "data"}})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "duplicate")
+	})
+
+	t.Run("rejects overlong tags", func(t *testing.T) {
+		_, err := AllocateTags([]session.VMMount{{Source: "/a", Tag: strings.Repeat("x", maxTagLength+1)}})
+		require.Error(t, err)
+	})
+}
+
+func TestValidateTags(t *testing.T) {
+	ok := []session.VMMount{
+		{Source: "/boot", Tag: TagBootstrap},
+		{Source: "/proj", Tag: "mount0"},
+		{Source: "/creds", Tag: TagCredentials},
+	}
+	assert.NoError(t, ValidateTags(ok))
+
+	collision := append(ok, session.VMMount{Source: "/other", Tag: TagCredentials})
+	err := ValidateTags(collision)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "/creds")
+	assert.Contains(t, err.Error(), "/other")
+
+	assert.Error(t, ValidateTags([]session.VMMount{{Source: "/x"}}))
+}
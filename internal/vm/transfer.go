@@ -0,0 +1,163 @@
+//go:build darwin || linux
+
+package vm
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/faize-ai/faize/internal/guest"
+)
+
+// transferDir is the staging area for faize cp in the bootstrap share
+const transferDir = "transfer"
+
+// newTransferStage creates an empty staging directory in the session's
+// bootstrap share, returning its host and guest paths
+func newTransferStage(id string) (hostDir, guestDir string, err error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("failed to generate staging name: %w", err)
+	}
+	name := hex.EncodeToString(buf)
+
+	hostDir = filepath.Join(sessionFile(id, "bootstrap"), transferDir, name)
+	if err := os.MkdirAll(hostDir, 0755); err != nil {
+		return "", "", fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	return hostDir, path.Join(guest.BootstrapDir, transferDir, name), nil
+}
+
+// CopyToGuest copies a host file or directory into a running session. Like
+// cp -R, an existing guest directory at dst receives the copy; otherwise the
+// copy is named dst. Relative guest paths are resolved against the project
+// directory, and the copy is owned by the session user.
+func CopyToGuest(id, src, dst string) error {
+	if _, err := os.Lstat(src); err != nil {
+		return fmt.Errorf("failed to read %s: %w", src, err)
+	}
+
+	hostStage, guestStage, err := newTransferStage(id)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(hostStage) }()
+
+	name := filepath.Base(filepath.Clean(src))
+	if err := copyTree(src, filepath.Join(hostStage, name)); err != nil {
+		return fmt.Errorf("failed to stage %s: %w", src, err)
+	}
+	return guestCopy(id, "", path.Join(guestStage, name), dst)
+}
+
+// CopyFromGuest copies a file or directory out of a running session. Like
+// cp -R, an existing host directory at dst receives the copy; otherwise the
+// copy is named dst.
+func CopyFromGuest(id, src, dst string) error {
+	hostStage, guestStage, err := newTransferStage(id)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(hostStage) }()
+
+	// Root can read anything the session can and write to the bootstrap share
+	if err := guestCopy(id, "root", src, guestStage+"/"); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(hostStage)
+	if err != nil || len(entries) != 1 {
+		return fmt.Errorf("failed to copy %s: nothing was staged", src)
+	}
+	staged := filepath.Join(hostStage, entries[0].Name())
+
+	target := dst
+	if info, err := os.Stat(dst); err == nil && info.IsDir() {
+		target = filepath.Join(dst, entries[0].Name())
+	}
+	// The staging area is under ~/.faize; fall back to copying across filesystems
+	if err := os.Rename(staged, target); err != nil {
+		if err := copyTree(staged, target); err != nil {
+			return fmt.Errorf("failed to copy to %s: %w", target, err)
+		}
+	}
+	return nil
+}
+
+// guestCopy runs cp -R inside the session, reporting cp's own error message on failure
+func guestCopy(id, user, src, dst string) error {
+	var stderr bytes.Buffer
+	req := &guest.ExecRequest{Args: []string{"cp", "-R", "--", src, dst}, User: user}
+	code, err := Exec(id, req, io.Discard, &stderr)
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = fmt.Sprintf("cp exited with status %d", code)
+		}
+		return fmt.Errorf("failed to copy %s: %s", src, msg)
+	}
+	return nil
+}
+
+// copyTree copies a file, symlink, or directory tree from src to dst,
+// preserving permissions. Other file types are skipped.
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			_ = os.Remove(target)
+			return os.Symlink(link, target)
+		case d.Type().IsRegular():
+			return copyRegularFile(p, target, info.Mode().Perm())
+		}
+		return nil
+	})
+}
+
+// copyRegularFile copies one file's contents, replacing dst if it exists
+func copyRegularFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,59 @@
+//go:build darwin || linux
+
+package vm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyTree(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "dist")
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "bin"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "bin", "app"), []byte("#!/bin/sh\n"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "README"), []byte("hi"), 0644))
+	require.NoError(t, os.Symlink("bin/app", filepath.Join(src, "app")))
+
+	dst := filepath.Join(t.TempDir(), "copy")
+	require.NoError(t, copyTree(src, dst))
+
+	data, err := os.ReadFile(filepath.Join(dst, "README"))
+	require.NoError(t, err)
+	assert.Equal(t, "hi", string(data))
+
+	info, err := os.Stat(filepath.Join(dst, "bin", "app"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+
+	link, err := os.Readlink(filepath.Join(dst, "app"))
+	require.NoError(t, err)
+	assert.Equal(t, "bin/app", link)
+}
+
+func TestCopyTree_File(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "out.bin")
+	require.NoError(t, os.WriteFile(src, []byte("new"), 0600))
+	dst := filepath.Join(t.TempDir(), "out.bin")
+	require.NoError(t, os.WriteFile(dst, []byte("old contents"), 0644))
+
+	require.NoError(t, copyTree(src, dst))
+	data, err := os.ReadFile(dst)
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(data))
+}
+
+func TestNewTransferStage(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	hostDir, guestDir, err := newTransferStage("aaa001")
+	require.NoError(t, err)
+	assert.DirExists(t, hostDir)
+	assert.Equal(t, filepath.Join(sessionFile("aaa001", "bootstrap"), transferDir, filepath.Base(hostDir)), hostDir)
+	assert.True(t, strings.HasPrefix(guestDir, "/mnt/bootstrap/transfer/"))
+	assert.Equal(t, filepath.Base(hostDir), filepath.Base(guestDir))
+}
@@ -3,21 +3,60 @@ package vm
 import (
 	"time"
 
+	"github.com/faize-ai/faize/internal/guest"
 	"github.com/faize-ai/faize/internal/network"
 	"github.com/faize-ai/faize/internal/session"
 )
 
 type Config struct {
+	Name           string            // user-chosen session name (faize start --name)
+	Labels         map[string]string // user-chosen KEY=VALUE labels (faize start --label)
 	ProjectDir     string
 	Mounts         []session.VMMount
 	Network        []string
 	NetworkPolicy  *network.Policy
 	CPUs           int
 	Memory         string
+	MaxCPUs        int    // size of the VM, which faize resize can grow CPUs up to (zero is CPUs)
+	MaxMemory      string // size of the VM's memory, likewise (empty is Memory)
+	Disk           string // size of a scratch disk mounted at /scratch, e.g. "20GB" (empty for none)
+	Overlay        string // the project's persistent rootfs overlay disk (empty keeps rootfs changes in memory)
+	Snapshot       string // rootfs snapshot the session starts from (faize start --from-snapshot); not combined with Overlay
+	Kernel         string // kernel image booted instead of the downloaded one (empty for the default)
+	Rootfs         string // rootfs image booted instead of the downloaded one, likewise
+	Image          string // OCI image Rootfs was built from, if any (faize start --image)
+	Flavor         string // Claude rootfs flavor, e.g. "debian" (empty for Alpine; see artifacts.Flavors)
 	Timeout        time.Duration
+	Watchdog       time.Duration // guest powers off after this long without a host heartbeat (zero disables)
 	ClaudeMode     bool
 	HostClaudeDir  string
 	ToolchainDir   string
 	CredentialsDir string
+	Home           string // the project's persistent guest home directory (empty keeps /home/claude in the rootfs)
+	SyncSettings   bool   // save the session's Claude settings.json for syncing back to the host
+	SyncSkills     bool   // save skills and plugin files changed in the session, likewise
 	ExtraDeps      []string
+	CaptureNetwork bool                  // record guest traffic to a rotating pcap in the bootstrap share
+	Publish        []session.PortForward // guest TCP ports published on host loopback
+	Warm           bool                  // boot idle and wait for a project to be claimed (faize warm)
+	PreventSleep   string                // when the macOS host is kept awake (PreventSleep* modes)
+	NetworkPrompt  bool                  // ask the attached user about denied connections (egress proxy only)
+	Notify         bool                  // desktop notification when the detached session needs attention
+	NetLimit       uint64                // guest bandwidth cap in bits per second, each direction (zero is unlimited)
+	Resolvers      []string              // DNS servers used instead of the defaults (empty keeps them)
+	Hosts          []session.HostEntry   // extra /etc/hosts entries in the guest
+	Proxy          string                // upstream HTTP proxy URL, e.g. a corporate proxy (empty connects directly)
+	NoProxy        []string              // hosts reached without Proxy, in NO_PROXY syntax
+	CACerts        []string              // host PEM files added to the guest's trusted CAs
+	StrictNetwork  bool                  // in-guest allowlist only accepts addresses resolved for allowed names
+	Prompt         string                // run claude -p with this prompt instead of an interactive session (faize run)
+	PromptArgs     []string              // extra Claude arguments with Prompt
+	Tasks          []guest.Task          // run these prompts one after another instead (faize run --tasks)
+	PostCreate     []string              // shell commands run in the project before Claude starts (devcontainer postCreateCommand)
+	Build          bool                  // boot a builder VM, which runs a rootfs build step instead of a session (see BuildRunner)
+
+	// Resource limits (zero means unlimited)
+	MaxRunningSessions int
+	MaxTotalMemory     string
+	ReplaceOldest      bool // stop the oldest running session instead of failing when a limit is hit
 }
@@ -0,0 +1,195 @@
+package vm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+
+	"github.com/faize-ai/faize/internal/artifacts"
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/faize-ai/faize/internal/session"
+)
+
+// ArtifactError reports a kernel or rootfs image that failed validation
+// before boot, so callers can replace it and retry
+type ArtifactError struct {
+	Name   string // "kernel" or "rootfs"
+	Path   string
+	Err    error
+	Custom bool // given with faize start --kernel or --rootfs, so not faize's to replace
+}
+
+func (e *ArtifactError) Error() string {
+	return fmt.Sprintf("%s validation failed: %v", e.Name, e.Err)
+}
+
+func (e *ArtifactError) Unwrap() error {
+	return e.Err
+}
+
+// bootImages returns the kernel and rootfs images a session boots: those it
+// was given (faize start --kernel and --rootfs), or else the downloaded ones,
+// the Claude rootfs being of flavor
+func bootImages(a *artifacts.Manager, kernel, rootfs, flavor string, claudeMode bool) (string, string) {
+	if kernel == "" {
+		kernel = a.KernelPath()
+	}
+	if rootfs == "" {
+		rootfs = a.RootfsPath()
+		if claudeMode {
+			rootfs = a.FlavorRootfsPath(flavor)
+		}
+	}
+	return kernel, rootfs
+}
+
+// validateArtifacts checks the kernel and rootfs a session boots before boot
+func validateArtifacts(a *artifacts.Manager, sess *session.Session) error {
+	debugLog("Running pre-start validation...")
+	kernel, rootfs := bootImages(a, sess.Kernel, sess.Rootfs, sess.Flavor, sess.ClaudeMode)
+	if err := validateKernelFile(kernel); err != nil {
+		return &ArtifactError{Name: "kernel", Path: kernel, Err: err, Custom: sess.Kernel != ""}
+	}
+	if err := validateRootfs(rootfs); err != nil {
+		return &ArtifactError{Name: "rootfs", Path: rootfs, Err: err, Custom: sess.Rootfs != ""}
+	}
+	// The Claude rootfs and image rootfs images faize builds record their agent
+	return checkRootfsAgent(rootfs, sess.ClaudeMode && (sess.Rootfs == "" || sess.Image != ""))
+}
+
+// checkRootfsAgent checks that the guest agent recorded in the manifest of a
+// rootfs speaks this host's interface. A rootfs without a manifest passes
+// unless one is required.
+func checkRootfsAgent(rootfs string, required bool) error {
+	version := artifacts.RootfsAgentVersion(rootfs)
+	if version == "" && !required {
+		return nil
+	}
+	if version != strconv.Itoa(guest.AgentVersion) {
+		if version == "" {
+			version = "unknown"
+		}
+		return fmt.Errorf("the guest agent in %s is outdated (version %s, this faize needs %d): rebuild it with 'faize claude rebuild'",
+			rootfs, version, guest.AgentVersion)
+	}
+	return nil
+}
+
+// ValidateKernel checks that path is a kernel image faize can boot, such as
+// one given with faize start --kernel
+func ValidateKernel(path string) error {
+	return validateKernelFile(path)
+}
+
+// ValidateRootfs checks that path is an ext4 rootfs image, such as one given
+// with faize start --rootfs
+func ValidateRootfs(path string) error {
+	return validateRootfs(path)
+}
+
+// ELF machine numbers of the architectures faize runs on
+const (
+	elfMachineX86_64  = 62
+	elfMachineAArch64 = 183
+)
+
+// validateKernelFile checks that the kernel is an ELF, ARM64 Image, or x86
+// bzImage file built for the host's architecture
+func validateKernelFile(path string) error {
+	arch, err := kernelArch(path)
+	if err != nil {
+		return err
+	}
+	if arch != runtime.GOARCH {
+		return fmt.Errorf("kernel is built for %s, but this host is %s", arch, runtime.GOARCH)
+	}
+	return nil
+}
+
+// kernelArch returns the architecture (as a GOARCH) of a kernel image, from
+// its format
+func kernelArch(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot open kernel: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	// Read first 64 bytes for header detection
+	header := make([]byte, 64)
+	n, err := io.ReadFull(f, header)
+	if n < 4 {
+		return "", fmt.Errorf("cannot read kernel header: %w", err)
+	}
+
+	// Check ELF magic bytes: 0x7F 'E' 'L' 'F', then the machine at offset 18
+	if header[0] == 0x7F && header[1] == 'E' && header[2] == 'L' && header[3] == 'F' {
+		if n < 20 {
+			return "", fmt.Errorf("kernel ELF header is truncated")
+		}
+		machine := binary.LittleEndian.Uint16(header[18:20])
+		debugLog("Kernel format: ELF (machine: %d)", machine)
+		switch machine {
+		case elfMachineAArch64:
+			return "arm64", nil
+		case elfMachineX86_64:
+			return "amd64", nil
+		}
+		return "", fmt.Errorf("kernel is an ELF file for an unsupported machine (%d)", machine)
+	}
+
+	// Check ARM64 Linux Image format
+	// ARM64 Image files start with executable code, and have "ARM\x64" at offset 56
+	if n >= 60 && header[56] == 'A' && header[57] == 'R' && header[58] == 'M' && header[59] == 0x64 {
+		debugLog("Kernel format: ARM64 Image (magic at 56: ARM\\x64)")
+		return "arm64", nil
+	}
+
+	// Check x86 bzImage format: "HdrS" setup header signature at offset 0x202
+	bzMagic := make([]byte, 4)
+	if _, err := f.ReadAt(bzMagic, 0x202); err == nil && string(bzMagic) == "HdrS" {
+		debugLog("Kernel format: x86 bzImage (HdrS at 0x202)")
+		return "amd64", nil
+	}
+
+	// Also accept if file starts with ARM64 instruction (common for Image format)
+	// The first instruction is typically a branch: 0x14xxxxxx or similar
+	// Or NOP-like: 0xd503201f or similar (which includes 0x1f2003d5 little-endian)
+	if header[3] == 0x14 || header[3] == 0xd5 {
+		debugLog("Kernel format: ARM64 Image (starts with ARM64 instruction: %x)", header[:4])
+		return "arm64", nil
+	}
+
+	return "", fmt.Errorf("kernel is not a valid ELF, ARM64 Image, or bzImage file (header: %x)", header[:8])
+}
+
+// validateRootfs checks if the rootfs has valid ext4 superblock
+func validateRootfs(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("cannot open rootfs: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	// ext4 superblock is at offset 1024, magic is at offset 0x38 (56) within superblock
+	// Total offset: 1024 + 56 = 1080
+	if _, err := f.Seek(1080, 0); err != nil {
+		return fmt.Errorf("cannot seek to ext4 magic: %w", err)
+	}
+
+	magic := make([]byte, 2)
+	if _, err := f.Read(magic); err != nil {
+		return fmt.Errorf("cannot read ext4 magic: %w", err)
+	}
+
+	// ext4 magic is 0xEF53 (little-endian: 0x53 0xEF)
+	if magic[0] != 0x53 || magic[1] != 0xEF {
+		return fmt.Errorf("rootfs is not valid ext4 (magic: %x)", magic)
+	}
+
+	debugLog("Rootfs ext4 magic validated: %x", magic)
+	return nil
+}
@@ -0,0 +1,105 @@
+package vm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// elfKernel returns the start of an ELF kernel for machine
+func elfKernel(machine uint16) []byte {
+	elf := append([]byte{0x7F, 'E', 'L', 'F'}, make([]byte, 60)...)
+	binary.LittleEndian.PutUint16(elf[18:], machine)
+	return elf
+}
+
+func TestKernelArch(t *testing.T) {
+	dir := t.TempDir()
+	bz := make([]byte, 0x300)
+	copy(bz[0x202:], "HdrS")
+	image := make([]byte, 64)
+	copy(image[56:], "ARM\x64")
+
+	for name, want := range map[string]struct {
+		data []byte
+		arch string
+	}{
+		"vmlinux-arm64": {elfKernel(elfMachineAArch64), "arm64"},
+		"vmlinux-amd64": {elfKernel(elfMachineX86_64), "amd64"},
+		"bzImage":       {bz, "amd64"},
+		"Image":         {image, "arm64"},
+	} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, want.data, 0644))
+		arch, err := kernelArch(path)
+		require.NoError(t, err, name)
+		assert.Equal(t, want.arch, arch, name)
+	}
+
+	riscv := filepath.Join(dir, "vmlinux-riscv")
+	require.NoError(t, os.WriteFile(riscv, elfKernel(243), 0644))
+	_, err := kernelArch(riscv)
+	assert.ErrorContains(t, err, "unsupported machine")
+}
+
+func TestValidateKernel(t *testing.T) {
+	dir := t.TempDir()
+
+	// Only a kernel for the host's architecture boots
+	native, foreign := elfKernel(elfMachineX86_64), elfKernel(elfMachineAArch64)
+	if runtime.GOARCH == "arm64" {
+		native, foreign = foreign, native
+	}
+	elf := filepath.Join(dir, "vmlinux")
+	require.NoError(t, os.WriteFile(elf, native, 0644))
+	assert.NoError(t, ValidateKernel(elf))
+	other := filepath.Join(dir, "vmlinux-other")
+	require.NoError(t, os.WriteFile(other, foreign, 0644))
+	assert.ErrorContains(t, ValidateKernel(other), "but this host is "+runtime.GOARCH)
+
+	text := filepath.Join(dir, "notes.txt")
+	require.NoError(t, os.WriteFile(text, []byte("not a kernel, just some notes about one"), 0644))
+	assert.Error(t, ValidateKernel(text))
+
+	assert.Error(t, ValidateKernel(filepath.Join(dir, "missing")))
+}
+
+func TestValidateRootfs(t *testing.T) {
+	dir := t.TempDir()
+
+	img := make([]byte, 4096)
+	img[1080], img[1081] = 0x53, 0xEF
+	ext4 := filepath.Join(dir, "rootfs.img")
+	require.NoError(t, os.WriteFile(ext4, img, 0644))
+	assert.NoError(t, ValidateRootfs(ext4))
+
+	blank := filepath.Join(dir, "blank.img")
+	require.NoError(t, os.WriteFile(blank, make([]byte, 4096), 0644))
+	assert.Error(t, ValidateRootfs(blank))
+
+	short := filepath.Join(dir, "short.img")
+	require.NoError(t, os.WriteFile(short, []byte("tiny"), 0644))
+	assert.Error(t, ValidateRootfs(short))
+}
+
+func TestCheckRootfsAgent(t *testing.T) {
+	rootfs := filepath.Join(t.TempDir(), "claude-rootfs.img")
+
+	// Images faize builds must record their agent; others are only checked if they do
+	assert.ErrorContains(t, checkRootfsAgent(rootfs, true), "faize claude rebuild")
+	assert.NoError(t, checkRootfsAgent(rootfs, false))
+
+	require.NoError(t, os.WriteFile(rootfs+".manifest", []byte("claude=1.0.0\nfaize-agent=present\n"), 0644))
+	assert.ErrorContains(t, checkRootfsAgent(rootfs, false), "outdated (version present")
+
+	current := fmt.Sprintf("faize-agent=%d\n", guest.AgentVersion)
+	require.NoError(t, os.WriteFile(rootfs+".manifest", []byte(current), 0644))
+	assert.NoError(t, checkRootfsAgent(rootfs, true))
+}
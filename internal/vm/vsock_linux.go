@@ -0,0 +1,94 @@
+//go:build linux
+
+package vm
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// vsockListener accepts host vsock connections from a single guest. The
+// host's vsock port space is shared by every VM, so connections from other
+// context IDs are dropped.
+type vsockListener struct {
+	file *os.File
+	port uint32
+	cid  uint32
+}
+
+// listenVsock listens on a host vsock port for connections from the guest cid
+func listenVsock(port, cid uint32) (*vsockListener, error) {
+	fd, err := unix.Socket(unix.AF_VSOCK, unix.SOCK_STREAM|unix.SOCK_CLOEXEC|unix.SOCK_NONBLOCK, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create vsock socket: %w", err)
+	}
+	if err := unix.Bind(fd, &unix.SockaddrVM{CID: unix.VMADDR_CID_ANY, Port: port}); err != nil {
+		_ = unix.Close(fd)
+		return nil, fmt.Errorf("failed to bind vsock port %d: %w", port, err)
+	}
+	if err := unix.Listen(fd, 16); err != nil {
+		_ = unix.Close(fd)
+		return nil, fmt.Errorf("failed to listen on vsock port %d: %w", port, err)
+	}
+	// A non-blocking descriptor lets Close interrupt a pending Accept
+	return &vsockListener{file: os.NewFile(uintptr(fd), "vsock"), port: port, cid: cid}, nil
+}
+
+// Accept waits for the next connection from the listener's guest
+func (l *vsockListener) Accept() (net.Conn, error) {
+	raw, err := l.file.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var fd int
+		var sa unix.Sockaddr
+		var acceptErr error
+		if err := raw.Read(func(s uintptr) bool {
+			fd, sa, acceptErr = unix.Accept4(int(s), unix.SOCK_CLOEXEC|unix.SOCK_NONBLOCK)
+			return acceptErr != unix.EAGAIN
+		}); err != nil {
+			return nil, net.ErrClosed
+		}
+		if acceptErr != nil {
+			return nil, acceptErr
+		}
+
+		peer, ok := sa.(*unix.SockaddrVM)
+		if !ok || peer.CID != l.cid {
+			_ = unix.Close(fd)
+			continue
+		}
+		return &vsockConn{File: os.NewFile(uintptr(fd), "vsock"), local: l.Addr(), remote: vsockAddr{peer.CID, peer.Port}}, nil
+	}
+}
+
+// Close stops accepting connections
+func (l *vsockListener) Close() error {
+	return l.file.Close()
+}
+
+// Addr returns the listening address
+func (l *vsockListener) Addr() net.Addr {
+	return vsockAddr{unix.VMADDR_CID_HOST, l.port}
+}
+
+// vsockConn is an accepted vsock connection
+type vsockConn struct {
+	*os.File
+	local, remote net.Addr
+}
+
+func (c *vsockConn) LocalAddr() net.Addr  { return c.local }
+func (c *vsockConn) RemoteAddr() net.Addr { return c.remote }
+
+// vsockAddr is a vsock endpoint
+type vsockAddr struct {
+	cid, port uint32
+}
+
+func (a vsockAddr) Network() string { return "vsock" }
+func (a vsockAddr) String() string  { return fmt.Sprintf("%d:%d", a.cid, a.port) }
@@ -4,6 +4,8 @@ package vm
 
 import (
 	"fmt"
+	"io"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -15,16 +17,8 @@ import (
 	"github.com/faize-ai/faize/internal/artifacts"
 	"github.com/faize-ai/faize/internal/guest"
 	"github.com/faize-ai/faize/internal/session"
-	"github.com/google/uuid"
-	"golang.org/x/term"
 )
 
-func debugLog(format string, args ...interface{}) {
-	if os.Getenv("FAIZE_DEBUG") == "1" {
-		fmt.Printf("[DEBUG:VM] "+format+"\n", args...)
-	}
-}
-
 // captureVZLogs captures recent macOS Virtualization.framework logs
 func captureVZLogs() {
 	debugLog("Capturing VZ Framework logs...")
@@ -43,73 +37,6 @@ func captureVZLogs() {
 	}
 }
 
-// validateKernelFile checks if the kernel is a valid ELF or ARM64 Image file
-func validateKernelFile(path string) error {
-	f, err := os.Open(path)
-	if err != nil {
-		return fmt.Errorf("cannot open kernel: %w", err)
-	}
-	defer func() { _ = f.Close() }()
-
-	// Read first 64 bytes for header detection
-	header := make([]byte, 64)
-	n, err := f.Read(header)
-	if err != nil || n < 4 {
-		return fmt.Errorf("cannot read kernel header: %w", err)
-	}
-
-	// Check ELF magic bytes: 0x7F 'E' 'L' 'F'
-	if header[0] == 0x7F && header[1] == 'E' && header[2] == 'L' && header[3] == 'F' {
-		debugLog("Kernel format: ELF (magic: %x)", header[:4])
-		return nil
-	}
-
-	// Check ARM64 Linux Image format
-	// ARM64 Image files start with executable code, and have "ARM\x64" at offset 56
-	if n >= 60 && header[56] == 'A' && header[57] == 'R' && header[58] == 'M' && header[59] == 0x64 {
-		debugLog("Kernel format: ARM64 Image (magic at 56: ARM\\x64)")
-		return nil
-	}
-
-	// Also accept if file starts with ARM64 instruction (common for Image format)
-	// The first instruction is typically a branch: 0x14xxxxxx or similar
-	// Or NOP-like: 0xd503201f or similar (which includes 0x1f2003d5 little-endian)
-	if header[3] == 0x14 || header[3] == 0xd5 {
-		debugLog("Kernel format: ARM64 Image (starts with ARM64 instruction: %x)", header[:4])
-		return nil
-	}
-
-	return fmt.Errorf("kernel is not a valid ELF or ARM64 Image file (header: %x)", header[:8])
-}
-
-// validateRootfs checks if the rootfs has valid ext4 superblock
-func validateRootfs(path string) error {
-	f, err := os.Open(path)
-	if err != nil {
-		return fmt.Errorf("cannot open rootfs: %w", err)
-	}
-	defer func() { _ = f.Close() }()
-
-	// ext4 superblock is at offset 1024, magic is at offset 0x38 (56) within superblock
-	// Total offset: 1024 + 56 = 1080
-	if _, err := f.Seek(1080, 0); err != nil {
-		return fmt.Errorf("cannot seek to ext4 magic: %w", err)
-	}
-
-	magic := make([]byte, 2)
-	if _, err := f.Read(magic); err != nil {
-		return fmt.Errorf("cannot read ext4 magic: %w", err)
-	}
-
-	// ext4 magic is 0xEF53 (little-endian: 0x53 0xEF)
-	if magic[0] != 0x53 || magic[1] != 0xEF {
-		return fmt.Errorf("rootfs is not valid ext4 (magic: %x)", magic)
-	}
-
-	debugLog("Rootfs ext4 magic validated: %x", magic)
-	return nil
-}
-
 // VZManager implements Manager using Apple's Virtualization.framework
 type VZManager struct {
 	sessions  *session.Store
@@ -117,6 +44,11 @@ type VZManager struct {
 	vms       map[string]*vz.VirtualMachine
 	consoles  map[string]*Console
 	proxies   map[string]*ConsoleProxyServer
+	execs     map[string]*ExecProxyServer
+	forwards  map[string]*PortForwarder
+	egress    map[string]*egressProxy
+	power     map[string]*powerAssertion // held for "always" sessions while the VM runs
+	owners    map[string]*session.OwnerLock
 	mu        sync.RWMutex
 }
 
@@ -138,134 +70,216 @@ func NewVZManager() (*VZManager, error) {
 		vms:       make(map[string]*vz.VirtualMachine),
 		consoles:  make(map[string]*Console),
 		proxies:   make(map[string]*ConsoleProxyServer),
+		execs:     make(map[string]*ExecProxyServer),
+		forwards:  make(map[string]*PortForwarder),
+		egress:    make(map[string]*egressProxy),
+		power:     make(map[string]*powerAssertion),
+		owners:    make(map[string]*session.OwnerLock),
 	}, nil
 }
 
+// NewManager returns the VM backend for this platform
+func NewManager() (Manager, error) {
+	m, err := NewVZManager()
+	if err != nil {
+		return nil, err
+	}
+	// Claude rootfs builds this manager needs run in builder VMs
+	if runner, err := NewBuildRunner(m, m.artifacts, os.Stdout); err == nil {
+		m.artifacts.SetBuildRunner(runner)
+	}
+	return m, nil
+}
+
+// buildVMAvailable reports why this host can't boot builder VMs, if it
+// can't; Virtualization.framework is always there
+func buildVMAvailable() error {
+	return nil
+}
+
+// warmHotplug is unset: Virtualization.framework can't add a share to a
+// running VM, so warm VMs share warm.root and claims bind from it
+const warmHotplug = false
+
+// attachWarmShares can't add shares to a running VM
+func attachWarmShares(sessionDir string, mounts []session.VMMount) error {
+	return fmt.Errorf("adding shares to a running VM is not supported on macOS")
+}
+
 // Create creates a new VM session
 func (m *VZManager) Create(cfg *Config) (*session.Session, error) {
-	// Ensure artifacts are downloaded
-	debugLog("Ensuring artifacts...")
-	if cfg.ClaudeMode {
-		if err := m.artifacts.EnsureClaudeRootfs(); err != nil {
-			return nil, fmt.Errorf("failed to ensure claude rootfs: %w", err)
-		}
-		if err := m.artifacts.EnsureToolchainDir(); err != nil {
-			return nil, fmt.Errorf("failed to ensure toolchain dir: %w", err)
-		}
-		if cfg.CredentialsDir != "" {
-			if err := m.artifacts.EnsureCredentialsDir(); err != nil {
-				return nil, fmt.Errorf("failed to ensure credentials dir: %w", err)
-			}
-		}
-	} else {
-		if err := m.artifacts.EnsureArtifacts(); err != nil {
-			return nil, fmt.Errorf("failed to ensure artifacts: %w", err)
-		}
+	// Enforce session quotas before allocating anything
+	if err := enforceLimits(m, cfg); err != nil {
+		return nil, err
+	}
+	if err := checkHostPorts(cfg.Publish); err != nil {
+		return nil, err
 	}
 
-	// Generate session ID
-	id := uuid.New().String()[:12]
-	debugLog("Session ID: %s", id)
+	// Prepare artifacts, bootstrap directory and VirtioFS share list
+	bs, err := prepareBootstrap(m.artifacts, cfg)
+	if err != nil {
+		return nil, err
+	}
+	id := bs.id
+	allMounts := bs.allMounts
 
-	// Create bootstrap directory for init script
-	bootstrapDir := filepath.Join(m.artifacts.SessionDir(id), "bootstrap")
-	if err := os.MkdirAll(bootstrapDir, 0700); err != nil {
-		return nil, fmt.Errorf("failed to create bootstrap directory: %w", err)
+	// A fixed MAC address lets a paused session restore onto the same NIC
+	mac, err := vz.NewRandomLocallyAdministeredMACAddress()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create MAC address: %w", err)
 	}
 
-	// Generate init script
-	var initScript string
-	if cfg.ClaudeMode {
-		initScript = guest.GenerateClaudeInitScript(cfg.Mounts, cfg.ProjectDir, cfg.NetworkPolicy, cfg.CredentialsDir != "", cfg.ExtraDeps)
-	} else {
-		initScript = guest.GenerateInitScript(cfg.Mounts, cfg.ProjectDir)
+	maxCPUs, maxMemory := vmSize(cfg.CPUs, cfg.MaxCPUs, cfg.Memory, cfg.MaxMemory)
+	kernelPath, rootfsPath := bootImages(m.artifacts, cfg.Kernel, cfg.Rootfs, cfg.Flavor, cfg.ClaudeMode)
+	vm, console, err := m.newMachine(id, kernelPath, rootfsPath, maxCPUs, maxMemory, allMounts, mac, cfg.Overlay, cfg.Snapshot)
+	if err != nil {
+		return nil, err
 	}
 
-	// Write init script to bootstrap directory
-	initScriptPath := filepath.Join(bootstrapDir, "init.sh")
-	if err := os.WriteFile(initScriptPath, []byte(initScript), 0755); err != nil {
-		return nil, fmt.Errorf("failed to write init script: %w", err)
+	// Create session
+	sess := &session.Session{
+		ID:           id,
+		Name:         cfg.Name,
+		Labels:       cfg.Labels,
+		ProjectDir:   cfg.ProjectDir,
+		Mounts:       cfg.Mounts,
+		SystemMounts: bs.systemMounts,
+		Network:      cfg.Network,
+		CPUs:         cfg.CPUs,
+		Memory:       cfg.Memory,
+		MaxCPUs:      maxCPUs,
+		MaxMemory:    maxMemory,
+		Disk:         cfg.Disk,
+		Overlay:      cfg.Overlay,
+		Home:         cfg.Home,
+		Snapshot:     cfg.Snapshot,
+		Kernel:       cfg.Kernel,
+		Rootfs:       cfg.Rootfs,
+		Image:        cfg.Image,
+		Flavor:       cfg.Flavor,
+		Status:       "created",
+		StartedAt:    time.Now(),
+		ClaudeMode:   cfg.ClaudeMode,
+
+		CaptureNetwork: cfg.CaptureNetwork,
+		Ports:          cfg.Publish,
+		MACAddress:     mac.String(),
+		PreventSleep:   cfg.PreventSleep,
+		NetworkPrompt:  cfg.NetworkPrompt,
+		Notify:         cfg.Notify,
+		NetLimit:       cfg.NetLimit,
+		Resolvers:      cfg.Resolvers,
+		Hosts:          cfg.Hosts,
+		Proxy:          cfg.Proxy,
+		NoProxy:        cfg.NoProxy,
+		SSHPort:        bs.sshPort,
+	}
+
+	m.register(id, vm, console)
+
+	// Persist session
+	if err := m.sessions.Save(sess); err != nil {
+		return nil, fmt.Errorf("failed to save session: %w", err)
 	}
 
-	// Write host time to bootstrap directory for guest clock sync
-	hostTime := time.Now().Unix()
-	hostTimePath := filepath.Join(bootstrapDir, "hosttime")
-	if err := os.WriteFile(hostTimePath, []byte(fmt.Sprintf("%d", hostTime)), 0644); err != nil {
-		return nil, fmt.Errorf("failed to write host time: %w", err)
+	return sess, nil
+}
+
+// register stores a session's VM and console and starts its console proxy
+func (m *VZManager) register(id string, vm *vz.VirtualMachine, console *Console) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.vms[id] = vm
+	m.consoles[id] = console
+
+	// Mark this process as the session's owner, so a crash is noticed
+	if owner, err := m.sessions.LockOwner(id); err != nil {
+		debugLog("Failed to lock session owner: %v", err)
+	} else {
+		m.owners[id] = owner
 	}
 
-	// Write terminal size to bootstrap directory for guest terminal setup
-	if term.IsTerminal(int(os.Stdout.Fd())) {
-		width, height, err := term.GetSize(int(os.Stdout.Fd()))
-		if err == nil && width > 0 && height > 0 {
-			termSizePath := filepath.Join(bootstrapDir, "termsize")
-			if err := os.WriteFile(termSizePath, []byte(fmt.Sprintf("%d %d", width, height)), 0644); err != nil {
-				debugLog("Failed to write terminal size: %v", err)
-			}
+	// Create and start console proxy server
+	proxy, err := NewConsoleProxyServer(id, console)
+	if err != nil {
+		debugLog("Failed to create console proxy: %v", err)
+	} else {
+		if err := proxy.Start(); err != nil {
+			debugLog("Failed to start console proxy: %v", err)
+		} else {
+			m.proxies[id] = proxy
+			debugLog("Console proxy started at %s", proxy.SocketPath())
 		}
 	}
+}
 
-	// Create clipboard directory for host-to-guest clipboard sync
-	clipboardDir := filepath.Join(bootstrapDir, "clipboard")
-	if err := os.MkdirAll(clipboardDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create clipboard directory: %w", err)
-	}
+// release forgets a session's VM and stops its proxies and port forwards.
+// Returns false if this process does not own the VM.
+func (m *VZManager) release(id string) (*vz.VirtualMachine, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	// Write debug flag to bootstrap directory if debug mode is enabled
-	if os.Getenv("FAIZE_DEBUG") == "1" {
-		debugPath := filepath.Join(bootstrapDir, "debug")
-		if err := os.WriteFile(debugPath, []byte("1"), 0644); err != nil {
-			debugLog("Failed to write debug flag: %v", err)
-		}
+	vm, ok := m.vms[id]
+	if !ok {
+		return nil, false
 	}
 
-	// Create bootstrap mount and prepend to mounts list
-	bootstrapMount := session.VMMount{
-		Source:   bootstrapDir,
-		Target:   "/mnt/bootstrap",
-		Tag:      "faize-bootstrap",
-		ReadOnly: false,
-	}
-	allMounts := append([]session.VMMount{bootstrapMount}, cfg.Mounts...)
-
-	// Add Claude mode specific mounts
-	if cfg.ClaudeMode {
-		// Add host-claude mount
-		if cfg.HostClaudeDir != "" {
-			claudeMount := session.VMMount{
-				Source:   cfg.HostClaudeDir,
-				Target:   "/mnt/host-claude",
-				Tag:      "host-claude",
-				ReadOnly: true,
-			}
-			allMounts = append(allMounts, claudeMount)
-		}
+	delete(m.vms, id)
+	delete(m.consoles, id)
 
-		// Add toolchain mount
-		if cfg.ToolchainDir != "" {
-			toolchainMount := session.VMMount{
-				Source:   cfg.ToolchainDir,
-				Target:   "/opt/toolchain",
-				Tag:      "toolchain",
-				ReadOnly: false,
-			}
-			allMounts = append(allMounts, toolchainMount)
-		}
+	// Stop and remove proxy
+	if proxy, ok := m.proxies[id]; ok {
+		_ = proxy.Stop()
+		delete(m.proxies, id)
+	}
+	if proxy, ok := m.execs[id]; ok {
+		_ = proxy.Stop()
+		delete(m.execs, id)
+	}
+	if forwarder, ok := m.forwards[id]; ok {
+		forwarder.Stop()
+		delete(m.forwards, id)
+	}
+	if proxy, ok := m.egress[id]; ok {
+		proxy.Stop()
+		delete(m.egress, id)
+	}
+	if assertion, ok := m.power[id]; ok {
+		assertion.release()
+		delete(m.power, id)
+	}
+	if owner, ok := m.owners[id]; ok {
+		owner.Release()
+		delete(m.owners, id)
+	}
+	return vm, true
+}
 
-		// Add credentials mount
-		if cfg.CredentialsDir != "" {
-			credentialsMount := session.VMMount{
-				Source:   cfg.CredentialsDir,
-				Target:   "/mnt/host-credentials",
-				Tag:      "credentials",
-				ReadOnly: false,
-			}
-			allMounts = append(allMounts, credentialsMount)
-		}
+// holdPower keeps the host awake while a session's VM runs, for sessions that
+// prevent sleep even when detached. The assertion is dropped by release.
+func (m *VZManager) holdPower(sess *session.Session) {
+	if sess.PreventSleep != PreventSleepAlways {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.power[sess.ID]; ok {
+		return
 	}
+	if assertion := acquirePowerAssertion(); assertion != nil {
+		m.power[sess.ID] = assertion
+	}
+}
 
+// newMachine builds the VM for a session. Create and Resume share it because a
+// saved state only restores into an identical configuration. kernelPath and
+// rootfsPath are the images it boots (see bootImages); overlay is the
+// project's persistent rootfs overlay disk and snapshot the rootfs snapshot
+// the session started from, if any.
+func (m *VZManager) newMachine(id, kernelPath, rootfsPath string, cpus int, memory string, mounts []session.VMMount, mac *vz.MACAddress, overlay, snapshot string) (*vz.VirtualMachine, *Console, error) {
 	// Create Linux boot loader
-	kernelPath := m.artifacts.KernelPath()
 	debugLog("Kernel path: %s", kernelPath)
 
 	// Check kernel file
@@ -275,7 +289,19 @@ func (m *VZManager) Create(cfg *Config) (*session.Session, error) {
 		debugLog("Kernel file size: %d bytes", info.Size())
 	}
 
+	// The scratch disk, if the session has one, exists until the session
+	// stops, so a resume finds it too
+	scratchPath := scratchDiskPath(m.artifacts.SessionDir(id))
+	_, err := os.Stat(scratchPath)
+	scratch := err == nil
+
 	cmdLine := "console=hvc0 root=/dev/vda ro rootwait init=/init"
+	if overlay != "" {
+		cmdLine += " faize.overlay=" + overlayDevice(scratch)
+	}
+	if snapshot != "" {
+		cmdLine += " faize.snapshot=" + overlayDevice(scratch)
+	}
 	if os.Getenv("FAIZE_DEBUG") != "1" {
 		cmdLine += " quiet loglevel=0"
 	}
@@ -286,21 +312,21 @@ func (m *VZManager) Create(cfg *Config) (*session.Session, error) {
 		vz.WithCommandLine(cmdLine),
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create boot loader: %w", err)
+		return nil, nil, fmt.Errorf("failed to create boot loader: %w", err)
 	}
 	debugLog("Boot loader created")
 
 	// Create VM configuration
-	memBytes := parseMemory(cfg.Memory)
-	debugLog("VM config: CPUs=%d, Memory=%d bytes (%s)", cfg.CPUs, memBytes, cfg.Memory)
+	memBytes := parseMemory(memory)
+	debugLog("VM config: CPUs=%d, Memory=%d bytes (%s)", cpus, memBytes, memory)
 
 	vmConfig, err := vz.NewVirtualMachineConfiguration(
 		bootLoader,
-		uint(cfg.CPUs),
+		uint(cpus),
 		memBytes,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create VM config: %w", err)
+		return nil, nil, fmt.Errorf("failed to create VM config: %w", err)
 	}
 	debugLog("VM configuration created")
 
@@ -309,17 +335,11 @@ func (m *VZManager) Create(cfg *Config) (*session.Session, error) {
 	debugLog("Configuring entropy device (first)...")
 	entropyDevice, err := vz.NewVirtioEntropyDeviceConfiguration()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create entropy device: %w", err)
+		return nil, nil, fmt.Errorf("failed to create entropy device: %w", err)
 	}
 	vmConfig.SetEntropyDevicesVirtualMachineConfiguration([]*vz.VirtioEntropyDeviceConfiguration{entropyDevice})
 
 	// Configure rootfs disk
-	var rootfsPath string
-	if cfg.ClaudeMode {
-		rootfsPath = m.artifacts.ClaudeRootfsPath()
-	} else {
-		rootfsPath = m.artifacts.RootfsPath()
-	}
 	debugLog("Rootfs path: %s", rootfsPath)
 
 	// Check rootfs file
@@ -334,20 +354,60 @@ func (m *VZManager) Create(cfg *Config) (*session.Session, error) {
 		true, // read-only: ephemeral overlay provides writes
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create disk attachment: %w", err)
+		return nil, nil, fmt.Errorf("failed to create disk attachment: %w", err)
 	}
 
 	blockDevice, err := vz.NewVirtioBlockDeviceConfiguration(diskAttachment)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create block device: %w", err)
+		return nil, nil, fmt.Errorf("failed to create block device: %w", err)
+	}
+	storageDevices := []vz.StorageDeviceConfiguration{blockDevice}
+
+	// Then the scratch disk (/dev/vdb) and the rootfs overlay disk or
+	// snapshot, in the order the guest expects them
+	if scratch {
+		debugLog("Scratch disk: %s", scratchPath)
+		scratchAttachment, err := vz.NewDiskImageStorageDeviceAttachment(scratchPath, false)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to attach scratch disk: %w", err)
+		}
+		scratchDevice, err := vz.NewVirtioBlockDeviceConfiguration(scratchAttachment)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create scratch block device: %w", err)
+		}
+		storageDevices = append(storageDevices, scratchDevice)
 	}
-	vmConfig.SetStorageDevicesVirtualMachineConfiguration([]vz.StorageDeviceConfiguration{blockDevice})
+	if overlay != "" {
+		debugLog("Rootfs overlay disk: %s", overlay)
+		overlayAttachment, err := vz.NewDiskImageStorageDeviceAttachment(overlay, false)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to attach rootfs overlay disk: %w", err)
+		}
+		overlayBlock, err := vz.NewVirtioBlockDeviceConfiguration(overlayAttachment)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create rootfs overlay block device: %w", err)
+		}
+		storageDevices = append(storageDevices, overlayBlock)
+	}
+	if snapshot != "" {
+		debugLog("Rootfs snapshot: %s", snapshot)
+		snapshotAttachment, err := vz.NewDiskImageStorageDeviceAttachment(snapshot, true)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to attach rootfs snapshot: %w", err)
+		}
+		snapshotBlock, err := vz.NewVirtioBlockDeviceConfiguration(snapshotAttachment)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create rootfs snapshot block device: %w", err)
+		}
+		storageDevices = append(storageDevices, snapshotBlock)
+	}
+	vmConfig.SetStorageDevicesVirtualMachineConfiguration(storageDevices)
 
 	// Configure console/serial
 	debugLog("Configuring serial console...")
 	console, serialConfig, err := createConsole()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create console: %w", err)
+		return nil, nil, fmt.Errorf("failed to create console: %w", err)
 	}
 	vmConfig.SetSerialPortsVirtualMachineConfiguration([]*vz.VirtioConsoleDeviceSerialPortConfiguration{serialConfig})
 
@@ -355,19 +415,36 @@ func (m *VZManager) Create(cfg *Config) (*session.Session, error) {
 	debugLog("Configuring NAT network...")
 	natAttachment, err := vz.NewNATNetworkDeviceAttachment()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create NAT attachment: %w", err)
+		return nil, nil, fmt.Errorf("failed to create NAT attachment: %w", err)
 	}
 	networkDevice, err := vz.NewVirtioNetworkDeviceConfiguration(natAttachment)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create network device: %w", err)
+		return nil, nil, fmt.Errorf("failed to create network device: %w", err)
 	}
+	networkDevice.SetMACAddress(mac)
 	vmConfig.SetNetworkDevicesVirtualMachineConfiguration([]*vz.VirtioNetworkDeviceConfiguration{networkDevice})
 
+	// Configure vsock control channel for faize exec
+	debugLog("Configuring vsock device...")
+	socketDevice, err := vz.NewVirtioSocketDeviceConfiguration()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create vsock device: %w", err)
+	}
+	vmConfig.SetSocketDevicesVirtualMachineConfiguration([]vz.SocketDeviceConfiguration{socketDevice})
+
+	// Configure memory balloon so idle guests can hand memory back
+	debugLog("Configuring memory balloon...")
+	balloonDevice, err := vz.NewVirtioTraditionalMemoryBalloonDeviceConfiguration()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create memory balloon: %w", err)
+	}
+	vmConfig.SetMemoryBalloonDevicesVirtualMachineConfiguration([]vz.MemoryBalloonDeviceConfiguration{balloonDevice})
+
 	// Configure VirtioFS mounts (last - optional)
 	debugLog("Configuring VirtioFS mounts...")
-	fsDevices, err := createVirtioFSDevices(allMounts)
+	fsDevices, err := createVirtioFSDevices(mounts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create VirtioFS devices: %w", err)
+		return nil, nil, fmt.Errorf("failed to create VirtioFS devices: %w", err)
 	}
 	vmConfig.SetDirectorySharingDevicesVirtualMachineConfiguration(fsDevices)
 
@@ -376,11 +453,11 @@ func (m *VZManager) Create(cfg *Config) (*session.Session, error) {
 	valid, err := vmConfig.Validate()
 	if err != nil {
 		debugLog("Validation error: %v", err)
-		return nil, fmt.Errorf("invalid VM configuration: %w", err)
+		return nil, nil, fmt.Errorf("invalid VM configuration: %w", err)
 	}
 	if !valid {
 		debugLog("Validation returned false")
-		return nil, fmt.Errorf("VM configuration validation failed")
+		return nil, nil, fmt.Errorf("VM configuration validation failed")
 	}
 	debugLog("VM configuration valid")
 
@@ -389,7 +466,7 @@ func (m *VZManager) Create(cfg *Config) (*session.Session, error) {
 	vm, err := vz.NewVirtualMachine(vmConfig)
 	if err != nil {
 		debugLog("VM creation error: %v", err)
-		return nil, fmt.Errorf("failed to create virtual machine: %w", err)
+		return nil, nil, fmt.Errorf("failed to create virtual machine: %w", err)
 	}
 	debugLog("Virtual machine created")
 
@@ -411,45 +488,7 @@ func (m *VZManager) Create(cfg *Config) (*session.Session, error) {
 		}
 	}()
 
-	// Create session
-	sess := &session.Session{
-		ID:         id,
-		ProjectDir: cfg.ProjectDir,
-		Mounts:     cfg.Mounts,
-		Network:    cfg.Network,
-		CPUs:       cfg.CPUs,
-		Memory:     cfg.Memory,
-		Status:     "created",
-		StartedAt:  time.Now(),
-		ClaudeMode: cfg.ClaudeMode,
-	}
-
-	// Store VM and console
-	m.mu.Lock()
-	m.vms[id] = vm
-	m.consoles[id] = console
-
-	// Create and start console proxy server
-	proxy, err := NewConsoleProxyServer(id, console)
-	if err != nil {
-		debugLog("Failed to create console proxy: %v", err)
-	} else {
-		if err := proxy.Start(); err != nil {
-			debugLog("Failed to start console proxy: %v", err)
-		} else {
-			m.proxies[id] = proxy
-			debugLog("Console proxy started at %s", proxy.SocketPath())
-		}
-	}
-
-	m.mu.Unlock()
-
-	// Persist session
-	if err := m.sessions.Save(sess); err != nil {
-		return nil, fmt.Errorf("failed to save session: %w", err)
-	}
-
-	return sess, nil
+	return vm, console, nil
 }
 
 // Start boots the VM
@@ -465,28 +504,27 @@ func (m *VZManager) Start(sess *session.Session) error {
 		return fmt.Errorf("VM not found: %s", sess.ID)
 	}
 
-	// Pre-start validation
-	debugLog("Running pre-start validation...")
-	if err := validateKernelFile(m.artifacts.KernelPath()); err != nil {
-		return fmt.Errorf("kernel validation failed: %w", err)
+	if err := validateArtifacts(m.artifacts, sess); err != nil {
+		return err
 	}
 
-	// Validate the correct rootfs based on mode
-	rootfsToValidate := m.artifacts.RootfsPath()
-	if sess.ClaudeMode {
-		rootfsToValidate = m.artifacts.ClaudeRootfsPath()
-	}
-	if err := validateRootfs(rootfsToValidate); err != nil {
-		return fmt.Errorf("rootfs validation failed: %w", err)
+	forwarder, err := m.startForwarder(sess)
+	if err != nil {
+		return err
 	}
 
 	debugLog("Calling vm.Start()...")
 	if err := vm.Start(); err != nil {
 		debugLog("vm.Start() error: %v", err)
+		if forwarder != nil {
+			forwarder.Stop()
+		}
 		// Capture VZ framework logs for diagnostics
 		captureVZLogs()
 		return fmt.Errorf("failed to start VM: %w", err)
 	}
+	m.startServices(sess, vm, forwarder)
+	m.holdPower(sess)
 	debugLog("vm.Start() succeeded")
 
 	// Update session status
@@ -498,31 +536,109 @@ func (m *VZManager) Start(sess *session.Session) error {
 	return nil
 }
 
-// Stop stops a running VM
-func (m *VZManager) Stop(id string) error {
-	m.mu.Lock()
-	vm, ok := m.vms[id]
-	if !ok {
+// startForwarder publishes a session's guest ports on the host; the guest is
+// reached on the NAT network. Returns nil if no ports are published.
+func (m *VZManager) startForwarder(sess *session.Session) (*PortForwarder, error) {
+	forwards := sessionForwards(sess)
+	if len(forwards) == 0 {
+		return nil, nil
+	}
+	return StartPortForwarder(forwards, guestIPReader(bootstrapPath(m.artifacts.SessionDir(sess.ID))))
+}
+
+// startServices wires up the host side of a running VM: port forwards, the
+// guest watchdog heartbeat, the memory balloon, the exec proxy and the egress
+// proxy
+func (m *VZManager) startServices(sess *session.Session, vm *vz.VirtualMachine, forwarder *PortForwarder) {
+	id := sess.ID
+	if forwarder != nil {
+		m.mu.Lock()
+		m.forwards[id] = forwarder
 		m.mu.Unlock()
-		// Try to load from store and update status
-		sess, err := m.sessions.Load(id)
+	}
+
+	// Keep the guest watchdog fed and track peak usage while this process owns the VM
+	alive := func() bool {
+		state := vm.State()
+		return state != vz.VirtualMachineStateStopped && state != vz.VirtualMachineStateError
+	}
+	go runHeartbeat(bootstrapPath(m.artifacts.SessionDir(id)), alive)
+	go runUsageSampler(m.sessions, id, bootstrapPath(m.artifacts.SessionDir(id)), alive)
+
+	// Reclaim memory the guest isn't using while it is idle
+	if devices := vm.MemoryBalloonDevices(); len(devices) > 0 {
+		if device := vz.AsVirtioTraditionalMemoryBalloonDevice(devices[0]); device != nil {
+			_, size := sessionSize(sess)
+			limit := sessionMemory(m.sessions, id, parseMemory(size))
+			go runBalloon(bootstrapPath(m.artifacts.SessionDir(id)), limit, alive, func(target uint64) error {
+				device.SetTargetVirtualMachineMemorySize(target)
+				return nil
+			})
+		}
+	}
+
+	// Forward faize exec clients to the guest agent over vsock
+	if devices := vm.SocketDevices(); len(devices) > 0 {
+		device := devices[0]
+		if proxy := startExecProxy(id, func() (io.ReadWriteCloser, error) {
+			return device.Connect(guest.ExecPort)
+		}); proxy != nil {
+			m.mu.Lock()
+			m.execs[id] = proxy
+			m.mu.Unlock()
+		}
+
+		// Enforce the allowlist for traffic the guest sends to the egress proxy
+		proxy, err := startEgressProxy(m.sessions, sess, m.artifacts.SessionDir(id), m.consoleAttached(id), func(port uint32) (net.Listener, error) {
+			return device.Listen(port)
+		})
 		if err != nil {
-			return fmt.Errorf("session not found: %s", id)
+			debugLog("Failed to start egress proxy: %v", err)
+			m.Warn(id, fmt.Sprintf("egress proxy not started, so the session has no network access: %v", err))
+		} else if proxy != nil {
+			m.mu.Lock()
+			m.egress[id] = proxy
+			m.mu.Unlock()
 		}
-		sess.Status = "stopped"
-		return m.sessions.Save(sess)
 	}
+}
 
-	delete(m.vms, id)
-	delete(m.consoles, id)
+// consoleAttached reports whether a client is attached to a session's console
+func (m *VZManager) consoleAttached(id string) func() bool {
+	return func() bool {
+		m.mu.RLock()
+		proxy := m.proxies[id]
+		m.mu.RUnlock()
+		return proxy != nil && proxy.Attached()
+	}
+}
 
-	// Stop and remove proxy
-	if proxy, ok := m.proxies[id]; ok {
-		_ = proxy.Stop()
-		delete(m.proxies, id)
+// Warn shows a warning to the user of a session this process owns
+func (m *VZManager) Warn(id, message string) bool {
+	m.mu.RLock()
+	_, owned := m.vms[id]
+	proxy := m.proxies[id]
+	m.mu.RUnlock()
+	if !owned {
+		return false
 	}
+	warnSession(m.sessions, proxy, id, message)
+	return true
+}
 
-	m.mu.Unlock()
+// egressAvailable reports whether guests can reach a host egress proxy;
+// every VZ guest has a vsock device
+func egressAvailable() bool {
+	return true
+}
+
+// Stop stops a running VM
+func (m *VZManager) Stop(id string) error {
+	vm, ok := m.release(id)
+	if !ok {
+		// Not owned by this process: stop via the detached owner, if any
+		return stopUnowned(m.sessions, id)
+	}
 
 	// Check if VM is already stopped
 	if vm.State() == vz.VirtualMachineStateStopped || vm.State() == vz.VirtualMachineStateError {
@@ -530,10 +646,12 @@ func (m *VZManager) Stop(id string) error {
 		sess, err := m.sessions.Load(id)
 		if err == nil {
 			sess.Status = "stopped"
+			sess.PID = 0
 			if saveErr := m.sessions.Save(sess); saveErr != nil {
 				debugLog("Failed to save session state: %v", saveErr)
 			}
 		}
+		removeScratchDisk(id)
 		return nil
 	}
 
@@ -561,82 +679,165 @@ func (m *VZManager) Stop(id string) error {
 	sess, err := m.sessions.Load(id)
 	if err == nil {
 		sess.Status = "stopped"
+		sess.PID = 0
 		if saveErr := m.sessions.Save(sess); saveErr != nil {
 			debugLog("Failed to save session state: %v", saveErr)
 		}
 	}
+	removeScratchDisk(id)
 
 	return nil
 }
 
-// List returns all sessions
-func (m *VZManager) List() ([]*session.Session, error) {
-	return m.sessions.List()
-}
+// Suspend pauses a running VM, saves its state into the session directory and
+// releases it. The session is left "paused" until Resume restores it.
+func (m *VZManager) Suspend(id string) error {
+	m.mu.RLock()
+	vm, ok := m.vms[id]
+	m.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("VM not found: %s", id)
+	}
 
-// Attach connects to the VM console
-// Always uses socket-based attach via proxy to ensure consistent behavior
-// between initial attach (faize claude start) and reattach (faize claude attach).
-// This prevents the bug where in-memory io.Copy goroutines would continue
-// consuming console output after detach, starving subsequent proxy clients.
-func (m *VZManager) Attach(id string) error {
-	socketPath := m.GetProxySocketPath(id)
+	sess, err := m.sessions.Load(id)
+	if err != nil {
+		return fmt.Errorf("session not found: %s", id)
+	}
 
-	// Wait briefly for proxy to be ready (relevant for fresh start)
-	for range 10 {
-		if _, err := os.Stat(socketPath); err == nil {
-			break
+	if !vm.CanPause() {
+		return fmt.Errorf("VM cannot be paused in state %v", vm.State())
+	}
+	debugLog("Pausing VM for session %s...", id)
+	if err := vm.Pause(); err != nil {
+		return fmt.Errorf("failed to pause VM: %w", err)
+	}
+
+	statePath := filepath.Join(m.artifacts.SessionDir(id), machineStateFile)
+	debugLog("Saving VM state to %s...", statePath)
+	if err := saveMachineState(vm, statePath); err != nil {
+		_ = os.Remove(statePath)
+		if resumeErr := vm.Resume(); resumeErr != nil {
+			debugLog("Failed to resume VM after failed save: %v", resumeErr)
 		}
-		time.Sleep(50 * time.Millisecond)
+		return fmt.Errorf("failed to save VM state: %w", err)
 	}
 
-	if _, err := os.Stat(socketPath); err != nil {
-		return fmt.Errorf("console not found for session: %s (VM may have stopped)", id)
+	m.release(id)
+	if err := vm.Stop(); err != nil {
+		debugLog("Failed to stop saved VM: %v", err)
 	}
 
-	client, err := NewConsoleClient(socketPath)
+	sess.Status = "paused"
+	sess.PID = 0
+	PauseDeadline(sess)
+	if err := m.sessions.Save(sess); err != nil {
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+	return nil
+}
+
+// Resume rebuilds a paused session's VM from its recorded configuration,
+// restores the saved state and lets it run again
+func (m *VZManager) Resume(id string) (*session.Session, error) {
+	sess, err := m.sessions.Load(id)
 	if err != nil {
-		// Connection failed - socket is stale (process crashed)
-		// Clean up the orphaned socket file
-		_ = os.Remove(socketPath)
+		return nil, fmt.Errorf("session not found: %s", id)
+	}
+	if sess.Status != "paused" {
+		return nil, fmt.Errorf("session %s is %s, not paused", id, sess.Status)
+	}
 
-		// Update session status to stopped
-		if sess, loadErr := m.sessions.Load(id); loadErr == nil {
-			sess.Status = "stopped"
-			if saveErr := m.sessions.Save(sess); saveErr != nil {
-				debugLog("Failed to save session state: %v", saveErr)
-			}
-		}
+	statePath := filepath.Join(m.artifacts.SessionDir(id), machineStateFile)
+	if _, err := os.Stat(statePath); err != nil {
+		return nil, fmt.Errorf("no saved state for session %s", id)
+	}
+	if err := checkHostPorts(sessionForwards(sess)); err != nil {
+		return nil, err
+	}
 
-		return fmt.Errorf("session %s is no longer running (cleaned up stale socket)", id)
+	hwAddr, err := net.ParseMAC(sess.MACAddress)
+	if err != nil {
+		return nil, fmt.Errorf("session %s has no recorded MAC address", id)
+	}
+	mac, err := vz.NewMACAddress(hwAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create MAC address: %w", err)
 	}
-	defer func() { _ = client.Close() }()
 
-	// Set up terminal resize propagation via VirtioFS termsize file
-	termsizePath := filepath.Join(m.artifacts.SessionDir(id), "bootstrap", "termsize")
-	client.SetTermsizePath(termsizePath)
+	cpus, memory := sessionSize(sess)
+	kernelPath, rootfsPath := bootImages(m.artifacts, sess.Kernel, sess.Rootfs, sess.Flavor, sess.ClaudeMode)
+	vm, console, err := m.newMachine(id, kernelPath, rootfsPath, cpus, memory, orderShares(sess.Mounts, sess.SystemMounts), mac, sess.Overlay, sess.Snapshot)
+	if err != nil {
+		return nil, err
+	}
 
-	// Set up clipboard sync via VirtioFS clipboard directory
-	clipboardDir := filepath.Join(m.artifacts.SessionDir(id), "bootstrap", "clipboard")
-	client.SetClipboardDir(clipboardDir)
+	// On failure the saved state is kept so the session can still be resumed
+	discard := func() {
+		if vm.CanStop() {
+			_ = vm.Stop()
+		}
+		_ = console.Close()
+	}
 
-	// Set up URL open watcher via VirtioFS bootstrap directory
-	client.SetOpenURLDir(filepath.Join(m.artifacts.SessionDir(id), "bootstrap"))
+	debugLog("Restoring VM state from %s...", statePath)
+	if err := restoreMachineState(vm, statePath); err != nil {
+		discard()
+		return nil, fmt.Errorf("failed to restore VM state: %w", err)
+	}
 
-	// Write current terminal size immediately (handles reattach from different-sized terminal)
-	if term.IsTerminal(int(os.Stdout.Fd())) {
-		if w, h, err := term.GetSize(int(os.Stdout.Fd())); err == nil && w > 0 && h > 0 {
-			_ = os.WriteFile(termsizePath, []byte(fmt.Sprintf("%d %d", w, h)), 0644)
+	forwarder, err := m.startForwarder(sess)
+	if err != nil {
+		discard()
+		return nil, err
+	}
+	m.register(id, vm, console)
+
+	if err := vm.Resume(); err != nil {
+		m.release(id)
+		if forwarder != nil {
+			forwarder.Stop()
 		}
+		discard()
+		return nil, fmt.Errorf("failed to resume VM: %w", err)
+	}
+	m.startServices(sess, vm, forwarder)
+	m.holdPower(sess)
+
+	// The state is consumed: resuming it again would fork the session
+	_ = os.Remove(statePath)
+
+	sess.Status = "running"
+	ResumeDeadline(sess)
+	if err := m.sessions.Save(sess); err != nil {
+		return nil, fmt.Errorf("failed to update session: %w", err)
 	}
+	return sess, nil
+}
 
-	return client.Attach(os.Stdin, os.Stdout)
+// List returns all sessions
+func (m *VZManager) List() ([]*session.Session, error) {
+	return m.sessions.List()
+}
+
+// Attach connects to the VM console
+// Always uses socket-based attach via proxy to ensure consistent behavior
+// between initial attach (faize claude start) and reattach (faize claude attach).
+// This prevents the bug where in-memory io.Copy goroutines would continue
+// consuming console output after detach, starving subsequent proxy clients.
+//
+// Sessions that prevent sleep while attached hold a power assertion for as
+// long as the console is attached, in whichever process is attached.
+func (m *VZManager) Attach(id string) error {
+	if sess, err := m.sessions.Load(id); err == nil && sess.PreventSleep == PreventSleepAttached {
+		assertion := acquirePowerAssertion()
+		defer assertion.release()
+	}
+	return attachConsole(m.sessions, m.artifacts.SessionDir(id), m.GetProxySocketPath(id), id)
 }
 
 // GetProxySocketPath returns the socket path for a session's proxy
 func (m *VZManager) GetProxySocketPath(id string) string {
-	homeDir, _ := os.UserHomeDir()
-	return filepath.Join(homeDir, ".faize", "sessions", fmt.Sprintf("%s.sock", id))
+	return proxySocketPath(id)
 }
 
 // WaitForVMStop blocks until the VM stops or an error occurs
@@ -664,19 +865,3 @@ func (m *VZManager) WaitForVMStop(id string) <-chan struct{} {
 
 	return done
 }
-
-// parseMemory converts memory string like "4GB" to bytes
-func parseMemory(mem string) uint64 {
-	var size uint64
-	var unit string
-	_, _ = fmt.Sscanf(mem, "%d%s", &size, &unit)
-
-	switch unit {
-	case "GB", "G":
-		return size * 1024 * 1024 * 1024
-	case "MB", "M":
-		return size * 1024 * 1024
-	default:
-		return 4 * 1024 * 1024 * 1024 // Default 4GB
-	}
-}
@@ -1,10 +1,15 @@
-//go:build !darwin
+//go:build !darwin && !linux
 
 package vm
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"os"
+	"time"
 
+	"github.com/faize-ai/faize/internal/guest"
 	"github.com/faize-ai/faize/internal/session"
 )
 
@@ -16,6 +21,19 @@ func NewVZManager() (*VZManager, error) {
 	return nil, fmt.Errorf("virtualization.framework is only available on macOS")
 }
 
+// NewManager returns an error on platforms without a VM backend
+func NewManager() (Manager, error) {
+	return nil, fmt.Errorf("VM support requires macOS or Linux")
+}
+
+// buildVMAvailable reports that builder VMs need a VM backend
+func buildVMAvailable() error {
+	return fmt.Errorf("VM support requires macOS or Linux")
+}
+
+// warmHotplug is unset on platforms without a VM backend
+const warmHotplug = false
+
 // Create is not implemented on non-macOS
 func (m *VZManager) Create(cfg *Config) (*session.Session, error) {
 	return nil, fmt.Errorf("VM support requires macOS")
@@ -47,3 +65,59 @@ func (m *VZManager) WaitForVMStop(id string) <-chan struct{} {
 	close(ch) // Immediately returns for stub
 	return ch
 }
+
+// Exec is not implemented on platforms without a VM backend
+func Exec(id string, req *guest.ExecRequest, stdout, stderr io.Writer) (int, error) {
+	return -1, fmt.Errorf("VM support requires macOS or Linux")
+}
+
+// ExecContext is not implemented on platforms without a VM backend
+func ExecContext(ctx context.Context, id string, req *guest.ExecRequest, stdout, stderr io.Writer) (int, error) {
+	return -1, fmt.Errorf("VM support requires macOS or Linux")
+}
+
+// ReconcileSessions does nothing on platforms without a VM backend
+func ReconcileSessions(sessions *session.Store) ([]*session.Session, error) {
+	return nil, nil
+}
+
+// NotifyPause does nothing on platforms without a VM backend
+func NotifyPause(c chan<- os.Signal) {}
+
+// Pause is not implemented on platforms without a VM backend
+func Pause(sessions *session.Store, id string) error {
+	return fmt.Errorf("VM support requires macOS or Linux")
+}
+
+// RecordPauseError does nothing on platforms without a VM backend
+func RecordPauseError(id string, pauseErr error) {}
+
+// IdleWarm returns no sessions on platforms without a VM backend
+func IdleWarm(sessions *session.Store) ([]*session.Session, error) {
+	return nil, nil
+}
+
+// ClaimWarm finds no warm sessions on platforms without a VM backend
+func ClaimWarm(sessions *session.Store, key string, claim *WarmClaim) (*session.Session, error) {
+	return nil, ErrNoWarmSession
+}
+
+// CopyToGuest is not implemented on platforms without a VM backend
+func CopyToGuest(id, src, dst string) error {
+	return fmt.Errorf("VM support requires macOS or Linux")
+}
+
+// CopyFromGuest is not implemented on platforms without a VM backend
+func CopyFromGuest(id, src, dst string) error {
+	return fmt.Errorf("VM support requires macOS or Linux")
+}
+
+// Shutdown is not implemented on platforms without a VM backend
+func Shutdown(sessions *session.Store, id string, timeout time.Duration) error {
+	return fmt.Errorf("VM support requires macOS or Linux")
+}
+
+// egressAvailable reports false: there is no VM backend on this platform
+func egressAvailable() bool {
+	return false
+}
@@ -0,0 +1,152 @@
+package vm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/faize-ai/faize/internal/session"
+)
+
+// ErrNoWarmSession is returned by ClaimWarm when no idle warm VM matches
+var ErrNoWarmSession = errors.New("no idle warm session")
+
+// warmClaimFile marks a warm session as taken so concurrent starts can't both claim it
+const warmClaimFile = "warm-claimed"
+
+// warmShareSlots is how many shares can be added to a warm VM for its claim
+const warmShareSlots = 8
+
+// WarmNeedsRoot reports whether warm VMs share a root directory that claims
+// bind their mounts from, because the hypervisor can't add shares to a
+// running VM. Elsewhere only the claimed mounts are shared, when claimed.
+func WarmNeedsRoot() bool {
+	return !warmHotplug
+}
+
+// WarmClaim is what a start hands an idle warm VM: the guest's claim and
+// the host directories shared for it
+type WarmClaim struct {
+	*guest.Claim
+	Mounts []session.VMMount // added to the VM before the claim is written, one per share
+}
+
+// WarmKey identifies the configuration a VM boots with apart from the project:
+// resources, scratch disk, network policy, bandwidth limit, resolvers, hosts entries, the
+// upstream proxy, strict mode, CA certificates, and faize's own shares. A start can only claim a warm VM
+// booted with the same key.
+func WarmKey(cfg *Config, root string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "root=%s\ncpus=%d\nmemory=%s\nnetwork=%s\nwatchdog=%s\n",
+		root, cfg.CPUs, cfg.Memory, strings.Join(cfg.Network, ","), cfg.Watchdog)
+	if maxCPUs, maxMemory := vmSize(cfg.CPUs, cfg.MaxCPUs, cfg.Memory, cfg.MaxMemory); maxCPUs != cfg.CPUs || maxMemory != cfg.Memory {
+		fmt.Fprintf(h, "size=%d/%s\n", maxCPUs, maxMemory)
+	}
+	if cfg.Disk != "" {
+		fmt.Fprintf(h, "disk=%s\n", cfg.Disk)
+	}
+	fmt.Fprintf(h, "claude=%s\ntoolchain=%s\ncredentials=%s\n",
+		cfg.HostClaudeDir, cfg.ToolchainDir, cfg.CredentialsDir)
+	if cfg.NetLimit != 0 {
+		fmt.Fprintf(h, "netlimit=%d\n", cfg.NetLimit)
+	}
+	if len(cfg.Resolvers) > 0 {
+		fmt.Fprintf(h, "resolvers=%s\n", strings.Join(cfg.Resolvers, ","))
+	}
+	for _, entry := range cfg.Hosts {
+		fmt.Fprintf(h, "host=%s\n", entry)
+	}
+	if cfg.Proxy != "" {
+		fmt.Fprintf(h, "proxy=%s\nnoproxy=%s\n", cfg.Proxy, strings.Join(cfg.NoProxy, ","))
+	}
+	if cfg.StrictNetwork {
+		fmt.Fprintf(h, "strict\n")
+	}
+	for _, path := range cfg.CACerts {
+		fmt.Fprintf(h, "cacert=%s\n", path)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// NewWarmClaim builds the claim that hands cfg's project to a warm VM sharing
+// root, or with root empty, to one that is given a share per mount. Every
+// user mount becomes a bind from under the guest's warm root; the Claude and
+// toolchain shares are already mounted. It fails when the session needs
+// something a running VM can't add, such as a mount outside root or
+// published ports.
+func NewWarmClaim(cfg *Config, root string) (*WarmClaim, error) {
+	if len(cfg.Publish) > 0 {
+		return nil, fmt.Errorf("published ports need a new VM")
+	}
+	if cfg.CaptureNetwork {
+		return nil, fmt.Errorf("network capture needs a new VM")
+	}
+	if cfg.Overlay != "" {
+		return nil, fmt.Errorf("a persistent rootfs overlay needs a new VM")
+	}
+	if cfg.Home != "" {
+		return nil, fmt.Errorf("a persistent home needs a new VM")
+	}
+	if cfg.Snapshot != "" {
+		return nil, fmt.Errorf("a rootfs snapshot needs a new VM")
+	}
+	if cfg.Kernel != "" || cfg.Rootfs != "" {
+		return nil, fmt.Errorf("a custom kernel or rootfs needs a new VM")
+	}
+	if cfg.Flavor != "" {
+		return nil, fmt.Errorf("a %s rootfs needs a new VM", cfg.Flavor)
+	}
+	if len(cfg.PostCreate) > 0 {
+		return nil, fmt.Errorf("a devcontainer postCreateCommand needs a new VM")
+	}
+
+	if root != "" {
+		root = resolvePath(root)
+	}
+	claim := &WarmClaim{Claim: &guest.Claim{ProjectDir: cfg.ProjectDir, SyncSettings: cfg.SyncSettings, SyncSkills: cfg.SyncSkills}}
+	for _, m := range cfg.Mounts {
+		if m.Source == cfg.HostClaudeDir || m.Source == cfg.ToolchainDir {
+			continue
+		}
+		if m.Target == guest.WarmRoot || strings.HasPrefix(m.Target, guest.WarmRoot+"/") {
+			return nil, fmt.Errorf("mount target %s is reserved for warm VMs", m.Target)
+		}
+		if root == "" {
+			if len(claim.Mounts) == warmShareSlots {
+				return nil, fmt.Errorf("more than %d mounts need a new VM", warmShareSlots)
+			}
+			share := guest.Share{
+				Tag:  fmt.Sprintf("warm%d", len(claim.Mounts)),
+				Path: path.Join(guest.WarmRoot, strconv.Itoa(len(claim.Mounts))),
+			}
+			claim.Shares = append(claim.Shares, share)
+			claim.Mounts = append(claim.Mounts, session.VMMount{Source: m.Source, Target: share.Path, ReadOnly: m.ReadOnly, Tag: share.Tag})
+			claim.Binds = append(claim.Binds, guest.Bind{Source: share.Path, Target: m.Target, ReadOnly: m.ReadOnly})
+			continue
+		}
+		rel, err := filepath.Rel(root, resolvePath(m.Source))
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("%s is outside the warm root %s", m.Source, root)
+		}
+		claim.Binds = append(claim.Binds, guest.Bind{
+			Source:   path.Join(guest.WarmRoot, filepath.ToSlash(rel)),
+			Target:   m.Target,
+			ReadOnly: m.ReadOnly,
+		})
+	}
+	return claim, nil
+}
+
+// resolvePath returns p with symlinks resolved, or p cleaned if it can't be resolved
+func resolvePath(p string) string {
+	if resolved, err := filepath.EvalSymlinks(p); err == nil {
+		return resolved
+	}
+	return filepath.Clean(p)
+}
@@ -0,0 +1,153 @@
+package vm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/faize-ai/faize/internal/guest"
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWarmKey(t *testing.T) {
+	base := &Config{CPUs: 2, Memory: "4GB", Network: []string{"npm"}, Watchdog: 5 * time.Minute, HostClaudeDir: "/h/.claude"}
+	key := WarmKey(base, "/h")
+
+	same := *base
+	same.ProjectDir = "/h/other"
+	same.Mounts = []session.VMMount{{Source: "/h/other", Target: "/h/other"}}
+	same.Timeout = time.Hour
+	assert.Equal(t, key, WarmKey(&same, "/h"), "project and timeout don't affect the VM")
+
+	memory := *base
+	memory.Memory = "8GB"
+	assert.NotEqual(t, key, WarmKey(&memory, "/h"))
+
+	network := *base
+	network.Network = []string{"npm", "pypi"}
+	assert.NotEqual(t, key, WarmKey(&network, "/h"))
+
+	creds := *base
+	creds.CredentialsDir = "/h/.faize/credentials"
+	assert.NotEqual(t, key, WarmKey(&creds, "/h"))
+
+	limited := *base
+	limited.NetLimit = 10_000_000
+	assert.NotEqual(t, key, WarmKey(&limited, "/h"), "a bandwidth limit is applied at boot")
+
+	resolvers := *base
+	resolvers.Resolvers = []string{"10.0.0.53"}
+	assert.NotEqual(t, key, WarmKey(&resolvers, "/h"))
+
+	hosts := *base
+	hosts.Hosts = []session.HostEntry{{Name: "db.local", IP: "10.0.0.5"}}
+	assert.NotEqual(t, key, WarmKey(&hosts, "/h"), "hosts entries are written at boot")
+
+	proxied := *base
+	proxied.Proxy = "http://proxy.corp.example:3128"
+	assert.NotEqual(t, key, WarmKey(&proxied, "/h"))
+
+	strict := *base
+	strict.StrictNetwork = true
+	assert.NotEqual(t, key, WarmKey(&strict, "/h"))
+
+	certs := *base
+	certs.CACerts = []string{"/h/corp-ca.pem"}
+	assert.NotEqual(t, key, WarmKey(&certs, "/h"))
+
+	assert.NotEqual(t, key, WarmKey(base, "/h/code"))
+}
+
+func TestNewWarmClaim(t *testing.T) {
+	root := t.TempDir()
+	project := filepath.Join(root, "code", "app")
+	require.NoError(t, os.MkdirAll(project, 0755))
+	root = resolvePath(root)
+	project = resolvePath(project)
+
+	cfg := &Config{
+		ProjectDir:    project,
+		HostClaudeDir: "/elsewhere/.claude",
+		ToolchainDir:  "/elsewhere/toolchain",
+		Mounts: []session.VMMount{
+			{Source: project, Target: project},
+			{Source: "/elsewhere/.claude", Target: "/mnt/host-claude", ReadOnly: true},
+			{Source: "/elsewhere/toolchain", Target: "/opt/toolchain"},
+			{Source: filepath.Join(root, "code", ".git"), Target: filepath.Join(root, "code", ".git"), ReadOnly: true},
+		},
+	}
+
+	claim, err := NewWarmClaim(cfg, root)
+	require.NoError(t, err)
+	assert.Equal(t, project, claim.ProjectDir)
+	assert.Equal(t, []guest.Bind{
+		{Source: "/mnt/warm/code/app", Target: project},
+		{Source: "/mnt/warm/code/.git", Target: filepath.Join(root, "code", ".git"), ReadOnly: true},
+	}, claim.Binds)
+	assert.False(t, claim.SyncSettings)
+
+	// Settings sync is decided per session, so the claim carries it
+	cfg.SyncSettings, cfg.SyncSkills = true, true
+	claim, err = NewWarmClaim(cfg, root)
+	require.NoError(t, err)
+	assert.True(t, claim.SyncSettings)
+	assert.True(t, claim.SyncSkills)
+	assert.Empty(t, claim.Shares)
+}
+
+func TestNewWarmClaim_Shares(t *testing.T) {
+	cfg := &Config{
+		ProjectDir:    "/h/code/app",
+		HostClaudeDir: "/h/.claude",
+		Mounts: []session.VMMount{
+			{Source: "/h/code/app", Target: "/h/code/app"},
+			{Source: "/h/.claude", Target: "/mnt/host-claude", ReadOnly: true},
+			{Source: "/h/code/.git", Target: "/h/code/.git", ReadOnly: true},
+		},
+	}
+
+	// Without a shared root, each mount is added to the VM as its own share
+	claim, err := NewWarmClaim(cfg, "")
+	require.NoError(t, err)
+	assert.Equal(t, []guest.Share{
+		{Tag: "warm0", Path: "/mnt/warm/0"},
+		{Tag: "warm1", Path: "/mnt/warm/1"},
+	}, claim.Shares)
+	assert.Equal(t, []session.VMMount{
+		{Source: "/h/code/app", Target: "/mnt/warm/0", Tag: "warm0"},
+		{Source: "/h/code/.git", Target: "/mnt/warm/1", Tag: "warm1", ReadOnly: true},
+	}, claim.Mounts)
+	assert.Equal(t, []guest.Bind{
+		{Source: "/mnt/warm/0", Target: "/h/code/app"},
+		{Source: "/mnt/warm/1", Target: "/h/code/.git", ReadOnly: true},
+	}, claim.Binds)
+
+	for i := range warmShareSlots {
+		cfg.Mounts = append(cfg.Mounts, session.VMMount{Source: fmt.Sprintf("/h/m%d", i), Target: fmt.Sprintf("/m%d", i)})
+	}
+	_, err = NewWarmClaim(cfg, "")
+	assert.ErrorContains(t, err, "need a new VM")
+}
+
+func TestNewWarmClaim_Ineligible(t *testing.T) {
+	root := t.TempDir()
+
+	tests := map[string]*Config{
+		"mount outside root": {Mounts: []session.VMMount{{Source: "/outside", Target: "/outside"}}},
+		"published ports":    {Publish: []session.PortForward{{HostPort: 3000, GuestPort: 3000}}},
+		"network capture":    {CaptureNetwork: true},
+		"reserved target":    {Mounts: []session.VMMount{{Source: root, Target: "/mnt/warm/x"}}},
+		"custom rootfs":      {Rootfs: "/images/alpine.img"},
+		"rootfs flavor":      {Flavor: "debian"},
+	}
+	for name, cfg := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewWarmClaim(cfg, root)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,466 @@
+// Package vmtest provides an in-memory vm.Manager for testing code that
+// drives faize sessions without booting VMs.
+package vmtest
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/faize-ai/faize/internal/changeset"
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/faize-ai/faize/internal/vm"
+)
+
+// Op names a Manager operation, for fault injection and call records
+type Op string
+
+// Operations of a FakeManager
+const (
+	OpCreate  Op = "create"
+	OpStart   Op = "start"
+	OpStop    Op = "stop"
+	OpAttach  Op = "attach"
+	OpSuspend Op = "suspend"
+	OpResume  Op = "resume"
+)
+
+// Call records one operation on a FakeManager. ID is empty for OpCreate.
+type Call struct {
+	Op Op
+	ID string
+}
+
+// FakeManager is an in-memory vm.Manager and vm.Suspender. Sessions move
+// through the same statuses as with a real backend; console output, guest
+// exits, changesets, and failures are scripted by the test.
+type FakeManager struct {
+	// Console receives console output while a session is attached; nil discards it
+	Console io.Writer
+	// Store, when set, receives every session change, and a session's
+	// scripted changeset is written to <id>/bootstrap/changeset.json in the
+	// store's directory when it stops, as the start command does
+	Store *session.Store
+	// Hook, when set, runs before every operation; an error fails the operation
+	Hook func(op Op, id string) error
+
+	mu     sync.Mutex
+	vms    map[string]*fakeVM
+	order  []string
+	faults map[Op][]error
+	calls  []Call
+	nextID int
+}
+
+// fakeVM is one simulated VM
+type fakeVM struct {
+	sess      *session.Session
+	pending   [][]byte      // console output not yet delivered to an attached client
+	output    chan struct{} // signalled when output is pending
+	detach    chan struct{} // signalled to end an Attach with vm.ErrUserDetach
+	attached  chan struct{} // closed when a client first attaches
+	stopped   chan struct{} // closed when the VM stops
+	changeset *changeset.SessionChangeset
+}
+
+var (
+	_ vm.Manager   = (*FakeManager)(nil)
+	_ vm.Suspender = (*FakeManager)(nil)
+)
+
+// NewFakeManager returns a FakeManager with no sessions
+func NewFakeManager() *FakeManager {
+	return &FakeManager{
+		vms:    make(map[string]*fakeVM),
+		faults: make(map[Op][]error),
+	}
+}
+
+// FailNext makes the next call of op fail with err. Calls queue up, so
+// FailNext(OpStart, a) then FailNext(OpStart, b) fails two starts in order.
+func (m *FakeManager) FailNext(op Op, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.faults[op] = append(m.faults[op], err)
+}
+
+// Calls returns the operations made so far, in order
+func (m *FakeManager) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Call(nil), m.calls...)
+}
+
+// Session returns a copy of a session's current state
+func (m *FakeManager) Session(id string) (*session.Session, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.vms[id]
+	if !ok {
+		return nil, false
+	}
+	return copySession(v.sess), true
+}
+
+// Create records a new session in the "created" state
+func (m *FakeManager) Create(cfg *vm.Config) (*session.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.begin(OpCreate, ""); err != nil {
+		return nil, err
+	}
+
+	m.nextID++
+	sess := &session.Session{
+		ID:             fmt.Sprintf("%06x", m.nextID),
+		Name:           cfg.Name,
+		Labels:         cfg.Labels,
+		ProjectDir:     cfg.ProjectDir,
+		Mounts:         cfg.Mounts,
+		Network:        cfg.Network,
+		CPUs:           cfg.CPUs,
+		Memory:         cfg.Memory,
+		Status:         "created",
+		StartedAt:      time.Now(),
+		ClaudeMode:     cfg.ClaudeMode,
+		CaptureNetwork: cfg.CaptureNetwork,
+		Ports:          cfg.Publish,
+		Warm:           cfg.Warm,
+		PreventSleep:   cfg.PreventSleep,
+		NetworkPrompt:  cfg.NetworkPrompt,
+		Notify:         cfg.Notify,
+		NetLimit:       cfg.NetLimit,
+		Resolvers:      cfg.Resolvers,
+		Hosts:          cfg.Hosts,
+		Proxy:          cfg.Proxy,
+		NoProxy:        cfg.NoProxy,
+	}
+	if cfg.Timeout > 0 {
+		sess.Timeout = cfg.Timeout.String()
+	}
+	m.vms[sess.ID] = &fakeVM{
+		sess:     sess,
+		output:   make(chan struct{}, 1),
+		detach:   make(chan struct{}, 1),
+		attached: make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	m.order = append(m.order, sess.ID)
+	if err := m.save(sess); err != nil {
+		return nil, err
+	}
+	return copySession(sess), nil
+}
+
+// Start moves a created session to "running"
+func (m *FakeManager) Start(sess *session.Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.begin(OpStart, sess.ID); err != nil {
+		return err
+	}
+	v, err := m.lookup(sess.ID)
+	if err != nil {
+		return err
+	}
+	if v.sess.Status != "created" {
+		return fmt.Errorf("session %s cannot be started (status: %s)", sess.ID, v.sess.Status)
+	}
+	v.sess.Status = "running"
+	return m.save(v.sess)
+}
+
+// Stop stops a running or paused session. Stopping a stopped session is a no-op.
+func (m *FakeManager) Stop(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.begin(OpStop, id); err != nil {
+		return err
+	}
+	v, err := m.lookup(id)
+	if err != nil {
+		return err
+	}
+	return m.stop(v, "")
+}
+
+// Exit simulates the guest powering off on its own (the agent exited, the
+// watchdog fired), recording reason as the session's exit reason
+func (m *FakeManager) Exit(id, reason string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := m.lookup(id)
+	if err != nil {
+		return err
+	}
+	return m.stop(v, reason)
+}
+
+// List returns copies of all sessions in creation order
+func (m *FakeManager) List() ([]*session.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sessions := make([]*session.Session, 0, len(m.order))
+	for _, id := range m.order {
+		sessions = append(sessions, copySession(m.vms[id].sess))
+	}
+	return sessions, nil
+}
+
+// Attach copies the session's console output to Console until the VM stops
+// (returning nil) or Detach is called (returning vm.ErrUserDetach)
+func (m *FakeManager) Attach(id string) error {
+	m.mu.Lock()
+	if err := m.begin(OpAttach, id); err != nil {
+		m.mu.Unlock()
+		return err
+	}
+	v, err := m.lookup(id)
+	if err == nil && v.sess.Status != "running" {
+		err = fmt.Errorf("session %s is not running (status: %s)", id, v.sess.Status)
+	}
+	if err == nil {
+		select {
+		case <-v.attached:
+		default:
+			close(v.attached)
+		}
+	}
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for {
+		if err := m.deliver(v); err != nil {
+			return fmt.Errorf("console error: %w", err)
+		}
+		select {
+		case <-v.output:
+		case <-v.detach:
+			return m.deliverDetached(v, vm.ErrUserDetach)
+		case <-v.stopped:
+			return m.deliverDetached(v, nil)
+		}
+	}
+}
+
+// WriteConsole queues output on a session's console. It is delivered to
+// Console by Attach, immediately if a client is attached.
+func (m *FakeManager) WriteConsole(id string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := m.lookup(id)
+	if err != nil {
+		return err
+	}
+	v.pending = append(v.pending, append([]byte(nil), data...))
+	select {
+	case v.output <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Detach ends the current Attach of a session as if the user typed ~.
+func (m *FakeManager) Detach(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := m.lookup(id)
+	if err != nil {
+		return err
+	}
+	select {
+	case v.detach <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// WaitForVMStop returns a channel closed when the session's VM stops. The
+// channel is already closed for unknown sessions.
+func (m *FakeManager) WaitForVMStop(id string) <-chan struct{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if v, ok := m.vms[id]; ok {
+		return v.stopped
+	}
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}
+
+// WaitForAttach returns a channel closed once a client has attached to the
+// session's console, so a test can script output after Attach has started
+func (m *FakeManager) WaitForAttach(id string) <-chan struct{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if v, ok := m.vms[id]; ok {
+		return v.attached
+	}
+	return make(chan struct{})
+}
+
+// SetChangeset scripts the changeset a session reports when it stops
+func (m *FakeManager) SetChangeset(id string, cs *changeset.SessionChangeset) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := m.lookup(id)
+	if err != nil {
+		return err
+	}
+	v.changeset = cs
+	return nil
+}
+
+// Suspend moves a running session to "paused"
+func (m *FakeManager) Suspend(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.begin(OpSuspend, id); err != nil {
+		return err
+	}
+	v, err := m.lookup(id)
+	if err != nil {
+		return err
+	}
+	if v.sess.Status != "running" {
+		return fmt.Errorf("session %s is not running (status: %s)", id, v.sess.Status)
+	}
+	v.sess.Status = "paused"
+	vm.PauseDeadline(v.sess)
+	return m.save(v.sess)
+}
+
+// Resume moves a paused session back to "running", from its record in Store
+// when set
+func (m *FakeManager) Resume(id string) (*session.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.begin(OpResume, id); err != nil {
+		return nil, err
+	}
+	v, err := m.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	if v.sess.Status != "paused" {
+		return nil, fmt.Errorf("session %s is not paused (status: %s)", id, v.sess.Status)
+	}
+	// Real backends restore the session from its record, which may have
+	// been updated since the session started
+	if m.Store != nil {
+		if rec, err := m.Store.Load(id); err == nil {
+			v.sess = rec
+		}
+	}
+	v.sess.Status = "running"
+	vm.ResumeDeadline(v.sess)
+	if err := m.save(v.sess); err != nil {
+		return nil, err
+	}
+	return copySession(v.sess), nil
+}
+
+// begin records a call and returns the injected failure for it, if any.
+// The caller holds m.mu.
+func (m *FakeManager) begin(op Op, id string) error {
+	m.calls = append(m.calls, Call{Op: op, ID: id})
+	if queued := m.faults[op]; len(queued) > 0 {
+		m.faults[op] = queued[1:]
+		return queued[0]
+	}
+	if m.Hook != nil {
+		return m.Hook(op, id)
+	}
+	return nil
+}
+
+// lookup returns a session's VM. The caller holds m.mu.
+func (m *FakeManager) lookup(id string) (*fakeVM, error) {
+	v, ok := m.vms[id]
+	if !ok {
+		return nil, fmt.Errorf("session not found: %s", id)
+	}
+	return v, nil
+}
+
+// stop marks a VM stopped and writes its changeset. The caller holds m.mu.
+func (m *FakeManager) stop(v *fakeVM, reason string) error {
+	if v.sess.Status == "stopped" {
+		return nil
+	}
+	now := time.Now()
+	v.sess.Status = "stopped"
+	v.sess.StoppedAt = &now
+	if reason != "" {
+		v.sess.ExitReason = reason
+	}
+	// Waiters wake once the stop is recorded, so their own updates land last
+	defer close(v.stopped)
+
+	if err := m.save(v.sess); err != nil {
+		return err
+	}
+	if m.Store != nil && v.changeset != nil {
+		bootstrapDir := filepath.Join(m.Store.Dir(), v.sess.ID, "bootstrap")
+		if err := os.MkdirAll(bootstrapDir, 0755); err != nil {
+			return fmt.Errorf("failed to create bootstrap directory: %w", err)
+		}
+		if err := changeset.SaveChangeset(filepath.Join(bootstrapDir, "changeset.json"), v.changeset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// save persists a session to Store, if set. The caller holds m.mu.
+func (m *FakeManager) save(sess *session.Session) error {
+	if m.Store == nil {
+		return nil
+	}
+	return m.Store.Save(copySession(sess))
+}
+
+// deliver writes pending console output to Console
+func (m *FakeManager) deliver(v *fakeVM) error {
+	m.mu.Lock()
+	pending := v.pending
+	v.pending = nil
+	m.mu.Unlock()
+
+	w := m.Console
+	if w == nil {
+		w = io.Discard
+	}
+	for _, data := range pending {
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deliverDetached flushes the last console output as Attach returns result
+func (m *FakeManager) deliverDetached(v *fakeVM, result error) error {
+	if err := m.deliver(v); err != nil {
+		return fmt.Errorf("console error: %w", err)
+	}
+	return result
+}
+
+// copySession returns a copy of sess that callers can modify freely
+func copySession(sess *session.Session) *session.Session {
+	c := *sess
+	c.Mounts = append([]session.VMMount(nil), sess.Mounts...)
+	c.Network = append([]string(nil), sess.Network...)
+	c.Ports = append([]session.PortForward(nil), sess.Ports...)
+	if sess.StoppedAt != nil {
+		t := *sess.StoppedAt
+		c.StoppedAt = &t
+	}
+	return &c
+}
@@ -0,0 +1,175 @@
+package vmtest
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/faize-ai/faize/internal/changeset"
+	"github.com/faize-ai/faize/internal/session"
+	"github.com/faize-ai/faize/internal/vm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRunning(t *testing.T, m *FakeManager) *session.Session {
+	t.Helper()
+	sess, err := m.Create(&vm.Config{ProjectDir: "/tmp/project", CPUs: 2, Memory: "4GB", Timeout: time.Hour})
+	require.NoError(t, err)
+	require.NoError(t, m.Start(sess))
+	return sess
+}
+
+func TestFakeManagerLifecycle(t *testing.T) {
+	m := NewFakeManager()
+
+	sess, err := m.Create(&vm.Config{ProjectDir: "/tmp/project", CPUs: 2, Memory: "4GB", Timeout: time.Hour})
+	require.NoError(t, err)
+	assert.Equal(t, "created", sess.Status)
+	assert.Equal(t, "1h0m0s", sess.Timeout)
+
+	require.NoError(t, m.Start(sess))
+	assert.Error(t, m.Start(sess), "a running session can't be started again")
+
+	require.NoError(t, m.Suspend(sess.ID))
+	got, ok := m.Session(sess.ID)
+	require.True(t, ok)
+	assert.Equal(t, "paused", got.Status)
+
+	resumed, err := m.Resume(sess.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "running", resumed.Status)
+
+	require.NoError(t, m.Stop(sess.ID))
+	require.NoError(t, m.Stop(sess.ID), "stopping twice is a no-op")
+	got, _ = m.Session(sess.ID)
+	assert.Equal(t, "stopped", got.Status)
+	assert.NotNil(t, got.StoppedAt)
+
+	select {
+	case <-m.WaitForVMStop(sess.ID):
+	default:
+		t.Fatal("expected WaitForVMStop to be closed after Stop")
+	}
+
+	list, err := m.List()
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, sess.ID, list[0].ID)
+
+	assert.EqualError(t, m.Stop("ffffff"), "session not found: ffffff")
+}
+
+func TestFakeManagerReturnsCopies(t *testing.T) {
+	m := NewFakeManager()
+	sess := newRunning(t, m)
+
+	sess.Status = "stopped"
+	got, _ := m.Session(sess.ID)
+	assert.Equal(t, "running", got.Status)
+}
+
+func TestFakeManagerConsole(t *testing.T) {
+	t.Run("streams output until detach", func(t *testing.T) {
+		m := NewFakeManager()
+		var out bytes.Buffer
+		m.Console = &out
+		sess := newRunning(t, m)
+		require.NoError(t, m.WriteConsole(sess.ID, []byte("booting\n")))
+
+		done := make(chan error)
+		go func() { done <- m.Attach(sess.ID) }()
+		<-m.WaitForAttach(sess.ID)
+		require.NoError(t, m.WriteConsole(sess.ID, []byte("ready\n")))
+		require.NoError(t, m.Detach(sess.ID))
+
+		assert.ErrorIs(t, <-done, vm.ErrUserDetach)
+		assert.Equal(t, "booting\nready\n", out.String())
+	})
+
+	t.Run("returns when the guest exits", func(t *testing.T) {
+		m := NewFakeManager()
+		var out bytes.Buffer
+		m.Console = &out
+		sess := newRunning(t, m)
+
+		done := make(chan error)
+		go func() { done <- m.Attach(sess.ID) }()
+		<-m.WaitForAttach(sess.ID)
+		require.NoError(t, m.WriteConsole(sess.ID, []byte("Claude exited with code: 0\n")))
+		require.NoError(t, m.Exit(sess.ID, "normal"))
+
+		assert.NoError(t, <-done)
+		assert.Equal(t, "Claude exited with code: 0\n", out.String())
+		got, _ := m.Session(sess.ID)
+		assert.Equal(t, "normal", got.ExitReason)
+	})
+
+	t.Run("requires a running session", func(t *testing.T) {
+		m := NewFakeManager()
+		sess, err := m.Create(&vm.Config{})
+		require.NoError(t, err)
+		assert.ErrorContains(t, m.Attach(sess.ID), "not running")
+	})
+}
+
+func TestFakeManagerFaults(t *testing.T) {
+	m := NewFakeManager()
+	boom := errors.New("boom")
+	m.FailNext(OpStart, boom)
+
+	sess, err := m.Create(&vm.Config{})
+	require.NoError(t, err)
+	assert.ErrorIs(t, m.Start(sess), boom)
+	assert.NoError(t, m.Start(sess), "a fault only fails one call")
+
+	m.Hook = func(op Op, id string) error {
+		if op == OpStop {
+			return vm.ErrVMNotImplemented
+		}
+		return nil
+	}
+	assert.ErrorIs(t, m.Stop(sess.ID), vm.ErrVMNotImplemented)
+
+	assert.Equal(t, []Call{
+		{Op: OpCreate},
+		{Op: OpStart, ID: sess.ID},
+		{Op: OpStart, ID: sess.ID},
+		{Op: OpStop, ID: sess.ID},
+	}, m.Calls())
+}
+
+func TestFakeManagerStore(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	store, err := session.NewStore()
+	require.NoError(t, err)
+
+	m := NewFakeManager()
+	m.Store = store
+	sess := newRunning(t, m)
+
+	saved, err := store.Load(sess.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "running", saved.Status)
+
+	cs := &changeset.SessionChangeset{
+		SessionID: sess.ID,
+		MountChanges: []changeset.MountChanges{{
+			Source:  "/tmp/project",
+			Target:  "/tmp/project",
+			Changes: []changeset.Change{{Path: "main.go", Type: "modified", OldSize: 10, NewSize: 20}},
+		}},
+	}
+	require.NoError(t, m.SetChangeset(sess.ID, cs))
+	require.NoError(t, m.Stop(sess.ID))
+
+	saved, err = store.Load(sess.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "stopped", saved.Status)
+
+	loaded, err := changeset.LoadChangeset(filepath.Join(store.Dir(), sess.ID, "bootstrap", "changeset.json"))
+	require.NoError(t, err)
+	assert.Equal(t, cs.MountChanges[0].Changes, loaded.MountChanges[0].Changes)
+}
@@ -0,0 +1,130 @@
+#!/bin/bash
+set -euo pipefail
+
+# Faize OCI Image Rootfs Builder
+# Flattens an OCI/Docker image into an ext4 rootfs and layers faize's init,
+# guest agent and claude user on top, so the image boots as a Claude session
+
+IMAGE="${1:?usage: build-image-rootfs.sh <image> <output>}"
+OUTPUT_PATH="${2:?usage: build-image-rootfs.sh <image> <output>}"
+SCRIPT_DIR="$(cd "$(dirname "$0")" && pwd)"
+REPO_DIR="$(cd "$SCRIPT_DIR/.." && pwd)"
+WORK_DIR=$(mktemp -d)
+SOURCE_ID=""
+BUILDER_ID=""
+
+cleanup() {
+    echo "Cleaning up..."
+    [ -n "$SOURCE_ID" ] && docker rm "$SOURCE_ID" >/dev/null 2>&1 || true
+    [ -n "$BUILDER_ID" ] && docker rm "$BUILDER_ID" >/dev/null 2>&1 || true
+    rm -rf "$WORK_DIR"
+}
+trap cleanup EXIT
+
+echo "==> Building Faize rootfs from image $IMAGE"
+echo "    Output: $OUTPUT_PATH"
+echo "    Work dir: $WORK_DIR"
+
+mkdir -p "$(dirname "$OUTPUT_PATH")" "$WORK_DIR/hooks"
+
+# Pull the image unless it is already local; a local copy is used as is, so
+# 'docker pull' updates it
+if ! docker image inspect "$IMAGE" >/dev/null 2>&1; then
+    echo "==> Pulling $IMAGE"
+    docker pull "$IMAGE"
+fi
+IMAGE_ID=$(docker image inspect --format '{{.Id}}' "$IMAGE")
+
+# Build the guest agent (runs the session inside the VM; see cmd/faize-agent)
+echo "==> Building faize guest agent"
+if [ ! -d "$REPO_DIR/cmd/faize-agent" ]; then
+    echo "Error: guest agent source not found at $REPO_DIR/cmd/faize-agent"
+    exit 1
+fi
+docker run --rm \
+    -v "$REPO_DIR:/src:ro" \
+    -v "$WORK_DIR/hooks:/out" \
+    -w /src \
+    -e CGO_ENABLED=0 \
+    -e GOFLAGS=-buildvcs=false \
+    golang:1.24-alpine \
+    go build -trimpath -ldflags="-s -w" -o /out/faize-agent ./cmd/faize-agent
+cp "$REPO_DIR/internal/rootfs/faize-init.sh" "$WORK_DIR/hooks/init"
+
+# Flatten the image's layers and build the ext4 image INSIDE a container, so
+# file ownership survives and Docker Desktop's bind mount sync on macOS is
+# bypassed; the result is extracted with docker cp
+echo "==> Flattening image and creating ext4 image"
+SOURCE_ID=$(docker create "$IMAGE" /bin/true)
+BUILDER_ID=$(docker create -i \
+    -v "$WORK_DIR/hooks:/hooks:ro" \
+    alpine:latest sh -c '
+        set -e
+        apk add --no-cache e2fsprogs >/dev/null 2>&1
+        mkdir -p /work/rootfs
+        tar -x -f - -C /work/rootfs
+        cd /work/rootfs
+
+        # Mount points and directories the init and guest agent expect
+        mkdir -p bin dev etc proc root run sys tmp home mnt/bootstrap mnt/host-claude opt/toolchain workspace usr/local/bin
+        chmod 1777 tmp
+        install -m 0755 /hooks/init init
+        install -m 0755 /hooks/faize-agent usr/local/bin/faize-agent
+        # faize checks the agent recorded here before booting the rootfs
+        echo "faize-agent=$(/hooks/faize-agent -version 2>/dev/null || echo 0)" > /tmp/manifest
+
+        # Non-root claude user for running Claude CLI, unless the image has one
+        touch etc/passwd etc/group etc/shadow
+        if ! grep -q "^claude:" etc/passwd; then
+            uid=1000
+            while cut -d: -f3 etc/passwd etc/group | grep -qx "$uid"; do
+                uid=$((uid + 1))
+            done
+            echo "claude:x:$uid:$uid::/home/claude:/bin/sh" >> etc/passwd
+            grep -q "^claude:" etc/group || echo "claude:x:$uid:" >> etc/group
+            # No password, but not locked: sshd refuses key logins to locked (!) accounts
+            echo "claude:*:19000:0:99999:7:::" >> etc/shadow
+        fi
+        owner=$(grep "^claude:" etc/passwd | cut -d: -f3,4)
+        mkdir -p home/claude/.claude
+        chown -R "$owner" home/claude
+
+        cat > opt/toolchain/env.sh << "ENVSH"
+#!/bin/bash
+# Toolchain environment (populated by first boot)
+export PATH=/opt/toolchain/bin:/usr/local/bin:$PATH
+ENVSH
+        chmod +x opt/toolchain/env.sh
+
+        # Sessions run the Claude CLI: install it with the npm in the image if missing
+        if ! chroot . /bin/sh -c "command -v claude" >/dev/null 2>&1; then
+            if chroot . /bin/sh -c "command -v npm" >/dev/null 2>&1; then
+                echo "Installing Claude Code CLI with the npm in the image..."
+                cp /etc/resolv.conf etc/resolv.conf 2>/dev/null || true
+                chroot . /bin/sh -c "npm install -g @anthropic-ai/claude-code" >/dev/null
+            else
+                echo "WARNING: the image has neither the claude CLI nor npm; Claude sessions need claude on PATH"
+            fi
+        fi
+
+        # Some headroom over the contents of the image
+        size=$(( $(du -sm . | cut -f1) + 512 ))
+        [ "$size" -lt 1024 ] && size=1024
+        mke2fs -t ext4 -d /work/rootfs -L faize-image \
+            -E no_copy_xattrs -b 4096 /tmp/rootfs.img ${size}M
+        e2fsck -f -y /tmp/rootfs.img >/dev/null 2>&1 || true
+    ')
+
+if ! docker export "$SOURCE_ID" | docker start -a -i "$BUILDER_ID"; then
+    echo "ERROR: Failed to create ext4 image inside container"
+    docker logs "$BUILDER_ID" 2>&1 || true
+    exit 1
+fi
+
+docker cp "$BUILDER_ID:/tmp/rootfs.img" "$OUTPUT_PATH"
+docker cp "$BUILDER_ID:/tmp/manifest" "$OUTPUT_PATH.manifest"
+echo "$IMAGE_ID" > "$OUTPUT_PATH.id"
+
+echo "==> Image rootfs build complete!"
+echo "    Location: $OUTPUT_PATH"
+echo "    Image: $IMAGE ($IMAGE_ID)"
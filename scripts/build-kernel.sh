@@ -1,19 +1,43 @@
 #!/bin/bash
-# Build uncompressed ARM64 Linux kernel for Apple Virtualization.framework
-# CRITICAL: Apple Silicon requires ARM64 Image format (not ELF vmlinux)
+# Build a minimal Linux kernel for faize VMs
+# arm64: uncompressed ARM64 Image, which Apple Virtualization.framework requires
+# (not ELF vmlinux); x86_64: bzImage for QEMU
 
 set -euo pipefail
 
 VERSION="${1:-6.6.10}"
 WORKDIR="${2:-$(mktemp -d)}"
 OUTPUT="${3:-./vmlinux}"
+ARCH="${4:-$(uname -m)}"
+
+# Kernel architecture, image target and toolchain for the requested architecture
+case "$ARCH" in
+    arm64|aarch64)
+        ARCH=arm64
+        KERNEL_ARCH=arm64
+        KERNEL_TARGET=Image
+        TOOLCHAIN=aarch64-linux-gnu-
+        DOCKER_PLATFORM=linux/arm64
+        ;;
+    x86_64|amd64)
+        ARCH=x86_64
+        KERNEL_ARCH=x86
+        KERNEL_TARGET=bzImage
+        TOOLCHAIN=x86_64-linux-gnu-
+        DOCKER_PLATFORM=linux/amd64
+        ;;
+    *)
+        echo "ERROR: unsupported architecture: $ARCH (expected arm64 or x86_64)"
+        exit 1
+        ;;
+esac
 
-echo "Building Linux kernel $VERSION..."
+echo "Building Linux kernel $VERSION for $ARCH..."
 echo "Work directory: $WORKDIR"
 
 # Get the directory where this script is located
 SCRIPT_DIR="$(cd "$(dirname "${BASH_SOURCE[0]}")" && pwd)"
-KERNEL_CONFIG="${SCRIPT_DIR}/kernel-config-arm64-minimal"
+KERNEL_CONFIG="${SCRIPT_DIR}/kernel-config-${ARCH}-minimal"
 
 # Verify kernel config exists
 if [[ ! -f "$KERNEL_CONFIG" ]]; then
@@ -49,26 +73,23 @@ build_kernel() {
     cat "$KERNEL_CONFIG" > .config
 
     echo "Configuring kernel..."
-    make ARCH=arm64 CROSS_COMPILE="${CROSS_COMPILE}" olddefconfig
+    make ARCH="${KERNEL_ARCH}" CROSS_COMPILE="${CROSS_COMPILE}" olddefconfig
 
     echo "Building kernel (this may take a while)..."
-    make ARCH=arm64 CROSS_COMPILE="${CROSS_COMPILE}" -j$(nproc) Image
+    make ARCH="${KERNEL_ARCH}" CROSS_COMPILE="${CROSS_COMPILE}" -j"${JOBS:-$(nproc)}" "${KERNEL_TARGET}"
 
     echo "Copying output..."
-    cp arch/arm64/boot/Image "$OUTPUT"
+    cp "arch/${KERNEL_ARCH}/boot/${KERNEL_TARGET}" "$OUTPUT"
 
     echo "Kernel built successfully!"
 }
 
-# Export function for Docker subshell
-export -f build_kernel
-
-# On macOS, use Docker for cross-compilation
+# On macOS, build in a Linux container of the target architecture
 if [[ "$(uname)" == "Darwin" ]]; then
-    echo "Detected macOS - using Docker for cross-compilation..."
+    echo "Detected macOS - building in Docker..."
 
     if ! command -v docker &> /dev/null; then
-        echo "ERROR: Docker is required on macOS for kernel cross-compilation."
+        echo "ERROR: Docker is required on macOS to build the kernel."
         echo "Install Docker Desktop from: https://www.docker.com/products/docker-desktop"
         exit 1
     fi
@@ -89,52 +110,31 @@ if [[ "$(uname)" == "Darwin" ]]; then
     echo "Output will be: $OUTPUT_ABS"
 
     # Run build inside Docker container (debian-slim is ~30MB vs ubuntu's ~78MB)
-    # Note: --ulimit nofile increases file descriptor limit to avoid "Too many open files" during parallel build
+    # of the target architecture, so its native gcc builds the kernel; build_kernel
+    # is passed in by definition.
+    # Note: --ulimit nofile and limited parallelism (JOBS) avoid "Too many open files"
+    # during the parallel build in Docker on macOS
     docker run --rm \
-        --platform linux/arm64 \
+        --platform "$DOCKER_PLATFORM" \
         --ulimit nofile=65536:65536 \
         -v "${WORKDIR}:/build" \
         -v "${OUTPUT_DIR}:/output" \
         -v "${KERNEL_CONFIG}:/kernel-config:ro" \
         -e VERSION="$VERSION" \
         -e OUTPUT_NAME="$OUTPUT_NAME" \
+        -e KERNEL_ARCH="$KERNEL_ARCH" \
+        -e KERNEL_TARGET="$KERNEL_TARGET" \
+        -e JOBS=4 \
+        -e BUILD_KERNEL="$(declare -f build_kernel)" \
         debian:bookworm-slim bash -c '
 set -euo pipefail
 
 echo "Installing build dependencies..."
 apt-get update -qq
-apt-get install -y -qq gcc-aarch64-linux-gnu make flex bison bc libssl-dev libelf-dev curl xz-utils >/dev/null
-
-cd /build
-
-# Download kernel source
-if [ ! -f "linux-${VERSION}.tar.xz" ]; then
-    echo "Downloading kernel source..."
-    curl -LO "https://cdn.kernel.org/pub/linux/kernel/v6.x/linux-${VERSION}.tar.xz"
-fi
-
-# Extract
-if [ ! -d "linux-${VERSION}" ]; then
-    echo "Extracting..."
-    tar xf "linux-${VERSION}.tar.xz"
-fi
+apt-get install -y -qq gcc make flex bison bc libssl-dev libelf-dev curl xz-utils >/dev/null
 
-cd "linux-${VERSION}"
-
-# Copy kernel config
-cat /kernel-config > .config
-
-echo "Configuring kernel..."
-make ARCH=arm64 CROSS_COMPILE=aarch64-linux-gnu- olddefconfig
-
-echo "Building kernel (this may take a while)..."
-# Use limited parallelism to avoid "Too many open files" in Docker on macOS
-make ARCH=arm64 CROSS_COMPILE=aarch64-linux-gnu- -j4 Image
-
-echo "Copying output..."
-cp arch/arm64/boot/Image "/output/${OUTPUT_NAME}"
-
-echo "Kernel built successfully!"
+eval "$BUILD_KERNEL"
+build_kernel "$VERSION" /build "/output/${OUTPUT_NAME}" "" /kernel-config
 '
 
     echo "Kernel built: $OUTPUT_ABS"
@@ -142,9 +142,20 @@ echo "Kernel built successfully!"
     exit 0
 fi
 
-# Linux: build directly with cross-compiler
-echo "Detected Linux - building with cross-compiler..."
-build_kernel "$VERSION" "$WORKDIR" "$OUTPUT" "aarch64-linux-gnu-" "$KERNEL_CONFIG"
+# Linux: build natively, or with a cross-compiler for another architecture
+CROSS_COMPILE=""
+case "$(uname -m)" in
+    aarch64|arm64) [[ "$ARCH" == arm64 ]] || CROSS_COMPILE="$TOOLCHAIN" ;;
+    x86_64|amd64) [[ "$ARCH" == x86_64 ]] || CROSS_COMPILE="$TOOLCHAIN" ;;
+    *) CROSS_COMPILE="$TOOLCHAIN" ;;
+esac
+if [[ -n "$CROSS_COMPILE" ]]; then
+    echo "Detected Linux - building with ${CROSS_COMPILE}gcc..."
+else
+    echo "Detected Linux - building natively..."
+fi
+OUTPUT_ABS="$(cd "$(dirname "$OUTPUT")" && pwd)/$(basename "$OUTPUT")"
+build_kernel "$VERSION" "$WORKDIR" "$OUTPUT_ABS" "$CROSS_COMPILE" "$KERNEL_CONFIG"
 
 echo "Kernel built: $OUTPUT"
 echo "Size: $(du -h "$OUTPUT" | cut -f1)"